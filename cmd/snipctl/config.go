@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+	"os"
+	"path/filepath"
+)
+
+// keyringService is what tokens are filed under in the OS keyring. Each is
+// stored against the URL of the server it's for.
+const keyringService = "snipctl"
+
+// secretStore is the part of the OS keyring that snipctl uses.
+type secretStore interface {
+	Get(service, user string) (string, error)
+	Set(service, user, secret string) error
+	Delete(service, user string) error
+}
+
+// osKeyring is the keyring of the user's desktop: the macOS Keychain, the
+// Windows Credential Manager or the Secret Service on Linux.
+type osKeyring struct{}
+
+func (osKeyring) Get(service, user string) (string, error) { return keyring.Get(service, user) }
+func (osKeyring) Set(service, user, secret string) error   { return keyring.Set(service, user, secret) }
+func (osKeyring) Delete(service, user string) error        { return keyring.Delete(service, user) }
+
+// config is what snipctl keeps in its config file. The token is only kept
+// here when there's no keyring to put it in, such as on a server without a
+// desktop session.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+}
+
+func (c *cli) configPath() string {
+	return filepath.Join(c.configDir, "snipctl", "config.json")
+}
+
+// loadConfig reads the config file, returning an empty config if there
+// isn't one yet.
+func (c *cli) loadConfig() (*config, error) {
+	cfg := &config{}
+
+	b, err := os.ReadFile(c.configPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.configPath(), err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config file, which only its owner can read as it
+// may hold a token.
+func (c *cli) saveConfig(cfg *config) error {
+	if err := os.MkdirAll(filepath.Dir(c.configPath()), 0o700); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.configPath(), append(b, '\n'), 0o600)
+}
+
+// credentials returns the server to talk to and the token to use, which
+// SNIPCTL_SERVER and SNIPCTL_TOKEN override. The token is empty if the user
+// hasn't logged in.
+func (c *cli) credentials() (server, token string, err error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return "", "", err
+	}
+
+	server = cfg.Server
+	if s := c.getenv("SNIPCTL_SERVER"); s != "" {
+		server = s
+	}
+	if server == "" {
+		return "", "", errors.New("no server configured; run snipctl login -server https://your.snippetbox first, or set SNIPCTL_SERVER")
+	}
+
+	if token = c.getenv("SNIPCTL_TOKEN"); token != "" {
+		return server, token, nil
+	}
+
+	token, err = c.keyring.Get(keyringService, server)
+	if err == nil {
+		return server, token, nil
+	}
+	if server == cfg.Server {
+		token = cfg.Token
+	}
+	return server, token, nil
+}
+
+// storeToken saves the token for server in the keyring, falling back to the
+// config file if there's no keyring. It reports where the token went.
+func (c *cli) storeToken(server, token string) (string, error) {
+	cfg := &config{Server: server}
+
+	err := c.keyring.Set(keyringService, server, token)
+	where := "the OS keyring"
+	if err != nil {
+		cfg.Token = token
+		where = c.configPath()
+	}
+
+	if err = c.saveConfig(cfg); err != nil {
+		return "", err
+	}
+	return where, nil
+}
+
+// forgetToken removes the token for the configured server from wherever it
+// was stored.
+func (c *cli) forgetToken() error {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Server == "" {
+		return nil
+	}
+
+	// A token in the config file means there was no keyring to put it in.
+	if cfg.Token == "" {
+		err = c.keyring.Delete(keyringService, cfg.Server)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("removing the token from the OS keyring: %w", err)
+		}
+	}
+
+	cfg.Token = ""
+	return c.saveConfig(cfg)
+}
@@ -0,0 +1,410 @@
+// Command snipctl creates and fetches snippets from the command line, using
+// a Snippetbox server's JSON API.
+//
+//	snipctl login -server https://snippets.example.com
+//	snipctl create -f main.go -lang go -expires 7d
+//	snipctl get 123 -o main.go
+//	snipctl list -mine
+//
+// login reads an API token, which is created on the account page, from
+// standard input and keeps it in the OS keyring, or in the config file if
+// there's no keyring. SNIPCTL_SERVER and SNIPCTL_TOKEN override both.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"github.com/ngohoang211020/snippetbox/pkg/client"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+const usage = `Usage: snipctl <command> [flags]
+
+Commands:
+  login   -server URL            store an API token, read from standard input
+  logout                         forget the stored token
+  create  [-f FILE] [-title T] [-lang L] [-expires 7d|never] [-burn]
+  get     ID [-o FILE]
+  list    [-mine] [-page N] [-per-page N]
+
+create, get and list take -format text or -format json.
+Run snipctl <command> -h for the flags of each command.
+`
+
+// errUsage is returned when the command line is wrong, after the problem has
+// been explained.
+var errUsage = errors.New("usage")
+
+// cli holds everything snipctl touches outside itself, so tests can provide
+// their own.
+type cli struct {
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	getenv    func(string) string
+	configDir string
+	keyring   secretStore
+}
+
+func main() {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snipctl: %v\n", err)
+		os.Exit(1)
+	}
+
+	c := &cli{
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		getenv:    os.Getenv,
+		configDir: configDir,
+		keyring:   osKeyring{},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = c.run(ctx, os.Args[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "snipctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, usage)
+		return errUsage
+	}
+
+	commands := map[string]func(context.Context, []string) error{
+		"login":  c.login,
+		"logout": c.logout,
+		"create": c.create,
+		"get":    c.get,
+		"list":   c.list,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			fmt.Fprint(c.stdout, usage)
+			return nil
+		}
+		fmt.Fprintf(c.stderr, "snipctl: unknown command %q\n\n%s", args[0], usage)
+		return errUsage
+	}
+	return command(ctx, args[1:])
+}
+
+// newFlagSet returns a flag set for the named command which reports errors
+// to stderr.
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("snipctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// parseFlags parses args, allowing flags to come after positional arguments
+// as well as before them, as in "snipctl get 123 -o file". It returns the
+// positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		// The flag set has already explained what was wrong.
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// formatFlag adds the -format flag to fs.
+func formatFlag(fs *flag.FlagSet, text string) *string {
+	return fs.String("format", "text", "Output format: "+text+" or json")
+}
+
+// newClient returns a client for the configured server.
+func (c *cli) newClient() (*client.Client, error) {
+	server, token, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := client.New(server, token)
+	if err != nil {
+		return nil, err
+	}
+	api.UserAgent = "snipctl"
+	return api, nil
+}
+
+func (c *cli) login(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("login")
+	server := fs.String("server", "", "URL of the Snippetbox server")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *server == "" {
+		cfg, err := c.loadConfig()
+		if err != nil {
+			return err
+		}
+		*server = cfg.Server
+	}
+	*server = strings.TrimSuffix(*server, "/")
+
+	// Check the URL before asking for a token.
+	if _, err := client.New(*server, ""); err != nil {
+		return fmt.Errorf("%w; use -server to say which server to log in to", err)
+	}
+
+	fmt.Fprintf(c.stderr, "Paste an API token from %s/account/tokens: ", *server)
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return errors.New("no token given")
+	}
+
+	where, err := c.storeToken(*server, token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stderr, "\nToken for %s saved in %s.\n", *server, where)
+	return nil
+}
+
+func (c *cli) logout(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("logout")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	return c.forgetToken()
+}
+
+func (c *cli) create(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("create")
+	file := fs.String("f", "-", "File to upload, or - for standard input")
+	title := fs.String("title", "", "Title (defaults to the file name, or the first line of standard input)")
+	lang := fs.String("lang", "", "Language (detected if not given)")
+	expires := fs.String("expires", "", "How long the snippet lasts, like 1d, 2w or 1y, or never, within the server's expiry policy (defaults to the policy's default)")
+	burn := fs.Bool("burn", false, "Delete the snippet after it has been read once")
+	format := formatFlag(fs, "text (the snippet's URL)")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	days, err := parseExpires(*expires)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	var filename string
+	if *file == "-" {
+		content, err = io.ReadAll(c.stdin)
+	} else {
+		content, err = os.ReadFile(*file)
+		filename = filepath.Base(*file)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *title == "" {
+		*title = filename
+		if *title == "" {
+			*title = firstLine(string(content))
+		}
+	}
+
+	api, err := c.newClient()
+	if err != nil {
+		return err
+	}
+
+	snippet, err := api.CreateSnippet(ctx, client.NewSnippet{
+		Title:            *title,
+		Content:          string(content),
+		Filename:         filename,
+		Language:         *lang,
+		Expires:          days,
+		BurnAfterReading: *burn,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		return c.writeJSON(snippet)
+	}
+	fmt.Fprintln(c.stdout, api.SnippetURL(snippet.ID))
+	return nil
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("get")
+	output := fs.String("o", "", "Write the content to this file instead of standard output")
+	format := formatFlag(fs, "text (the content)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(c.stderr, "Usage: snipctl get ID [-o FILE] [-format text|json]")
+		return errUsage
+	}
+	id, ok := publicid.Normalize(positional[0])
+	if !ok {
+		return fmt.Errorf("%q isn't a snippet ID", positional[0])
+	}
+
+	api, err := c.newClient()
+	if err != nil {
+		return err
+	}
+
+	snippet, err := api.GetSnippet(ctx, id)
+	if err != nil {
+		if client.IsNotFound(err) {
+			return fmt.Errorf("there's no snippet %s", id)
+		}
+		return err
+	}
+
+	if *format == "json" {
+		return c.writeJSON(snippet)
+	}
+	if *output != "" {
+		return os.WriteFile(*output, []byte(snippet.Content), 0o644)
+	}
+	_, err = io.WriteString(c.stdout, snippet.Content)
+	return err
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("list")
+	mine := fs.Bool("mine", false, "Only list your own snippets")
+	page := fs.Int("page", 1, "Page to show")
+	perPage := fs.Int("per-page", 20, "Snippets per page")
+	format := formatFlag(fs, "table")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	api, err := c.newClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := api.ListSnippets(ctx, client.ListOptions{Page: *page, PerPage: *perPage, Mine: *mine})
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		return c.writeJSON(result.Snippets)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tCREATED\tEXPIRES")
+	for _, s := range result.Snippets {
+		language := s.Language
+		if language == "" {
+			language = s.DetectedLanguage
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.Title, language, s.Created.Local().Format("2006-01-02 15:04"), s.Expires.Local().Format("2006-01-02"))
+	}
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+
+	if pages := (result.Total + *perPage - 1) / max(*perPage, 1); pages > 1 {
+		fmt.Fprintf(c.stderr, "Page %d of %d (%d snippets)\n", *page, pages, result.Total)
+	}
+	return nil
+}
+
+func (c *cli) writeJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseExpires turns a lifetime like "7d", "2w" or "1y" into the number of
+// days the API takes, "never" into client.NeverExpires and "" into zero, for
+// the server's default. Which lifetimes are allowed is up to the server's
+// expiry policy, so it's left to the server to check.
+func parseExpires(s string) (int, error) {
+	number := strings.ToLower(strings.TrimSpace(s))
+	switch number {
+	case "":
+		return 0, nil
+	case "never":
+		return client.NeverExpires, nil
+	}
+
+	unit := 1
+	switch {
+	case strings.HasSuffix(number, "d"):
+		number = strings.TrimSuffix(number, "d")
+	case strings.HasSuffix(number, "w"):
+		number, unit = strings.TrimSuffix(number, "w"), 7
+	case strings.HasSuffix(number, "y"):
+		number, unit = strings.TrimSuffix(number, "y"), 365
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("-expires must be a number of days, weeks or years, like 7d, 2w or 1y, or never, not %q", s)
+	}
+	return n * unit, nil
+}
+
+// firstLine returns the first non-blank line of content, cut to the 100
+// characters a title can have.
+func firstLine(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > 100 {
+			line = string([]rune(line)[:99]) + "…"
+		}
+		return line
+	}
+	return "Untitled"
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/pkg/client"
+	"github.com/zalando/go-keyring"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKeyring is an in-memory keyring. A broken one fails like a machine
+// without a keyring does.
+type fakeKeyring struct {
+	secrets map[string]string
+	broken  bool
+}
+
+func (k *fakeKeyring) Get(service, user string) (string, error) {
+	if k.broken {
+		return "", errors.New("no keyring")
+	}
+	secret, ok := k.secrets[service+"/"+user]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return secret, nil
+}
+
+func (k *fakeKeyring) Set(service, user, secret string) error {
+	if k.broken {
+		return errors.New("no keyring")
+	}
+	k.secrets[service+"/"+user] = secret
+	return nil
+}
+
+func (k *fakeKeyring) Delete(service, user string) error {
+	if k.broken {
+		return errors.New("no keyring")
+	}
+	delete(k.secrets, service+"/"+user)
+	return nil
+}
+
+// fakeAPI answers like a Snippetbox server with one snippet, which belongs
+// to whoever has the token "TOKEN".
+func fakeAPI(t *testing.T) *httptest.Server {
+	snippet := map[string]any{"id": "01HZX3V4Q8K2M5N7P9R1S3T5V7", "title": "Hello", "content": "package main\n", "language": "go", "created": "2024-01-02T03:04:05Z", "expires": "2025-01-02T03:04:05Z"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/snippets/01HZX3V4Q8K2M5N7P9R1S3T5V7", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"snippet": snippet})
+	})
+	mux.HandleFunc("/api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if r.Header.Get("Authorization") != "Bearer TOKEN" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "you must be authenticated to access this resource"}`))
+				return
+			}
+
+			var input map[string]any
+			json.NewDecoder(r.Body).Decode(&input)
+			if input["expires"] != 7.0 || input["filename"] != "main.go" || input["title"] != "main.go" {
+				t.Errorf("unexpected snippet %v", input)
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"snippet": snippet})
+			return
+		}
+
+		assert.Equal(t, r.URL.Query().Get("mine"), "true")
+		w.Header().Set("X-Total-Count", "1")
+		json.NewEncoder(w).Encode(map[string]any{"snippets": []any{snippet}})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "the requested resource could not be found"}`))
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestCLI(t *testing.T, k *fakeKeyring) (*cli, *bytes.Buffer) {
+	stdout := new(bytes.Buffer)
+	return &cli{
+		stdin:     strings.NewReader(""),
+		stdout:    stdout,
+		stderr:    new(bytes.Buffer),
+		getenv:    func(string) string { return "" },
+		configDir: t.TempDir(),
+		keyring:   k,
+	}, stdout
+}
+
+func TestSnipctl(t *testing.T) {
+	ts := fakeAPI(t)
+	ctx := context.Background()
+
+	k := &fakeKeyring{secrets: map[string]string{}}
+	c, stdout := newTestCLI(t, k)
+
+	// Nothing works before logging in.
+	err := c.run(ctx, []string{"list"})
+	assert.StringContains(t, err.Error(), "no server configured")
+
+	c.stdin = strings.NewReader("TOKEN\n")
+	err = c.run(ctx, []string{"login", "-server", ts.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, k.secrets["snipctl/"+ts.URL], "TOKEN")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0o644)
+
+	err = c.run(ctx, []string{"create", "-f", file, "-lang", "go", "-expires", "7d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stdout.String(), ts.URL+"/snippet/view/01HZX3V4Q8K2M5N7P9R1S3T5V7\n")
+
+	stdout.Reset()
+	err = c.run(ctx, []string{"get", "01HZX3V4Q8K2M5N7P9R1S3T5V7", "-o", filepath.Join(dir, "out.go")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "out.go"))
+	assert.Equal(t, string(b), "package main\n")
+
+	err = c.run(ctx, []string{"get", "01HZX3V4Q8K2M5N7P9R1S3T5V8"})
+	assert.Equal(t, err.Error(), "there's no snippet 01HZX3V4Q8K2M5N7P9R1S3T5V8")
+
+	stdout.Reset()
+	err = c.run(ctx, []string{"list", "--mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, stdout.String(), "ID                          TITLE  LANGUAGE")
+	assert.StringContains(t, stdout.String(), "01HZX3V4Q8K2M5N7P9R1S3T5V7  Hello  go")
+
+	stdout.Reset()
+	err = c.run(ctx, []string{"list", "-mine", "-format", "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, stdout.String(), `"title": "Hello"`)
+
+	err = c.run(ctx, []string{"logout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(k.secrets), 0)
+
+	err = c.run(ctx, []string{"create", "-f", file})
+	assert.StringContains(t, err.Error(), "401 Unauthorized")
+}
+
+func TestTokenWithoutKeyring(t *testing.T) {
+	c, _ := newTestCLI(t, &fakeKeyring{broken: true})
+
+	c.stdin = strings.NewReader("TOKEN\n")
+	err := c.run(context.Background(), []string{"login", "-server", "https://snippets.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(c.configPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0o600))
+
+	server, token, err := c.credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, server, "https://snippets.example.com")
+	assert.Equal(t, token, "TOKEN")
+
+	// The environment overrides what's stored.
+	c.getenv = func(key string) string {
+		return map[string]string{"SNIPCTL_TOKEN": "OTHER"}[key]
+	}
+	_, token, _ = c.credentials()
+	assert.Equal(t, token, "OTHER")
+
+	err = c.run(context.Background(), []string{"logout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.getenv = func(string) string { return "" }
+	_, token, _ = c.credentials()
+	assert.Equal(t, token, "")
+}
+
+func TestParseExpires(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"1d", 1, false},
+		{"7d", 7, false},
+		{"1w", 7, false},
+		{"365d", 365, false},
+		{"1Y", 365, false},
+		{"30d", 30, false},
+		{"14", 14, false},
+		{"never", client.NeverExpires, false},
+		{"", 0, false},
+		{"0d", 0, true},
+		{"-1", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseExpires(tt.in)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	c, _ := newTestCLI(t, &fakeKeyring{secrets: map[string]string{}})
+
+	for _, args := range [][]string{{}, {"frobnicate"}, {"get"}, {"get", "1", "2"}, {"list", "-nope"}} {
+		err := c.run(context.Background(), args)
+		assert.Equal(t, err, errUsage)
+	}
+}
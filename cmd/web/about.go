@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// about shows the site's statistics. They're counted in the background, so
+// they can be up to ten minutes old, except the first time the page is shown
+// after the server starts.
+func (app *application) about(w http.ResponseWriter, r *http.Request) {
+	stats := app.instanceStats.Load()
+	if stats == nil {
+		if err := app.refreshInstanceStats(); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		stats = app.instanceStats.Load()
+	}
+
+	render(app, w, http.StatusOK, "about.tmpl.html", &aboutPage{
+		templateBase: app.newTemplateBase(r),
+		Stats:        stats,
+		Uptime:       humanUptime(time.Since(app.started)),
+		Version:      buildVersion(),
+	})
+}
+
+// refreshInstanceStats counts the statistics shown on the about page again.
+func (app *application) refreshInstanceStats() error {
+	stats, err := app.snippets.Stats()
+	if err != nil {
+		return err
+	}
+	app.instanceStats.Store(stats)
+	return nil
+}
+
+// humanUptime says how long the server has been up in its two largest units,
+// like "3 days, 4 hours", or "less than a minute".
+func humanUptime(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+
+	var parts []string
+	for _, u := range units {
+		n := int(d / u.size)
+		if n == 0 {
+			if len(parts) > 0 {
+				break
+			}
+			continue
+		}
+		d -= time.Duration(n) * u.size
+
+		part := fmt.Sprintf("%d %s", n, u.name)
+		if n != 1 {
+			part += "s"
+		}
+		if parts = append(parts, part); len(parts) == 2 {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		return "less than a minute"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// buildVersion returns the version the server was built as: its module
+// version when it was installed with go install, or else the commit it was
+// built from, marked if there were changes which weren't committed.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return "development"
+	}
+
+	revision = revision[:min(len(revision), 12)]
+	if modified == "true" {
+		revision += " (modified)"
+	}
+	return revision
+}
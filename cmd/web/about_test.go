@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAbout(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/about")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<th>Public snippets</th>\n        <td>1</td>")
+	assert.StringContains(t, body, "<th>Users</th>\n        <td>2</td>")
+	assert.StringContains(t, body, "<td>less than a minute</td>")
+	assert.StringContains(t, body, "<a href='/language/plaintext'>Plain text</a>")
+
+	// The statistics are kept until they're refreshed.
+	assert.Equal(t, app.instanceStats.Load().PublicSnippets, 1)
+}
+
+func TestHumanUptime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "less than a minute"},
+		{time.Minute, "1 minute"},
+		{2*time.Hour + 5*time.Minute, "2 hours, 5 minutes"},
+		{3*24*time.Hour + 4*time.Hour + 59*time.Minute, "3 days, 4 hours"},
+		{24*time.Hour + 10*time.Minute, "1 day"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, humanUptime(tt.d), tt.want)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// emailChangeTTL is how long a user has to confirm a change of email address.
+const emailChangeTTL = 24 * time.Hour
+
+type accountEmailUpdateForm struct {
+	NewEmail            string `form:"newEmail"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountEmailUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountEmailUpdateForm{}
+	app.render(w, http.StatusOK, "email.tmpl.html", data)
+}
+
+// accountEmailUpdatePost starts a change of email address. The address isn't
+// changed until the user follows the link sent to the new address, and the
+// old address gets a notice in case the change wasn't made by its owner.
+func (app *application) accountEmailUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountEmailUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewEmail), "newEmail", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.NewEmail, validator.EmailRX), "newEmail", "This field must be a valid email address")
+	form.CheckField(form.NewEmail != user.Email, "newEmail", "This is already your email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() {
+		authenticatedID, err := app.users.Authenticate(user.Email, form.Password)
+		if err != nil && !errors.Is(err, models.ErrInvalidCredentials) {
+			app.serverError(w, err)
+			return
+		}
+		form.CheckField(err == nil && authenticatedID == id, "password", "Password is incorrect")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "email.tmpl.html", data)
+		return
+	}
+
+	// The link goes to -base-url, never to whatever host the request
+	// claims, so without one there's nowhere to send it.
+	if app.baseURL == "" {
+		app.errorLog.Print("not sending an email confirmation link: -base-url isn't set")
+		form.AddNonFieldError("Confirmation links can't be sent right now. Please try again later.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusServiceUnavailable, "email.tmpl.html", data)
+		return
+	}
+
+	// The new address is only known to the link, which is signed so that
+	// it can't be changed to another.
+	link, err := app.signedURL(urlFor("account.email.confirm"), url.Values{"uid": {strconv.Itoa(id)}, "email": {form.NewEmail}}, emailChangeTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.sendEmail(form.NewEmail, "verify_email", mailer.VerifyEmailData{
+		Layout: app.emailLayout(),
+		Name:   user.Name,
+		Link:   link,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.sendEmail(user.Email, "email_changed", mailer.EmailChangedData{
+		Layout:   app.emailLayout(),
+		Name:     user.Name,
+		NewEmail: form.NewEmail,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a confirmation link to %s.", form.NewEmail))
+	http.Redirect(w, r, urlFor("account.view"), http.StatusSeeOther)
+}
+
+// emailConfirmation is what the email confirmation page shows: the new
+// address, and the signed link which the form posts back to.
+type emailConfirmation struct {
+	NewEmail string
+	Action   string
+}
+
+// accountEmailConfirm shows the page which the confirmation link points to.
+// The change itself is only made when the form on the page is submitted, so
+// that email scanners which follow links don't confirm it by accident.
+func (app *application) accountEmailConfirm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = emailConfirmation{
+		NewEmail: signedurl.FromContext(r.Context()).Params.Get("email"),
+		Action:   r.URL.RequestURI(),
+	}
+	app.render(w, http.StatusOK, "email_confirm.tmpl.html", data)
+}
+
+// accountEmailConfirmPost changes the address. The link it's posted to
+// only works once.
+func (app *application) accountEmailConfirmPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := signedURLUser(r)
+	if !ok {
+		app.signedURLFailed(w, r, signedurl.ErrInvalid)
+		return
+	}
+
+	err := app.users.SetEmail(id, signedurl.FromContext(r.Context()).Params.Get("email"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateEmail):
+			app.sessionManager.Put(r.Context(), "flash", "That email address is already in use by another account.")
+		default:
+			app.serverError(w, err)
+			return
+		}
+		http.Redirect(w, r, urlFor("home"), http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been changed.")
+
+	if app.isAuthenticated(r) {
+		http.Redirect(w, r, urlFor("account.view"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAccountEmailUpdatePost(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/account/email/update")
+
+	tests := []struct {
+		name      string
+		newEmail  string
+		password  string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:      "Invalid email",
+			newEmail:  "alice@",
+			password:  "pa$$word",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be a valid email address",
+		},
+		{
+			name:      "Same email",
+			newEmail:  "alice@example.com",
+			password:  "pa$$word",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This is already your email address",
+		},
+		{
+			name:      "Wrong password",
+			newEmail:  "alice.new@example.com",
+			password:  "wrong",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Password is incorrect",
+		},
+		{
+			name:     "Valid",
+			newEmail: "alice.new@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mail.sent = nil
+
+			form := url.Values{}
+			form.Add("newEmail", tt.newEmail)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/account/email/update", form)
+			sendEmails(t, app)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+				assert.Equal(t, len(mail.sent), 0)
+				return
+			}
+
+			// The confirmation link goes to the new address, and a notice to
+			// the old one.
+			assert.Equal(t, len(mail.sent), 2)
+			assert.Equal(t, mail.sent[0].To, "alice.new@example.com")
+			link, err := url.Parse(emailLink(t, mail.sent[0].Body, "/account/email/confirm"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, link.Query().Get("email"), "alice.new@example.com")
+			assert.Equal(t, mail.sent[1].To, "alice@example.com")
+		})
+	}
+}
+
+func TestAccountEmailUpdatePostLink(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("newEmail", "alice.new@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", ts.csrfToken(t, "/account/email/update"))
+
+	// The link is built from -base-url, whatever the Host header says.
+	code, _, _ := ts.postFormWithHost(t, "evil.example", "/account/email/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	sendEmails(t, app)
+	assert.Equal(t, len(mail.sent), 2)
+	assert.StringContains(t, mail.sent[0].Body, app.baseURL+"/account/email/confirm?")
+	assert.Equal(t, strings.Contains(mail.sent[0].Body, "evil.example"), false)
+
+	// Without -base-url, no link is sent.
+	mail.sent = nil
+	app.baseURL = ""
+	code, _, body := ts.postForm(t, "/account/email/update", form)
+	sendEmails(t, app)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, "Confirmation links can&#39;t be sent right now")
+	assert.Equal(t, len(mail.sent), 0)
+}
+
+func TestAccountEmailConfirm(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	sign := func(email string) string {
+		link, err := app.urlSigner.Sign("/account/email/confirm", url.Values{"uid": {"1"}, "email": {email}}, emailChangeTTL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link
+	}
+
+	valid := sign("alice.new@example.com")
+
+	code, _, body := ts.get(t, valid)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<strong>alice.new@example.com</strong>")
+	assert.StringContains(t, body, "<form action='"+html.EscapeString(valid)+"' method='POST'>")
+	csrfToken := extractCSRFToken(t, body)
+
+	// The address can't be changed to another.
+	code, _, body = ts.get(t, strings.Replace(valid, "alice.new", "mallory", 1))
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "This link is invalid or has expired.")
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, valid, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Your email address has been changed.")
+
+	// Each link only works once.
+	code, _, body = ts.postForm(t, valid, form)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "This link has already been used.")
+
+	code, headers, _ = ts.postForm(t, sign("admin@example.com"), form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "That email address is already in use by another account.")
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/activitypub"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The instance is published on the Fediverse as a single actor, so people
+// can follow it from Mastodon and the like to see new public snippets.
+// Everything it publishes needs absolute URLs which stay the same wherever
+// they're fetched from, so it's all 404s unless -base-url is set.
+//
+// The actor, its outbox and its notes are read-only and always available
+// once there's a base URL. Taking followers and sending them activities
+// also needs the activitypub feature flag, as it has us fetching documents
+// from and posting to other servers.
+
+// activityPubUsername is the actor's preferredUsername, and the user part
+// of its acct: URI.
+const activityPubUsername = "snippetbox"
+
+// activityPubBatch is how many deliveries are made each time
+// deliverActivities runs.
+const activityPubBatch = 50
+
+// maxNoteContent is how much of a snippet's content goes in its note. The
+// rest is a click away.
+const maxNoteContent = 1000
+
+// parseBaseURL checks the -base-url flag, which must be an absolute http or
+// https URL without a path, and returns it without a trailing slash.
+func parseBaseURL(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("-base-url %q must be an http or https URL", s)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("-base-url %q must not have a path, query or user", s)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// loadActivityPubKey returns the actor's key, making one the first time the
+// application is run with a base URL.
+func loadActivityPubKey(m models.ActivityPubModelInterface, keyID string) (*activitypub.Key, string, error) {
+	public, private, err := m.Key()
+	if errors.Is(err, models.ErrNoRecord) {
+		public, private, err = activitypub.GenerateKey()
+		if err != nil {
+			return nil, "", err
+		}
+		if err = m.InsertKey(public, private); err != nil {
+			return nil, "", err
+		}
+		// Another instance may have stored its key first.
+		public, private, err = m.Key()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	k, err := activitypub.ParsePrivateKey(private)
+	if err != nil {
+		return nil, "", err
+	}
+	return &activitypub.Key{ID: keyID, Private: k}, public, nil
+}
+
+// apURL returns the absolute URL of the named route, for use in ActivityPub
+// documents.
+func (app *application) apURL(name string, args ...any) string {
+	return app.baseURL + urlFor(name, args...)
+}
+
+func (app *application) actorURL() string {
+	return app.apURL("activitypub.actor")
+}
+
+// federating reports whether the instance takes followers and sends them
+// activities. It doesn't while the instance protects its source, as the
+// activities carry the snippets' content.
+func (app *application) federating() bool {
+	return app.baseURL != "" && app.activityPubKey != nil && app.features.Enabled(features.ActivityPub) && !app.protectsSource()
+}
+
+// federated reports whether a snippet may be published over ActivityPub.
+// Only snippets anyone can read are, and burn after reading and encrypted
+// snippets never are, as a copy of them would end up on other servers.
+func federated(s *models.Snippet) bool {
+	return s.VisibleTo(0) && !s.BurnAfterReading && !s.ContentEncrypted && !s.OrgOnly
+}
+
+func (app *application) writeActivityPub(w http.ResponseWriter, doc any) {
+	js, err := json.Marshal(doc)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", activitypub.ContentType)
+	w.Write(js)
+}
+
+// requireActivityPub turns the ActivityPub routes into 404s when there's no
+// base URL to publish them under.
+func (app *application) requireActivityPub(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.baseURL == "" || app.activityPubKey == nil {
+			app.apiNotFound(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) activityPubActor(w http.ResponseWriter, r *http.Request) {
+	actor := app.actorURL()
+	app.writeActivityPub(w, activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actor,
+		Type:              "Service",
+		PreferredUsername: activityPubUsername,
+		Name:              "Snippetbox",
+		Summary:           "New public snippets from " + app.baseURL,
+		URL:               app.apURL("home"),
+		Inbox:             app.apURL("activitypub.inbox"),
+		Outbox:            app.apURL("activitypub.outbox"),
+		Followers:         app.apURL("activitypub.followers"),
+		PublicKey: activitypub.PublicKey{
+			ID:           app.activityPubKey.ID,
+			Owner:        actor,
+			PublicKeyPem: app.activityPubPublicKey,
+		},
+	})
+}
+
+// activityPubOutbox lists a Create activity for each of the latest public
+// snippets.
+func (app *application) activityPubOutbox(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Latest()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	items := []any{}
+	for _, s := range snippets {
+		if federated(s) {
+			items = append(items, app.createActivity(s))
+		}
+	}
+
+	app.writeActivityPub(w, activitypub.OrderedCollection{
+		Context:      activitypub.Context[0],
+		ID:           app.apURL("activitypub.outbox"),
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	})
+}
+
+// activityPubFollowers only says how many followers there are. Who they
+// are is their business.
+func (app *application) activityPubFollowers(w http.ResponseWriter, r *http.Request) {
+	n, err := app.activityPub.FollowerCount()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.writeActivityPub(w, activitypub.OrderedCollection{
+		Context:    activitypub.Context[0],
+		ID:         app.apURL("activitypub.followers"),
+		Type:       "OrderedCollection",
+		TotalItems: n,
+	})
+}
+
+func (app *application) activityPubNote(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.apiNotFound(w)
+		return
+	}
+
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	if !federated(s) {
+		app.apiNotFound(w)
+		return
+	}
+
+	note := app.note(s)
+	note.Context = activitypub.Context[0]
+	app.writeActivityPub(w, note)
+}
+
+// note is how a snippet is published: its title, the start of its content
+// and a link to the rest.
+func (app *application) note(s *models.Snippet) *activitypub.Note {
+	link := app.apURL("snippet.view", s.PublicID)
+
+	content := []rune(s.Content)
+	more := len(content) > maxNoteContent
+	if more {
+		content = content[:maxNoteContent]
+	}
+
+	// Snippets which are still being created haven't been read back from
+	// the database, so they may not have their times yet.
+	published := s.PublishAt
+	if published.IsZero() {
+		published = s.Created
+	}
+	if published.IsZero() {
+		published = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p><strong>%s</strong></p>", html.EscapeString(s.Title))
+	fmt.Fprintf(&b, "<pre><code>%s", html.EscapeString(string(content)))
+	if more {
+		b.WriteString("\n…")
+	}
+	fmt.Fprintf(&b, "</code></pre><p><a href=\"%s\">%s</a></p>", link, link)
+
+	return &activitypub.Note{
+		ID:           app.apURL("activitypub.note", s.PublicID),
+		Type:         "Note",
+		AttributedTo: app.actorURL(),
+		Name:         s.Title,
+		Content:      b.String(),
+		URL:          link,
+		Published:    published.UTC(),
+		To:           []string{activitypub.Public},
+		Cc:           []string{app.apURL("activitypub.followers")},
+	}
+}
+
+func (app *application) createActivity(s *models.Snippet) *activitypub.Activity {
+	note := app.note(s)
+	return &activitypub.Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    note,
+		Published: &note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+	}
+}
+
+// publishActivity queues a Create activity for a new public snippet, to be
+// delivered to every follower. As with webhooks, failing to queue it is
+// only logged.
+func (app *application) publishActivity(s *models.Snippet) {
+	if !app.federating() || !federated(s) {
+		return
+	}
+
+	err := app.enqueueActivity(app.createActivity(s))
+	if err != nil {
+		app.errorLog.Printf("queue activity for snippet %d: %s", s.ID, err)
+	}
+}
+
+func (app *application) enqueueActivity(a *activitypub.Activity, inboxes ...string) error {
+	if len(inboxes) == 0 {
+		var err error
+		inboxes, err = app.activityPub.FollowerInboxes()
+		if err != nil || len(inboxes) == 0 {
+			return err
+		}
+	}
+
+	a.Context = activitypub.Context[0]
+	js, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return app.activityPub.Enqueue(inboxes, string(js))
+}
+
+// activityPubInbox takes activities from other servers. Only following and
+// unfollowing the instance mean anything to it; everything else is
+// accepted and ignored, as other servers send all sorts.
+func (app *application) activityPubInbox(w http.ResponseWriter, r *http.Request) {
+	if !app.federating() {
+		app.apiNotFound(w)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBodyBytes))
+	if err != nil {
+		app.apiError(w, http.StatusRequestEntityTooLarge, "the request body is too large")
+		return
+	}
+
+	var activity activitypub.Incoming
+	if err = json.Unmarshal(body, &activity); err != nil || activity.Actor == "" {
+		app.apiError(w, http.StatusBadRequest, "the request body must be an activity")
+		return
+	}
+
+	// The request must be signed by the actor the activity claims is doing
+	// it, with the key published in their actor document.
+	var actor *activitypub.Actor
+	_, err = activitypub.Verify(r, body, func(keyID string) (*rsa.PublicKey, error) {
+		owner, _, _ := strings.Cut(keyID, "#")
+		if owner != activity.Actor {
+			return nil, activitypub.ErrInvalidSignature
+		}
+		a, err := activitypub.FetchActor(r.Context(), app.webhookSender.PublicClient, app.activityPubKey, owner)
+		if err != nil {
+			return nil, err
+		}
+		if a.PublicKey.ID != keyID || a.PublicKey.Owner != a.ID {
+			return nil, activitypub.ErrInvalidSignature
+		}
+		actor = a
+		return activitypub.ParsePublicKey(a.PublicKey.PublicKeyPem)
+	})
+	if err != nil {
+		app.infoLog.Printf("activitypub: rejected %s from %s: %s", activity.Type, activity.Actor, err)
+		app.apiError(w, http.StatusUnauthorized, "the request must be signed by the activity's actor")
+		return
+	}
+
+	switch activity.Type {
+	case "Follow":
+		if activity.ObjectID() != app.actorURL() {
+			break
+		}
+		if err = app.activityPub.AddFollower(actor.ID, actor.DeliveryInbox()); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		accept := &activitypub.Activity{
+			ID:     fmt.Sprintf("%s#accept-%x", app.actorURL(), sha256.Sum256(body)),
+			Type:   "Accept",
+			Actor:  app.actorURL(),
+			Object: json.RawMessage(body),
+		}
+		if err = app.enqueueActivity(accept, actor.Inbox); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		app.infoLog.Printf("activitypub: %s followed the instance", actor.ID)
+
+	case "Undo":
+		inner, err := activity.ObjectActivity()
+		if err != nil || inner.Type != "Follow" || inner.Actor != actor.ID {
+			break
+		}
+		if err = app.activityPub.RemoveFollower(actor.ID); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		app.infoLog.Printf("activitypub: %s unfollowed the instance", actor.ID)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// webFinger answers WebFinger lookups for the actor, which is how people
+// find it by typing @snippetbox@host into the search box of their server.
+func (app *application) webFinger(w http.ResponseWriter, r *http.Request) {
+	if app.baseURL == "" || app.activityPubKey == nil {
+		app.apiNotFound(w)
+		return
+	}
+
+	user, host, err := activitypub.ParseAcct(r.URL.Query().Get("resource"))
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "the resource must be an acct: URI")
+		return
+	}
+	base, _ := url.Parse(app.baseURL)
+	if user != activityPubUsername || host != strings.ToLower(base.Host) {
+		app.apiNotFound(w)
+		return
+	}
+
+	js, err := json.Marshal(activitypub.WebFinger{
+		Subject: "acct:" + activityPubUsername + "@" + base.Host,
+		Aliases: []string{app.actorURL()},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: app.actorURL()},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: app.apURL("home")},
+		},
+	})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(js)
+}
+
+// deliverActivities sends queued activities to followers' inboxes, signed
+// with the actor's key.
+func (app *application) deliverActivities() error {
+	if !app.federating() {
+		return nil
+	}
+
+	due, err := app.activityPub.Due(activityPubBatch)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, d := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		sendErr := activitypub.Deliver(ctx, app.webhookSender.PublicClient, app.activityPubKey, d.Inbox, []byte(d.Activity))
+		cancel()
+
+		if sendErr == nil {
+			err = app.activityPub.Delivered(d.ID)
+		} else {
+			failed++
+			var retryAt time.Time
+			if delay, ok := webhooks.RetryDelay(d.Attempts); ok {
+				retryAt = time.Now().Add(delay)
+			}
+			err = app.activityPub.Failed(d.ID, sendErr.Error(), retryAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		app.infoLog.Printf("%d of %d activity deliveries failed", failed, len(due))
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/activitypub"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// withActivityPub publishes the instance at https://snippets.example, and
+// turns on the activitypub flag if federating is set.
+func withActivityPub(t *testing.T, federating bool) testOption {
+	return func(app *application) {
+		app.baseURL = "https://snippets.example"
+		app.features = features.New(&mocks.FeatureModel{}, map[string]bool{features.ActivityPub: federating})
+
+		var err error
+		app.activityPubKey, app.activityPubPublicKey, err = loadActivityPubKey(app.activityPub, app.actorURL()+"#main-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "https://snippets.example", want: "https://snippets.example"},
+		{in: "https://snippets.example/", want: "https://snippets.example"},
+		{in: "http://localhost:4000", want: "http://localhost:4000"},
+		{in: "snippets.example", wantErr: true},
+		{in: "ftp://snippets.example", wantErr: true},
+		{in: "https://snippets.example/snippetbox", wantErr: true},
+		{in: "https://snippets.example/?a=b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseBaseURL(tt.in)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestActivityPubWithoutBaseURL(t *testing.T) {
+	app := newTestApplication(t)
+	app.baseURL = ""
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	for _, p := range []string{"/ap/actor", "/ap/outbox", "/ap/notes/00000000000000000000000001", "/.well-known/webfinger?resource=acct:snippetbox@snippets.example"} {
+		code, _, _ := ts.get(t, p)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+}
+
+func TestActivityPubDocuments(t *testing.T) {
+	app := newTestApplication(t, withActivityPub(t, false))
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/ap/actor")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), activitypub.ContentType)
+
+	var actor activitypub.Actor
+	if err := json.Unmarshal([]byte(body), &actor); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, actor.ID, "https://snippets.example/ap/actor")
+	assert.Equal(t, actor.Inbox, "https://snippets.example/ap/inbox")
+	assert.Equal(t, actor.PublicKey.ID, "https://snippets.example/ap/actor#main-key")
+	if _, err := activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPem); err != nil {
+		t.Fatal(err)
+	}
+
+	code, headers, body = ts.get(t, "/.well-known/webfinger?resource=acct:snippetbox@snippets.example")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/jrd+json")
+	assert.StringContains(t, body, `"href":"https://snippets.example/ap/actor"`)
+
+	code, _, _ = ts.get(t, "/.well-known/webfinger?resource=acct:alice@snippets.example")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, "/.well-known/webfinger?resource=https://snippets.example/ap/actor")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, body = ts.get(t, "/ap/outbox")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"totalItems":1`)
+	assert.StringContains(t, body, `"type":"Create"`)
+
+	code, _, body = ts.get(t, "/ap/notes/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"id":"https://snippets.example/ap/notes/00000000000000000000000001"`)
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.StringContains(t, body, `"url":"https://snippets.example/snippet/view/00000000000000000000000001"`)
+
+	// Scheduled, burn after reading and encrypted snippets aren't published.
+	for _, p := range []string{"/ap/notes/00000000000000000000000003", "/ap/notes/00000000000000000000000004", "/ap/notes/00000000000000000000000005", "/ap/notes/99"} {
+		code, _, _ = ts.get(t, p)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	// The inbox is closed until the feature flag is on.
+	code, _, _ = ts.do(t, http.MethodPost, "/ap/inbox", nil, `{}`)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+// remoteActor is an actor on another server, which follows the instance and
+// receives what it delivers.
+type remoteActor struct {
+	*httptest.Server
+	key *activitypub.Key
+
+	mu       sync.Mutex
+	received [][]byte
+}
+
+func newRemoteActor(t *testing.T, trusted *rsa.PublicKey) *remoteActor {
+	public, private, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := activitypub.ParsePrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := &remoteActor{}
+	ra.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/alice":
+			json.NewEncoder(w).Encode(activitypub.Actor{
+				ID:        ra.actorURL(),
+				Type:      "Person",
+				Inbox:     ra.URL + "/users/alice/inbox",
+				Endpoints: &activitypub.Endpoints{SharedInbox: ra.URL + "/inbox"},
+				PublicKey: activitypub.PublicKey{ID: ra.key.ID, Owner: ra.actorURL(), PublicKeyPem: public},
+			})
+		case "/inbox", "/users/alice/inbox":
+			body, _ := io.ReadAll(r.Body)
+			_, err := activitypub.Verify(r, body, func(string) (*rsa.PublicKey, error) { return trusted, nil })
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			ra.mu.Lock()
+			ra.received = append(ra.received, body)
+			ra.mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	ra.key = &activitypub.Key{ID: ra.actorURL() + "#main-key", Private: k}
+	return ra
+}
+
+func (ra *remoteActor) actorURL() string {
+	return ra.URL + "/users/alice"
+}
+
+// post signs and posts an activity to the instance's inbox.
+func (ra *remoteActor) post(t *testing.T, ts *testServer, activity string) int {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/ap/inbox", bytes.NewReader([]byte(activity)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	if err = ra.key.Sign(req, []byte(activity)); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	return rs.StatusCode
+}
+
+func TestActivityPubFollowing(t *testing.T) {
+	app := newTestApplication(t, withActivityPub(t, true))
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	remote := newRemoteActor(t, &app.activityPubKey.Private.PublicKey)
+	defer remote.Close()
+	app.webhookSender = &webhooks.Sender{PublicClient: remote.Client()}
+
+	follow := `{"id":"` + remote.actorURL() + `#follows/1","type":"Follow","actor":"` + remote.actorURL() + `","object":"https://snippets.example/ap/actor"}`
+
+	// Unsigned requests, and requests signed by someone other than the
+	// activity's actor, are turned away.
+	code, _, _ := ts.do(t, http.MethodPost, "/ap/inbox", http.Header{"Content-Type": {activitypub.ContentType}}, follow)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	impostor := `{"id":"x","type":"Follow","actor":"https://elsewhere.example/users/bob","object":"https://snippets.example/ap/actor"}`
+	assert.Equal(t, remote.post(t, ts, impostor), http.StatusUnauthorized)
+
+	assert.Equal(t, remote.post(t, ts, follow), http.StatusAccepted)
+
+	n, err := app.activityPub.FollowerCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	// The follow is accepted through the queue, to the follower's own
+	// inbox, and new snippets go to their server's shared inbox, except for
+	// burn after reading ones like snippet 4.
+	for _, id := range []int{1, 4} {
+		s, err := app.snippets.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.publishActivity(s)
+	}
+
+	if err = app.deliverActivities(); err != nil {
+		t.Fatal(err)
+	}
+	remote.mu.Lock()
+	received := remote.received
+	remote.mu.Unlock()
+
+	assert.Equal(t, len(received), 2)
+	assert.StringContains(t, string(received[0]), `"type":"Accept"`)
+	assert.StringContains(t, string(received[0]), `#follows/1`)
+	assert.StringContains(t, string(received[1]), `"type":"Create"`)
+	assert.StringContains(t, string(received[1]), `"id":"https://snippets.example/ap/notes/00000000000000000000000001"`)
+
+	undo := `{"id":"` + remote.actorURL() + `#undo/1","type":"Undo","actor":"` + remote.actorURL() + `","object":` + follow + `}`
+	assert.Equal(t, remote.post(t, ts, undo), http.StatusAccepted)
+
+	n, err = app.activityPub.FollowerCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+}
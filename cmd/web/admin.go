@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"math"
+	"net/http"
+)
+
+func (app *application) adminIndex(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+
+	// The test application has no database.
+	if app.queries != nil {
+		stats := app.queries.Stats()
+		data.QueryStats = &stats
+	}
+
+	app.render(w, http.StatusOK, "admin.tmpl.html", data)
+}
+
+type adminFeaturesForm struct {
+	Name                string `form:"name"`
+	Enabled             bool   `form:"enabled"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) adminFeatures(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.FeatureFlags = features.Known
+	app.render(w, http.StatusOK, "admin_features.tmpl.html", data)
+}
+
+func (app *application) adminFeaturesPost(w http.ResponseWriter, r *http.Request) {
+	var form adminFeaturesForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The form is made up of hidden fields, so an unknown flag name means the
+	// request didn't come from our page.
+	if !features.IsKnown(form.Name) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.features.Set(form.Name, form.Enabled)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Feature flag updated.")
+
+	http.Redirect(w, r, urlFor("admin.features"), http.StatusSeeOther)
+}
+
+type adminInvitationForm struct {
+	MaxUses             int `form:"maxUses"`
+	Expires             int `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) adminInvitations(w http.ResponseWriter, r *http.Request) {
+	app.renderAdminInvitations(w, r, http.StatusOK, adminInvitationForm{MaxUses: 1, Expires: 7})
+}
+
+// renderAdminInvitations renders the invitations page with the given state of
+// the create invitation form.
+func (app *application) renderAdminInvitations(w http.ResponseWriter, r *http.Request, status int, form adminInvitationForm) {
+	invitations, err := app.invitations.Latest()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Invitations = invitations
+	data.SignupMode = app.features.SignupMode()
+	app.render(w, status, "admin_invitations.tmpl.html", data)
+}
+
+func (app *application) adminInvitationsPost(w http.ResponseWriter, r *http.Request) {
+	var form adminInvitationForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(form.MaxUses >= 1 && form.MaxUses <= 1000, "maxUses", "This field must be between 1 and 1000")
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 30), "expires", "This field must equal 1, 7 or 30")
+
+	if !form.Valid() {
+		app.renderAdminInvitations(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	invitation, err := app.invitations.Insert(id, form.MaxUses, form.Expires)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invitation %s created.", invitation.Code))
+
+	http.Redirect(w, r, urlFor("admin.invitations"), http.StatusSeeOther)
+}
+
+type adminSignupModeForm struct {
+	Mode string `form:"mode"`
+}
+
+func (app *application) adminSignupModePost(w http.ResponseWriter, r *http.Request) {
+	var form adminSignupModeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !validator.PermittedValue(form.Mode, features.SignupModeOpen, features.SignupModeClosed, features.SignupModeInvite) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.features.SetSignupMode(form.Mode)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Signup mode updated.")
+
+	http.Redirect(w, r, urlFor("admin.invitations"), http.StatusSeeOther)
+}
+
+type adminRateLimitForm struct {
+	UserID              int `form:"userID"`
+	RequestsPerHour     int `form:"requestsPerHour"`
+	Burst               int `form:"burst"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) adminRateLimits(w http.ResponseWriter, r *http.Request) {
+	app.renderAdminRateLimits(w, r, http.StatusOK, adminRateLimitForm{})
+}
+
+// renderAdminRateLimits renders the API rate limits page with the given state
+// of the set limit form.
+func (app *application) renderAdminRateLimits(w http.ResponseWriter, r *http.Request, status int, form adminRateLimitForm) {
+	limits, err := app.apiRateLimits.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.APIRateLimits = limits
+	data.DefaultAPIRateLimit = int(math.Round(app.apiLimiter.Rate * 3600))
+	data.DefaultAPIRateBurst = app.apiLimiter.Burst
+	app.render(w, status, "admin_rate_limits.tmpl.html", data)
+}
+
+func (app *application) adminRateLimitsPost(w http.ResponseWriter, r *http.Request) {
+	var form adminRateLimitForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	exists, err := app.users.Exists(form.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form.CheckField(exists, "userID", "There is no user with this ID")
+	form.CheckField(form.RequestsPerHour >= 1 && form.RequestsPerHour <= 1000000, "requestsPerHour", "This field must be between 1 and 1000000")
+	form.CheckField(form.Burst >= 1 && form.Burst <= 10000, "burst", "This field must be between 1 and 10000")
+
+	if !form.Valid() {
+		app.renderAdminRateLimits(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	err = app.apiRateLimits.Set(form.UserID, form.RequestsPerHour, form.Burst)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Rate limit updated.")
+
+	http.Redirect(w, r, urlFor("admin.rate-limits"), http.StatusSeeOther)
+}
+
+func (app *application) adminRateLimitsDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form adminRateLimitForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.apiRateLimits.Delete(form.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Rate limit removed; the user is back on the default limit.")
+
+	http.Redirect(w, r, urlFor("admin.rate-limits"), http.StatusSeeOther)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAdminAccess(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+	code, _, body := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/admin/features")
+}
+
+func TestAdminFeaturesPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/features")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "signup_open")
+
+	form := url.Values{}
+	form.Add("name", "signup_open")
+	form.Add("enabled", "false")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, headers, _ := ts.postForm(t, "/admin/features", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Feature flag updated.")
+
+	// With signup closed the signup routes should no longer exist.
+	code, _, _ = ts.get(t, "/user/signup")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form.Set("name", "no_such_flag")
+	code, _, _ = ts.postForm(t, "/admin/features", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestAdminInvitationsPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/admin/invitations")
+
+	form := url.Values{}
+	form.Add("maxUses", "5")
+	form.Add("expires", "7")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/admin/invitations", form)
+	_, _, body := ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Invitation NEWINVITATIONXYZ created.")
+
+	form.Set("maxUses", "0")
+	code, _, body = ts.postForm(t, "/admin/invitations", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be between 1 and 1000")
+
+	form = url.Values{}
+	form.Add("mode", "closed")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/admin/signup-mode", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, app.features.SignupMode(), "closed")
+}
+
+func TestAdminRateLimits(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/admin/rate-limits")
+
+	form := url.Values{}
+	form.Add("userID", "1")
+	form.Add("requestsPerHour", "50")
+	form.Add("burst", "5")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/admin/rate-limits", form)
+	_, _, body := ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Rate limit updated.")
+	assert.StringContains(t, body, "<td>50</td>")
+
+	limit, err := app.apiRateLimits.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, limit.Burst, 5)
+
+	form.Set("userID", "99")
+	code, _, body = ts.postForm(t, "/admin/rate-limits", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "There is no user with this ID")
+
+	form.Set("userID", "1")
+	code, headers, _ = ts.postForm(t, "/admin/rate-limits/delete", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Rate limit removed; the user is back on the default limit.")
+	assert.StringContains(t, body, "Everyone is on the default limit.")
+}
+
+func TestAdminSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>3</td>")
+	assert.StringContains(t, body, "<td>2</td>")
+	assert.StringContains(t, body, "haven't been deleted since the server started")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/sessions"))
+
+	code, headers, _ := ts.postForm(t, "/admin/sessions/gc", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Deleted 2 expired sessions.")
+	assert.StringContains(t, body, "<td>0</td>")
+	assert.StringContains(t, body, "and deleted 2.")
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/admin/sessions")
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestAdminPerformance(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	ts.get(t, "/snippet/view/00000000000000000000000001")
+	ts.get(t, "/missing")
+
+	code, _, body := ts.get(t, "/admin/performance")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "GET snippet.view")
+	assert.StringContains(t, body, unmatchedRoute)
+	assert.StringContains(t, body, "Go runtime")
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/admin/performance")
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestRoundDuration(t *testing.T) {
+	assert.Equal(t, roundDuration(1234567*time.Nanosecond), 1230*time.Microsecond)
+	assert.Equal(t, roundDuration(2345*time.Millisecond), 2350*time.Millisecond)
+	assert.Equal(t, roundDuration(1499*time.Nanosecond), time.Microsecond)
+	assert.Equal(t, formatPercent(0.025), "2.5%")
+}
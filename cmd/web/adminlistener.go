@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/middleware"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// The admin listeners, given with -admin-addr, are for serving the
+// operational routes somewhere the public can't reach, like 127.0.0.1:4001
+// or a port which asks for client certificates. Once there are any, the
+// admin pages, /metrics and the debugging aids are left out of the public
+// listeners' routes altogether, rather than relying on each being protected,
+// and /debug/pprof is only ever served on them. The admin listeners serve
+// the rest of the site too, so that admins can sign in and follow links from
+// the admin pages without switching between the two.
+
+// adminStack is the standard stack for the admin listeners. Everything on
+// them is held to -admin-ip-rules, including /metrics and /debug/pprof,
+// which nobody signs in to.
+func (app *application) adminStack() middleware.Stack {
+	return app.standardStack().Extend("adminStandard",
+		middleware.New("requireAdminNetwork", app.requireAdminNetwork),
+	)
+}
+
+// adminRoutes is the handler for the admin listeners: every route, including
+// the adminOnly ones.
+func (app *application) adminRoutes() http.Handler {
+	return app.handler(app.adminStack(), func(route) bool { return true })
+}
+
+// requireAdminNetwork turns away clients which -admin-ip-rules doesn't
+// allow. It runs before the session is loaded, so unlike requireAllowedIP
+// it can't show the forbidden page.
+func (app *application) requireAdminNetwork(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if !app.ipRules.admin.Allowed(ip) {
+			app.infoLog.Printf("audit: denied admin listener access from %s: %s %s", ip, r.Method, r.URL.RequestURI())
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// debugPprof serves the runtime profiles from net/http/pprof. It's only
+// routed on the admin listeners.
+func (app *application) debugPprof(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		// The index serves the named profiles, like heap and goroutine, as
+		// well as the list of them.
+		pprof.Index(w, r)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"log"
+	"net/http"
+	"testing"
+)
+
+func TestAdminListener(t *testing.T) {
+	app := newTestApplication(t, func(app *application) {
+		app.separateAdmin = true
+	})
+	public := newTestServer1(t, app.routes())
+	defer public.Close()
+	admin := newTestServer1(t, app.adminRoutes())
+	defer admin.Close()
+
+	// The operational routes aren't on the public listener at all.
+	for _, path := range []string{"/admin", "/admin/performance", "/metrics", "/debug/pprof/"} {
+		code, _, _ := public.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+	code, _, _ := public.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+
+	// The admin listener serves them, along with the rest of the site.
+	code, _, body := admin.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "snippetbox_db_breaker_state")
+
+	code, _, body = admin.get(t, "/debug/pprof/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "goroutine")
+
+	code, _, body = admin.get(t, "/debug/pprof/goroutine?debug=1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "goroutine profile:")
+
+	code, _, _ = admin.get(t, "/debug/pprof/cmdline")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Admin pages still need an admin to sign in.
+	code, _, _ = admin.get(t, "/admin")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	admin.login(t, "admin@example.com", "pa$$word")
+	code, _, _ = admin.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAdminListenerNotSeparate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Without admin listeners the operational routes stay where they were,
+	// but the profiles aren't served anywhere.
+	code, _, _ := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.get(t, "/debug/pprof/")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAdminListenerIPRules(t *testing.T) {
+	// The test server's clients connect from 127.0.0.1.
+	path := writeIPRules(t, "", "allow 10.0.0.0/8\n")
+
+	var audit bytes.Buffer
+	app := newTestApplication(t, func(app *application) {
+		var err error
+		app.ipRules, err = loadIPRules(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.infoLog = log.New(&audit, "", 0)
+		app.separateAdmin = true
+	})
+	ts := newTestServer1(t, app.adminRoutes())
+	defer ts.Close()
+
+	// Even the routes nobody signs in to are held to the rules.
+	code, _, _ := ts.get(t, "/debug/pprof/")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, audit.String(), "audit: denied admin listener access from 127.0.0.1: GET /debug/pprof/")
+
+	code, _, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusForbidden)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// snippetFields are the fields of snippets which API clients can ask for
+// with ?fields=.
+var snippetFields = jsonFields(models.Snippet{})
+
+// apiSnippetList returns a page of the published snippets. The page and its
+// size are chosen with the page and per_page query string parameters, and
+// the Link and X-Total-Count headers describe the other pages. With
+// mine=true, only the authenticated user's own snippets are listed, and
+// with fields=title,created only those fields of each. Like the other GET
+// endpoints it sends an ETag, and a 304 response when the page hasn't
+// changed since the client fetched it.
+//
+// Pages chosen by number shift when snippets are published while a client
+// is going through them, so that it sees some twice. The next_cursor in the
+// response can be sent back as the cursor parameter instead, to get the
+// snippets after the last one on the page whatever has been published
+// since; see apiSnippetListAfter.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	mine := r.URL.Query().Get("mine") == "true"
+	if mine && !app.isAuthenticated(r) {
+		app.apiErrorResponse(w, http.StatusUnauthorized, "you must be authenticated to list your own snippets")
+		return
+	}
+
+	fields, err := parseFields(r, snippetFields)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	var userIDs []int
+	if mine {
+		userIDs = []int{reqctx.UserID(r.Context())}
+	}
+
+	if r.URL.Query().Has("cursor") {
+		app.apiSnippetListAfter(w, r, fields, perPage, userIDs)
+		return
+	}
+
+	p := newPagination(r, perPage)
+
+	var snippets []*models.Snippet
+	var total int
+	if mine {
+		snippets, total, err = app.snippets.LatestFromAuthors(userIDs, p.PerPage, p.Offset())
+	} else {
+		snippets, total, err = app.snippets.Page(p.PerPage, p.Offset())
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	p.Total = total
+
+	headers := make(http.Header)
+	headers.Set("Link", p.LinkHeader())
+	headers.Set("X-Total-Count", strconv.Itoa(total))
+
+	if app.notModified(w, r, snippetListETag(snippets, p)) {
+		return
+	}
+
+	projected, err := projectAll(fields, snippets)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	var next any
+	if p.HasNext() && len(snippets) > 0 {
+		next = encodeCursor(snippets[len(snippets)-1].Cursor())
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": projected, "next_cursor": next}, headers)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// apiSnippetListAfter sends the page of the snippet list which comes after
+// the cursor parameter, or the first page if it's empty. The Link header
+// points at the next page, if there is one, and next_cursor is its cursor;
+// there's no total, since it would be out of date by the time the client
+// got to the end.
+func (app *application) apiSnippetListAfter(w http.ResponseWriter, r *http.Request, fields fieldSet, perPage int, userIDs []int) {
+	after, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	// One more than a page is asked for, to find out whether there's a
+	// next page.
+	snippets, err := app.snippets.LatestAfter(after, perPage+1, userIDs...)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	var next any
+	headers := make(http.Header)
+	if len(snippets) > perPage {
+		snippets = snippets[:perPage]
+		cursor := encodeCursor(snippets[perPage-1].Cursor())
+		next = cursor
+		headers.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, cursorURL(r.URL, cursor)))
+	}
+
+	if app.notModified(w, r, snippetCursorETag(snippets, next != nil)) {
+		return
+	}
+
+	projected, err := projectAll(fields, snippets)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": projected, "next_cursor": next}, headers)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.apiNotFound(w)
+		return
+	}
+
+	fields, err := parseFields(r, snippetFields)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	visible, err := app.snippetVisible(snippet, reqctx.UserID(r.Context()))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !visible {
+		app.apiNotFound(w)
+		return
+	}
+
+	if app.notModified(w, r, snippetETag(snippet)) {
+		return
+	}
+
+	projected, err := fields.project(snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": projected}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// snippetCreateInput is the request body accepted by apiSnippetCreate.
+// Additional files are given as a list of objects with filename, language
+// and content keys.
+type snippetCreateInput struct {
+	Title               string            `json:"title"`
+	Content             string            `json:"content"`
+	Filename            string            `json:"filename"`
+	Language            string            `json:"language"`
+	Files               []snippetFileForm `json:"files"`
+	Expires             int               `json:"expires"`
+	PublishAt           *time.Time        `json:"publish_at"`
+	BurnAfterReading    bool              `json:"burn_after_reading"`
+	ContentEncrypted    bool              `json:"content_encrypted"`
+	validator.Validator `json:"-"`
+}
+
+// snippet validates the input, leaving any problems in its FieldErrors, and
+// returns the snippet it describes, owned by userID. The expiry is held to
+// the given policy, and is the policy's default if it's left out.
+func (input *snippetCreateInput) snippet(userID int, policy models.ExpiryPolicy, limits validator.ContentLimits) *models.Snippet {
+	if input.Expires == 0 {
+		input.Expires = policy.DefaultDays
+	}
+
+	input.CheckField(validator.NotBlank(input.Title), "title", "This field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Title, 100), "title", "This field cannot be more than 100 characters long")
+	input.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+	checkExpires(&input.Validator, "expires", input.Expires, policy)
+
+	primary := snippetFileForm{Filename: input.Filename, Language: input.Language, Content: input.Content}
+	files := validateSnippetFiles(&input.Validator, limits, &primary, input.Files)
+	input.Content = primary.Content
+	if input.ContentEncrypted {
+		validateEncryptedSnippet(&input.Validator, &primary, files)
+	}
+
+	var publishAt time.Time
+	if input.PublishAt != nil {
+		publishAt = *input.PublishAt
+		validatePublishAt(&input.Validator, publishAt, input.Expires)
+	}
+
+	return &models.Snippet{
+		Title:            input.Title,
+		Content:          input.Content,
+		Filename:         primary.Filename,
+		Language:         primary.Language,
+		DetectedLanguage: primary.DetectedLanguage,
+		Files:            files,
+		UserID:           userID,
+		PublishAt:        publishAt,
+		BurnAfterReading: input.BurnAfterReading,
+		ContentEncrypted: input.ContentEncrypted,
+	}
+}
+
+// createSnippet saves a new snippet made through the API, tells webhooks
+// and followers on other servers about it, and returns it as saved.
+func (app *application) createSnippet(r *http.Request, snippet *models.Snippet, expires int) (*models.Snippet, error) {
+	id, err := app.snippets.Insert(snippet, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	snippet, err = app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.emitWebhookEvent(webhooks.SnippetCreated, snippet.UserID, app.webhookSnippet(snippet))
+	app.publishActivity(snippet)
+	return snippet, nil
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input snippetCreateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet := input.snippet(reqctx.UserID(r.Context()), policy, app.contentLimits)
+	if !input.Valid() {
+		app.apiFailedValidation(w, input.FieldErrors)
+		return
+	}
+
+	snippet, err = app.createSnippet(r, snippet, input.Expires)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTooLarge):
+			app.apiErrorResponse(w, http.StatusRequestEntityTooLarge, "the snippet is too large to save")
+		case errors.Is(err, models.ErrExpiryNotPermitted):
+			app.apiFailedValidation(w, map[string]string{"expires": "The expiry policy has changed, and no longer permits this expiry"})
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", urlFor("api.snippet", snippet.PublicID))
+	headers.Set("ETag", snippetETag(snippet))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": snippet, "warnings": snippetLintWarnings(snippet)}, headers)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// apiSnippetLanguageUpdate is the API equivalent of snippetLanguagePost. The
+// body is an object with a language key, which may be empty to go back to the
+// detected language. Clients should send the snippet's ETag in an If-Match
+// header, so that a change made since they fetched the snippet isn't
+// overwritten; the request then fails with 412 Precondition Failed.
+func (app *application) apiSnippetLanguageUpdate(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.apiNotFound(w)
+		return
+	}
+
+	position, err := strconv.Atoi(params.ByName("position"))
+	if err != nil || position < 0 {
+		app.apiNotFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+	visible, err := app.snippetVisible(snippet, userID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !visible {
+		app.apiNotFound(w)
+		return
+	}
+	if snippet.UserID != userID {
+		app.apiErrorResponse(w, http.StatusForbidden, "only the owner of a snippet can change it")
+		return
+	}
+
+	if _, ok := snippet.File(position); !ok {
+		app.apiNotFound(w)
+		return
+	}
+
+	if app.preconditionFailed(w, r, snippetETag(snippet)) {
+		return
+	}
+
+	var input struct {
+		Language string `json:"language"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	if _, known := languages.Lookup(input.Language); input.Language != "" && !known {
+		app.apiFailedValidation(w, map[string]string{"language": "This field must be one of the supported languages"})
+		return
+	}
+
+	// Without an If-Match header the last write wins, as it does on the
+	// site. With one, the snippet must still be at the version it matched.
+	version := 0
+	if r.Header.Get("If-Match") != "" {
+		version = snippet.Version
+	}
+
+	err = app.snippets.SetLanguage(id, position, input.Language, version)
+	if err != nil {
+		if errors.Is(err, models.ErrEditConflict) {
+			app.apiPreconditionFailed(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	snippet, err = app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.emitSnippetLanguageChanged(snippet, position, input.Language)
+
+	headers := make(http.Header)
+	headers.Set("ETag", snippetETag(snippet))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, headers)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
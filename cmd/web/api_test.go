@@ -0,0 +1,329 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	assert.StringContains(t, body, `"title": "An old silent pond"`)
+	assert.StringContains(t, body, `"read_seconds": 4`)
+
+	code, _, body = ts.get(t, "/api/v1/snippets/2")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.StringContains(t, body, `"error"`)
+
+	code, headers, body = ts.get(t, "/api/v1/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"snippets"`)
+	assert.Equal(t, headers.Get("X-Total-Count"), "1")
+	assert.Equal(t, headers.Get("Link"), `</api/v1/snippets?page=1>; rel="first", </api/v1/snippets?page=1>; rel="last"`)
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	const validBody = `{"title": "Title", "content": "Content", "expires": 7}`
+
+	code, _, _ := ts.postJSON(t, "/api/v1/snippets", validBody)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			body:     validBody,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "Invalid fields",
+			body:     `{"title": "", "content": "Content", "expires": 3}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"expires": "This field must equal 1, 7 or 365"`,
+		},
+		{
+			name:     "Too large",
+			body:     `{"title": "Too large", "content": "Content", "expires": 7}`,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `"error": "the snippet is too large to save"`,
+		},
+		{
+			name:     "Unknown field",
+			body:     `{"title": "Title", "author": "bob"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: `body contains unknown key \"author\"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/api/v1/snippets", tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name: "Valid",
+			body: `{"title": "Title", "content": "Content", "expires": 7}`,
+		},
+		{
+			name:    "Syntax error",
+			body:    `{"title": "Title",}`,
+			wantErr: "body contains badly-formed JSON (at character 19)",
+		},
+		{
+			name:    "Unexpected EOF",
+			body:    `{"title": "Title"`,
+			wantErr: "body contains badly-formed JSON",
+		},
+		{
+			name:    "Wrong type",
+			body:    `{"expires": "seven"}`,
+			wantErr: `body contains incorrect JSON type for field "expires" (at character 19)`,
+		},
+		{
+			name:    "Empty",
+			body:    ``,
+			wantErr: "body must not be empty",
+		},
+		{
+			name:    "Multiple values",
+			body:    `{"title": "A"}{"title": "B"}`,
+			wantErr: "body must only contain a single JSON value",
+		},
+		{
+			name:    "Too large",
+			body:    `{"content": "` + strings.Repeat("a", maxJSONBodyBytes) + `"}`,
+			wantErr: "body must not be larger than 1048576 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			var input snippetCreateInput
+			err := app.readJSON(rr, r, &input)
+
+			if tt.wantErr == "" {
+				assert.Equal(t, err, nil)
+				return
+			}
+			if err == nil {
+				t.Fatalf("got nil error; want %q", tt.wantErr)
+			}
+			assert.Equal(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestAPIETags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	for _, urlPath := range []string{"/api/v1/snippets/00000000000000000000000001", "/api/v1/snippets"} {
+		t.Run(urlPath, func(t *testing.T) {
+			code, headers, _ := ts.get(t, urlPath)
+			assert.Equal(t, code, http.StatusOK)
+
+			etag := headers.Get("ETag")
+			assert.Equal(t, etag != "", true)
+
+			code, headers, body := ts.do(t, http.MethodGet, urlPath, http.Header{"If-None-Match": {etag}}, "")
+			assert.Equal(t, code, http.StatusNotModified)
+			assert.Equal(t, headers.Get("ETag"), etag)
+			assert.Equal(t, body, "")
+
+			code, _, _ = ts.do(t, http.MethodGet, urlPath, http.Header{"If-None-Match": {`"stale"`}}, "")
+			assert.Equal(t, code, http.StatusOK)
+		})
+	}
+}
+
+func TestAPISnippetLanguageUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	const urlPath = "/api/v1/snippets/00000000000000000000000001/files/0/language"
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ := ts.do(t, http.MethodPut, urlPath, nil, `{"language": "go"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, headers, _ := ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	etag := headers.Get("ETag")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		ifMatch  string
+		body     string
+		wantCode int
+	}{
+		{"Without If-Match", urlPath, "", `{"language": "go"}`, http.StatusOK},
+		{"Matching If-Match", urlPath, etag, `{"language": "go"}`, http.StatusOK},
+		{"Stale If-Match", urlPath, `"1.0"`, `{"language": "go"}`, http.StatusPreconditionFailed},
+		{"Unknown language", urlPath, etag, `{"language": "cobol"}`, http.StatusUnprocessableEntity},
+		{"Non-existent file", "/api/v1/snippets/00000000000000000000000001/files/5/language", "", `{"language": "go"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.ifMatch != "" {
+				headers.Set("If-Match", tt.ifMatch)
+			}
+
+			code, headers, _ := ts.do(t, http.MethodPut, tt.urlPath, headers, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusOK {
+				assert.Equal(t, headers.Get("ETag") != "", true)
+			}
+		})
+	}
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.apiRateLimits.Set(1, 3600, 2)
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous clients get the default limit.
+	code, headers, _ := ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-RateLimit-Limit"), "1000")
+	assert.Equal(t, headers.Get("X-RateLimit-Remaining"), "99")
+
+	// Alice has her own limit of one request a second with bursts of two.
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, headers, _ = ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-RateLimit-Limit"), "3600")
+	assert.Equal(t, headers.Get("X-RateLimit-Remaining"), "1")
+	assert.Equal(t, headers.Get("X-RateLimit-Reset") != "", true)
+
+	ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+
+	code, headers, body := ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("X-RateLimit-Remaining"), "0")
+	assert.Equal(t, headers.Get("Retry-After"), "1")
+	assert.StringContains(t, body, "rate limit exceeded")
+}
+
+func TestAPIFields(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Snippet",
+			urlPath:  "/api/v1/snippets/00000000000000000000000001?fields=created,title",
+			wantCode: http.StatusOK,
+			wantBody: "{\n\t\t\"id\": \"00000000000000000000000001\",\n\t\t\"title\": \"An old silent pond\",\n\t\t\"created\": ",
+		},
+		{
+			name:     "List",
+			urlPath:  "/api/v1/snippets?fields=title",
+			wantCode: http.StatusOK,
+			wantBody: "\"snippets\": [\n\t\t{\n\t\t\t\"id\": \"00000000000000000000000001\",\n\t\t\t\"title\": \"An old silent pond\"\n\t\t}\n\t]",
+		},
+		{
+			name:     "Unknown field",
+			urlPath:  "/api/v1/snippets/00000000000000000000000001?fields=title,user_id",
+			wantCode: http.StatusBadRequest,
+			wantBody: `fields: unknown field \"user_id\" (the fields are id, title, content,`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			if code == http.StatusOK {
+				assert.Equal(t, strings.Contains(body, "content"), false)
+			}
+		})
+	}
+}
+
+func TestAPISnippetListCursor(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/api/v1/snippets?cursor=&per_page=5")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title": "An old silent pond"`)
+	assert.StringContains(t, body, `"next_cursor": null`)
+	assert.Equal(t, headers.Get("X-Total-Count"), "")
+	assert.Equal(t, headers.Get("Link"), "")
+
+	// mockSnippet is the only snippet, so there's nothing after it.
+	cursor := encodeCursor(mockSnippetCursor(t, app))
+	code, _, body = ts.get(t, "/api/v1/snippets?cursor="+cursor)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"snippets": []`)
+
+	code, _, body = ts.get(t, "/api/v1/snippets?cursor=nonsense")
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, `"error": "the cursor is invalid"`)
+}
+
+// mockSnippetCursor returns the cursor just after the mock snippet.
+func mockSnippetCursor(t *testing.T, app *application) models.SnippetCursor {
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Cursor()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAPITokens is how many API tokens each user can have.
+const maxAPITokens = 20
+
+type apiTokenForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountAPITokens(w http.ResponseWriter, r *http.Request) {
+	app.apiTokenList(w, r, apiTokenForm{}, http.StatusOK)
+}
+
+// apiTokenList shows the user's API tokens. A token which has just been
+// created is shown once, straight after creating it, as only its hash is
+// kept.
+func (app *application) apiTokenList(w http.ResponseWriter, r *http.Request, form apiTokenForm, status int) {
+	tokens, err := app.apiTokens.ForUser(reqctx.UserID(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.NewAPIToken = app.sessionManager.PopString(r.Context(), "newAPIToken")
+	data.Form = form
+	app.render(w, status, "apitokens.tmpl.html", data)
+}
+
+func (app *application) accountAPITokensPost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	form.Name = strings.TrimSpace(form.Name)
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if form.Valid() {
+		tokens, err := app.apiTokens.ForUser(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.CheckField(len(tokens) < maxAPITokens, "name", "You can't have more than 20 API tokens")
+	}
+
+	if !form.Valid() {
+		app.apiTokenList(w, r, form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	token, err := app.apiTokens.Insert(id, form.Name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "newAPIToken", token)
+	http.Redirect(w, r, urlFor("account.tokens"), http.StatusSeeOther)
+}
+
+func (app *application) accountAPITokenDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tokenID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || tokenID < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.apiTokens.Delete(tokenID, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your API token has been revoked.")
+	http.Redirect(w, r, urlFor("account.tokens"), http.StatusSeeOther)
+}
+
+// authenticateBearer returns a middleware which authenticates requests
+// carrying an API token in an "Authorization: Bearer" header, taking
+// precedence over any session. Requests without one carry on as they are,
+// but a token which isn't valid is refused outright, so that a typo doesn't
+// quietly turn into an anonymous request. Errors are sent with
+// errorResponse, so that each API can answer in its own format.
+func (app *application) authenticateBearer(errorResponse func(w http.ResponseWriter, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, _ := strings.Cut(header, " ")
+			token = strings.TrimSpace(token)
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", bearerChallenge)
+				errorResponse(w, http.StatusUnauthorized, `the Authorization header must be "Bearer <token>"`)
+				return
+			}
+
+			id, err := app.apiTokens.Authenticate(token)
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidToken) {
+					w.Header().Set("WWW-Authenticate", bearerChallenge)
+					errorResponse(w, http.StatusUnauthorized, "invalid or revoked API token")
+				} else {
+					app.errorLog.Print(err)
+					errorResponse(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+				}
+				return
+			}
+
+			user, err := app.users.Get(id)
+			if err != nil {
+				app.errorLog.Print(err)
+				errorResponse(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+				return
+			}
+			if user.Suspended(time.Now()) {
+				errorResponse(w, http.StatusForbidden, suspensionMessage(user))
+				return
+			}
+
+			reqctx.SetUserID(r.Context(), id)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerChallenge is the WWW-Authenticate header sent when an API token is
+// missing or wrong.
+const bearerChallenge = `Bearer realm="snippetbox"`
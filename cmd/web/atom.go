@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"time"
+)
+
+// The atom* types are the parts of an Atom (RFC 4287) feed which we use.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Content atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// writeAtom sends snippets as an Atom feed. path is the path of the HTML page
+// showing the same snippets, which the feed links to.
+func (app *application) writeAtom(w http.ResponseWriter, r *http.Request, title, path string, snippets []*models.Snippet) {
+	base := app.absoluteURL("")
+
+	feed := atomFeed{
+		Title:   title,
+		ID:      base + path,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: base + path, Rel: "alternate"},
+			{Href: base + r.URL.Path, Rel: "self"},
+		},
+	}
+
+	// The feed was last updated when its newest snippet was published.
+	if len(snippets) > 0 {
+		feed.Updated = snippets[0].PublishAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, s := range snippets {
+		link := base + urlFor("snippet.view", s.PublicID)
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   s.Title,
+			ID:      link,
+			Updated: s.PublishAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: link},
+			Content: atomText{Type: "text", Body: s.Content},
+		})
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
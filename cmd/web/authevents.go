@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"io"
+	"net/http"
+	"time"
+)
+
+// recordAuthEvent records an authentication outcome in the audit log and
+// the metrics, filling in when it happened, which request it came from and,
+// unless it's already set, which admin is impersonating the user.
+func (app *application) recordAuthEvent(r *http.Request, e authevents.Event) {
+	e.Time = time.Now()
+	if e.ImpersonatorID == 0 {
+		e.ImpersonatorID = reqctx.ImpersonatorID(r.Context())
+		e.ImpersonationID = reqctx.ImpersonationID(r.Context())
+	}
+	e.ClientIP = clientIP(r)
+	e.RequestID = reqctx.RequestID(r.Context())
+	app.authEvents.Record(e)
+}
+
+// writeAuthEventMetrics writes a counter of the authentication events seen,
+// labelled with what happened and how the user signed in.
+func writeAuthEventMetrics(w io.Writer, counts []authevents.Count) {
+	const name = "snippetbox_auth_events_total"
+
+	fmt.Fprintf(w, "# HELP %s Authentication events, by event and sign-in method.\n# TYPE %s counter\n", name, name)
+	for _, c := range counts {
+		fmt.Fprintf(w, "%s{event=%q,method=%q} %d\n", name, c.Kind, c.Method, c.N)
+	}
+}
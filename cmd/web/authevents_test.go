@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthEvents(t *testing.T) {
+	var audit bytes.Buffer
+	app := newTestApplication(t)
+	app.authEvents = authevents.New(&audit, logging.Human)
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/login")
+	code, _, _ := ts.postForm(t, "/user/login", url.Values{"csrf_token": {csrfToken}, "email": {"alice@example.com"}, "password": {"wrong"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ = ts.postForm(t, "/user/logout", url.Values{"csrf_token": {ts.csrfToken(t, "/")}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Each outcome is in the audit log, with who it was for...
+	lines := strings.Split(strings.TrimSuffix(audit.String(), "\n"), "\n")
+	assert.Equal(t, len(lines), 3)
+	assert.StringContains(t, lines[0], "AUTH\tlogin_failed method=password email=\"alice@example.com\" ip=127.0.0.1 reason=\"invalid credentials\"")
+	assert.StringContains(t, lines[1], "AUTH\tlogin_succeeded method=password user=1 ip=127.0.0.1")
+	assert.StringContains(t, lines[2], "AUTH\tlogout user=1 ip=127.0.0.1")
+
+	// ...and counted in the metrics.
+	_, _, body := ts.get(t, "/metrics")
+	assert.StringContains(t, body, "# TYPE snippetbox_auth_events_total counter\n")
+	assert.StringContains(t, body, `snippetbox_auth_events_total{event="login_failed",method="password"} 1`)
+	assert.StringContains(t, body, `snippetbox_auth_events_total{event="login_succeeded",method="password"} 1`)
+	assert.StringContains(t, body, `snippetbox_auth_events_total{event="logout",method=""} 1`)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"github.com/ngohoang211020/snippetbox/internal/ratelimit"
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// benchCommand implements "web bench", which measures how quickly the
+// application answers requests. It serves the application backed by the
+// mock models, so there's no database to set up and nothing but the
+// application's own code is measured: the middleware, handlers and
+// templates. Each scenario is run for a while by several clients at once,
+// and the throughput and latency percentiles of each are printed, so that
+// the numbers from before and after a change can be compared.
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	duration := fs.Duration("duration", 5*time.Second, "How long to run each scenario for")
+	concurrency := fs.Int("concurrency", 8, "Number of clients making requests at once")
+	names := fs.String("scenarios", "home,view,create", "Comma-separated list of scenarios to run")
+	fs.Parse(args)
+
+	if *concurrency < 1 {
+		return errors.New("-concurrency must be at least 1")
+	}
+
+	var scenarios []benchScenario
+	for _, name := range strings.Split(*names, ",") {
+		i := slices.IndexFunc(benchScenarios, func(s benchScenario) bool { return s.name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown scenario %q", name)
+		}
+		scenarios = append(scenarios, benchScenarios[i])
+	}
+
+	l := &logs{
+		infoLog:    log.New(io.Discard, "", 0),
+		errorLog:   log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime),
+		accessLog:  logging.NewAccessLog(io.Discard, logging.Human),
+		authEvents: authevents.New(io.Discard, logging.Human),
+	}
+
+	app, err := newMockApplication(l)
+	if err != nil {
+		return err
+	}
+	// The clients all come from the same address, and would soon be
+	// stopped by the rate limits.
+	app.apiLimiter = ratelimit.New(1e9, 1e9)
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	var results []benchResult
+	for _, s := range scenarios {
+		r, err := runBenchScenario(ts, s, *concurrency, *duration)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		results = append(results, r)
+	}
+
+	writeBenchReport(os.Stdout, results)
+	return nil
+}
+
+// benchScenario is a request which is made over and over. setup, if there
+// is one, is run once for each client before the clock starts.
+type benchScenario struct {
+	name    string
+	setup   func(c *benchClient) error
+	request func(c *benchClient) (*http.Response, error)
+	// want is the status code of a successful response.
+	want int
+}
+
+// benchScenarios are the scenarios "web bench" knows how to run.
+var benchScenarios = []benchScenario{
+	{
+		name: "home",
+		request: func(c *benchClient) (*http.Response, error) {
+			return c.Get(c.url + "/")
+		},
+		want: http.StatusOK,
+	},
+	{
+		name: "view",
+		request: func(c *benchClient) (*http.Response, error) {
+			return c.Get(c.url + "/snippet/view/00000000000000000000000001")
+		},
+		want: http.StatusOK,
+	},
+	{
+		name:  "create",
+		setup: (*benchClient).login,
+		request: func(c *benchClient) (*http.Response, error) {
+			return c.PostForm(c.url+"/snippet/create", url.Values{
+				"title":      {"A benchmark"},
+				"content":    {"package main\n\nfunc main() {}\n"},
+				"expires":    {"7"},
+				"csrf_token": {c.csrfToken},
+			})
+		},
+		want: http.StatusSeeOther,
+	},
+}
+
+// benchClient is one of the clients making requests. Each has a session of
+// its own.
+type benchClient struct {
+	*http.Client
+	url       string
+	csrfToken string
+}
+
+// csrfTokenRX captures the CSRF token from the hidden field in a form.
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
+
+// login signs the client in as Alice, one of the mock users, and keeps the
+// CSRF token for the forms it sends.
+func (c *benchClient) login() error {
+	resp, err := c.Get(c.url + "/user/login")
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	matches := csrfTokenRX.FindSubmatch(body)
+	if matches == nil {
+		return errors.New("no CSRF token in the login form")
+	}
+	c.csrfToken = html.UnescapeString(string(matches[1]))
+
+	resp, err = c.PostForm(c.url+"/user/login", url.Values{
+		"email":      {"alice@example.com"},
+		"password":   {"pa$$word"},
+		"csrf_token": {c.csrfToken},
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		return fmt.Errorf("logging in: got status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// benchResult is what came of running a scenario.
+type benchResult struct {
+	name      string
+	elapsed   time.Duration
+	latencies []time.Duration
+	errors    int
+}
+
+// runBenchScenario runs s with the given number of clients until the
+// duration is up.
+func runBenchScenario(ts *httptest.Server, s benchScenario, concurrency int, duration time.Duration) (benchResult, error) {
+	transport := &http.Transport{
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		MaxIdleConnsPerHost: concurrency,
+	}
+	defer transport.CloseIdleConnections()
+
+	clients := make([]*benchClient, concurrency)
+	for i := range clients {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return benchResult{}, err
+		}
+		clients[i] = &benchClient{
+			Client: &http.Client{
+				Transport: transport,
+				Jar:       jar,
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			},
+			url: ts.URL,
+		}
+		if s.setup != nil {
+			if err := s.setup(clients[i]); err != nil {
+				return benchResult{}, err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var mu sync.Mutex
+	result := benchResult{name: s.name}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *benchClient) {
+			defer wg.Done()
+
+			var latencies []time.Duration
+			errors := 0
+			for ctx.Err() == nil {
+				t := time.Now()
+				resp, err := s.request(c)
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				latencies = append(latencies, time.Since(t))
+				if err != nil || resp.StatusCode != s.want {
+					errors++
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.latencies = append(result.latencies, latencies...)
+			result.errors += errors
+		}(c)
+	}
+	wg.Wait()
+	result.elapsed = time.Since(start)
+
+	slices.Sort(result.latencies)
+	return result, nil
+}
+
+// percentile returns the latency which p percent of the requests were
+// quicker than. The latencies must be sorted.
+func (r benchResult) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies)-1) * p / 100)
+	return r.latencies[i]
+}
+
+// writeBenchReport prints a table of the results.
+func writeBenchReport(w io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tREQUESTS\tERRORS\tREQ/S\tP50\tP90\tP99\tMAX")
+	for _, r := range results {
+		rate := float64(len(r.latencies)) / r.elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\n", r.name, len(r.latencies), r.errors, rate,
+			benchDuration(r.percentile(50)), benchDuration(r.percentile(90)), benchDuration(r.percentile(99)),
+			benchDuration(r.percentile(100)))
+	}
+	tw.Flush()
+}
+
+// benchDuration formats a latency to three significant figures or so, which
+// is as precise as they're worth reading.
+func benchDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// newMockApplication returns an application backed by the mock models, with
+// sessions kept in memory and email written to the info log. It's what the
+// tests and "web bench" run against.
+func newMockApplication(l *logs) (*application, error) {
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a store, sessions are kept in memory.
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	webAuthn, err := newWebAuthn("localhost", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := mailer.NewTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	urlSigner, err := signedurl.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	changelogEntries, err := loadChangelog()
+	if err != nil {
+		return nil, err
+	}
+
+	return &application{
+		started:          time.Now(),
+		errorLog:         l.errorLog,
+		infoLog:          l.infoLog,
+		accessLog:        l.accessLog,
+		authEvents:       l.authEvents,
+		snippets:         &mocks.SnippetModel{},
+		users:            &mocks.UserModel{},
+		invitations:      &mocks.InvitationModel{},
+		notifications:    &mocks.NotificationModel{},
+		cspReports:       &mocks.CSPReportModel{},
+		follows:          &mocks.FollowModel{},
+		consumedTokens:   &mocks.ConsumedTokenModel{},
+		passkeys:         &mocks.PasskeyModel{},
+		drafts:           &mocks.DraftModel{},
+		sessions:         &mocks.SessionModel{},
+		orphans:          &mocks.IntegrityModel{},
+		snippetTemplates: &mocks.SnippetTemplateModel{},
+		collections:      &mocks.CollectionModel{},
+		orgs:             &mocks.OrgModel{},
+		webhooks:         &mocks.WebhookModel{},
+		activityPub:      &mocks.ActivityPubModel{},
+		identities:       &mocks.IdentityModel{},
+		preferences:      &mocks.PreferenceModel{},
+		expiryPolicy:     &mocks.ExpiryPolicyModel{},
+		contentLimits:    validator.DefaultContentLimits,
+		apiTokens:        &mocks.APITokenModel{},
+		webhookSender:    webhooks.NewSender(5 * time.Second),
+		webAuthn:         webAuthn,
+		incidents:        &mocks.IncidentModel{},
+		snippetStats:     &mocks.SnippetStatsModel{},
+		apiRateLimits:    &mocks.APIRateLimitModel{},
+		apiLimiter:       ratelimit.New(1000.0/3600, 100),
+		magicLimiter:     newMagicLinkLimiter(),
+		mailer:           &mailer.Log{Logger: l.infoLog},
+		emailQueue:       &mocks.EmailQueueModel{},
+		emails:           emails,
+		urlSigner:        urlSigner,
+		templateCache:    templateCache,
+		formDecoder:      form.NewDecoder(),
+		sessionManager:   sessionManager,
+		sessionGC:        &sessionGC{Interval: 5 * time.Minute},
+		retention:        &retentionPolicy{},
+		integrity:        &integrityChecker{Interval: 24 * time.Hour},
+		staleCache:       newStaleCache(10, 1<<20),
+		requestTimes:     newRequestTimes(),
+		queryTimes:       newQueryTimes(),
+		streamAfter:      defaultStreamAfter,
+		dbHealth:         &dbHealth{db: mockPinger{}, retry: dbRetry{initialDelay: time.Millisecond, maxDelay: time.Millisecond, maxWait: time.Second}},
+		features:         features.New(&mocks.FeatureModel{}, nil),
+		siteURL:          "https://localhost:4000",
+		wellKnownConfig:  &wellKnown{},
+		changelog:        changelogEntries,
+	}, nil
+}
+
+// mockPinger stands in for the database in the readiness checks of a mock
+// application, which is always ready.
+type mockPinger struct{}
+
+func (mockPinger) PingContext(ctx context.Context) error {
+	return nil
+}
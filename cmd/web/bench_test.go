@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRunBenchScenario(t *testing.T) {
+	app := newTestApplication(t)
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	var results []benchResult
+	for _, s := range benchScenarios {
+		r, err := runBenchScenario(ts, s, 2, 50*time.Millisecond)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if len(r.latencies) == 0 {
+			t.Errorf("%s: no requests were made", s.name)
+		}
+		assert.Equal(t, r.errors, 0)
+		results = append(results, r)
+	}
+
+	var buf bytes.Buffer
+	writeBenchReport(&buf, results)
+	assert.StringContains(t, buf.String(), "SCENARIO  REQUESTS")
+	assert.StringContains(t, buf.String(), "create")
+}
+
+func TestBenchResultPercentile(t *testing.T) {
+	r := benchResult{}
+	for i := 1; i <= 100; i++ {
+		r.latencies = append(r.latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, r.percentile(50), 50*time.Millisecond)
+	assert.Equal(t, r.percentile(99), 99*time.Millisecond)
+	assert.Equal(t, r.percentile(100), 100*time.Millisecond)
+	assert.Equal(t, benchResult{}.percentile(50), time.Duration(0))
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"github.com/ngohoang211020/snippetbox/internal/systemd"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverGoneErrors are the MySQL errors which do say something about the
+// health of the database: too many connections, and the server shutting
+// down. Every other error from the server means it answered, so it doesn't
+// count towards opening the circuit breaker.
+var serverGoneErrors = map[uint16]bool{1040: true, 1053: true}
+
+func ignoreMySQLError(err error) bool {
+	var mySQLError *mysql.MySQLError
+	return errors.As(err, &mySQLError) && !serverGoneErrors[mySQLError.Number]
+}
+
+// breakerStore is a session store which goes through the database's circuit
+// breaker. Every page loads the session before it touches a model, so
+// without it an outage would never open the breaker.
+type breakerStore struct {
+	scs.Store
+	breaker *query.Breaker
+}
+
+func (s breakerStore) Find(token string) ([]byte, bool, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return nil, false, err
+	}
+	b, found, err := s.Store.Find(token)
+	s.breaker.Record(err)
+	return b, found, err
+}
+
+func (s breakerStore) Commit(token string, b []byte, expiry time.Time) error {
+	if err := s.breaker.Allow(); err != nil {
+		return err
+	}
+	err := s.Store.Commit(token, b, expiry)
+	s.breaker.Record(err)
+	return err
+}
+
+func (s breakerStore) Delete(token string) error {
+	if err := s.breaker.Allow(); err != nil {
+		return err
+	}
+	err := s.Store.Delete(token)
+	s.breaker.Record(err)
+	return err
+}
+
+// retryAfter sets the Retry-After header to when the circuit breaker will
+// next try the database.
+func (app *application) retryAfter(w http.ResponseWriter) {
+	seconds := 1.0
+	if retryAt := app.dbBreaker.Stats().RetryAt; !retryAt.IsZero() {
+		seconds = math.Max(math.Ceil(time.Until(retryAt).Seconds()), 1)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+}
+
+const unavailableMessage = "We can't reach our database at the moment, so we've stopped trying for a little while. Please try again in a minute."
+
+// requireDatabase answers requests with a 503 while the database's circuit
+// breaker is open, rather than letting each of them wait on the database
+// only to fail. Errors are sent with unavailable, so that the API can send
+// them as JSON.
+func (app *application) requireDatabase(unavailable func(http.ResponseWriter, int, string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.dbBreaker.Stats().State == query.BreakerOpen {
+				app.retryAfter(w)
+				unavailable(w, http.StatusServiceUnavailable, unavailableMessage)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// staleCache keeps the most recent copies of pages shown to visitors who
+// aren't signed in, to show while the database is unavailable. The oldest
+// page is dropped when it's full. It's safe for concurrent use.
+type staleCache struct {
+	maxPages int
+	maxBytes int
+
+	mu    sync.Mutex
+	pages map[string]stalePage
+	order []string
+}
+
+type stalePage struct {
+	body []byte
+	csp  string
+}
+
+func newStaleCache(maxPages, maxBytes int) *staleCache {
+	return &staleCache{maxPages: maxPages, maxBytes: maxBytes, pages: map[string]stalePage{}}
+}
+
+// csrfTokenField matches the CSRF token in a form. Tokens are emptied before
+// a page is cached, so that one visitor's token isn't shown to another.
+var csrfTokenField = regexp.MustCompile(`(name='csrf_token' value=')[^']*'`)
+
+func (c *staleCache) put(key string, body []byte, csp string) {
+	if len(body) > c.maxBytes {
+		return
+	}
+	body = csrfTokenField.ReplaceAll(body, []byte("$1'"))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pages[key]; !ok {
+		if len(c.order) >= c.maxPages {
+			delete(c.pages, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.pages[key] = stalePage{body: body, csp: csp}
+}
+
+func (c *staleCache) get(key string) (stalePage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	page, ok := c.pages[key]
+	return page, ok
+}
+
+func (c *staleCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.pages)
+}
+
+// bodyRecorder keeps a copy of a response as it's written, up to limit
+// bytes. A longer response, such as a download, isn't copied at all, and
+// overflowed is set.
+type bodyRecorder struct {
+	http.ResponseWriter
+	status     int
+	body       bytes.Buffer
+	limit      int
+	overflowed bool
+}
+
+func (rec *bodyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *bodyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *bodyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflowed {
+		if rec.body.Len()+len(b) > rec.limit {
+			rec.overflowed = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// serveStale keeps copies of the pages it shows to visitors who aren't
+// signed in, and while the database's circuit breaker is open shows them
+// those copies instead of an error. Signed in users always get the error,
+// since a page made for somebody else would be confusing at best. It must
+// come before requireDatabase.
+func (app *application) serveStale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := r.Cookie(app.sessionManager.Cookie.Name); err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.URL.RequestURI()
+
+		if app.dbBreaker.Stats().State == query.BreakerOpen {
+			if page, ok := app.staleCache.get(key); ok {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Content-Security-Policy", page.csp)
+				w.Header().Set("Cache-Control", "no-store")
+				w.Header().Set("Warning", `110 - "Response is Stale"`)
+				w.Write(page.body)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bodyRecorder{ResponseWriter: w, limit: app.staleCache.maxBytes}
+		next.ServeHTTP(rec, r)
+
+		// Pages don't set a Content-Type, leaving it to be sniffed.
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(rec.body.Bytes())
+		}
+
+		cacheable := rec.status == http.StatusOK && r.Method == http.MethodGet && !rec.overflowed &&
+			strings.HasPrefix(contentType, "text/html") && w.Header().Get("Cache-Control") != "no-store"
+		if cacheable {
+			app.staleCache.put(key, rec.body.Bytes(), w.Header().Get("Content-Security-Policy"))
+		}
+	})
+}
+
+// healthz reports how the application is doing as JSON. Unlike /ready it
+// always succeeds, since a database outage is no reason to restart the
+// application, but it says whether the circuit breaker is open.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	stats := app.dbBreaker.Stats()
+
+	status := "ok"
+	if stats.State != query.BreakerClosed {
+		status = "degraded"
+	}
+
+	breaker := envelope{
+		"state":    stats.State.String(),
+		"failures": stats.Failures,
+		"trips":    stats.Trips,
+	}
+	if !stats.RetryAt.IsZero() {
+		breaker["retry_at"] = stats.RetryAt.UTC()
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": status, "database": envelope{"breaker": breaker}}, nil)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// logBreakerChange logs the database's circuit breaker opening and
+// closing, and shows it in systemctl status.
+func (app *application) logBreakerChange(state query.BreakerState) {
+	if _, err := systemd.Notify(systemd.Status(healthStatus(state))); err != nil {
+		app.errorLog.Print(err)
+	}
+
+	if state == query.BreakerOpen {
+		app.errorLog.Printf("database circuit breaker opened; retrying in %s", app.dbBreaker.Cooldown)
+		return
+	}
+	app.infoLog.Print("database circuit breaker closed; the database is back")
+}
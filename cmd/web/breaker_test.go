@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errDatabaseDown = errors.New("dial tcp: connection refused")
+
+// openBreaker gives app a circuit breaker which is already open.
+func openBreaker(app *application) {
+	app.dbBreaker = query.NewBreaker(1, time.Minute)
+	app.dbBreaker.Record(errDatabaseDown)
+}
+
+func TestRequireDatabase(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// The login page is shown to an anonymous visitor while the database
+	// is fine, so a copy of it is kept.
+	code, _, body := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+	csrfToken := extractCSRFToken(t, body)
+
+	openBreaker(app)
+
+	code, headers, body := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Warning"), `110 - "Response is Stale"`)
+	assert.StringContains(t, body, "<form action='/user/login'")
+	// One visitor's CSRF token isn't given to another.
+	assert.StringContains(t, body, "name='csrf_token' value=''")
+	assert.Equal(t, strings.Contains(body, csrfToken), false)
+
+	// Pages nobody has seen get the error page.
+	code, headers, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, headers.Get("Retry-After"), "60")
+	assert.StringContains(t, body, "reach our database at the moment")
+
+	// Forms can't be submitted.
+	code, _, _ = ts.postForm(t, "/user/login", nil)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+
+	// Nor can the API be used.
+	code, _, body = ts.get(t, "/api/v1/snippets")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, `"error": "We can't reach our database`)
+
+	// Once the database is back, everything works again.
+	app.dbBreaker = query.NewBreaker(1, time.Minute)
+
+	code, headers, _ = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Warning"), "")
+}
+
+func TestServeStaleSignedIn(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+
+	openBreaker(app)
+
+	// Signed in users aren't shown pages made for somebody else.
+	code, _, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+}
+
+func TestServerErrorCircuitOpen(t *testing.T) {
+	app := newTestApplication(t)
+	openBreaker(app)
+
+	rr := httptest.NewRecorder()
+	app.serverError(rr, fmt.Errorf("models: %w", query.ErrCircuitOpen))
+
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "60")
+	assert.StringContains(t, rr.Body.String(), "reach our database at the moment")
+}
+
+func TestStaleCache(t *testing.T) {
+	c := newStaleCache(2, 10)
+
+	c.put("/a", []byte("a"), "")
+	c.put("/b", []byte("b"), "")
+	c.put("/a", []byte("A"), "")
+	c.put("/big", []byte("far too big to keep"), "")
+	assert.Equal(t, c.len(), 2)
+
+	page, _ := c.get("/a")
+	assert.Equal(t, string(page.body), "A")
+
+	// The oldest page makes way.
+	c.put("/c", []byte("c"), "")
+	_, ok := c.get("/a")
+	assert.Equal(t, ok, false)
+	_, ok = c.get("/c")
+	assert.Equal(t, ok, true)
+}
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"status": "ok"`)
+	assert.StringContains(t, body, `"state": "closed"`)
+
+	openBreaker(app)
+
+	code, _, body = ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"status": "degraded"`)
+	assert.StringContains(t, body, `"state": "open"`)
+	assert.StringContains(t, body, `"trips": 1`)
+	assert.StringContains(t, body, `"retry_at":`)
+}
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	openBreaker(app)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, headers.Get("Content-Type"), "text/plain")
+	assert.StringContains(t, body, "# TYPE snippetbox_db_breaker_state gauge\nsnippetbox_db_breaker_state 1\n")
+	assert.StringContains(t, body, "snippetbox_db_breaker_trips_total 1\n")
+}
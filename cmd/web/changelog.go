@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/changelog"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/ui"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// loadChangelog reads the changelog embedded in the binary.
+func loadChangelog() ([]changelog.Entry, error) {
+	data, err := fs.ReadFile(ui.Files, "changelog.json")
+	if err != nil {
+		return nil, err
+	}
+	return changelog.Parse(data)
+}
+
+// latestChangelogVersion returns the version of the newest changelog entry,
+// or "" if there aren't any.
+func (app *application) latestChangelogVersion() string {
+	if len(app.changelog) == 0 {
+		return ""
+	}
+	return app.changelog[0].Version
+}
+
+// changelogNew reports whether the current user hasn't seen the newest
+// changelog entry, for the badge in the navigation bar. The version they've
+// seen is kept in their profile, and cached in the session so that it's
+// only looked up once. Like the notifications badge, errors are only
+// logged.
+func (app *application) changelogNew(r *http.Request) bool {
+	latest := app.latestChangelogVersion()
+	if latest == "" || !app.isAuthenticated(r) {
+		return false
+	}
+
+	if !app.sessionManager.Exists(r.Context(), "changelogSeen") {
+		seen, err := app.users.ChangelogSeen(reqctx.UserID(r.Context()))
+		if err != nil {
+			app.errorLog.Print(err)
+			return false
+		}
+		app.sessionManager.Put(r.Context(), "changelogSeen", seen)
+	}
+
+	return app.sessionManager.GetString(r.Context(), "changelogSeen") != latest
+}
+
+func (app *application) changelogView(w http.ResponseWriter, r *http.Request) {
+	// Looking at the page is what clears the badge, so this happens before
+	// the navigation bar is rendered.
+	if latest := app.latestChangelogVersion(); latest != "" && app.isAuthenticated(r) {
+		err := app.users.SetChangelogSeen(reqctx.UserID(r.Context()), latest)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "changelogSeen", latest)
+	}
+
+	data := app.newTemplateData(r)
+	data.Changelog = app.changelog
+	app.render(w, http.StatusOK, "changelog.tmpl.html", data)
+}
+
+// changelogCommand implements "web changelog", which adds an entry to the
+// top of ui/changelog.json. The changelog is embedded in the binary, so the
+// entry shows up once the application is rebuilt and deployed; users who
+// haven't looked at the new version yet then see a badge on the link.
+func changelogCommand(args []string) error {
+	var items []string
+
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	file := fs.String("file", "./ui/changelog.json", "Path to the changelog")
+	version := fs.String("version", "", "Version of the new entry")
+	date := fs.String("date", time.Now().Format(changelog.DateLayout), "Date of the new entry")
+	fs.Func("item", "A change in the new entry (repeat for each one)", func(s string) error {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+		return nil
+	})
+	fs.Parse(args)
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	data, err = changelog.Prepend(data, changelog.Entry{Version: *version, Date: *date, Items: items})
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(*file, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added version %s to %s.\n", *version, *file)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/changelog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangelog(t *testing.T) {
+	app := newTestApplication(t)
+	app.changelog = []changelog.Entry{
+		{Version: "2.0", Date: "2024-02-01", Items: []string{"Shiny new thing"}},
+		{Version: "1.0", Date: "2024-01-01", Items: []string{"First release"}},
+	}
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	const badge = "<span class='badge'>new</span>"
+
+	// Anonymous users see the changelog, but never the badge.
+	code, _, body := ts.get(t, "/changelog")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Shiny new thing")
+	assert.StringContains(t, body, "1 February 2024")
+	assert.Equal(t, strings.Contains(body, badge), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, badge)
+
+	// Reading the changelog clears the badge, on that page too...
+	_, _, body = ts.get(t, "/changelog")
+	assert.Equal(t, strings.Contains(body, badge), false)
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, badge), false)
+
+	// ...and in later sessions.
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body = ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, badge), false)
+
+	// Until there's another entry.
+	app.changelog = append([]changelog.Entry{{Version: "3.0", Date: "2024-03-01", Items: []string{"Even newer"}}}, app.changelog...)
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, badge)
+}
+
+func TestChangelogCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "changelog.json")
+	err := os.WriteFile(file, []byte(`[{"version":"1.0","date":"2024-01-01","items":["First release"]}]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = changelogCommand([]string{"-file", file, "-version", "1.1", "-date", "2024-02-01", "-item", "Faster, with commas", "-item", "Smaller"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := changelog.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, entries[0].Version, "1.1")
+	assert.Equal(t, entries[0].Items[0], "Faster, with commas")
+
+	// The same version can't be added twice.
+	err = changelogCommand([]string{"-file", file, "-version", "1.1", "-item", "Again"})
+	assert.Equal(t, err != nil, true)
+}
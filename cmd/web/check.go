@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// checkCommand implements "web check", a smoke test for a deployment. It
+// boots the application with the same flags as the server, serves it on a
+// test listener over HTTPS and checks the things which keep visitors safe:
+// the security headers, the cookie flags, CSRF protection on every POST
+// route and the lack of directory listings. It prints a report and fails if
+// any check does.
+//
+// Only requests which change nothing are made, so it's safe to run against
+// the production database.
+func checkCommand(args []string) error {
+	var cfg config
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	cfg.registerFlags(fs)
+	fs.Parse(args)
+
+	// The report goes to standard output, so only errors are logged, to
+	// standard error.
+	l := &logs{
+		infoLog:   log.New(io.Discard, "", 0),
+		errorLog:  log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime),
+		accessLog: logging.NewAccessLog(io.Discard, logging.Human),
+	}
+
+	app, closeDB, err := newApplication(cfg, l)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	results := app.runChecks(ts.URL, ts.Client())
+
+	failed := writeCheckReport(os.Stdout, results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// checkResult is the outcome of one check. err says what was wrong, if
+// anything.
+type checkResult struct {
+	name string
+	err  error
+}
+
+// writeCheckReport prints a line for each check and returns how many
+// failed.
+func writeCheckReport(w io.Writer, results []checkResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL  %s: %v\n", r.name, r.err)
+		} else {
+			fmt.Fprintf(w, "PASS  %s\n", r.name)
+		}
+	}
+	fmt.Fprintf(w, "\n%d checks, %d failed\n", len(results), failed)
+	return failed
+}
+
+// checkedHeaders are the security headers every response must have. An
+// empty value means any value will do.
+var checkedHeaders = []struct {
+	name  string
+	value string
+}{
+	{"Content-Security-Policy", ""},
+	{"Strict-Transport-Security", ""},
+	{"Referrer-Policy", ""},
+	{"X-Content-Type-Options", "nosniff"},
+	{"X-Frame-Options", "deny"},
+}
+
+// csrfExempt are the middleware sets which deliberately do without CSRF
+// protection, as they don't use the session cookie or, for one-click links,
+// are authorized by a signature in the URL instead; see middlewareStacks.
+var csrfExempt = map[middlewareSet]bool{
+	chainNone:         true,
+	chainCSPReport:    true,
+	chainAPI:          true,
+	chainAPIProtected: true,
+	chainQuick:        true,
+	chainActivityPub:  true,
+	chainOneClick:     true,
+}
+
+// runChecks makes the requests for each check against the application
+// served at base, using client, which must trust the server's certificate
+// and not follow redirects.
+func (app *application) runChecks(base string, client *http.Client) []checkResult {
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var results []checkResult
+	var cookies []*http.Cookie
+
+	// A page, a form, a static file and an error page, as each of them is
+	// served differently.
+	for _, p := range []string{urlFor("home"), urlFor("user.login"), urlFor("static", "css/main.css"), "/check/not-found"} {
+		rs, err := client.Get(base + p)
+		if err == nil {
+			rs.Body.Close()
+			cookies = append(cookies, rs.Cookies()...)
+			err = checkHeaders(rs.Header)
+		}
+		results = append(results, checkResult{name: "security headers on " + p, err: err})
+	}
+
+	results = append(results, checkResult{name: "cookies are Secure and HttpOnly", err: app.checkCookies(cookies)})
+	results = append(results, checkResult{name: "POST routes require a CSRF token", err: checkCSRF(base, client)})
+	results = append(results, checkResult{name: "no directory listings under /static", err: checkDirectoryListings(base, client)})
+
+	return results
+}
+
+func checkHeaders(h http.Header) error {
+	var problems []string
+	for _, want := range checkedHeaders {
+		got := h.Get(want.name)
+		switch {
+		case got == "":
+			problems = append(problems, want.name+" is missing")
+		case want.value != "" && !strings.EqualFold(got, want.value):
+			problems = append(problems, fmt.Sprintf("%s is %q, not %q", want.name, got, want.value))
+		}
+	}
+	return joinProblems(problems)
+}
+
+// checkCookies checks the cookies which were set, along with the settings
+// of the session cookie, as the session cookie is only set once something
+// is stored in the session.
+func (app *application) checkCookies(cookies []*http.Cookie) error {
+	var problems []string
+
+	session := app.sessionManager.Cookie
+	if !session.Secure {
+		problems = append(problems, "the session cookie isn't Secure")
+	}
+	if !session.HttpOnly {
+		problems = append(problems, "the session cookie isn't HttpOnly")
+	}
+
+	for _, c := range cookies {
+		if !c.Secure {
+			problems = append(problems, c.Name+" isn't Secure")
+		}
+		if !c.HttpOnly {
+			problems = append(problems, c.Name+" isn't HttpOnly")
+		}
+	}
+	if len(cookies) == 0 {
+		problems = append(problems, "no cookies were set, so there was nothing to check")
+	}
+
+	return joinProblems(problems)
+}
+
+// checkCSRF posts an empty form without a CSRF token to each POST route in
+// the route table which uses the session, and expects it to be turned away
+// with the 400 Bad Request sent by the CSRF middleware.
+func checkCSRF(base string, client *http.Client) error {
+	param := regexp.MustCompile(`[:*]\w+`)
+
+	var problems []string
+	for _, rt := range routeTable {
+		if rt.method != http.MethodPost || csrfExempt[rt.chain] {
+			continue
+		}
+
+		args := []any{}
+		for range param.FindAllString(rt.pattern, -1) {
+			args = append(args, 1)
+		}
+		p := urlFor(rt.name, args...)
+
+		rs, err := client.PostForm(base+p, nil)
+		if err != nil {
+			return err
+		}
+		rs.Body.Close()
+
+		if rs.StatusCode != http.StatusBadRequest {
+			problems = append(problems, fmt.Sprintf("POST %s without a token got %d", p, rs.StatusCode))
+		}
+	}
+	return joinProblems(problems)
+}
+
+// checkDirectoryListings asks for each directory of static files, and
+// expects a 404 rather than a list of what's in it.
+func checkDirectoryListings(base string, client *http.Client) error {
+	var problems []string
+	err := fs.WalkDir(staticFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		p := path.Clean(urlFor("static", name)) + "/"
+		rs, err := client.Get(base + p)
+		if err != nil {
+			return err
+		}
+		rs.Body.Close()
+
+		if rs.StatusCode != http.StatusNotFound {
+			problems = append(problems, fmt.Sprintf("GET %s got %d", p, rs.StatusCode))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return joinProblems(problems)
+}
+
+func joinProblems(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
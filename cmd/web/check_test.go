@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRunChecks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	results := app.runChecks(ts.URL, ts.Client())
+
+	for _, r := range results {
+		if r.err != nil {
+			t.Errorf("%s: %v", r.name, r.err)
+		}
+	}
+
+	var buf bytes.Buffer
+	failed := writeCheckReport(&buf, results)
+	assert.Equal(t, failed, 0)
+	assert.StringContains(t, buf.String(), "PASS  POST routes require a CSRF token")
+}
+
+func TestRunChecksFailures(t *testing.T) {
+	app := newTestApplication(t)
+	app.sessionManager.Cookie.Secure = false
+
+	// A handler with none of the middleware fails everything.
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "tracker", Value: "1"})
+		w.Write([]byte("OK"))
+	}))
+	defer ts.Close()
+
+	results := app.runChecks(ts.URL, ts.Client())
+
+	var buf bytes.Buffer
+	failed := writeCheckReport(&buf, results)
+	assert.Equal(t, failed, len(results))
+
+	report := buf.String()
+	assert.StringContains(t, report, "FAIL  security headers on /: Content-Security-Policy is missing")
+	assert.StringContains(t, report, "the session cookie isn't Secure")
+	assert.StringContains(t, report, "tracker isn't HttpOnly")
+	assert.StringContains(t, report, "POST /user/logout without a token got 200")
+	assert.StringContains(t, report, "GET /static/css/ got 200")
+}
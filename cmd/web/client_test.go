@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/pkg/client"
+	"net/http"
+	"testing"
+)
+
+// TestClient checks pkg/client against the real API routes.
+func TestClient(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	token, err := app.apiTokens.Insert(1, "Client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.New(ts.URL, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HTTPClient = ts.Client()
+
+	ctx := context.Background()
+
+	snippet, err := c.GetSnippet(ctx, "00000000000000000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.Title, "An old silent pond")
+
+	_, err = c.GetSnippet(ctx, "00000000000000000000000002")
+	assert.Equal(t, client.IsNotFound(err), true)
+
+	page, err := c.ListSnippets(ctx, client.ListOptions{Page: 1, PerPage: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, page.Total, 1)
+	assert.Equal(t, len(page.Snippets), 1)
+
+	page, err = c.ListSnippets(ctx, client.ListOptions{Fields: []string{"title"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, page.Snippets[0].Title, "An old silent pond")
+	assert.Equal(t, page.Snippets[0].Content, "")
+
+	page, err = c.ListSnippets(ctx, client.ListOptions{Cursor: client.Start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.NextCursor, "")
+
+	// The only snippet belongs to somebody else.
+	page, err = c.ListSnippets(ctx, client.ListOptions{Mine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, page.Total, 0)
+
+	snippet, err = c.CreateSnippet(ctx, client.NewSnippet{Title: "Hello", Content: "world", Expires: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippet.ID, "00000000000000000000000001")
+
+	_, err = c.CreateSnippet(ctx, client.NewSnippet{Title: "Hello", Content: "world", Expires: 3})
+	var e *client.Error
+	assert.Equal(t, errors.As(err, &e), true)
+	assert.Equal(t, e.StatusCode, http.StatusUnprocessableEntity)
+	assert.Equal(t, e.FieldErrors["expires"], "This field must equal 1, 7 or 365")
+
+	// Without a token, snippets can be read but not created.
+	anonymous, err := client.New(ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous.HTTPClient = ts.Client()
+
+	_, err = anonymous.GetSnippet(ctx, "00000000000000000000000001")
+	assert.Equal(t, err, nil)
+
+	_, err = anonymous.ListSnippets(ctx, client.ListOptions{Mine: true})
+	assert.Equal(t, errors.As(err, &e), true)
+	assert.Equal(t, e.StatusCode, http.StatusUnauthorized)
+
+	_, err = anonymous.CreateSnippet(ctx, client.NewSnippet{Title: "Hello", Content: "world", Expires: 7})
+	assert.Equal(t, errors.As(err, &e), true)
+	assert.Equal(t, e.StatusCode, http.StatusUnauthorized)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"strconv"
+)
+
+// collectionForm is the form for creating and editing collections. ID is
+// zero for a new collection.
+type collectionForm struct {
+	ID                  int    `form:"-"`
+	Name                string `form:"name"`
+	Description         string `form:"description"`
+	validator.Validator `form:"-"`
+}
+
+func (f *collectionForm) validate() {
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.MaxChars(f.Description, 1000), "description", "This field cannot be more than 1000 characters long")
+}
+
+func (f *collectionForm) collection(userID int) *models.Collection {
+	return &models.Collection{
+		ID:          f.ID,
+		UserID:      userID,
+		Name:        f.Name,
+		Description: f.Description,
+	}
+}
+
+// collectionSnippetForm names a snippet in a collection, and for moving it,
+// which way to go.
+type collectionSnippetForm struct {
+	Snippet   int    `form:"snippet"`
+	Direction string `form:"direction"`
+}
+
+// ownCollection returns the user's collection named in the URL, or sends a
+// 404 and returns nil if they have no such collection.
+func (app *application) ownCollection(w http.ResponseWriter, r *http.Request) *models.Collection {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return nil
+	}
+
+	c, err := app.collections.Get(id, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return c
+}
+
+func (app *application) accountCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := app.collections.ForUser(reqctx.UserID(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collections = collections
+	app.render(w, http.StatusOK, "collections.tmpl.html", data)
+}
+
+func (app *application) accountCollectionCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = collectionForm{}
+	app.render(w, http.StatusOK, "collection_form.tmpl.html", data)
+}
+
+func (app *application) accountCollectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	var id int
+	if form.Valid() {
+		id, err = app.collections.Insert(form.collection(reqctx.UserID(r.Context())))
+		if errors.Is(err, models.ErrDuplicateCollectionName) {
+			form.AddFieldError("name", "You already have a collection with this name")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "collection_form.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Collection %q created.", form.Name))
+	http.Redirect(w, r, urlFor("account.collections.edit", id), http.StatusSeeOther)
+}
+
+// accountCollectionEdit shows the form for renaming a collection, along with
+// its snippets so they can be reordered or taken out of it.
+func (app *application) accountCollectionEdit(w http.ResponseWriter, r *http.Request) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	app.renderCollectionForm(w, r, http.StatusOK, c, collectionForm{ID: c.ID, Name: c.Name, Description: c.Description})
+}
+
+func (app *application) renderCollectionForm(w http.ResponseWriter, r *http.Request, status int, c *models.Collection, form collectionForm) {
+	snippets, err := app.collections.Snippets(c.ID, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = c
+	data.Snippets = snippets
+	data.Form = form
+	app.render(w, status, "collection_form.tmpl.html", data)
+}
+
+func (app *application) accountCollectionEditPost(w http.ResponseWriter, r *http.Request) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.ID = c.ID
+
+	form.validate()
+
+	if form.Valid() {
+		err = app.collections.Update(form.collection(c.UserID))
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+			return
+		} else if errors.Is(err, models.ErrDuplicateCollectionName) {
+			form.AddFieldError("name", "You already have a collection with this name")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.renderCollectionForm(w, r, http.StatusUnprocessableEntity, c, form)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Collection %q saved.", form.Name))
+	http.Redirect(w, r, urlFor("account.collections"), http.StatusSeeOther)
+}
+
+func (app *application) accountCollectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.collections.Delete(id, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection deleted. Its snippets haven't been.")
+	http.Redirect(w, r, urlFor("account.collections"), http.StatusSeeOther)
+}
+
+// accountCollectionRemovePost takes a snippet out of a collection.
+func (app *application) accountCollectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	app.changeCollection(w, r, func(c *models.Collection, form collectionSnippetForm) error {
+		return app.collections.RemoveSnippet(c.ID, form.Snippet)
+	})
+}
+
+// accountCollectionMovePost moves a snippet up or down in a collection.
+func (app *application) accountCollectionMovePost(w http.ResponseWriter, r *http.Request) {
+	app.changeCollection(w, r, func(c *models.Collection, form collectionSnippetForm) error {
+		if form.Direction != "up" && form.Direction != "down" {
+			return errBadDirection
+		}
+		return app.collections.MoveSnippet(c.ID, form.Snippet, form.Direction == "up")
+	})
+}
+
+// errBadDirection is returned when asked to move a snippet neither up nor
+// down.
+var errBadDirection = errors.New("direction must be up or down")
+
+// changeCollection makes a change to the snippets in the user's collection
+// named in the URL, and goes back to the collection's page.
+func (app *application) changeCollection(w http.ResponseWriter, r *http.Request, change func(*models.Collection, collectionSnippetForm) error) {
+	c := app.ownCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = change(c, form)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, errBadDirection):
+			app.clientError(w, http.StatusBadRequest)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, urlFor("account.collections.edit", c.ID), http.StatusSeeOther)
+}
+
+type snippetCollectForm struct {
+	Collection int `form:"collection"`
+}
+
+// snippetCollectPost adds one of the user's snippets to one of their
+// collections, from the snippet's page.
+func (app *application) snippetCollectPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	var form snippetCollectForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	// Collections can only hold their owner's own snippets.
+	if snippet.UserID != userID || snippet.IsBurned() {
+		app.notFound(w)
+		return
+	}
+
+	c, err := app.collections.Get(form.Collection, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusUnprocessableEntity)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.collections.AddSnippet(c.ID, snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Added to %q.", c.Name))
+	http.Redirect(w, r, urlFor("snippet.view", snippet.PublicID), http.StatusSeeOther)
+}
+
+// collectionView is the public page of a collection. Its owner also sees
+// the snippets in it which are scheduled, burn after reading or encrypted;
+// everyone else only sees the published ones.
+func (app *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	c, err := app.collections.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	isOwner := reqctx.UserID(r.Context()) == c.UserID
+
+	snippets, err := app.collections.Snippets(c.ID, isOwner)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = c
+	data.Snippets = snippets
+	data.IsOwner = isOwner
+	app.render(w, http.StatusOK, "collection.tmpl.html", data)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAccountCollections(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/account/collections")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// The admin owns the mock snippets 1 and 4.
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/account/collections")
+	assert.StringContains(t, body, "You don't have any collections yet.")
+
+	csrfToken := ts.csrfToken(t, "/account/collections/create")
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("name", "Go tips")
+	form.Add("description", "Things worth remembering.")
+
+	code, headers, _ = ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/account/collections/edit/1")
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, `Collection "Go tips" created.`)
+	assert.StringContains(t, body, "<a href='/collection/go-tips'>/collection/go-tips</a>")
+	assert.StringContains(t, body, "There's nothing in this collection yet.")
+
+	// Names are unique per user.
+	code, _, body = ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You already have a collection with this name")
+
+	form.Set("name", "")
+	code, _, body = ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	// Snippets are added from their pages.
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<form class='collect' action='/snippet/collect/00000000000000000000000001' method='POST'>")
+
+	for _, id := range []string{"00000000000000000000000001", "00000000000000000000000004"} {
+		code, headers, _ = ts.postForm(t, "/snippet/collect/"+id, url.Values{"csrf_token": {csrfToken}, "collection": {"1"}})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/"+id)
+	}
+
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<a href='/collection/go-tips'>Go tips</a>")
+
+	_, _, body = ts.get(t, "/account/collections")
+	assert.StringContains(t, body, "<td>2</td>")
+
+	// Reordering.
+	move := url.Values{"csrf_token": {csrfToken}, "snippet": {"4"}, "direction": {"up"}}
+	code, _, _ = ts.postForm(t, "/account/collections/move/1", move)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/account/collections/edit/1")
+	assert.Equal(t, strings.Index(body, "/snippet/view/00000000000000000000000004") < strings.Index(body, "/snippet/view/00000000000000000000000001"), true)
+
+	move.Set("direction", "sideways")
+	code, _, _ = ts.postForm(t, "/account/collections/move/1", move)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Renaming keeps the slug.
+	form.Set("name", "Go")
+	code, headers, _ = ts.postForm(t, "/account/collections/edit/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, `Collection "Go" saved.`)
+	assert.StringContains(t, body, "<a href='/collection/go-tips'>Go</a>")
+
+	// Removing.
+	code, _, _ = ts.postForm(t, "/account/collections/remove/1", url.Values{"csrf_token": {csrfToken}, "snippet": {"4"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.postForm(t, "/account/collections/remove/1", url.Values{"csrf_token": {csrfToken}, "snippet": {"4"}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Deleting.
+	code, headers, _ = ts.postForm(t, "/account/collections/delete/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Collection deleted. Its snippets haven't been.")
+
+	code, _, _ = ts.get(t, "/collection/go-tips")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCollectionView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	form := url.Values{"csrf_token": {ts.csrfToken(t, "/account/collections/create")}, "name": {"Mine"}}
+	code, _, _ := ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	for _, id := range []string{"00000000000000000000000001", "00000000000000000000000004"} {
+		form := url.Values{"csrf_token": form["csrf_token"], "collection": {"1"}}
+		code, _, _ = ts.postForm(t, "/snippet/collect/"+id, form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// The owner sees their burn after reading snippet in the collection.
+	_, _, body := ts.get(t, "/collection/mine")
+	assert.StringContains(t, body, "<a href='/snippet/view/00000000000000000000000001'>")
+	assert.StringContains(t, body, "<a href='/snippet/view/00000000000000000000000004'>")
+	assert.StringContains(t, body, "Edit collection")
+
+	// Everyone else only sees the published snippets.
+	ts.resetClient(t)
+
+	code, _, body = ts.get(t, "/collection/mine")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/00000000000000000000000001'>")
+	assert.Equal(t, strings.Contains(body, "/snippet/view/00000000000000000000000004"), false)
+	assert.Equal(t, strings.Contains(body, "Edit collection"), false)
+}
+
+func TestCollectionsOtherUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	csrfToken := ts.csrfToken(t, "/account/collections/create")
+	form := url.Values{"csrf_token": {csrfToken}, "name": {"Admin's"}}
+	code, _, _ := ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken = ts.csrfToken(t, "/account/collections/create")
+
+	code, _, _ = ts.get(t, "/account/collections/edit/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/account/collections/delete/1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Alice can't put her snippet in the admin's collection, nor the
+	// admin's snippet in a collection of her own.
+	code, _, _ = ts.postForm(t, "/snippet/collect/00000000000000000000000003", url.Values{"csrf_token": {csrfToken}, "collection": {"1"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form = url.Values{"csrf_token": {csrfToken}, "name": {"Alice's"}}
+	code, _, _ = ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.postForm(t, "/snippet/collect/00000000000000000000000001", url.Values{"csrf_token": {csrfToken}, "collection": {"2"}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetCreateInCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	csrfToken := ts.csrfToken(t, "/account/collections/create")
+	form := url.Values{"csrf_token": {csrfToken}, "name": {"Scripts"}}
+	code, _, _ := ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='1'>Scripts</option>")
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("title", "Hello")
+	form.Add("content", "echo hello")
+	form.Add("expires", "7")
+	form.Add("collection", "2")
+
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Pick one of your collections")
+
+	form.Set("collection", "1")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The mock snippet model gives every new snippet ID 1.
+	_, _, body = ts.get(t, "/account/collections")
+	assert.StringContains(t, body, "<td>1</td>")
+}
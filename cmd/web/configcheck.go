@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"io/fs"
+	"log"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The certificate and key served when TLS is terminated by the application.
+const (
+	tlsCertFile = "./tls/cert.pem"
+	tlsKeyFile  = "./tls/key.pem"
+)
+
+// smtpDialTimeout is how long validateConfig waits to connect to the SMTP
+// server.
+const smtpDialTimeout = 5 * time.Second
+
+// configCheck is one of the checks validateConfig makes. check says what's
+// wrong with the configuration, if anything, and how to put it right.
+type configCheck struct {
+	name  string
+	check func(cfg config) error
+}
+
+// configChecks are run in order by validateConfig. Most of them go through
+// the same functions as newApplication, so that they can't disagree about
+// what's valid; the rest check the things newApplication would only trip
+// over later, like a missing certificate or an unreachable mail server.
+var configChecks = []configCheck{
+	{"listen address (-addr, -admin-addr)", checkAddr},
+	{"TLS (-tls, -tls-*, -trusted-proxies)", checkTLS},
+	{"database (-dsn)", checkDSN},
+	{"static files (-static-dir)", checkStaticFiles},
+	{"base URL (-base-url)", func(cfg config) error {
+		_, err := loadBaseURL(cfg)
+		return err
+	}},
+	{"feature flags (-features, -signup-mode)", checkFeatures},
+	{"encryption keys (-encryption-keys-env)", func(cfg config) error {
+		_, err := loadEncryptionKeys(cfg.encryptionKeysEnv)
+		return err
+	}},
+	{"link signing keys (-url-signing-keys-env)", func(cfg config) error {
+		_, err := loadURLSigner(cfg.urlSigningKeysEnv)
+		return err
+	}},
+	{"IP rules (-admin-ip-rules, -login-ip-rules)", func(cfg config) error {
+		_, err := loadIPRules(cfg.ipRules.admin, cfg.ipRules.login)
+		return err
+	}},
+	{"/.well-known files (-well-known-dir, -security-contact, -security-policy)", func(cfg config) error {
+		_, err := newWellKnown(cfg.wellKnown.dir, cfg.wellKnown.securityContacts, cfg.wellKnown.securityPolicy)
+		return err
+	}},
+	{"CORS (-cors-trusted-origins, -cors-allow-credentials)", func(cfg config) error {
+		return cfg.cors.validate()
+	}},
+	{"accounts (-auth-backend, -password-hash, -ldap-*, -oidc-*)", checkAccounts},
+	{"snippet limits (-max-snippet-bytes, -max-snippet-lines)", func(cfg config) error {
+		var problems []string
+		if cfg.contentLimits.MaxBytes < 0 {
+			problems = append(problems, "-max-snippet-bytes can't be negative; use 0 for no limit")
+		}
+		if cfg.contentLimits.MaxLines < 0 {
+			problems = append(problems, "-max-snippet-lines can't be negative; use 0 for no limit")
+		}
+		return joinProblems(problems)
+	}},
+	{"snippet IDs (-snippet-ids)", func(cfg config) error {
+		_, err := publicid.Parse(cfg.snippetIDs)
+		return err
+	}},
+	{"rate limits (-api-rate-*, -soft-rate-limit-*)", checkRateLimits},
+	{"logging (-log-*, -access-log-output)", checkLogging},
+	{"data retention (-retention-*)", func(cfg config) error {
+		_, err := newRetentionPolicy(cfg)
+		return err
+	}},
+	{"email (-smtp-*, -sendgrid-api-key)", checkEmail},
+}
+
+// validateConfig runs every check in configChecks against cfg, so that all
+// the problems with a configuration are reported at once, rather than the
+// first one stopping the server.
+func validateConfig(cfg config) []checkResult {
+	results := make([]checkResult, 0, len(configChecks))
+	for _, c := range configChecks {
+		results = append(results, checkResult{name: c.name, err: c.check(cfg)})
+	}
+	return results
+}
+
+// logConfigProblems logs each failed check in results and returns how many
+// there were.
+func logConfigProblems(l *log.Logger, results []checkResult) int {
+	n := 0
+	for _, r := range results {
+		if r.err != nil {
+			l.Printf("config: %s: %v", r.name, r.err)
+			n++
+		}
+	}
+	return n
+}
+
+func checkAddr(cfg config) error {
+	seen := map[string]bool{}
+	for _, lc := range cfg.listeners() {
+		if seen[lc.addr] {
+			return fmt.Errorf("%q is given more than once", lc.addr)
+		}
+		seen[lc.addr] = true
+
+		if path, ok := strings.CutPrefix(lc.addr, "unix:"); ok {
+			// The admin listeners don't take PROXY protocol headers.
+			if cfg.proxyProtocol && !lc.admin {
+				return errors.New("-proxy-protocol is only supported on TCP listeners; use a host:port address")
+			}
+			if err := checkDir(filepath.Dir(path), "the directory for the socket"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, port, err := net.SplitHostPort(lc.addr)
+		if err != nil {
+			return fmt.Errorf("%q must be host:port, like \":4000\" or \"[::1]:4443\", or unix:/path/to.sock", lc.addr)
+		}
+		if _, err := net.LookupPort("tcp", port); err != nil {
+			return fmt.Errorf("%q has an unknown port %q", lc.addr, port)
+		}
+	}
+	return nil
+}
+
+// checkTLS checks how each listener terminates TLS and, for those which
+// serve HTTPS themselves, that their certificate and key can be loaded, and
+// the TLS settings they share.
+func checkTLS(cfg config) error {
+	listeners := cfg.listeners()
+	if _, err := loadTrustedProxies(listeners, cfg.proxies); err != nil {
+		return err
+	}
+	if _, err := newTLSConfig(cfg.tlsSettings); err != nil {
+		return err
+	}
+	if err := checkClientAuth(listeners, cfg.tlsSettings); err != nil {
+		return err
+	}
+
+	for _, lc := range listeners {
+		if lc.tls != tlsApp {
+			if lc.certFile != "" || lc.keyFile != "" {
+				return fmt.Errorf("%s: cert and key are only used with tls=app", lc.addr)
+			}
+			continue
+		}
+		if lc.certFile == "" || lc.keyFile == "" {
+			return fmt.Errorf("%s: give both cert and key, or neither to use %s and %s", lc.addr, tlsCertFile, tlsKeyFile)
+		}
+
+		for _, file := range []string{lc.certFile, lc.keyFile} {
+			if _, err := os.Stat(file); err != nil {
+				return fmt.Errorf("%w; create a certificate and key there (for development, with crypto/tls's generate_cert.go), or terminate TLS elsewhere with -tls=proxy", err)
+			}
+		}
+
+		if _, err := tls.LoadX509KeyPair(lc.certFile, lc.keyFile); err != nil {
+			return fmt.Errorf("loading %s and %s: %w", lc.certFile, lc.keyFile, err)
+		}
+	}
+	return nil
+}
+
+// checkDSN checks that the DSN parses and asks for the options the models
+// depend on. It doesn't connect, since the database may not be up yet when
+// the server starts; newApplication waits for it.
+func checkDSN(cfg config) error {
+	dsn, err := mysql.ParseDSN(cfg.dsn)
+	if err != nil {
+		return fmt.Errorf("%w; it should look like user:password@tcp(host:3306)/snippetbox?parseTime=true", err)
+	}
+	if !dsn.ParseTime {
+		return errors.New("the DSN must include parseTime=true, so that dates are read as times")
+	}
+	if dsn.DBName == "" {
+		return errors.New("the DSN doesn't name a database; add one after the slash, like /snippetbox")
+	}
+	return nil
+}
+
+// checkStaticFiles checks that there are static files to serve: the ones in
+// -static-dir, or failing that the ones embedded in the binary.
+func checkStaticFiles(cfg config) error {
+	if fi, err := os.Stat(cfg.staticDir); err == nil && fi.IsDir() {
+		return nil
+	}
+	if _, err := fs.Stat(staticFS, "css/main.css"); err != nil {
+		return fmt.Errorf("%s isn't a directory and the embedded static files are missing (%v); rebuild the binary or point -static-dir at ui/static", cfg.staticDir, err)
+	}
+	return nil
+}
+
+func checkFeatures(cfg config) error {
+	if _, err := features.Parse(cfg.features); err != nil {
+		return err
+	}
+	if cfg.signupMode != "" {
+		if _, err := features.SignupModeFlags(cfg.signupMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAccounts checks the settings for where accounts come from. The user
+// model is only built, not used, so the LDAP server isn't contacted.
+func checkAccounts(cfg config) error {
+	if _, err := newUserModel(cfg, nil); err != nil {
+		return err
+	}
+	if _, err := newSSO(cfg.oidc.issuer, cfg.oidc.clientID, cfg.oidc.clientSecret, cfg.oidc.name, cfg.oidc.enforce, cfg.oidc.provision); err != nil {
+		return err
+	}
+	_, err := newWebAuthn(cfg.webauthn.rpID, cfg.webauthn.origins)
+	return err
+}
+
+func checkRateLimits(cfg config) error {
+	var problems []string
+	if cfg.apiRateLimit.requestsPerHour < 1 {
+		problems = append(problems, "-api-rate-limit must be at least 1")
+	}
+	if cfg.apiRateLimit.burst < 1 {
+		problems = append(problems, "-api-rate-burst must be at least 1")
+	}
+	if cfg.softRateLimit.enabled && cfg.softRateLimit.halfLife <= 0 {
+		problems = append(problems, "-soft-rate-limit-half-life must be positive, or turn soft rate limiting off with -soft-rate-limit=false")
+	}
+	return joinProblems(problems)
+}
+
+func checkLogging(cfg config) error {
+	var problems []string
+	if _, err := logging.ParseFormat(cfg.log.format); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, path := range []string{cfg.log.output, cfg.log.accessOutput, cfg.log.auditOutput} {
+		if path == "" || path == "stdout" || path == "stderr" {
+			continue
+		}
+		if err := checkWritable(path); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return joinProblems(problems)
+}
+
+// checkEmail checks the sender address and, if emails are sent by SMTP,
+// that the server accepts connections.
+func checkEmail(cfg config) error {
+	if cfg.sendGridAPIKey == "" && cfg.smtp.host == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(cfg.smtp.sender); err != nil {
+		return fmt.Errorf("-smtp-sender %q isn't an email address: %v", cfg.smtp.sender, err)
+	}
+	if cfg.sendGridAPIKey != "" {
+		return nil
+	}
+
+	addr := net.JoinHostPort(cfg.smtp.host, strconv.Itoa(cfg.smtp.port))
+	conn, err := net.DialTimeout("tcp", addr, smtpDialTimeout)
+	if err != nil {
+		return fmt.Errorf("can't reach the SMTP server at %s: %w; check -smtp-host and -smtp-port, and that the firewall lets us through", addr, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// checkDir checks that dir exists and is a directory.
+func checkDir(dir, what string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s, %s, isn't a directory", what, dir)
+	}
+	return nil
+}
+
+// checkWritable checks that a log file can be written: an existing file must
+// be writable, and otherwise the nearest directory which exists above it
+// must be a directory, since logging.Open creates the rest. Nothing is
+// created.
+func checkWritable(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	dir := filepath.Dir(path)
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("%s: %s isn't a directory", path, dir)
+			}
+			return nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
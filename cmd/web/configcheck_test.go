@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net"
+	"testing"
+)
+
+// defaultConfig returns the configuration the server gets with no flags.
+func defaultConfig(t *testing.T) config {
+	var cfg config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.registerFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg := defaultConfig(t)
+	// There's no certificate under cmd/web, so TLS is left to a proxy.
+	cfg.tls = tlsProxy
+	cfg.proxies = "127.0.0.1"
+
+	for _, r := range validateConfig(cfg) {
+		if r.err != nil {
+			t.Errorf("%s: %v", r.name, r.err)
+		}
+	}
+}
+
+func TestValidateConfigProblems(t *testing.T) {
+	// Nothing is listening on the port once the listener is closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	cfg := defaultConfig(t)
+	cfg.addrs = listenerList{{addr: "4000"}}
+	cfg.dsn = "web:pass@/snippetbox"
+	cfg.log.format = "xml"
+	cfg.smtp.host = "127.0.0.1"
+	cfg.smtp.port = addr.Port
+	cfg.softRateLimit.halfLife = 0
+	cfg.cors = corsConfig{trustedOrigins: stringList{"*"}, allowCredentials: true}
+
+	var buf bytes.Buffer
+	failed := writeCheckReport(&buf, validateConfig(cfg))
+
+	report := buf.String()
+	assert.StringContains(t, report, `FAIL  listen address (-addr, -admin-addr): "4000" must be host:port`)
+	assert.StringContains(t, report, "FAIL  TLS (-tls, -tls-*, -trusted-proxies): stat ./tls/cert.pem")
+	assert.StringContains(t, report, "FAIL  database (-dsn): the DSN must include parseTime=true")
+	assert.StringContains(t, report, "FAIL  rate limits (-api-rate-*, -soft-rate-limit-*): -soft-rate-limit-half-life must be positive")
+	assert.StringContains(t, report, `FAIL  logging (-log-*, -access-log-output): logging: unknown format "xml"`)
+	assert.StringContains(t, report, "FAIL  email (-smtp-*, -sendgrid-api-key): can't reach the SMTP server at 127.0.0.1:")
+	assert.StringContains(t, report, `FAIL  CORS (-cors-trusted-origins, -cors-allow-credentials): -cors-allow-credentials can't be used when -cors-trusted-origins is "*"`)
+	assert.StringContains(t, report, "FAIL  base URL (-base-url): -smtp-host and -sendgrid-api-key need -base-url")
+	assert.StringContains(t, report, "PASS  static files (-static-dir)")
+	assert.Equal(t, failed, 8)
+}
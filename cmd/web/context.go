@@ -1,5 +0,0 @@
-package main
-
-type contextKey string
-
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// corsConfig is the Cross-Origin Resource Sharing setup for the JSON API.
+// trustedOrigins are the origins (like "https://app.example.com") whose pages
+// may call the API, or "*" for any origin. If allowCredentials is set,
+// browsers send the session cookie along with requests from those origins;
+// since the cookie is SameSite=Strict, this only works for origins on the
+// same site, such as other subdomains.
+type corsConfig struct {
+	trustedOrigins   stringList
+	allowCredentials bool
+}
+
+// trusts reports whether requests from the given origin are allowed.
+func (c corsConfig) trusts(origin string) bool {
+	if c.trustsAny() {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, trusted := range c.trustedOrigins {
+		if strings.ToLower(strings.TrimSuffix(trusted, "/")) == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the settings can be used together. Letting any site
+// make requests with a visitor's session would let any site act as them.
+func (c corsConfig) validate() error {
+	if c.allowCredentials && c.trustsAny() {
+		return errors.New("-cors-allow-credentials can't be used when -cors-trusted-origins is \"*\"; list the trusted origins instead")
+	}
+	return nil
+}
+
+// trustsAny reports whether requests from every origin are allowed.
+func (c corsConfig) trustsAny() bool {
+	for _, trusted := range c.trustedOrigins {
+		if trusted == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// The request headers which API clients may send, and the response headers
+// which their scripts may read, beyond the ones browsers always allow.
+const (
+	corsAllowedHeaders = "Content-Type, If-Match, If-None-Match"
+	corsExposedHeaders = "ETag, Link, Location, Retry-After, X-Request-ID, X-Total-Count, " +
+		"X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset"
+)
+
+// enableCORS lets pages from the trusted origins call the API. Preflight
+// requests from those origins are answered here, with the methods the route
+// supports; everything else is passed on, with the headers which tell the
+// browser to let the page see the response. Requests from other origins get
+// no CORS headers, so browsers keep the response from the page.
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Origin header, so caches mustn't give
+		// one origin's response to another.
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !app.cors.trusts(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if app.cors.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			// httprouter has already set the Allow header to the methods
+			// which the route supports.
+			w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stringList is a flag which can be given several times, or once with a
+// comma-separated list, or both.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestStringList(t *testing.T) {
+	var l stringList
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&l, "origin", "")
+
+	err := fs.Parse([]string{"-origin", "https://a.example, https://b.example", "-origin", "https://c.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, l.String(), "https://a.example,https://b.example,https://c.example")
+}
+
+func TestCORS(t *testing.T) {
+	app := newTestApplication(t)
+	app.cors = corsConfig{trustedOrigins: stringList{"https://app.example.com/"}, allowCredentials: true}
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Trusted origin", func(t *testing.T) {
+		code, headers, _ := ts.do(t, http.MethodGet, "/api/v1/snippets/00000000000000000000000001", http.Header{"Origin": {"https://app.example.com"}}, "")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		assert.Equal(t, headers.Get("Access-Control-Allow-Credentials"), "true")
+		assert.StringContains(t, headers.Get("Access-Control-Expose-Headers"), "ETag")
+		assert.Equal(t, headers.Get("Vary"), "Origin")
+	})
+
+	t.Run("Untrusted origin", func(t *testing.T) {
+		code, headers, _ := ts.do(t, http.MethodGet, "/api/v1/snippets/00000000000000000000000001", http.Header{"Origin": {"https://evil.example"}}, "")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, headers.Get("Vary"), "Origin")
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Origin", "https://app.example.com")
+		headers.Set("Access-Control-Request-Method", http.MethodPost)
+		headers.Set("Access-Control-Request-Headers", "content-type")
+
+		code, headers, _ := ts.do(t, http.MethodOptions, "/api/v1/snippets", headers, "")
+
+		assert.Equal(t, code, http.StatusNoContent)
+		assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "https://app.example.com")
+		assert.StringContains(t, headers.Get("Access-Control-Allow-Methods"), http.MethodPost)
+		assert.StringContains(t, headers.Get("Access-Control-Allow-Headers"), "Content-Type")
+	})
+
+	t.Run("Preflight from untrusted origin", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Origin", "https://evil.example")
+		headers.Set("Access-Control-Request-Method", http.MethodPost)
+
+		_, headers, _ = ts.do(t, http.MethodOptions, "/api/v1/snippets", headers, "")
+
+		assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, headers.Get("Access-Control-Allow-Methods"), "")
+	})
+
+	t.Run("Outside the API", func(t *testing.T) {
+		headers := http.Header{}
+		headers.Set("Origin", "https://app.example.com")
+		headers.Set("Access-Control-Request-Method", http.MethodGet)
+
+		_, headers, _ = ts.do(t, http.MethodOptions, "/snippet/view/00000000000000000000000001", headers, "")
+
+		assert.Equal(t, headers.Get("Access-Control-Allow-Origin"), "")
+	})
+}
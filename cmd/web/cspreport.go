@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"mime"
+	"net/http"
+	"unicode/utf8"
+)
+
+// maxCSPReportBytes limits the size of a CSP report body. Real reports are a
+// few hundred bytes, even when batched.
+const maxCSPReportBytes = 64 * 1024
+
+// cspViolation is the body of a report sent to the report-uri directive with
+// the application/csp-report content type.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+}
+
+// reportingAPIReport is a single report sent to the report-to directive by
+// the Reporting API, with the application/reports+json content type. These
+// arrive in batches.
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+	} `json:"body"`
+}
+
+// cspReport accepts Content Security Policy violation reports in both the
+// report-uri and report-to formats, and stores them for review on the admin
+// pages. Browsers ignore the response, so anything which isn't a usable report
+// just gets a 400 and is otherwise ignored.
+func (app *application) cspReport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCSPReportBytes)
+	dec := json.NewDecoder(r.Body)
+
+	var reports []*models.CSPReport
+
+	// Some browsers add parameters, like a charset, to the content type.
+	// A header which doesn't parse is treated as unsupported.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "application/csp-report":
+		var body struct {
+			Report cspViolation `json:"csp-report"`
+		}
+		if err := dec.Decode(&body); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		directive := body.Report.EffectiveDirective
+		if directive == "" {
+			directive = body.Report.ViolatedDirective
+		}
+
+		reports = append(reports, &models.CSPReport{
+			DocumentURI:       body.Report.DocumentURI,
+			ViolatedDirective: directive,
+			BlockedURI:        body.Report.BlockedURI,
+			SourceFile:        body.Report.SourceFile,
+			LineNumber:        body.Report.LineNumber,
+		})
+
+	case "application/reports+json":
+		var body []reportingAPIReport
+		if err := dec.Decode(&body); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		for _, report := range body {
+			// The Reporting API also delivers other kinds of report, such as
+			// deprecation warnings, which we aren't interested in.
+			if report.Type != "csp-violation" {
+				continue
+			}
+
+			reports = append(reports, &models.CSPReport{
+				DocumentURI:       report.Body.DocumentURL,
+				ViolatedDirective: report.Body.EffectiveDirective,
+				BlockedURI:        report.Body.BlockedURL,
+				SourceFile:        report.Body.SourceFile,
+				LineNumber:        report.Body.LineNumber,
+			})
+		}
+
+	default:
+		app.clientError(w, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	for _, report := range reports {
+		report.UserAgent = r.UserAgent()
+
+		// The reports come from untrusted clients, so truncate everything to
+		// fit the database columns rather than failing the insert.
+		report.DocumentURI = truncate(report.DocumentURI, 2048)
+		report.ViolatedDirective = truncate(report.ViolatedDirective, 255)
+		report.BlockedURI = truncate(report.BlockedURI, 2048)
+		report.SourceFile = truncate(report.SourceFile, 2048)
+		report.UserAgent = truncate(report.UserAgent, 512)
+
+		app.infoLog.Printf("CSP violation: %s blocked %q on %s", report.ViolatedDirective, report.BlockedURI, report.DocumentURI)
+
+		err := app.cspReports.Insert(report)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) adminCSPReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := app.cspReports.Latest()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.CSPReports = reports
+	app.render(w, http.StatusOK, "admin_csp_reports.tmpl.html", data)
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
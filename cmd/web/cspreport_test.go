@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCSPReport(t *testing.T) {
+	reports := &mocks.CSPReportModel{}
+	app := newTestApplication(t)
+	app.cspReports = reports
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		wantCode      int
+		wantDirective string
+	}{
+		{
+			name:        "report-uri",
+			contentType: "application/csp-report",
+			body: `{"csp-report": {"document-uri": "https://example.com/", "violated-directive": "script-src-elem",
+				"blocked-uri": "inline", "line-number": 12}}`,
+			wantCode:      http.StatusNoContent,
+			wantDirective: "script-src-elem",
+		},
+		{
+			name:        "report-to",
+			contentType: "application/reports+json",
+			body: `[{"type": "deprecation", "body": {}},
+				{"type": "csp-violation", "body": {"documentURL": "https://example.com/", "effectiveDirective": "img-src", "blockedURL": "https://evil.example.com/x.png"}}]`,
+			wantCode:      http.StatusNoContent,
+			wantDirective: "img-src",
+		},
+		{
+			name:          "Content type with a charset",
+			contentType:   "application/csp-report; charset=utf-8",
+			body:          `{"csp-report": {"document-uri": "https://example.com/", "effective-directive": "style-src-attr", "blocked-uri": "inline"}}`,
+			wantCode:      http.StatusNoContent,
+			wantDirective: "style-src-attr",
+		},
+		{
+			name:        "Malformed",
+			contentType: "application/csp-report",
+			body:        `{"csp-report": `,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Wrong content type",
+			contentType: "text/plain",
+			body:        `hello`,
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports.Reports = nil
+
+			rs, err := ts.Client().Post(ts.URL+"/csp-report", tt.contentType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			if tt.wantDirective != "" {
+				assert.Equal(t, len(reports.Reports), 1)
+				assert.Equal(t, reports.Reports[0].ViolatedDirective, tt.wantDirective)
+			}
+		})
+	}
+}
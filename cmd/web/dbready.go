@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dbRetry says how to wait for the database to come up: how long to wait
+// after the first failed attempt, the most to wait between attempts, and the
+// most to wait altogether before giving up.
+type dbRetry struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	maxWait      time.Duration
+}
+
+// delay returns how long to wait after the given failed attempt, counting
+// from 1. The delay doubles with each attempt up to maxDelay, and then a
+// random amount of up to half of it is taken off, so that several instances
+// started together don't all retry in step.
+func (c dbRetry) delay(attempt int) time.Duration {
+	d := c.initialDelay
+	for i := 1; i < attempt && d < c.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, c.maxDelay)
+
+	if half := int64(d / 2); half > 0 {
+		d -= time.Duration(rand.Int63n(half))
+	}
+	return d
+}
+
+// pinger is the part of *sql.DB which waitForDB needs.
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// waitForDB pings db until it answers, waiting longer after each failure,
+// and logs each failed attempt. It gives up once the next attempt would start
+// more than c.maxWait after the first, returning the last error.
+func waitForDB(db pinger, c dbRetry, errorLog *log.Logger) error {
+	deadline := time.Now().Add(c.maxWait)
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		wait := c.delay(attempt)
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("database still not ready after %d attempts: %w", attempt, err)
+		}
+
+		errorLog.Printf("database not ready (attempt %d): %s; retrying in %s", attempt, err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+	}
+}
+
+// dbHealth tracks whether the database is reachable, for the readiness
+// probe. It's safe for concurrent use.
+type dbHealth struct {
+	db    pinger
+	retry dbRetry
+
+	mu           sync.Mutex
+	reconnecting bool
+}
+
+// check reports whether the database is answering. If it isn't, waitForDB is
+// started in the background, and the application is reported as not ready
+// without pinging again until it finishes, so that a struggling database
+// isn't hammered by every probe.
+func (h *dbHealth) check(errorLog, infoLog *log.Logger) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.reconnecting {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := h.db.PingContext(ctx)
+	if err == nil {
+		return true
+	}
+
+	errorLog.Printf("readiness check failed: %s", err)
+	h.reconnecting = true
+
+	go func() {
+		err := waitForDB(h.db, h.retry, errorLog)
+		if err != nil {
+			errorLog.Print(err)
+		} else {
+			infoLog.Print("database is back")
+		}
+
+		h.mu.Lock()
+		h.reconnecting = false
+		h.mu.Unlock()
+	}()
+
+	return false
+}
+
+// ready is the readiness probe. Unlike /ping, it fails while the database
+// is unreachable, so that load balancers and orchestrators stop sending
+// traffic until it's back.
+func (app *application) ready(w http.ResponseWriter, r *http.Request) {
+	if !app.dbHealth.check(app.errorLog, app.infoLog) {
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("OK"))
+}
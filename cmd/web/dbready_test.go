@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDBRetryDelay(t *testing.T) {
+	c := dbRetry{initialDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			d := c.delay(tt.attempt)
+			if d > tt.max || d <= tt.max/2 {
+				t.Fatalf("attempt %d: got %s; want between %s and %s", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestWaitForDB(t *testing.T) {
+	errorLog := log.New(io.Discard, "", 0)
+	retry := dbRetry{initialDelay: time.Millisecond, maxDelay: 4 * time.Millisecond, maxWait: time.Second}
+
+	t.Run("Comes up", func(t *testing.T) {
+		p := &testPinger{down: true}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			p.setDown(false)
+		}()
+
+		err := waitForDB(p, retry, errorLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.pings < 2 {
+			t.Errorf("got %d pings; want more than one", p.pings)
+		}
+	})
+
+	t.Run("Gives up", func(t *testing.T) {
+		p := &testPinger{down: true}
+
+		err := waitForDB(p, dbRetry{initialDelay: time.Millisecond, maxDelay: 4 * time.Millisecond, maxWait: 30 * time.Millisecond}, errorLog)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		assert.StringContains(t, err.Error(), "connection refused")
+	})
+}
+
+func TestReady(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/ready")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "OK")
+
+	p := app.dbHealth.db.(*testPinger)
+	p.setDown(true)
+
+	code, _, _ = ts.get(t, "/ready")
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+
+	// The database comes back, and the background reconnect notices.
+	p.setDown(false)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		code, _, _ = ts.get(t, "/ready")
+		if code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, code, http.StatusOK)
+}
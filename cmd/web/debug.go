@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/ui"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// debugTemplates checks the template cache, listing each page with what's
+// wrong with its template set, or "ok". The response is 500 Internal Server
+// Error if anything is wrong, so that it can be used from scripts. With
+// ?page=name only that page is checked. It's only routed in debug mode.
+func (app *application) debugTemplates(w http.ResponseWriter, r *http.Request) {
+	files, err := fs.Glob(ui.Files, "html/pages/*.tmpl.html")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var pages []string
+	for _, file := range files {
+		pages = append(pages, filepath.Base(file))
+	}
+	// Pages which are in the cache without a file are reported too.
+	for _, page := range app.templateCache.pages() {
+		if !slices.Contains(pages, page) {
+			pages = append(pages, page)
+		}
+	}
+	slices.Sort(pages)
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		if !slices.Contains(pages, page) {
+			app.notFound(w)
+			return
+		}
+		pages = []string{page}
+	}
+
+	var b strings.Builder
+	status := http.StatusOK
+	for _, page := range pages {
+		problems := templateProblems(app.templateCache, page)
+		if len(problems) == 0 {
+			fmt.Fprintf(&b, "%s: ok\n", page)
+			continue
+		}
+		status = http.StatusInternalServerError
+		fmt.Fprintf(&b, "%s: %s\n", page, strings.Join(problems, "; "))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(b.String()))
+}
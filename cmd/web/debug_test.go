@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebugTemplates(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Without debug mode the endpoint doesn't exist.
+	ts := newTestServer1(t, app.routes())
+	code, _, _ := ts.get(t, "/debug/templates")
+	assert.Equal(t, code, http.StatusNotFound)
+	ts.Close()
+
+	app.debug = true
+	ts = newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/debug/templates")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.StringContains(t, body, "about.tmpl.html: ok\n")
+	assert.Equal(t, strings.Contains(body, "error.tmpl.html"), false)
+
+	app.templateCache.remove("about.tmpl.html")
+	app.templateCache.set("stray.tmpl.html", template.Must(template.New("stray.tmpl.html").Parse(`{{define "main"}}{{end}}`)))
+
+	code, _, body = ts.get(t, "/debug/templates")
+	assert.Equal(t, code, http.StatusInternalServerError)
+	assert.StringContains(t, body, "about.tmpl.html: not in the template cache\n")
+	assert.StringContains(t, body, `stray.tmpl.html: doesn't define "base"`)
+
+	code, _, body = ts.get(t, "/debug/templates?page=home.tmpl.html")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "home.tmpl.html: ok\n")
+
+	code, _, _ = ts.get(t, "/debug/templates?page=nope.tmpl.html")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/diff"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+)
+
+// snippetDiff is the comparison of two snippets shown by the diff page.
+type snippetDiff struct {
+	From    *models.Snippet
+	To      *models.Snippet
+	Files   []fileDiff
+	Unified bool
+}
+
+// fileDiff compares the files at the same position in two snippets. Old or
+// New is nil if only one of the snippets has a file there.
+type fileDiff struct {
+	Old    *models.SnippetFile
+	New    *models.SnippetFile
+	Result *diff.Result
+}
+
+// diffFiles pairs up the files of two snippets by position, and compares
+// each pair.
+func diffFiles(from, to *models.Snippet) []fileDiff {
+	oldFiles, newFiles := from.AllFiles(), to.AllFiles()
+
+	var files []fileDiff
+	for i := 0; i < max(len(oldFiles), len(newFiles)); i++ {
+		var fd fileDiff
+		var oldContent, newContent string
+		if i < len(oldFiles) {
+			fd.Old, oldContent = oldFiles[i], oldFiles[i].Content
+		}
+		if i < len(newFiles) {
+			fd.New, newContent = newFiles[i], newFiles[i].Content
+		}
+		fd.Result = diff.Compare(oldContent, newContent)
+		files = append(files, fd)
+	}
+	return files
+}
+
+// snippetDiffView shows the differences between the snippets given by the
+// from and to query parameters, such as a snippet and a later copy of it.
+// Each is a public ID, or a link to the snippet, which is what people have
+// to hand.
+// The files are side by side unless view=unified is given.
+func (app *application) snippetDiffView(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var snippets [2]*models.Snippet
+	for i, param := range []string{"from", "to"} {
+		publicID := publicIDFromInput(query.Get(param))
+		if _, ok := publicid.Normalize(publicID); !ok {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		s, err := app.visibleSnippetFor(r, publicID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+		snippets[i] = s
+	}
+
+	// The content of encrypted snippets is only readable in the browser.
+	if snippets[0].ContentEncrypted || snippets[1].ContentEncrypted {
+		app.clientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Diff = &snippetDiff{
+		From:    snippets[0],
+		To:      snippets[1],
+		Files:   diffFiles(snippets[0], snippets[1]),
+		Unified: query.Get("view") == "unified",
+	}
+	app.render(w, http.StatusOK, "diff.tmpl.html", data)
+}
+
+// visibleSnippet returns the snippet with the given ID if the current user
+// can see it, and ErrNoRecord otherwise, so that hidden snippets can't be
+// told apart from missing ones.
+func (app *application) visibleSnippet(r *http.Request, id int) (*models.Snippet, error) {
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := app.snippetVisible(s, reqctx.UserID(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, models.ErrNoRecord
+	}
+	return s, nil
+}
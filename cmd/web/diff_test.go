@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSnippetDiffView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"No IDs", "/diff", http.StatusBadRequest},
+		{"Invalid ID", "/diff?from=00000000000000000000000001&to=foo", http.StatusBadRequest},
+		{"Missing snippet", "/diff?from=00000000000000000000000001&to=00000000000000000000000099", http.StatusNotFound},
+		// Only members of its organization can see snippet 6.
+		{"Hidden snippet", "/diff?from=00000000000000000000000001&to=00000000000000000000000006", http.StatusNotFound},
+		{"Scheduled snippet", "/diff?from=00000000000000000000000003&to=00000000000000000000000001", http.StatusNotFound},
+		{"Encrypted snippet", "/diff?from=00000000000000000000000001&to=00000000000000000000000005", http.StatusUnprocessableEntity},
+		{"Same snippet", "/diff?from=00000000000000000000000001&to=00000000000000000000000001", http.StatusOK},
+		{"Database ID", "/diff?from=00000000000000000000000001&to=1", http.StatusBadRequest},
+		{"Link", "/diff?from=00000000000000000000000001&to=http%3A%2F%2Fsnippets.example%2Fsnippet%2Fview%2F00000000000000000000000001", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/diff?from=00000000000000000000000001&to=00000000000000000000000006")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Compare Snippets</h2>")
+	assert.StringContains(t, body, "<td class='line deleted'><pre>An old silent pond...</pre></td>")
+	assert.StringContains(t, body, "<td class='line inserted'><pre>For the team&#39;s eyes only.</pre></td>")
+	assert.StringContains(t, body, "frog.txt (removed)")
+	assert.StringContains(t, body, "<a href='/diff?from=00000000000000000000000001&to=00000000000000000000000006&view=unified'>Unified</a>")
+
+	_, _, body = ts.get(t, "/diff?from=00000000000000000000000001&to=00000000000000000000000006&view=unified")
+	assert.StringContains(t, body, "<tr class='deleted'>")
+	assert.StringContains(t, body, "<a href='/diff?from=00000000000000000000000006&to=00000000000000000000000001&view=unified'>Swap</a>")
+
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<form class='compare' action='/diff' method='GET'>")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// downloadBatchSize is how many snippets a ZIP download reads from the
+// database at a time. Only one batch is held in memory, however many
+// snippets there are.
+const downloadBatchSize = 100
+
+// downloadWriteTimeout is how long each batch of a ZIP download has to be
+// sent in. The server's write timeout would otherwise cut off large
+// downloads part of the way through.
+const downloadWriteTimeout = 30 * time.Second
+
+// collectionDownload sends every snippet in a collection that the visitor
+// can see, as a ZIP.
+func (app *application) collectionDownload(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	c, err := app.collections.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	isOwner := reqctx.UserID(r.Context()) == c.UserID
+
+	offset := 0
+	app.writeSnippetsZip(w, r, c.Slug+".zip", func() ([]*models.Snippet, error) {
+		snippets, err := app.collections.SnippetsPage(c.ID, isOwner, downloadBatchSize, offset)
+		offset += len(snippets)
+		return snippets, err
+	})
+}
+
+// accountSnippetsDownload sends all of the user's own snippets as a ZIP.
+func (app *application) accountSnippetsDownload(w http.ResponseWriter, r *http.Request) {
+	userID := reqctx.UserID(r.Context())
+
+	afterID := 0
+	app.writeSnippetsZip(w, r, "snippets.zip", func() ([]*models.Snippet, error) {
+		snippets, err := app.snippets.ForUserAfter(userID, afterID, downloadBatchSize)
+		if len(snippets) > 0 {
+			afterID = snippets[len(snippets)-1].ID
+		}
+		return snippets, err
+	})
+}
+
+// writeSnippetsZip sends a ZIP of the snippets next returns, a batch at a
+// time until it returns none, as it goes. Each snippet is a file named after
+// its ID and title, or a folder of its files if it has more than one.
+// Encrypted snippets are left out, as their content is only ciphertext.
+//
+// An error reading the first batch gets an error page. After that it's too
+// late, so later errors are logged and the ZIP is cut short, which leaves it
+// without its central directory, so it can't be mistaken for a whole one.
+func (app *application) writeSnippetsZip(w http.ResponseWriter, r *http.Request, filename string, next func() ([]*models.Snippet, error)) {
+	snippets, err := next()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Cache-Control", "no-store")
+
+	rc := http.NewResponseController(w)
+	zw := zip.NewWriter(w)
+
+	for len(snippets) > 0 {
+		if err = rc.SetWriteDeadline(time.Now().Add(downloadWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			break
+		}
+		if err = app.writeZipBatch(zw, snippets); err != nil {
+			break
+		}
+		if snippets, err = next(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil && r.Context().Err() == nil {
+		app.errorLog.Printf("writing %s: %s", filename, err)
+	}
+}
+
+// writeZipBatch adds a batch of snippets, with their additional files, to a
+// ZIP.
+func (app *application) writeZipBatch(zw *zip.Writer, snippets []*models.Snippet) error {
+	ids := make([]int, len(snippets))
+	for i, s := range snippets {
+		ids[i] = s.ID
+	}
+
+	extra, err := app.snippets.FilesFor(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		if s.ContentEncrypted {
+			continue
+		}
+
+		// The snippet is copied, as it may be shared with other requests.
+		c := *s
+		c.Files = extra[s.ID]
+		files := c.AllFiles()
+
+		name := zipBaseName(s)
+		for _, f := range files {
+			header := &zip.FileHeader{Method: zip.Deflate, Modified: s.Created}
+			if len(files) == 1 {
+				header.Name = name + "." + zipExtension(f)
+			} else {
+				header.Name = name + "/" + path.Base(f.DisplayName())
+			}
+
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if _, err = fw.Write([]byte(f.Content)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// zipBaseName names a snippet's file or folder in a ZIP, like
+// "01ARZ3NDEKTSV4RRFFQ69G5FAV-hello-world": its public ID keeps the names
+// unique, and its title, cut down to letters, numbers and dashes, says what
+// it is.
+func zipBaseName(s *models.Snippet) string {
+	var b strings.Builder
+	dash := true
+
+	for _, r := range strings.ToLower(s.Title) {
+		if r < 0x80 && (r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	if b.Len() == 0 {
+		return s.PublicID
+	}
+	return s.PublicID + "-" + b.String()
+}
+
+// zipExtension returns the extension of a single file snippet in a ZIP: the
+// one its filename has, or else the one for its language.
+func zipExtension(f *models.SnippetFile) string {
+	if ext := strings.TrimPrefix(path.Ext(f.Filename), "."); ext != "" {
+		return ext
+	}
+	return languages.Extension(f.EffectiveLanguage())
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// readZip returns the files in a ZIP by name.
+func readZip(t *testing.T, body string) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader([]byte(body)), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestAccountSnippetsDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/account/snippets/download")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, headers, body := ts.get(t, "/account/snippets/download")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/zip")
+	assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=snippets.zip")
+
+	// A snippet with more than one file gets a folder of them.
+	files := readZip(t, body)
+	assert.Equal(t, len(files), 4)
+	assert.Equal(t, files["00000000000000000000000001-an-old-silent-pond/file1.txt"], "An old silent pond...")
+	assert.Equal(t, files["00000000000000000000000001-an-old-silent-pond/frog.txt"], "A frog jumps into the pond,")
+	_, ok := files["00000000000000000000000004-a-secret.txt"]
+	assert.Equal(t, ok, true)
+	_, ok = files["00000000000000000000000006-an-internal-snippet.txt"]
+	assert.Equal(t, ok, true)
+}
+
+func TestAccountSnippetsDownloadEncrypted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// The encrypted snippet is left out, as its content is only ciphertext.
+	code, _, body := ts.get(t, "/account/snippets/download")
+	assert.Equal(t, code, http.StatusOK)
+
+	files := readZip(t, body)
+	assert.Equal(t, len(files), 1)
+	_, ok := files["00000000000000000000000003-a-scheduled-snippet.txt"]
+	assert.Equal(t, ok, true)
+}
+
+func TestCollectionDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/collection/missing/download")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	form := url.Values{"csrf_token": {ts.csrfToken(t, "/account/collections/create")}, "name": {"Mine"}}
+	code, _, _ = ts.postForm(t, "/account/collections/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	for _, id := range []string{"00000000000000000000000001", "00000000000000000000000004"} {
+		form := url.Values{"csrf_token": form["csrf_token"], "collection": {"1"}}
+		code, _, _ = ts.postForm(t, "/snippet/collect/"+id, form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	_, _, body := ts.get(t, "/collection/mine")
+	assert.StringContains(t, body, "<a href='/collection/mine/download'>Download as ZIP</a>")
+
+	code, headers, body := ts.get(t, "/collection/mine/download")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=mine.zip")
+	files := readZip(t, body)
+	assert.Equal(t, len(files), 3)
+	_, ok := files["00000000000000000000000004-a-secret.txt"]
+	assert.Equal(t, ok, true)
+
+	// Everyone else only gets the published snippets.
+	ts.resetClient(t)
+
+	code, _, body = ts.get(t, "/collection/mine/download")
+	assert.Equal(t, code, http.StatusOK)
+
+	files = readZip(t, body)
+	assert.Equal(t, len(files), 2)
+	for name := range files {
+		assert.Equal(t, strings.HasPrefix(name, "00000000000000000000000001-an-old-silent-pond/"), true)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxDraftBytes limits the size of an autosaved draft.
+const maxDraftBytes = 1 << 20
+
+// isBlank reports whether nothing has been entered in the form, apart from
+// the choices which have defaults.
+func (f *snippetCreateForm) isBlank() bool {
+	if strings.TrimSpace(f.Title) != "" || strings.TrimSpace(f.Content) != "" || f.Filename != "" {
+		return false
+	}
+	for _, file := range f.Files {
+		if file.Filename != "" || strings.TrimSpace(file.Content) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// snippetDraftPost saves the create form as the user's draft. The form posts
+// itself here in the background as the user types, so that their work isn't
+// lost if they navigate away or their browser crashes. A blank form deletes
+// the draft instead.
+func (app *application) snippetDraftPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	if form.isBlank() {
+		err = app.drafts.Delete(id)
+	} else {
+		// The draft is kept as the submitted form fields, so restoring it is
+		// the same as decoding a submission. The CSRF token is left out as
+		// it's no use later.
+		values := url.Values{}
+		for key, value := range r.PostForm {
+			if key != "csrf_token" {
+				values[key] = value
+			}
+		}
+
+		data := values.Encode()
+		if len(data) > maxDraftBytes {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		err = app.drafts.Save(id, data)
+	}
+	if errors.Is(err, models.ErrTooLarge) {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) snippetDraftDeletePost(w http.ResponseWriter, r *http.Request) {
+	id := reqctx.UserID(r.Context())
+
+	err := app.drafts.Delete(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your draft has been discarded.")
+	http.Redirect(w, r, urlFor("snippet.create"), http.StatusSeeOther)
+}
+
+// restoreDraft returns the user's saved draft and the create form filled in
+// from it, or nil if they don't have one.
+func (app *application) restoreDraft(userID int) (*models.Draft, *snippetCreateForm, error) {
+	draft, err := app.drafts.Get(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	values, err := url.ParseQuery(draft.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var form snippetCreateForm
+	if err = app.decodeForm(&form, values); err != nil {
+		return nil, nil, err
+	}
+
+	// Drop the spare file section which was saved along with the others, as
+	// the form always adds one of its own.
+	files := form.Files[:0]
+	for _, f := range form.Files {
+		if f.Filename != "" || strings.TrimSpace(f.Content) != "" {
+			files = append(files, f)
+		}
+	}
+	form.Files = files
+
+	return draft, &form, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSnippetDraft(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("title", "Half-written haiku")
+	form.Add("content", "An old silent pond")
+	form.Add("expires", "7")
+	form.Add("files[0].filename", "notes.txt")
+	form.Add("files[0].content", "Syllables: 5, 7, 5")
+	form.Add("files[1].filename", "")
+	form.Add("files[1].content", "")
+
+	code, _, _ := ts.postForm(t, "/snippet/draft", form)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	draft, err := app.drafts.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(draft.Data, "csrf_token"), false)
+
+	// The draft is restored on the next visit, without the spare file
+	// section.
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "We've restored the draft you were working on")
+	assert.StringContains(t, body, "value='Half-written haiku'")
+	assert.StringContains(t, body, "<textarea name='content'>An old silent pond</textarea>")
+	assert.StringContains(t, body, "value='notes.txt'")
+	assert.StringContains(t, body, "name='files[1].filename' placeholder='Filename'")
+	assert.StringContains(t, body, "value='7' checked")
+
+	// Discarding it brings back an empty form.
+	code, headers, _ := ts.postForm(t, "/snippet/draft/delete", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Your draft has been discarded.")
+	assert.Equal(t, strings.Contains(body, "Half-written haiku"), false)
+
+	// Saving a blank form deletes the draft rather than keeping an empty one.
+	code, _, _ = ts.postForm(t, "/snippet/draft", form)
+	assert.Equal(t, code, http.StatusNoContent)
+	code, _, _ = ts.postForm(t, "/snippet/draft", url.Values{"csrf_token": {csrfToken}, "title": {" "}})
+	assert.Equal(t, code, http.StatusNoContent)
+	_, err = app.drafts.Get(1)
+	assert.Equal(t, err != nil, true)
+
+	// Publishing a snippet deletes its draft.
+	code, _, _ = ts.postForm(t, "/snippet/draft", form)
+	assert.Equal(t, code, http.StatusNoContent)
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, err = app.drafts.Get(1)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestSnippetDraftTooLarge(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/snippet/create"))
+	form.Add("content", strings.Repeat("a", maxDraftBytes))
+
+	code, _, _ := ts.postForm(t, "/snippet/draft", form)
+	assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// editAutosaveInterval is how often the edit page saves the user's changes
+// in the background, if they've made any.
+const editAutosaveInterval = 5 * time.Second
+
+var (
+	// errNotOwner is returned by editableSnippet for snippets which belong
+	// to someone else.
+	errNotOwner = errors.New("snippet belongs to someone else")
+	// errNotEditable is returned by editableSnippet for encrypted snippets,
+	// whose content can't be changed without the key, and burned ones.
+	errNotEditable = errors.New("snippet can't be edited")
+)
+
+// editableSnippet returns the snippet with the ID in the URL if userID can
+// edit it. It returns models.ErrNoRecord if there's no such snippet, or none
+// the user can see.
+func (app *application) editableSnippet(r *http.Request, userID int) (*models.Snippet, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := app.snippetVisible(snippet, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, models.ErrNoRecord
+	}
+	if snippet.UserID != userID {
+		return nil, errNotOwner
+	}
+	if snippet.ContentEncrypted || snippet.IsBurned() {
+		return nil, errNotEditable
+	}
+	return snippet, nil
+}
+
+// applySnippetEdit validates changes to a snippet's title and the content of
+// its first file, leaving any problems in v, and makes them to s. A nil
+// title or content is left as it is. The content is normalized and held to
+// limits as a new snippet's is. It returns the names of the fields which
+// changed.
+func applySnippetEdit(v *validator.Validator, limits validator.ContentLimits, s *models.Snippet, title, content *string) []string {
+	var changed []string
+	if title != nil {
+		v.CheckField(validator.NotBlank(*title), "title", "This field cannot be blank")
+		v.CheckField(validator.MaxChars(*title, 100), "title", "This field cannot be more than 100 characters long")
+		if *title != s.Title {
+			s.Title = *title
+			changed = append(changed, "title")
+		}
+	}
+	if content != nil {
+		*content = normalizeContent(snippetFileForm{Language: s.Language, DetectedLanguage: s.DetectedLanguage}.effectiveLanguage(), *content)
+		v.CheckField(validator.NotBlank(*content), "content", "This field cannot be blank")
+		limits.Check(v, "content", *content)
+		if *content != s.Content {
+			s.Content = *content
+			s.DetectedLanguage = languages.Detect(s.Filename, s.Content)
+			changed = append(changed, "content")
+		}
+	}
+	return changed
+}
+
+// saveSnippetEdit saves the changes made to s, as long as it's still at the
+// given version if that isn't zero, tells webhooks which fields changed and
+// returns the snippet as saved.
+func (app *application) saveSnippetEdit(s *models.Snippet, version int, changed []string) (*models.Snippet, error) {
+	err := app.snippets.Update(s, version)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = app.snippets.Get(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := app.webhookSnippet(s)
+	data["changes"] = map[string]any{"fields": changed}
+	app.emitWebhookEvent(webhooks.SnippetUpdated, s.UserID, data)
+
+	return s, nil
+}
+
+// snippetEditInput is the body of a PATCH request to a snippet. Fields which
+// are left out aren't changed.
+type snippetEditInput struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+// apiSnippetUpdate changes the title or the content of a snippet's first
+// file, or both. Clients should send the snippet's ETag in an If-Match
+// header, as the edit page does, so that a change made since they fetched
+// the snippet isn't overwritten; the request then fails with 412
+// Precondition Failed. The response includes any warnings about files which
+// don't parse, by position, which the edit page shows as it saves.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.editableSnippet(r, reqctx.UserID(r.Context()))
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.apiNotFound(w)
+		return
+	case errors.Is(err, errNotOwner):
+		app.apiErrorResponse(w, http.StatusForbidden, "only the owner of a snippet can change it")
+		return
+	case errors.Is(err, errNotEditable):
+		app.apiErrorResponse(w, http.StatusConflict, "encrypted and burned snippets can't be edited")
+		return
+	case err != nil:
+		app.apiServerError(w, err)
+		return
+	}
+
+	if app.preconditionFailed(w, r, snippetETag(snippet)) {
+		return
+	}
+
+	var input snippetEditInput
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	var v validator.Validator
+	changed := applySnippetEdit(&v, app.contentLimits, snippet, input.Title, input.Content)
+	if !v.Valid() {
+		app.apiFailedValidation(w, v.FieldErrors)
+		return
+	}
+
+	// Without an If-Match header the last write wins, as it does on the
+	// site. With one, the snippet must still be at the version it matched.
+	version := 0
+	if r.Header.Get("If-Match") != "" {
+		version = snippet.Version
+	}
+
+	snippet, err = app.saveSnippetEdit(snippet, version, changed)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.apiPreconditionFailed(w)
+		case errors.Is(err, models.ErrTooLarge):
+			app.apiErrorResponse(w, http.StatusRequestEntityTooLarge, "the snippet is too large to save")
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", snippetETag(snippet))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet, "warnings": snippetLintWarnings(snippet)}, headers)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// snippetEditForm is the edit page's form. Version is the version of the
+// snippet the form was filled in from, so that a change made since then
+// isn't overwritten.
+type snippetEditForm struct {
+	Title               string   `form:"title"`
+	Content             string   `form:"content"`
+	Version             int      `form:"version"`
+	ETag                string   `form:"-"`
+	AutosaveSeconds     int      `form:"-"`
+	Warnings            []string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// snippetEdit shows the edit page, where the owner of a snippet can change
+// its title and the content of its first file. With JavaScript, main.js
+// saves the changes through the API every editAutosaveInterval, and says so
+// if someone else has changed the snippet in the meantime.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetToEdit(w, r)
+	if snippet == nil {
+		return
+	}
+
+	app.renderSnippetEdit(w, r, http.StatusOK, snippet, snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+		Version: snippet.Version,
+	})
+}
+
+// snippetEditPost saves the edit page's form, for browsers without
+// JavaScript.
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.snippetToEdit(w, r)
+	if snippet == nil {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	changed := applySnippetEdit(&form.Validator, app.contentLimits, snippet, &form.Title, &form.Content)
+	if !form.Valid() {
+		app.renderSnippetEdit(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	_, err = app.saveSnippetEdit(snippet, form.Version, changed)
+	if errors.Is(err, models.ErrEditConflict) {
+		form.AddNonFieldError("Someone else has changed this snippet since you started editing it. Copy your changes, then reload the page to see theirs.")
+		app.renderSnippetEdit(w, r, http.StatusConflict, snippet, form)
+		return
+	} else if errors.Is(err, models.ErrTooLarge) {
+		form.AddFieldError("content", "This snippet is too large to save")
+		app.renderSnippetEdit(w, r, http.StatusRequestEntityTooLarge, snippet, form)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your changes have been saved.")
+
+	http.Redirect(w, r, urlFor("snippet.view", snippet.PublicID), http.StatusSeeOther)
+}
+
+// snippetToEdit returns the snippet with the ID in the URL, or sends the
+// right error page and returns nil if the user can't edit it.
+func (app *application) snippetToEdit(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	snippet, err := app.editableSnippet(r, reqctx.UserID(r.Context()))
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+	case errors.Is(err, errNotOwner), errors.Is(err, errNotEditable):
+		app.clientError(w, http.StatusForbidden)
+	case err != nil:
+		app.serverError(w, err)
+	default:
+		return snippet
+	}
+	return nil
+}
+
+// renderSnippetEdit shows the edit page for the snippet, with the form
+// filled in.
+func (app *application) renderSnippetEdit(w http.ResponseWriter, r *http.Request, status int, snippet *models.Snippet, form snippetEditForm) {
+	form.ETag = snippetETag(snippet)
+	form.AutosaveSeconds = int(editAutosaveInterval / time.Second)
+	form.Warnings = lintMessages(snippet.AllFiles()[0].EffectiveLanguage(), form.Content)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = form
+	app.render(w, status, "edit.tmpl.html", data)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAPISnippetUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	const urlPath = "/api/v1/snippets/00000000000000000000000001"
+
+	code, _, _ := ts.do(t, http.MethodPatch, urlPath, nil, `{"title": "A new title"}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.do(t, http.MethodPatch, urlPath, nil, `{"title": "A new title"}`)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, _, _ = ts.do(t, http.MethodPatch, "/api/v1/snippets/00000000000000000000000005", nil, `{"title": "A new title"}`)
+	assert.Equal(t, code, http.StatusConflict)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, headers, _ := ts.get(t, urlPath)
+	etag := headers.Get("ETag")
+
+	tests := []struct {
+		name     string
+		ifMatch  string
+		body     string
+		wantCode int
+	}{
+		{"Title only", etag, `{"title": "A new title"}`, http.StatusOK},
+		{"Content only", etag, `{"content": "A new content"}`, http.StatusOK},
+		{"Without If-Match", "", `{"title": "A new title"}`, http.StatusOK},
+		{"Stale If-Match", `"1.0"`, `{"title": "A new title"}`, http.StatusPreconditionFailed},
+		{"Blank title", etag, `{"title": ""}`, http.StatusUnprocessableEntity},
+		{"Blank content", etag, `{"content": " "}`, http.StatusUnprocessableEntity},
+		{"Unknown field", etag, `{"language": "go"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.ifMatch != "" {
+				headers.Set("If-Match", tt.ifMatch)
+			}
+
+			code, headers, _ := ts.do(t, http.MethodPatch, urlPath, headers, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			if code == http.StatusOK {
+				assert.Equal(t, headers.Get("ETag") != "", true)
+			}
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Only the owner can edit a snippet.
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ := ts.get(t, "/snippet/edit/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/snippet/edit/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='text' name='title' value='An old silent pond'>")
+	assert.StringContains(t, body, "data-url='/api/v1/snippets/00000000000000000000000001'")
+	assert.StringContains(t, body, "<input type='hidden' name='version' value='1'>")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	// The form saves the snippet for browsers without JavaScript...
+	form := url.Values{"csrf_token": {csrfToken}, "title": {"A new title"}, "content": {"A new content"}, "version": {"1"}}
+	code, headers, _ := ts.postForm(t, "/snippet/edit/00000000000000000000000001", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/00000000000000000000000001")
+
+	// ...as long as it's valid, and nobody else changed it after the page
+	// was loaded.
+	form.Set("title", "")
+	code, _, body = ts.postForm(t, "/snippet/edit/00000000000000000000000001", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	form.Set("title", "A new title")
+	form.Set("version", "3")
+	code, _, body = ts.postForm(t, "/snippet/edit/00000000000000000000000001", form)
+	assert.Equal(t, code, http.StatusConflict)
+	assert.StringContains(t, body, "Someone else has changed this snippet since you started editing it.")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// emailBatch is how many queued emails each run of the sending task
+	// sends at most.
+	emailBatch = 50
+
+	// maxDeadLetters is how many dead letters the admin page lists.
+	maxDeadLetters = 100
+)
+
+// sendEmail renders the named email from the templates in internal/mailer
+// and queues it for the given address. It only fails if the email can't be
+// rendered or queued; problems sending it are dealt with by
+// sendQueuedEmails.
+func (app *application) sendEmail(to, name string, data any) error {
+	msg, err := app.emails.Render(to, name, data)
+	if err != nil {
+		return err
+	}
+	return (&queuedMailer{queue: app.emailQueue}).Send(msg)
+}
+
+// queuedMailer is a mailer.Sender which queues messages rather than sending
+// them, for anything outside the application, like the incident notifiers,
+// which wants a Sender.
+type queuedMailer struct {
+	queue models.EmailQueueModelInterface
+}
+
+func (q *queuedMailer) Send(msg mailer.Message) error {
+	_, err := q.queue.Enqueue(&models.QueuedEmail{To: msg.To, Subject: msg.Subject, Body: msg.Body, HTML: msg.HTML, Unsubscribe: msg.Unsubscribe})
+	return err
+}
+
+// sendQueuedEmails sends the queued emails which are due through the mail
+// provider. Those which fail are retried with exponential backoff until
+// they've failed too often, or straight away become dead letters if the
+// provider rejected them.
+func (app *application) sendQueuedEmails() error {
+	due, err := app.emailQueue.Due(emailBatch)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, e := range due {
+		sendErr := app.mailer.Send(mailer.Message{To: e.To, Subject: e.Subject, Body: e.Body, HTML: e.HTML, Unsubscribe: e.Unsubscribe})
+
+		if sendErr == nil {
+			err = app.emailQueue.Sent(e.ID)
+		} else {
+			failed++
+			var retryAt time.Time
+			if delay, ok := mailer.RetryDelay(e.Attempts); ok && !errors.Is(sendErr, mailer.ErrRejected) {
+				retryAt = time.Now().Add(delay)
+			}
+			if retryAt.IsZero() {
+				app.errorLog.Printf("giving up on email %d to %s after %d attempts: %v", e.ID, e.To, e.Attempts, sendErr)
+			}
+			err = app.emailQueue.Failed(e.ID, sendErr.Error(), retryAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		app.infoLog.Printf("%d of %d emails failed to send", failed, len(due))
+	}
+	return nil
+}
+
+// emailLayout returns the data the email layout needs.
+func (app *application) emailLayout() mailer.Layout {
+	return mailer.Layout{SiteURL: app.absoluteURL("")}
+}
+
+// emailPreviewCSP replaces the site's Content-Security-Policy for email
+// previews. Emails are styled with style attributes, which the usual policy
+// blocks, and never have scripts.
+const emailPreviewCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:; form-action 'none'; frame-ancestors 'self'"
+
+// adminEmailPreviews lists the emails which can be previewed. Like the
+// previews themselves, it's only routed in debug mode.
+func (app *application) adminEmailPreviews(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.EmailNames = app.emails.Names()
+	app.render(w, http.StatusOK, "admin_emails.tmpl.html", data)
+}
+
+// adminEmailPreview shows an email as it would be sent, filled in with
+// made-up data. The HTML version is shown unless ?format=text is given.
+func (app *application) adminEmailPreview(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	msg, err := app.emails.Preview(name, app.absoluteURL(""))
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("X-Email-Subject", msg.Subject)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(msg.Body))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", emailPreviewCSP)
+	w.Write([]byte(msg.HTML))
+}
+
+// adminEmails lists the dead letters: emails which were given up on, so an
+// admin can fix whatever was wrong and retry them.
+func (app *application) adminEmails(w http.ResponseWriter, r *http.Request) {
+	dead, err := app.emailQueue.DeadLetters(maxDeadLetters)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.EmailDeadLetters = dead
+	data.EmailPreviews = app.debug
+	app.render(w, http.StatusOK, "admin_email_queue.tmpl.html", data)
+}
+
+func (app *application) adminEmailRetryPost(w http.ResponseWriter, r *http.Request) {
+	app.deadLetterAction(w, r, app.emailQueue.Retry, "The email has been queued again.")
+}
+
+func (app *application) adminEmailDeletePost(w http.ResponseWriter, r *http.Request) {
+	app.deadLetterAction(w, r, app.emailQueue.DeleteDeadLetter, "The email has been deleted.")
+}
+
+// deadLetterAction applies action to the dead letter named in the URL and
+// goes back to the list.
+func (app *application) deadLetterAction(w http.ResponseWriter, r *http.Request, action func(id int) error, flash string) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = action(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, urlFor("admin.emails"), http.StatusSeeOther)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAdminEmailPreview(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Previews only exist in debug mode.
+	ts := newTestServer1(t, app.routes())
+	ts.login(t, "admin@example.com", "pa$$word")
+	code, _, _ := ts.get(t, "/admin/emails/preview/welcome")
+	assert.Equal(t, code, http.StatusNotFound)
+	ts.Close()
+
+	app.debug = true
+	ts = newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ = ts.get(t, "/admin/emails/preview")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/emails/preview")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/admin/emails/preview/login_link'>HTML</a>")
+
+	code, headers, body := ts.get(t, "/admin/emails/preview/login_link")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-Email-Subject"), "Your Snippetbox sign-in link")
+	assert.Equal(t, headers.Get("Content-Security-Policy"), emailPreviewCSP)
+	assert.StringContains(t, body, app.siteURL+"/user/login/magic/link?sig=PREVIEW")
+	assert.StringContains(t, body, `class="button"`)
+
+	code, headers, body = ts.get(t, "/admin/emails/preview/login_link?format=text")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.StringContains(t, body, "Hi Alice,")
+
+	code, _, _ = ts.get(t, "/admin/emails/preview/nope")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestWelcomeEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/signup"))
+
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The email is queued rather than sent during the request.
+	mail := app.mailer.(*testMailer)
+	assert.Equal(t, len(mail.sent), 0)
+	sendEmails(t, app)
+
+	assert.Equal(t, len(mail.sent), 1)
+	assert.Equal(t, mail.sent[0].To, "bob@example.com")
+	assert.Equal(t, mail.sent[0].Subject, "Welcome to Snippetbox")
+	assert.StringContains(t, mail.sent[0].Body, app.siteURL+"/user/login")
+	assert.StringContains(t, mail.sent[0].HTML, "Hi Bob,")
+}
+
+// failingMailer fails to send every message with err.
+type failingMailer struct {
+	err error
+}
+
+func (m *failingMailer) Send(msg mailer.Message) error {
+	return m.err
+}
+
+func TestSendQueuedEmails(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	queue := &queuedMailer{queue: app.emailQueue}
+	err := queue.Send(mailer.Message{To: "bob@example.com", Subject: "Hello", Body: "Hi Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A temporary failure is retried later, so the email isn't due again
+	// yet, nor given up on.
+	app.mailer = &failingMailer{err: errors.New("connection refused")}
+	sendEmails(t, app)
+
+	due, err := app.emailQueue.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 0)
+	dead, err := app.emailQueue.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(dead), 0)
+
+	// A rejection is given up on straight away.
+	err = queue.Send(mailer.Message{To: "nobody@example.com", Subject: "Hello", Body: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.mailer = &failingMailer{err: fmt.Errorf("%w: 550 no such user", mailer.ErrRejected)}
+	sendEmails(t, app)
+
+	dead, err = app.emailQueue.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(dead), 1)
+	assert.Equal(t, dead[0].To, "nobody@example.com")
+
+	// Admins see the dead letters, and can retry them.
+	ts.login(t, "admin@example.com", "pa$$word")
+	_, _, body := ts.get(t, "/admin/emails")
+	assert.StringContains(t, body, "nobody@example.com")
+	assert.StringContains(t, body, "550 no such user")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/emails"))
+	code, headers, _ := ts.postForm(t, fmt.Sprintf("/admin/emails/retry/%d", dead[0].ID), form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "The email has been queued again.")
+	assert.StringContains(t, body, "Every email has been sent.")
+
+	mail := &testMailer{}
+	app.mailer = mail
+	sendEmails(t, app)
+	assert.Equal(t, len(mail.sent), 1)
+	assert.Equal(t, mail.sent[0].To, "nobody@example.com")
+
+	code, _, _ = ts.postForm(t, fmt.Sprintf("/admin/emails/retry/%d", dead[0].ID), form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
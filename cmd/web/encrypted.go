@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"regexp"
+)
+
+// maxEncryptedContent is the most ciphertext an encrypted snippet can hold,
+// in bytes. We can't look inside encrypted snippets, so the limit is
+// tighter than it would otherwise need to be.
+const maxEncryptedContent = 256 * 1024
+
+// encryptedContentRX matches the ciphertext main.js produces: a version, the
+// AES-GCM nonce and the encrypted content with its tag, each base64url
+// encoded without padding. The key stays in the URL fragment, which browsers
+// never send to the server.
+var encryptedContentRX = regexp.MustCompile(`^v1:[A-Za-z0-9_-]{16}:[A-Za-z0-9_-]{22,}$`)
+
+// validateEncryptedSnippet checks a snippet whose content was encrypted in
+// the browser. Only the content is encrypted, so there can't be any other
+// files, and there's no point guessing the language from ciphertext.
+func validateEncryptedSnippet(v *validator.Validator, primary *snippetFileForm, files []*models.SnippetFile) {
+	v.CheckField(len(primary.Content) <= maxEncryptedContent, "content", fmt.Sprintf("This field cannot be more than %d KB once encrypted", maxEncryptedContent/1024))
+	v.CheckField(encryptedContentRX.MatchString(primary.Content), "content", "This field must be encrypted in your browser; check that JavaScript is enabled")
+	v.CheckField(len(files) == 0, "files", "Encrypted snippets can only have one file")
+
+	primary.DetectedLanguage = ""
+}
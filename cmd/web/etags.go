@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"strings"
+)
+
+// snippetETag returns the entity tag of a snippet's API representation. It's
+// derived from the snippet's version, which goes up every time the snippet
+// changes, so it can be worked out without encoding the snippet.
+func snippetETag(s *models.Snippet) string {
+	return fmt.Sprintf(`"%s.%d"`, s.PublicID, s.Version)
+}
+
+// snippetListETag returns the entity tag of a page of the snippet list. The
+// page changes whenever a snippet on it changes or snippets are added or
+// removed, which the total and the IDs and versions of the snippets cover.
+func snippetListETag(snippets []*models.Snippet, p pagination) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d/%d/%d", p.Page, p.PerPage, p.Total)
+	for _, s := range snippets {
+		fmt.Fprintf(h, ",%d.%d", s.ID, s.Version)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// snippetCursorETag is snippetListETag for the pages of the snippet list
+// which start at a cursor, which have no total but may or may not have a
+// next page.
+func snippetCursorETag(snippets []*models.Snippet, more bool) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "cursor/%t", more)
+	for _, s := range snippets {
+		fmt.Fprintf(h, ",%d.%d", s.ID, s.Version)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether etag is in the comma-separated list of entity
+// tags in an If-Match or If-None-Match header, or the header is "*". With
+// weak comparison, which If-None-Match uses, the W/ prefix of weak tags is
+// ignored; with strong comparison, which If-Match uses, weak tags never match.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header of a response and, if the request's
+// If-None-Match header matches it, sends a 304 Not Modified response and
+// returns true. The handler should then not write anything else.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag, true) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// preconditionFailed checks the If-Match header of a request which changes
+// a resource against the resource's current entity tag. If it doesn't match,
+// meaning the client's copy is out of date, it sends a 412 Precondition
+// Failed response and returns true.
+func (app *application) preconditionFailed(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, etag, false) {
+		return false
+	}
+
+	app.apiPreconditionFailed(w)
+	return true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		weak   bool
+		want   bool
+	}{
+		{"Match", `"1.1"`, false, true},
+		{"In list", `"1.0", "1.1"`, false, true},
+		{"Any", `*`, false, true},
+		{"Mismatch", `"1.2"`, true, false},
+		{"Weak tag, weak comparison", `W/"1.1"`, true, true},
+		{"Weak tag, strong comparison", `W/"1.1"`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, etagMatches(tt.header, `"1.1"`, tt.weak), tt.want)
+		})
+	}
+}
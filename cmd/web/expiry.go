@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+)
+
+// checkExpires checks that the number of days a new snippet lasts is one the
+// expiry policy permits.
+func checkExpires(v *validator.Validator, key string, expires int, policy models.ExpiryPolicy) {
+	v.CheckField(policy.Permits(expires), key, fmt.Sprintf("This field must equal %s", policy))
+}
+
+// preferredExpires returns the expiry a user's new snippets start out with:
+// their preference, unless they haven't got one or the policy has ruled it
+// out since, in which case it's the policy's default.
+func preferredExpires(prefs *models.Preferences, policy models.ExpiryPolicy) int {
+	if prefs.Expires == 0 || !policy.Permits(prefs.Expires) {
+		return policy.DefaultDays
+	}
+	return prefs.Expires
+}
+
+// expiryLabel describes an expiry for the choices on forms, like "One Week".
+func expiryLabel(days int) string {
+	switch days {
+	case models.NeverExpires:
+		return "Never"
+	case 1:
+		return "One Day"
+	case 7:
+		return "One Week"
+	case 30:
+		return "One Month"
+	case 365:
+		return "One Year"
+	}
+	return fmt.Sprintf("%d Days", days)
+}
+
+// expiryChoice is the data for the "expiresChoices" partial: the expiry
+// policy whose choices are offered, and the one which should be selected.
+type expiryChoice struct {
+	Policy   models.ExpiryPolicy
+	Selected int
+}
+
+func newExpiryChoice(policy models.ExpiryPolicy, selected int) expiryChoice {
+	return expiryChoice{Policy: policy, Selected: selected}
+}
+
+type adminExpiryForm struct {
+	MinDays             int  `form:"min_days"`
+	MaxDays             int  `form:"max_days"`
+	AllowNever          bool `form:"allow_never"`
+	DefaultDays         int  `form:"default_days"`
+	validator.Validator `form:"-"`
+}
+
+func (form *adminExpiryForm) policy() models.ExpiryPolicy {
+	return models.ExpiryPolicy{MinDays: form.MinDays, MaxDays: form.MaxDays, AllowNever: form.AllowNever, DefaultDays: form.DefaultDays}
+}
+
+// adminExpiry shows the expiry policy, with a form to change it.
+func (app *application) adminExpiry(w http.ResponseWriter, r *http.Request) {
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := adminExpiryForm{MinDays: policy.MinDays, MaxDays: policy.MaxDays, AllowNever: policy.AllowNever, DefaultDays: policy.DefaultDays}
+	app.renderAdminExpiry(w, r, http.StatusOK, policy, form)
+}
+
+func (app *application) renderAdminExpiry(w http.ResponseWriter, r *http.Request, status int, policy models.ExpiryPolicy, form adminExpiryForm) {
+	render(app, w, status, "admin_expiry.tmpl.html", &adminExpiryPage{
+		templateBase: app.newTemplateBase(r),
+		Policy:       policy,
+		Form:         form,
+	})
+}
+
+// adminExpiryPost replaces the expiry policy. It applies to snippets created
+// from then on; those which already exist keep the expiry they were given.
+func (app *application) adminExpiryPost(w http.ResponseWriter, r *http.Request) {
+	var form adminExpiryForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	policy := form.policy()
+
+	form.CheckField(form.MinDays >= 1, "min_days", "This field must be at least 1")
+	form.CheckField(form.MaxDays >= form.MinDays, "max_days", "This field can't be less than the minimum")
+	form.CheckField(form.MaxDays <= models.MaxExpiryDays, "max_days", fmt.Sprintf("This field can't be more than %d", models.MaxExpiryDays))
+	if form.Valid() {
+		checkExpires(&form.Validator, "default_days", form.DefaultDays, policy)
+	}
+
+	if !form.Valid() {
+		current, err := app.expiryPolicy.Get()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.renderAdminExpiry(w, r, http.StatusUnprocessableEntity, current, form)
+		return
+	}
+
+	err = app.expiryPolicy.Set(policy)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Expiry policy updated.")
+	http.Redirect(w, r, urlFor("admin.expiry"), http.StatusSeeOther)
+}
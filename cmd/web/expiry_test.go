@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/expiry")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "New snippets can be kept for\nOne Year, One Week, One Day,\nand are kept for One Year unless another is picked.")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/expiry"))
+	form.Add("min_days", "0")
+	form.Add("max_days", "30")
+	form.Add("default_days", "365")
+
+	code, _, body = ts.postForm(t, "/admin/expiry", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be at least 1")
+
+	form.Set("min_days", "1")
+	code, _, body = ts.postForm(t, "/admin/expiry", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal 1, 7 or 30")
+
+	form.Set("default_days", "14")
+	form.Set("allow_never", "true")
+	code, headers, _ := ts.postForm(t, "/admin/expiry", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Expiry policy updated.")
+
+	// The create form only offers what the policy allows, starting from its
+	// default.
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='-1'> Never")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='14' checked> 14 Days")
+	assert.Equal(t, strings.Contains(body, "value='365'"), false)
+
+	// So does the API.
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "Title", "content": "Content", "expires": 365}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `"expires": "This field must equal 1, 7, 14, 30 or -1 for never"`)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/snippets", `{"title": "Title", "content": "Content", "expires": -1}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, _, _ = ts.postJSON(t, "/api/v1/snippets", `{"title": "Title", "content": "Content"}`)
+	assert.Equal(t, code, http.StatusCreated)
+
+	// A preference the policy has since ruled out is refused too.
+	form = url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/account/preferences"))
+	form.Add("expires", "365")
+	form.Add("tab_width", "8")
+
+	code, _, body = ts.postForm(t, "/account/preferences", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal 1, 7, 14, 30 or -1 for never")
+}
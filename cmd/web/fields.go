@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// fieldSet is the sparse fieldset an API client asked for with ?fields=, the
+// fields of a resource it wants in the response. A nil fieldSet means all of
+// them.
+type fieldSet []string
+
+// parseFields reads the fields parameter of r, checking that each field is
+// one of known. The id is always included, so that what comes back can be
+// told apart. The returned error is written for the client.
+func parseFields(r *http.Request, known []string) (fieldSet, error) {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return nil, nil
+	}
+
+	fs := fieldSet{"id"}
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("fields: unknown field %q (the fields are %s)", name, strings.Join(known, ", "))
+		}
+		if !slices.Contains(fs, name) {
+			fs = append(fs, name)
+		}
+	}
+	return fs, nil
+}
+
+// project returns v as JSON with only the fields in fs, in the order they'd
+// usually come in. Fields which v leaves out, like empty ones marked
+// omitempty, stay out. If fs is nil, v is returned as it is.
+func (fs fieldSet) project(v any) (any, error) {
+	if fs == nil {
+		return v, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	err = json.Unmarshal(js, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range jsonFields(v) {
+		value, ok := values[name]
+		if !ok || !slices.Contains(fs, name) {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// projectAll projects each of a list of values.
+func projectAll[T any](fs fieldSet, list []T) (any, error) {
+	if fs == nil {
+		return list, nil
+	}
+
+	projected := make([]any, len(list))
+	for i, v := range list {
+		var err error
+		projected[i], err = fs.project(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return projected, nil
+}
+
+// jsonFields returns the names which the fields of v, a struct or a pointer to
+// one, have in its JSON encoding, in order.
+func jsonFields(v any) []string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"strconv"
+)
+
+// snippetsPerPage is the number of snippets shown on each page of the
+// snippet lists.
+const snippetsPerPage = 10
+
+// latestFromAuthors fetches the page of snippets from the given authors
+// asked for by r.
+func (app *application) latestFromAuthors(r *http.Request, userIDs []int) ([]*models.Snippet, pagination, error) {
+	p := newPagination(r, snippetsPerPage)
+
+	snippets, total, err := app.snippets.LatestFromAuthors(userIDs, p.PerPage, p.Offset())
+	if err != nil {
+		return nil, pagination{}, err
+	}
+	p.Total = total
+
+	return snippets, p, nil
+}
+
+// profileUser returns the user named by the :id parameter, sending a 404
+// response and returning false if there's no such user.
+func (app *application) profileUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	snippets, p, err := app.latestFromAuthors(r, []int{user.ID})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Profile = user
+	data.Snippets = snippets
+	data.Pagination = p
+
+	if app.isAuthenticated(r) {
+		viewerID := reqctx.UserID(r.Context())
+
+		data.IsOwnProfile = viewerID == user.ID
+		data.IsFollowing, err = app.follows.IsFollowing(viewerID, user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, http.StatusOK, "profile.tmpl.html", data)
+}
+
+func (app *application) userFollowPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+	if id == user.ID {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.follows.Follow(id, user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You are now following %s.", user.Name))
+	http.Redirect(w, r, urlFor("user.profile", user.ID), http.StatusSeeOther)
+}
+
+func (app *application) userUnfollowPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.profileUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	err := app.follows.Unfollow(id, user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You are no longer following %s.", user.Name))
+	http.Redirect(w, r, urlFor("user.profile", user.ID), http.StatusSeeOther)
+}
+
+// feedSnippets returns a page of the latest snippets from the users followed
+// by the current user.
+func (app *application) feedSnippets(r *http.Request) ([]*models.Snippet, pagination, error) {
+	id := reqctx.UserID(r.Context())
+
+	following, err := app.follows.Following(id)
+	if err != nil {
+		return nil, pagination{}, err
+	}
+
+	return app.latestFromAuthors(r, following)
+}
+
+func (app *application) feed(w http.ResponseWriter, r *http.Request) {
+	snippets, p, err := app.feedSnippets(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = p
+
+	app.render(w, http.StatusOK, "feed.tmpl.html", data)
+}
+
+func (app *application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	snippets, _, err := app.feedSnippets(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeAtom(w, r, "Snippetbox: your feed", urlFor("feed"), snippets)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/profile/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Admin</h2>")
+	assert.StringContains(t, body, "An old silent pond")
+	assert.Equal(t, strings.Contains(body, "Follow</button>"), false)
+
+	code, _, _ = ts.get(t, "/user/profile/99")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/user/profile/2")
+	assert.StringContains(t, body, "<button>Unfollow</button>")
+
+	// There is no follow button on your own profile.
+	_, _, body = ts.get(t, "/user/profile/1")
+	assert.Equal(t, strings.Contains(body, "Follow</button>"), false)
+}
+
+func TestUserFollowPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/user/profile/2"))
+
+	code, headers, _ := ts.postForm(t, "/user/unfollow/2", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/profile/2")
+
+	_, _, body := ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "You are no longer following Admin.")
+	assert.StringContains(t, body, "<button>Follow</button>")
+
+	// With nobody followed, the feed is empty.
+	_, _, body = ts.get(t, "/feed")
+	assert.StringContains(t, body, "Nothing here yet.")
+
+	code, headers, _ = ts.postForm(t, "/user/follow/2", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "You are now following Admin.")
+
+	// Users can't follow themselves.
+	code, _, _ = ts.postForm(t, "/user/follow/1", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestFeed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/feed")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/feed")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/00000000000000000000000001'>An old silent pond</a>")
+
+	code, headers, body = ts.get(t, "/feed.atom")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/atom+xml; charset=utf-8")
+	assert.StringContains(t, body, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.StringContains(t, body, "<title>An old silent pond</title>")
+}
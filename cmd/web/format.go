@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/format"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"net/http"
+	"strings"
+)
+
+// apiFormat formats content written in the given language, for the Format
+// buttons on the create and edit pages. Nothing is stored. If the language
+// is left out it's detected from the content, and the response says which
+// it was.
+func (app *application) apiFormat(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Content  string `json:"content"`
+		Language string `json:"language"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	if strings.TrimSpace(input.Content) == "" {
+		app.apiFailedValidation(w, map[string]string{"content": "This field cannot be blank"})
+		return
+	}
+
+	language := input.Language
+	if language == "" {
+		language = languages.FromContent(input.Content)
+	} else if _, known := languages.Lookup(language); !known {
+		app.apiFailedValidation(w, map[string]string{"language": "This field must be one of the supported languages"})
+		return
+	}
+
+	formatted, err := format.Format(language, input.Content)
+	if err != nil {
+		var formatErr *format.Error
+		switch {
+		case errors.Is(err, format.ErrUnsupported) && language == "":
+			app.apiFailedValidation(w, map[string]string{"language": "The language couldn't be detected, so this field must be given"})
+		case errors.Is(err, format.ErrUnsupported):
+			app.apiFailedValidation(w, map[string]string{"language": fmt.Sprintf("%s can't be formatted", languages.Label(language))})
+		case errors.As(err, &formatErr):
+			app.apiFailedValidation(w, map[string]string{"content": fmt.Sprintf("This %s couldn't be formatted: %s", languages.Label(language), formatErr)})
+		default:
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"content": formatted, "language": language}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
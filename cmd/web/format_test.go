@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+)
+
+func TestAPIFormat(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Go",
+			body:     `{"content": "x:=1\nfmt.Println( x )", "language": "go"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"content": "x := 1\nfmt.Println(x)"`,
+		},
+		{
+			name:     "Detected",
+			body:     `{"content": "{\"a\":[1]}"}`,
+			wantCode: http.StatusOK,
+			wantBody: `"language": "json"`,
+		},
+		{
+			name:     "Invalid content",
+			body:     `{"content": "{\"a\": }", "language": "json"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"content": "This JSON couldn't be formatted: line 1: invalid character '}' looking for beginning of value"`,
+		},
+		{
+			name:     "Unsupported language",
+			body:     `{"content": "puts 1", "language": "ruby"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"language": "Ruby can't be formatted"`,
+		},
+		{
+			name:     "Unknown language",
+			body:     `{"content": "1", "language": "cobol"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"language": "This field must be one of the supported languages"`,
+		},
+		{
+			name:     "Blank",
+			body:     `{"content": " ", "language": "go"}`,
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: `"content": "This field cannot be blank"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/api/v1/format", tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	// The create page has a Format button beside the content.
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<button type='button' class='format-button' data-url='/api/v1/format' hidden>Format</button>`)
+}
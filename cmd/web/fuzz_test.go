@@ -0,0 +1,283 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hostilePayloads are the seeds for the fuzz tests, and what the escaping
+// tests put in every field: markup, attribute and URL injections, and the
+// things the parsers trip over.
+var hostilePayloads = []string{
+	`<script>alert(1)</script>`,
+	`"'><img src=x onerror=alert(1)>`,
+	`'><svg onload=alert(1)>`,
+	`</textarea><script>alert(1)</script>`,
+	`</code></pre><iframe src=javascript:alert(1)>`,
+	`javascript:alert(1)`,
+	` onmouseover=alert(1) x=`,
+	`{{.CSRFToken}}`,
+	`' OR 1=1 --`,
+	"line\r\nSet-Cookie: x=1",
+	"\x00\xff\xfe",
+	`-1`,
+	`99999999999999999999`,
+	`../../etc/passwd`,
+	``,
+}
+
+// markupProblems returns the signs of a payload having escaped into markup:
+// inline scripts, frames, event handler attributes and javascript: URLs.
+// The only script the templates use is main.js, loaded from /static, so
+// anything else came from the data. It's not a full HTML parser, but it
+// keeps to quoted attribute values, which is where payloads usually hide.
+func markupProblems(body string) []string {
+	var problems []string
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '<' || i+1 >= len(body) || !isASCIILetter(body[i+1]) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(body) && (isASCIILetter(body[j]) || body[j] >= '0' && body[j] <= '9') {
+			j++
+		}
+		tag := strings.ToLower(body[i+1 : j])
+
+		attrs := map[string]string{}
+		for j < len(body) && body[j] != '>' {
+			if isHTMLSpace(body[j]) || body[j] == '/' {
+				j++
+				continue
+			}
+
+			k := j
+			for k < len(body) && !isHTMLSpace(body[k]) && strings.IndexByte("=>/", body[k]) < 0 {
+				k++
+			}
+			attr := strings.ToLower(body[j:k])
+
+			var value string
+			if k < len(body) && body[k] == '=' {
+				k++
+				if k < len(body) && (body[k] == '"' || body[k] == '\'') {
+					end := strings.IndexByte(body[k+1:], body[k])
+					if end < 0 {
+						end = len(body) - k - 1
+					}
+					value = body[k+1 : k+1+end]
+					k += end + 2
+				} else {
+					start := k
+					for k < len(body) && !isHTMLSpace(body[k]) && body[k] != '>' {
+						k++
+					}
+					value = body[start:k]
+				}
+			}
+			if k == j {
+				k++
+			}
+			j = k
+
+			if attr != "" {
+				attrs[attr] = value
+			}
+		}
+		i = j
+
+		switch tag {
+		case "script":
+			if !strings.HasPrefix(attrs["src"], "/static/") {
+				problems = append(problems, "<script> element")
+			}
+		case "iframe", "object", "embed":
+			problems = append(problems, "<"+tag+"> element")
+		}
+		for attr, value := range attrs {
+			switch {
+			case strings.HasPrefix(attr, "on"):
+				problems = append(problems, "<"+tag+"> "+attr+" attribute")
+			case (attr == "href" || attr == "src" || attr == "action") && strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "javascript:"):
+				problems = append(problems, "<"+tag+"> "+attr+" javascript: URL")
+			}
+		}
+	}
+
+	return problems
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func assertNoMarkupProblems(t testing.TB, body string) {
+	t.Helper()
+
+	if problems := markupProblems(body); len(problems) > 0 {
+		t.Fatalf("markup escaped into the page: %s", strings.Join(problems, ", "))
+	}
+}
+
+// FuzzSnippetPreview sends arbitrary form bodies through the create form's
+// decoding and validation, and renders what comes out with the snippet
+// template, as the Preview tab does. Whatever is sent, the form is either
+// refused or rendered, with nothing in it that could run.
+func FuzzSnippetPreview(f *testing.F) {
+	for _, p := range hostilePayloads {
+		f.Add(url.Values{"title": {p}, "content": {p}, "filename": {p}, "language": {p}}.Encode())
+		f.Add(url.Values{"title": {"Title"}, "content": {p}, "files[0].filename": {p}, "files[0].content": {p}, "publish_at": {p}}.Encode())
+	}
+	f.Add("files[99999].content=x")
+	f.Add("expires=-1&title=%zz")
+	f.Add("title=a&title=b&content=%3Cb%3E")
+
+	app := newTestApplication(f)
+	handler := app.sessionManager.LoadAndSave(http.HandlerFunc(app.snippetPreviewPost))
+
+	f.Fuzz(func(t *testing.T, body string) {
+		req := httptest.NewRequest(http.MethodPost, "/snippet/preview", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		switch rr.Code {
+		case http.StatusOK:
+			assertNoMarkupProblems(t, rr.Body.String())
+		case http.StatusBadRequest:
+		default:
+			t.Fatalf("got status %d for %q", rr.Code, body)
+		}
+	})
+}
+
+// FuzzRouteParams requests the pages which parse an ID or a slug out of the
+// path with arbitrary ones. They're either found or not, and never break
+// the server.
+func FuzzRouteParams(f *testing.F) {
+	for _, p := range hostilePayloads {
+		f.Add(p)
+	}
+	f.Add("1")
+	f.Add("0x1")
+	f.Add("+1")
+	f.Add("1.0")
+	f.Add("go-snippets")
+
+	app := newTestApplication(f)
+	handler := app.routes()
+
+	f.Fuzz(func(t *testing.T, param string) {
+		param = url.PathEscape(param)
+
+		for _, path := range []string{
+			"/snippet/view/" + param,
+			"/snippet/raw/" + param + "/" + param,
+			"/snippet/raw/1/" + param,
+			"/snippet/download/" + param + "/0",
+			"/user/profile/" + param,
+			"/language/" + param,
+			"/collection/" + param,
+			"/collection/" + param + "/download",
+			"/org/" + param,
+			"/api/v1/snippets/" + param,
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code >= 500 {
+				t.Fatalf("GET %s: got status %d", path, rr.Code)
+			}
+			if strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+				assertNoMarkupProblems(t, rr.Body.String())
+			}
+		}
+	})
+}
+
+// TestTemplateEscaping puts each hostile payload in every field the pages
+// show which a user can write, and checks they come out as text.
+func TestTemplateEscaping(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, p := range hostilePayloads {
+		if p == "" {
+			continue
+		}
+
+		base := templateBase{CurrentYear: 2024, Flash: p, Locale: newLocale("")}
+		snippet := &models.Snippet{
+			ID:       1,
+			Title:    p,
+			Content:  p + "\n" + p,
+			Filename: p,
+			Language: "plaintext",
+			Created:  time.Now(),
+			Expires:  time.Now().Add(time.Hour),
+			Files:    []*models.SnippetFile{{Position: 1, Filename: p, Content: p}},
+		}
+
+		pages := []struct {
+			name string
+			page string
+			data pageData
+		}{
+			{"view", "view.tmpl.html", &snippetViewPage{templateBase: base, Snippet: snippet}},
+			{"home", "home.tmpl.html", &homePage{templateBase: base, Snippets: []*models.Snippet{snippet}}},
+			{"notifications", "notifications.tmpl.html", &templateData{templateBase: base, Notifications: []*models.Notification{
+				{ID: 1, Message: p, Link: p, Created: time.Now()},
+			}}},
+		}
+
+		for _, pg := range pages {
+			t.Run(pg.name+"/"+p, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				render(app, rr, http.StatusOK, pg.page, pg.data)
+
+				assert.Equal(t, rr.Code, http.StatusOK)
+				body := rr.Body.String()
+
+				assertNoMarkupProblems(t, body)
+				if strings.ContainsAny(p, "<>") && strings.Contains(body, p) {
+					t.Errorf("%q appears unescaped", p)
+				}
+			})
+		}
+	}
+}
+
+func TestMarkupProblems(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`<p title='<script>'>&lt;script&gt;</p>`, 0},
+		{`<script src="/static/js/main.js" type="text/javascript"></script>`, 0},
+		{`<a href='#ZgotmplZ'>x</a>`, 0},
+		{`<script>alert(1)</script>`, 1},
+		{`<img src=x onerror=alert(1)>`, 1},
+		{`<a title='x' href=" JavaScript:alert(1)">x</a>`, 1},
+		{`<p title="x"><svg/onload=alert(1)>`, 1},
+		{`<iframe src=javascript:alert(1)>`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			assert.Equal(t, len(markupProblems(tt.body)), tt.want)
+		})
+	}
+}
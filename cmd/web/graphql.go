@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/graphql"
+	"github.com/ngohoang211020/snippetbox/internal/metadata"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The limits on GraphQL queries. A query is refused before it's run if its
+// selections are nested more deeply than graphqlMaxDepth, or if fetching
+// everything it asks for could take more than graphqlMaxComplexity fields'
+// worth of work; see graphql.Schema.
+const (
+	graphqlMaxDepth      = 6
+	graphqlMaxComplexity = 1000
+)
+
+// graphqlMaxFirst is the most items a list field in the GraphQL schema
+// returns at once, like per_page in the JSON API.
+const graphqlMaxFirst = 100
+
+// graphqlRequest runs a GraphQL query or mutation, sent as a JSON object
+// with query, operationName and variables keys. It lives in the api chain,
+// so clients authenticate with a session or an API token, as with the JSON
+// API. Bodies must be application/json, which a form on another site can't
+// send, so mutations need no CSRF token. Requests which can't be run at all
+// get a 400 response; errors from individual fields are reported alongside
+// the rest of the data, with a 200.
+func (app *application) graphqlRequest(w http.ResponseWriter, r *http.Request) {
+	if !isJSONRequest(r) {
+		app.apiErrorResponse(w, http.StatusUnsupportedMediaType, "the request body must be application/json")
+		return
+	}
+
+	var req graphql.Request
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	resp := app.graphqlSchema(r).Execute(r.Context(), req)
+
+	status := http.StatusOK
+	body := envelope{}
+	if resp.Data != nil {
+		body["data"] = resp.Data
+	} else {
+		status = http.StatusBadRequest
+	}
+	if len(resp.Errors) > 0 {
+		body["errors"] = resp.Errors
+	}
+
+	err = app.writeJSON(w, status, body, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// dateTime is the GraphQL type of times, which are written in RFC 3339
+// format.
+var dateTime = &graphql.Scalar{
+	Name: "DateTime",
+	Coerce: func(v any) (any, error) {
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return nil, fmt.Errorf("expected a time like 2006-01-02T15:04:05Z, found %s", t)
+			}
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("expected a DateTime, found %v", v)
+	},
+	Serialize: func(v any) any {
+		return v.(time.Time).UTC().Format(time.RFC3339)
+	},
+}
+
+// field returns a GraphQL field whose value is worked out from that of its
+// object, which is a T.
+func field[T any](t graphql.Type, get func(T) any) *graphql.Field {
+	return &graphql.Field{Type: t, Resolve: func(p graphql.Params) (any, error) {
+		return get(p.Source.(T)), nil
+	}}
+}
+
+// graphqlFirst returns the first argument of a list field, checking it's in
+// range.
+func graphqlFirst(p graphql.Params) (int, error) {
+	first := p.Args["first"].(int)
+	if first < 1 || first > graphqlMaxFirst {
+		return 0, graphql.Errorf("first must be between 1 and %d", graphqlMaxFirst)
+	}
+	return first, nil
+}
+
+// graphqlSchema returns the GraphQL schema for a request. It's put together
+// for each request, so that its loaders batch and cache only that request's
+// loads: the authors of the snippets in a list are fetched with one query,
+// as are their files.
+func (app *application) graphqlSchema(r *http.Request) *graphql.Schema {
+	users := graphql.NewLoader(app.users.GetMany)
+	files := graphql.NewLoader(app.snippets.FilesFor)
+
+	// While the instance protects its source, snippets can only be read on
+	// their pages, so their content is null here.
+	content := func(s string) any {
+		if app.protectsSource() {
+			return nil
+		}
+		return s
+	}
+
+	metadataType := &graphql.Object{Name: "Metadata", Fields: graphql.Fields{
+		"lines":       field(graphql.NonNull{Of: graphql.Int}, func(m *metadata.Metadata) any { return m.Lines }),
+		"bytes":       field(graphql.NonNull{Of: graphql.Int}, func(m *metadata.Metadata) any { return m.Bytes }),
+		"readSeconds": field(graphql.NonNull{Of: graphql.Int}, func(m *metadata.Metadata) any { return m.ReadSeconds }),
+		"languageConfidence": field(graphql.Float, func(m *metadata.Metadata) any {
+			if m.LanguageConfidence == 0 {
+				return nil
+			}
+			return m.LanguageConfidence
+		}),
+	}}
+
+	fileType := &graphql.Object{Name: "File", Fields: graphql.Fields{
+		"position": field(graphql.NonNull{Of: graphql.Int}, func(f *models.SnippetFile) any { return f.Position }),
+		"filename": field(graphql.NonNull{Of: graphql.String}, func(f *models.SnippetFile) any { return f.Filename }),
+		// The language is the one the owner chose, or the detected one if
+		// they didn't.
+		"language": field(graphql.String, func(f *models.SnippetFile) any {
+			if f.Language != "" {
+				return f.Language
+			}
+			if f.DetectedLanguage != "" {
+				return f.DetectedLanguage
+			}
+			return nil
+		}),
+		"content": field(graphql.String, func(f *models.SnippetFile) any { return content(f.Content) }),
+	}}
+
+	userType := &graphql.Object{Name: "User"}
+	snippetType := &graphql.Object{Name: "Snippet", Fields: graphql.Fields{
+		"id":               field(graphql.NonNull{Of: graphql.ID}, func(s *models.Snippet) any { return s.PublicID }),
+		"title":            field(graphql.NonNull{Of: graphql.String}, func(s *models.Snippet) any { return s.Title }),
+		"content":          field(graphql.String, func(s *models.Snippet) any { return content(s.Content) }),
+		"created":          field(graphql.NonNull{Of: dateTime}, func(s *models.Snippet) any { return s.Created }),
+		"expires":          field(graphql.NonNull{Of: dateTime}, func(s *models.Snippet) any { return s.Expires }),
+		"publishAt":        field(graphql.NonNull{Of: dateTime}, func(s *models.Snippet) any { return s.PublishAt }),
+		"burnAfterReading": field(graphql.NonNull{Of: graphql.Boolean}, func(s *models.Snippet) any { return s.BurnAfterReading }),
+		"contentEncrypted": field(graphql.NonNull{Of: graphql.Boolean}, func(s *models.Snippet) any { return s.ContentEncrypted }),
+		"metadata":         field(metadataType, func(s *models.Snippet) any { return s.Metadata }),
+		// Every file, starting with the snippet's own content at position
+		// 0. Snippets in lists don't come with their files, so those are
+		// loaded for the whole list at once.
+		"files": {
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: fileType}}},
+			Size: models.MaxSnippetFiles,
+			Resolve: func(p graphql.Params) (any, error) {
+				s := p.Source.(*models.Snippet)
+				if s.Files != nil {
+					return s.AllFiles(), nil
+				}
+
+				load := files.Load(s.ID)
+				return graphql.Thunk(func() (any, error) {
+					extra, err := load()
+					if err != nil {
+						return nil, err
+					}
+					all := *s
+					all.Files = extra.([]*models.SnippetFile)
+					return all.AllFiles(), nil
+				}), nil
+			},
+		},
+		"author": {
+			Type: userType,
+			Resolve: func(p graphql.Params) (any, error) {
+				s := p.Source.(*models.Snippet)
+				if s.UserID == 0 {
+					return nil, nil
+				}
+				return users.Load(s.UserID), nil
+			},
+		},
+	}}
+
+	userType.Fields = graphql.Fields{
+		"id":      field(graphql.NonNull{Of: graphql.ID}, func(u *models.User) any { return u.ID }),
+		"name":    field(graphql.NonNull{Of: graphql.String}, func(u *models.User) any { return u.Name }),
+		"created": field(graphql.NonNull{Of: dateTime}, func(u *models.User) any { return u.Created }),
+		// Users can only see their own email address.
+		"email": field(graphql.String, func(u *models.User) any {
+			if u.ID != reqctx.UserID(r.Context()) {
+				return nil
+			}
+			return u.Email
+		}),
+		// The user's published snippets, newest first. Each user's are
+		// fetched with a query of their own, which the complexity limit
+		// keeps in check.
+		"snippets": {
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: snippetType}}},
+			Args: graphql.Args{"first": {Type: graphql.Int, Default: 10}},
+			Cost: 5,
+			Resolve: func(p graphql.Params) (any, error) {
+				first, err := graphqlFirst(p)
+				if err != nil {
+					return nil, err
+				}
+				snippets, _, err := app.snippets.LatestFromAuthors([]int{p.Source.(*models.User).ID}, first, 0)
+				return snippets, err
+			},
+		},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		// A snippet, if the client can see it. Burn after reading snippets
+		// can only be read by their owners here, so that nobody burns one
+		// by accident.
+		"snippet": {
+			Type: snippetType,
+			Args: graphql.Args{"id": {Type: graphql.NonNull{Of: graphql.ID}}},
+			Resolve: func(p graphql.Params) (any, error) {
+				// IDs which look like numbers are read as them, but no
+				// public ID does.
+				publicID, ok := p.Args["id"].(string)
+				if !ok {
+					return nil, nil
+				}
+				s, err := app.visibleSnippetFor(r, publicID)
+				if errors.Is(err, models.ErrNoRecord) {
+					return nil, nil
+				}
+				return s, err
+			},
+		},
+		// A page of the published snippets, newest first.
+		"snippets": {
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: snippetType}}},
+			Args: graphql.Args{
+				"first":  {Type: graphql.Int, Default: 20},
+				"offset": {Type: graphql.Int, Default: 0},
+			},
+			Cost: 5,
+			Resolve: func(p graphql.Params) (any, error) {
+				first, err := graphqlFirst(p)
+				if err != nil {
+					return nil, err
+				}
+				offset := p.Args["offset"].(int)
+				if offset < 0 {
+					return nil, graphql.Errorf("offset can't be negative")
+				}
+				snippets, _, err := app.snippets.Page(first, offset)
+				return snippets, err
+			},
+		},
+		"user": {
+			Type: userType,
+			Args: graphql.Args{"id": {Type: graphql.NonNull{Of: graphql.ID}}},
+			Resolve: func(p graphql.Params) (any, error) {
+				id, ok := p.Args["id"].(int)
+				if !ok {
+					return nil, nil
+				}
+				return users.Load(id), nil
+			},
+		},
+		// The authenticated user, or null.
+		"viewer": {
+			Type: userType,
+			Resolve: func(p graphql.Params) (any, error) {
+				if !app.isAuthenticated(r) {
+					return nil, nil
+				}
+				return users.Load(reqctx.UserID(r.Context())), nil
+			},
+		},
+	}}
+
+	mutation := &graphql.Object{Name: "Mutation", Fields: graphql.Fields{
+		// createSnippet takes the same input as POST /api/v1/snippets, and
+		// checks it in the same way.
+		"createSnippet": {
+			Type: graphql.NonNull{Of: snippetType},
+			Args: graphql.Args{
+				"title":            {Type: graphql.NonNull{Of: graphql.String}},
+				"content":          {Type: graphql.NonNull{Of: graphql.String}},
+				"filename":         {Type: graphql.String},
+				"language":         {Type: graphql.String},
+				"expires":          {Type: graphql.Int, Default: 0},
+				"publishAt":        {Type: dateTime},
+				"burnAfterReading": {Type: graphql.Boolean, Default: false},
+			},
+			Cost: 10,
+			Resolve: func(p graphql.Params) (any, error) {
+				if !app.isAuthenticated(r) {
+					return nil, graphql.Errorf("you must be authenticated to create snippets")
+				}
+				if app.isReadOnly() {
+					return nil, graphql.Errorf(apiReadOnlyMessage)
+				}
+
+				input := snippetCreateInput{
+					Title:            p.Args["title"].(string),
+					Content:          p.Args["content"].(string),
+					Expires:          p.Args["expires"].(int),
+					BurnAfterReading: p.Args["burnAfterReading"].(bool),
+				}
+				input.Filename, _ = p.Args["filename"].(string)
+				input.Language, _ = p.Args["language"].(string)
+				if publishAt, ok := p.Args["publishAt"].(time.Time); ok {
+					input.PublishAt = &publishAt
+				}
+
+				policy, err := app.expiryPolicy.Get()
+				if err != nil {
+					return nil, err
+				}
+
+				snippet := input.snippet(reqctx.UserID(r.Context()), policy, app.contentLimits)
+				if !input.Valid() {
+					return nil, graphql.Errorf("%s", fieldErrorsMessage(input.FieldErrors))
+				}
+				snippet, err = app.createSnippet(r, snippet, input.Expires)
+				switch {
+				case errors.Is(err, models.ErrTooLarge):
+					return nil, graphql.Errorf("the snippet is too large to save")
+				case errors.Is(err, models.ErrExpiryNotPermitted):
+					return nil, graphql.Errorf("expires: The expiry policy has changed, and no longer permits this expiry")
+				}
+				return snippet, err
+			},
+		},
+	}}
+
+	return &graphql.Schema{
+		Query:         query,
+		Mutation:      mutation,
+		MaxDepth:      graphqlMaxDepth,
+		MaxComplexity: graphqlMaxComplexity,
+		InternalError: func(err error) string {
+			app.errorLog.Output(2, err.Error())
+			return "the server encountered a problem and could not process your request"
+		},
+	}
+}
+
+// fieldErrorsMessage puts field errors into one message, like "content: This
+// field cannot be blank; title: ...", with the fields in order.
+func fieldErrorsMessage(fieldErrors map[string]string) string {
+	keys := make([]string, 0, len(fieldErrors))
+	for key := range fieldErrors {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = key + ": " + fieldErrors[key]
+	}
+	return strings.Join(msgs, "; ")
+}
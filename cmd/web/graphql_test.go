@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody []string
+	}{
+		{
+			name:     "Snippet",
+			body:     `{"query": "{ snippet(id: \"00000000000000000000000001\") { title files { filename } author { name email } metadata { lines } } }"}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{
+				`"title": "An old silent pond"`,
+				`"filename": "frog.txt"`,
+				`"name": "Admin"`,
+				`"email": null`,
+				`"lines": 2`,
+			},
+		},
+		{
+			name:     "Hidden snippet",
+			body:     `{"query": "query($id: ID!) { snippet(id: $id) { title } }", "variables": {"id": "00000000000000000000000006"}}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{`"snippet": null`},
+		},
+		{
+			name:     "List",
+			body:     `{"query": "{ snippets(first: 5) { id } user(id: 2) { snippets { title } } }"}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{`"id": "00000000000000000000000001"`, `"title": "An old silent pond"`},
+		},
+		{
+			name:     "Too many",
+			body:     `{"query": "{ snippets(first: 500) { id } }"}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{`"message": "first must be between 1 and 100"`},
+		},
+		{
+			name:     "Too deep",
+			body:     `{"query": "{ snippets { author { snippets { author { snippets { author { name } } } } } } }"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: []string{`nested 7 levels deep`},
+		},
+		{
+			name:     "Anonymous viewer",
+			body:     `{"query": "{ viewer { name } }"}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{`"viewer": null`},
+		},
+		{
+			name:     "Anonymous mutation",
+			body:     `{"query": "mutation { createSnippet(title: \"Title\", content: \"Content\") { id } }"}`,
+			wantCode: http.StatusOK,
+			wantBody: []string{`"message": "you must be authenticated to create snippets"`},
+		},
+		{
+			name:     "Syntax error",
+			body:     `{"query": "{ snippets { id }"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: []string{`syntax error on line 1`},
+		},
+		{
+			name:     "Unknown key",
+			body:     `{"query": "{ viewer { name } }", "variable": {}}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: []string{`body contains unknown key \"variable\"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.postJSON(t, "/graphql", tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+
+	code, _, _ := ts.postForm(t, "/graphql", nil)
+	assert.Equal(t, code, http.StatusUnsupportedMediaType)
+}
+
+func TestGraphQLMutation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.postJSON(t, "/graphql", `{"query": "{ viewer { name email } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"email": "alice@example.com"`)
+
+	code, _, body = ts.postJSON(t, "/graphql", `{
+		"query": "mutation Create($title: String!) { createSnippet(title: $title, content: \"Content\", expires: 7) { id } }",
+		"variables": {"title": "Title"}
+	}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"createSnippet": {`)
+
+	// The input is checked as it is by the JSON API.
+	code, _, body = ts.postJSON(t, "/graphql", `{"query": "mutation { createSnippet(title: \"\", content: \"Content\", expires: 3) { id } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"message": "expires: This field must equal 1, 7 or 365; title: This field cannot be blank"`)
+}
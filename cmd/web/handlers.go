@@ -2,24 +2,35 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
 	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
 	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	p := newPagination(r, snippetsPerPage)
+
+	data := &homePage{templateBase: app.newTemplateBase(r)}
+	renderStream(app, w, r, http.StatusOK, "home.tmpl.html", data, func(data *homePage) error {
+		snippets, total, err := app.snippets.Page(p.PerPage, p.Offset())
+		if err != nil {
+			return err
+		}
+		p.Total = total
 
-	app.render(w, http.StatusOK, "home.tmpl.html", data)
+		data.Snippets = snippets
+		data.Pagination = p
+		return nil
+	})
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
@@ -48,16 +59,210 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	// Burned snippets leave a tombstone behind, so that visitors can be
+	// told what happened to them.
+	if snippet.IsBurned() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		app.render(w, http.StatusGone, "burned.tmpl.html", data)
+		return
+	}
+
+	// Viewing a burn after reading snippet destroys it, so everyone but its
+	// owner is asked to confirm first. Link previews and crawlers only fetch
+	// the page, so they can't burn it by accident.
+	userID := reqctx.UserID(r.Context())
+	isOwner := userID != 0 && snippet.UserID == userID
+	if snippet.BurnAfterReading && !isOwner && !snippet.IsScheduled() {
+		data := app.newTemplateData(r)
+		data.Snippet = &models.Snippet{ID: snippet.ID, Title: snippet.Title}
+		w.Header().Set("X-Robots-Tag", "noindex")
+		app.render(w, http.StatusOK, "burn_confirm.tmpl.html", data)
+		return
+	}
+
+	// Scheduled snippets are hidden from everyone but their owner until
+	// they're published, and org only ones from everyone outside the
+	// organization.
+	visible, err := app.snippetVisible(snippet, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !visible {
+		app.notFound(w)
+		return
+	}
+
+	prefs, err := app.currentPreferences(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	collections, err := app.collections.ForSnippet(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &snippetViewPage{
+		templateBase: app.newTemplateBase(r),
+		Snippet:      snippet,
+		IsOwner:      isOwner,
+		TabWidth:     prefs.TabWidth,
+		Collections:  collections,
+	}
+
+	// The owner can add the snippet to any of their collections, and is
+	// warned about any files which don't parse.
+	if isOwner {
+		data.LintWarnings = snippetLintWarnings(snippet)
+		data.CollectionChoices, err = app.collections.ForUser(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// Encrypted snippets are meaningless to search engines, and their links
+	// carry the key.
+	if snippet.ContentEncrypted {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+
+	// The plain view shows the snippet on its own with line numbers and a
+	// minimal stylesheet, for printing or copying into documents. It has no
+	// scripts, so it can't decrypt encrypted snippets.
+	if r.URL.Query().Get("view") == "plain" && !snippet.ContentEncrypted {
+		renderLayout(app, w, http.StatusOK, "print", "view.tmpl.html", data)
+		return
+	}
+
+	render(app, w, http.StatusOK, "view.tmpl.html", data)
+}
+
+// snippetBurnPost shows a burn after reading snippet once, and burns it.
+// If somebody else got there first, the view page shows the tombstone.
+func (app *application) snippetBurnPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.GetAndConsume(id, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, urlFor("snippet.view", params.ByName("publicID")), http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Burning is the only way a snippet goes away before it expires, so
+	// that's what webhooks hear of as a deletion.
+	app.emitWebhookEvent(webhooks.SnippetDeleted, snippet.UserID, app.webhookSnippet(snippet))
+
+	data := &snippetViewPage{
+		templateBase: app.newTemplateBase(r),
+		Snippet:      snippet,
+		Burned:       true,
+	}
+
+	// The content is gone from the database now, so it mustn't linger in
+	// caches either.
+	w.Header().Set("Cache-Control", "no-store")
+	render(app, w, http.StatusOK, "view.tmpl.html", data)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	userID := reqctx.UserID(r.Context())
+
+	templates, err := app.snippetTemplates.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	prefs, err := app.currentPreferences(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	collections, err := app.collections.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	data.SnippetTemplates = templates
+	data.Collections = collections
+	data.ExpiryPolicy = policy
+	defaults := newSnippetCreateForm(prefs, policy)
+	data.Form = defaults
+
+	// Starting from one of the user's templates pre-populates the form.
+	// Otherwise pick up where the user left off, if they have a draft.
+	if s := r.URL.Query().Get("template"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+
+		t, err := app.snippetTemplates.Get(id, userID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		data.SnippetTemplate = t
+		form := defaults
+		form.Title = t.Title
+		form.Content = t.Content
+		form.Filename = t.Filename
+		form.Language = t.Language
+		data.Form = form
+	} else {
+		draft, form, err := app.restoreDraft(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if draft != nil {
+			// Drafts saved before the policy changed, or before they kept
+			// the expiry, start from the preferred expiry instead.
+			if !policy.Permits(form.Expires) {
+				form.Expires = defaults.Expires
+			}
+			data.Draft = draft
+			data.Form = *form
+		}
+	}
+
+	// New snippets are for the organization the user has switched to, if
+	// they have.
+	if form, ok := data.Form.(snippetCreateForm); ok && data.CurrentOrg != nil {
+		form.Org = data.CurrentOrg.ID
+		data.Form = form
 	}
+
 	app.render(w, http.StatusOK, "create.tmpl.html", data)
 }
 
@@ -71,29 +276,115 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	//form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	primary, files, publishAt := form.validate(policy, app.contentLimits)
+
+	userID := reqctx.UserID(r.Context())
+
+	// The snippet can go straight into one of the user's collections.
+	collections, err := app.collections.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if form.Collection != 0 {
+		form.CheckField(slices.ContainsFunc(collections, func(c *models.Collection) bool { return c.ID == form.Collection }),
+			"collection", "Pick one of your collections")
+	}
+
+	// Or be published for one of the user's organizations.
+	if form.Org != 0 {
+		_, err = app.orgs.Role(form.Org, userID)
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddFieldError("org", "Pick one of your organizations")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	// Use the Valid() method to see if any of the checks failed. If they did,
 	// then re-render the template passing in the form in the same way as
 	// before.
-	if !form.Valid() {
+	renderInvalid := func() {
 		data := app.newTemplateData(r)
 		data.Form = form
+		data.Collections = collections
+		data.ExpiryPolicy = policy
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl.html", data)
+	}
+	if !form.Valid() {
+		renderInvalid()
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	snippet := &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Filename:         primary.Filename,
+		Language:         primary.Language,
+		DetectedLanguage: primary.DetectedLanguage,
+		Files:            files,
+		UserID:           userID,
+		PublishAt:        publishAt,
+		BurnAfterReading: form.BurnAfterReading,
+		ContentEncrypted: form.ContentEncrypted,
+		OrgID:            form.Org,
+		OrgOnly:          form.OrgOnly,
+	}
 
+	id, err := app.snippets.Insert(snippet, form.Expires)
 	if err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, models.ErrTooLarge):
+			form.AddFieldError("content", "This snippet is too large to save")
+			renderInvalid()
+		case errors.Is(err, models.ErrForeignKeyViolation):
+			// The organization was deleted since it was checked above.
+			form.AddFieldError("org", "Pick one of your organizations")
+			renderInvalid()
+		case errors.Is(err, models.ErrExpiryNotPermitted):
+			// The policy was changed since it was checked above.
+			policy, err = app.expiryPolicy.Get()
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			checkExpires(&form.Validator, "expires", form.Expires, policy)
+			renderInvalid()
+		default:
+			app.serverError(w, err)
+		}
 		return
 	}
+
+	snippet.ID = id
+	app.emitWebhookEvent(webhooks.SnippetCreated, userID, app.webhookSnippet(snippet))
+	app.publishActivity(snippet)
+
+	if form.Collection != 0 {
+		if err = app.collections.AddSnippet(form.Collection, id); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// The draft has been published, so it isn't needed any more. The snippet
+	// exists either way, so failing to delete it, or to remember the choices
+	// for next time, is only logged.
+	if err = app.drafts.Delete(userID); err != nil {
+		app.errorLog.Print(err)
+	}
+	if err = app.rememberSnippetChoices(r, &form); err != nil {
+		app.errorLog.Print(err)
+	}
 	// Update the redirect path to use the new clean URL format.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, urlFor("snippet.view", snippet.PublicID), http.StatusSeeOther)
 }
 
 // Define a snippetCreateForm struct to represent the form data and validation
@@ -104,34 +395,129 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 // The struct tag `form:"-"` tells the decoder to completely ignore a field during decoding.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+	Title               string            `form:"title"`
+	Content             string            `form:"content"`
+	Filename            string            `form:"filename"`
+	Language            string            `form:"language"`
+	Files               []snippetFileForm `form:"files"`
+	Expires             int               `form:"expires"`
+	PublishAt           string            `form:"publish_at"`
+	BurnAfterReading    bool              `form:"burn_after_reading"`
+	ContentEncrypted    bool              `form:"content_encrypted"`
+	Collection          int               `form:"collection"`
+	Org                 int               `form:"org"`
+	OrgOnly             bool              `form:"org_only"`
 	validator.Validator `form:"-"`
 }
 
+// newSnippetCreateForm returns the form for a new snippet as it starts out:
+// with the choices the user made for their last one, or otherwise with their
+// preferences. Expiries the policy has ruled out since fall back to the
+// preferred one.
+func newSnippetCreateForm(prefs *models.Preferences, policy models.ExpiryPolicy) snippetCreateForm {
+	form := snippetCreateForm{
+		Language: prefs.Language,
+		Expires:  preferredExpires(prefs, policy),
+	}
+	if last := prefs.LastUsed; last != nil {
+		form.Language = last.Language
+		if policy.Permits(last.Expires) {
+			form.Expires = last.Expires
+		}
+		form.BurnAfterReading = last.BurnAfterReading
+		form.OrgOnly = last.OrgOnly
+	}
+	return form
+}
+
+// validate checks the form, with the expiry held to the given policy. It
+// returns the snippet's first file, with the detected language filled in,
+// its other files and when to publish it.
+func (form *snippetCreateForm) validate(policy models.ExpiryPolicy, limits validator.ContentLimits) (primary snippetFileForm, files []*models.SnippetFile, publishAt time.Time) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+
+	primary = snippetFileForm{Filename: form.Filename, Language: form.Language, Content: form.Content}
+	files = validateSnippetFiles(&form.Validator, limits, &primary, form.Files)
+	form.Content = primary.Content
+	if form.ContentEncrypted {
+		validateEncryptedSnippet(&form.Validator, &primary, files)
+	}
+
+	if form.PublishAt != "" {
+		// The datetime-local input sends times without a zone, and like all
+		// the other times on the site they are in UTC.
+		var err error
+		publishAt, err = time.ParseInLocation("2006-01-02T15:04", form.PublishAt, time.UTC)
+		form.CheckField(err == nil, "publish_at", "This field must be a valid date and time")
+		if err == nil {
+			validatePublishAt(&form.Validator, publishAt, form.Expires)
+		}
+	}
+	checkExpires(&form.Validator, "expires", form.Expires, policy)
+
+	if form.OrgOnly {
+		form.CheckField(form.Org != 0, "org_only", "Pick an organization to share the snippet with")
+		form.CheckField(!form.BurnAfterReading, "org_only", "Burn after reading snippets can't be org only")
+	}
+
+	return primary, files, publishAt
+}
+
+// validatePublishAt checks that a snippet's scheduled publishing time is in
+// the future, and before the snippet expires.
+func validatePublishAt(v *validator.Validator, publishAt time.Time, expires int) {
+	v.CheckField(publishAt.After(time.Now()), "publish_at", "This field must be in the future")
+	if expires != models.NeverExpires {
+		v.CheckField(publishAt.Before(time.Now().AddDate(0, 0, expires)), "publish_at", "The snippet must be published before it expires")
+	}
+}
+
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Invitation          string `form:"invitation"`
 	validator.Validator `form:"-"`
 }
 
+// validate checks the form. Whether the email address is taken and the
+// invitation code is valid are only found out by trying to sign up.
+func (form *userSignupForm) validate(inviteOnly bool) {
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field cannot be less than 8 characters long")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if inviteOnly {
+		form.CheckField(validator.NotBlank(form.Invitation), "invitation", "This field cannot be blank")
+	}
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
 }
 
-func (app *application) about(w http.ResponseWriter, r *http.Request) {
-	data := app.newTemplateData(r)
-	app.render(w, http.StatusOK, "about.tmpl.html", data)
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+	// Links to the signup and login pages can say where to go afterwards.
+	// It's kept in the session, so it survives signing up and then logging
+	// in.
+	app.rememberLoginRedirect(r, r.URL.Query().Get("next"))
+
+	// Invitation links include the code in the query string, so pre-fill
+	// the form with it.
+	app.renderSignup(w, r, http.StatusOK, userSignupForm{
+		Invitation: r.URL.Query().Get("invitation"),
+	})
 }
 
-func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
-	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl.html", data)
+// renderSignup shows the signup page with the form filled in.
+func (app *application) renderSignup(w http.ResponseWriter, r *http.Request, status int, form userSignupForm) {
+	render(app, w, status, "signup.tmpl.html", &signupPage{templateBase: app.newTemplateBase(r), Form: form})
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -143,45 +529,82 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be at least 8 characters long")
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field cannot be less than 8 characters long")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	inviteOnly := app.features.Enabled(features.SignupInviteOnly)
+	form.validate(inviteOnly)
 
 	// Use the Valid() method to see if any of the checks failed. If they did,
 	// then re-render the template passing in the form in the same way as
 	// before.
 	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+		app.renderSignup(w, r, http.StatusUnprocessableEntity, form)
 		return
 	}
 
+	// Consume the invitation before creating the user, so that the last use
+	// of a code can't be shared by two concurrent signups.
+	if inviteOnly {
+		err = app.invitations.Consume(form.Invitation)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidInvitation) {
+				form.AddFieldError("invitation", "This invitation code is invalid, expired or already used")
+				app.renderSignup(w, r, http.StatusUnprocessableEntity, form)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+	}
+
 	err = app.users.Insert(form.Name, form.Email, form.Password)
 	if err != nil {
+		// The signup didn't happen, so hand the invitation use back.
+		if inviteOnly {
+			if releaseErr := app.invitations.Release(form.Invitation); releaseErr != nil {
+				app.errorLog.Print(releaseErr)
+			}
+		}
+
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl.html", data)
+			app.renderSignup(w, r, http.StatusUnprocessableEntity, form)
 		} else {
 			app.serverError(w, err)
 		}
 		return
 	}
 
+	// Only admins' webhooks hear about signups.
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil {
+		app.errorLog.Print(err)
+	} else {
+		app.emitWebhookEvent(webhooks.UserRegistered, 0, map[string]any{
+			"id":      user.ID,
+			"name":    user.Name,
+			"created": user.Created.UTC(),
+			"url":     app.absoluteURL(urlFor("user.profile", user.ID)),
+		})
+	}
+
+	// The account exists either way, so failing to welcome its owner is only
+	// logged.
+	err = app.sendEmail(form.Email, "welcome", mailer.WelcomeData{Layout: app.emailLayout(), Name: form.Name})
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
 	// Otherwise add a confirmation flash message to the session confirming that
 	// their signup worked.
 	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
 
 	// And redirect the user to the login page.
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
 
 }
 
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	app.rememberLoginRedirect(r, r.URL.Query().Get("next"))
+
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
 	app.render(w, http.StatusOK, "login.tmpl.html", data)
@@ -212,6 +635,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.Password, Email: form.Email, Reason: "invalid credentials"})
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
@@ -223,27 +647,47 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.logIn(w, r, id, authevents.Password)
+}
+
+// logIn starts an authenticated session for the user, who signed in with
+// the given method, and redirects them to the page they were trying to
+// reach, if any.
+func (app *application) logIn(w http.ResponseWriter, r *http.Request, id int, method authevents.Method) {
+	path, err := app.startSession(r, id, method)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// startSession logs the user in, recording how they signed in, and returns
+// the path to send them to next.
+func (app *application) startSession(r *http.Request, id int, method authevents.Method) (string, error) {
 	// Use the RenewToken() method on the current session to change the session
 	// ID. It's good practice to generate a new session ID when the
 	// authentication state or privilege levels changes for the user (e.g. login
 	// and logout operations).
-	err = app.sessionManager.RenewToken(r.Context())
+	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
-		return
+		return "", err
 	}
 
 	// Add the ID of the current user to the session, so that they are now
 	// 'logged in'.
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginSucceeded, Method: method, UserID: id})
+
+	// The path was checked with isLocalPath when it was remembered.
 	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
 	if path != "" {
-		http.Redirect(w, r, path, http.StatusSeeOther)
-		return
+		return path, nil
 	}
 
-	// Redirect the user to the create snippet page.
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	// Otherwise send the user to the create snippet page.
+	return urlFor("snippet.create"), nil
 }
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
@@ -258,26 +702,28 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// Remove the authenticatedUserID from the session data so that the user is
 	// 'logged out'.
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.clearImpersonation(r)
+	app.recordAuthEvent(r, authevents.Event{Kind: authevents.Logout, UserID: reqctx.UserID(r.Context())})
 
 	// Add a flash message to the session to confirm to the user that they've been
 	// logged out.
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
 
 	// Redirect the user to the application home page.
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, urlFor("home"), http.StatusSeeOther)
 }
 
-func ping(w http.ResponseWriter, r *http.Request) {
+func (app *application) ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
-	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	id := reqctx.UserID(r.Context())
 
 	user, err := app.users.Get(id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
 		} else {
 			app.serverError(w, err)
 		}
@@ -328,10 +774,11 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 		return
 	}
 
-	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	id := reqctx.UserID(r.Context())
 	err = app.users.PasswordUpdate(id, form.CurrentPassword, form.NewPassword)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.recordAuthEvent(r, authevents.Event{Kind: authevents.PasswordChangeFailed, UserID: id, Reason: "incorrect current password"})
 			form.AddNonFieldError("Password is incorrect")
 
 			data := app.newTemplateData(r)
@@ -342,8 +789,9 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 		}
 		return
 	}
+	app.recordAuthEvent(r, authevents.Event{Kind: authevents.PasswordChanged, UserID: id})
 	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
 
 	// Redirect the user to the create snippet page.
-	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+	http.Redirect(w, r, urlFor("account.view"), http.StatusSeeOther)
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/features"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPing(t *testing.T) {
@@ -38,13 +41,19 @@ func TestSnippetView(t *testing.T) {
 	}{
 		{
 			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			urlPath:  "/snippet/view/00000000000000000000000001",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
 		{
 			name:     "Non-existent ID",
-			urlPath:  "/snippet/view/2",
+			urlPath:  "/snippet/view/00000000000000000000000002",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			// Snippets can't be found by counting through database IDs.
+			name:     "Database ID",
+			urlPath:  "/snippet/view/1",
 			wantCode: http.StatusNotFound,
 		},
 		{
@@ -54,7 +63,7 @@ func TestSnippetView(t *testing.T) {
 		},
 		{
 			name:     "Decimal ID",
-			urlPath:  "/snippet/view/1.23",
+			urlPath:  "/snippet/view/00000000000000000000000001.23",
 			wantCode: http.StatusNotFound,
 		},
 		{
@@ -234,3 +243,427 @@ func TestSnippetCreate(t *testing.T) {
 		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
 	})
 }
+
+func TestUserLogoutPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/snippet/create"))
+	code, headers, _ := ts.postForm(t, "/user/logout", form)
+
+	code, _, body := ts.followRedirect(t, code, headers)
+	assert.Equal(t, code, http.StatusOK)
+	assertFlash(t, body, "You've been logged out successfully!")
+
+	// Once logged out, protected pages should redirect back to the login
+	// form again.
+	code, headers, _ = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
+
+func TestUserSignupPostMultipart(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/signup"))
+
+	code, headers, _ := ts.postMultipart(t, "/user/signup", form, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body := ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Your signup was successful. Please log in.")
+}
+
+func TestUserSignupInviteOnly(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	err := app.features.SetSignupMode(features.SignupModeInvite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/signup?invitation=VALIDINVITATION1")
+	assert.StringContains(t, body, "value='VALIDINVITATION1'")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		invitation string
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "Valid invitation",
+			invitation: "VALIDINVITATION1",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Missing invitation",
+			invitation: "",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This field cannot be blank",
+		},
+		{
+			name:       "Used invitation",
+			invitation: "USEDINVITATION12",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This invitation code is invalid, expired or already used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", "bob@example.com")
+			form.Add("password", "validPa$$word")
+			form.Add("invitation", tt.invitation)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetViewMetadata(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// The metadata was worked out when the snippet was saved.
+	_, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<span class='facts'>2 lines &middot; 48 bytes &middot; 1 min read</span>")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<td>2 lines &middot; 1 min read</td>")
+
+	// Snippets are only ever numbered by their public IDs.
+	assert.StringContains(t, body, "<td>#00000000000000000000000001</td>")
+	assert.Equal(t, strings.Contains(body, "<td>#1</td>"), false)
+
+	// There's nothing to work out about ciphertext.
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000005")
+	assert.Equal(t, strings.Contains(body, "class='facts'"), false)
+}
+
+func TestSnippetViewPlain(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/00000000000000000000000001?view=plain")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "/static/css/print.css")
+	assert.StringContains(t, body, "<td class='line-number'>1</td>")
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.Equal(t, strings.Contains(body, "<nav>"), false)
+}
+
+func TestSnippetViewScheduled(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users can't see the snippet until it's published...
+	code, _, _ := ts.get(t, "/snippet/view/00000000000000000000000003")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/raw/00000000000000000000000003/0")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// ...but its owner can, along with a note that it's scheduled.
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/snippet/view/00000000000000000000000003")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<em class='scheduled'>Scheduled for")
+
+	// Other users still can't.
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, _ = ts.get(t, "/snippet/view/00000000000000000000000003")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetViewBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Its owner can look at it as often as they like.
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/snippet/view/00000000000000000000000004")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The password is hunter2.")
+	assert.StringContains(t, body, "will be deleted once someone else views it")
+
+	// Anyone else has to confirm first, and can't get at it any other way.
+	ts.resetClient(t)
+
+	code, _, body = ts.get(t, "/snippet/view/00000000000000000000000004")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "View and burn snippet")
+	if strings.Contains(body, "hunter2") {
+		t.Error("the confirmation page shows the snippet's content")
+	}
+
+	code, _, _ = ts.get(t, "/snippet/raw/00000000000000000000000004/0")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = ts.postForm(t, "/snippet/burn/00000000000000000000000004", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The password is hunter2.")
+	assert.StringContains(t, body, "This snippet has been burned")
+
+	// Once burned, only the tombstone is left.
+	code, _, body = ts.get(t, "/snippet/view/00000000000000000000000004")
+	assert.Equal(t, code, http.StatusGone)
+	if strings.Contains(body, "hunter2") {
+		t.Error("the burned snippet's content is still shown")
+	}
+
+	code, headers, _ := ts.postForm(t, "/snippet/burn/00000000000000000000000004", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/00000000000000000000000004")
+}
+
+func TestSnippetEncrypted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/snippet/view/00000000000000000000000005")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-Robots-Tag"), "noindex")
+	assert.StringContains(t, body, "data-ciphertext='v1:AAAAAAAAAAAAAAAA:")
+	if strings.Contains(body, "/snippet/raw/00000000000000000000000005/") {
+		t.Error("the view links to the ciphertext")
+	}
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	tests := []struct {
+		name      string
+		content   string
+		file      string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "Ciphertext",
+			content:  "v1:AAAAAAAAAAAAAAAA:AAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:      "Plaintext",
+			content:   "Not encrypted at all",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be encrypted in your browser",
+		},
+		{
+			name:      "Too long",
+			content:   "v1:AAAAAAAAAAAAAAAA:" + strings.Repeat("A", maxEncryptedContent),
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field cannot be more than 256 KB once encrypted",
+		},
+		{
+			name:      "Extra files",
+			content:   "v1:AAAAAAAAAAAAAAAA:AAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+			file:      "more plaintext",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Encrypted snippets can only have one file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", tt.content)
+			form.Add("expires", "7")
+			form.Add("content_encrypted", "true")
+			if tt.file != "" {
+				form.Add("files[0].content", tt.file)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePostPublishAt(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	tests := []struct {
+		name      string
+		publishAt string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:      "Tomorrow",
+			publishAt: time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02T15:04"),
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "In the past",
+			publishAt: "2020-01-01T10:00",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be in the future",
+		},
+		{
+			name:      "After expiry",
+			publishAt: time.Now().UTC().AddDate(0, 0, 30).Format("2006-01-02T15:04"),
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "The snippet must be published before it expires",
+		},
+		{
+			name:      "Invalid",
+			publishAt: "next tuesday",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be a valid date and time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", "Content")
+			form.Add("expires", "7")
+			form.Add("publish_at", tt.publishAt)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePostTooLarge(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("title", "Too large")
+	form.Add("content", "Content")
+	form.Add("expires", "7")
+	form.Add("csrf_token", ts.csrfToken(t, "/snippet/create"))
+
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This snippet is too large to save")
+}
+
+func TestLoginRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// postLogin logs in as Alice and returns where she is sent.
+	postLogin := func(t *testing.T) string {
+		form := url.Values{}
+		form.Add("email", "alice@example.com")
+		form.Add("password", "pa$$word")
+		form.Add("csrf_token", ts.csrfToken(t, "/user/login"))
+
+		code, headers, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		return headers.Get("Location")
+	}
+
+	t.Run("Requested page", func(t *testing.T) {
+		ts.resetClient(t)
+
+		code, headers, _ := ts.get(t, "/account/templates?sort=name")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+
+		assert.Equal(t, postLogin(t), "/account/templates?sort=name")
+	})
+
+	t.Run("Form submitted from another page", func(t *testing.T) {
+		ts.resetClient(t)
+
+		form := url.Values{"csrf_token": {ts.csrfToken(t, "/user/login")}}
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/user/follow/2", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Referer", ts.URL+"/snippet/view/00000000000000000000000001")
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, http.StatusSeeOther)
+
+		assert.Equal(t, postLogin(t), "/snippet/view/00000000000000000000000001")
+	})
+
+	t.Run("Across signup", func(t *testing.T) {
+		ts.resetClient(t)
+
+		ts.get(t, "/user/signup?next=/feed")
+		assert.Equal(t, postLogin(t), "/feed")
+	})
+
+	for _, next := range []string{
+		"//evil.example/",
+		"/%09/evil.example",
+		"/%0a/evil.example",
+		"/%5Cevil.example",
+		"https://evil.example/",
+		"https:evil.example",
+	} {
+		t.Run("Other sites "+next, func(t *testing.T) {
+			ts.resetClient(t)
+
+			ts.get(t, "/user/login?next="+next)
+			assert.Equal(t, postLogin(t), "/snippet/create")
+		})
+	}
+}
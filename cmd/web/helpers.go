@@ -1,28 +1,85 @@
 package main
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"html/template"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strings"
 	"time"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
 // then sends a generic 500 Internal Server Error response to the user.
 func (app *application) serverError(w http.ResponseWriter, err error) {
+	// The circuit breaker has already logged that the database is down.
+	if errors.Is(err, query.ErrCircuitOpen) {
+		app.retryAfter(w)
+		app.errorPage(w, http.StatusServiceUnavailable, unavailableMessage)
+		return
+	}
+
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
 
+	detail := ""
 	if app.debug {
-		http.Error(w, trace, http.StatusInternalServerError)
+		detail = trace
+	}
+	app.renderError(w, http.StatusInternalServerError, "", detail)
+}
+
+// renderError sends the error page. It doesn't use the template cache or
+// the base layout, so that it still works when they are what's broken. If
+// even the error page can't be rendered, a plain text response is sent
+// instead.
+func (app *application) renderError(w http.ResponseWriter, status int, reference, detail string) {
+	app.renderErrorPage(w, status, errorPageData{
+		StatusText: http.StatusText(status),
+		Reference:  reference,
+		Detail:     detail,
+	})
+}
+
+// errorPage renders the error page with a message, for passing to
+// middleware which sends errors in more than one format.
+func (app *application) errorPage(w http.ResponseWriter, status int, message string) {
+	app.renderErrorPage(w, status, errorPageData{StatusText: http.StatusText(status), Message: message})
+}
+
+func (app *application) renderErrorPage(w http.ResponseWriter, status int, data errorPageData) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	err := errorPage.ExecuteTemplate(buf, "error", data)
+	if err != nil {
+		app.errorLog.Printf("rendering error page: %s", err)
+
+		body := data.StatusText
+		if data.Message != "" {
+			body += "\n\n" + data.Message
+		}
+		if data.Reference != "" {
+			body += "\n\nReference: " + data.Reference + "\n"
+		}
+		if data.Detail != "" {
+			body += "\n" + data.Detail
+		}
+		http.Error(w, body, status)
 		return
 	}
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
 }
 
 // The clientError helper sends a specific status code and corresponding description
@@ -39,51 +96,87 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// The render helper renders a page inside the standard "base" layout, with the
+// header, navigation and footer.
 func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
-	// Retrieve the appropriate template set from the cache based on the page
-	// name (like 'home.tmpl'). If no entry exists in the cache with the
-	// provided name, then create a new error and call the serverError() helper
-	// method that we made earlier and return.
-	ts, ok := app.templateCache[page]
-	if !ok {
-		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+	app.renderLayout(w, status, "base", page, data)
+}
+
+// The renderLayout helper renders a page which has no view model of its own
+// inside the given layout.
+func (app *application) renderLayout(w http.ResponseWriter, status int, layout, page string, data *templateData) {
+	// Every layout reads fields like Flash and CSRFToken, so there's no
+	// point trying without any data.
+	if data == nil {
+		app.serverError(w, fmt.Errorf("rendering %s: no template data", page))
 		return
 	}
 
-	// Initialize a new buffer.
-	buf := new(bytes.Buffer)
+	renderLayout(app, w, status, layout, page, data)
+}
 
-	// Write the template to the buffer, instead of straight to the
-	// http.ResponseWriter. If there's an error, call our serverError() helper
-	// and then return.
-	err := ts.ExecuteTemplate(buf, "base", data)
+// templateSet retrieves the template set for a page from the cache, based
+// on the page name (like 'home.tmpl'), checking that it has the layout.
+func (app *application) templateSet(page, layout string) (*template.Template, error) {
+	ts, err := app.templateCache.lookup(page)
 	if err != nil {
-		app.serverError(w, err)
-		return
+		return nil, err
 	}
-
-	// Write out the provided HTTP status code ('200 OK', '400 Bad Request'
-	w.WriteHeader(status)
-
-	// Write the contents of the buffer to the http.ResponseWriter. Note: this
-	// is another time where we pass our http.ResponseWriter to a function that
-	// takes an io.Writer.
-	buf.WriteTo(w)
+	if ts.Lookup(layout) == nil {
+		return nil, fmt.Errorf("the template %s has no %s layout", page, layout)
+	}
+	return ts, nil
 }
 
 // Create an newTemplateData() helper, which returns a pointer to a templateData
 // struct initialized with the current year. Note that we're not using the
 // *http.Request parameter here at the moment, but we will do later in the book.
 func (app *application) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r), // Add the CSRF token.
+	return &templateData{templateBase: app.newTemplateBase(r)}
+}
+
+// newTemplateBase returns what the layouts need to show around any page.
+func (app *application) newTemplateBase(r *http.Request) templateBase {
+	orgs, currentOrg := app.userOrgs(r)
+
+	return templateBase{
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		CSRFToken:           nosurf.Token(r), // Add the CSRF token.
+		CSPNonce:            reqctx.CSPNonce(r.Context()),
+		Features:            app.features.All(),
+		UnreadNotifications: app.unreadNotifications(r),
+		ChangelogNew:        app.changelogNew(r),
+		SSOName:             app.ssoName(),
+		PasswordLogin:       !app.passwordLoginDisabled(),
+		LocalAccounts:       !app.localAccountsDisabled(),
+		Orgs:                orgs,
+		CurrentOrg:          currentOrg,
+		Impersonation:       app.impersonationBanner(r),
+		Locale:              newLocale(reqctx.Locale(r.Context())),
 	}
 }
 
+// unreadNotifications returns the number of unread notifications for the
+// current user, for the badge in the navigation bar. The badge isn't worth
+// failing the whole page for, so errors are logged and reported as zero.
+func (app *application) unreadNotifications(r *http.Request) int {
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	count, err := app.notifications.UnreadCount(id)
+	if err != nil {
+		app.errorLog.Print(err)
+		return 0
+	}
+
+	return count
+}
+
 // Create a new decodePostForm() helper method. The second parameter here, dst,
 // is the target destination that we want to decode the form data into.
 func (app *application) decodePostForm(r *http.Request, dst any) error {
@@ -96,7 +189,7 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 
 	// Call Decode() on our decoder instance, passing the target destination as
 	// the first parameter.
-	err = app.formDecoder.Decode(dst, r.PostForm)
+	err = app.decodeForm(dst, r.PostForm)
 	if err != nil {
 		// If we try to use an invalid target destination, the Decode() method
 		// will return an error with the type *form.InvalidDecoderError.We use
@@ -115,13 +208,187 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// decodeForm decodes values into dst. The decoder panics on keys with an
+// unbalanced bracket, like "files[0", rather than returning an error, and
+// the keys come from the client, so those panics are turned back into
+// errors. Anything else it panics with is a bug, and still panics.
+func (app *application) decodeForm(dst any, values url.Values) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			msg, ok := p.(string)
+			if !ok {
+				panic(p)
+			}
+			err = errors.New("form: " + msg)
+		}
+	}()
+
+	return app.formDecoder.Decode(dst, values)
+}
+
 // Return true if the current request is from an authenticated user, otherwise
 // return false.
 func (app *application) isAuthenticated(r *http.Request) bool {
-	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
-	if !ok {
+	return reqctx.IsAuthenticated(r.Context())
+}
+
+// isLocalPath reports whether s is an absolute path on this site, as opposed
+// to a URL which could point somewhere else. Protocol-relative URLs like
+// "//example.com" are rejected, and so is anything with a backslash or a
+// control character in it: browsers treat backslashes as slashes and strip
+// tabs and newlines, so "/\example.com" and "/\t/example.com" both end up
+// protocol-relative too.
+func isLocalPath(s string) bool {
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") {
 		return false
 	}
+	if strings.ContainsFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f || r == '\\' }) {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
 
-	return isAuthenticated
+// preferredLocale returns the first language in the request's
+// Accept-Language header, or "en" if it doesn't have one.
+func preferredLocale(r *http.Request) string {
+	first, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+
+	if tag == "" || tag == "*" {
+		return "en"
+	}
+	return tag
+}
+
+// loginRedirectTarget returns where to send a user who was asked to log in
+// while making r once they have done so. That's the requested page itself
+// for GET requests; other requests, like submitting a form, can't be
+// repeated by a redirect, so the user is sent back to the page they were on
+// instead, if it was on this site.
+func loginRedirectTarget(r *http.Request) string {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return r.URL.RequestURI()
+	}
+
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host != r.Host {
+		return ""
+	}
+	return referer.RequestURI()
+}
+
+// rememberLoginRedirect records where to send the user once they have logged
+// in. Anything which isn't a path on this site is ignored, so that links
+// to the login page can't send users elsewhere.
+func (app *application) rememberLoginRedirect(r *http.Request, target string) {
+	if isLocalPath(target) {
+		app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", target)
+	}
+}
+
+// runPeriodically calls fn every interval in a background goroutine until the
+// application exits. Errors and panics are logged rather than bringing down
+// the whole application.
+func (app *application) runPeriodically(name string, interval time.Duration, fn func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			app.runTask(name, fn)
+		}
+	}()
+}
+
+func (app *application) runTask(name string, fn func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.errorLog.Printf("%s: panic: %s\n%s", name, err, debug.Stack())
+		}
+	}()
+
+	if err := fn(); err != nil {
+		app.errorLog.Printf("%s: %s", name, err)
+	}
+}
+
+// clientIP returns the IP address of the client which made the request,
+// without the port.
+func clientIP(r *http.Request) string {
+	if ip := reqctx.ClientIP(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// isHTTPS reports whether the client made the request over HTTPS. Behind a
+// reverse proxy which terminates TLS, that's what the proxy says in
+// X-Forwarded-Proto, as long as it is one of the -trusted-proxies.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || reqctx.IsHTTPS(r.Context())
+}
+
+// absoluteURL returns the full URL of path on this site, for links which
+// leave the site, like those in emails and feeds. It's built from
+// -base-url, never from the request: the Host header is whatever the client
+// says it is, so links made from it could point anywhere.
+func (app *application) absoluteURL(path string) string {
+	return app.siteURL + path
+}
+
+// loadBaseURL parses -base-url, checking that it's set if anything needs
+// links to the site which can't be left pointing at localhost.
+func loadBaseURL(cfg config) (string, error) {
+	baseURL, err := parseBaseURL(cfg.baseURL)
+	if err != nil {
+		return "", err
+	}
+	if baseURL != "" {
+		return baseURL, nil
+	}
+
+	var problems []string
+	if cfg.oidc.issuer != "" {
+		problems = append(problems, "-oidc-issuer needs -base-url, for the address the provider sends users back to")
+	}
+	if cfg.smtp.host != "" || cfg.sendGridAPIKey != "" {
+		problems = append(problems, "-smtp-host and -sendgrid-api-key need -base-url, for the links in emails")
+	}
+	return "", joinProblems(problems)
+}
+
+// localSiteURL is the site's URL on this machine, where links point when
+// there's no -base-url: lc's port on localhost, or lc's own host if it
+// listens on just one.
+func localSiteURL(lc listenerConfig) string {
+	scheme := "http"
+	if lc.tls == tlsApp {
+		scheme = "https"
+	}
+
+	// Unix domain sockets are only reached through a proxy, which serves
+	// the default port.
+	if strings.HasPrefix(lc.addr, "unix:") {
+		return scheme + "://localhost"
+	}
+	host, port, err := net.SplitHostPort(lc.addr)
+	if err != nil {
+		return scheme + "://localhost"
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// remoteIP returns the host part of r.RemoteAddr, for requestContext to
+// store. Everything else should use clientIP.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
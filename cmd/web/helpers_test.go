@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderBrokenCache(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A page which forgot to define its blocks, so the layout fails.
+	broken, err := template.New("broken.tmpl.html").Funcs(functions).Parse(`{{define "base"}}{{template "main" .}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.set("nil.tmpl.html", nil)
+	cache.set("broken.tmpl.html", broken)
+	cache.set("nolayout.tmpl.html", template.Must(template.New("nolayout.tmpl.html").Parse(`{{define "main"}}{{end}}`)))
+
+	tests := []struct {
+		name    string
+		page    string
+		data    *templateData
+		wantLog string
+	}{
+		{"Missing template", "missing.tmpl.html", &templateData{}, "the template missing.tmpl.html does not exist"},
+		{"Nil template", "nil.tmpl.html", &templateData{}, "the template nil.tmpl.html does not exist"},
+		{"No layout", "nolayout.tmpl.html", &templateData{}, "has no base layout"},
+		{"Execution error", "broken.tmpl.html", &templateData{}, `no such template "main"`},
+		{"Nil data", "about.tmpl.html", nil, "rendering about.tmpl.html: no template data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			app := &application{templateCache: cache, errorLog: log.New(&logged, "", 0)}
+
+			rr := httptest.NewRecorder()
+			app.render(rr, http.StatusOK, tt.page, tt.data)
+
+			rs := rr.Result()
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, http.StatusInternalServerError)
+			assert.Equal(t, rs.Header.Get("Content-Type"), "text/html; charset=utf-8")
+			assert.StringContains(t, string(body), "<h2>Internal Server Error</h2>")
+			assert.StringContains(t, logged.String(), tt.wantLog)
+			// The trace is only shown in debug mode.
+			assert.Equal(t, strings.Contains(string(body), tt.wantLog), false)
+		})
+	}
+
+	t.Run("Debug", func(t *testing.T) {
+		app := &application{templateCache: cache, errorLog: log.New(io.Discard, "", 0), debug: true}
+
+		rr := httptest.NewRecorder()
+		app.render(rr, http.StatusOK, "missing.tmpl.html", &templateData{})
+
+		assert.Equal(t, rr.Code, http.StatusInternalServerError)
+		assert.StringContains(t, rr.Body.String(), "<pre>the template missing.tmpl.html does not exist")
+	})
+}
+
+func TestLocalSiteURL(t *testing.T) {
+	tests := []struct {
+		lc   listenerConfig
+		want string
+	}{
+		{listenerConfig{addr: ":4000", tls: tlsApp}, "https://localhost:4000"},
+		{listenerConfig{addr: "0.0.0.0:8080", tls: tlsProxy}, "http://localhost:8080"},
+		{listenerConfig{addr: "[::]:4000", tls: tlsApp}, "https://localhost:4000"},
+		{listenerConfig{addr: "127.0.0.1:4000", tls: tlsApp}, "https://127.0.0.1:4000"},
+		{listenerConfig{addr: "[::1]:4000", tls: tlsProxy}, "http://[::1]:4000"},
+		{listenerConfig{addr: "unix:/run/snippetbox.sock", tls: tlsProxy}, "http://localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lc.addr, func(t *testing.T) {
+			assert.Equal(t, localSiteURL(tt.lc), tt.want)
+		})
+	}
+}
+
+func TestLoadBaseURL(t *testing.T) {
+	cfg := defaultConfig(t)
+	got, err := loadBaseURL(cfg)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got, "")
+
+	cfg.oidc.issuer = "https://id.example.com"
+	_, err = loadBaseURL(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "-oidc-issuer needs -base-url")
+
+	cfg.oidc.issuer = ""
+	cfg.sendGridAPIKey = "SG.key"
+	_, err = loadBaseURL(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "-sendgrid-api-key need -base-url")
+
+	cfg.baseURL = "https://snippets.example/"
+	got, err = loadBaseURL(cfg)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got, "https://snippets.example")
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"time"
+)
+
+// impersonationTTL is how long an admin can act as another user before
+// they're turned back into themselves.
+const impersonationTTL = 30 * time.Minute
+
+// impersonationBanner is shown at the top of every page while an admin is
+// acting as another user.
+type impersonationBanner struct {
+	UserName string
+	Expires  time.Time
+}
+
+type adminImpersonationForm struct {
+	Reason              string `form:"reason"`
+	validator.Validator `form:"-"`
+}
+
+// sessionUserID returns the ID of the user the session is signed in as. If
+// an admin is impersonating them, it records so in the request context, or
+// hands the session back to the admin if the impersonation has expired.
+func (app *application) sessionUserID(r *http.Request) (int, error) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	adminID := app.sessionManager.GetInt(r.Context(), "impersonatorID")
+	if adminID == 0 {
+		return id, nil
+	}
+
+	if time.Now().Unix() >= app.sessionManager.GetInt64(r.Context(), "impersonationExpires") {
+		err := app.endImpersonation(r, "expired")
+		if err != nil {
+			return 0, err
+		}
+		app.sessionManager.Put(r.Context(), "flash", "Your impersonation has expired, and you're signed in as yourself again.")
+		return adminID, nil
+	}
+
+	reqctx.SetImpersonation(r.Context(), adminID, app.sessionManager.GetString(r.Context(), "impersonationID"))
+	return id, nil
+}
+
+// endImpersonation signs the session back in as the admin who was
+// impersonating its user, recording why.
+func (app *application) endImpersonation(r *http.Request, reason string) error {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	adminID := app.sessionManager.GetInt(r.Context(), "impersonatorID")
+	impersonationID := app.sessionManager.GetString(r.Context(), "impersonationID")
+
+	app.clearImpersonation(r)
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", adminID)
+	reqctx.SetImpersonation(r.Context(), 0, "")
+
+	app.recordAuthEvent(r, authevents.Event{
+		Kind:            authevents.ImpersonationEnded,
+		UserID:          userID,
+		ImpersonatorID:  adminID,
+		ImpersonationID: impersonationID,
+		Reason:          reason,
+	})
+	return nil
+}
+
+// clearImpersonation forgets that the session's user is being
+// impersonated, along with the organization the admin had switched to.
+func (app *application) clearImpersonation(r *http.Request) {
+	for _, key := range []string{"impersonatorID", "impersonationID", "impersonationExpires", "orgID"} {
+		app.sessionManager.Remove(r.Context(), key)
+	}
+}
+
+// impersonationBanner returns what the banner says while an admin is
+// impersonating the current user, or nil if nobody is.
+func (app *application) impersonationBanner(r *http.Request) *impersonationBanner {
+	if reqctx.ImpersonatorID(r.Context()) == 0 {
+		return nil
+	}
+
+	user, err := app.users.Get(reqctx.UserID(r.Context()))
+	if err != nil {
+		app.errorLog.Print(err)
+		return nil
+	}
+
+	return &impersonationBanner{
+		UserName: user.Name,
+		Expires:  time.Unix(app.sessionManager.GetInt64(r.Context(), "impersonationExpires"), 0),
+	}
+}
+
+// forbidImpersonation refuses requests made by an admin impersonating the
+// user, for pages which change how the user signs in or what can act on
+// their behalf. Those are for the user to change themselves.
+func (app *application) forbidImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqctx.ImpersonatorID(r.Context()) != 0 {
+			app.errorPage(w, http.StatusForbidden, "This can't be changed while you're impersonating someone.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminUserImpersonatePost signs the admin in as the user named in the URL,
+// to see the site as they do, for impersonationTTL. The reason is recorded
+// in the audit log. Admins, including the one asking, and suspended users
+// can't be impersonated.
+func (app *application) adminUserImpersonatePost(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var form adminImpersonationForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	adminID := reqctx.UserID(r.Context())
+
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 255), "reason", "This field cannot be more than 255 characters long")
+	if user.IsAdmin {
+		form.AddNonFieldError("Admins can't be impersonated.")
+	} else if user.Suspended(time.Now()) {
+		form.AddNonFieldError("Suspended users can't be impersonated.")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.AdminUser = user
+		data.Form = adminSuspensionForm{Days: 7}
+		data.ImpersonationForm = form
+		app.render(w, http.StatusUnprocessableEntity, "admin_user.tmpl.html", data)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	impersonationID := randomToken()
+	expires := time.Now().Add(impersonationTTL)
+
+	app.clearImpersonation(r)
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", user.ID)
+	app.sessionManager.Put(r.Context(), "impersonatorID", adminID)
+	app.sessionManager.Put(r.Context(), "impersonationID", impersonationID)
+	app.sessionManager.Put(r.Context(), "impersonationExpires", expires.Unix())
+
+	app.recordAuthEvent(r, authevents.Event{
+		Kind:            authevents.ImpersonationStarted,
+		UserID:          user.ID,
+		ImpersonatorID:  adminID,
+		ImpersonationID: impersonationID,
+		Reason:          form.Reason,
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You're now signed in as %s until %s UTC.", user.Name, humanDate(expires)))
+	http.Redirect(w, r, urlFor("home"), http.StatusSeeOther)
+}
+
+// impersonationStopPost hands the session back to the admin who was
+// impersonating its user.
+func (app *application) impersonationStopPost(w http.ResponseWriter, r *http.Request) {
+	userID := reqctx.UserID(r.Context())
+	if reqctx.ImpersonatorID(r.Context()) == 0 {
+		http.Redirect(w, r, urlFor("home"), http.StatusSeeOther)
+		return
+	}
+
+	err := app.endImpersonation(r, "stopped")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You're signed in as yourself again.")
+	http.Redirect(w, r, urlFor("admin.user", userID), http.StatusSeeOther)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminUserImpersonate(t *testing.T) {
+	var audit bytes.Buffer
+	app := newTestApplication(t)
+	app.authEvents = authevents.New(&audit, logging.Human)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/users/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Sign in as Alice")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		reason    string
+		wantCode  int
+		wantError string
+	}{
+		{"No reason", "/admin/users/impersonate/1", "", http.StatusUnprocessableEntity, "This field cannot be blank"},
+		{"Admin", "/admin/users/impersonate/2", "Testing", http.StatusUnprocessableEntity, "Admins can&#39;t be impersonated."},
+		{"Unknown user", "/admin/users/impersonate/99", "Testing", http.StatusNotFound, ""},
+		{"Impersonate", "/admin/users/impersonate/1", "Ticket 42", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("reason", tt.reason)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+
+	assert.StringContains(t, audit.String(), "AUTH\timpersonation_started user=1 impersonator=2 impersonation=")
+	assert.StringContains(t, audit.String(), `reason="Ticket 42"`)
+
+	// Every page says who the admin is acting as.
+	code, _, body = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You're impersonating <strong>Alice</strong>")
+	assert.StringContains(t, body, "alice@example.com")
+
+	// Account security settings are the user's own business, and the admin
+	// pages are out of reach while acting as someone who isn't an admin.
+	for _, path := range []string{"/account/password/update", "/admin/users/1"} {
+		code, _, _ = ts.get(t, path)
+		assert.Equal(t, code, http.StatusForbidden)
+	}
+	form := url.Values{}
+	form.Add("name", "Admin's token")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/account/tokens", form)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	// Stopping hands the session back to the admin.
+	audit.Reset()
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, headers, _ := ts.postForm(t, "/impersonation/stop", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/admin/users/1")
+	assert.StringContains(t, audit.String(), "AUTH\timpersonation_ended user=1 impersonator=2 impersonation=")
+	assert.StringContains(t, audit.String(), `reason="stopped"`)
+
+	code, _, body = ts.get(t, "/admin/users/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "You're impersonating"), false)
+}
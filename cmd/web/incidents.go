@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/incidents"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"time"
+)
+
+// reportPanic records a panic which happened while handling r as an incident,
+// sends it to the configured notifiers and responds with a 500 Internal
+// Server Error which includes the incident's reference code.
+func (app *application) reportPanic(w http.ResponseWriter, r *http.Request, recovered any, stack []byte) {
+	reference, err := incidents.NewReference()
+	if err != nil {
+		app.serverError(w, fmt.Errorf("%s", recovered))
+		return
+	}
+
+	info := reqctx.From(r.Context())
+
+	incident := &models.Incident{
+		Reference: reference,
+		Message:   fmt.Sprint(recovered),
+		Stack:     string(stack),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		UserID:    info.UserID,
+		RequestID: info.RequestID,
+		Created:   time.Now(),
+	}
+
+	app.errorLog.Printf("panic (incident %s): %s\n%s", reference, incident.Message, stack)
+
+	err = app.incidents.Insert(incident)
+	if err != nil {
+		app.errorLog.Printf("storing incident %s: %s", reference, err)
+	}
+
+	// Notifiers talk to other services, so they run in the background rather
+	// than holding up the response.
+	if len(app.incidentNotifiers) > 0 {
+		go app.notifyIncident(incident)
+	}
+
+	detail := ""
+	if app.debug {
+		detail = fmt.Sprintf("%s\n%s", incident.Message, stack)
+	}
+
+	app.renderError(w, http.StatusInternalServerError, reference, detail)
+}
+
+func (app *application) notifyIncident(incident *models.Incident) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, n := range app.incidentNotifiers {
+		if err := n.Notify(ctx, incident); err != nil {
+			app.errorLog.Printf("notifying about incident %s: %s", incident.Reference, err)
+		}
+	}
+}
+
+func (app *application) adminIncidents(w http.ResponseWriter, r *http.Request) {
+	list, err := app.incidents.Latest()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Incidents = list
+	app.render(w, http.StatusOK, "admin_incidents.tmpl.html", data)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/incidents"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// chanNotifier passes incidents to a channel.
+type chanNotifier chan *models.Incident
+
+func (n chanNotifier) Notify(ctx context.Context, incident *models.Incident) error {
+	n <- incident
+	return nil
+}
+
+func TestRecoverPanic(t *testing.T) {
+	store := &mocks.IncidentModel{}
+	notified := make(chanNotifier, 1)
+
+	app := newTestApplication(t)
+	app.incidents = store
+	app.incidentNotifiers = []incidents.Notifier{notified}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqctx.SetUserID(r.Context(), 1)
+		panic("something went wrong")
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/snippet/view/00000000000000000000000001", nil)
+
+	app.requestContext(app.recoverPanic(next)).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, rs.Header.Get("Connection"), "close")
+
+	assert.Equal(t, len(store.Incidents), 1)
+	incident := store.Incidents[0]
+	assert.Equal(t, incident.Message, "something went wrong")
+	assert.Equal(t, incident.Path, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, incident.UserID, 1)
+	assert.Equal(t, incident.RequestID, rs.Header.Get("X-Request-ID"))
+	assert.StringContains(t, incident.Stack, "TestRecoverPanic")
+	assert.StringContains(t, string(body), "Reference: "+incident.Reference)
+
+	select {
+	case n := <-notified:
+		assert.Equal(t, n.Reference, incident.Reference)
+	case <-time.After(time.Second):
+		t.Fatal("notifier wasn't called")
+	}
+}
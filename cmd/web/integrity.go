@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// integrityChecker keeps track of the scheduled check for orphaned rows,
+// for the admin integrity page and the metrics. The check runs every
+// Interval, and deletes what it finds if Repair is set. It is safe for
+// concurrent use.
+type integrityChecker struct {
+	Interval time.Duration
+	Repair   bool
+
+	mu        sync.Mutex
+	lastRun   time.Time
+	results   []integrityResult
+	remaining int
+}
+
+// integrityResult is what one check found the last time it ran. Repaired
+// is how many of the rows it found it deleted, and Error why it couldn't
+// run, if it couldn't.
+type integrityResult struct {
+	Name        string
+	Description string
+	Found       int
+	Repaired    int
+	Error       string
+}
+
+// integrityStatus is a snapshot of an integrityChecker.
+type integrityStatus struct {
+	Interval time.Duration
+	Repair   bool
+	LastRun  time.Time
+	Results  []integrityResult
+}
+
+func (c *integrityChecker) status() integrityStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return integrityStatus{Interval: c.Interval, Repair: c.Repair, LastRun: c.lastRun, Results: c.results}
+}
+
+// integrityCheck is one kind of orphaned row: what it is, and how to count
+// and delete them.
+type integrityCheck struct {
+	name        string
+	description string
+	count       func() (int, error)
+	repair      func() (int, error)
+}
+
+// integrityChecks returns the checks: the rows whose parent row is gone,
+// which the database finds, and the sessions of users who no longer exist,
+// which only the session manager can decode.
+func (app *application) integrityChecks() []integrityCheck {
+	var checks []integrityCheck
+
+	for _, c := range models.OrphanChecks {
+		name := c.Name
+		checks = append(checks, integrityCheck{
+			name:        name,
+			description: c.Description,
+			count:       func() (int, error) { return app.orphans.CountOrphans(name) },
+			repair:      func() (int, error) { return app.orphans.DeleteOrphans(name) },
+		})
+	}
+
+	checks = append(checks, integrityCheck{
+		name:        "sessions",
+		description: "Sessions of users who no longer exist",
+		count: func() (int, error) {
+			tokens, err := app.orphanedSessions()
+			return len(tokens), err
+		},
+		repair: func() (int, error) {
+			tokens, err := app.orphanedSessions()
+			if err != nil {
+				return 0, err
+			}
+			for i, token := range tokens {
+				if err := app.sessions.Delete(token); err != nil {
+					return i, err
+				}
+			}
+			return len(tokens), nil
+		},
+	})
+
+	return checks
+}
+
+// orphanedSessions returns the tokens of the active sessions which are
+// signed in as users who no longer exist. authenticate already treats
+// them as signed out, so they're only taking up space. Sessions which
+// can't be decoded are left alone.
+func (app *application) orphanedSessions() ([]string, error) {
+	sessions, err := app.sessions.Active()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string]int{}
+	var ids []int
+	for _, s := range sessions {
+		_, values, err := app.sessionManager.Codec.Decode(s.Data)
+		if err != nil {
+			continue
+		}
+		if id, ok := values["authenticatedUserID"].(int); ok && id > 0 {
+			owners[s.Token] = id
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	users, err := app.users.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	for _, s := range sessions {
+		if id, ok := owners[s.Token]; ok && users[id] == nil {
+			tokens = append(tokens, s.Token)
+		}
+	}
+	return tokens, nil
+}
+
+// checkIntegrity runs every check, deleting what they find if repair is
+// set. It runs every -integrity-interval, repairing if -integrity-repair is
+// set, and when an admin asks for it. A check which fails doesn't stop the
+// others. The admins are notified when rows are repaired, and when the
+// number left unrepaired changes.
+func (app *application) checkIntegrity(repair bool) ([]integrityResult, error) {
+	var results []integrityResult
+	var errs []error
+	found, repaired := 0, 0
+
+	for _, check := range app.integrityChecks() {
+		result := integrityResult{Name: check.name, Description: check.description}
+
+		n, err := check.count()
+		if err == nil && n > 0 && repair {
+			result.Repaired, err = check.repair()
+		}
+		result.Found = n
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", check.name, err))
+		}
+
+		if result.Repaired > 0 {
+			app.infoLog.Printf("integrity: deleted %d orphaned %s", result.Repaired, check.name)
+		} else if n > 0 {
+			app.infoLog.Printf("integrity: found %d orphaned %s", n, check.name)
+		}
+
+		found += result.Found
+		repaired += result.Repaired
+		results = append(results, result)
+	}
+
+	app.integrity.mu.Lock()
+	app.integrity.lastRun = time.Now()
+	app.integrity.results = results
+	remaining, changed := found-repaired, found-repaired != app.integrity.remaining
+	app.integrity.remaining = remaining
+	app.integrity.mu.Unlock()
+
+	var message string
+	switch {
+	case repaired > 0:
+		message = fmt.Sprintf("The integrity check deleted %d orphaned rows.", repaired)
+		if remaining > 0 {
+			message += fmt.Sprintf(" %d more couldn't be deleted.", remaining)
+		}
+	case remaining > 0 && changed:
+		message = fmt.Sprintf("The integrity check found %d orphaned rows.", remaining)
+	}
+	if message != "" {
+		if _, err := app.notifications.NotifyAdmins(models.NotificationIntegrity, message, urlFor("admin.integrity")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// writeIntegrityMetrics writes what each check found the last time it ran.
+// Nothing is written before the first run.
+func writeIntegrityMetrics(w io.Writer, status integrityStatus) {
+	if status.LastRun.IsZero() {
+		return
+	}
+
+	const name = "snippetbox_integrity_orphans"
+
+	fmt.Fprintf(w, "# HELP %s Orphaned rows found by the last integrity check, by check.\n# TYPE %s gauge\n", name, name)
+	for _, r := range status.Results {
+		fmt.Fprintf(w, "%s{check=%q} %d\n", name, r.Name, r.Found-r.Repaired)
+	}
+
+	writeMetrics(w, []metric{
+		{"snippetbox_integrity_last_run_timestamp_seconds", "gauge", "When the integrity check last ran.", float64(status.LastRun.Unix())},
+	})
+}
+
+// adminIntegrity shows what the integrity check found the last time it
+// ran.
+func (app *application) adminIntegrity(w http.ResponseWriter, r *http.Request) {
+	render(app, w, http.StatusOK, "admin_integrity.tmpl.html", &adminIntegrityPage{
+		templateBase: app.newTemplateBase(r),
+		Integrity:    app.integrity.status(),
+	})
+}
+
+// adminIntegrityRunPost runs the integrity check straight away.
+func (app *application) adminIntegrityRunPost(w http.ResponseWriter, r *http.Request) {
+	app.checkIntegrityNow(w, r, false)
+}
+
+// adminIntegrityRepairPost runs the integrity check straight away, and
+// deletes what it finds even if -integrity-repair isn't set.
+func (app *application) adminIntegrityRepairPost(w http.ResponseWriter, r *http.Request) {
+	app.checkIntegrityNow(w, r, true)
+}
+
+func (app *application) checkIntegrityNow(w http.ResponseWriter, r *http.Request, repair bool) {
+	results, err := app.checkIntegrity(repair)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	found, repaired := 0, 0
+	for _, result := range results {
+		found += result.Found
+		repaired += result.Repaired
+	}
+
+	switch {
+	case repair:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Deleted %d orphaned rows.", repaired))
+	case found > 0:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Found %d orphaned rows.", found))
+	default:
+		app.sessionManager.Put(r.Context(), "flash", "No orphaned rows were found.")
+	}
+
+	http.Redirect(w, r, urlFor("admin.integrity"), http.StatusSeeOther)
+}
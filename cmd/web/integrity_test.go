@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAdminIntegrity(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Two notifications have lost their users, and so has one of the
+	// sessions.
+	notifications := &mocks.NotificationModel{}
+	sessions := &mocks.SessionModel{}
+	app.notifications = notifications
+	app.sessions = sessions
+	app.orphans = &mocks.IntegrityModel{Orphans: map[string]int{"notifications": 2}}
+
+	for token, userID := range map[string]int{"alice": 1, "gone": 99} {
+		data, err := app.sessionManager.Codec.Encode(time.Now().Add(time.Hour), map[string]any{"authenticatedUserID": userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions.Sessions = append(sessions.Sessions, models.SessionData{Token: token, Data: data})
+	}
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/integrity")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "It hasn't run since the server started.")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/integrity"))
+
+	// A check only reports what it finds...
+	code, headers, _ := ts.postForm(t, "/admin/integrity/run", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Found 3 orphaned rows.")
+	assert.StringContains(t, body, "<td>Sessions of users who no longer exist</td>\n        <td>1</td>\n        <td>0</td>")
+	assert.Equal(t, len(sessions.Sessions), 2)
+	assert.Equal(t, len(notifications.AdminMessages), 1)
+	assert.Equal(t, notifications.AdminMessages[0], "The integrity check found 3 orphaned rows.")
+
+	_, _, body = ts.get(t, "/metrics")
+	assert.StringContains(t, body, `snippetbox_integrity_orphans{check="notifications"} 2`)
+	assert.StringContains(t, body, `snippetbox_integrity_orphans{check="sessions"} 1`)
+
+	// ...and the admins aren't told again while nothing changes.
+	ts.postForm(t, "/admin/integrity/run", form)
+	assert.Equal(t, len(notifications.AdminMessages), 1)
+
+	// Repairing deletes it.
+	code, headers, _ = ts.postForm(t, "/admin/integrity/repair", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Deleted 3 orphaned rows.")
+	assert.Equal(t, len(sessions.Sessions), 1)
+	assert.Equal(t, sessions.Sessions[0].Token, "alice")
+	assert.Equal(t, notifications.AdminMessages[1], "The integrity check deleted 3 orphaned rows.")
+
+	_, _, body = ts.get(t, "/metrics")
+	assert.StringContains(t, body, `snippetbox_integrity_orphans{check="sessions"} 0`)
+
+	code, headers, _ = ts.postForm(t, "/admin/integrity/run", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "No orphaned rows were found.")
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/admin/integrity")
+	assert.Equal(t, code, http.StatusForbidden)
+}
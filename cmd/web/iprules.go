@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/ipfilter"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ipRules are the IP allow and deny lists for the parts of the site which
+// can be restricted, loaded from the files named by the -admin-ip-rules and
+// -login-ip-rules flags. Either may be nil, in which case everyone is
+// allowed. See the ipfilter package for the file format.
+type ipRules struct {
+	admin *ipfilter.File
+	login *ipfilter.File
+}
+
+// loadIPRules loads the rules files which are configured.
+func loadIPRules(adminPath, loginPath string) (ipRules, error) {
+	var rules ipRules
+	var err error
+
+	if adminPath != "" {
+		rules.admin, err = ipfilter.Load(adminPath)
+		if err != nil {
+			return ipRules{}, err
+		}
+	}
+
+	if loginPath != "" {
+		rules.login, err = ipfilter.Load(loginPath)
+		if err != nil {
+			return ipRules{}, err
+		}
+	}
+
+	return rules, nil
+}
+
+// reloadIPRules reads the rules files again. A file which can't be read or has
+// mistakes in it is logged and its previous rules are kept, so a typo can't
+// lock everyone out.
+func (app *application) reloadIPRules() {
+	for _, f := range []*ipfilter.File{app.ipRules.admin, app.ipRules.login} {
+		if f == nil {
+			continue
+		}
+		if err := f.Reload(); err != nil {
+			app.errorLog.Printf("reloading IP rules: %s", err)
+			continue
+		}
+		app.infoLog.Printf("reloaded %d IP rules from %s", f.Rules().Len(), f.Path())
+	}
+}
+
+// reloadIPRulesOnSIGHUP reloads the IP rules files whenever the process
+// receives SIGHUP, so they can be changed without a restart.
+func (app *application) reloadIPRulesOnSIGHUP() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for range sighup {
+			app.reloadIPRules()
+		}
+	}()
+}
+
+// requireAllowedIP only lets clients whose IP address is allowed by rules
+// through, and shows everyone else a 403 Forbidden page. Denied requests are
+// logged, naming the area of the site so that the log is easy to search.
+func (app *application) requireAllowedIP(rules *ipfilter.File, area string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+
+			if !rules.Allowed(ip) {
+				app.infoLog.Printf("audit: denied %s access from %s: %s %s (user %d)",
+					area, ip, r.Method, r.URL.RequestURI(), reqctx.UserID(r.Context()))
+
+				data := app.newTemplateData(r)
+				data.ClientIP = ip
+				app.render(w, http.StatusForbidden, "forbidden.tmpl.html", data)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeIPRules writes rules to a file in a temporary directory and returns
+// its path.
+func writeIPRules(t *testing.T, path, rules string) string {
+	t.Helper()
+
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "ip-rules")
+	}
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAdminIPRules(t *testing.T) {
+	// The test server's clients connect from 127.0.0.1.
+	path := writeIPRules(t, "", "allow 10.0.0.0/8\n")
+
+	var audit bytes.Buffer
+	app := newTestApplication(t, func(app *application) {
+		var err error
+		app.ipRules, err = loadIPRules(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.infoLog = log.New(&audit, "", 0)
+	})
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Clients from elsewhere get a 403 page, without being asked to sign in.
+	code, _, body := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "can't be used from your network (127.0.0.1)")
+	assert.StringContains(t, audit.String(), "audit: denied admin access from 127.0.0.1: GET /admin (user 0)")
+
+	// Signing in isn't restricted.
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, _ = ts.get(t, "/admin/features")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, audit.String(), "GET /admin/features (user 2)")
+
+	// The rules can be changed without a restart.
+	writeIPRules(t, path, "allow 127.0.0.1\n")
+	app.reloadIPRules()
+
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+
+	// A broken file keeps the previous rules.
+	writeIPRules(t, path, "allow localhost\n")
+	app.reloadIPRules()
+
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+
+	writeIPRules(t, path, "deny 127.0.0.0/8\n")
+	app.reloadIPRules()
+
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestLoginIPRules(t *testing.T) {
+	path := writeIPRules(t, "", "deny 127.0.0.1\n")
+
+	app := newTestApplication(t, func(app *application) {
+		var err error
+		app.ipRules, err = loadIPRules("", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	for _, page := range []string{"/user/login", "/user/login/magic"} {
+		code, _, _ := ts.get(t, page)
+		assert.Equal(t, code, http.StatusForbidden)
+	}
+
+	// The rest of the site isn't affected.
+	code, _, _ := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxJSONBodyBytes limits the size of the request body accepted by readJSON.
+const maxJSONBodyBytes = 1_048_576
+
+// envelope wraps the top-level value in every JSON response, so that
+// responses are always JSON objects like {"snippet": {...}}.
+type envelope map[string]any
+
+// writeJSON encodes data as JSON and writes it with the given status code and
+// any additional headers.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// Append a newline to make it easier to view in terminal applications.
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// readJSON decodes a JSON request body into dst. The body must be a single
+// JSON value of at most maxJSONBodyBytes and must not contain any fields that
+// dst doesn't have. The returned errors are written for the client, so they
+// can be sent back in the response as they are.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+
+		// Decode() can also return io.ErrUnexpectedEOF for syntax errors in
+		// the JSON, without an offset.
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q (at character %d)", unmarshalTypeError.Field, unmarshalTypeError.Offset)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+
+		// There's no distinct error type for unknown fields, so we have to
+		// check the error message instead.
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+
+		// A non-nil pointer must be passed to Decode(), so this is a bug in
+		// our handler rather than a problem with the request.
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+
+		default:
+			return err
+		}
+	}
+
+	// Decode again to make sure there is nothing but whitespace after the
+	// first JSON value.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// isJSONRequest reports whether the request body is declared as JSON.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// The apiErrorResponse helper sends a JSON error response with the given
+// status code. The message can be any value which encodes to JSON, such as a
+// string or a map of field errors.
+func (app *application) apiErrorResponse(w http.ResponseWriter, status int, message any) {
+	err := app.writeJSON(w, status, envelope{"error": message}, nil)
+	if err != nil {
+		app.errorLog.Output(2, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// apiError is apiErrorResponse for plain messages, for passing to
+// middleware which sends errors in more than one format.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.apiErrorResponse(w, status, message)
+}
+
+// The apiServerError helper is the JSON equivalent of serverError.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, query.ErrCircuitOpen) {
+		app.retryAfter(w)
+		app.apiErrorResponse(w, http.StatusServiceUnavailable, unavailableMessage)
+		return
+	}
+
+	app.errorLog.Output(2, err.Error())
+
+	app.apiErrorResponse(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiErrorResponse(w, http.StatusNotFound, "the requested resource could not be found")
+}
+
+func (app *application) apiBadRequest(w http.ResponseWriter, err error) {
+	app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+func (app *application) apiFailedValidation(w http.ResponseWriter, fieldErrors map[string]string) {
+	app.apiErrorResponse(w, http.StatusUnprocessableEntity, fieldErrors)
+}
+
+func (app *application) apiPreconditionFailed(w http.ResponseWriter) {
+	app.apiErrorResponse(w, http.StatusPreconditionFailed, "the resource has been changed since you last fetched it")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+)
+
+// sortOption is one of the ways a list can be sorted, for the links at the
+// top of it.
+type sortOption struct {
+	Value string
+	Label string
+}
+
+// snippetSortOptions are the ways the snippets of a language can be sorted.
+var snippetSortOptions = []sortOption{
+	{models.OrderNewest, "Newest"},
+	{models.OrderOldest, "Oldest"},
+	{models.OrderTitle, "Title"},
+}
+
+func (app *application) languageIndex(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippets.CountByLanguage()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.LanguageCounts = counts
+	app.render(w, http.StatusOK, "languages.tmpl.html", data)
+}
+
+func (app *application) languageSnippets(w http.ResponseWriter, r *http.Request) {
+	lang, ok := languages.Lookup(httprouter.ParamsFromContext(r.Context()).ByName("lang"))
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case models.OrderOldest, models.OrderTitle:
+	default:
+		sort = models.OrderNewest
+	}
+
+	p := newPagination(r, snippetsPerPage)
+
+	data := app.newTemplateData(r)
+	data.Language = lang
+	data.Sort = sort
+	data.SortOptions = snippetSortOptions
+	renderStream(app, w, r, http.StatusOK, "language_snippets.tmpl.html", data, func(data *templateData) error {
+		snippets, total, err := app.snippets.ByLanguage(lang.Name, sort, p.PerPage, p.Offset())
+		if err != nil {
+			return err
+		}
+		p.Total = total
+
+		data.Snippets = snippets
+		data.Pagination = p
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+)
+
+func TestLanguageIndex(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/languages")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/language/plaintext'>Plain text</a>")
+	assert.StringContains(t, body, "<a href='/languages'>Languages</a>")
+}
+
+func TestLanguageSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Snippets",
+			urlPath:  "/language/plaintext",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/00000000000000000000000001'>An old silent pond</a>",
+		},
+		{
+			name:     "Default sort",
+			urlPath:  "/language/plaintext?sort=sideways",
+			wantCode: http.StatusOK,
+			wantBody: "<strong>Newest</strong>",
+		},
+		{
+			name:     "Sorted",
+			urlPath:  "/language/plaintext?sort=title",
+			wantCode: http.StatusOK,
+			wantBody: "<strong>Title</strong>",
+		},
+		{
+			name:     "No snippets",
+			urlPath:  "/language/go",
+			wantCode: http.StatusOK,
+			wantBody: "There are no Go snippets yet.",
+		},
+		{
+			name:     "Unknown language",
+			urlPath:  "/language/cobol",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
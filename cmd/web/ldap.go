@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/ldapauth"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+)
+
+// Values for -auth-backend.
+const (
+	authLocal = "local"
+	authLDAP  = "ldap"
+)
+
+// newUserModel returns the user model for the -auth-backend: either the
+// users table on its own, or with passwords checked against an LDAP
+// directory.
+func newUserModel(cfg config, db models.DBTX) (models.UserModelInterface, error) {
+	hasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	local := &models.UserModel{DB: db, Hasher: hasher}
+
+	switch cfg.authBackend {
+	case authLocal:
+		return local, nil
+	case authLDAP:
+		dir, err := ldapauth.New(ldapauth.Config{
+			URL:            cfg.ldap.url,
+			StartTLS:       cfg.ldap.startTLS,
+			BindDN:         cfg.ldap.bindDN,
+			BindPassword:   cfg.ldap.bindPassword,
+			BaseDN:         cfg.ldap.baseDN,
+			UserFilter:     cfg.ldap.userFilter,
+			EmailAttribute: cfg.ldap.emailAttribute,
+			NameAttribute:  cfg.ldap.nameAttribute,
+			GroupAttribute: cfg.ldap.groupAttribute,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &models.LDAPUserModel{UserModel: local, Directory: dir, AdminGroups: cfg.ldap.adminGroups}, nil
+	default:
+		return nil, fmt.Errorf("-auth-backend must be %q or %q", authLocal, authLDAP)
+	}
+}
+
+// localAccountsDisabled reports whether accounts are managed somewhere
+// else, either by the LDAP directory or by an enforced single sign-on
+// provider. Then nobody can sign up, and the ways of signing in and
+// changing account details which don't go through it are turned off.
+func (app *application) localAccountsDisabled() bool {
+	return app.authBackend == authLDAP || app.passwordLoginDisabled()
+}
+
+// requireLocalAccounts returns 404 Not Found for the routes which are
+// turned off when accounts are managed somewhere else.
+func (app *application) requireLocalAccounts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.localAccountsDisabled() {
+			app.notFound(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
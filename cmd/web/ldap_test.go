@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewUserModel(t *testing.T) {
+	cfg := defaultConfig(t)
+
+	cfg.authBackend = authLDAP
+	_, err := newUserModel(cfg, nil)
+	assert.Equal(t, err != nil, true)
+
+	cfg.ldap.url = "ldaps://ad.example.com"
+	cfg.ldap.baseDN = "dc=example,dc=com"
+	_, err = newUserModel(cfg, nil)
+	assert.Equal(t, err, nil)
+
+	cfg.authBackend = "kerberos"
+	_, err = newUserModel(cfg, nil)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestLDAPAccounts(t *testing.T) {
+	app := newTestApplication(t)
+	app.authBackend = authLDAP
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Passwords are still typed into the login form, but they are checked
+	// by the directory, so the other ways in are gone.
+	code, _, body := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/user/login' method='POST'")
+	assert.Equal(t, strings.Contains(body, "/user/login/magic"), false)
+	assert.Equal(t, strings.Contains(body, "passkey-login"), false)
+
+	for _, path := range []string{"/user/login/magic", "/user/signup"} {
+		code, _, _ = ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "/account/password/update"), false)
+
+	for _, path := range []string{"/account/password/update", "/account/email/update"} {
+		code, _, _ = ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/lint"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+)
+
+// lintMessages checks content written in the given language, and words the
+// warnings for the page. They're only ever shown alongside the snippet:
+// nothing is refused because of them.
+func lintMessages(language, content string) []string {
+	var messages []string
+	for _, w := range lint.Check(language, content) {
+		if w.Line > 0 {
+			messages = append(messages, fmt.Sprintf("This %s is invalid at line %d: %s", languages.Label(language), w.Line, w.Message))
+		} else {
+			messages = append(messages, fmt.Sprintf("This %s is invalid: %s", languages.Label(language), w.Message))
+		}
+	}
+	return messages
+}
+
+// snippetLintWarnings checks each of a snippet's files in its effective
+// language, and returns the warnings by file position. Encrypted snippets
+// can't be checked.
+func snippetLintWarnings(s *models.Snippet) map[int][]string {
+	warnings := map[int][]string{}
+	if s.ContentEncrypted {
+		return warnings
+	}
+
+	for _, f := range s.AllFiles() {
+		if messages := lintMessages(f.EffectiveLanguage(), f.Content); messages != nil {
+			warnings[f.Position] = messages
+		}
+	}
+	return warnings
+}
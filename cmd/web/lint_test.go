@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"testing"
+)
+
+func TestSnippetLintWarnings(t *testing.T) {
+	snippet := &models.Snippet{
+		Content:  "func main() {\n\tfmt.Println(\"hi\"\n}\n",
+		Language: "go",
+		Files: []*models.SnippetFile{
+			{Position: 1, Filename: "valid.json", Content: `{"a": 1}`, DetectedLanguage: "json"},
+			{Position: 2, Filename: "invalid.yaml", Content: "a: 1\nb: c: d", DetectedLanguage: "yaml"},
+			{Position: 3, Filename: "notes.txt", Content: "{", Language: "plaintext"},
+		},
+	}
+
+	warnings := snippetLintWarnings(snippet)
+	assert.Equal(t, len(warnings), 2)
+	assert.StringContains(t, warnings[0][0], "This Go is invalid at line 2: ")
+	assert.StringContains(t, warnings[2][0], "This YAML is invalid at line 2: ")
+
+	// Encrypted content is never checked.
+	snippet.ContentEncrypted = true
+	assert.Equal(t, len(snippetLintWarnings(snippet)), 0)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/proxyproto"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultAddr is where the server listens when -addr isn't given. On most
+// systems it accepts both IPv4 and IPv6 connections.
+const defaultAddr = ":4000"
+
+// listenerConfig is one of the addresses the server listens on, given with
+// -addr. Each can terminate TLS differently: tls, certFile and keyFile
+// override -tls and the certificate in ./tls for this listener alone, and
+// clientAuth asks for client certificates on it; see clientAuthRequire.
+// Admin listeners are the ones given with -admin-addr; see adminRoutes.
+type listenerConfig struct {
+	addr       string
+	tls        string
+	certFile   string
+	keyFile    string
+	clientAuth string
+	admin      bool
+}
+
+// listenerList is the -addr flag. It can be repeated to listen on several
+// addresses, and each address can be followed by comma-separated options,
+// like "[::1]:4443,tls=app,cert=/etc/snippetbox/cert.pem,key=/etc/snippetbox/key.pem"
+// or "127.0.0.1:4001,client-auth=require".
+type listenerList []listenerConfig
+
+func (l *listenerList) String() string {
+	addrs := make([]string, len(*l))
+	for i, lc := range *l {
+		addrs[i] = lc.addr
+	}
+	return strings.Join(addrs, " ")
+}
+
+func (l *listenerList) Set(value string) error {
+	fields := strings.Split(value, ",")
+
+	lc := listenerConfig{addr: strings.TrimSpace(fields[0])}
+	if lc.addr == "" {
+		return errors.New("missing address")
+	}
+
+	for _, field := range fields[1:] {
+		name, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || v == "" {
+			return fmt.Errorf("%q should be option=value", field)
+		}
+		switch name {
+		case "tls":
+			lc.tls = v
+		case "cert":
+			lc.certFile = v
+		case "key":
+			lc.keyFile = v
+		case "client-auth":
+			lc.clientAuth = v
+		default:
+			return fmt.Errorf("unknown option %q; use tls, cert, key or client-auth", name)
+		}
+	}
+
+	*l = append(*l, lc)
+	return nil
+}
+
+// listeners returns the listeners given with -addr, or the default one,
+// followed by the admin listeners given with -admin-addr, with the settings
+// they don't override filled in.
+func (cfg config) listeners() []listenerConfig {
+	list := cfg.addrs
+	if len(list) == 0 {
+		list = listenerList{{addr: defaultAddr}}
+	}
+	// The full slice expression keeps append from writing into cfg.addrs.
+	list = append(list[:len(list):len(list)], cfg.adminAddrs...)
+
+	listeners := make([]listenerConfig, len(list))
+	for i, lc := range list {
+		if lc.tls == "" {
+			lc.tls = cfg.tls
+		}
+		if lc.tls == tlsApp && lc.certFile == "" && lc.keyFile == "" {
+			lc.certFile, lc.keyFile = tlsCertFile, tlsKeyFile
+		}
+		lc.admin = i >= len(list)-len(cfg.adminAddrs)
+		listeners[i] = lc
+	}
+	return listeners
+}
+
+// serveListener serves srv's handler on ln, over HTTPS with the listener's
+// own certificate, or plain HTTP when TLS is terminated by a proxy. It
+// returns when the server fails or is shut down. Any number of listeners can
+// be served by the same server at once.
+func serveListener(srv *http.Server, lc listenerConfig, ln net.Listener) error {
+	if lc.tls == tlsProxy {
+		return srv.Serve(ln)
+	}
+	if lc.clientAuth != "" {
+		return serveTLSWithClientAuth(srv, lc, ln)
+	}
+	return srv.ServeTLS(ln, lc.certFile, lc.keyFile)
+}
+
+// describe says where ln, bound for lc, listens and how, for the log. The
+// address is the one actually bound, so a port of 0 shows the port picked.
+func (lc listenerConfig) describe(ln net.Listener) string {
+	if lc.tls == tlsProxy {
+		return fmt.Sprintf("%s, with TLS terminated by the proxy", ln.Addr())
+	}
+	switch lc.clientAuth {
+	case clientAuthOptional:
+		return fmt.Sprintf("%s, serving HTTPS with %s and checking any client certificates", ln.Addr(), lc.certFile)
+	case clientAuthRequire:
+		return fmt.Sprintf("%s, serving HTTPS with %s to clients with certificates", ln.Addr(), lc.certFile)
+	}
+	return fmt.Sprintf("%s, serving HTTPS with %s", ln.Addr(), lc.certFile)
+}
+
+// newListener creates the listener the server accepts connections on.
+// Addresses of the form "unix:/run/snippetbox.sock" bind a Unix domain socket
+// at the given path; anything else is treated as a TCP address like ":4000".
+//
+// When proxyProtocol is true, every TCP connection must start with a HAProxy
+// PROXY protocol header, and r.RemoteAddr in handlers is the client address
+// from that header rather than the address of the load balancer.
+func newListener(addr string, proxyProtocol bool) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if proxyProtocol {
+			return nil, errors.New("the PROXY protocol is only supported on TCP listeners")
+		}
+
+		// A socket file left behind by a previous run stops us binding to the
+		// same path again, so remove it. Anything that isn't a socket is left
+		// alone, in case the path was mistyped.
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+
+		return net.Listen("unix", path)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyProtocol {
+		ln = &proxyproto.Listener{Listener: ln}
+	}
+
+	return ln, nil
+}
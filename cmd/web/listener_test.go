@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/proxyproto"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewListener(t *testing.T) {
+	t.Run("Unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snippetbox.sock")
+
+		ln, err := newListener("unix:"+path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ln.Addr().Network(), "unix")
+
+		// Simulate a crash which leaves the socket file behind: binding to
+		// the same path again must still work.
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		ln.Close()
+
+		ln, err = newListener("unix:"+path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.Close()
+	})
+
+	t.Run("Unix socket with PROXY protocol", func(t *testing.T) {
+		_, err := newListener("unix:"+filepath.Join(t.TempDir(), "s.sock"), true)
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("TCP with PROXY protocol", func(t *testing.T) {
+		ln, err := newListener("127.0.0.1:0", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		_, ok := ln.(*proxyproto.Listener)
+		assert.Equal(t, ok, true)
+	})
+}
+
+func TestListenerList(t *testing.T) {
+	var l listenerList
+	assert.Equal(t, l.Set(":4000"), nil)
+	assert.Equal(t, l.Set("[::1]:4443, tls=app, cert=a.pem, key=a-key.pem"), nil)
+	assert.Equal(t, l.String(), ":4000 [::1]:4443")
+	assert.Equal(t, l[1], listenerConfig{addr: "[::1]:4443", tls: tlsApp, certFile: "a.pem", keyFile: "a-key.pem"})
+
+	assert.Equal(t, l.Set("").Error(), "missing address")
+	assert.Equal(t, l.Set(":4000,tls").Error(), `"tls" should be option=value`)
+	assert.Equal(t, l.Set(":4000,http2=off").Error(), `unknown option "http2"; use tls, cert, key or client-auth`)
+	assert.Equal(t, len(l), 2)
+
+	// Listeners take what they don't override from -tls.
+	cfg := config{addrs: append(l, listenerConfig{addr: "unix:/run/s.sock", tls: tlsProxy}), tls: tlsApp}
+	listeners := cfg.listeners()
+	assert.Equal(t, listeners[0], listenerConfig{addr: ":4000", tls: tlsApp, certFile: tlsCertFile, keyFile: tlsKeyFile})
+	assert.Equal(t, listeners[1].certFile, "a.pem")
+	assert.Equal(t, listeners[2], listenerConfig{addr: "unix:/run/s.sock", tls: tlsProxy})
+
+	cfg = config{tls: tlsProxy}
+	assert.Equal(t, len(cfg.listeners()), 1)
+	assert.Equal(t, cfg.listeners()[0], listenerConfig{addr: defaultAddr, tls: tlsProxy})
+
+	// The admin listeners come after the others, with the same defaults.
+	cfg.adminAddrs = listenerList{{addr: "127.0.0.1:4001", tls: tlsApp, clientAuth: clientAuthRequire}}
+	listeners = cfg.listeners()
+	assert.Equal(t, len(listeners), 2)
+	assert.Equal(t, listeners[0], listenerConfig{addr: defaultAddr, tls: tlsProxy})
+	assert.Equal(t, listeners[1], listenerConfig{addr: "127.0.0.1:4001", tls: tlsApp, certFile: tlsCertFile, keyFile: tlsKeyFile, clientAuth: clientAuthRequire, admin: true})
+}
+
+func TestServeListeners(t *testing.T) {
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "TLS: %t", r.TLS != nil)
+	})}
+	defer srv.Close()
+
+	listeners := []listenerConfig{
+		{addr: "127.0.0.1:0", tls: tlsProxy},
+		{addr: "[::1]:0", tls: tlsProxy},
+		{addr: "127.0.0.1:0", tls: tlsApp, certFile: "../../tls/cert.pem", keyFile: "../../tls/key.pem"},
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	for _, lc := range listeners {
+		ln, err := newListener(lc.addr, false)
+		if err != nil {
+			if strings.HasPrefix(lc.addr, "[") {
+				t.Logf("skipping %s: %v", lc.addr, err)
+				continue
+			}
+			t.Fatal(err)
+		}
+		go serveListener(srv, lc, ln)
+
+		// The port picked for port 0 is the one logged.
+		assert.Equal(t, ln.Addr().(*net.TCPAddr).Port != 0, true)
+		if lc.tls == tlsProxy {
+			assert.Equal(t, lc.describe(ln), ln.Addr().String()+", with TLS terminated by the proxy")
+		} else {
+			assert.Equal(t, lc.describe(ln), ln.Addr().String()+", serving HTTPS with ../../tls/cert.pem")
+		}
+
+		scheme := "http"
+		if lc.tls == tlsApp {
+			scheme = "https"
+		}
+		rs, err := client.Get(scheme + "://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rs.Body)
+		rs.Body.Close()
+		assert.Equal(t, string(body), fmt.Sprintf("TLS: %t", lc.tls == tlsApp))
+	}
+}
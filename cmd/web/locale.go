@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"time"
+)
+
+// localeFormats are the locales whose formatting of dates and numbers the
+// pages follow, with the layout of their dates. The first is the default,
+// for visitors who want none of the others. Only the formatting follows the
+// locale; the words are English whatever it is.
+var localeFormats = []struct {
+	tag        language.Tag
+	dateLayout string
+}{
+	{language.English, "02 Jan 2006 at 15:04"},
+	{language.AmericanEnglish, "Jan 2, 2006 at 3:04 PM"},
+	{language.German, "02.01.2006, 15:04"},
+	{language.Spanish, "02/01/2006 15:04"},
+	{language.French, "02/01/2006 15:04"},
+	{language.Italian, "02/01/2006 15:04"},
+	{language.Dutch, "02-01-2006 15:04"},
+	{language.Portuguese, "02/01/2006 15:04"},
+	{language.Japanese, "2006/01/02 15:04"},
+	{language.Chinese, "2006-01-02 15:04"},
+}
+
+// localeMatcher picks the nearest of the localeFormats to a request's.
+var localeMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(localeFormats))
+	for i, f := range localeFormats {
+		tags[i] = f.tag
+	}
+	return language.NewMatcher(tags)
+}()
+
+// locale formats dates, relative times and numbers for the pages in the way
+// the visitor's locale does. Every page has one for the request's locale, as
+// .Locale, so templates format values with calls like
+// {{$.Locale.Date .Created}}. The zero value formats them as the default
+// locale does.
+type locale struct {
+	tag        language.Tag
+	dateLayout string
+	printer    *message.Printer
+}
+
+// newLocale returns the locale for a language tag, like "pt-BR", taken from
+// the Accept-Language header. Tags which can't be parsed, or which aren't
+// close to any of the localeFormats, get the default.
+func newLocale(name string) locale {
+	tag, err := language.Parse(name)
+	if err != nil {
+		return locale{}
+	}
+
+	_, i, confidence := localeMatcher.Match(tag)
+	if confidence == language.No {
+		return locale{}
+	}
+
+	f := localeFormats[i]
+	return locale{tag: f.tag, dateLayout: f.dateLayout, printer: message.NewPrinter(f.tag)}
+}
+
+// Date formats a time as a date and time in UTC, or returns "" for the zero
+// time.
+func (l locale) Date(t time.Time) string {
+	if l.dateLayout == "" {
+		return humanDate(t)
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(l.dateLayout)
+}
+
+// Number formats a count with the locale's digit grouping, like "12,345" or
+// "12.345".
+func (l locale) Number(n int) string {
+	if l.printer == nil {
+		return message.NewPrinter(localeFormats[0].tag).Sprintf("%d", n)
+	}
+	return l.printer.Sprintf("%d", n)
+}
+
+// Ago says how long ago a time was, like "5 minutes ago", for times in the
+// last month. Older ones are given as a date.
+func (l locale) Ago(t time.Time) string {
+	d := time.Since(t)
+
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), "day"
+	default:
+		return l.Date(t)
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%s %s ago", l.Number(n), unit)
+}
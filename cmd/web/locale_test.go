@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLocale(t *testing.T) {
+	tm := time.Date(2024, 3, 17, 14, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		wantDate   string
+		wantNumber string
+	}{
+		{"", "17 Mar 2024 at 14:05", "1,234,567"},
+		{"en", "17 Mar 2024 at 14:05", "1,234,567"},
+		{"en-GB", "17 Mar 2024 at 14:05", "1,234,567"},
+		{"en-US", "Mar 17, 2024 at 2:05 PM", "1,234,567"},
+		{"de-AT", "17.03.2024, 14:05", "1.234.567"},
+		{"fr", "17/03/2024 14:05", "1 234 567"},
+		{"pt-BR", "17/03/2024 14:05", "1.234.567"},
+		{"ja", "2024/03/17 14:05", "1,234,567"},
+		{"tlh", "17 Mar 2024 at 14:05", "1,234,567"},
+		{"not a tag", "17 Mar 2024 at 14:05", "1,234,567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLocale(tt.name)
+			assert.Equal(t, l.Date(tm), tt.wantDate)
+			assert.Equal(t, l.Date(time.Time{}), "")
+			assert.Equal(t, l.Number(1234567), tt.wantNumber)
+		})
+	}
+}
+
+func TestLocaleAgo(t *testing.T) {
+	l := newLocale("de")
+
+	assert.Equal(t, l.Ago(time.Now().Add(-10*time.Second)), "just now")
+	assert.Equal(t, l.Ago(time.Now().Add(-time.Minute)), "1 minute ago")
+	assert.Equal(t, l.Ago(time.Now().Add(-5*time.Hour)), "5 hours ago")
+	assert.Equal(t, l.Ago(time.Now().Add(-3*24*time.Hour)), "3 days ago")
+
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	assert.Equal(t, l.Ago(old), old.UTC().Format("02.01.2006, 15:04"))
+}
+
+func TestLocaleFromRequest(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// The home page lists mockSnippet, which was created just now.
+	code, _, body := ts.do(t, http.MethodGet, "/", http.Header{"Accept-Language": {"de-DE,de;q=0.9,en;q=0.8"}}, "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>"+time.Now().UTC().Format("02.01.2006, "))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/ratelimit"
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// magicLinkTTL is how long a sign-in link works for.
+const magicLinkTTL = 15 * time.Minute
+
+// magicLinkSentFlash is shown whether or not there is an account for the
+// address, so the form can't be used to find out who has one.
+const magicLinkSentFlash = "If there's an account for that address, we've emailed it a sign-in link. The link works once, within 15 minutes."
+
+// newMagicLinkLimiter returns the limiter for sign-in link requests, which
+// allows three links per email address and then one every five minutes.
+func newMagicLinkLimiter() *ratelimit.Limiter {
+	return ratelimit.New(1.0/300, 3)
+}
+
+type magicLinkForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userLoginMagicForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = magicLinkForm{}
+	app.render(w, http.StatusOK, "login_magic.tmpl.html", data)
+}
+
+// magicLinkConfirmation is what the sign-in link's page shows: the signed
+// link which the form posts back to.
+type magicLinkConfirmation struct {
+	Action string
+}
+
+// userLoginMagicConfirm shows the page which a sign-in link points to. The
+// user is only signed in when the form on the page is submitted, so that
+// email scanners which follow links don't use them up.
+func (app *application) userLoginMagicConfirm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = magicLinkConfirmation{Action: r.URL.RequestURI()}
+	app.render(w, http.StatusOK, "login_magic_confirm.tmpl.html", data)
+}
+
+// userLoginMagic signs in the user which the signed link being posted to
+// was sent to. Each link only works once.
+func (app *application) userLoginMagic(w http.ResponseWriter, r *http.Request) {
+	id, ok := signedURLUser(r)
+	if !ok {
+		app.loginLinkFailed(w, r, signedurl.ErrInvalid)
+		return
+	}
+
+	app.logIn(w, r, id, authevents.LoginLink)
+}
+
+// loginLinkFailed records the failed sign-in when a sign-in link isn't
+// accepted, and sends the visitor back to the sign-in page.
+func (app *application) loginLinkFailed(w http.ResponseWriter, r *http.Request, err error) {
+	var reason string
+	switch {
+	case errors.Is(err, signedurl.ErrUsed):
+		reason = "link already used"
+	case errors.Is(err, signedurl.ErrInvalid), errors.Is(err, signedurl.ErrExpired):
+		reason = "invalid or expired link"
+	default:
+		app.serverError(w, err)
+		return
+	}
+
+	app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.LoginLink, Reason: reason})
+	app.sessionManager.Put(r.Context(), "flash", "That sign-in link is invalid, has expired or has already been used. Please ask for a new one.")
+	http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
+}
+
+// userLoginMagicPost emails a sign-in link to the given address. The
+// response is the same whether or not the address belongs to an account,
+// and requests are throttled per address so that the form can't be used to
+// flood somebody's inbox.
+func (app *application) userLoginMagicPost(w http.ResponseWriter, r *http.Request) {
+	var form magicLinkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_magic.tmpl.html", data)
+		return
+	}
+
+	// The link goes to -base-url, never to whatever host the request
+	// claims, so without one there's nowhere to send it.
+	if app.baseURL == "" {
+		app.errorLog.Print("not sending a sign-in link: -base-url isn't set")
+		form.AddNonFieldError("Sign-in links can't be sent right now. Please sign in with your password.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusServiceUnavailable, "login_magic.tmpl.html", data)
+		return
+	}
+
+	if !app.magicLimiter.Allow(strings.ToLower(strings.TrimSpace(form.Email))) {
+		form.AddNonFieldError("Too many sign-in links have been sent to this address. Please try again in a few minutes.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login_magic.tmpl.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	event := authevents.Event{Kind: authevents.LoginLinkRequested, Email: form.Email}
+	if user == nil {
+		event.Reason = "no such account"
+	} else {
+		event.UserID = user.ID
+	}
+	app.recordAuthEvent(r, event)
+
+	if user != nil {
+		link, err := app.signedURL(urlFor("user.login.magic.link"), url.Values{"uid": {strconv.Itoa(user.ID)}}, magicLinkTTL)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.sendEmail(user.Email, "login_link", mailer.LoginLinkData{
+			Layout: app.emailLayout(),
+			Name:   user.Name,
+			Link:   link,
+		})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", magicLinkSentFlash)
+	http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
+}
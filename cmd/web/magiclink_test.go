@@ -0,0 +1,158 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUserLoginMagicPost(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/login/magic")
+
+	post := func(email string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("csrf_token", csrfToken)
+		code, headers, body := ts.postForm(t, "/user/login/magic", form)
+		sendEmails(t, app)
+		return code, headers, body
+	}
+
+	t.Run("Invalid email", func(t *testing.T) {
+		code, _, body := post("alice@")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must be a valid email address")
+		assert.Equal(t, len(mail.sent), 0)
+	})
+
+	t.Run("Unknown email", func(t *testing.T) {
+		code, headers, _ := post("nobody@example.com")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, len(mail.sent), 0)
+
+		_, _, body := ts.followRedirect(t, code, headers)
+		assertFlash(t, body, magicLinkSentFlash)
+	})
+
+	t.Run("Known email", func(t *testing.T) {
+		code, headers, _ := post("alice@example.com")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, len(mail.sent), 1)
+		assert.Equal(t, mail.sent[0].To, "alice@example.com")
+		assert.StringContains(t, emailLink(t, mail.sent[0].Body, "/user/login/magic/link"), "uid=1")
+
+		_, _, body := ts.followRedirect(t, code, headers)
+		assertFlash(t, body, magicLinkSentFlash)
+	})
+
+	t.Run("Spoofed Host", func(t *testing.T) {
+		mail.sent = nil
+
+		form := url.Values{}
+		form.Add("email", "admin@example.com")
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postFormWithHost(t, "evil.example", "/user/login/magic", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		sendEmails(t, app)
+		assert.Equal(t, len(mail.sent), 1)
+		assert.StringContains(t, mail.sent[0].Body, app.baseURL+"/user/login/magic/link?")
+		assert.Equal(t, strings.Contains(mail.sent[0].Body, "evil.example"), false)
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		mail.sent = nil
+
+		// Alice has already been sent one link, so two more are allowed.
+		for i := 0; i < 2; i++ {
+			code, _, _ := post("alice@example.com")
+			assert.Equal(t, code, http.StatusSeeOther)
+		}
+
+		// Addresses are throttled regardless of case.
+		code, _, body := post("Alice@Example.com")
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.StringContains(t, body, "Too many sign-in links have been sent to this address")
+		assert.Equal(t, len(mail.sent), 2)
+	})
+}
+
+func TestUserLoginMagicWithoutBaseURL(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	app.baseURL = ""
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("csrf_token", ts.csrfToken(t, "/user/login/magic"))
+	code, _, body := ts.postForm(t, "/user/login/magic", form)
+	sendEmails(t, app)
+
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, "Sign-in links can&#39;t be sent right now")
+	assert.Equal(t, len(mail.sent), 0)
+}
+
+func TestUserLoginMagic(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	link, err := app.urlSigner.Sign("/user/login/magic/link", url.Values{"uid": {"1"}}, magicLinkTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Following the link only shows the page which signs in, so that email
+	// scanners don't use it up.
+	var body string
+	for i := 0; i < 2; i++ {
+		var code int
+		code, _, body = ts.get(t, link)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='"+html.EscapeString(link)+"' method='POST'>")
+	}
+	form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+
+	code, headers, _ := ts.postForm(t, link, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/create")
+
+	code, _, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	// The link only works once...
+	ts.resetClient(t)
+	_, _, body = ts.get(t, "/user/login")
+	form.Set("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ = ts.postForm(t, link, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "That sign-in link is invalid, has expired or has already been used. Please ask for a new one.")
+
+	// ...and can't be made to sign in somebody else.
+	forged := strings.Replace(link, "uid=1", "uid=2", 1)
+	code, headers, _ = ts.get(t, forged)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+	code, headers, _ = ts.postForm(t, forged, form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	code, _, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+}
@@ -1,60 +1,300 @@
 package main
 
 import (
-	"crypto/tls"
 	"database/sql"
 	"flag"
+	"fmt"
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/ngohoang211020/snippetbox/internal/activitypub"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/changelog"
+	"github.com/ngohoang211020/snippetbox/internal/crypto"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/incidents"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
 	"github.com/ngohoang211020/snippetbox/internal/models"
-	"html/template"
+	"github.com/ngohoang211020/snippetbox/internal/perf"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"github.com/ngohoang211020/snippetbox/internal/ratelimit"
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type config struct {
-	addr      string
-	staticDir string
-	dsn       string
+	addrs         listenerList
+	adminAddrs    listenerList
+	proxyProtocol bool
+	tls           string
+	tlsSettings   tlsSettings
+	proxies       string
+	staticDir     string
+	baseURL       string
+
+	prerenderStatic bool
+	lazyTemplates   bool
+	compressAbove   int
+	contentLimits   validator.ContentLimits
+	snippetIDs      string
+	dsn             string
+	features        string
+	signupMode      string
+
+	notificationRetention int
+	sessionGCInterval     time.Duration
+
+	retention struct {
+		anonymousSnippetDays int
+		auditLogMonths       int
+		statsDetailDays      int
+		dryRun               bool
+	}
+
+	integrity struct {
+		interval time.Duration
+		repair   bool
+	}
+
+	encryptionKeysEnv string
+	urlSigningKeysEnv string
+
+	wellKnown struct {
+		dir              string
+		securityContacts stringList
+		securityPolicy   string
+	}
+
+	dbRetry dbRetry
+
+	dbBreaker struct {
+		threshold int
+		cooldown  time.Duration
+	}
+
+	cors corsConfig
+
+	ipRules struct {
+		admin string
+		login string
+	}
+
+	authBackend string
+
+	passwordHash struct {
+		scheme            string
+		bcryptCost        int
+		argon2Memory      uint
+		argon2Iterations  uint
+		argon2Parallelism uint
+	}
+
+	ldap struct {
+		url            string
+		startTLS       bool
+		bindDN         string
+		bindPassword   string
+		baseDN         string
+		userFilter     string
+		emailAttribute string
+		nameAttribute  string
+		groupAttribute string
+		adminGroups    []string
+	}
+
+	oidc struct {
+		issuer       string
+		clientID     string
+		clientSecret string
+		name         string
+		enforce      bool
+		provision    bool
+	}
+
+	webauthn struct {
+		rpID    string
+		origins stringList
+	}
+
+	apiRateLimit struct {
+		requestsPerHour int
+		burst           int
+	}
+
+	softRateLimit struct {
+		enabled       bool
+		halfLife      time.Duration
+		powDifficulty int
+	}
+
+	log struct {
+		format       string
+		output       string
+		accessOutput string
+		auditOutput  string
+		maxSize      int
+		maxAge       int
+		maxBackups   int
+		rotateEvery  time.Duration
+		compress     bool
+	}
+
+	incidents struct {
+		webhook   string
+		sentryDSN string
+		email     string
+	}
+
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+
+	sendGridAPIKey string
+
+	debug        bool
+	validateOnly bool
 }
 
 // Define an application struct to hold the application-wide dependencies for the
 // web application. For now we'll only include fields for the two custom loggers, but
 // we'll add more to it as the build progresses.
 type application struct {
-	debug          bool // Add a new debug field.
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       models.SnippetModelInterface // Use our new interface type.
-	users          models.UserModelInterface    // Use our new interface type.
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
-}
+	debug            bool // Add a new debug field.
+	errorLog         *log.Logger
+	infoLog          *log.Logger
+	accessLog        *logging.AccessLog
+	authEvents       *authevents.Recorder
+	snippets         models.SnippetModelInterface // Use our new interface type.
+	users            models.UserModelInterface    // Use our new interface type.
+	invitations      models.InvitationModelInterface
+	notifications    models.NotificationModelInterface
+	cspReports       models.CSPReportModelInterface
+	follows          models.FollowModelInterface
+	consumedTokens   models.ConsumedTokenModelInterface
+	passkeys         models.PasskeyModelInterface
+	drafts           models.DraftModelInterface
+	sessions         models.SessionModelInterface
+	orphans          models.IntegrityModelInterface
+	snippetTemplates models.SnippetTemplateModelInterface
+	collections      models.CollectionModelInterface
+	orgs             models.OrgModelInterface
+	webhooks         models.WebhookModelInterface
+	identities       models.IdentityModelInterface
+	preferences      models.PreferenceModelInterface
+	expiryPolicy     models.ExpiryPolicyModelInterface
+	contentLimits    validator.ContentLimits
+	apiTokens        models.APITokenModelInterface
+	webhookSender    *webhooks.Sender
+	webAuthn         *webauthn.WebAuthn
+	mailer           mailer.Sender
+	emailQueue       models.EmailQueueModelInterface
+	emails           *mailer.Templates
+	urlSigner        *signedurl.Signer
 
-func main() {
-	var cfg config
-	// Define a new command-line flag with the name 'addr', a default value of ":4000"
-	// and some short help text explaining what the flag controls. The value of the flag will be stored in the addr variable at runtime.
+	snippetStats  models.SnippetStatsModelInterface
+	apiRateLimits models.APIRateLimitModelInterface
+	apiLimiter    *ratelimit.Limiter
+	magicLimiter  *ratelimit.Limiter
+	softLimit     *softLimit
+	cors          corsConfig
+	ipRules       ipRules
 
-	flag.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
-	flag.StringVar(&cfg.staticDir, "static-dir", "./ui/static", "Path to static assets")
+	incidents         models.IncidentModelInterface
+	incidentNotifiers []incidents.Notifier
+	templateCache     *templateCache
+	formDecoder       *form.Decoder
+	sessionManager    *scs.SessionManager
+	sessionGC         *sessionGC
+	retention         *retentionPolicy
+	integrity         *integrityChecker
+	queries           *query.DB
+	requestTimes      *perf.Store
+	queryTimes        *perf.Store
+	dbHealth          *dbHealth
+	dbBreaker         *query.Breaker
+	staleCache        *staleCache
+	streamAfter       time.Duration
+	trustedProxies    *trustedProxies
+	// separateAdmin is set when there are admin listeners, which the
+	// operational routes are moved to; see adminRoutes.
+	separateAdmin   bool
+	wellKnownConfig *wellKnown
+	sso             *sso
+	authBackend     string
+	changelog       []changelog.Entry
+	features        *features.Flags
 
-	//use the parseTime=true parameter in our DSN to force it to convert TIME and DATE fields to time.Time. Otherwise it returns these as []byte objects
-	flag.StringVar(&cfg.dsn, "dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	// baseURL is where the site is reached from outside, for the links
+	// which need to be absolute wherever they're followed from. It's empty
+	// unless -base-url is set. siteURL is what absoluteURL builds links
+	// from: baseURL, or localSiteURL without one.
+	baseURL              string
+	siteURL              string
+	activityPub          models.ActivityPubModelInterface
+	activityPubKey       *activitypub.Key
+	activityPubPublicKey string
 
-	debug := flag.Bool("debug", false, "Enable debug model")
+	// started is when the server started, for the uptime on the about page,
+	// and instanceStats the statistics shown there, which are refreshed in
+	// the background.
+	started       time.Time
+	instanceStats atomic.Pointer[models.InstanceStats]
+}
 
-	// Use log.New() to create a logger for writing information messages. This takes three parameters: the destination to write the logs to (os.Stdout), a string prefix for message (INFO followed by a tab), and flags to indicate what additional information to include (local date and time). Note that the flags are joined using the bitwise OR operator |.
-	infoLog := log.New(os.Stderr, "INFO\t", log.Ldate|log.Ltime)
-	// Create a logger for writing error messages in the same way, but use stderr as the destination and use the log.Lshortfile flag to include the relevant file name and line number.
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+func main() {
+	// Subcommands are dispatched before the server's flags are parsed, as
+	// each of them has its own flags.
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := seedCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "recompress" {
+		if err := recompressCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "changelog" {
+		if err := changelogCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		if err := checkCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := benchCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var cfg config
+	cfg.registerFlags(flag.CommandLine)
 
-	formDecoder := form.NewDecoder()
 	// Importantly, we use the flag.Parse() function to parse the command-line flag.
 	// This reads in the command-line flag value and assigns it to the addr
 	// variable. You need to call this *before* you use the addr variable
@@ -62,77 +302,751 @@ func main() {
 	// encountered during parsing the application will be terminated
 	flag.Parse()
 
-	db, err := openDB(cfg.dsn)
+	// Every problem with the configuration is reported before giving up,
+	// rather than only the first one newApplication trips over.
+	results := validateConfig(cfg)
+	if cfg.validateOnly {
+		if writeCheckReport(os.Stdout, results) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	stderr := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+	if n := logConfigProblems(stderr, results); n > 0 {
+		stderr.Fatalf("%d problems with the configuration; run with -validate-only to check it without starting the server", n)
+	}
+
+	logs, err := openLogs(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logs.close()
+
+	infoLog, errorLog := logs.infoLog, logs.errorLog
+
+	app, closeDB, err := newApplication(cfg, logs)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
-	// We also defer a call to db.Close(), so that the connection pool is closed
-	// before the main() function exits.
-	defer db.Close()
+	defer closeDB()
+
+	// Prune old notifications once a day.
+	app.runPeriodically("prune notifications", 24*time.Hour, func() error {
+		n, err := app.notifications.DeleteOlderThan(cfg.notificationRetention)
+		if n > 0 {
+			app.infoLog.Printf("pruned %d notifications", n)
+		}
+		return err
+	})
+
+	app.runPeriodically("delete expired sessions", cfg.sessionGCInterval, func() error {
+		_, err := app.deleteExpiredSessions()
+		return err
+	})
+
+	// Forget the single-use links which have been used once they've
+	// expired, as they wouldn't be accepted anyway.
+	app.runPeriodically("delete expired consumed tokens", time.Hour, func() error {
+		_, err := app.consumedTokens.DeleteExpired()
+		return err
+	})
+
+	// Roll up the previous days' snippet views. Each day is only aggregated
+	// once it's over, so running this hourly is plenty.
+	app.runPeriodically("aggregate snippet stats", time.Hour, func() error {
+		_, err := app.snippetStats.Aggregate()
+		return err
+	})
+
+	// Scheduled snippets become visible as soon as they are due, but their
+	// owners are only notified when this runs.
+	app.runPeriodically("publish scheduled snippets", time.Minute, app.publishScheduledSnippets)
 
-	templateCache, err := newTemplateCache()
+	// Webhook deliveries are queued in the database as events happen, and
+	// sent from here so that slow receivers never hold up a request.
+	app.runPeriodically("deliver webhooks", 10*time.Second, app.deliverWebhooks)
+	app.runPeriodically("send emails", 10*time.Second, app.sendQueuedEmails)
+	app.runPeriodically("deliver activities", 10*time.Second, app.deliverActivities)
+
+	// New snippets get their metadata when they're saved. Older ones, and
+	// those saved by an older version of the pipeline, are filled in a
+	// batch at a time.
+	app.runPeriodically("fill in snippet metadata", time.Minute, func() error {
+		n, err := app.snippets.FillMetadata(100)
+		if n > 0 {
+			app.infoLog.Printf("filled in the metadata of %d snippets", n)
+		}
+		return err
+	})
+
+	// Likewise the public IDs of snippets saved before there were any,
+	// which can't be linked to until they have one.
+	app.runPeriodically("assign snippet public IDs", time.Minute, func() error {
+		n, err := app.snippets.AssignPublicIDs(100)
+		if n > 0 {
+			app.infoLog.Printf("gave %d snippets public IDs", n)
+		}
+		return err
+	})
+
+	// Suspended users are kept out by authenticate as soon as they're
+	// suspended; this only tidies up once the suspensions end.
+	app.runPeriodically("end suspensions", time.Minute, app.unsuspendExpired)
+
+	// Whatever the -retention-* policies say has been kept long enough is
+	// purged, or logged in a dry run.
+	app.runPeriodically("apply retention policies", time.Hour, func() error {
+		_, err := app.applyRetention()
+		return err
+	})
+
+	// The statistics on the about page count every snippet, so they're only
+	// counted now and then.
+	app.runPeriodically("refresh instance statistics", 10*time.Minute, app.refreshInstanceStats)
+
+	// Orphaned rows are reported to the admins, and deleted if
+	// -integrity-repair is set.
+	if cfg.integrity.interval > 0 {
+		app.runPeriodically("check integrity", cfg.integrity.interval, func() error {
+			_, err := app.checkIntegrity(cfg.integrity.repair)
+			return err
+		})
+	}
+
+	// The TLS settings were checked along with the rest of the
+	// configuration, so this only fails if a file has changed since.
+	tlsConfig, err := newTLSConfig(cfg.tlsSettings)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
+	app.logMiddleware()
+
+	srv := &http.Server{
+		ErrorLog:     errorLog,
+		Handler:      app.routes(),
+		TLSConfig:    tlsConfig,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	// The admin listeners have a server of their own, as their handler is
+	// different. CPU profiles and traces take 30 seconds by default, which
+	// the write timeout has to allow for.
+	adminSrv := &http.Server{
+		ErrorLog:     errorLog,
+		Handler:      app.adminRoutes(),
+		TLSConfig:    tlsConfig,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 2 * time.Minute,
+	}
+
+	var listeners, adminListeners []listenerConfig
+	for _, lc := range cfg.listeners() {
+		if lc.admin {
+			adminListeners = append(adminListeners, lc)
+		} else {
+			listeners = append(listeners, lc)
+		}
+	}
+
+	// The sockets systemd bound are used if it socket activated the server.
+	// Otherwise every address is bound before any is served, so that a
+	// mistake in one of them stops the server before it has started.
+	lns, activated, err := activatedListeners(listeners, cfg.proxyProtocol)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if lns != nil {
+		listeners = activated
+	} else {
+		lns = make([]net.Listener, len(listeners))
+		for i, lc := range listeners {
+			lns[i], err = newListener(lc.addr, cfg.proxyProtocol)
+			if err != nil {
+				errorLog.Fatal(err)
+			}
+		}
+	}
+
+	// The admin listeners are always bound here, and never behind a load
+	// balancer sending PROXY protocol headers.
+	for _, lc := range adminListeners {
+		ln, err := newListener(lc.addr, false)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		listeners = append(listeners, lc)
+		lns = append(lns, ln)
+	}
+
+	// The listeners share the servers and so the handlers, and the server
+	// stops as soon as any of them fails.
+	errs := make(chan error, len(lns))
+	for i, lc := range listeners {
+		server := srv
+		if lc.admin {
+			server = adminSrv
+			infoLog.Printf("Starting admin server on %s", lc.describe(lns[i]))
+		} else {
+			infoLog.Printf("Starting server on %s", lc.describe(lns[i]))
+		}
+		go func(server *http.Server, lc listenerConfig, ln net.Listener) {
+			errs <- serveListener(server, lc, ln)
+		}(server, lc, lns[i])
+	}
+	app.notifySystemd()
+	errorLog.Fatalln(<-errs)
+}
+
+// newApplication sets up the application from its configuration: it opens
+// the database and builds the models, templates and everything else the
+// handlers need. It doesn't start the background tasks or the server, so the
+// check command can use it too. The caller must call closeDB when it's done
+// with the application.
+func newApplication(cfg config, logs *logs) (app *application, closeDB func(), err error) {
+	infoLog, errorLog := logs.infoLog, logs.errorLog
+
+	if err := cfg.cors.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	proxies, err := loadTrustedProxies(cfg.listeners(), cfg.proxies)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encryptionKeys, err := loadEncryptionKeys(cfg.encryptionKeysEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if encryptionKeys == nil {
+		errorLog.Printf("$%s isn't set, so sensitive columns will be stored unencrypted", cfg.encryptionKeysEnv)
+	}
+
+	urlSigner, err := loadURLSigner(cfg.urlSigningKeysEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if urlSigner == nil {
+		errorLog.Printf("$%s isn't set, so the links in emails will stop working when the application restarts", cfg.urlSigningKeysEnv)
+		urlSigner, err = signedurl.NewRandom()
+		if err != nil {
+			return nil, nil, err
+		}
+		urlSigner.Skew = signedURLSkew
+	}
+
+	db, err := openDB(cfg.dsn, cfg.dbRetry, errorLog)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The models run their statements through queries, which prepares each
+	// one once and reuses it from then on.
+	queries := query.New(db, query.MySQL)
+
+	// closeDB closes the connection pool, which main defers until it exits.
+	closeDB = func() {
+		queries.Close()
+		db.Close()
+	}
+	defer func() {
+		if err != nil {
+			closeDB()
+		}
+	}()
+
+	queryTimes := newQueryTimes()
+	queries.Observe = observeQuery(queryTimes)
+
+	if cfg.dbBreaker.threshold > 0 {
+		queries.Breaker = query.NewBreaker(cfg.dbBreaker.threshold, cfg.dbBreaker.cooldown)
+		queries.Breaker.Ignore = ignoreMySQLError
+	}
+
+	featureOverrides, err := features.Parse(cfg.features)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.signupMode != "" {
+		modeFlags, err := features.SignupModeFlags(cfg.signupMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, enabled := range modeFlags {
+			featureOverrides[name] = enabled
+		}
+	}
+
+	// Values toggled from the admin UI are persisted in the database and take
+	// precedence over the command-line overrides.
+	featureFlags := features.New(&models.FeatureModel{DB: queries}, featureOverrides)
+	if err = featureFlags.Load(); err != nil {
+		return nil, nil, err
+	}
+
+	formDecoder := form.NewDecoder()
+
+	templateCache, err := loadTemplateCache(infoLog, cfg.lazyTemplates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.prerenderStatic {
+		if err = prerenderStaticPages(templateCache); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	changelogEntries, err := loadChangelog()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Use the scs.New() function to initialize a new session manager. Then we
 	// configure it to use our MySQL database as the session store, and set a
 	// lifetime of 12 hours (so that sessions automatically expire 12 hours
 	// after first being created).
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
+	// Expired sessions are deleted by our own background task rather than
+	// the store's, so that admins can see how it's doing.
+	sessionManager.Store = breakerStore{Store: mysqlstore.NewWithCleanupInterval(db, 0), breaker: queries.Breaker}
 	sessionManager.Cookie.SameSite = http.SameSiteStrictMode
+	// Browsers only ever talk to us over HTTPS, whether it's terminated here
+	// or by a proxy, so the session cookie never needs to travel without it.
+	sessionManager.Cookie.Secure = true
 	sessionManager.Lifetime = 12 * time.Minute
 
+	// Without SendGrid or an SMTP server emails are written to the info log,
+	// which is handy in development.
+	var mail mailer.Sender = &mailer.Log{Logger: infoLog}
+	switch {
+	case cfg.sendGridAPIKey != "":
+		mail = &mailer.SendGrid{APIKey: cfg.sendGridAPIKey, From: cfg.smtp.sender}
+	case cfg.smtp.host != "":
+		mail = &mailer.SMTP{
+			Host:     cfg.smtp.host,
+			Port:     cfg.smtp.port,
+			Username: cfg.smtp.username,
+			Password: cfg.smtp.password,
+			From:     cfg.smtp.sender,
+		}
+	}
+
+	// Emails are queued and sent by a background job, so that a slow or
+	// failing mail provider never holds up a request.
+	emailQueue := &models.EmailQueueModel{DB: queries, Keys: encryptionKeys}
+
+	emails, err := mailer.NewTemplates()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	incidentNotifiers, err := newIncidentNotifiers(cfg, &queuedMailer{queue: emailQueue})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	webAuthn, err := newWebAuthn(cfg.webauthn.rpID, cfg.webauthn.origins)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ipRules, err := loadIPRules(cfg.ipRules.admin, cfg.ipRules.login)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wellKnown, err := newWellKnown(cfg.wellKnown.dir, cfg.wellKnown.securityContacts, cfg.wellKnown.securityPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	users, err := newUserModel(cfg, queries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	baseURL, err := loadBaseURL(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	siteURL := baseURL
+	if siteURL == "" {
+		siteURL = localSiteURL(cfg.listeners()[0])
+		errorLog.Printf("-base-url isn't set, so links which leave the site, like those in feeds, point at %s", siteURL)
+	}
+
+	retention, err := newRetentionPolicy(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snippetIDs, err := publicid.Parse(cfg.snippetIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	singleSignOn, err := newSSO(cfg.oidc.issuer, cfg.oidc.clientID, cfg.oidc.clientSecret, cfg.oidc.name, cfg.oidc.enforce, cfg.oidc.provision)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Initialize a new instance of our application struct, containing the dependencies.
-	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-		debug:          *debug,
-	}
-
-	// Initialize a tls.Config struct to hold the non-default TLS settings we
-	// want the server to use. In this case the only thing that we're changing
-	// is the curve preferences value, so that only elliptic curves with
-	// assembly implementations are used.
-	tlsConfig := &tls.Config{
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-		},
+	app = &application{
+		started:          time.Now(),
+		errorLog:         errorLog,
+		infoLog:          infoLog,
+		accessLog:        logs.accessLog,
+		authEvents:       logs.authEvents,
+		snippets:         &models.SnippetModel{DB: queries, CompressAbove: cfg.compressAbove, IDs: snippetIDs},
+		users:            users,
+		invitations:      &models.InvitationModel{DB: queries},
+		notifications:    &models.NotificationModel{DB: queries},
+		cspReports:       &models.CSPReportModel{DB: queries},
+		follows:          &models.FollowModel{DB: queries},
+		consumedTokens:   &models.ConsumedTokenModel{DB: queries},
+		passkeys:         &models.PasskeyModel{DB: queries},
+		drafts:           &models.DraftModel{DB: queries},
+		sessions:         &models.SessionModel{DB: queries},
+		orphans:          &models.IntegrityModel{DB: queries},
+		snippetTemplates: &models.SnippetTemplateModel{DB: queries},
+		collections:      &models.CollectionModel{DB: queries},
+		orgs:             &models.OrgModel{DB: queries},
+		webhooks:         &models.WebhookModel{DB: queries, Keys: encryptionKeys},
+		identities:       &models.IdentityModel{DB: queries},
+		preferences:      &models.PreferenceModel{DB: queries},
+		expiryPolicy:     &models.ExpiryPolicyModel{DB: queries},
+		contentLimits:    cfg.contentLimits,
+		apiTokens:        &models.APITokenModel{DB: queries},
+		webhookSender:    webhooks.NewSender(10 * time.Second),
+		webAuthn:         webAuthn,
+		mailer:           mail,
+		emailQueue:       emailQueue,
+		emails:           emails,
+		urlSigner:        urlSigner,
+
+		snippetStats:  &models.SnippetStatsModel{DB: queries},
+		apiRateLimits: &models.APIRateLimitModel{DB: queries},
+		cors:          cfg.cors,
+		ipRules:       ipRules,
+		apiLimiter:    ratelimit.New(float64(cfg.apiRateLimit.requestsPerHour)/3600, cfg.apiRateLimit.burst),
+		magicLimiter:  newMagicLinkLimiter(),
+
+		incidents:         &models.IncidentModel{DB: queries},
+		incidentNotifiers: incidentNotifiers,
+		templateCache:     templateCache,
+		formDecoder:       formDecoder,
+		sessionManager:    sessionManager,
+		sessionGC:         &sessionGC{Interval: cfg.sessionGCInterval},
+		retention:         retention,
+		integrity:         &integrityChecker{Interval: cfg.integrity.interval, Repair: cfg.integrity.repair},
+		queries:           queries,
+		dbHealth:          &dbHealth{db: db, retry: cfg.dbRetry},
+		dbBreaker:         queries.Breaker,
+		staleCache:        newStaleCache(500, 1<<20),
+		requestTimes:      newRequestTimes(),
+		queryTimes:        queryTimes,
+		streamAfter:       defaultStreamAfter,
+		trustedProxies:    proxies,
+		separateAdmin:     len(cfg.adminAddrs) > 0,
+		wellKnownConfig:   wellKnown,
+		sso:               singleSignOn,
+		authBackend:       cfg.authBackend,
+		changelog:         changelogEntries,
+		features:          featureFlags,
+		debug:             cfg.debug,
+		baseURL:           baseURL,
+		siteURL:           siteURL,
+		activityPub:       &models.ActivityPubModel{DB: queries, Keys: encryptionKeys},
 	}
 
-	srv := &http.Server{
-		Addr:         cfg.addr,
-		ErrorLog:     errorLog,
-		Handler:      app.routes(),
-		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+	// The ActivityPub actor is published under the base URL, so there's
+	// only a key for it once there's one.
+	if baseURL != "" {
+		app.activityPubKey, app.activityPubPublicKey, err = loadActivityPubKey(app.activityPub, app.actorURL()+"#main-key")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.softRateLimit.enabled {
+		app.softLimit = newSoftLimit(cfg.softRateLimit.halfLife, cfg.softRateLimit.powDifficulty)
+	}
+
+	app.reloadIPRulesOnSIGHUP()
+
+	if app.dbBreaker != nil {
+		app.dbBreaker.OnChange = app.logBreakerChange
 	}
+	// The session is loaded from the database too, so failing to load it
+	// gets the same error page as any other database error.
+	sessionManager.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		app.serverError(w, err)
+	}
+
+	return app, closeDB, nil
+}
+
+// registerFlags defines the server's command-line flags on fs, storing their
+// values in cfg. The check command accepts the same flags as the server.
+func (cfg *config) registerFlags(fs *flag.FlagSet) {
+	// Define a new command-line flag with the name 'addr'. It can be repeated
+	// to listen on several addresses, and ":4000" is used if it isn't given.
+	fs.Var(&cfg.addrs, "addr", `Network address to listen on, like ":4000", "[::1]:4443" or unix:/path/to.sock for a Unix domain socket (default ":4000"). Repeat to listen on several; follow an address with ",tls=app|proxy,cert=FILE,key=FILE" to override -tls for it, or ",client-auth=optional|require" to check client certificates on it against -tls-client-ca`)
+	fs.Var(&cfg.adminAddrs, "admin-addr", `Network address for the admin pages, /metrics and /debug/pprof, like "127.0.0.1:4001", which then aren't served on -addr. It takes the same options as -addr, like ",client-auth=require"`)
+	fs.StringVar(&cfg.tls, "tls", tlsApp, `Where TLS is terminated: "app" serves HTTPS using ./tls/cert.pem and ./tls/key.pem, "proxy" serves plain HTTP behind a reverse proxy`)
+	fs.StringVar(&cfg.tlsSettings.minVersion, "tls-min-version", "", `Oldest TLS version to accept, "1.2" or "1.3" (default "1.2"; 1.3 is used whenever the client supports it)`)
+	fs.StringVar(&cfg.tlsSettings.cipherSuites, "tls-cipher-suites", "", "Comma-separated TLS 1.2 cipher suites, like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (default forward-secret AEAD suites only)")
+	fs.StringVar(&cfg.tlsSettings.curves, "tls-curves", "", "Comma-separated elliptic curves for key exchange, in order of preference, from X25519, P256, P384 and P521 (default \"X25519,P256\")")
+	fs.StringVar(&cfg.tlsSettings.clientCAFile, "tls-client-ca", "", "PEM file of the certificate authorities which sign client certificates, for listeners with client-auth")
+	fs.StringVar(&cfg.proxies, "trusted-proxies", "", "Comma-separated IP addresses and CIDR ranges of reverse proxies whose X-Forwarded-Proto header is trusted")
+	fs.BoolVar(&cfg.proxyProtocol, "proxy-protocol", false, "Require a PROXY protocol header on TCP connections (for use behind an L4 load balancer)")
+	fs.StringVar(&cfg.staticDir, "static-dir", "./ui/static", "Path to static assets")
+	fs.StringVar(&cfg.baseURL, "base-url", "", "Public URL of the site, like https://snippets.example.com, for links followed from elsewhere, such as those in feeds (ActivityPub is off and such links point at localhost if this is empty; needed for -oidc-issuer, -smtp-host and -sendgrid-api-key)")
+	fs.BoolVar(&cfg.prerenderStatic, "prerender-static", true, "Render the static parts of pages like /about once at startup, rather than on every request")
+	fs.BoolVar(&cfg.lazyTemplates, "lazy-templates", false, "Compile each page's templates the first time it's rendered rather than at startup, so that the server starts sooner (a broken template then only shows up when its page is rendered)")
+	fs.StringVar(&cfg.wellKnown.dir, "well-known-dir", "", "Directory of extra files to serve under /.well-known/")
+	fs.Var(&cfg.wellKnown.securityContacts, "security-contact", "Where to report security issues, for /.well-known/security.txt: email addresses or mailto:, https: or tel: URIs, comma-separated or repeated")
+	fs.StringVar(&cfg.wellKnown.securityPolicy, "security-policy", "", "URL of the security policy linked from /.well-known/security.txt")
+
+	//use the parseTime=true parameter in our DSN to force it to convert TIME and DATE fields to time.Time. Otherwise it returns these as []byte objects
+	fs.StringVar(&cfg.dsn, "dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	fs.IntVar(&cfg.compressAbove, "compress-snippets-above", models.DefaultCompressAbove, "Store snippet contents larger than this many bytes compressed (-1 turns compression off; run \"web recompress\" to apply a new threshold to existing snippets)")
+	fs.IntVar(&cfg.contentLimits.MaxBytes, "max-snippet-bytes", validator.DefaultContentLimits.MaxBytes, "Largest file a snippet can have, in bytes, after line endings and trailing spaces are tidied up (0 for no limit)")
+	fs.StringVar(&cfg.snippetIDs, "snippet-ids", string(publicid.Default), "Kind of public ID given to new snippets for their URLs: uuidv7 or ulid (both kinds are always accepted)")
+	fs.IntVar(&cfg.contentLimits.MaxLines, "max-snippet-lines", validator.DefaultContentLimits.MaxLines, "Most lines a snippet's file can have (0 for no limit)")
+	fs.DurationVar(&cfg.dbRetry.initialDelay, "db-retry-delay", 500*time.Millisecond, "How long to wait before retrying when the database isn't ready; the wait doubles after each attempt")
+	fs.DurationVar(&cfg.dbRetry.maxDelay, "db-retry-max-delay", 15*time.Second, "Longest wait between attempts to reach the database")
+	fs.DurationVar(&cfg.dbRetry.maxWait, "db-max-wait", time.Minute, "How long to keep trying to reach the database before giving up")
+	fs.IntVar(&cfg.dbBreaker.threshold, "db-breaker-threshold", 5, "Database errors in a row after which the site stops querying it for a while and answers with 503s (0 turns the circuit breaker off)")
+	fs.DurationVar(&cfg.dbBreaker.cooldown, "db-breaker-cooldown", 30*time.Second, "How long the database circuit breaker stays open before trying the database again")
+
+	fs.StringVar(&cfg.features, "features", "", "Comma-separated feature flag overrides (e.g. \"api_enabled=false,signup_open\")")
+
+	fs.StringVar(&cfg.signupMode, "signup-mode", "", "Signup mode: open, closed or invite (overrides -features)")
+
+	fs.DurationVar(&cfg.sessionGCInterval, "session-gc-interval", 5*time.Minute, "How often to delete expired sessions from the database")
+	fs.IntVar(&cfg.notificationRetention, "notification-retention", 90, "Number of days to keep notifications for")
+	fs.IntVar(&cfg.retention.anonymousSnippetDays, "retention-anonymous-snippets", 0, "Delete snippets without an owner this many days after they're created, even if they haven't expired (0 keeps them until they expire)")
+	fs.IntVar(&cfg.retention.auditLogMonths, "retention-audit-log", 0, "Delete rotated audit log files this many months after they're rotated; needs -audit-log-output to name a file of its own (0 leaves them to -log-max-age)")
+	fs.IntVar(&cfg.retention.statsDetailDays, "retention-stats-detail", 0, "Drop the referrer, country and browser breakdowns of snippet views after this many days, keeping the daily totals (0 keeps them)")
+	fs.BoolVar(&cfg.retention.dryRun, "retention-dry-run", false, "Log what the -retention-* policies would purge, without purging anything")
+	fs.DurationVar(&cfg.integrity.interval, "integrity-interval", 24*time.Hour, "How often to look for orphaned rows, such as notifications and sessions of users who no longer exist (0 only looks when an admin asks)")
+	fs.BoolVar(&cfg.integrity.repair, "integrity-repair", false, "Delete the orphaned rows which the scheduled integrity check finds, rather than only reporting them")
+
+	fs.IntVar(&cfg.apiRateLimit.requestsPerHour, "api-rate-limit", 1000, "Default number of JSON API requests allowed per hour for each user or anonymous client")
+	fs.IntVar(&cfg.apiRateLimit.burst, "api-rate-burst", 100, "Default number of JSON API requests allowed in a burst")
+	fs.BoolVar(&cfg.softRateLimit.enabled, "soft-rate-limit", true, "Slow down, challenge and then temporarily block clients which fail to log in or create accounts and snippets rapidly")
+	fs.DurationVar(&cfg.softRateLimit.halfLife, "soft-rate-limit-half-life", 10*time.Minute, "How long it takes for a suspicious client's score to halve")
+	fs.IntVar(&cfg.softRateLimit.powDifficulty, "soft-rate-limit-pow-difficulty", 16, "Number of leading zero bits in the proof-of-work challenges which challenged browsers solve; each one more doubles the work")
+
+	fs.Var(&cfg.cors.trustedOrigins, "cors-trusted-origins", "Origins allowed to call the JSON API from the browser, comma-separated or repeated (\"*\" allows any)")
+	fs.BoolVar(&cfg.cors.allowCredentials, "cors-allow-credentials", false, "Let trusted origins send the session cookie with JSON API requests")
+
+	fs.StringVar(&cfg.encryptionKeysEnv, "encryption-keys-env", "SNIPPETBOX_ENCRYPTION_KEYS", "Environment variable holding the keys for encrypting sensitive database columns, as comma-separated id:base64-key pairs (newest first)")
+	fs.StringVar(&cfg.urlSigningKeysEnv, "url-signing-keys-env", "SNIPPETBOX_URL_SIGNING_KEYS", "Environment variable holding the keys for signing the links in emails, as comma-separated id:base64-key pairs (newest first)")
+
+	fs.StringVar(&cfg.ipRules.admin, "admin-ip-rules", "", "File of IP allow/deny rules for the admin pages (reloaded on SIGHUP)")
+	fs.StringVar(&cfg.ipRules.login, "login-ip-rules", "", "File of IP allow/deny rules for signing in (reloaded on SIGHUP)")
+
+	fs.StringVar(&cfg.webauthn.rpID, "webauthn-rp-id", "localhost", "Domain which passkeys are registered for")
+	fs.Var(&cfg.webauthn.origins, "webauthn-origins", "Origins which passkeys can be used from, comma-separated or repeated (defaults to "+defaultWebAuthnOrigin+")")
+
+	fs.StringVar(&cfg.authBackend, "auth-backend", authLocal, `Where passwords are checked: "local" uses the users table, "ldap" uses an LDAP directory (admins' local passwords still work as a fallback)`)
+	fs.StringVar(&cfg.passwordHash.scheme, "password-hash", hashBcrypt, `How new passwords are hashed: "bcrypt" or "argon2id" (passwords hashed otherwise are hashed again when their owners sign in)`)
+	fs.IntVar(&cfg.passwordHash.bcryptCost, "bcrypt-cost", 12, "Cost of bcrypt password hashes; each one more doubles the work")
+	fs.UintVar(&cfg.passwordHash.argon2Memory, "argon2-memory", 64*1024, "Memory used by Argon2id password hashing, in KiB")
+	fs.UintVar(&cfg.passwordHash.argon2Iterations, "argon2-iterations", 3, "Number of passes Argon2id password hashing makes over its memory")
+	fs.UintVar(&cfg.passwordHash.argon2Parallelism, "argon2-parallelism", 2, "Number of threads used by Argon2id password hashing")
+	fs.StringVar(&cfg.ldap.url, "ldap-url", "", "LDAP server, as ldap://host:port or ldaps://host:port")
+	fs.BoolVar(&cfg.ldap.startTLS, "ldap-start-tls", false, "Upgrade ldap:// connections with StartTLS")
+	fs.StringVar(&cfg.ldap.bindDN, "ldap-bind-dn", "", "DN of the service account used to search for users (empty for anonymous search)")
+	fs.StringVar(&cfg.ldap.bindPassword, "ldap-bind-password", "", "Password of the LDAP service account")
+	fs.StringVar(&cfg.ldap.baseDN, "ldap-base-dn", "", "DN to search for users under")
+	fs.StringVar(&cfg.ldap.userFilter, "ldap-user-filter", "(mail={login})", "LDAP filter finding the user who signs in, with {login} replaced by what they typed as their email")
+	fs.StringVar(&cfg.ldap.emailAttribute, "ldap-email-attribute", "mail", "LDAP attribute holding users' email addresses")
+	fs.StringVar(&cfg.ldap.nameAttribute, "ldap-name-attribute", "displayName", "LDAP attribute holding users' names")
+	fs.StringVar(&cfg.ldap.groupAttribute, "ldap-group-attribute", "memberOf", "LDAP attribute listing the groups a user is a member of")
+	// DNs contain commas, so the list is separated by semicolons.
+	fs.Func("ldap-admin-groups", "DNs of LDAP groups whose members are admins, separated by semicolons or repeated (roles are managed locally if empty)", func(value string) error {
+		for _, dn := range strings.Split(value, ";") {
+			if dn = strings.TrimSpace(dn); dn != "" {
+				cfg.ldap.adminGroups = append(cfg.ldap.adminGroups, dn)
+			}
+		}
+		return nil
+	})
+
+	fs.StringVar(&cfg.oidc.issuer, "oidc-issuer", "", "Issuer URL of an OpenID Connect provider to sign in with (single sign-on is off if this is empty)")
+	fs.StringVar(&cfg.oidc.clientID, "oidc-client-id", "", "Client ID registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.oidc.clientSecret, "oidc-client-secret", "", "Client secret registered with the OpenID Connect provider")
+	fs.StringVar(&cfg.oidc.name, "oidc-name", "", "Name of the OpenID Connect provider, for the sign-in button")
+	fs.BoolVar(&cfg.oidc.enforce, "oidc-enforce", false, "Only allow signing in with the OpenID Connect provider, turning off passwords, sign-in links, passkeys and signup")
+	fs.BoolVar(&cfg.oidc.provision, "oidc-provision", true, "Create accounts for people who sign in with the OpenID Connect provider and don't have one yet")
 
-	infoLog.Printf("Starting server on %s", cfg.addr)
-	// Call the ListenAndServe() method on our new http.Server struct.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatalln(err)
+	fs.StringVar(&cfg.smtp.host, "smtp-host", "", "SMTP server host (emails are only logged if this is empty)")
+	fs.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP server port")
+	fs.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	fs.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.smtp.sender, "smtp-sender", "Snippetbox <no-reply@snippetbox.example>", "Sender address for emails")
+	fs.StringVar(&cfg.sendGridAPIKey, "sendgrid-api-key", "", "Send emails through the SendGrid API with this key, instead of SMTP")
+
+	fs.StringVar(&cfg.incidents.webhook, "incident-webhook", "", "URL to post panic reports to as JSON")
+	fs.StringVar(&cfg.incidents.sentryDSN, "incident-sentry-dsn", "", "Sentry DSN to send panic reports to")
+	fs.StringVar(&cfg.incidents.email, "incident-email", "", "Comma-separated email addresses to send panic reports to")
+
+	fs.StringVar(&cfg.log.format, "log-format", "human", "Log format: human or json")
+	fs.StringVar(&cfg.log.output, "log-output", "stdout", "Where to write the application log: stdout, stderr or a file path")
+	fs.StringVar(&cfg.log.accessOutput, "access-log-output", "", "Where to write the access log: stdout, stderr or a file path (defaults to -log-output)")
+	fs.StringVar(&cfg.log.auditOutput, "audit-log-output", "", "Where to write the audit log of sign-ins, sign-outs and password changes: stdout, stderr or a file path (defaults to -log-output)")
+	fs.IntVar(&cfg.log.maxSize, "log-max-size", 100, "Rotate log files once they reach this many megabytes")
+	fs.IntVar(&cfg.log.maxAge, "log-max-age", 0, "Delete rotated log files after this many days (0 keeps them)")
+	fs.IntVar(&cfg.log.maxBackups, "log-max-backups", 0, "Number of rotated log files to keep (0 keeps them all)")
+	fs.DurationVar(&cfg.log.rotateEvery, "log-rotate-every", 0, "Also rotate log files at this interval, e.g. 24h (0 disables)")
+	fs.BoolVar(&cfg.log.compress, "log-compress", true, "Gzip rotated log files")
+
+	fs.BoolVar(&cfg.debug, "debug", false, "Enable debug model")
+	fs.BoolVar(&cfg.validateOnly, "validate-only", false, "Check the configuration, print a report and exit without starting the server (exits with status 1 if anything is wrong)")
+}
+
+// logs holds the application's loggers and the outputs they write to.
+type logs struct {
+	infoLog    *log.Logger
+	errorLog   *log.Logger
+	accessLog  *logging.AccessLog
+	authEvents *authevents.Recorder
+	outputs    []io.Closer
+}
+
+// openLogs opens the outputs configured by the -log-* flags and creates the
+// loggers which write to them. The access and audit logs share the
+// application log's output unless -access-log-output or -audit-log-output
+// names a different one.
+func openLogs(cfg config) (*logs, error) {
+	format, err := logging.ParseFormat(cfg.log.format)
+	if err != nil {
+		return nil, err
+	}
+
+	output := logging.Output{
+		Path:        cfg.log.output,
+		MaxSize:     cfg.log.maxSize,
+		MaxAge:      cfg.log.maxAge,
+		MaxBackups:  cfg.log.maxBackups,
+		RotateEvery: cfg.log.rotateEvery,
+		Compress:    cfg.log.compress,
+	}
+
+	w, err := logging.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	l := &logs{outputs: []io.Closer{w}}
+
+	// Opening the same file twice would have two rotators fighting over it,
+	// so each path is only opened once.
+	opened := map[string]io.Writer{cfg.log.output: w}
+	openOutput := func(path string) (io.Writer, error) {
+		if path == "" {
+			return w, nil
+		}
+		if ow, ok := opened[path]; ok {
+			return ow, nil
+		}
+		output.Path = path
+		ow, err := logging.Open(output)
+		if err != nil {
+			return nil, err
+		}
+		l.outputs = append(l.outputs, ow)
+		opened[path] = ow
+		return ow, nil
+	}
+
+	accessW, err := openOutput(cfg.log.accessOutput)
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+	auditW, err := openOutput(cfg.log.auditOutput)
+	if err != nil {
+		l.close()
+		return nil, err
+	}
+
+	l.infoLog = logging.New(w, format, "INFO", false)
+	l.errorLog = logging.New(w, format, "ERROR", true)
+	l.accessLog = logging.NewAccessLog(accessW, format)
+	l.authEvents = authevents.New(auditW, format)
+
+	return l, nil
+}
+
+func (l *logs) close() {
+	for _, c := range l.outputs {
+		c.Close()
+	}
+}
+
+// newIncidentNotifiers sets up the notifiers for panic reports which are
+// enabled by the -incident-* flags.
+func newIncidentNotifiers(cfg config, mail mailer.Sender) ([]incidents.Notifier, error) {
+	var notifiers []incidents.Notifier
+
+	if cfg.incidents.webhook != "" {
+		notifiers = append(notifiers, &incidents.Webhook{URL: cfg.incidents.webhook})
+	}
+
+	if cfg.incidents.sentryDSN != "" {
+		sentry, err := incidents.NewSentry(cfg.incidents.sentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, sentry)
+	}
+
+	if cfg.incidents.email != "" {
+		var to []string
+		for _, addr := range strings.Split(cfg.incidents.email, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		notifiers = append(notifiers, &incidents.Email{Mailer: mail, To: to})
+	}
+
+	return notifiers, nil
+}
+
+// loadEncryptionKeys parses the encryption keys in the named environment
+// variable. It returns nil if the variable isn't set. A key can be generated
+// with "openssl rand -base64 32".
+func loadEncryptionKeys(env string) (*crypto.Keyring, error) {
+	s := os.Getenv(env)
+	if s == "" {
+		return nil, nil
+	}
+
+	keys, err := crypto.ParseKeys(s)
+	if err != nil {
+		return nil, fmt.Errorf("$%s: %w", env, err)
+	}
+	return keys, nil
 }
 
-func openDB(dsn string) (*sql.DB, error) {
+// openDB opens the connection pool and waits for the database to answer,
+// which it may not do straight away when both are started together, as they
+// are with docker compose.
+func openDB(dsn string, retry dbRetry, errorLog *log.Logger) (*sql.DB, error) {
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
+	if err = waitForDB(db, retry, errorLog); err != nil {
+		db.Close()
 		return nil, err
 	}
 	return db, nil
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// metric is one value in the Prometheus text format.
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value float64
+}
+
+// metrics serves the application's metrics in the Prometheus text format.
+// Like /ready it's meant for monitoring systems, which can't sign in, so it
+// isn't behind authentication.
+func (app *application) metrics(w http.ResponseWriter, r *http.Request) {
+	breaker := app.dbBreaker.Stats()
+
+	metrics := []metric{
+		{"snippetbox_db_breaker_state", "gauge", "State of the database circuit breaker: 0 closed, 1 open, 2 half-open.", float64(breaker.State)},
+		{"snippetbox_db_breaker_failures", "gauge", "Database errors in a row.", float64(breaker.Failures)},
+		{"snippetbox_db_breaker_trips_total", "counter", "Times the database circuit breaker has opened.", float64(breaker.Trips)},
+		{"snippetbox_stale_pages", "gauge", "Pages kept to show while the database is unavailable.", float64(app.staleCache.len())},
+	}
+
+	if app.queries != nil {
+		stats := app.queries.Stats()
+		metrics = append(metrics,
+			metric{"snippetbox_db_queries_total", "counter", "Statements run against the database.", float64(stats.Queries)},
+			metric{"snippetbox_db_prepares_total", "counter", "Statements prepared.", float64(stats.Prepares)},
+			metric{"snippetbox_db_prepared_statements", "gauge", "Prepared statements in the cache.", float64(stats.Statements)},
+		)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, metrics)
+	writeAuthEventMetrics(w, app.authEvents.Counts())
+	writeIntegrityMetrics(w, app.integrity.status())
+}
+
+func writeMetrics(w io.Writer, metrics []metric) {
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
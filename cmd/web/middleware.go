@@ -1,35 +1,133 @@
 package main
 
 import (
-	"context"
-	"fmt"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"github.com/justinas/nosurf"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/ratelimit"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Note: This is split across multiple lines for readability. You don't
 		// need to do this in your own code.
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		//
+		// Violations are reported to our /csp-report endpoint, using both the
+		// older report-uri directive and its report-to replacement, which
+		// refers to the endpoint named in the Reporting-Endpoints header.
+		//
+		// Inline scripts are only allowed if they carry the request's nonce.
+		csp := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; "
+		if nonce := reqctx.CSPNonce(r.Context()); nonce != "" {
+			csp += "script-src 'self' 'nonce-" + nonce + "'; "
+		}
+		w.Header().Set("Content-Security-Policy", csp+"report-uri /csp-report; report-to csp-endpoint")
+		w.Header().Set("Reporting-Endpoints", `csp-endpoint="/csp-report"`)
 
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
 		w.Header().Set("X-XSS-Protection", "0")
 
+		// Only tell browsers to stick to HTTPS if they're already using it;
+		// the header is ignored over plain HTTP anyway.
+		if isHTTPS(r) {
+			w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// requestContext sets up the values which describe the request in the
+// request context; see the reqctx package. Each request gets a random ID,
+// which is sent back in the X-Request-ID header and included in logs and
+// panic reports so that they can be matched up. The authenticated user is
+// filled in later, by authenticate.
+func (app *application) requestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 24)
+		rand.Read(b)
+
+		v := &reqctx.Values{
+			RequestID: hex.EncodeToString(b[:8]),
+			ClientIP:  remoteIP(r),
+			HTTPS:     app.servedOverHTTPS(r),
+			Locale:    preferredLocale(r),
+			CSPNonce:  base64.RawURLEncoding.EncodeToString(b[8:]),
+		}
+		w.Header().Set("X-Request-ID", v.RequestID)
+
+		next.ServeHTTP(w, r.WithContext(reqctx.New(r.Context(), v)))
+	})
+}
+
+// logRequest writes an entry to the access log for each request once it has
+// been handled, with the status and size of the response.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rec, r)
+
+		// Handlers which don't write anything send an empty 200 response.
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		app.accessLog.Log(logging.AccessEntry{
+			Time:       start,
+			RequestID:  reqctx.RequestID(r.Context()),
+			RemoteAddr: r.RemoteAddr,
+			Proto:      r.Proto,
+			Method:     r.Method,
+			URI:        r.URL.RequestURI(),
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			Duration:   time.Since(start),
+		})
 	})
 }
+
+// responseRecorder records the status code and number of bytes of a
+// response for the access log.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Create a deferred function (which will always be run in the event
@@ -40,9 +138,9 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 			if err := recover(); err != nil {
 				// Set a "Connection: close" header on the response.
 				w.Header().Set("Connection", "close")
-				// Call the app.serverError helper method to return a 500
-				// Internal Server response.
-				app.serverError(w, fmt.Errorf("%s", err))
+				// Record the panic as an incident and return a 500
+				// Internal Server response with its reference code.
+				app.reportPanic(w, r, err, debug.Stack())
 			}
 		}()
 
@@ -55,9 +153,11 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		// If the user is not authenticated, redirect them to the login page and
 		// return from the middleware chain so that no subsequent handlers in
 		// the chain are executed.
+		// Once they have logged in they are sent back to where they were
+		// going.
 		if !app.isAuthenticated(r) {
-			app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.Path)
-			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			app.rememberLoginRedirect(r, loginRedirectTarget(r))
+			http.Redirect(w, r, urlFor("user.login"), http.StatusSeeOther)
 			return
 		}
 
@@ -86,11 +186,16 @@ func noSurf(next http.Handler) http.Handler {
 
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Retrieve the authenticatedUserID value from the session using the
-		// GetInt() method. This will return the zero value for an int (0) if no
+		// Retrieve the authenticatedUserID value from the session, or the
+		// admin's ID if their impersonation of the user has expired. This
+		// will return the zero value for an int (0) if no
 		// "authenticatedUserID" value is in the session -- in which case we
 		// call the next handler in the chain as normal and return.
-		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		id, err := app.sessionUserID(r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
@@ -98,22 +203,146 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// Otherwise, we check to see if a user with that ID exists in our
 		// database.
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, err)
 			return
 		}
 
+		// Suspended users are signed out as soon as they're suspended,
+		// rather than when their session ends.
+		if user != nil && user.Suspended(time.Now()) {
+			app.renderSuspended(w, r, user)
+			return
+		}
+
 		// If a matching user is found, we know we know that the request is
-		// coming from an authenticated user who exists in our database. We
-		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
-		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+		// coming from an authenticated user who exists in our database, and
+		// record their ID in the request context.
+		if user != nil {
+			reqctx.SetUserID(r.Context(), id)
 		}
 
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireAdmin only lets requests from users with the admin role through. It
+// must be used after requireAuthentication.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := reqctx.UserID(r.Context())
+
+		user, err := app.users.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.clientError(w, http.StatusForbidden)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		if !user.IsAdmin {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRequireAuthentication is the JSON API equivalent of requireAuthentication.
+// API requests which change state must also declare a JSON body: together
+// with the SameSite=Strict session cookie this stops other sites from using
+// a visitor's session to make requests on their behalf.
+func (app *application) apiRequireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiErrorResponse(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		if !isJSONRequest(r) {
+			app.apiErrorResponse(w, http.StatusUnsupportedMediaType, "the request body must be application/json")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireFeature returns a middleware which responds with a 404 Not Found
+// unless the named feature flag is enabled, so that routes behind a disabled
+// flag look as though they don't exist at all.
+func (app *application) requireFeature(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.features.Enabled(name) {
+				app.notFound(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimit returns a middleware which limits each client IP address to the
+// rate allowed by the limiter, responding with 429 Too Many Requests once the
+// limit is exceeded.
+func (app *application) rateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow(clientIP(r)) {
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// apiRateLimit limits how many JSON API requests each client can make.
+// Authenticated users are limited individually, using the limit an admin set
+// for them if there is one and the default limit otherwise; anonymous
+// requests are limited by client IP address. Every response tells the client
+// where it stands with X-RateLimit-Limit (requests per hour),
+// X-RateLimit-Remaining and X-RateLimit-Reset (when all the requests will be
+// available again, in Unix time) headers.
+func (app *application) apiRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + clientIP(r)
+		perHour := int(math.Round(app.apiLimiter.Rate * 3600))
+		burst := app.apiLimiter.Burst
+
+		if app.isAuthenticated(r) {
+			id := reqctx.UserID(r.Context())
+			key = "user:" + strconv.Itoa(id)
+
+			limit, err := app.apiRateLimits.Get(id)
+			if err == nil {
+				perHour, burst = limit.RequestsPerHour, limit.Burst
+			} else if !errors.Is(err, models.ErrNoRecord) {
+				app.apiServerError(w, err)
+				return
+			}
+		}
+
+		status := app.apiLimiter.Check(key, float64(perHour)/3600, burst)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(perHour))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+
+		if !status.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
+			app.apiErrorResponse(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -36,9 +39,14 @@ func TestSecureHeaders(t *testing.T) {
 
 	// Check that the middleware has correctly set the Content-Security-Policy
 	// header on the response.
-	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; " +
+		"report-uri /csp-report; report-to csp-endpoint"
 	assert.Equal(t, rs.Header.Get("Content-Security-Policy"), expectedValue)
 
+	// Check that the endpoint named in the report-to directive is defined.
+	expectedValue = `csp-endpoint="/csp-report"`
+	assert.Equal(t, rs.Header.Get("Reporting-Endpoints"), expectedValue)
+
 	// Check that the middleware has correctly set the Referrer-Policy
 	// header on the response.
 	expectedValue = "origin-when-cross-origin"
@@ -72,3 +80,51 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.accessLog = logging.NewAccessLog(&buf, logging.JSON)
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/snippet/view/99")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	var entry logging.AccessEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry.Method, http.MethodGet)
+	assert.Equal(t, entry.URI, "/snippet/view/99")
+	assert.Equal(t, entry.Status, http.StatusNotFound)
+	assert.Equal(t, entry.RequestID, headers.Get("X-Request-ID"))
+	assert.Equal(t, entry.Bytes > 0, true)
+}
+
+func TestRequestContext(t *testing.T) {
+	app := newTestApplication(t)
+
+	var got reqctx.Values
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *reqctx.From(r.Context())
+	})
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("Accept-Language", "pt-BR;q=0.9, en;q=0.8")
+
+	app.requestContext(secureHeaders(next)).ServeHTTP(rr, r)
+
+	assert.Equal(t, got.RequestID, rr.Header().Get("X-Request-ID"))
+	assert.Equal(t, got.ClientIP, "192.0.2.1")
+	assert.Equal(t, got.Locale, "pt-BR")
+	assert.Equal(t, got.UserID, 0)
+
+	// The nonce lets inline scripts carrying it run.
+	assert.StringContains(t, rr.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+got.CSPNonce+"'")
+}
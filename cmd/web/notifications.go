@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+)
+
+func (app *application) notificationList(w http.ResponseWriter, r *http.Request) {
+	id := reqctx.UserID(r.Context())
+
+	notifications, err := app.notifications.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Notifications = notifications
+	app.render(w, http.StatusOK, "notifications.tmpl.html", data)
+}
+
+type notificationReadForm struct {
+	ID int `form:"id"`
+}
+
+// notificationReadPost marks a single notification as read, or all of the
+// user's notifications if no ID is given.
+func (app *application) notificationReadPost(w http.ResponseWriter, r *http.Request) {
+	var form notificationReadForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+
+	if form.ID == 0 {
+		err = app.notifications.MarkAllRead(userID)
+	} else {
+		err = app.notifications.MarkRead(userID, form.ID)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, urlFor("notifications"), http.StatusSeeOther)
+}
+
+type adminAnnouncementForm struct {
+	Message             string `form:"message"`
+	Link                string `form:"link"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) adminAnnouncements(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = adminAnnouncementForm{}
+	app.render(w, http.StatusOK, "admin_announcements.tmpl.html", data)
+}
+
+func (app *application) adminAnnouncementsPost(w http.ResponseWriter, r *http.Request) {
+	var form adminAnnouncementForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Message), "message", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Message, 255), "message", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.MaxChars(form.Link, 255), "link", "This field cannot be more than 255 characters long")
+	form.CheckField(form.Link == "" || isLocalPath(form.Link), "link", "This field must be a path on this site, like /about")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "admin_announcements.tmpl.html", data)
+		return
+	}
+
+	n, err := app.notifications.Broadcast(models.NotificationAnnouncement, form.Message, form.Link)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Announcement sent to %d users.", n))
+
+	http.Redirect(w, r, urlFor("admin.announcements"), http.StatusSeeOther)
+}
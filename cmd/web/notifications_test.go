@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNotificationList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/notifications")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Welcome to Snippetbox!")
+	assert.StringContains(t, body, "<span class='badge'>1</span>")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/notifications/read", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/notifications")
+
+	form.Set("id", "-1")
+	code, _, _ = ts.postForm(t, "/notifications/read", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestAdminAnnouncementsPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("message", "Scheduled maintenance tonight")
+	form.Add("link", "https://evil.example.com")
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/announcements"))
+
+	code, _, body := ts.postForm(t, "/admin/announcements", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be a path on this site")
+
+	form.Set("link", "/about")
+	code, headers, _ := ts.postForm(t, "/admin/announcements", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Announcement sent to 2 users.")
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// orgInvitationTTL is how long the link in an invitation to join an
+// organization works for.
+const orgInvitationTTL = 7 * 24 * time.Hour
+
+type orgForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+type orgInviteForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+type orgRoleForm struct {
+	Role string `form:"role"`
+}
+
+// orgInvitationForm carries the token of the invitation being accepted.
+type orgInvitationForm struct {
+	Token string
+}
+
+type orgSwitchForm struct {
+	Org int `form:"org"`
+}
+
+// snippetVisible reports whether the snippet can be shown to the user with
+// the given ID (or 0 for anonymous users). On top of what VisibleTo checks,
+// org only snippets are only shown to their owner and the organization's
+// members.
+func (app *application) snippetVisible(s *models.Snippet, userID int) (bool, error) {
+	if !s.VisibleTo(userID) {
+		return false, nil
+	}
+	if !s.OrgOnly || s.UserID == userID {
+		return true, nil
+	}
+	if userID == 0 {
+		return false, nil
+	}
+
+	_, err := app.orgs.Role(s.OrgID, userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// userOrgs returns the organizations the current user is a member of, and
+// the one they have switched to, for the switcher in the navigation bar. As
+// with the notification badge, errors are logged rather than failing the
+// page.
+func (app *application) userOrgs(r *http.Request) ([]*models.Org, *models.Org) {
+	if !app.isAuthenticated(r) {
+		return nil, nil
+	}
+
+	orgs, err := app.orgs.ForUser(reqctx.UserID(r.Context()))
+	if err != nil {
+		app.errorLog.Print(err)
+		return nil, nil
+	}
+
+	current := app.sessionManager.GetInt(r.Context(), "orgID")
+	for _, o := range orgs {
+		if o.ID == current {
+			return orgs, o
+		}
+	}
+	return orgs, nil
+}
+
+// orgFromSlug returns the organization named in the URL and the current
+// user's role in it, which is empty if they aren't a member. It sends a 404
+// and returns nil if there's no such organization.
+func (app *application) orgFromSlug(w http.ResponseWriter, r *http.Request) (*models.Org, string) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	o, err := app.orgs.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, ""
+	}
+
+	userID := reqctx.UserID(r.Context())
+	if userID == 0 {
+		return o, ""
+	}
+
+	role, err := app.orgs.Role(o.ID, userID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return nil, ""
+	}
+	return o, role
+}
+
+// ownedOrg is orgFromSlug for the pages only owners may use. Everyone else
+// gets a 403, or a 404 if they aren't a member at all.
+func (app *application) ownedOrg(w http.ResponseWriter, r *http.Request) *models.Org {
+	o, role := app.orgFromSlug(w, r)
+	if o == nil {
+		return nil
+	}
+
+	switch role {
+	case models.OrgOwner:
+		return o
+	case "":
+		app.notFound(w)
+	default:
+		app.clientError(w, http.StatusForbidden)
+	}
+	return nil
+}
+
+// orgs lists the user's organizations, with a form for starting a new one.
+func (app *application) orgsView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = orgForm{}
+	app.render(w, http.StatusOK, "orgs.tmpl.html", data)
+}
+
+func (app *application) orgCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form orgForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "orgs.tmpl.html", data)
+		return
+	}
+
+	id, err := app.orgs.Insert(form.Name, reqctx.UserID(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	o, err := app.orgs.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Starting an organization switches to it, as the user most likely
+	// wants to publish something for it next.
+	app.sessionManager.Put(r.Context(), "orgID", o.ID)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Organization %q created.", o.Name))
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+// orgView is an organization's page. Everyone sees its published snippets;
+// members also see its org only snippets and who the members are, and owners
+// get to manage them.
+func (app *application) orgView(w http.ResponseWriter, r *http.Request) {
+	o, role := app.orgFromSlug(w, r)
+	if o == nil {
+		return
+	}
+
+	app.renderOrg(w, r, http.StatusOK, o, role, orgInviteForm{Role: models.OrgMember})
+}
+
+func (app *application) renderOrg(w http.ResponseWriter, r *http.Request, status int, o *models.Org, role string, form orgInviteForm) {
+	p := newPagination(r, snippetsPerPage)
+
+	snippets, total, err := app.snippets.ForOrg(o.ID, role != "", p.PerPage, p.Offset())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	p.Total = total
+
+	data := app.newTemplateData(r)
+	data.Org = o
+	data.OrgRole = role
+	data.Snippets = snippets
+	data.Pagination = p
+	data.Form = form
+
+	if role != "" {
+		data.OrgMembers, err = app.orgs.Members(o.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	if role == models.OrgOwner {
+		data.OrgInvitations, err = app.orgs.Invitations(o.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.render(w, status, "org.tmpl.html", data)
+}
+
+// orgInvitePost emails an invitation to join the organization.
+func (app *application) orgInvitePost(w http.ResponseWriter, r *http.Request) {
+	o := app.ownedOrg(w, r)
+	if o == nil {
+		return
+	}
+
+	var form orgInviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.Email = strings.TrimSpace(form.Email)
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.PermittedValue(form.Role, models.OrgMember, models.OrgOwner), "role", "Pick a role")
+
+	if !form.Valid() {
+		app.renderOrg(w, r, http.StatusUnprocessableEntity, o, models.OrgOwner, form)
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+
+	inviter, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Greet people who already have an account by name, and let them
+	// unsubscribe from invitations.
+	name := form.Email
+	invitee, err := app.users.GetByEmail(form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	layout := app.emailLayout()
+	if invitee != nil {
+		name = invitee.Name
+
+		p, err := app.preferences.Get(invitee.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if p.InvitationEmails && app.baseURL != "" {
+			layout.Unsubscribe, err = app.signedURL(urlFor("email.unsubscribe"), url.Values{"uid": {strconv.Itoa(invitee.ID)}}, unsubscribeLinkTTL)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+	}
+
+	token, err := app.orgs.Invite(o.ID, userID, form.Email, form.Role, orgInvitationTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	link := app.absoluteURL(urlFor("org.invitation", token))
+
+	// Links in emails only ever point at -base-url, so without one the
+	// inviter passes the link on instead.
+	if app.baseURL == "" {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invitations can't be emailed right now, so send %s this link yourself: %s", form.Email, link))
+		http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+		return
+	}
+
+	// Those who have unsubscribed are left for the inviter to tell.
+	if invitee != nil && layout.Unsubscribe == "" {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has asked not to be emailed invitations, so send them this link yourself: %s", form.Email, link))
+		http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+		return
+	}
+
+	err = app.sendEmail(form.Email, "org_invitation", mailer.OrgInvitationData{
+		Layout:    layout,
+		Name:      name,
+		InvitedBy: inviter.Name,
+		Org:       o.Name,
+		Role:      form.Role,
+		Link:      link,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invitation sent to %s.", form.Email))
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+func (app *application) orgInvitationRevokePost(w http.ResponseWriter, r *http.Request) {
+	o := app.ownedOrg(w, r)
+	if o == nil {
+		return
+	}
+
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.orgs.RevokeInvitation(o.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Invitation revoked.")
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+func (app *application) orgMemberRolePost(w http.ResponseWriter, r *http.Request) {
+	o := app.ownedOrg(w, r)
+	if o == nil {
+		return
+	}
+
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var form orgRoleForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Role, models.OrgMember, models.OrgOwner) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.orgs.SetRole(o.ID, id, form.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", lastOwnerFlash)
+			http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Role changed.")
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+// orgMemberRemovePost takes someone out of the organization.
+func (app *application) orgMemberRemovePost(w http.ResponseWriter, r *http.Request) {
+	o := app.ownedOrg(w, r)
+	if o == nil {
+		return
+	}
+
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	if !app.removeOrgMember(w, r, o, id) {
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Member removed.")
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+// orgLeavePost takes the current user out of the organization.
+func (app *application) orgLeavePost(w http.ResponseWriter, r *http.Request) {
+	o, role := app.orgFromSlug(w, r)
+	if o == nil {
+		return
+	}
+	if role == "" {
+		app.notFound(w)
+		return
+	}
+
+	if !app.removeOrgMember(w, r, o, reqctx.UserID(r.Context())) {
+		return
+	}
+
+	if app.sessionManager.GetInt(r.Context(), "orgID") == o.ID {
+		app.sessionManager.Remove(r.Context(), "orgID")
+	}
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You have left %s.", o.Name))
+	http.Redirect(w, r, urlFor("orgs"), http.StatusSeeOther)
+}
+
+// removeOrgMember removes a member, sending the response and returning false
+// if that fails. The last owner can't be removed, so they are sent back to
+// the organization's page to make someone else an owner first.
+func (app *application) removeOrgMember(w http.ResponseWriter, r *http.Request, o *models.Org, userID int) bool {
+	err := app.orgs.RemoveMember(o.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.notFound(w)
+		case errors.Is(err, models.ErrLastOwner):
+			app.sessionManager.Put(r.Context(), "flash", lastOwnerFlash)
+			http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+		default:
+			app.serverError(w, err)
+		}
+		return false
+	}
+	return true
+}
+
+// lastOwnerFlash is the flash message for trying to remove the only owner of
+// an organization, or make them a member.
+const lastOwnerFlash = "An organization needs an owner. Make someone else an owner first."
+
+// orgInvitationFor returns the invitation for the token in the URL and the
+// organization it's to, or sends a 404 and returns nil if the token is
+// unknown or has expired.
+func (app *application) orgInvitationFor(w http.ResponseWriter, r *http.Request) (*models.OrgInvitation, *models.Org) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	i, err := app.orgs.GetInvitation(params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil
+	}
+
+	o, err := app.orgs.Get(i.OrgID)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, nil
+	}
+	return i, o
+}
+
+// orgInvitation asks the user to confirm they want to join the organization
+// they were invited to.
+func (app *application) orgInvitation(w http.ResponseWriter, r *http.Request) {
+	i, o := app.orgInvitationFor(w, r)
+	if i == nil {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	data := app.newTemplateData(r)
+	data.Org = o
+	data.OrgInvitation = i
+	data.Form = orgInvitationForm{Token: params.ByName("token")}
+	app.render(w, http.StatusOK, "org_invitation.tmpl.html", data)
+}
+
+// orgInvitationPost accepts an invitation. Invitations go to an email
+// address, so only the account with that address can accept one; a link
+// forwarded to someone else is no use to them.
+func (app *application) orgInvitationPost(w http.ResponseWriter, r *http.Request) {
+	i, o := app.orgInvitationFor(w, r)
+	if i == nil {
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !strings.EqualFold(user.Email, i.Email) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	_, err = app.orgs.AcceptInvitation(params.ByName("token"), userID)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "orgID", o.ID)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Welcome to %s!", o.Name))
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
+
+// orgSwitchPost switches the organization new snippets are published for by
+// default. Zero switches back to publishing personally.
+func (app *application) orgSwitchPost(w http.ResponseWriter, r *http.Request) {
+	var form orgSwitchForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.Org == 0 {
+		app.sessionManager.Remove(r.Context(), "orgID")
+		app.sessionManager.Put(r.Context(), "flash", "Switched to your personal account.")
+		http.Redirect(w, r, urlFor("home"), http.StatusSeeOther)
+		return
+	}
+
+	_, err = app.orgs.Role(form.Org, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, http.StatusUnprocessableEntity)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	o, err := app.orgs.Get(form.Org)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "orgID", o.ID)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Switched to %s.", o.Name))
+	http.Redirect(w, r, urlFor("org.view", o.Slug), http.StatusSeeOther)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOrgOnlySnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Mock snippet 6 is only for the members of Acme, which only the admin
+	// is.
+	for _, p := range []string{"/snippet/view/00000000000000000000000006", "/snippet/raw/00000000000000000000000006/0", "/api/v1/snippets/00000000000000000000000006"} {
+		code, _, _ := ts.get(t, p)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	code, _, body := ts.get(t, "/org/acme")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "An internal snippet"), false)
+	assert.Equal(t, strings.Contains(body, "Members"), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/snippet/view/00000000000000000000000006")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/snippet/view/00000000000000000000000006")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "For the team&#39;s eyes only.")
+
+	_, _, body = ts.get(t, "/org/acme")
+	assert.StringContains(t, body, "<a href='/snippet/view/00000000000000000000000006'>An internal snippet</a>")
+	assert.StringContains(t, body, "<h3>Members</h3>")
+	assert.StringContains(t, body, "<h3>Invite someone</h3>")
+}
+
+func TestOrgInvitations(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/org/acme")
+
+	form := url.Values{"csrf_token": {csrfToken}, "email": {"alice@"}, "role": {"member"}}
+	code, _, body := ts.postForm(t, "/org/acme/invite", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be a valid email address")
+
+	form.Set("email", "alice@example.com")
+	code, headers, _ := ts.postForm(t, "/org/acme/invite", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	sendEmails(t, app)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Invitation sent to alice@example.com.")
+	assert.StringContains(t, body, "<td>alice@example.com</td>")
+
+	assert.Equal(t, len(mail.sent), 1)
+	assert.Equal(t, mail.sent[0].To, "alice@example.com")
+	assert.Equal(t, mail.sent[0].Subject, "Admin invited you to Acme on Snippetbox")
+	assert.StringContains(t, mail.sent[0].Body, "Hi Alice,")
+	assert.StringContains(t, mail.sent[0].Body, "/orgs/invitation/token-1\n")
+
+	// Only the invited address can accept.
+	code, _, _ = ts.postForm(t, "/orgs/invitation/token-1", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/orgs/invitation/token-1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Join Acme</h2>")
+
+	code, headers, _ = ts.postForm(t, "/orgs/invitation/token-1", url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/org/acme")
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Welcome to Acme!")
+	assert.StringContains(t, body, "An internal snippet")
+	assert.StringContains(t, body, "<option value='1' selected>Acme</option>")
+
+	// The invitation is used up.
+	code, _, _ = ts.get(t, "/orgs/invitation/token-1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/view/00000000000000000000000006")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Members can't manage the organization.
+	csrfToken = extractCSRFToken(t, body)
+	code, _, _ = ts.postForm(t, "/org/acme/members/2/remove", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, _ = ts.postForm(t, "/org/acme/members/1/role", url.Values{"csrf_token": {csrfToken}, "role": {"owner"}})
+	assert.Equal(t, code, http.StatusForbidden)
+
+	code, headers, _ = ts.postForm(t, "/org/acme/leave", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "You have left Acme.")
+
+	code, _, _ = ts.get(t, "/snippet/view/00000000000000000000000006")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// The last owner can't leave.
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken = ts.csrfToken(t, "/org/acme")
+
+	code, headers, _ = ts.postForm(t, "/org/acme/leave", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, lastOwnerFlash)
+}
+
+func TestOrgInvitationsWithoutBaseURL(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	app.baseURL = ""
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	form := url.Values{"csrf_token": {ts.csrfToken(t, "/org/acme")}, "email": {"alice@example.com"}, "role": {"member"}}
+	code, headers, _ := ts.postForm(t, "/org/acme/invite", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	sendEmails(t, app)
+	assert.Equal(t, len(mail.sent), 0)
+
+	_, _, body := ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Invitations can't be emailed right now, so send alice@example.com this link yourself: "+app.siteURL+"/orgs/invitation/token-1")
+}
+
+func TestOrgCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/orgs")
+	assert.StringContains(t, body, "You aren't in any organizations yet.")
+	assert.Equal(t, strings.Contains(body, "class='org-switcher'"), false)
+
+	csrfToken := extractCSRFToken(t, body)
+
+	code, _, body := ts.postForm(t, "/orgs", url.Values{"csrf_token": {csrfToken}, "name": {""}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	code, headers, _ := ts.postForm(t, "/orgs", url.Values{"csrf_token": {csrfToken}, "name": {"Frog Pond"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/org/frog-pond")
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, `Organization "Frog Pond" created.`)
+	assert.StringContains(t, body, "<option value='2' selected>Frog Pond</option>")
+
+	// New snippets are for the organization switched to.
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='2' selected>Frog Pond</option>")
+
+	form := url.Values{
+		"csrf_token": {csrfToken},
+		"title":      {"Internal"},
+		"content":    {"Content"},
+		"expires":    {"7"},
+		"org":        {"1"},
+		"org_only":   {"true"},
+	}
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Pick one of your organizations")
+
+	form.Set("org", "0")
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Pick an organization to share the snippet with")
+
+	form.Set("org", "2")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, headers, _ = ts.postForm(t, "/orgs/switch", url.Values{"csrf_token": {csrfToken}, "org": {"0"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Switched to your personal account.")
+	assert.StringContains(t, body, "<option value='0'>Personal</option>")
+
+	// Only organizations the user is in can be switched to.
+	code, _, _ = ts.postForm(t, "/orgs/switch", url.Values{"csrf_token": {csrfToken}, "org": {"1"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"time"
+)
+
+// templateBase is what every layout reads, whichever page it's showing: the
+// flash message, the CSRF token and what goes in the navigation bar. Every
+// page's view model embeds it, filled in by newTemplateBase.
+type templateBase struct {
+	CurrentYear         int
+	Flash               string
+	IsAuthenticated     bool
+	CSRFToken           string
+	CSPNonce            string
+	Features            map[string]bool
+	UnreadNotifications int
+	ChangelogNew        bool
+	SSOName             string
+	PasswordLogin       bool
+	LocalAccounts       bool
+	Orgs                []*models.Org
+	CurrentOrg          *models.Org
+	Impersonation       *impersonationBanner
+	Locale              locale
+
+	// flush is set while the page is being streamed; see Flush.
+	flush func() error
+}
+
+func (b *templateBase) base() *templateBase {
+	return b
+}
+
+// pageData is a page's view model: a struct embedding templateBase, with a
+// field for each thing the page shows. The layouts and pages are checked
+// against their view models by TestPageViewModels.
+type pageData interface {
+	base() *templateBase
+}
+
+// homePage is the home page, which lists the latest snippets.
+type homePage struct {
+	templateBase
+	Snippets   []*models.Snippet
+	Pagination pagination
+}
+
+// snippetViewPage shows a snippet, or a preview of one that's being
+// written, or the snippet that was just burned after reading.
+type snippetViewPage struct {
+	templateBase
+	Snippet           *models.Snippet
+	IsOwner           bool
+	TabWidth          int
+	Collections       []*models.Collection
+	CollectionChoices []*models.Collection
+	Burned            bool
+	Preview           bool
+	// LintWarnings are the warnings about each file, by position, for its
+	// owner and in previews.
+	LintWarnings map[int][]string
+}
+
+// signupPage is the signup form.
+type signupPage struct {
+	templateBase
+	Form userSignupForm
+}
+
+// adminRetentionPage shows the data retention policies, and what they're
+// about to purge.
+type adminRetentionPage struct {
+	templateBase
+	Purges        []retentionPurge
+	DryRun        bool
+	LookaheadDays int
+	LastRun       time.Time
+	LastPurged    int
+}
+
+// aboutPage shows the site's statistics, how long the server has been up and
+// which version it is.
+type aboutPage struct {
+	templateBase
+	Stats   *models.InstanceStats
+	Uptime  string
+	Version string
+}
+
+// adminExpiryPage shows the expiry policy, with a form to change it.
+type adminExpiryPage struct {
+	templateBase
+	Policy models.ExpiryPolicy
+	Form   adminExpiryForm
+}
+
+// adminIntegrityPage shows what the integrity check found the last time it
+// ran.
+type adminIntegrityPage struct {
+	templateBase
+	Integrity integrityStatus
+}
+
+// adminPerformancePage is the performance dashboard: how long requests and
+// database queries have taken over the last Window, and how the Go runtime
+// is doing.
+type adminPerformancePage struct {
+	templateBase
+	Window         string
+	Requests       perfRow
+	Routes         []perfRow
+	Queries        perfRow
+	SlowestQueries []perfRow
+	Runtime        perfRuntime
+}
+
+// emailUnsubscribePage is the page which the unsubscribe links in emails
+// point to. Action is the link, which its form posts back to.
+type emailUnsubscribePage struct {
+	templateBase
+	Subscribed bool
+	Action     string
+}
+
+// render renders a page in the base layout.
+func render[T pageData](app *application, w http.ResponseWriter, status int, page string, data T) {
+	renderLayout(app, w, status, "base", page, data)
+}
+
+// renderLayout renders a page inside the given layout. Every layout is
+// parsed into every page's template set, so any page can be shown in any
+// layout as long as it defines the templates the layout uses.
+func renderLayout[T pageData](app *application, w http.ResponseWriter, status int, layout, page string, data T) {
+	ts, err := app.templateSet(page, layout)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Borrow a buffer from the pool, rather than allocating a new one for
+	// every response.
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// Write the template to the buffer, instead of straight to the
+	// http.ResponseWriter. If there's an error, call our serverError() helper
+	// and then return.
+	err = ts.ExecuteTemplate(buf, layout, data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Write out the provided HTTP status code ('200 OK', '400 Bad Request'
+	w.WriteHeader(status)
+
+	// Write the contents of the buffer to the http.ResponseWriter. Note: this
+	// is another time where we pass our http.ResponseWriter to a function that
+	// takes an io.Writer.
+	buf.WriteTo(w)
+}
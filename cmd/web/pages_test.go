@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"slices"
+	"testing"
+	"text/template/parse"
+)
+
+// fieldChecker checks, without executing anything, that every field and
+// method a template uses exists on the data it's executed with. Executing a
+// page only checks the branches its data happens to take, so a typo in an
+// error message or an empty list's placeholder would otherwise only turn up
+// when someone saw it.
+//
+// Types which can't be worked out, like the result of index or a field of
+// type any, are nil, and anything used from them isn't checked.
+type fieldChecker struct {
+	ts       *template.Template
+	seen     map[string]bool
+	problems []string
+}
+
+// check checks the named template, executed with data of type dot.
+func (c *fieldChecker) check(name string, dot reflect.Type) {
+	key := fmt.Sprintf("%s %v", name, dot)
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	t := c.ts.Lookup(name)
+	if t == nil || t.Tree == nil {
+		return
+	}
+	c.walk(name, t.Tree.Root, dot, dot)
+}
+
+// walk checks a node, where dot and $ are of the given types.
+func (c *fieldChecker) walk(name string, node parse.Node, dot, root reflect.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			c.walk(name, child, dot, root)
+		}
+	case *parse.ActionNode:
+		c.pipe(name, n.Pipe, dot, root)
+	case *parse.IfNode:
+		c.pipe(name, n.Pipe, dot, root)
+		c.walk(name, n.List, dot, root)
+		c.walk(name, n.ElseList, dot, root)
+	case *parse.WithNode:
+		c.walk(name, n.List, c.pipe(name, n.Pipe, dot, root), root)
+		c.walk(name, n.ElseList, dot, root)
+	case *parse.RangeNode:
+		c.walk(name, n.List, elemType(c.pipe(name, n.Pipe, dot, root)), root)
+		c.walk(name, n.ElseList, dot, root)
+	case *parse.TemplateNode:
+		var data reflect.Type
+		if n.Pipe != nil {
+			data = c.pipe(name, n.Pipe, dot, root)
+		}
+		// An unknown type would check nothing, but a known one checks the
+		// template as it's used here.
+		if data != nil {
+			c.check(n.Name, data)
+		}
+	}
+}
+
+// pipe checks a pipeline and returns the type of its result.
+func (c *fieldChecker) pipe(name string, p *parse.PipeNode, dot, root reflect.Type) reflect.Type {
+	var result reflect.Type
+	for _, cmd := range p.Cmds {
+		for i, arg := range cmd.Args {
+			t := c.arg(name, arg, dot, root)
+			if i == 0 {
+				result = t
+			}
+		}
+	}
+	return result
+}
+
+// arg checks an argument of a command and returns its type.
+func (c *fieldChecker) arg(name string, node parse.Node, dot, root reflect.Type) reflect.Type {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.resolve(name, n, dot, n.Ident)
+	case *parse.VariableNode:
+		// Only $ is known; other variables are whatever they were set to.
+		if n.Ident[0] != "$" {
+			return nil
+		}
+		return c.resolve(name, n, root, n.Ident[1:])
+	case *parse.ChainNode:
+		return c.resolve(name, n, c.arg(name, n.Node, dot, root), n.Field)
+	case *parse.PipeNode:
+		return c.pipe(name, n, dot, root)
+	case *parse.IdentifierNode:
+		if f, ok := functions[n.Ident]; ok {
+			if t := reflect.TypeOf(f); t.NumOut() > 0 {
+				return t.Out(0)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the type of the chain of fields and methods idents, on a
+// value of type t.
+func (c *fieldChecker) resolve(name string, node parse.Node, t reflect.Type, idents []string) reflect.Type {
+	for _, ident := range idents {
+		if t == nil {
+			return nil
+		}
+
+		if m, ok := methodByName(t, ident); ok {
+			if m.Type.NumOut() == 0 {
+				return nil
+			}
+			t = m.Type.Out(0)
+			continue
+		}
+
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Interface:
+			return nil
+		case reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+			if f, ok := t.FieldByName(ident); ok && f.IsExported() {
+				t = f.Type
+				continue
+			}
+		}
+
+		location, _ := c.ts.Tree.ErrorContext(node)
+		c.problems = append(c.problems, fmt.Sprintf("%s (in %q): %s has no field or method %s", location, name, t, ident))
+		return nil
+	}
+	return t
+}
+
+// methodByName looks for a method of t, or of a pointer to it, since
+// templates can call either on the addressable values they're given.
+func methodByName(t reflect.Type, name string) (reflect.Method, bool) {
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		t = reflect.PointerTo(t)
+	}
+	return t.MethodByName(name)
+}
+
+// elemType returns the type of the elements ranged over in a value of type
+// t.
+func elemType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return t.Elem()
+	}
+	return nil
+}
+
+type pageViewModel struct {
+	page    string
+	model   pageData
+	layouts []string
+}
+
+// pageViewModels are the pages which have a view model of their own, and
+// the layouts they're shown in. Every other page is shown in the base layout
+// with templateData.
+var pageViewModels = []pageViewModel{
+	{"home.tmpl.html", &homePage{}, []string{"base"}},
+	{"view.tmpl.html", &snippetViewPage{}, []string{"base", "print", "snippet"}},
+	{"signup.tmpl.html", &signupPage{}, []string{"base"}},
+	{"about.tmpl.html", &aboutPage{}, []string{"base"}},
+	{"admin_performance.tmpl.html", &adminPerformancePage{}, []string{"base"}},
+	{"admin_integrity.tmpl.html", &adminIntegrityPage{}, []string{"base"}},
+	{"admin_expiry.tmpl.html", &adminExpiryPage{}, []string{"base"}},
+	{"admin_retention.tmpl.html", &adminRetentionPage{}, []string{"base"}},
+	{"unsubscribe.tmpl.html", &emailUnsubscribePage{}, []string{"base"}},
+}
+
+func TestPageViewModels(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, page := range cache.pages() {
+		t.Run(page, func(t *testing.T) {
+			ts, err := cache.lookup(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &fieldChecker{ts: ts, seen: map[string]bool{}}
+
+			i := slices.IndexFunc(pageViewModels, func(m pageViewModel) bool { return m.page == page })
+			if i < 0 {
+				c.check("base", reflect.TypeOf(&templateData{}))
+			} else {
+				for _, layout := range pageViewModels[i].layouts {
+					c.check(layout, reflect.TypeOf(pageViewModels[i].model))
+				}
+			}
+
+			for _, problem := range c.problems {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+func TestFieldChecker(t *testing.T) {
+	ts := template.Must(template.New("page").Funcs(functions).Parse(`
+		{{define "base"}}{{.Flash}}{{template "main" .}}{{end}}
+		{{define "main"}}
+			{{if .Snippets}}{{range .Snippets}}{{.Titel}}{{end}}{{else}}{{.Nothing}}{{end}}
+			{{with .Impersonation}}{{.UserName}}{{$.CSRFToken}}{{end}}
+			{{humanDate (index .Snippets 0).Created}}
+		{{end}}`))
+
+	c := &fieldChecker{ts: ts, seen: map[string]bool{}}
+	c.check("base", reflect.TypeOf(&homePage{}))
+
+	if len(c.problems) != 2 {
+		t.Fatalf("got problems %q; want two", c.problems)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pagination describes one page of a paginated list. It's used both for the
+// "pagination" template partial and for the Link headers of the JSON API.
+type pagination struct {
+	Page    int
+	PerPage int
+	Total   int
+
+	// url is the request URL, which the links to other pages are built
+	// from by changing its page parameter.
+	url url.URL
+}
+
+// newPagination reads the requested page from the "page" query string
+// parameter, defaulting to the first page if it's missing or not a positive
+// number. Pages past the end are allowed, and are simply empty.
+func newPagination(r *http.Request, perPage int) pagination {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return pagination{Page: page, PerPage: perPage, url: *r.URL}
+}
+
+// Offset returns the number of items before the current page.
+func (p pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// TotalPages returns the number of pages, which is always at least one so
+// that an empty list is shown as "page 1 of 1".
+func (p pagination) TotalPages() int {
+	if p.Total == 0 || p.PerPage == 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+func (p pagination) HasPrev() bool { return p.Page > 1 }
+func (p pagination) HasNext() bool { return p.Page < p.TotalPages() }
+
+func (p pagination) FirstURL() string { return p.pageURL(1) }
+func (p pagination) LastURL() string  { return p.pageURL(p.TotalPages()) }
+func (p pagination) PrevURL() string  { return p.pageURL(p.Page - 1) }
+func (p pagination) NextURL() string  { return p.pageURL(p.Page + 1) }
+
+// pageURL returns the current URL with its page parameter changed, keeping
+// any other parameters (like per_page) as they were.
+func (p pagination) pageURL(page int) string {
+	u := p.url
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// LinkHeader returns the value for an RFC 5988 Link header pointing at the
+// first, previous, next and last pages.
+func (p pagination) LinkHeader() string {
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, p.FirstURL())}
+	if p.HasPrev() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, p.PrevURL()))
+	}
+	if p.HasNext() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, p.NextURL()))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, p.LastURL()))
+
+	return strings.Join(links, ", ")
+}
+
+// encodeCursor returns an opaque string for c, for API clients to send back
+// as the cursor parameter.
+func encodeCursor(c models.SnippetCursor) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%s", c.PublishAt.UnixNano(), c.PublicID))
+}
+
+// decodeCursor reads a cursor made by encodeCursor. An empty string is the
+// start of the list. The returned error is written for the client.
+func decodeCursor(s string) (models.SnippetCursor, error) {
+	if s == "" {
+		return models.SnippetCursor{}, nil
+	}
+
+	invalid := errors.New("the cursor is invalid")
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.SnippetCursor{}, invalid
+	}
+	nanos, publicID, found := strings.Cut(string(b), ".")
+	if !found || publicID == "" {
+		return models.SnippetCursor{}, invalid
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return models.SnippetCursor{}, invalid
+	}
+	return models.SnippetCursor{PublishAt: time.Unix(0, n).UTC(), PublicID: publicID}, nil
+}
+
+// cursorURL returns u with its cursor parameter changed, keeping any other
+// parameters as they were.
+func cursorURL(u *url.URL, cursor string) string {
+	next := *u
+	q := next.Query()
+	q.Set("cursor", cursor)
+	next.RawQuery = q.Encode()
+	return next.RequestURI()
+}
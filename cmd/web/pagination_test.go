@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		total        int
+		wantPage     int
+		wantOffset   int
+		wantPages    int
+		wantPrevURL  string
+		wantNextURL  string
+		wantLastURL  string
+		wantLinkNext bool
+	}{
+		{
+			name:        "First page",
+			url:         "/feed",
+			total:       25,
+			wantPage:    1,
+			wantOffset:  0,
+			wantPages:   3,
+			wantNextURL: "/feed?page=2",
+			wantLastURL: "/feed?page=3",
+		},
+		{
+			name:        "Middle page keeps other parameters",
+			url:         "/api/v1/snippets?page=2&per_page=10",
+			total:       25,
+			wantPage:    2,
+			wantOffset:  10,
+			wantPages:   3,
+			wantPrevURL: "/api/v1/snippets?page=1&per_page=10",
+			wantNextURL: "/api/v1/snippets?page=3&per_page=10",
+			wantLastURL: "/api/v1/snippets?page=3&per_page=10",
+		},
+		{
+			name:        "Invalid page",
+			url:         "/?page=-3",
+			total:       0,
+			wantPage:    1,
+			wantOffset:  0,
+			wantPages:   1,
+			wantLastURL: "/?page=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPagination(httptest.NewRequest("GET", tt.url, nil), 10)
+			p.Total = tt.total
+
+			assert.Equal(t, p.Page, tt.wantPage)
+			assert.Equal(t, p.Offset(), tt.wantOffset)
+			assert.Equal(t, p.TotalPages(), tt.wantPages)
+			assert.Equal(t, p.LastURL(), tt.wantLastURL)
+
+			if tt.wantPrevURL != "" {
+				assert.Equal(t, p.HasPrev(), true)
+				assert.Equal(t, p.PrevURL(), tt.wantPrevURL)
+			} else {
+				assert.Equal(t, p.HasPrev(), false)
+			}
+
+			if tt.wantNextURL != "" {
+				assert.Equal(t, p.HasNext(), true)
+				assert.Equal(t, p.NextURL(), tt.wantNextURL)
+			} else {
+				assert.Equal(t, p.HasNext(), false)
+			}
+		})
+	}
+}
+
+func TestPaginationLinkHeader(t *testing.T) {
+	p := newPagination(httptest.NewRequest("GET", "/api/v1/snippets?page=2", nil), 10)
+	p.Total = 30
+
+	want := `</api/v1/snippets?page=1>; rel="first", </api/v1/snippets?page=1>; rel="prev", ` +
+		`</api/v1/snippets?page=3>; rel="next", </api/v1/snippets?page=3>; rel="last"`
+	assert.Equal(t, p.LinkHeader(), want)
+}
+
+func TestCursor(t *testing.T) {
+	c := models.SnippetCursor{PublishAt: time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC), PublicID: "01HS7Q4ZB7N3XG8T2V5K9MWCPD"}
+
+	got, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got, c)
+
+	got, err = decodeCursor("")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got, models.SnippetCursor{})
+
+	for _, s := range []string{"!!!", base64.RawURLEncoding.EncodeToString([]byte("123")), encodeCursor(models.SnippetCursor{})} {
+		_, err = decodeCursor(s)
+		assert.Equal(t, err.Error(), "the cursor is invalid")
+	}
+
+	u, _ := url.Parse("/api/v1/snippets?cursor=abc&per_page=5")
+	assert.Equal(t, cursorURL(u, "def"), "/api/v1/snippets?cursor=def&per_page=5")
+}
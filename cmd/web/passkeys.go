@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultWebAuthnOrigin is the origin passkeys are used from when none are
+// configured, which suits running the server locally.
+const defaultWebAuthnOrigin = "https://localhost:4000"
+
+// newWebAuthn returns the relying party which registers and checks passkeys
+// for the given domain and origins.
+func newWebAuthn(rpID string, origins []string) (*webauthn.WebAuthn, error) {
+	if len(origins) == 0 {
+		origins = []string{defaultWebAuthnOrigin}
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: "Snippetbox",
+		RPOrigins:     origins,
+	})
+}
+
+// webAuthnUser adapts a user and their passkeys to the webauthn.User
+// interface.
+type webAuthnUser struct {
+	user     *models.User
+	passkeys []*models.Passkey
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return userHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.passkeys))
+	for i, p := range u.passkeys {
+		credentials[i] = passkeyCredential(p)
+	}
+	return credentials
+}
+
+// userHandle is the opaque ID which authenticators store alongside a
+// passkey, so that it can be used without typing an email address first.
+func userHandle(id int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func passkeyCredential(p *models.Passkey) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, len(p.Transports))
+	for i, t := range p.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+
+	return webauthn.Credential{
+		ID:              p.CredentialID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: p.BackupEligible,
+			BackupState:    p.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+// loadWebAuthnUser returns the user with the given ID along with their
+// passkeys.
+func (app *application) loadWebAuthnUser(id int) (*webAuthnUser, error) {
+	user, err := app.users.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := app.passkeys.ForUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{user: user, passkeys: passkeys}, nil
+}
+
+// putWebAuthnSession keeps the challenge for a ceremony in the session until
+// the browser finishes it. It's stored as JSON, since the session store only
+// knows how to encode basic types.
+func (app *application) putWebAuthnSession(r *http.Request, key string, session *webauthn.SessionData) error {
+	js, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), key, string(js))
+	return nil
+}
+
+// popWebAuthnSession returns and removes the challenge stored by
+// putWebAuthnSession. Each challenge can only be answered once.
+func (app *application) popWebAuthnSession(r *http.Request, key string) (webauthn.SessionData, bool) {
+	var session webauthn.SessionData
+
+	js := app.sessionManager.PopString(r.Context(), key)
+	if js == "" {
+		return session, false
+	}
+
+	if err := json.Unmarshal([]byte(js), &session); err != nil {
+		return session, false
+	}
+
+	return session, true
+}
+
+func (app *application) accountPasskeys(w http.ResponseWriter, r *http.Request) {
+	id := reqctx.UserID(r.Context())
+
+	passkeys, err := app.passkeys.ForUser(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Passkeys = passkeys
+	app.render(w, http.StatusOK, "passkeys.tmpl.html", data)
+}
+
+// accountPasskeyRegisterBegin starts registering a new passkey, returning
+// the options for navigator.credentials.create(). Passkeys which are already
+// registered are excluded, so the same authenticator isn't added twice.
+func (app *application) accountPasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	id := reqctx.UserID(r.Context())
+
+	user, err := app.loadWebAuthnUser(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, len(user.passkeys))
+	for i, p := range user.passkeys {
+		exclusions[i] = passkeyCredential(p).Descriptor()
+	}
+
+	// Passkeys have to be discoverable, as signing in with one doesn't start
+	// by asking who the user is.
+	options, session, err := app.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	if err = app.putWebAuthnSession(r, "webauthnRegistration", session); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"options": options}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// accountPasskeyRegisterFinish checks the new credential returned by the
+// browser and stores it. The name which the user gave it is passed in the
+// query string, since the body is the credential itself.
+func (app *application) accountPasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	id := reqctx.UserID(r.Context())
+
+	session, ok := app.popWebAuthnSession(r, "webauthnRegistration")
+	if !ok {
+		app.apiErrorResponse(w, http.StatusBadRequest, "no passkey registration is in progress")
+		return
+	}
+
+	user, err := app.loadWebAuthnUser(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		app.apiFailedValidation(w, map[string]string{"name": "This field cannot be more than 100 characters long"})
+		return
+	}
+
+	credential, err := app.webAuthn.FinishRegistration(user, session, r)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, "the passkey could not be verified")
+		return
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	_, err = app.passkeys.Insert(&models.Passkey{
+		UserID:          id,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicatePasskey) {
+			app.apiErrorResponse(w, http.StatusConflict, "this passkey is already registered")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been registered.")
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"redirect": urlFor("account.passkeys")}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+func (app *application) accountPasskeyDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	passkeyID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || passkeyID < 1 {
+		app.notFound(w)
+		return
+	}
+
+	id := reqctx.UserID(r.Context())
+
+	err = app.passkeys.Delete(passkeyID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been removed.")
+	http.Redirect(w, r, urlFor("account.passkeys"), http.StatusSeeOther)
+}
+
+// userLoginPasskeyBegin starts signing in with a passkey, returning the
+// options for navigator.credentials.get(). No user is named up front: the
+// browser offers whichever passkeys it has for this site.
+func (app *application) userLoginPasskeyBegin(w http.ResponseWriter, r *http.Request) {
+	options, session, err := app.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	if err = app.putWebAuthnSession(r, "webauthnLogin", session); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"options": options}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
+
+// userLoginPasskeyFinish checks the signed challenge returned by the browser
+// and, if it's good, starts the user's session. The response says where to
+// go next, since the request is made from JavaScript. When anything goes
+// wrong the user can still sign in with their password.
+func (app *application) userLoginPasskeyFinish(w http.ResponseWriter, r *http.Request) {
+	session, ok := app.popWebAuthnSession(r, "webauthnLogin")
+	if !ok {
+		app.apiErrorResponse(w, http.StatusBadRequest, "no passkey sign-in is in progress")
+		return
+	}
+
+	var passkey *models.Passkey
+
+	findUser := func(rawID, handle []byte) (webauthn.User, error) {
+		var err error
+		passkey, err = app.passkeys.GetByCredentialID(rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(handle, userHandle(passkey.UserID)) {
+			return nil, models.ErrNoRecord
+		}
+		return app.loadWebAuthnUser(passkey.UserID)
+	}
+
+	credential, err := app.webAuthn.FinishDiscoverableLogin(findUser, session, r)
+	if err != nil {
+		app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.Passkey, Reason: "verification failed"})
+		app.apiErrorResponse(w, http.StatusUnauthorized, "passkey sign-in failed")
+		return
+	}
+
+	// A signature counter which goes backwards suggests the passkey has been
+	// copied, so it isn't trusted.
+	if credential.Authenticator.CloneWarning {
+		app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.Passkey, UserID: passkey.UserID, Reason: fmt.Sprintf("passkey %d may have been cloned", passkey.ID)})
+		app.apiErrorResponse(w, http.StatusUnauthorized, "passkey sign-in failed")
+		return
+	}
+
+	err = app.passkeys.Used(passkey.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	path, err := app.startSession(r, passkey.UserID, authevents.Passkey)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"redirect": path}, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// testAuthenticator is a software passkey authenticator, which answers
+// WebAuthn challenges the way a browser and security key would.
+type testAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err = rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// challenge extracts the challenge from the options returned by a begin
+// endpoint.
+func (a *testAuthenticator) challenge(body string) string {
+	var rs struct {
+		Options struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+				User      struct {
+					ID string `json:"id"`
+				} `json:"user"`
+			} `json:"publicKey"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal([]byte(body), &rs); err != nil {
+		a.t.Fatal(err)
+	}
+
+	if rs.Options.PublicKey.User.ID != "" {
+		handle, err := base64.RawURLEncoding.DecodeString(rs.Options.PublicKey.User.ID)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		a.userHandle = handle
+	}
+
+	return rs.Options.PublicKey.Challenge
+}
+
+func (a *testAuthenticator) clientData(typ, challenge string) []byte {
+	js, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    defaultWebAuthnOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return js
+}
+
+// authData builds the authenticator data, with the user present and
+// verified flags set.
+func (a *testAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte("localhost"))
+
+	data := append(rpIDHash[:], flags|0x05)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+// create answers a registration challenge.
+func (a *testAuthenticator) create(challenge string) string {
+	publicKey, err := cbor.Marshal(map[int]any{
+		1:  2,  // Key type: EC2
+		3:  -7, // Algorithm: ES256
+		-1: 1,  // Curve: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestationObject, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(0x40, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    b64(a.clientData("webauthn.create", challenge)),
+		"attestationObject": b64(attestationObject),
+	})
+}
+
+// get answers a sign-in challenge.
+func (a *testAuthenticator) get(challenge string) string {
+	a.signCount++
+
+	authData := a.authData(0, nil)
+	clientData := a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *testAuthenticator) credential(response map[string]any) string {
+	js, err := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return string(js)
+}
+
+func TestPasskeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	authenticator := newTestAuthenticator(t)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	headers := http.Header{"X-Csrf-Token": {ts.csrfToken(t, "/account/security/passkeys")}}
+
+	// A registration can't be finished without starting one.
+	code, _, _ := ts.do(t, http.MethodPost, "/account/security/passkeys/register/finish", headers, authenticator.create("bogus"))
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, body := ts.do(t, http.MethodPost, "/account/security/passkeys/register/begin", headers, "")
+	assert.Equal(t, code, http.StatusOK)
+	challenge := authenticator.challenge(body)
+
+	code, _, body = ts.do(t, http.MethodPost, "/account/security/passkeys/register/finish?name=Laptop", headers, authenticator.create(challenge))
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, body, `"redirect": "/account/security/passkeys"`)
+
+	_, _, body = ts.get(t, "/account/security/passkeys")
+	assertFlash(t, body, "Your passkey has been registered.")
+	assert.StringContains(t, body, "<td>Laptop</td>")
+
+	// Sign in again with the passkey alone.
+	ts.resetClient(t)
+	headers = http.Header{"X-Csrf-Token": {ts.csrfToken(t, "/user/login")}}
+
+	code, _, body = ts.do(t, http.MethodPost, "/user/login/passkey/begin", headers, "")
+	assert.Equal(t, code, http.StatusOK)
+	challenge = authenticator.challenge(body)
+
+	// Answering a different challenge fails, and uses up the real one.
+	code, _, _ = ts.do(t, http.MethodPost, "/user/login/passkey/finish", headers, authenticator.get("bogus"))
+	assert.Equal(t, code, http.StatusUnauthorized)
+	code, _, _ = ts.do(t, http.MethodPost, "/user/login/passkey/finish", headers, authenticator.get(challenge))
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, body = ts.do(t, http.MethodPost, "/user/login/passkey/begin", headers, "")
+	assert.Equal(t, code, http.StatusOK)
+	challenge = authenticator.challenge(body)
+
+	code, _, body = ts.do(t, http.MethodPost, "/user/login/passkey/finish", headers, authenticator.get(challenge))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"redirect": "/snippet/create"`)
+
+	code, _, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	passkeys, err := app.passkeys.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, passkeys[0].SignCount, uint32(3))
+
+	// Finally, remove the passkey again.
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/account/security/passkeys"))
+
+	code, _, _ = ts.postForm(t, "/account/security/passkeys/delete/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.postForm(t, "/account/security/passkeys/delete/1", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
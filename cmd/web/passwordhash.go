@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/passwordhash"
+	"golang.org/x/crypto/bcrypt"
+	"math"
+	"strings"
+)
+
+// The schemes -password-hash can choose.
+const (
+	hashBcrypt   = "bcrypt"
+	hashArgon2id = "argon2id"
+)
+
+// newPasswordHasher returns the hasher for the -password-hash scheme and its
+// parameters. Passwords hashed with anything else are still accepted, and
+// hashed again with this one when their owner next signs in.
+func newPasswordHasher(cfg config) (*passwordhash.Hasher, error) {
+	var problems []string
+
+	var scheme passwordhash.Scheme
+	switch cfg.passwordHash.scheme {
+	case hashBcrypt:
+		if cfg.passwordHash.bcryptCost < bcrypt.MinCost || cfg.passwordHash.bcryptCost > bcrypt.MaxCost {
+			problems = append(problems, fmt.Sprintf("-bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+		}
+		scheme = passwordhash.Bcrypt{Cost: cfg.passwordHash.bcryptCost}
+	case hashArgon2id:
+		p := cfg.passwordHash.argon2Parallelism
+		if p < 1 || p > math.MaxUint8 {
+			problems = append(problems, fmt.Sprintf("-argon2-parallelism must be between 1 and %d", math.MaxUint8))
+		}
+		if cfg.passwordHash.argon2Iterations < 1 || cfg.passwordHash.argon2Iterations > math.MaxUint32 {
+			problems = append(problems, "-argon2-iterations must be at least 1")
+		}
+		// Argon2 needs at least 8 KiB for each lane.
+		if cfg.passwordHash.argon2Memory < 8*max(p, 1) || cfg.passwordHash.argon2Memory > math.MaxUint32 {
+			problems = append(problems, "-argon2-memory must be at least 8 KiB for each of -argon2-parallelism")
+		}
+		scheme = passwordhash.Argon2id{
+			Memory:      uint32(cfg.passwordHash.argon2Memory),
+			Iterations:  uint32(cfg.passwordHash.argon2Iterations),
+			Parallelism: uint8(p),
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("-password-hash must be %q or %q", hashBcrypt, hashArgon2id))
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return &passwordhash.Hasher{Scheme: scheme}, nil
+}
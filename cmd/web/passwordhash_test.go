@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/passwordhash"
+	"testing"
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	cfg := defaultConfig(t)
+
+	h, err := newPasswordHasher(cfg)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, h.Scheme, passwordhash.Scheme(passwordhash.Bcrypt{Cost: 12}))
+
+	cfg.passwordHash.scheme = hashArgon2id
+	h, err = newPasswordHasher(cfg)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, h.Scheme, passwordhash.Scheme(passwordhash.Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 2}))
+
+	cfg.passwordHash.argon2Memory = 8
+	_, err = newPasswordHasher(cfg)
+	assert.Equal(t, err.Error(), "-argon2-memory must be at least 8 KiB for each of -argon2-parallelism")
+
+	cfg.passwordHash.scheme = hashBcrypt
+	cfg.passwordHash.bcryptCost = 40
+	_, err = newPasswordHasher(cfg)
+	assert.Equal(t, err.Error(), "-bcrypt-cost must be between 4 and 31")
+
+	cfg.passwordHash.scheme = "md5"
+	_, err = newPasswordHasher(cfg)
+	assert.Equal(t, err.Error(), `-password-hash must be "bcrypt" or "argon2id"`)
+}
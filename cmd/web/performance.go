@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/perf"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"time"
+)
+
+// The performance dashboard at /admin/performance shows how long requests
+// and database queries have been taking, and what the Go runtime says about
+// the process. The times are kept in memory by recordPerformance and the
+// query.DB's Observe hook, so they start again when the server restarts.
+
+const (
+	// perfWindow is how far back the dashboard looks.
+	perfWindow = 15 * time.Minute
+	// perfSamples is how many of the latest samples are kept for each
+	// route and statement, which is all a busy one's summary covers.
+	perfSamples = 1000
+	// perfSlowestQueries is how many statements the dashboard lists.
+	perfSlowestQueries = 20
+)
+
+// newRequestTimes returns the store of how long each route takes, which has
+// room for every route in routeTable.
+func newRequestTimes() *perf.Store {
+	return perf.NewStore(perfSamples, len(routeTable)+1)
+}
+
+// newQueryTimes returns the store of how long each statement takes.
+// Statements are built from a fixed set in the models, but some, like those
+// with WhereIn, come in a length for each number of values.
+func newQueryTimes() *perf.Store {
+	return perf.NewStore(perfSamples, 500)
+}
+
+// observeQuery records how long a statement took. Finding no rows isn't a
+// failure.
+func observeQuery(store *perf.Store) func(stmt string, d time.Duration, err error) {
+	return func(stmt string, d time.Duration, err error) {
+		store.Record(stmt, d, err != nil && !errors.Is(err, sql.ErrNoRows))
+	}
+}
+
+// unmatchedRoute is what requests which didn't match a route, like those
+// for pages that don't exist, are recorded as.
+const unmatchedRoute = "(no route)"
+
+// recordPerformance records how long each request took under its route,
+// and whether it failed with a server error.
+func (app *application) recordPerformance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		route := reqctx.From(r.Context()).Route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		app.requestTimes.Record(route, time.Since(start), rec.status >= 500)
+	})
+}
+
+// perfRow is a line of one of the dashboard's tables.
+type perfRow struct {
+	Name      string
+	Count     int
+	P50       time.Duration
+	P95       time.Duration
+	Max       time.Duration
+	ErrorRate string
+}
+
+func newPerfRow(s perf.Summary) perfRow {
+	return perfRow{
+		Name:      s.Name,
+		Count:     s.Count,
+		P50:       roundDuration(s.P50),
+		P95:       roundDuration(s.P95),
+		Max:       roundDuration(s.Max),
+		ErrorRate: formatPercent(s.ErrorRate()),
+	}
+}
+
+// perfRuntime is what the dashboard shows of perf.Runtime.
+type perfRuntime struct {
+	Goroutines uint64
+	Heap       string
+	HeapGoal   string
+	GCCycles   uint64
+	GCPauseP50 time.Duration
+	GCPauseP95 time.Duration
+	GCCPU      string
+}
+
+// adminPerformance shows the performance dashboard.
+func (app *application) adminPerformance(w http.ResponseWriter, r *http.Request) {
+	page := &adminPerformancePage{
+		templateBase: app.newTemplateBase(r),
+		Window:       humanUptime(perfWindow),
+		Requests:     newPerfRow(app.requestTimes.Total(perfWindow)),
+		Queries:      newPerfRow(app.queryTimes.Total(perfWindow)),
+	}
+
+	for _, s := range app.requestTimes.Summaries(perfWindow) {
+		page.Routes = append(page.Routes, newPerfRow(s))
+	}
+	for _, s := range app.queryTimes.Summaries(perfWindow) {
+		if len(page.SlowestQueries) == perfSlowestQueries {
+			break
+		}
+		page.SlowestQueries = append(page.SlowestQueries, newPerfRow(s))
+	}
+
+	rt := perf.ReadRuntime()
+	page.Runtime = perfRuntime{
+		Goroutines: rt.Goroutines,
+		Heap:       validator.FormatBytes(int(rt.HeapBytes)),
+		HeapGoal:   validator.FormatBytes(int(rt.HeapGoal)),
+		GCCycles:   rt.GCCycles,
+		GCPauseP50: roundDuration(rt.GCPauseP50),
+		GCPauseP95: roundDuration(rt.GCPauseP95),
+		GCCPU:      formatPercent(rt.GCCPUFraction()),
+	}
+
+	render(app, w, http.StatusOK, "admin_performance.tmpl.html", page)
+}
+
+// roundDuration rounds d to three significant figures or so, which is all
+// anyone reads of a latency.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// formatPercent writes a fraction as a percentage, like 2.5%.
+func formatPercent(f float64) string {
+	return fmt.Sprintf("%.1f%%", f*100)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/gob"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+)
+
+// tabWidths are the tab widths users can choose from. Each has a tab-N class
+// in the stylesheets.
+var tabWidths = []int{2, 4, 8}
+
+type preferencesForm struct {
+	Language            string `form:"language"`
+	Expires             int    `form:"expires"`
+	TabWidth            int    `form:"tab_width"`
+	InvitationEmails    bool   `form:"invitation_emails"`
+	validator.Validator `form:"-"`
+}
+
+// Anonymous visitors' last used choices are kept in their session, which
+// needs to know the type to encode it.
+func init() {
+	gob.Register(models.SnippetChoices{})
+}
+
+// currentPreferences returns the current user's preferences, or the defaults for
+// anonymous visitors, with whatever they last used from their session.
+func (app *application) currentPreferences(r *http.Request) (*models.Preferences, error) {
+	id := reqctx.UserID(r.Context())
+	if id == 0 {
+		defaults := models.DefaultPreferences
+		if last, ok := app.sessionManager.Get(r.Context(), "lastUsed").(models.SnippetChoices); ok {
+			defaults.LastUsed = &last
+		}
+		return &defaults, nil
+	}
+	return app.preferences.Get(id)
+}
+
+// rememberSnippetChoices keeps the choices made on the form for a new
+// snippet, for the form to start from next time: in the user's preferences,
+// or in the session for anonymous visitors.
+func (app *application) rememberSnippetChoices(r *http.Request, form *snippetCreateForm) error {
+	last := models.SnippetChoices{
+		Language:         form.Language,
+		Expires:          form.Expires,
+		BurnAfterReading: form.BurnAfterReading,
+		OrgOnly:          form.OrgOnly,
+	}
+
+	id := reqctx.UserID(r.Context())
+	if id == 0 {
+		app.sessionManager.Put(r.Context(), "lastUsed", last)
+		return nil
+	}
+
+	p, err := app.preferences.Get(id)
+	if err != nil {
+		return err
+	}
+	if p.LastUsed != nil && *p.LastUsed == last {
+		return nil
+	}
+	p.LastUsed = &last
+	return app.preferences.Set(id, p)
+}
+
+func (app *application) accountPreferences(w http.ResponseWriter, r *http.Request) {
+	p, err := app.currentPreferences(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ExpiryPolicy = policy
+	data.Form = preferencesForm{Language: p.Language, Expires: p.Expires, TabWidth: p.TabWidth, InvitationEmails: p.InvitationEmails}
+	app.render(w, http.StatusOK, "preferences.tmpl.html", data)
+}
+
+func (app *application) accountPreferencesPost(w http.ResponseWriter, r *http.Request) {
+	var form preferencesForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	_, known := languages.Lookup(form.Language)
+	form.CheckField(form.Language == "" || known, "language", "This field must be one of the supported languages")
+	if form.Expires != 0 {
+		checkExpires(&form.Validator, "expires", form.Expires, policy)
+	}
+	form.CheckField(validator.PermittedValue(form.TabWidth, tabWidths...), "tab_width", "This field must equal 2, 4 or 8")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.ExpiryPolicy = policy
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "preferences.tmpl.html", data)
+		return
+	}
+
+	// Saving preferences forgets what the user last used, so that new
+	// snippets start from them again.
+	err = app.preferences.Set(reqctx.UserID(r.Context()), &models.Preferences{
+		Language:         form.Language,
+		Expires:          form.Expires,
+		TabWidth:         form.TabWidth,
+		InvitationEmails: form.InvitationEmails,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your preferences have been saved.")
+	http.Redirect(w, r, urlFor("account.preferences"), http.StatusSeeOther)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAccountPreferences(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous visitors get the default tab width.
+	_, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<div class='snippet tab-8'>")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body = ts.get(t, "/account/preferences")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='0' checked> The site's default (One Year)")
+	assert.StringContains(t, body, "<input type='radio' name='tab_width' value='8' checked>")
+	assert.StringContains(t, body, "<input type='checkbox' name='invitation_emails' value='true' checked>")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/account/preferences"))
+	form.Add("language", "cobol-ish")
+	form.Add("expires", "30")
+	form.Add("tab_width", "3")
+
+	code, _, body := ts.postForm(t, "/account/preferences", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be one of the supported languages")
+	assert.StringContains(t, body, "This field must equal 1, 7 or 365")
+	assert.StringContains(t, body, "This field must equal 2, 4 or 8")
+
+	form.Set("language", "go")
+	form.Set("expires", "7")
+	form.Set("tab_width", "4")
+
+	code, headers, _ := ts.postForm(t, "/account/preferences", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Your preferences have been saved.")
+	assert.StringContains(t, body, "<input type='radio' name='tab_width' value='4' checked>")
+	assert.StringContains(t, body, "<input type='checkbox' name='invitation_emails' value='true'>")
+
+	// The create form starts from the new defaults.
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='go' selected>")
+	assert.StringContains(t, body, "value='7' checked")
+
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<div class='snippet tab-4'>")
+
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001?view=plain")
+	assert.StringContains(t, body, "<table class='code tab-4'>")
+}
+
+func TestSnippetCreateRemembersChoices(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/snippet/create"))
+	form.Add("title", "Title")
+	form.Add("content", "Content")
+	form.Add("language", "python")
+	form.Add("expires", "7")
+	form.Add("burn_after_reading", "true")
+
+	code, _, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The form starts from the last snippet's choices.
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='python' selected>")
+	assert.StringContains(t, body, "value='7' checked")
+	assert.StringContains(t, body, "<input type='checkbox' name='burn_after_reading' value='true' checked>")
+
+	// Until the user saves their preferences.
+	form = url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/account/preferences"))
+	form.Add("language", "go")
+	form.Add("expires", "0")
+	form.Add("tab_width", "8")
+
+	code, _, _ = ts.postForm(t, "/account/preferences", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='go' selected>")
+	assert.StringContains(t, body, "value='365' checked")
+	assert.StringContains(t, body, "<input type='checkbox' name='burn_after_reading' value='true'>")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+)
+
+// snippetPreviewPost renders the create form as the snippet it would
+// publish, for the form's Preview tab. The response is only the snippet
+// itself, made by the same template as the snippet's page, so the preview
+// looks exactly like what will be published. Nothing is stored.
+func (app *application) snippetPreviewPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Encrypted snippets are encrypted before they're sent, and their
+	// content must never reach us in the clear, so main.js doesn't offer a
+	// preview of them.
+	if form.ContentEncrypted {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The form is validated for the detected languages and files it fills
+	// in. A preview of a form with mistakes in it is still useful, so the
+	// mistakes are left for publishing to point out.
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	primary, files, publishAt := form.validate(policy, app.contentLimits)
+
+	// The additional files are numbered as they're stored.
+	for i, f := range files {
+		f.Position = i + 1
+	}
+
+	prefs, err := app.currentPreferences(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &snippetViewPage{templateBase: app.newTemplateBase(r), Preview: true, TabWidth: prefs.TabWidth}
+	data.Snippet = &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Filename:         primary.Filename,
+		Language:         primary.Language,
+		DetectedLanguage: primary.DetectedLanguage,
+		Files:            files,
+		UserID:           reqctx.UserID(r.Context()),
+		PublishAt:        publishAt,
+		BurnAfterReading: form.BurnAfterReading,
+	}
+	data.LintWarnings = snippetLintWarnings(data.Snippet)
+
+	// The preview goes into the create page as it is, so it's rendered
+	// without a layout around it.
+	renderLayout(app, w, http.StatusOK, "snippet", "view.tmpl.html", data)
+}
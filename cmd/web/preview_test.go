@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSnippetPreview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("title", "Frog <haiku>")
+	form.Add("content", "An old silent pond & a frog")
+	form.Add("filename", "pond.txt")
+	form.Add("expires", "7")
+	form.Add("files[0].filename", "notes.md")
+	form.Add("files[0].content", "Syllables: 5, 7, 5")
+	form.Add("files[1].filename", "")
+	form.Add("files[1].content", "")
+
+	// Like the rest of the create form, previews are for signed-in users.
+	_, _, body := ts.get(t, "/user/login")
+	form.Set("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	form.Set("csrf_token", ts.csrfToken(t, "/snippet/create"))
+
+	code, _, body = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<strong>Frog &lt;haiku&gt;</strong>")
+	assert.StringContains(t, body, "<pre><code>An old silent pond &amp; a frog</code></pre>")
+	assert.StringContains(t, body, "<a href='#file-1'>notes.md</a>")
+	assert.StringContains(t, body, "notes.md &middot; Markdown <em>(detected)</em>")
+
+	// It's only the snippet, without the page around it or the links to a
+	// snippet which doesn't exist yet.
+	assert.Equal(t, strings.Contains(body, "<html"), false)
+	assert.Equal(t, strings.Contains(body, "/snippet/raw/"), false)
+	assert.Equal(t, strings.Contains(body, "Created:"), false)
+
+	// Problems with the form are left for publishing to point out.
+	form.Set("title", "")
+	code, _, _ = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusOK)
+
+	// Encrypted content is never sent in the clear, so there's nothing to
+	// preview.
+	form.Set("content_encrypted", "true")
+	code, _, _ = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusBadRequest)
+}
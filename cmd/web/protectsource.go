@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"net/http"
+)
+
+// Private deployments, like one inside a company, can protect their source
+// with the protect_source feature flag. Snippets can then only be read on
+// their pages: the routes marked as source routes, which hand out their
+// content as raw text, as files, or to other programs through the API, the
+// Atom feed and ActivityPub, are turned off, and the links to them aren't
+// shown. GraphQL, which also creates snippets, stays on, but has no content
+// to give out, and nothing is sent to the instance's Fediverse followers.
+// Every response asks search engines not to index it, in case the site can
+// be reached from outside after all.
+
+// protectsSource reports whether the instance protects its source.
+func (app *application) protectsSource() bool {
+	return app.features.Enabled(features.ProtectSource)
+}
+
+// requireSourceVisible answers requests for source routes with a 404 Not
+// Found, in the format the route answers in, while the instance protects
+// its source, as if they didn't exist.
+func (app *application) requireSourceVisible(notFound func(http.ResponseWriter)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.protectsSource() {
+				notFound(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// noIndex asks search engines not to index, follow or keep copies of
+// anything while the instance protects its source.
+func (app *application) noIndex(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.protectsSource() {
+			w.Header().Set("X-Robots-Tag", "noindex, nofollow, noarchive")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProtectSource(t *testing.T) {
+	app := newTestApplication(t)
+	app.features = features.New(&mocks.FeatureModel{}, map[string]bool{features.ProtectSource: true})
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// The snippet can still be read on its page, without the links.
+	code, headers, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-Robots-Tag"), "noindex, nofollow, noarchive")
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.Equal(t, strings.Contains(body, "/snippet/raw/00000000000000000000000001/0"), false)
+	assert.Equal(t, strings.Contains(body, "/snippet/download/00000000000000000000000001/0"), false)
+
+	for _, path := range []string{"/snippet/raw/00000000000000000000000001/0", "/snippet/download/00000000000000000000000001/0"} {
+		code, headers, _ = ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, headers.Get("X-Robots-Tag"), "noindex, nofollow, noarchive")
+	}
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, _ = ts.get(t, "/account/snippets/download")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body = ts.get(t, "/account/view")
+	assert.Equal(t, strings.Contains(body, "Download all as ZIP"), false)
+}
+
+func TestProtectSourceOff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("X-Robots-Tag"), "")
+	assert.StringContains(t, body, "<a href='/snippet/raw/00000000000000000000000001/0'>Raw</a>")
+
+	code, _, _ = ts.get(t, "/snippet/raw/00000000000000000000000001/0")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestProtectSourceAPI(t *testing.T) {
+	app := newTestApplication(t, withActivityPub(t, true))
+	app.features = features.New(&mocks.FeatureModel{}, map[string]bool{features.ActivityPub: true, features.ProtectSource: true})
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	assert.Equal(t, app.federating(), false)
+
+	// The API's and ActivityPub's snippet routes are gone, in their own
+	// format.
+	for _, path := range []string{"/api/v1/snippets", "/api/v1/snippets?cursor=", "/api/v1/snippets/00000000000000000000000001", "/ap/outbox", "/ap/notes/00000000000000000000000001"} {
+		code, headers, body := ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, strings.Contains(headers.Get("Content-Type"), "html"), false)
+		assert.Equal(t, strings.Contains(body, "An old silent pond..."), false)
+	}
+
+	// GraphQL still answers, but without the content.
+	code, _, body := ts.postJSON(t, "/graphql", `{"query": "{ snippet(id: \"00000000000000000000000001\") { title content files { filename content } } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"title": "An old silent pond"`)
+	assert.StringContains(t, body, `"filename": "frog.txt"`)
+	assert.StringContains(t, body, `"content": null`)
+	assert.Equal(t, strings.Contains(body, "An old silent pond..."), false)
+	assert.Equal(t, strings.Contains(body, "A frog jumps into the pond"), false)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/feed.atom")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, strings.Contains(body, "An old silent pond..."), false)
+
+	_, _, body = ts.get(t, "/feed")
+	assert.Equal(t, strings.Contains(body, "/feed.atom"), false)
+}
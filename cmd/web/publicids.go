@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Snippets are known outside the database by their public IDs, which can't
+// be guessed from each other; see internal/publicid. The routes marked as
+// publicID routes take one in their id parameter, and it's swapped for the
+// snippet's own ID before their handlers see it, so the handlers work with
+// database IDs as before. Anything else in the parameter, including a
+// database ID, finds nothing, so that snippets can't be found by counting.
+
+// snippetIDFor returns the database ID of the snippet with the given public
+// ID, or models.ErrNoRecord if there isn't one.
+func (app *application) snippetIDFor(publicID string) (int, error) {
+	publicID, ok := publicid.Normalize(publicID)
+	if !ok {
+		return 0, models.ErrNoRecord
+	}
+	return app.snippets.IDForPublicID(publicID)
+}
+
+// visibleSnippetFor is visibleSnippet for the snippet with the given public
+// ID.
+func (app *application) visibleSnippetFor(r *http.Request, publicID string) (*models.Snippet, error) {
+	id, err := app.snippetIDFor(publicID)
+	if err != nil {
+		return nil, err
+	}
+	return app.visibleSnippet(r, id)
+}
+
+// publicIDFromInput returns the public ID in what someone typed to mean a
+// snippet, which is either the ID or a link to it.
+func publicIDFromInput(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "/")
+	return s[strings.LastIndexByte(s, '/')+1:]
+}
+
+// resolveSnippetID swaps the public ID in the id parameter for the
+// snippet's database ID, and keeps it in the publicID parameter for links
+// back to the snippet. Unknown IDs become 0, which every handler answers
+// with a 404 Not Found in its own format.
+func (app *application) resolveSnippetID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		publicID := params.ByName("id")
+		id, err := app.snippetIDFor(publicID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		// The params are shared with the router, so they're copied rather
+		// than changed in place.
+		resolved := make(httprouter.Params, len(params), len(params)+1)
+		copy(resolved, params)
+		for i := range resolved {
+			if resolved[i].Key == "id" {
+				resolved[i].Value = strconv.Itoa(id)
+			}
+		}
+		resolved = append(resolved, httprouter.Param{Key: "publicID", Value: publicID})
+
+		ctx := context.WithValue(r.Context(), httprouter.ParamsKey, resolved)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSnippetPublicIDs(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Only public IDs find snippets, on the pages and in the API alike.
+	for _, urlPath := range []string{"/snippet/view/1", "/snippet/raw/1/0", "/api/v1/snippets/1", "/ap/notes/1"} {
+		code, _, _ := ts.get(t, urlPath)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	code, _, body := ts.get(t, "/api/v1/snippets/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"id": "00000000000000000000000001"`)
+
+	// Links to other pages use the public ID too.
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<a href='/snippet/raw/00000000000000000000000001/0'>Raw</a>")
+	assert.StringContains(t, body, "<input type='hidden' name='from' value='00000000000000000000000001'>")
+}
+
+func TestPublicIDFromInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{" 01ARZ3NDEKTSV4RRFFQ69G5FAV\n", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{"https://snippets.example/snippet/view/01ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{"https://snippets.example/snippet/view/01ARZ3NDEKTSV4RRFFQ69G5FAV/?view=plain#file-1", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, publicIDFromInput(tt.input), tt.want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxQuickBodyBytes limits the size of the snippets posted to quickCreate.
+const maxQuickBodyBytes = 1_048_576
+
+// quickCreate creates a snippet from the raw request body, for use from the
+// command line:
+//
+//	curl --data-binary @main.go -H "Authorization: Bearer $TOKEN" https://host/api/quick
+//
+// The title is taken from the X-Title header, or else the first line of the
+// content, and the language is detected unless X-Language names one. The
+// X-Filename and X-Expires headers set the filename and how many days the
+// snippet lasts, or "never" where the expiry policy allows it (the user's
+// preference by default). Everything, including errors, is plain text, and a
+// successful response is just the snippet's URL, so that it can be piped
+// straight into something else.
+func (app *application) quickCreate(w http.ResponseWriter, r *http.Request) {
+	userID := reqctx.UserID(r.Context())
+	if userID == 0 {
+		w.Header().Set("WWW-Authenticate", bearerChallenge)
+		quickError(w, http.StatusUnauthorized, "an API token is required; create one at "+app.absoluteURL(urlFor("account.tokens")))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuickBodyBytes)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			quickError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the snippet must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		quickError(w, http.StatusBadRequest, "reading the request body: "+err.Error())
+		return
+	}
+	if !utf8.Valid(body) {
+		quickError(w, http.StatusUnsupportedMediaType, "the snippet must be UTF-8 text")
+		return
+	}
+	content := string(body)
+
+	prefs, err := app.preferences.Get(userID)
+	if err != nil {
+		app.quickServerError(w, err)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.quickServerError(w, err)
+		return
+	}
+
+	expires := preferredExpires(prefs, policy)
+	if h := r.Header.Get("X-Expires"); h == "never" {
+		expires = models.NeverExpires
+	} else if h != "" {
+		expires, err = strconv.Atoi(h)
+		if err != nil {
+			expires = 0
+		}
+	}
+
+	title := strings.TrimSpace(r.Header.Get("X-Title"))
+	if title == "" {
+		title = quickTitle(content)
+	}
+
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(content), "content", "The snippet cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "The title cannot be more than 100 characters long")
+	v.CheckField(policy.Permits(expires), "expires", "X-Expires must equal "+policy.String())
+
+	primary := snippetFileForm{
+		Filename: strings.TrimSpace(r.Header.Get("X-Filename")),
+		Language: strings.TrimSpace(r.Header.Get("X-Language")),
+		Content:  content,
+	}
+	validateSnippetFiles(&v, app.contentLimits, &primary, nil)
+	content = primary.Content
+
+	if !v.Valid() {
+		app.quickFailedValidation(w, v.FieldErrors)
+		return
+	}
+
+	snippet := &models.Snippet{
+		Title:            title,
+		Content:          content,
+		Filename:         primary.Filename,
+		Language:         primary.Language,
+		DetectedLanguage: primary.DetectedLanguage,
+		UserID:           userID,
+	}
+
+	id, err := app.snippets.Insert(snippet, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrTooLarge) {
+			quickError(w, http.StatusRequestEntityTooLarge, "the snippet is too large to save")
+		} else if errors.Is(err, models.ErrExpiryNotPermitted) {
+			quickError(w, http.StatusUnprocessableEntity, "the expiry policy has changed, and no longer permits this expiry")
+		} else {
+			app.quickServerError(w, err)
+		}
+		return
+	}
+
+	snippet.ID = id
+	app.emitWebhookEvent(webhooks.SnippetCreated, userID, app.webhookSnippet(snippet))
+	app.publishActivity(snippet)
+
+	url := app.absoluteURL(urlFor("snippet.view", snippet.PublicID))
+
+	w.Header().Set("Location", url)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, url)
+}
+
+// quickTitle returns the first non-blank line of content, cut short if it's
+// too long for a title. Content which is all blank gets a placeholder, and is
+// then refused for being blank.
+func quickTitle(content string) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(nil, maxQuickBodyBytes)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > 100 {
+			line = string([]rune(line)[:99]) + "…"
+		}
+		return line
+	}
+	return "Untitled"
+}
+
+// quickFailedValidation sends the validation errors as plain text, one per
+// line, in a 422 Unprocessable Entity response.
+func (app *application) quickFailedValidation(w http.ResponseWriter, fieldErrors map[string]string) {
+	messages := make([]string, 0, len(fieldErrors))
+	for _, message := range fieldErrors {
+		messages = append(messages, message)
+	}
+	sort.Strings(messages)
+
+	quickError(w, http.StatusUnprocessableEntity, strings.Join(messages, "\n"))
+}
+
+// quickError sends a plain text error response. It's the quick paste
+// endpoint's equivalent of apiErrorResponse.
+func quickError(w http.ResponseWriter, status int, message string) {
+	http.Error(w, message, status)
+}
+
+// quickServerError is the plain text equivalent of serverError.
+func (app *application) quickServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, err.Error())
+
+	quickError(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQuickCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	token, err := app.apiTokens.Insert(1, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := http.Header{"Authorization": {"Bearer " + token}}
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  http.Header
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "No token",
+			method:   http.MethodPost,
+			body:     "package main\n",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "an API token is required",
+		},
+		{
+			name:     "Unknown token",
+			method:   http.MethodPost,
+			headers:  http.Header{"Authorization": {"Bearer nope"}},
+			body:     "package main\n",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid or revoked API token",
+		},
+		{
+			name:     "Wrong scheme",
+			method:   http.MethodPost,
+			headers:  http.Header{"Authorization": {"Basic " + token}},
+			body:     "package main\n",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "POST",
+			method:   http.MethodPost,
+			headers:  auth,
+			body:     "package main\n\nfunc main() {}\n",
+			wantCode: http.StatusCreated,
+			wantBody: "/snippet/view/00000000000000000000000001\n",
+		},
+		{
+			name:     "PUT",
+			method:   http.MethodPut,
+			headers:  auth,
+			body:     "SELECT 1;\n",
+			wantCode: http.StatusCreated,
+			wantBody: "/snippet/view/00000000000000000000000001\n",
+		},
+		{
+			name:     "Blank",
+			method:   http.MethodPost,
+			headers:  auth,
+			body:     "\n  \n",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "The snippet cannot be blank",
+		},
+		{
+			name:     "Bad expiry",
+			method:   http.MethodPost,
+			headers:  http.Header{"Authorization": auth["Authorization"], "X-Expires": {"30"}},
+			body:     "hello\n",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "X-Expires must equal 1, 7 or 365",
+		},
+		{
+			name:     "Unknown language",
+			method:   http.MethodPost,
+			headers:  http.Header{"Authorization": auth["Authorization"], "X-Language": {"klingon"}},
+			body:     "hello\n",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "supported languages",
+		},
+		{
+			name:     "Not UTF-8",
+			method:   http.MethodPost,
+			headers:  auth,
+			body:     "\xff\xfe",
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "Too large",
+			method:   http.MethodPost,
+			headers:  auth,
+			body:     strings.Repeat("x", maxQuickBodyBytes+1),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.do(t, tt.method, "/api/quick", tt.headers, tt.body)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+
+			if code == http.StatusCreated {
+				assert.Equal(t, headers.Get("Location"), strings.TrimSpace(body))
+			}
+			if code == http.StatusUnauthorized {
+				assert.Equal(t, headers.Get("WWW-Authenticate"), `Bearer realm="snippetbox"`)
+			}
+		})
+	}
+
+	// Using the token is recorded, so it shows on the tokens page.
+	tokens, err := app.apiTokens.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tokens[0].LastUsed != nil, true)
+}
+
+func TestQuickTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"First line", "package main\n\nfunc main() {}\n", "package main"},
+		{"Leading blank lines", "\n\n   // hello  \nworld", "// hello"},
+		{"Blank", " \n\t\n", "Untitled"},
+		{"Long", strings.Repeat("é", 150), strings.Repeat("é", 99) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, quickTitle(tt.content), tt.want)
+		})
+	}
+}
+
+func TestAccountAPITokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/account/tokens")
+	assert.StringContains(t, body, "You haven't created any API tokens yet.")
+
+	code, _, body := ts.postForm(t, "/account/tokens", url.Values{"name": {""}, "csrf_token": {ts.csrfToken(t, "/account/tokens")}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	code, headers, _ := ts.postForm(t, "/account/tokens", url.Values{"name": {"Laptop"}, "csrf_token": {ts.csrfToken(t, "/account/tokens")}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The token is shown once, and then only its name.
+	_, _, body = ts.followRedirect(t, code, headers)
+	assert.StringContains(t, body, "<code>APITOKEN1</code>")
+	assert.StringContains(t, body, "<td>Laptop</td>")
+
+	_, _, body = ts.get(t, "/account/tokens")
+	assert.Equal(t, strings.Contains(body, "APITOKEN1"), false)
+
+	code, _, _ = ts.do(t, http.MethodPost, "/api/quick", http.Header{"Authorization": {"Bearer APITOKEN1"}}, "hello")
+	assert.Equal(t, code, http.StatusCreated)
+
+	code, headers, _ = ts.postForm(t, "/account/tokens/delete/1", url.Values{"csrf_token": {ts.csrfToken(t, "/account/tokens")}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Your API token has been revoked.")
+
+	code, _, _ = ts.do(t, http.MethodPost, "/api/quick", http.Header{"Authorization": {"Bearer APITOKEN1"}}, "hello")
+	assert.Equal(t, code, http.StatusUnauthorized)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"net/http"
+)
+
+// The instance can be made read-only with the read_only feature flag, to
+// freeze it before it's shut down for good or while its data is being
+// migrated. Snippets can still be read, and people can sign in and out, but
+// everything else which would change something is refused. Admins can carry
+// on, so that they can turn it off again.
+
+const (
+	readOnlyMessage    = "This site is read-only at the moment. Snippets can still be read, but nothing can be created or changed."
+	apiReadOnlyMessage = "the site is read-only, so nothing can be created or changed"
+)
+
+// isReadOnly reports whether the instance is read-only.
+func (app *application) isReadOnly() bool {
+	return app.features.Enabled(features.ReadOnly)
+}
+
+// requireWritable answers requests with a 503 Service Unavailable and the
+// given message while the instance is read-only. Errors are sent with refuse,
+// so that the API can send them as JSON.
+func (app *application) requireWritable(refuse func(http.ResponseWriter, int, string), message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.isReadOnly() {
+				refuse(w, http.StatusServiceUnavailable, message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
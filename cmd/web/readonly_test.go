@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	app := newTestApplication(t)
+	app.features = features.New(&mocks.FeatureModel{}, map[string]bool{features.ReadOnly: true})
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Signing in still works.
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This site is read-only at the moment.")
+	assert.Equal(t, strings.Contains(body, "Create snippet"), false)
+
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	form := url.Values{}
+	form.Add("title", "Title")
+	form.Add("content", "Content")
+	form.Add("expires", "7")
+	form.Add("csrf_token", csrfToken)
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, "nothing can be created or changed")
+
+	// Previews change nothing.
+	code, _, _ = ts.postForm(t, "/snippet/preview", form)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "Title", "content": "Content", "expires": 7}`)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, `"error": "the site is read-only, so nothing can be created or changed"`)
+
+	// GraphQL queries are posted too, but only mutations are refused.
+	code, _, body = ts.postJSON(t, "/graphql", `{"query": "mutation { createSnippet(title: \"Title\", content: \"Content\") { id } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"message": "the site is read-only, so nothing can be created or changed"`)
+
+	code, _, _ = ts.postJSON(t, "/graphql", `{"query": "{ viewer { name } }"}`)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestReadOnlyAdmin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/admin/features")
+
+	// Admins can turn read-only mode on and off again.
+	for _, enabled := range []string{"true", "false"} {
+		form := url.Values{}
+		form.Add("name", features.ReadOnly)
+		form.Add("enabled", enabled)
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postForm(t, "/admin/features", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, app.isReadOnly(), enabled == "true")
+	}
+}
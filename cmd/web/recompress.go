@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"log"
+	"os"
+	"time"
+)
+
+// recompressCommand implements "web recompress", which rewrites existing
+// snippets so their storage matches a compression threshold: large contents
+// are compressed and small ones decompressed. It works through the snippets
+// in batches, each in its own transaction, so it can run against a live
+// database, and can be stopped and restarted from where it got to with
+// -after.
+func recompressCommand(args []string) error {
+	fs := flag.NewFlagSet("recompress", flag.ExitOnError)
+	dsn := fs.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	threshold := fs.Int("compress-snippets-above", models.DefaultCompressAbove, "Store snippet contents larger than this many bytes compressed (-1 turns compression off)")
+	batch := fs.Int("batch", 500, "Number of snippets to rewrite in each transaction")
+	after := fs.Int("after", 0, "Only rewrite snippets with IDs above this one")
+	maxWait := fs.Duration("db-max-wait", 30*time.Second, "How long to keep trying to reach the database before giving up")
+	fs.Parse(args)
+
+	infoLog := log.New(os.Stderr, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	retry := dbRetry{initialDelay: 500 * time.Millisecond, maxDelay: 5 * time.Second, maxWait: *maxWait}
+
+	db, err := openDB(*dsn, retry, errorLog)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := &models.SnippetModel{DB: db, CompressAbove: *threshold}
+
+	lastID, total := *after, 0
+	for {
+		next, changed, err := m.Recompress(lastID, *batch)
+		if err != nil {
+			return err
+		}
+		if next == 0 {
+			break
+		}
+		lastID = next
+		total += changed
+		infoLog.Printf("rewrote %d contents of snippets up to ID %d", changed, lastID)
+	}
+	infoLog.Printf("done: rewrote %d contents in total", total)
+
+	return nil
+}
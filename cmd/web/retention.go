@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// retentionLookahead is how far ahead the admin retention page looks for
+// data which is about to be purged.
+const retentionLookahead = 7 * 24 * time.Hour
+
+// retentionPolicy is how long data is kept for, as set by the -retention-*
+// flags. A period of zero turns that policy off, leaving the data to expire
+// or be deleted as it otherwise would. In a dry run the purges are only
+// logged. It is safe for concurrent use.
+type retentionPolicy struct {
+	AnonymousSnippetDays int
+	AuditLogMonths       int
+	StatsDetailDays      int
+	DryRun               bool
+
+	// auditLog is the file the audit log is written to, if it has one of
+	// its own.
+	auditLog string
+
+	mu         sync.Mutex
+	lastRun    time.Time
+	lastPurged int
+}
+
+// newRetentionPolicy checks the -retention-* flags. The audit log can only
+// be purged when it's written to a file of its own, so that nothing else's
+// logs are deleted with it.
+func newRetentionPolicy(cfg config) (*retentionPolicy, error) {
+	p := &retentionPolicy{
+		AnonymousSnippetDays: cfg.retention.anonymousSnippetDays,
+		AuditLogMonths:       cfg.retention.auditLogMonths,
+		StatsDetailDays:      cfg.retention.statsDetailDays,
+		DryRun:               cfg.retention.dryRun,
+	}
+
+	switch cfg.log.auditOutput {
+	case "", "stdout", "stderr", cfg.log.output, cfg.log.accessOutput:
+	default:
+		p.auditLog = cfg.log.auditOutput
+	}
+
+	var problems []string
+	if p.AnonymousSnippetDays < 0 {
+		problems = append(problems, "-retention-anonymous-snippets can't be negative")
+	}
+	if p.AuditLogMonths < 0 {
+		problems = append(problems, "-retention-audit-log can't be negative")
+	} else if p.AuditLogMonths > 0 && p.auditLog == "" {
+		problems = append(problems, "-retention-audit-log needs -audit-log-output to name a file which no other log is written to")
+	}
+	if p.StatsDetailDays < 0 {
+		problems = append(problems, "-retention-stats-detail can't be negative")
+	}
+
+	if err := joinProblems(problems); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// retentionRule is one of the policies which is turned on: what it purges,
+// and how to count and purge what's older than a cutoff.
+type retentionRule struct {
+	name   string
+	kept   string
+	cutoff func(now time.Time) time.Time
+	count  func(before time.Time) (int, error)
+	purge  func(before time.Time) (int, error)
+}
+
+// retentionRules returns the rules for the policies which are turned on.
+func (app *application) retentionRules() []retentionRule {
+	p := app.retention
+	var rules []retentionRule
+
+	if p.AnonymousSnippetDays > 0 {
+		rules = append(rules, retentionRule{
+			name: "anonymous snippets",
+			kept: fmt.Sprintf("%d days after they're created", p.AnonymousSnippetDays),
+			cutoff: func(now time.Time) time.Time {
+				return now.AddDate(0, 0, -p.AnonymousSnippetDays)
+			},
+			count: app.snippets.CountAnonymousBefore,
+			purge: app.snippets.DeleteAnonymousBefore,
+		})
+	}
+
+	// Views are aggregated by the day, so they're purged by the day too.
+	if p.StatsDetailDays > 0 {
+		rules = append(rules, retentionRule{
+			name: "referrers, countries and browsers in snippet statistics",
+			kept: fmt.Sprintf("%d days, after which only the daily totals are kept", p.StatsDetailDays),
+			cutoff: func(now time.Time) time.Time {
+				return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -p.StatsDetailDays)
+			},
+			count: app.snippetStats.CountDetailBefore,
+			purge: app.snippetStats.DeleteDetailBefore,
+		})
+	}
+
+	if p.AuditLogMonths > 0 {
+		rules = append(rules, retentionRule{
+			name: "rotated audit log files",
+			kept: fmt.Sprintf("%d months after they're rotated", p.AuditLogMonths),
+			cutoff: func(now time.Time) time.Time {
+				return now.AddDate(0, -p.AuditLogMonths, 0)
+			},
+			count: func(before time.Time) (int, error) {
+				return purgeAuditLogs(p.auditLog, before, false)
+			},
+			purge: func(before time.Time) (int, error) {
+				return purgeAuditLogs(p.auditLog, before, true)
+			},
+		})
+	}
+
+	return rules
+}
+
+// purgeAuditLogs counts the files which the audit log was rotated into
+// before the given time, and deletes them if remove is set.
+func purgeAuditLogs(path string, before time.Time, remove bool) (int, error) {
+	backups, err := logging.Backups(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, b := range backups {
+		if !b.Rotated.Before(before) {
+			break
+		}
+		if remove {
+			if err := os.Remove(b.Path); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// applyRetention purges whatever the retention policies say has been kept
+// long enough, or only logs what it would purge in a dry run. It runs every
+// hour, and when an admin asks for it. A policy which fails doesn't stop the
+// others.
+func (app *application) applyRetention() (int, error) {
+	now := time.Now()
+	total := 0
+	var errs []error
+
+	for _, rule := range app.retentionRules() {
+		before := rule.cutoff(now)
+
+		if app.retention.DryRun {
+			n, err := rule.count(before)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", rule.name, err))
+			} else if n > 0 {
+				app.infoLog.Printf("retention (dry run): would purge %d %s from before %s", n, rule.name, before.UTC().Format(time.RFC3339))
+			}
+			total += n
+			continue
+		}
+
+		n, err := rule.purge(before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rule.name, err))
+		}
+		if n > 0 {
+			app.infoLog.Printf("retention: purged %d %s from before %s", n, rule.name, before.UTC().Format(time.RFC3339))
+		}
+		total += n
+	}
+
+	app.retention.mu.Lock()
+	app.retention.lastRun = now
+	app.retention.lastPurged = total
+	app.retention.mu.Unlock()
+
+	return total, errors.Join(errs...)
+}
+
+// retentionPurge is what one retention policy purges: Due is how much is
+// past its cutoff now, and Soon how much more will be within
+// retentionLookahead.
+type retentionPurge struct {
+	Name   string
+	Kept   string
+	Cutoff time.Time
+	Due    int
+	Soon   int
+}
+
+// adminRetention shows the retention policies and what they're about to
+// purge.
+func (app *application) adminRetention(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	data := &adminRetentionPage{
+		templateBase:  app.newTemplateBase(r),
+		DryRun:        app.retention.DryRun,
+		LookaheadDays: int(retentionLookahead / (24 * time.Hour)),
+	}
+
+	for _, rule := range app.retentionRules() {
+		before := rule.cutoff(now)
+
+		due, err := rule.count(before)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		soon, err := rule.count(before.Add(retentionLookahead))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Purges = append(data.Purges, retentionPurge{Name: rule.name, Kept: rule.kept, Cutoff: before, Due: due, Soon: soon - due})
+	}
+
+	app.retention.mu.Lock()
+	data.LastRun, data.LastPurged = app.retention.lastRun, app.retention.lastPurged
+	app.retention.mu.Unlock()
+
+	render(app, w, http.StatusOK, "admin_retention.tmpl.html", data)
+}
+
+// adminRetentionRunPost applies the retention policies straight away.
+func (app *application) adminRetentionRunPost(w http.ResponseWriter, r *http.Request) {
+	n, err := app.applyRetention()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if app.retention.DryRun {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Dry run: %d items would have been purged. See the log for details.", n))
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Purged %d items.", n))
+	}
+
+	http.Redirect(w, r, urlFor("admin.retention"), http.StatusSeeOther)
+}
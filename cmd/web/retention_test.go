@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config)
+		wantErr   bool
+	}{
+		{"Defaults", func(cfg *config) {}, false},
+		{"Negative", func(cfg *config) { cfg.retention.anonymousSnippetDays = -1 }, true},
+		{"Audit log to stdout", func(cfg *config) { cfg.retention.auditLogMonths = 6 }, true},
+		{"Audit log shared with the application log", func(cfg *config) {
+			cfg.retention.auditLogMonths = 6
+			cfg.log.output = "app.log"
+			cfg.log.auditOutput = "app.log"
+		}, true},
+		{"Audit log of its own", func(cfg *config) {
+			cfg.retention.auditLogMonths = 6
+			cfg.log.auditOutput = "audit.log"
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig(t)
+			tt.configure(&cfg)
+
+			_, err := newRetentionPolicy(cfg)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestAdminRetention(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/retention")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No retention policies are set")
+
+	// Two rotated audit logs are past the cutoff, and one will be in a few
+	// days.
+	dir := t.TempDir()
+	backup := func(rotated time.Time) string {
+		return filepath.Join(dir, "audit-"+rotated.UTC().Format("2006-01-02T15-04-05.000")+".log.gz")
+	}
+	old := backup(time.Now().AddDate(0, -3, 0))
+	older := backup(time.Now().AddDate(0, -4, 0))
+	soon := backup(time.Now().AddDate(0, -2, 3))
+	for _, path := range []string{old, older, soon} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app.retention = &retentionPolicy{AuditLogMonths: 2, StatsDetailDays: 30, DryRun: true, auditLog: filepath.Join(dir, "audit.log")}
+
+	code, _, body = ts.get(t, "/admin/retention")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This is a dry run")
+	assert.StringContains(t, body, "<td>rotated audit log files</td>\n        <td>2 months after they&#39;re rotated</td>\n        <td>2</td>\n        <td>1</td>")
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.csrfToken(t, "/admin/retention"))
+
+	// A dry run leaves everything where it is...
+	code, headers, _ := ts.postForm(t, "/admin/retention/run", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Dry run: 2 items would have been purged. See the log for details.")
+	_, err := os.Stat(old)
+	assert.Equal(t, err, nil)
+
+	// ...and a real one purges them.
+	app.retention.DryRun = false
+	code, headers, _ = ts.postForm(t, "/admin/retention/run", form)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Purged 2 items.")
+	_, err = os.Stat(old)
+	assert.Equal(t, os.IsNotExist(err), true)
+	_, err = os.Stat(soon)
+	assert.Equal(t, err, nil)
+
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/admin/retention")
+	assert.Equal(t, code, http.StatusForbidden)
+}
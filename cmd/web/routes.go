@@ -1,18 +1,318 @@
 package main
 
 import (
+	"fmt"
 	"github.com/julienschmidt/httprouter"
-	"github.com/justinas/alice"
-	"github.com/ngohoang211020/snippetbox/ui"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/middleware"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
+// middlewareSet names one of the middleware chains which routes run
+// through. The chains themselves are put together in middlewareStacks.
+type middlewareSet int
+
+const (
+	// chainNone adds nothing to the standard middleware.
+	chainNone middlewareSet = iota
+	chainCSPReport
+	chainDynamic
+	chainSignup
+	chainLogin
+	chainPasswordLogin
+	chainLocalLogin
+	chainProtected
+	chainProtectedLocal
+	chainAdmin
+	chainAPI
+	chainAPIProtected
+	chainQuick
+	chainActivityPub
+	chainOneClick
+)
+
+// route is an entry in the route table. Routes for the same URL with
+// different methods, like a form and the handler it's posted to, share a
+// name.
+type route struct {
+	name    string
+	method  string
+	pattern string
+	chain   middlewareSet
+	handler func(*application, http.ResponseWriter, *http.Request)
+
+	// with names middleware from routeMiddleware which runs inside the
+	// route's chain, for the odd route that needs something the rest of
+	// its chain doesn't.
+	with []string
+
+	// debugOnly routes are development aids which give away how the site
+	// is put together, so they only exist in debug mode.
+	debugOnly bool
+
+	// readOnlySafe routes keep taking posts while the instance is
+	// read-only, since all they do is check input or sign people in and
+	// out. Admin routes always do, so that admins can turn read-only mode
+	// off again.
+	readOnlySafe bool
+
+	// source routes hand out snippets' content as it is, or to other
+	// programs, rather than on a page, so they're turned off while the
+	// instance protects its source.
+	source bool
+
+	// publicID routes take a snippet's public ID as their id parameter,
+	// which is swapped for its database ID before the handler sees it.
+	publicID bool
+
+	// adminOnly routes are only served on the admin listeners, as they'd
+	// give too much away anywhere else. Without -admin-addr they aren't
+	// served at all.
+	adminOnly bool
+}
+
+// operational reports whether the route is for the people running the site
+// rather than its visitors: the admin pages, the metrics and the debugging
+// aids. With -admin-addr they're only served on the admin listeners.
+func (rt route) operational() bool {
+	return rt.chain == chainAdmin || rt.name == "metrics" || rt.debugOnly || rt.adminOnly
+}
+
+// routeTable lists every route. Links and redirects are made from it with
+// urlFor, so that a route can be moved without hunting down every link to
+// it.
+var routeTable = []route{
+	{name: "static", method: http.MethodGet, pattern: "/static/*filepath", chain: chainNone, handler: (*application).staticFiles},
+	{name: "ping", method: http.MethodGet, pattern: "/ping", chain: chainNone, handler: (*application).ping},
+	{name: "ready", method: http.MethodGet, pattern: "/ready", chain: chainNone, handler: (*application).ready},
+	{name: "healthz", method: http.MethodGet, pattern: "/healthz", chain: chainNone, handler: (*application).healthz},
+	{name: "metrics", method: http.MethodGet, pattern: "/metrics", chain: chainNone, handler: (*application).metrics},
+	{name: "debug.templates", method: http.MethodGet, pattern: "/debug/templates", chain: chainNone, handler: (*application).debugTemplates, debugOnly: true},
+	{name: "debug.pprof", method: http.MethodGet, pattern: "/debug/pprof/*name", chain: chainNone, handler: (*application).debugPprof, adminOnly: true},
+	{name: "well-known", method: http.MethodGet, pattern: "/.well-known/*name", chain: chainNone, handler: (*application).wellKnown},
+	{name: "csp-report", method: http.MethodPost, pattern: "/csp-report", chain: chainCSPReport, handler: (*application).cspReport},
+
+	{name: "home", method: http.MethodGet, pattern: "/", chain: chainDynamic, handler: (*application).home},
+	{name: "languages", method: http.MethodGet, pattern: "/languages", chain: chainDynamic, handler: (*application).languageIndex},
+	{name: "language", method: http.MethodGet, pattern: "/language/:lang", chain: chainDynamic, handler: (*application).languageSnippets},
+	{name: "snippet.view", method: http.MethodGet, pattern: "/snippet/view/:id", chain: chainDynamic, handler: (*application).snippetView, with: []string{"recordView"}, publicID: true},
+	{name: "snippet.burn", method: http.MethodPost, pattern: "/snippet/burn/:id", chain: chainDynamic, handler: (*application).snippetBurnPost, publicID: true},
+	{name: "snippet.raw", method: http.MethodGet, pattern: "/snippet/raw/:id/:position", chain: chainDynamic, handler: (*application).snippetFileRaw, source: true, publicID: true},
+	{name: "diff", method: http.MethodGet, pattern: "/diff", chain: chainDynamic, handler: (*application).snippetDiffView},
+	{name: "snippet.download", method: http.MethodGet, pattern: "/snippet/download/:id/:position", chain: chainDynamic, handler: (*application).snippetFileDownload, source: true, publicID: true},
+	{name: "about", method: http.MethodGet, pattern: "/about", chain: chainDynamic, handler: (*application).about},
+	{name: "changelog", method: http.MethodGet, pattern: "/changelog", chain: chainDynamic, handler: (*application).changelogView},
+	{name: "user.profile", method: http.MethodGet, pattern: "/user/profile/:id", chain: chainDynamic, handler: (*application).userProfile},
+	{name: "collection.view", method: http.MethodGet, pattern: "/collection/:slug", chain: chainDynamic, handler: (*application).collectionView},
+	{name: "collection.download", method: http.MethodGet, pattern: "/collection/:slug/download", chain: chainDynamic, handler: (*application).collectionDownload, source: true},
+	{name: "org.view", method: http.MethodGet, pattern: "/org/:slug", chain: chainDynamic, handler: (*application).orgView},
+	{name: "account.email.confirm", method: http.MethodGet, pattern: "/account/email/confirm", chain: chainDynamic, handler: (*application).accountEmailConfirm, with: []string{"requireSignedURL"}},
+	{name: "account.email.confirm", method: http.MethodPost, pattern: "/account/email/confirm", chain: chainDynamic, handler: (*application).accountEmailConfirmPost, with: []string{"consumeSignedURL"}},
+	{name: "email.unsubscribe", method: http.MethodGet, pattern: "/email/unsubscribe", chain: chainDynamic, handler: (*application).emailUnsubscribe, with: []string{"requireSignedURL"}},
+	{name: "email.unsubscribe", method: http.MethodPost, pattern: "/email/unsubscribe", chain: chainOneClick, handler: (*application).emailUnsubscribePost, with: []string{"requireSignedURL"}},
+	{name: "challenge", method: http.MethodPost, pattern: "/challenge", chain: chainDynamic, handler: (*application).challengePost, readOnlySafe: true},
+
+	{name: "user.signup", method: http.MethodGet, pattern: "/user/signup", chain: chainSignup, handler: (*application).userSignup},
+	{name: "user.signup", method: http.MethodPost, pattern: "/user/signup", chain: chainSignup, handler: (*application).userSignupPost},
+	{name: "validate.signup", method: http.MethodPost, pattern: "/validate/signup", chain: chainSignup, handler: (*application).validateSignup, readOnlySafe: true},
+
+	{name: "user.login", method: http.MethodGet, pattern: "/user/login", chain: chainLogin, handler: (*application).userLogin},
+	{name: "user.login", method: http.MethodPost, pattern: "/user/login", chain: chainPasswordLogin, handler: (*application).userLoginPost, readOnlySafe: true},
+	{name: "user.login.magic", method: http.MethodGet, pattern: "/user/login/magic", chain: chainLocalLogin, handler: (*application).userLoginMagicForm},
+	{name: "user.login.magic", method: http.MethodPost, pattern: "/user/login/magic", chain: chainLocalLogin, handler: (*application).userLoginMagicPost, readOnlySafe: true},
+	{name: "user.login.magic.link", method: http.MethodGet, pattern: "/user/login/magic/link", chain: chainLocalLogin, handler: (*application).userLoginMagicConfirm, with: []string{"checkLoginLink"}},
+	{name: "user.login.magic.link", method: http.MethodPost, pattern: "/user/login/magic/link", chain: chainLocalLogin, handler: (*application).userLoginMagic, with: []string{"consumeLoginLink"}, readOnlySafe: true},
+	{name: "user.login.passkey.begin", method: http.MethodPost, pattern: "/user/login/passkey/begin", chain: chainLocalLogin, handler: (*application).userLoginPasskeyBegin, readOnlySafe: true},
+	{name: "user.login.passkey.finish", method: http.MethodPost, pattern: "/user/login/passkey/finish", chain: chainLocalLogin, handler: (*application).userLoginPasskeyFinish, readOnlySafe: true},
+	{name: "user.login.sso", method: http.MethodGet, pattern: "/user/login/sso", chain: chainLogin, handler: (*application).userLoginSSO},
+	{name: "user.login.sso.callback", method: http.MethodGet, pattern: "/user/login/sso/callback", chain: chainLogin, handler: (*application).userLoginSSOCallback},
+
+	{name: "snippet.create", method: http.MethodGet, pattern: "/snippet/create", chain: chainProtected, handler: (*application).snippetCreate},
+	{name: "snippet.create", method: http.MethodPost, pattern: "/snippet/create", chain: chainProtected, handler: (*application).snippetCreatePost},
+	{name: "validate.snippet", method: http.MethodPost, pattern: "/validate/snippet", chain: chainProtected, handler: (*application).validateSnippet, readOnlySafe: true},
+	{name: "snippet.draft", method: http.MethodPost, pattern: "/snippet/draft", chain: chainProtected, handler: (*application).snippetDraftPost},
+	{name: "snippet.preview", method: http.MethodPost, pattern: "/snippet/preview", chain: chainProtected, handler: (*application).snippetPreviewPost, readOnlySafe: true},
+	{name: "snippet.draft.delete", method: http.MethodPost, pattern: "/snippet/draft/delete", chain: chainProtected, handler: (*application).snippetDraftDeletePost},
+	{name: "snippet.edit", method: http.MethodGet, pattern: "/snippet/edit/:id", chain: chainProtected, handler: (*application).snippetEdit, publicID: true},
+	{name: "snippet.edit", method: http.MethodPost, pattern: "/snippet/edit/:id", chain: chainProtected, handler: (*application).snippetEditPost, publicID: true},
+	{name: "snippet.language", method: http.MethodPost, pattern: "/snippet/language/:id/:position", chain: chainProtected, handler: (*application).snippetLanguagePost, publicID: true},
+	{name: "snippet.collect", method: http.MethodPost, pattern: "/snippet/collect/:id", chain: chainProtected, handler: (*application).snippetCollectPost, publicID: true},
+	{name: "snippet.stats", method: http.MethodGet, pattern: "/snippet/stats/:id", chain: chainProtected, handler: (*application).snippetStatsView, publicID: true},
+	{name: "orgs", method: http.MethodGet, pattern: "/orgs", chain: chainProtected, handler: (*application).orgsView},
+	{name: "orgs", method: http.MethodPost, pattern: "/orgs", chain: chainProtected, handler: (*application).orgCreatePost},
+	{name: "orgs.switch", method: http.MethodPost, pattern: "/orgs/switch", chain: chainProtected, handler: (*application).orgSwitchPost, readOnlySafe: true},
+	{name: "org.invitation", method: http.MethodGet, pattern: "/orgs/invitation/:token", chain: chainProtected, handler: (*application).orgInvitation},
+	{name: "org.invitation", method: http.MethodPost, pattern: "/orgs/invitation/:token", chain: chainProtected, handler: (*application).orgInvitationPost},
+	{name: "org.invite", method: http.MethodPost, pattern: "/org/:slug/invite", chain: chainProtected, handler: (*application).orgInvitePost},
+	{name: "org.invitation.revoke", method: http.MethodPost, pattern: "/org/:slug/invitations/:id/revoke", chain: chainProtected, handler: (*application).orgInvitationRevokePost},
+	{name: "org.member.role", method: http.MethodPost, pattern: "/org/:slug/members/:id/role", chain: chainProtected, handler: (*application).orgMemberRolePost},
+	{name: "org.member.remove", method: http.MethodPost, pattern: "/org/:slug/members/:id/remove", chain: chainProtected, handler: (*application).orgMemberRemovePost},
+	{name: "org.leave", method: http.MethodPost, pattern: "/org/:slug/leave", chain: chainProtected, handler: (*application).orgLeavePost},
+	{name: "impersonation.stop", method: http.MethodPost, pattern: "/impersonation/stop", chain: chainProtected, handler: (*application).impersonationStopPost, readOnlySafe: true},
+	{name: "user.logout", method: http.MethodPost, pattern: "/user/logout", chain: chainProtected, handler: (*application).userLogoutPost, readOnlySafe: true},
+	{name: "account.view", method: http.MethodGet, pattern: "/account/view", chain: chainProtected, handler: (*application).accountView},
+	{name: "account.password.update", method: http.MethodGet, pattern: "/account/password/update", chain: chainProtectedLocal, handler: (*application).accountPasswordUpdate, with: []string{"forbidImpersonation"}},
+	{name: "account.password.update", method: http.MethodPost, pattern: "/account/password/update", chain: chainProtectedLocal, handler: (*application).accountPasswordUpdatePost, with: []string{"forbidImpersonation"}},
+	{name: "account.email.update", method: http.MethodGet, pattern: "/account/email/update", chain: chainProtectedLocal, handler: (*application).accountEmailUpdate, with: []string{"forbidImpersonation"}},
+	{name: "account.email.update", method: http.MethodPost, pattern: "/account/email/update", chain: chainProtectedLocal, handler: (*application).accountEmailUpdatePost, with: []string{"forbidImpersonation"}},
+	{name: "account.preferences", method: http.MethodGet, pattern: "/account/preferences", chain: chainProtected, handler: (*application).accountPreferences},
+	{name: "account.preferences", method: http.MethodPost, pattern: "/account/preferences", chain: chainProtected, handler: (*application).accountPreferencesPost},
+	{name: "account.templates", method: http.MethodGet, pattern: "/account/templates", chain: chainProtected, handler: (*application).accountTemplates},
+	{name: "account.templates.create", method: http.MethodGet, pattern: "/account/templates/create", chain: chainProtected, handler: (*application).accountTemplateCreate},
+	{name: "account.templates.create", method: http.MethodPost, pattern: "/account/templates/create", chain: chainProtected, handler: (*application).accountTemplateCreatePost},
+	{name: "account.templates.edit", method: http.MethodGet, pattern: "/account/templates/edit/:id", chain: chainProtected, handler: (*application).accountTemplateEdit},
+	{name: "account.templates.edit", method: http.MethodPost, pattern: "/account/templates/edit/:id", chain: chainProtected, handler: (*application).accountTemplateEditPost},
+	{name: "account.templates.delete", method: http.MethodPost, pattern: "/account/templates/delete/:id", chain: chainProtected, handler: (*application).accountTemplateDeletePost},
+	{name: "account.snippets.download", method: http.MethodGet, pattern: "/account/snippets/download", chain: chainProtected, handler: (*application).accountSnippetsDownload, source: true},
+	{name: "account.collections", method: http.MethodGet, pattern: "/account/collections", chain: chainProtected, handler: (*application).accountCollections},
+	{name: "account.collections.create", method: http.MethodGet, pattern: "/account/collections/create", chain: chainProtected, handler: (*application).accountCollectionCreate},
+	{name: "account.collections.create", method: http.MethodPost, pattern: "/account/collections/create", chain: chainProtected, handler: (*application).accountCollectionCreatePost},
+	{name: "account.collections.edit", method: http.MethodGet, pattern: "/account/collections/edit/:id", chain: chainProtected, handler: (*application).accountCollectionEdit},
+	{name: "account.collections.edit", method: http.MethodPost, pattern: "/account/collections/edit/:id", chain: chainProtected, handler: (*application).accountCollectionEditPost},
+	{name: "account.collections.delete", method: http.MethodPost, pattern: "/account/collections/delete/:id", chain: chainProtected, handler: (*application).accountCollectionDeletePost},
+	{name: "account.collections.remove", method: http.MethodPost, pattern: "/account/collections/remove/:id", chain: chainProtected, handler: (*application).accountCollectionRemovePost},
+	{name: "account.collections.move", method: http.MethodPost, pattern: "/account/collections/move/:id", chain: chainProtected, handler: (*application).accountCollectionMovePost},
+	{name: "account.passkeys", method: http.MethodGet, pattern: "/account/security/passkeys", chain: chainProtected, handler: (*application).accountPasskeys},
+	{name: "account.passkeys.register.begin", method: http.MethodPost, pattern: "/account/security/passkeys/register/begin", chain: chainProtected, handler: (*application).accountPasskeyRegisterBegin, with: []string{"forbidImpersonation"}},
+	{name: "account.passkeys.register.finish", method: http.MethodPost, pattern: "/account/security/passkeys/register/finish", chain: chainProtected, handler: (*application).accountPasskeyRegisterFinish, with: []string{"forbidImpersonation"}},
+	{name: "account.passkeys.delete", method: http.MethodPost, pattern: "/account/security/passkeys/delete/:id", chain: chainProtected, handler: (*application).accountPasskeyDeletePost, with: []string{"forbidImpersonation"}},
+	{name: "account.tokens", method: http.MethodGet, pattern: "/account/tokens", chain: chainProtected, handler: (*application).accountAPITokens},
+	{name: "account.tokens", method: http.MethodPost, pattern: "/account/tokens", chain: chainProtected, handler: (*application).accountAPITokensPost, with: []string{"forbidImpersonation"}},
+	{name: "account.tokens.delete", method: http.MethodPost, pattern: "/account/tokens/delete/:id", chain: chainProtected, handler: (*application).accountAPITokenDeletePost, with: []string{"forbidImpersonation"}},
+	{name: "account.webhooks", method: http.MethodGet, pattern: "/account/webhooks", chain: chainProtected, handler: (*application).accountWebhooks},
+	{name: "account.webhooks", method: http.MethodPost, pattern: "/account/webhooks", chain: chainProtected, handler: (*application).accountWebhooksPost, with: []string{"forbidImpersonation"}},
+	{name: "account.webhook", method: http.MethodGet, pattern: "/account/webhooks/:id", chain: chainProtected, handler: (*application).accountWebhook},
+	{name: "account.webhooks.delete", method: http.MethodPost, pattern: "/account/webhooks/delete/:id", chain: chainProtected, handler: (*application).accountWebhookDeletePost, with: []string{"forbidImpersonation"}},
+	{name: "notifications", method: http.MethodGet, pattern: "/notifications", chain: chainProtected, handler: (*application).notificationList},
+	{name: "notifications.read", method: http.MethodPost, pattern: "/notifications/read", chain: chainProtected, handler: (*application).notificationReadPost},
+	{name: "user.follow", method: http.MethodPost, pattern: "/user/follow/:id", chain: chainProtected, handler: (*application).userFollowPost},
+	{name: "user.unfollow", method: http.MethodPost, pattern: "/user/unfollow/:id", chain: chainProtected, handler: (*application).userUnfollowPost},
+	{name: "feed", method: http.MethodGet, pattern: "/feed", chain: chainProtected, handler: (*application).feed},
+	{name: "feed.atom", method: http.MethodGet, pattern: "/feed.atom", chain: chainProtected, handler: (*application).feedAtom, source: true},
+
+	{name: "admin", method: http.MethodGet, pattern: "/admin", chain: chainAdmin, handler: (*application).adminIndex},
+	{name: "admin.features", method: http.MethodGet, pattern: "/admin/features", chain: chainAdmin, handler: (*application).adminFeatures},
+	{name: "admin.features", method: http.MethodPost, pattern: "/admin/features", chain: chainAdmin, handler: (*application).adminFeaturesPost},
+	{name: "admin.invitations", method: http.MethodGet, pattern: "/admin/invitations", chain: chainAdmin, handler: (*application).adminInvitations},
+	{name: "admin.invitations", method: http.MethodPost, pattern: "/admin/invitations", chain: chainAdmin, handler: (*application).adminInvitationsPost},
+	{name: "admin.signup-mode", method: http.MethodPost, pattern: "/admin/signup-mode", chain: chainAdmin, handler: (*application).adminSignupModePost},
+	{name: "admin.announcements", method: http.MethodGet, pattern: "/admin/announcements", chain: chainAdmin, handler: (*application).adminAnnouncements},
+	{name: "admin.announcements", method: http.MethodPost, pattern: "/admin/announcements", chain: chainAdmin, handler: (*application).adminAnnouncementsPost},
+	{name: "admin.csp-reports", method: http.MethodGet, pattern: "/admin/csp-reports", chain: chainAdmin, handler: (*application).adminCSPReports},
+	{name: "admin.incidents", method: http.MethodGet, pattern: "/admin/incidents", chain: chainAdmin, handler: (*application).adminIncidents},
+	{name: "admin.rate-limits", method: http.MethodGet, pattern: "/admin/rate-limits", chain: chainAdmin, handler: (*application).adminRateLimits},
+	{name: "admin.rate-limits", method: http.MethodPost, pattern: "/admin/rate-limits", chain: chainAdmin, handler: (*application).adminRateLimitsPost},
+	{name: "admin.rate-limits.delete", method: http.MethodPost, pattern: "/admin/rate-limits/delete", chain: chainAdmin, handler: (*application).adminRateLimitsDeletePost},
+	{name: "admin.offenders", method: http.MethodGet, pattern: "/admin/offenders", chain: chainAdmin, handler: (*application).adminOffenders},
+	{name: "admin.offenders.clear", method: http.MethodPost, pattern: "/admin/offenders/clear", chain: chainAdmin, handler: (*application).adminOffenderClearPost},
+	{name: "admin.expiry", method: http.MethodGet, pattern: "/admin/expiry", chain: chainAdmin, handler: (*application).adminExpiry},
+	{name: "admin.expiry", method: http.MethodPost, pattern: "/admin/expiry", chain: chainAdmin, handler: (*application).adminExpiryPost},
+	{name: "admin.integrity", method: http.MethodGet, pattern: "/admin/integrity", chain: chainAdmin, handler: (*application).adminIntegrity},
+	{name: "admin.integrity.run", method: http.MethodPost, pattern: "/admin/integrity/run", chain: chainAdmin, handler: (*application).adminIntegrityRunPost},
+	{name: "admin.integrity.repair", method: http.MethodPost, pattern: "/admin/integrity/repair", chain: chainAdmin, handler: (*application).adminIntegrityRepairPost},
+	{name: "admin.performance", method: http.MethodGet, pattern: "/admin/performance", chain: chainAdmin, handler: (*application).adminPerformance},
+	{name: "admin.sessions", method: http.MethodGet, pattern: "/admin/sessions", chain: chainAdmin, handler: (*application).adminSessions},
+	{name: "admin.sessions.gc", method: http.MethodPost, pattern: "/admin/sessions/gc", chain: chainAdmin, handler: (*application).adminSessionsGCPost},
+	{name: "admin.retention", method: http.MethodGet, pattern: "/admin/retention", chain: chainAdmin, handler: (*application).adminRetention},
+	{name: "admin.retention.run", method: http.MethodPost, pattern: "/admin/retention/run", chain: chainAdmin, handler: (*application).adminRetentionRunPost},
+	{name: "admin.webhooks", method: http.MethodGet, pattern: "/admin/webhooks", chain: chainAdmin, handler: (*application).adminWebhooks},
+	{name: "admin.webhooks", method: http.MethodPost, pattern: "/admin/webhooks", chain: chainAdmin, handler: (*application).adminWebhooksPost},
+	{name: "admin.webhook", method: http.MethodGet, pattern: "/admin/webhooks/:id", chain: chainAdmin, handler: (*application).adminWebhook},
+	{name: "admin.webhooks.delete", method: http.MethodPost, pattern: "/admin/webhooks/delete/:id", chain: chainAdmin, handler: (*application).adminWebhookDeletePost},
+	{name: "admin.users", method: http.MethodGet, pattern: "/admin/users", chain: chainAdmin, handler: (*application).adminUsers},
+	{name: "admin.user", method: http.MethodGet, pattern: "/admin/users/:id", chain: chainAdmin, handler: (*application).adminUserView},
+	{name: "admin.users.suspend", method: http.MethodPost, pattern: "/admin/users/suspend/:id", chain: chainAdmin, handler: (*application).adminUserSuspendPost},
+	{name: "admin.users.ban", method: http.MethodPost, pattern: "/admin/users/ban/:id", chain: chainAdmin, handler: (*application).adminUserBanPost},
+	{name: "admin.users.impersonate", method: http.MethodPost, pattern: "/admin/users/impersonate/:id", chain: chainAdmin, handler: (*application).adminUserImpersonatePost},
+	{name: "admin.users.unsuspend", method: http.MethodPost, pattern: "/admin/users/unsuspend/:id", chain: chainAdmin, handler: (*application).adminUserUnsuspendPost},
+	{name: "admin.emails", method: http.MethodGet, pattern: "/admin/emails", chain: chainAdmin, handler: (*application).adminEmails},
+	{name: "admin.emails.retry", method: http.MethodPost, pattern: "/admin/emails/retry/:id", chain: chainAdmin, handler: (*application).adminEmailRetryPost},
+	{name: "admin.emails.delete", method: http.MethodPost, pattern: "/admin/emails/delete/:id", chain: chainAdmin, handler: (*application).adminEmailDeletePost},
+	{name: "admin.emails.previews", method: http.MethodGet, pattern: "/admin/emails/preview", chain: chainAdmin, handler: (*application).adminEmailPreviews, debugOnly: true},
+	{name: "admin.emails.preview", method: http.MethodGet, pattern: "/admin/emails/preview/:name", chain: chainAdmin, handler: (*application).adminEmailPreview, debugOnly: true},
+
+	{name: "api.snippets", method: http.MethodGet, pattern: "/api/v1/snippets", chain: chainAPI, handler: (*application).apiSnippetList, source: true},
+	{name: "api.snippets", method: http.MethodPost, pattern: "/api/v1/snippets", chain: chainAPIProtected, handler: (*application).apiSnippetCreate},
+	{name: "api.snippet", method: http.MethodGet, pattern: "/api/v1/snippets/:id", chain: chainAPI, handler: (*application).apiSnippetView, source: true, publicID: true},
+	{name: "api.snippet", method: http.MethodPatch, pattern: "/api/v1/snippets/:id", chain: chainAPIProtected, handler: (*application).apiSnippetUpdate, publicID: true},
+	{name: "api.snippet.language", method: http.MethodPut, pattern: "/api/v1/snippets/:id/files/:position/language", chain: chainAPIProtected, handler: (*application).apiSnippetLanguageUpdate, publicID: true},
+	{name: "api.format", method: http.MethodPost, pattern: "/api/v1/format", chain: chainAPI, handler: (*application).apiFormat, readOnlySafe: true},
+	{name: "api.graphql", method: http.MethodPost, pattern: "/graphql", chain: chainAPI, handler: (*application).graphqlRequest, readOnlySafe: true},
+	{name: "api.quick", method: http.MethodPost, pattern: "/api/quick", chain: chainQuick, handler: (*application).quickCreate},
+	{name: "api.quick", method: http.MethodPut, pattern: "/api/quick", chain: chainQuick, handler: (*application).quickCreate},
+
+	{name: "activitypub.actor", method: http.MethodGet, pattern: "/ap/actor", chain: chainActivityPub, handler: (*application).activityPubActor},
+	{name: "activitypub.inbox", method: http.MethodPost, pattern: "/ap/inbox", chain: chainActivityPub, handler: (*application).activityPubInbox},
+	{name: "activitypub.outbox", method: http.MethodGet, pattern: "/ap/outbox", chain: chainActivityPub, handler: (*application).activityPubOutbox, source: true},
+	{name: "activitypub.followers", method: http.MethodGet, pattern: "/ap/followers", chain: chainActivityPub, handler: (*application).activityPubFollowers},
+	{name: "activitypub.note", method: http.MethodGet, pattern: "/ap/notes/:id", chain: chainActivityPub, handler: (*application).activityPubNote, source: true, publicID: true},
+}
+
+// routePatterns maps the names in routeTable to their patterns. It's filled
+// in by init, since the handlers in routeTable use urlFor themselves.
+var routePatterns = map[string]string{}
+
+func init() {
+	for _, rt := range routeTable {
+		if p, ok := routePatterns[rt.name]; ok && p != rt.pattern {
+			panic(fmt.Sprintf("route %q has two patterns: %s and %s", rt.name, p, rt.pattern))
+		}
+		routePatterns[rt.name] = rt.pattern
+	}
+}
+
+// urlFor returns the path of the named route, with args filling in its
+// parameters in order. For example urlFor("snippet.view", 123) returns
+// "/snippet/view/123". Each parameter is escaped; a catch-all parameter may
+// contain slashes. It panics if there's no such route or the wrong number of
+// arguments is given, since either is a mistake in the code.
+func urlFor(name string, args ...any) string {
+	pattern, ok := routePatterns[name]
+	if !ok {
+		panic(fmt.Sprintf("urlFor: no route named %q", name))
+	}
+
+	segments := strings.Split(pattern, "/")
+	n := 0
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		if n == len(args) {
+			panic(fmt.Sprintf("urlFor: route %q needs more than %d arguments", name, len(args)))
+		}
+
+		value := fmt.Sprint(args[n])
+		if segment[0] == '*' {
+			segments[i] = strings.TrimPrefix((&url.URL{Path: value}).EscapedPath(), "/")
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+		n++
+	}
+	if n != len(args) {
+		panic(fmt.Sprintf("urlFor: route %q takes %d arguments, not %d", name, n, len(args)))
+	}
+
+	return strings.Join(segments, "/")
+}
+
 // Update the signature for the routes() method so that it returns a
-// http.Handler instead of *http.ServeMux.
+// http.Handler instead of *http.ServeMux. With admin listeners, the
+// operational routes are left out; see adminRoutes.
 func (app *application) routes() http.Handler {
+	return app.handler(app.standardStack(), func(rt route) bool {
+		return !rt.adminOnly && !(app.separateAdmin && rt.operational())
+	})
+}
+
+// handler routes the routes in routeTable which serve says to, each through
+// its middleware stack, inside standard.
+func (app *application) handler(standard middleware.Stack, serve func(route) bool) http.Handler {
 	router := httprouter.New()
-	// Create a file server which serves files out of the "./ui/static" directory. Note that the path given to the http.Dir function is relative to the project directory root.
-	fileServer := http.FileServer(http.FS(ui.Files))
 
 	// Create a handler function which wraps our notFound() helper, and then
 	// assign it as the custom handler for 404 Not Found responses. You can also
@@ -22,44 +322,44 @@ func (app *application) routes() http.Handler {
 		app.notFound(w)
 	})
 
-	// Use the mux.Handle() function to register the file server as the handler for
-	// all URL paths that start with "/static/". For matching paths, we strip the
-	// "/static" prefix before the request reaches the file server.
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
-	// Add a new GET /ping route.
-	router.HandlerFunc(http.MethodGet, "/ping", ping)
-
-	// Create a new middleware chain containing the middleware specific to our
-	// dynamic application routes. For now, this chain will only contain the
-	// LoadAndSave session middleware but we'll add more to it later.
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
-
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
-	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
-	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
-
-	// Add the five new routes, all of which use our 'dynamic' middleware chain.
-	protected := dynamic.Append(app.requireAuthentication)
-
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
-	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
-	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
-
-	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
-	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
-
-	// Because secureHeaders is just a function, and the function returns a
-	// http.Handler we don't need to do anything else.
-	// Create a middleware chain containing our 'standard' middleware
-	// which will be used for every request our application receives.
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
-
-	// Return the 'standard' middleware chain followed by the servemux.
-	return standard.Then(router)
+	stacks := app.middlewareStacks()
+	extra := app.routeMiddleware()
+
+	for _, rt := range routeTable {
+		if (rt.debugOnly && !app.debug) || !serve(rt) {
+			continue
+		}
+		stack := routeStack(stacks, extra, rt)
+		if err := standard.Extend(stack.Name, stack.Middleware...).Check(middlewareRules...); err != nil {
+			panic(fmt.Sprintf("route %q: %v", rt.name, err))
+		}
+		handler := rt.handler
+		routed := stack.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(app, w, r)
+		})
+		name := rt.method + " " + rt.name
+		router.Handler(rt.method, rt.pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqctx.From(r.Context()).Route = name
+			routed.ServeHTTP(w, r)
+		}))
+	}
 
+	// httprouter answers OPTIONS requests itself, so CORS preflight requests
+	// for the API never reach the api chain. They're handled here instead,
+	// once httprouter has worked out which methods the path allows.
+	apiPreflight := middleware.NewStack("apiPreflight",
+		middleware.New("requireFeature", app.requireFeature(features.APIEnabled)),
+		middleware.New("enableCORS", app.enableCORS),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/v1/") || r.URL.Path == urlFor("api.graphql") {
+			apiPreflight.ServeHTTP(w, r)
+		}
+	})
+
+	// Every request goes through the standard stack, which wraps the
+	// router rather than each route.
+	return standard.Then(router)
 }
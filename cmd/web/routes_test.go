@@ -0,0 +1,192 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/middleware"
+	"github.com/ngohoang211020/snippetbox/ui"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestURLFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string
+		args  []any
+		want  string
+	}{
+		{
+			name:  "No parameters",
+			route: "home",
+			want:  "/",
+		},
+		{
+			name:  "One parameter",
+			route: "snippet.view",
+			args:  []any{123},
+			want:  "/snippet/view/123",
+		},
+		{
+			name:  "Two parameters",
+			route: "snippet.raw",
+			args:  []any{1, 2},
+			want:  "/snippet/raw/1/2",
+		},
+		{
+			name:  "Escaped parameter",
+			route: "language",
+			args:  []any{"c/c++"},
+			want:  "/language/c%2Fc++",
+		},
+		{
+			name:  "Catch-all parameter",
+			route: "static",
+			args:  []any{"css/main.css"},
+			want:  "/static/css/main.css",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, urlFor(tt.route, tt.args...), tt.want)
+		})
+	}
+}
+
+func TestURLForPanics(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string
+		args  []any
+	}{
+		{name: "Unknown route", route: "snippet.nonexistent"},
+		{name: "Too few arguments", route: "snippet.view"},
+		{name: "Too many arguments", route: "home", args: []any{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				assert.Equal(t, recover() != nil, true)
+			}()
+			urlFor(tt.route, tt.args...)
+		})
+	}
+}
+
+// Every route in the table should be registered, so the URLs urlFor makes
+// lead somewhere. The adminOnly routes are on the admin listeners.
+func TestRouteTable(t *testing.T) {
+	app := newTestApplication(t)
+	app.debug = true
+	public := newTestServer1(t, app.routes())
+	defer public.Close()
+	admin := newTestServer1(t, app.adminRoutes())
+	defer admin.Close()
+
+	for _, rt := range routeTable {
+		if rt.method != http.MethodGet || rt.name == "static" || rt.name == "well-known" {
+			continue
+		}
+
+		args := []any{}
+		for range regexp.MustCompile(`[:*]\w+`).FindAllString(rt.pattern, -1) {
+			args = append(args, 1)
+		}
+
+		ts := public
+		if rt.adminOnly {
+			ts = admin
+		}
+
+		t.Run(rt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodOptions, ts.URL+urlFor(rt.name, args...), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.StringContains(t, rs.Header.Get("Allow"), http.MethodGet)
+		})
+	}
+}
+
+// Templates name routes in strings, which the compiler can't check, so make
+// sure each of them is in the route table.
+func TestTemplateRouteNames(t *testing.T) {
+	names := regexp.MustCompile(`urlFor "([^"]+)"`)
+
+	err := fs.WalkDir(ui.Files, "html", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(ui.Files, path)
+		if err != nil {
+			return err
+		}
+		for _, m := range names.FindAllSubmatch(b, -1) {
+			if _, ok := routePatterns[string(m[1])]; !ok {
+				t.Errorf("%s: no route named %q", path, m[1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	app := newTestApplication(t)
+	standard := app.standardStack()
+	stacks := app.middlewareStacks()
+	extra := app.routeMiddleware()
+
+	// Only requestContext and logRequest run before recoverPanic, so that
+	// a panic anywhere else still gets a 500.
+	assert.Equal(t, strings.Join(standard.Names(), ","), "requestContext,logRequest,recordPerformance,recoverPanic,secureHeaders,noIndex")
+
+	for _, rt := range routeTable {
+		stack := routeStack(stacks, extra, rt)
+		full := standard.Extend(stack.Name, stack.Middleware...)
+		if err := full.Check(middlewareRules...); err != nil {
+			t.Errorf("%s %s: %v", rt.method, rt.pattern, err)
+		}
+
+		names := strings.Join(stack.Names(), ",")
+		if !csrfExempt[rt.chain] && !strings.Contains(names, "session,noSurf,authenticate") {
+			t.Errorf("%s %s runs through %s", rt.method, rt.pattern, stack)
+		}
+	}
+
+	assert.Equal(t, strings.Join(stacks[chainAdmin].Names()[5:], ","), "softRateLimit,requireAllowedIP,requireAuthentication,requireAdmin")
+	assert.Equal(t, stacks[chainProtectedLocal].String(), "protectedLocal (extends protected): serveStale > requireDatabase > session > noSurf > authenticate > softRateLimit > requireAuthentication > requireLocalAccounts")
+
+	// The rules catch middleware put in the wrong place.
+	wrong := middleware.NewStack("wrong", extra["recordView"], stacks[chainProtected].Middleware[6], stacks[chainDynamic].Middleware[4])
+	assert.Equal(t, wrong.Check(middlewareRules...).Error(), "wrong: authenticate must wrap requireAuthentication")
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	stacks := app.middlewareStacks()
+	extra := app.routeMiddleware()
+
+	for _, rt := range routeTable {
+		if rt.name == "snippet.view" {
+			assert.Equal(t, routeStack(stacks, extra, rt).String(), "snippet.view (extends dynamic): serveStale > requireDatabase > session > noSurf > authenticate > softRateLimit > resolveSnippetID > recordView")
+		}
+	}
+
+	defer func() {
+		assert.Equal(t, recover(), any(`route "home": no route middleware named "missing"`))
+	}()
+	routeStack(stacks, extra, route{name: "home", chain: chainDynamic, with: []string{"missing"}})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// seedPassword is the password of every user created by the seed command.
+const seedPassword = "pa$$word"
+
+// seedUser is a fake user created by the seed command.
+type seedUser struct {
+	Name  string
+	Email string
+}
+
+// seedCommand implements "web seed", which fills the development database
+// with fake users and snippets. The data is generated from a fixed seed value,
+// so running it twice with the same flags produces the same data. Users which
+// already exist are left alone, so it can safely be run against a database
+// which has been seeded before.
+func seedCommand(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	dsn := fs.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	numUsers := fs.Int("users", 10, "Number of users to create")
+	numSnippets := fs.Int("snippets", 100, "Number of snippets to create")
+	seed := fs.Int64("seed", 1, "Random seed, for generating the same data each time")
+	maxWait := fs.Duration("db-max-wait", 30*time.Second, "How long to keep trying to reach the database before giving up")
+	fs.Parse(args)
+
+	infoLog := log.New(os.Stderr, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	retry := dbRetry{initialDelay: 500 * time.Millisecond, maxDelay: 5 * time.Second, maxWait: *maxWait}
+
+	db, err := openDB(*dsn, retry, errorLog)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	users, snippets := generateSeedData(*seed, *numUsers, *numSnippets)
+
+	userModel := &models.UserModel{DB: db}
+	for _, u := range users {
+		err = userModel.Insert(u.Name, u.Email, seedPassword)
+		if err != nil && !errors.Is(err, models.ErrDuplicateEmail) {
+			return err
+		}
+	}
+	infoLog.Printf("created %d users with the password %q", len(users), seedPassword)
+
+	snippetModel := &models.SnippetModel{DB: db}
+	for _, s := range snippets {
+		_, err = snippetModel.Insert(s.snippet, s.expires)
+		if err != nil {
+			return err
+		}
+	}
+	infoLog.Printf("created %d snippets", len(snippets))
+
+	return nil
+}
+
+// seedSnippet is a fake snippet and the number of days until it expires.
+type seedSnippet struct {
+	snippet *models.Snippet
+	expires int
+}
+
+var (
+	seedFirstNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter"}
+	seedLastNames  = []string{"Jones", "Smith", "Nguyen", "Garcia", "Müller", "Kowalski", "Tanaka", "Okafor", "Silva", "Larsen"}
+	seedAdjectives = []string{"Quick", "Simple", "Tiny", "Handy", "Recursive", "Concurrent", "Lazy", "Naive", "Faster", "Minimal"}
+	seedSubjects   = []string{"HTTP server", "string reverse", "binary search", "retry loop", "config parser", "CSV reader", "rate limiter", "linked list", "JSON encoder", "date formatter"}
+	seedExpiries   = []int{1, 7, 365}
+)
+
+// seedContent has a short piece of example code for some of the languages.
+// Languages without an entry get some generic text instead.
+var seedContent = map[string]string{
+	"go":         "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello, world\")\n}\n",
+	"python":     "def greet(name):\n    return f\"hello, {name}\"\n\nprint(greet(\"world\"))\n",
+	"javascript": "function greet(name) {\n  return `hello, ${name}`;\n}\n\nconsole.log(greet(\"world\"));\n",
+	"bash":       "#!/bin/sh\nfor f in *.txt; do\n  wc -l \"$f\"\ndone\n",
+	"sql":        "SELECT id, title\nFROM snippets\nWHERE expires > UTC_TIMESTAMP()\nORDER BY id DESC;\n",
+	"rust":       "fn main() {\n    println!(\"hello, world\");\n}\n",
+	"markdown":   "# Notes\n\n- one\n- two\n- three\n",
+	"json":       "{\n  \"name\": \"snippetbox\",\n  \"version\": 1\n}\n",
+}
+
+// generateSeedData returns the users and snippets to create. It doesn't touch
+// the database, so the same seed always gives exactly the same data.
+func generateSeedData(seed int64, numUsers, numSnippets int) ([]seedUser, []seedSnippet) {
+	rng := rand.New(rand.NewSource(seed))
+
+	pick := func(s []string) string {
+		return s[rng.Intn(len(s))]
+	}
+
+	users := make([]seedUser, numUsers)
+	for i := range users {
+		users[i] = seedUser{
+			Name:  pick(seedFirstNames) + " " + pick(seedLastNames),
+			Email: fmt.Sprintf("user%d@example.com", i+1),
+		}
+	}
+
+	snippets := make([]seedSnippet, numSnippets)
+	for i := range snippets {
+		lang := languages.All[rng.Intn(len(languages.All))]
+
+		content, ok := seedContent[lang.Name]
+		if !ok {
+			content = strings.Repeat("Lorem ipsum dolor sit amet.\n", 1+rng.Intn(5))
+		}
+
+		s := &models.Snippet{
+			Title:    pick(seedAdjectives) + " " + pick(seedSubjects),
+			Content:  content,
+			Filename: fmt.Sprintf("snippet%d.%s", i+1, lang.Extensions[0]),
+			Language: lang.Name,
+		}
+
+		// Roughly one snippet in five gets a second file, so the tabbed view
+		// gets some use too.
+		if rng.Intn(5) == 0 {
+			s.Files = []*models.SnippetFile{{
+				Filename: "README.md",
+				Language: "markdown",
+				Content:  seedContent["markdown"],
+			}}
+		}
+
+		snippets[i] = seedSnippet{snippet: s, expires: seedExpiries[rng.Intn(len(seedExpiries))]}
+	}
+
+	return users, snippets
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestGenerateSeedData(t *testing.T) {
+	users, snippets := generateSeedData(42, 5, 20)
+
+	assert.Equal(t, len(users), 5)
+	assert.Equal(t, len(snippets), 20)
+	assert.Equal(t, users[0].Email, "user1@example.com")
+
+	// The same seed must always produce the same data...
+	users2, snippets2 := generateSeedData(42, 5, 20)
+	for i := range users {
+		assert.Equal(t, users2[i], users[i])
+	}
+	for i := range snippets {
+		assert.Equal(t, snippets2[i].snippet.Title, snippets[i].snippet.Title)
+		assert.Equal(t, snippets2[i].snippet.Language, snippets[i].snippet.Language)
+		assert.Equal(t, snippets2[i].expires, snippets[i].expires)
+	}
+
+	// ...and a different seed different data.
+	_, snippets3 := generateSeedData(43, 5, 20)
+	same := true
+	for i := range snippets {
+		if snippets3[i].snippet.Title != snippets[i].snippet.Title {
+			same = false
+		}
+	}
+	assert.Equal(t, same, false)
+}
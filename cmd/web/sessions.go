@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sessionsExpiringSoon is how far ahead the admin sessions page looks for
+// sessions which are about to expire.
+const sessionsExpiringSoon = 5 * time.Minute
+
+// sessionGC keeps track of the cleanup of expired sessions, for the admin
+// sessions page. It is safe for concurrent use.
+type sessionGC struct {
+	Interval time.Duration
+
+	mu          sync.Mutex
+	lastRun     time.Time
+	lastDeleted int
+}
+
+// sessionGCStatus is a snapshot of a sessionGC.
+type sessionGCStatus struct {
+	Interval    time.Duration
+	LastRun     time.Time
+	LastDeleted int
+}
+
+func (gc *sessionGC) status() sessionGCStatus {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return sessionGCStatus{Interval: gc.Interval, LastRun: gc.lastRun, LastDeleted: gc.lastDeleted}
+}
+
+// deleteExpiredSessions removes expired sessions from the database. It runs
+// every -session-gc-interval, and when an admin asks for it.
+func (app *application) deleteExpiredSessions() (int, error) {
+	n, err := app.sessions.DeleteExpired()
+	if err != nil {
+		return 0, err
+	}
+
+	app.sessionGC.mu.Lock()
+	app.sessionGC.lastRun = time.Now()
+	app.sessionGC.lastDeleted = n
+	app.sessionGC.mu.Unlock()
+
+	return n, nil
+}
+
+func (app *application) adminSessions(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.sessions.Stats(sessionsExpiringSoon)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SessionStats = stats
+	data.SessionGC = app.sessionGC.status()
+	app.render(w, http.StatusOK, "admin_sessions.tmpl.html", data)
+}
+
+func (app *application) adminSessionsGCPost(w http.ResponseWriter, r *http.Request) {
+	n, err := app.deleteExpiredSessions()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Deleted %d expired sessions.", n))
+
+	http.Redirect(w, r, urlFor("admin.sessions"), http.StatusSeeOther)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// signedURLSkew is how long after they expire signed links are still
+// accepted, in case the clocks of the instances behind a load balancer
+// don't quite agree.
+const signedURLSkew = 2 * time.Minute
+
+// loadURLSigner parses the keys for signing the links in emails from the
+// named environment variable. It returns nil if the variable isn't set.
+func loadURLSigner(env string) (*signedurl.Signer, error) {
+	s := os.Getenv(env)
+	if s == "" {
+		return nil, nil
+	}
+
+	signer, err := signedurl.ParseKeys(s)
+	if err != nil {
+		return nil, fmt.Errorf("$%s: %w", env, err)
+	}
+	signer.Skew = signedURLSkew
+	return signer, nil
+}
+
+// errNoBaseURL is returned by signedURL when -base-url isn't set. Signed
+// links stand in for their recipient's password, so they're only ever sent
+// pointing at the site's configured address.
+var errNoBaseURL = errors.New("signed links can't be sent without -base-url")
+
+// signedURL returns the full URL of path under -base-url, signed so that it
+// carries params and works until ttl from now, for sending in an email.
+func (app *application) signedURL(path string, params url.Values, ttl time.Duration) (string, error) {
+	if app.baseURL == "" {
+		return "", errNoBaseURL
+	}
+
+	link, err := app.urlSigner.Sign(path, params, ttl)
+	if err != nil {
+		return "", err
+	}
+	return app.baseURL + link, nil
+}
+
+// signedURLFailed tells visitors who follow a signed link which isn't
+// accepted why not.
+func (app *application) signedURLFailed(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, signedurl.ErrUsed):
+		app.errorPage(w, http.StatusBadRequest, "This link has already been used.")
+	case errors.Is(err, signedurl.ErrInvalid), errors.Is(err, signedurl.ErrExpired):
+		app.errorPage(w, http.StatusBadRequest, "This link is invalid or has expired.")
+	default:
+		app.serverError(w, err)
+	}
+}
+
+// signedURLUser returns the ID of the user which the signed link being
+// followed was sent to.
+func signedURLUser(r *http.Request) (int, bool) {
+	t := signedurl.FromContext(r.Context())
+	if t == nil {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(t.Params.Get("uid"))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// snippetFileForm is one of the additional file sections in the snippet
+// create form. The form fields are named like "files[0].filename".
+// DetectedLanguage isn't part of the form; validateSnippetFiles fills it in.
+type snippetFileForm struct {
+	Filename         string `form:"filename" json:"filename"`
+	Language         string `form:"language" json:"language"`
+	Content          string `form:"content" json:"content"`
+	DetectedLanguage string `form:"-" json:"-"`
+}
+
+// effectiveLanguage returns the language the file was given, or failing
+// that the detected one, once the form has been validated.
+func (f snippetFileForm) effectiveLanguage() string {
+	if f.Language != "" {
+		return f.Language
+	}
+	return f.DetectedLanguage
+}
+
+// validateSnippetFiles checks the name and language of the snippet's first
+// file and each of the additional files, and returns the additional files
+// ready for inserting. File sections which were left completely empty (as
+// the create form's spare section usually is) are skipped. When a file's
+// language wasn't given, it's detected from the filename and content and kept
+// separately, so the owner's choice can always be told apart from our guess.
+// Each file's content is normalized as it would be stored, and held to the
+// limits.
+func validateSnippetFiles(v *validator.Validator, limits validator.ContentLimits, primary *snippetFileForm, extra []snippetFileForm) []*models.SnippetFile {
+	seen := map[string]bool{}
+
+	check := func(key string, f *snippetFileForm) {
+		v.CheckField(validator.MaxChars(f.Filename, 255), key+"filename", "This field cannot be more than 255 characters long")
+		v.CheckField(!strings.ContainsAny(f.Filename, `/\`) && f.Filename != "." && f.Filename != "..", key+"filename", "This field must be a plain filename")
+
+		if f.Filename != "" {
+			v.CheckField(!seen[f.Filename], key+"filename", "Each file must have a different name")
+			seen[f.Filename] = true
+		}
+
+		if f.Language == "" {
+			f.DetectedLanguage = languages.Detect(f.Filename, f.Content)
+		}
+		_, known := languages.Lookup(f.Language)
+		v.CheckField(f.Language == "" || known, key+"language", "This field must be one of the supported languages")
+
+		f.Content = normalizeContent(f.effectiveLanguage(), f.Content)
+		limits.Check(v, key+"content", f.Content)
+	}
+
+	check("", primary)
+
+	var files []*models.SnippetFile
+
+	for i := range extra {
+		f := &extra[i]
+		if f.Filename == "" && strings.TrimSpace(f.Content) == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("files[%d].", i)
+		check(key, f)
+		v.CheckField(validator.NotBlank(f.Content), key+"content", "This field cannot be blank")
+
+		files = append(files, &models.SnippetFile{
+			Filename:         f.Filename,
+			Language:         f.Language,
+			DetectedLanguage: f.DetectedLanguage,
+			Content:          f.Content,
+		})
+	}
+
+	if len(files)+1 > models.MaxSnippetFiles {
+		v.AddFieldError("files", fmt.Sprintf("A snippet can have at most %d files", models.MaxSnippetFiles))
+	}
+
+	return files
+}
+
+// normalizeContent normalizes the line endings of a file's content and the
+// spaces at their ends, except in Markdown, where two spaces at the end of a
+// line break it.
+func normalizeContent(language, content string) string {
+	return validator.NormalizeContent(content, language == "markdown")
+}
+
+// snippetFile looks up the snippet and file named by the :id and :position
+// parameters, sending a 404 response and returning false if either of them
+// doesn't exist.
+func (app *application) snippetFile(w http.ResponseWriter, r *http.Request) (*models.SnippetFile, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	position, err := strconv.Atoi(params.ByName("position"))
+	if err != nil || position < 0 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	visible, err := app.snippetVisible(snippet, reqctx.UserID(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	if !visible {
+		app.notFound(w)
+		return nil, false
+	}
+
+	file, ok := snippet.File(position)
+	if !ok {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return file, true
+}
+
+// snippetFileRaw sends a single file of a snippet as plain text.
+func (app *application) snippetFileRaw(w http.ResponseWriter, r *http.Request) {
+	file, ok := app.snippetFile(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(file.Content))
+}
+
+// snippetFileDownload sends a single file of a snippet as an attachment, so
+// the browser saves it under the file's name.
+func (app *application) snippetFileDownload(w http.ResponseWriter, r *http.Request) {
+	file, ok := app.snippetFile(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.DisplayName()})
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", disposition)
+	w.Write([]byte(file.Content))
+}
+
+// snippetLanguagePost lets the owner of a snippet choose the language of one
+// of its files, correcting the detected language. Choosing no language goes
+// back to the detected one.
+func (app *application) snippetLanguagePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	position, err := strconv.Atoi(params.ByName("position"))
+	if err != nil || position < 0 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+	visible, err := app.snippetVisible(snippet, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !visible {
+		app.notFound(w)
+		return
+	}
+	if snippet.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	if _, ok := snippet.File(position); !ok {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	language := r.PostForm.Get("language")
+	if _, known := languages.Lookup(language); language != "" && !known {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.SetLanguage(id, position, language, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.emitSnippetLanguageChanged(snippet, position, language)
+
+	app.sessionManager.Put(r.Context(), "flash", "The file's language has been updated.")
+
+	http.Redirect(w, r, fmt.Sprintf("%s#file-%d", urlFor("snippet.view", snippet.PublicID), position), http.StatusSeeOther)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSnippetViewFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='#file-1'>frog.txt</a>")
+	assert.StringContains(t, body, "href='/snippet/raw/00000000000000000000000001/1'")
+	assert.StringContains(t, body, "href='/snippet/download/00000000000000000000000001/0'")
+}
+
+func TestSnippetFileRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First file",
+			urlPath:  "/snippet/raw/00000000000000000000000001/0",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Additional file",
+			urlPath:  "/snippet/raw/00000000000000000000000001/1",
+			wantCode: http.StatusOK,
+			wantBody: "A frog jumps into the pond,",
+		},
+		{
+			name:     "Non-existent file",
+			urlPath:  "/snippet/raw/00000000000000000000000001/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/raw/2/0",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Negative position",
+			urlPath:  "/snippet/raw/00000000000000000000000001/-1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.Equal(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetFileDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/snippet/download/00000000000000000000000001/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=frog.txt")
+
+	// Files without a name get one based on their position and language.
+	_, headers, _ = ts.get(t, "/snippet/download/00000000000000000000000001/0")
+	assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=file1.txt")
+}
+
+func TestSnippetCreatePostFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantCode  int
+		wantError string
+	}{
+		{
+			name: "Valid files",
+			files: map[string]string{
+				"filename":          "main.go",
+				"files[0].filename": "go.mod",
+				"files[0].content":  "module example",
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "Empty spare section",
+			files: map[string]string{
+				"files[0].filename": "",
+				"files[0].content":  "",
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "Missing content",
+			files: map[string]string{
+				"files[0].filename": "go.mod",
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field cannot be blank",
+		},
+		{
+			name: "Duplicate filename",
+			files: map[string]string{
+				"filename":          "main.go",
+				"files[0].filename": "main.go",
+				"files[0].content":  "package main",
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Each file must have a different name",
+		},
+		{
+			name: "Path in filename",
+			files: map[string]string{
+				"files[0].filename": "../main.go",
+				"files[0].content":  "package main",
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be a plain filename",
+		},
+		{
+			name: "Unknown language",
+			files: map[string]string{
+				"language": "cobol",
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field must be one of the supported languages",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", "Content")
+			form.Add("expires", "7")
+			form.Add("csrf_token", csrfToken)
+			for k, v := range tt.files {
+				form.Add(k, v)
+			}
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateSnippetFilesDetectsLanguage(t *testing.T) {
+	var v validator.Validator
+
+	primary := snippetFileForm{Content: "package main\n\nfunc main() {\n}\n"}
+	files := validateSnippetFiles(&v, validator.DefaultContentLimits, &primary, []snippetFileForm{
+		{Filename: "query.sql", Content: "SELECT 1"},
+		{Language: "python", Content: "package main\n\nfunc main() {\n}\n"},
+	})
+
+	assert.Equal(t, v.Valid(), true)
+
+	// The detected language is kept apart from the chosen one...
+	assert.Equal(t, primary.Language, "")
+	assert.Equal(t, primary.DetectedLanguage, "go")
+	assert.Equal(t, files[0].Language, "")
+	assert.Equal(t, files[0].DetectedLanguage, "sql")
+
+	// ...and nothing is detected when the user chose a language.
+	assert.Equal(t, files[1].Language, "python")
+	assert.Equal(t, files[1].DetectedLanguage, "")
+}
+
+func TestValidateSnippetFilesNormalizesContent(t *testing.T) {
+	var v validator.Validator
+
+	primary := snippetFileForm{Content: "x := 1  \r\ny := 2\t\r\n"}
+	files := validateSnippetFiles(&v, validator.DefaultContentLimits, &primary, []snippetFileForm{
+		{Filename: "README.md", Content: "Two spaces  \r\nbreak a line\r\n"},
+	})
+
+	assert.Equal(t, v.Valid(), true)
+	assert.Equal(t, primary.Content, "x := 1\ny := 2\n")
+
+	// Markdown keeps its trailing spaces, which break lines.
+	assert.Equal(t, files[0].Content, "Two spaces  \nbreak a line\n")
+}
+
+func TestSnippetLanguagePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Only the owner of the snippet, user 2, gets the form.
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.Equal(t, strings.Contains(body, "action='/snippet/language/00000000000000000000000001/0'"), false)
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("language", "go")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ := ts.postForm(t, "/snippet/language/00000000000000000000000001/0", form)
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+	_, _, body = ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "action='/snippet/language/00000000000000000000000001/0'")
+	csrfToken = extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		language string
+		wantCode int
+	}{
+		{"Valid", "/snippet/language/00000000000000000000000001/1", "markdown", http.StatusSeeOther},
+		{"Back to detection", "/snippet/language/00000000000000000000000001/0", "", http.StatusSeeOther},
+		{"Unknown language", "/snippet/language/00000000000000000000000001/0", "cobol", http.StatusBadRequest},
+		{"Non-existent file", "/snippet/language/00000000000000000000000001/2", "go", http.StatusNotFound},
+		{"Non-existent snippet", "/snippet/language/2/0", "go", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("language", tt.language)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"strconv"
+)
+
+// snippetTemplateForm is the form for creating and editing snippet
+// templates. ID is zero for a new template.
+type snippetTemplateForm struct {
+	ID                  int    `form:"-"`
+	Name                string `form:"name"`
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Filename            string `form:"filename"`
+	Language            string `form:"language"`
+	validator.Validator `form:"-"`
+}
+
+func (f *snippetTemplateForm) validate(limits validator.ContentLimits) {
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.MaxChars(f.Title, 100), "title", "This field cannot be more than 100 characters long")
+
+	// The filename and language follow the same rules as a snippet's.
+	primary := snippetFileForm{Filename: f.Filename, Language: f.Language, Content: f.Content}
+	validateSnippetFiles(&f.Validator, limits, &primary, nil)
+	f.Content = primary.Content
+}
+
+func (f *snippetTemplateForm) template(userID int) *models.SnippetTemplate {
+	return &models.SnippetTemplate{
+		ID:       f.ID,
+		UserID:   userID,
+		Name:     f.Name,
+		Title:    f.Title,
+		Content:  f.Content,
+		Filename: f.Filename,
+		Language: f.Language,
+	}
+}
+
+// idParam returns the ID named in the URL, such as a template's, or false
+// if it isn't a valid ID.
+func idParam(r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+func (app *application) accountTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := reqctx.UserID(r.Context())
+
+	templates, err := app.snippetTemplates.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetTemplates = templates
+	app.render(w, http.StatusOK, "templates.tmpl.html", data)
+}
+
+// accountTemplateCreate shows the form for a new template. Given a snippet's
+// public ID in the query string the form starts off as a copy of that snippet, for
+// saving a snippet as a template.
+func (app *application) accountTemplateCreate(w http.ResponseWriter, r *http.Request) {
+	form := snippetTemplateForm{}
+
+	if s := r.URL.Query().Get("snippet"); s != "" {
+		id, err := app.snippetIDFor(s)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		snippet, err := app.snippets.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		// Templates are stored in plain text, so encrypted snippets can't
+		// be turned into them.
+		visible, err := app.snippetVisible(snippet, reqctx.UserID(r.Context()))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !visible || snippet.ContentEncrypted {
+			app.notFound(w)
+			return
+		}
+
+		form.Name = snippet.Title
+		form.Title = snippet.Title
+		form.Content = snippet.Content
+		form.Filename = snippet.Filename
+		form.Language = snippet.Language
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusOK, "template_form.tmpl.html", data)
+}
+
+func (app *application) accountTemplateCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form snippetTemplateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(app.contentLimits)
+
+	userID := reqctx.UserID(r.Context())
+
+	if form.Valid() {
+		_, err = app.snippetTemplates.Insert(form.template(userID))
+		if errors.Is(err, models.ErrDuplicateTemplateName) {
+			form.AddFieldError("name", "You already have a template with this name")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "template_form.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Template %q saved.", form.Name))
+	http.Redirect(w, r, urlFor("account.templates"), http.StatusSeeOther)
+}
+
+func (app *application) accountTemplateEdit(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	t, err := app.snippetTemplates.Get(id, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = snippetTemplateForm{
+		ID:       t.ID,
+		Name:     t.Name,
+		Title:    t.Title,
+		Content:  t.Content,
+		Filename: t.Filename,
+		Language: t.Language,
+	}
+	app.render(w, http.StatusOK, "template_form.tmpl.html", data)
+}
+
+func (app *application) accountTemplateEditPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var form snippetTemplateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.ID = id
+
+	form.validate(app.contentLimits)
+
+	userID := reqctx.UserID(r.Context())
+
+	if form.Valid() {
+		err = app.snippetTemplates.Update(form.template(userID))
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+			return
+		} else if errors.Is(err, models.ErrDuplicateTemplateName) {
+			form.AddFieldError("name", "You already have a template with this name")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "template_form.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Template %q saved.", form.Name))
+	http.Redirect(w, r, urlFor("account.templates"), http.StatusSeeOther)
+}
+
+func (app *application) accountTemplateDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.snippetTemplates.Delete(id, reqctx.UserID(r.Context()))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Template deleted.")
+	http.Redirect(w, r, urlFor("account.templates"), http.StatusSeeOther)
+}
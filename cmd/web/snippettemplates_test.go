@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAccountTemplates(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Templates belong to an account, so anonymous users are sent to log in.
+	code, headers, _ := ts.get(t, "/account/templates")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/account/templates")
+	assert.StringContains(t, body, "<a href='/snippet/create?template=1'>Go program</a>")
+
+	csrfToken := ts.csrfToken(t, "/account/templates/create")
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("name", "Shell script")
+	form.Add("title", "A shell script")
+	form.Add("filename", "run.sh")
+	form.Add("language", "bash")
+	form.Add("content", "#!/bin/sh\nset -eu\n")
+
+	code, headers, _ = ts.postForm(t, "/account/templates/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, `Template "Shell script" saved.`)
+	assert.StringContains(t, body, "<a href='/snippet/create?template=2'>Shell script</a>")
+
+	// Names are unique per user.
+	code, _, body = ts.postForm(t, "/account/templates/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You already have a template with this name")
+
+	form.Set("name", "")
+	code, _, body = ts.postForm(t, "/account/templates/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	// Editing.
+	_, _, body = ts.get(t, "/account/templates/edit/2")
+	assert.StringContains(t, body, "<form action='/account/templates/edit/2' method='POST' novalidate>")
+	assert.StringContains(t, body, "value='run.sh'")
+
+	form.Set("name", "POSIX shell script")
+	code, headers, _ = ts.postForm(t, "/account/templates/edit/2", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, `Template "POSIX shell script" saved.`)
+
+	// Deleting.
+	code, headers, _ = ts.postForm(t, "/account/templates/delete/2", url.Values{"csrf_token": {csrfToken}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Template deleted.")
+	assert.Equal(t, strings.Contains(body, "POSIX shell script"), false)
+
+	code, _, _ = ts.get(t, "/account/templates/edit/2")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountTemplatesOtherUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	// Alice's template is invisible to everyone else.
+	code, _, _ := ts.get(t, "/account/templates/edit/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/create?template=1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	form := url.Values{"csrf_token": {ts.csrfToken(t, "/account/templates")}}
+	code, _, _ = ts.postForm(t, "/account/templates/delete/1", form)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountTemplateFromSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "<a href='/account/templates/create?snippet=00000000000000000000000001'>Save as template</a>")
+
+	_, _, body = ts.get(t, "/account/templates/create?snippet=00000000000000000000000001")
+	assert.StringContains(t, body, "<form action='/account/templates/create' method='POST' novalidate>")
+	assert.StringContains(t, body, "name='name' value='An old silent pond'")
+	assert.StringContains(t, body, "<textarea name='content'>An old silent pond...</textarea>")
+
+	code, _, _ := ts.get(t, "/account/templates/create?snippet=00000000000000000000000099")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetCreateFromTemplate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<option value='1'>Go program</option>")
+
+	_, _, body = ts.get(t, "/snippet/create?template=1")
+	assert.StringContains(t, body, "<option value='1' selected>Go program</option>")
+	assert.StringContains(t, body, "value='A Go program'")
+	assert.StringContains(t, body, "value='main.go'")
+	assert.StringContains(t, body, "<textarea name='content'>package main\n\nfunc main() {\n}\n</textarea>")
+
+	code, _, _ := ts.get(t, "/snippet/create?template=99")
+	assert.Equal(t, code, http.StatusNotFound)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/abuse"
+	"github.com/ngohoang211020/snippetbox/internal/pow"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSoftLimitDelay is the longest a request from a suspicious client is held
+// up for.
+const maxSoftLimitDelay = 5 * time.Second
+
+// powTTL is how long a visitor has to solve a proof-of-work challenge.
+const powTTL = 10 * time.Minute
+
+// softLimit slows down, challenges and finally blocks clients which behave
+// suspiciously, like guessing passwords, according to their score.
+type softLimit struct {
+	scorer *abuse.Scorer
+	// pow issues the proof-of-work challenges which the challenge page has
+	// browsers solve.
+	pow *pow.Issuer
+
+	// sleep waits for d, or until ctx is done. It's replaceable in tests.
+	sleep func(ctx context.Context, d time.Duration)
+}
+
+// newSoftLimit returns a softLimit whose scores halve every halfLife, and
+// whose proof-of-work challenges need difficulty leading zero bits.
+func newSoftLimit(halfLife time.Duration, difficulty int) *softLimit {
+	return &softLimit{scorer: abuse.New(halfLife), pow: pow.New(difficulty, powTTL), sleep: sleepContext}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// abuseDetector looks at a request which has been handled, and the status
+// of its response, for something suspicious.
+type abuseDetector func(r *http.Request, status int) (abuse.Signal, bool)
+
+// abuseDetectors are the detectors softRateLimit runs on every request.
+var abuseDetectors = []abuseDetector{
+	detectPost("user.login", http.StatusUnprocessableEntity, abuse.Signal{Name: "failed login", Weight: 3}),
+	detectPost("challenge", http.StatusUnprocessableEntity, abuse.Signal{Name: "failed challenge", Weight: 2}),
+	detectPost("user.signup", http.StatusSeeOther, abuse.Signal{Name: "signup", Weight: 2}),
+	detectPost("snippet.create", http.StatusSeeOther, abuse.Signal{Name: "snippet created", Weight: 1}),
+}
+
+// detectPost returns a detector for POST requests to the named route which
+// are answered with the given status.
+func detectPost(name string, status int, sig abuse.Signal) abuseDetector {
+	return func(r *http.Request, s int) (abuse.Signal, bool) {
+		return sig, r.Method == http.MethodPost && s == status && r.URL.Path == urlFor(name)
+	}
+}
+
+// softRateLimit scores each client IP address with abuseDetectors, and
+// treats the client according to its score: requests which change something
+// are delayed and then have to pass a challenge first, and at worst every
+// request is answered with 429 Too Many Requests until the score has decayed.
+// It does nothing unless -soft-rate-limit is set.
+func (app *application) softRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.softLimit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := clientIP(r)
+		status := app.softLimit.scorer.Check(key)
+		unsafe := r.Method != http.MethodGet && r.Method != http.MethodHead
+
+		switch status.Level {
+		case abuse.Block:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
+			app.clientError(w, http.StatusTooManyRequests)
+			return
+		case abuse.Challenge:
+			if unsafe && r.URL.Path != urlFor("challenge") {
+				app.renderChallenge(w, r, http.StatusTooManyRequests, challengeForm{Next: loginRedirectTarget(r)})
+				return
+			}
+		case abuse.Delay:
+			if unsafe {
+				app.softLimit.sleep(r.Context(), softLimitDelay(status.Score, app.softLimit.scorer.Thresholds))
+			}
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		for _, detect := range abuseDetectors {
+			if sig, ok := detect(r, rec.status); ok {
+				app.softLimit.scorer.Record(key, sig)
+			}
+		}
+	})
+}
+
+// softLimitDelay returns how long to hold up a request from a client with the
+// given score: a second for reaching the delay threshold, growing to
+// maxSoftLimitDelay at the challenge threshold.
+func softLimitDelay(score float64, t abuse.Thresholds) time.Duration {
+	frac := (score - t.Delay) / (t.Challenge - t.Delay)
+	d := time.Second + time.Duration(frac*float64(maxSoftLimitDelay-time.Second))
+	return min(d, maxSoftLimitDelay)
+}
+
+// challengeForm is the challenge page's form. Browsers with JavaScript solve
+// the proof-of-work challenge in Nonce and send its Solution, without the
+// visitor having to do anything; everyone else answers the question.
+type challengeForm struct {
+	Answer              int    `form:"answer"`
+	Nonce               string `form:"nonce"`
+	Solution            string `form:"solution"`
+	Next                string `form:"next"`
+	Question            string `form:"-"`
+	Difficulty          int    `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// renderChallenge shows the challenge page with a new proof-of-work
+// challenge, and a new question whose answer is kept in the session.
+func (app *application) renderChallenge(w http.ResponseWriter, r *http.Request, status int, form challengeForm) {
+	a, b := rand.Intn(9)+1, rand.Intn(9)+1
+	app.sessionManager.Put(r.Context(), "challengeAnswer", a+b)
+
+	form.Question = fmt.Sprintf("What is %d + %d?", a, b)
+	form.Answer = 0
+
+	form.Nonce, form.Solution, form.Difficulty = "", "", 0
+	if app.softLimit != nil {
+		c := app.softLimit.pow.Issue()
+		form.Nonce, form.Difficulty = c.Nonce, c.Difficulty
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, status, "challenge.tmpl.html", data)
+}
+
+func (app *application) challengePost(w http.ResponseWriter, r *http.Request) {
+	var form challengeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if !isLocalPath(form.Next) {
+		form.Next = "/"
+	}
+
+	answer := app.sessionManager.PopInt(r.Context(), "challengeAnswer")
+	if form.Solution != "" && app.softLimit != nil {
+		if err := app.softLimit.pow.Verify(form.Nonce, form.Solution); err != nil {
+			form.AddNonFieldError("Your browser couldn't be checked; please answer the question instead")
+		}
+	} else {
+		form.CheckField(answer != 0 && form.Answer == answer, "answer", "That isn't right; try this one instead")
+	}
+
+	if !form.Valid() {
+		app.renderChallenge(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	if app.softLimit != nil {
+		app.softLimit.scorer.Relieve(clientIP(r))
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks! Please try that again.")
+
+	http.Redirect(w, r, form.Next, http.StatusSeeOther)
+}
+
+func (app *application) adminOffenders(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.SoftRateLimit = app.softLimit != nil
+	if app.softLimit != nil {
+		data.Offenders = app.softLimit.scorer.Offenders()
+	}
+	app.render(w, http.StatusOK, "admin_offenders.tmpl.html", data)
+}
+
+type adminOffenderForm struct {
+	Key string `form:"key"`
+}
+
+// adminOffenderClearPost forgets everything an offender has done, letting
+// them straight back in.
+func (app *application) adminOffenderClearPost(w http.ResponseWriter, r *http.Request) {
+	var form adminOffenderForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || app.softLimit == nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if app.softLimit.scorer.Clear(form.Key) {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been cleared.", form.Key))
+	}
+
+	http.Redirect(w, r, urlFor("admin.offenders"), http.StatusSeeOther)
+}
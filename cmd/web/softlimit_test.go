@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"github.com/ngohoang211020/snippetbox/internal/abuse"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/pow"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSoftRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.softLimit = newSoftLimit(time.Hour, 8)
+
+	var delays []time.Duration
+	app.softLimit.sleep = func(ctx context.Context, d time.Duration) {
+		delays = append(delays, d)
+	}
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/login")
+	form := url.Values{"csrf_token": {csrfToken}, "email": {"alice@example.com"}, "password": {"wrong"}}
+
+	// Each failed login counts for 3: the first two go straight through,
+	// the next two are delayed...
+	for i := 0; i < 4; i++ {
+		code, _, _ := ts.postForm(t, "/user/login", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+	assert.Equal(t, len(delays), 2)
+	assert.Equal(t, delays[0].Round(time.Millisecond), 1800*time.Millisecond)
+
+	// ...and then the client has to answer a challenge before going on,
+	// even with the right password. Pages can still be read.
+	form.Set("password", "pa$$word")
+	code, _, body := ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "<h2>Are You a Person?</h2>")
+
+	code, _, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, body = ts.postForm(t, "/challenge", url.Values{"csrf_token": {csrfToken}, "answer": {"100"}, "next": {"/user/login"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "That isn&#39;t right; try this one instead")
+
+	m := regexp.MustCompile(`What is (\d) &#43; (\d)\?`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatal("no question on the challenge page")
+	}
+	a, _ := strconv.Atoi(m[1])
+	b, _ := strconv.Atoi(m[2])
+
+	code, headers, _ := ts.postForm(t, "/challenge", url.Values{"csrf_token": {csrfToken}, "answer": {strconv.Itoa(a + b)}, "next": {"/user/login"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "Thanks! Please try that again.")
+
+	code, _, _ = ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Browsers can instead solve the proof-of-work challenge on the page,
+	// but each challenge only once.
+	app.softLimit.scorer.Record("127.0.0.1", abuse.Signal{Name: "failed login", Weight: 10})
+
+	code, _, body = ts.postForm(t, "/user/login", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "data-difficulty='8'")
+
+	m = regexp.MustCompile(`name='nonce' value='([^']+)'`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatal("no proof-of-work challenge on the challenge page")
+	}
+	solution := pow.Solve(pow.Challenge{Nonce: m[1], Difficulty: 8})
+	challenge := url.Values{"csrf_token": {csrfToken}, "nonce": {m[1]}, "solution": {solution}, "next": {"/user/login"}}
+
+	code, _, _ = ts.postForm(t, "/challenge", challenge)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	app.softLimit.scorer.Record("127.0.0.1", abuse.Signal{Name: "failed login", Weight: 10})
+	code, _, body = ts.postForm(t, "/challenge", challenge)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Your browser couldn&#39;t be checked; please answer the question instead")
+
+	// Blocked clients are turned away altogether.
+	app.softLimit.scorer.Record("127.0.0.1", abuse.Signal{Name: "failed login", Weight: 20})
+
+	code, headers, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, headers.Get("Retry-After") != "", true)
+}
+
+func TestAdminOffenders(t *testing.T) {
+	app := newTestApplication(t)
+	app.softLimit = newSoftLimit(time.Hour, 8)
+	app.softLimit.scorer.Record("192.0.2.1", abuse.Signal{Name: "failed login", Weight: 12})
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/offenders")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>192.0.2.1</td>")
+	assert.StringContains(t, body, "<td>challenge</td>")
+	assert.StringContains(t, body, "failed login &times;1")
+
+	code, headers, _ := ts.postForm(t, "/admin/offenders/clear", url.Values{"csrf_token": {extractCSRFToken(t, body)}, "key": {"192.0.2.1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "192.0.2.1 has been cleared.")
+	assert.StringContains(t, body, "Nobody is being slowed down, challenged or blocked.")
+}
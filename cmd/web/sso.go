@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"golang.org/x/oauth2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ssoFlowCookie carries the state of a sign-in at the identity provider
+// from userLoginSSO to userLoginSSOCallback. It can't live in the session,
+// because the session cookie is SameSite=Strict and so isn't sent with the
+// provider's redirect back to us.
+const ssoFlowCookie = "sso_flow"
+
+// ssoFlowTTL is how long somebody has to sign in at the provider.
+const ssoFlowTTL = 10 * time.Minute
+
+// sso signs users in with an OpenID Connect provider, using the
+// authorization code flow with PKCE.
+type sso struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+
+	// name is what the sign-in button calls the provider.
+	name string
+	// enforce turns off every other way of signing in, and signing up.
+	enforce bool
+	// provision creates accounts for people the provider vouches for who
+	// don't have one yet.
+	provision bool
+
+	// The provider's configuration is discovered on first use rather than
+	// at startup, so that the site still starts while the provider is down.
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// newSSO returns nil if no issuer is configured, which leaves single sign-on
+// turned off.
+func newSSO(issuer, clientID, clientSecret, name string, enforce, provision bool) (*sso, error) {
+	if issuer == "" {
+		if enforce {
+			return nil, errors.New("-oidc-enforce needs -oidc-issuer")
+		}
+		return nil, nil
+	}
+	if clientID == "" {
+		return nil, errors.New("-oidc-issuer needs -oidc-client-id")
+	}
+	if name == "" {
+		name = "single sign-on"
+	}
+
+	return &sso{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		name:         name,
+		enforce:      enforce,
+		provision:    provision,
+	}, nil
+}
+
+// discover returns the provider's configuration, fetching it the first time.
+func (s *sso) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(ctx, s.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("sso: discovering %s: %w", s.issuer, err)
+	}
+
+	s.provider = provider
+	return provider, nil
+}
+
+// oauth2Config returns the OAuth 2.0 settings for provider. The identity
+// provider sends users back to redirectURL, the callback's absoluteURL.
+func (s *sso) oauth2Config(provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.clientID,
+		ClientSecret: s.clientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+// ssoFlow is what ssoFlowCookie holds.
+type ssoFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next,omitempty"`
+}
+
+// ssoClaims are the ID token claims used to find or create the user.
+type ssoClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// displayName returns the best name the provider gave for the user.
+func (c ssoClaims) displayName() string {
+	for _, name := range []string{c.Name, c.PreferredUsername} {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	name, _, _ := strings.Cut(c.Email, "@")
+	return name
+}
+
+// passwordLoginDisabled reports whether single sign-on is the only way to
+// sign in.
+func (app *application) passwordLoginDisabled() bool {
+	return app.sso != nil && app.sso.enforce
+}
+
+// ssoName returns the name of the identity provider for the sign-in button,
+// or "" if single sign-on is off.
+func (app *application) ssoName() string {
+	if app.sso == nil {
+		return ""
+	}
+	return app.sso.name
+}
+
+// requirePasswordLogin returns 404 Not Found for the password login form
+// while single sign-on is enforced. The other ways of signing in without the
+// provider are covered by requireLocalAccounts.
+func (app *application) requirePasswordLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.passwordLoginDisabled() {
+			app.notFound(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userLoginSSO sends the user to the identity provider to sign in.
+func (app *application) userLoginSSO(w http.ResponseWriter, r *http.Request) {
+	if app.sso == nil {
+		app.notFound(w)
+		return
+	}
+
+	provider, err := app.sso.discover(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	flow := ssoFlow{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		Next:     app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin"),
+	}
+
+	value, err := json.Marshal(flow)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Lax rather than Strict, as the cookie has to come back with the
+	// provider's redirect.
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     urlFor("user.login.sso"),
+		MaxAge:   int(ssoFlowTTL / time.Second),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	url := app.sso.oauth2Config(provider, app.absoluteURL(urlFor("user.login.sso.callback"))).AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// userLoginSSOCallback is where the identity provider sends the user back
+// to. It checks the ID token, finds or creates their account and signs them
+// in.
+func (app *application) userLoginSSOCallback(w http.ResponseWriter, r *http.Request) {
+	if app.sso == nil {
+		app.notFound(w)
+		return
+	}
+
+	flow, ok := readSSOFlow(r)
+	http.SetCookie(w, &http.Cookie{Name: ssoFlowCookie, Path: urlFor("user.login.sso"), MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+
+	query := r.URL.Query()
+	if !ok || query.Get("state") != flow.State {
+		app.ssoError(w, r, "That sign-in attempt has expired. Please try again.")
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.SSO, Reason: fmt.Sprintf("provider refused sign-in: %s: %s", e, query.Get("error_description"))})
+		app.ssoError(w, r, fmt.Sprintf("Signing in with %s didn't work. Please try again.", app.sso.name))
+		return
+	}
+
+	provider, err := app.sso.discover(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	token, err := app.sso.oauth2Config(provider, app.absoluteURL(urlFor("user.login.sso.callback"))).Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		app.errorLog.Printf("sso: exchanging code: %v", err)
+		app.ssoError(w, r, fmt.Sprintf("We couldn't confirm your sign-in with %s. Please try again.", app.sso.name))
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: app.sso.clientID}).Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != flow.Nonce {
+		if err == nil {
+			err = errors.New("nonce doesn't match")
+		}
+		app.errorLog.Printf("sso: verifying ID token: %v", err)
+		app.ssoError(w, r, fmt.Sprintf("We couldn't confirm your sign-in with %s. Please try again.", app.sso.name))
+		return
+	}
+
+	var claims ssoClaims
+	if err = idToken.Claims(&claims); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := app.ssoUser(idToken.Issuer, claims)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.recordAuthEvent(r, authevents.Event{Kind: authevents.LoginFailed, Method: authevents.SSO, Email: claims.Email, Reason: "no such account"})
+			app.ssoError(w, r, "There's no account for you here yet. Please ask an administrator to create one.")
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.rememberLoginRedirect(r, flow.Next)
+	path, err := app.startSession(r, id, authevents.SSO)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The browser treats a redirect at the end of the trip through the
+	// provider as cross-site, so it wouldn't send the new session cookie
+	// with it. A page which moves on by itself is a navigation from this
+	// site, which does.
+	w.Header().Set("Refresh", "0; url="+path)
+
+	data := app.newTemplateData(r)
+	data.RedirectPath = path
+	app.render(w, http.StatusOK, "login_sso.tmpl.html", data)
+}
+
+// ssoUser returns the ID of the user that the provider signed in. Users are
+// found by the account at the provider if it has been linked, and then by
+// verified email address, which links it. Failing that, a new user is
+// created if provisioning is on; otherwise ErrNoRecord is returned.
+func (app *application) ssoUser(issuer string, claims ssoClaims) (int, error) {
+	id, err := app.identities.UserID(issuer, claims.Subject)
+	if err == nil || !errors.Is(err, models.ErrNoRecord) {
+		return id, err
+	}
+
+	// An unverified address might not belong to whoever signed in, so it
+	// isn't trusted to pick an existing account.
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, models.ErrNoRecord
+	}
+
+	user, err := app.users.GetByEmail(claims.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	if user == nil {
+		if !app.sso.provision {
+			return 0, models.ErrNoRecord
+		}
+
+		// The account is only ever signed in to through the provider, so
+		// its password is random and never told to anyone.
+		err = app.users.Insert(claims.displayName(), claims.Email, randomToken())
+		if err != nil {
+			return 0, err
+		}
+		user, err = app.users.GetByEmail(claims.Email)
+		if err != nil {
+			return 0, err
+		}
+		app.infoLog.Printf("sso: created user %d for %s", user.ID, claims.Email)
+	}
+
+	err = app.identities.Link(user.ID, issuer, claims.Subject)
+	if err != nil && !errors.Is(err, models.ErrDuplicateIdentity) {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+// ssoError shows the login page with an error about signing in with the
+// identity provider.
+func (app *application) ssoError(w http.ResponseWriter, r *http.Request, message string) {
+	form := userLoginForm{}
+	form.AddNonFieldError(message)
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+}
+
+func readSSOFlow(r *http.Request) (ssoFlow, bool) {
+	var flow ssoFlow
+
+	cookie, err := r.Cookie(ssoFlowCookie)
+	if err != nil {
+		return flow, false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return flow, false
+	}
+	if err = json.Unmarshal(value, &flow); err != nil || flow.State == "" {
+		return flow, false
+	}
+
+	return flow, true
+}
+
+// randomToken returns 256 random bits, base64-encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIdP is just enough of an OpenID Connect provider to sign in with. It
+// signs in whoever it's told to, without asking.
+type fakeIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu sync.Mutex
+	// claims are put in the next ID token, along with the standard ones.
+	claims map[string]any
+	// codes holds the PKCE challenge and nonce of each code handed out.
+	codes map[string][2]string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &fakeIdP{key: key, codes: map[string][2]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "PKCE is required", http.StatusBadRequest)
+			return
+		}
+
+		code := randomToken()
+		idp.mu.Lock()
+		idp.codes[code] = [2]string{q.Get("code_challenge"), q.Get("nonce")}
+		idp.mu.Unlock()
+
+		redirect := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		idp.mu.Lock()
+		issued, ok := idp.codes[r.Form.Get("code")]
+		delete(idp.codes, r.Form.Get("code"))
+		claims := idp.claims
+		idp.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != issued[0] {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		payload := map[string]any{
+			"iss":   idp.URL,
+			"aud":   "snippetbox",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": issued[1],
+		}
+		for k, v := range claims {
+			payload[k] = v
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, payload),
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *fakeIdP) sign(t *testing.T, payload map[string]any) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: idp.key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// signInWithSSO goes through the sign-in flow, signing in at idp as
+// whoever claims says, and returns the response to the callback.
+func signInWithSSO(t *testing.T, ts *testServer, idp *fakeIdP, claims map[string]any) (int, http.Header, string) {
+	idp.mu.Lock()
+	idp.claims = claims
+	idp.mu.Unlock()
+
+	code, headers, _ := ts.get(t, "/user/login/sso")
+	assert.Equal(t, code, http.StatusFound)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	rs, err := client.Get(headers.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusFound)
+
+	callback, err := url.Parse(rs.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts.get(t, callback.RequestURI())
+}
+
+func TestSSO(t *testing.T) {
+	idp := newFakeIdP(t)
+	defer idp.Close()
+
+	s, err := newSSO(idp.URL, "snippetbox", "secret", "Example", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.sso = s
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	assert.StringContains(t, body, "sign in with Example")
+
+	t.Run("Unknown user", func(t *testing.T) {
+		ts.resetClient(t)
+
+		code, _, body := signInWithSSO(t, ts, idp, map[string]any{"sub": "bob", "email": "bob@example.com", "email_verified": true})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "no account for you here yet")
+	})
+
+	t.Run("Unverified email", func(t *testing.T) {
+		ts.resetClient(t)
+
+		code, _, _ := signInWithSSO(t, ts, idp, map[string]any{"sub": "alice", "email": "alice@example.com"})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Verified email", func(t *testing.T) {
+		ts.resetClient(t)
+
+		// Where to go afterwards is carried through the provider.
+		ts.get(t, "/user/login?next=/feed")
+
+		code, headers, body := signInWithSSO(t, ts, idp, map[string]any{"sub": "alice", "email": "alice@example.com", "email_verified": true})
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Refresh"), "0; url=/feed")
+		assert.StringContains(t, body, "<a href='/feed'>Continue</a>")
+
+		code, _, _ = ts.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Linked identity", func(t *testing.T) {
+		ts.resetClient(t)
+
+		// The email address has changed at the provider, but the account
+		// was linked when Alice last signed in.
+		code, _, _ := signInWithSSO(t, ts, idp, map[string]any{"sub": "alice", "email": "alice@new.example.com"})
+		assert.Equal(t, code, http.StatusOK)
+
+		code, _, _ = ts.get(t, "/account/view")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Wrong state", func(t *testing.T) {
+		ts.resetClient(t)
+
+		ts.get(t, "/user/login/sso")
+		code, _, body := ts.get(t, "/user/login/sso/callback?code=x&state=forged")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "sign-in attempt has expired")
+	})
+}
+
+func TestSSOEnforced(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, _, _ := ts.get(t, "/user/login/sso")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, err := newSSO("", "", "", "", true, false)
+	assert.Equal(t, err != nil, true)
+
+	app.sso, err = newSSO("https://idp.example.com", "snippetbox", "", "Example", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Sign in with Example")
+	assert.Equal(t, csrfTokenRX.MatchString(body), false)
+
+	for _, path := range []string{"/user/login/magic", "/user/login/magic/token", "/user/signup"} {
+		code, _, _ = ts.get(t, path)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+}
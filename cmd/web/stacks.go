@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/middleware"
+	"github.com/ngohoang211020/snippetbox/internal/ratelimit"
+	"net/http"
+	"slices"
+)
+
+// middlewareRules are the orderings which the middleware relies on. Every
+// route's stack, with the standard stack around it, is checked against them
+// when the routes are set up.
+var middlewareRules = []middleware.Rule{
+	// The access log includes the request ID, and the 500 responses sent
+	// for panics.
+	{Outer: "requestContext", Inner: "logRequest"},
+	{Outer: "logRequest", Inner: "recoverPanic"},
+	// Requests are timed under the route they matched, and the 500
+	// responses sent for panics count as failures.
+	{Outer: "requestContext", Inner: "recordPerformance"},
+	{Outer: "recordPerformance", Inner: "recoverPanic"},
+	{Outer: "recoverPanic", Inner: "secureHeaders"},
+	// Stale pages are served instead of a 503 while the database is down,
+	// and the sessions are kept in the database.
+	{Outer: "serveStale", Inner: "requireDatabase"},
+	{Outer: "requireDatabase", Inner: "session"},
+	// The user is looked up from the session, and shouldn't be trusted
+	// with a form post before its CSRF token has been checked.
+	{Outer: "session", Inner: "noSurf"},
+	{Outer: "noSurf", Inner: "authenticate"},
+	{Outer: "session", Inner: "authenticate"},
+	{Outer: "session", Inner: "softRateLimit"},
+	{Outer: "authenticate", Inner: "requireAuthentication"},
+	// Impersonation is found out about while authenticating.
+	{Outer: "authenticate", Inner: "forbidImpersonation"},
+	{Outer: "requireAuthentication", Inner: "requireAdmin"},
+	// Clients from the wrong network aren't even asked to sign in.
+	{Outer: "requireAllowedIP", Inner: "requireAuthentication"},
+	// API errors carry CORS headers, so that scripts on other sites can
+	// read them, and API clients are rate-limited by who they are.
+	{Outer: "enableCORS", Inner: "requireDatabase"},
+	{Outer: "authenticate", Inner: "apiRateLimit"},
+	{Outer: "authenticateBearer", Inner: "apiRateLimit"},
+	{Outer: "authenticateBearer", Inner: "apiRequireAuthentication"},
+	// Visitors who aren't signed in are sent to sign in before being told
+	// that there's nothing they could do once they had.
+	{Outer: "requireAuthentication", Inner: "requireWritable"},
+	{Outer: "apiRequireAuthentication", Inner: "requireWritable"},
+	// The links which only work once are remembered in the database, and
+	// those which fail to verify aren't used up.
+	{Outer: "requireDatabase", Inner: "consumeSignedURL"},
+	{Outer: "requireWritable", Inner: "consumeSignedURL"},
+}
+
+// standardStack is the middleware which every request goes through, around
+// the router. logRequest wraps recoverPanic so that the 500 responses sent
+// for panics still make it into the access log.
+func (app *application) standardStack() middleware.Stack {
+	return middleware.NewStack("standard",
+		middleware.New("requestContext", app.requestContext),
+		middleware.New("logRequest", app.logRequest),
+		middleware.New("recordPerformance", app.recordPerformance),
+		middleware.New("recoverPanic", app.recoverPanic),
+		middleware.New("secureHeaders", secureHeaders),
+		middleware.New("noIndex", app.noIndex),
+	)
+}
+
+// middlewareStacks puts together the stack for each middleware set, which
+// runs inside the standard stack.
+func (app *application) middlewareStacks() map[middlewareSet]middleware.Stack {
+	// While the database is unavailable, visitors get recent copies of the
+	// pages or a 503, before the session is even loaded. Suspicious clients
+	// are slowed down, challenged or blocked last, once the session holding
+	// their challenge is loaded.
+	dynamic := middleware.NewStack("dynamic",
+		middleware.New("serveStale", app.serveStale),
+		middleware.New("requireDatabase", app.requireDatabase(app.errorPage)),
+		middleware.New("session", app.sessionManager.LoadAndSave),
+		middleware.New("noSurf", noSurf),
+		middleware.New("authenticate", app.authenticate),
+		middleware.New("softRateLimit", app.softRateLimit),
+	)
+
+	// Signing in can be restricted to certain networks with -login-ip-rules.
+	login := dynamic.Extend("login", middleware.New("requireAllowedIP", app.requireAllowedIP(app.ipRules.login, "login")))
+
+	protected := dynamic.Extend("protected", middleware.New("requireAuthentication", app.requireAuthentication))
+
+	// The JSON API lives under /api/v1. It shares the session with the web
+	// pages but deliberately skips the CSRF middleware, which only knows how
+	// to check form submissions; see apiRequireAuthentication for how writes
+	// are protected instead. Scripts and pkg/client authenticate with an
+	// API token instead of a session.
+	api := middleware.NewStack("api",
+		middleware.New("requireFeature", app.requireFeature(features.APIEnabled)),
+		middleware.New("enableCORS", app.enableCORS),
+		middleware.New("requireDatabase", app.requireDatabase(app.apiError)),
+		middleware.New("session", app.sessionManager.LoadAndSave),
+		middleware.New("authenticate", app.authenticate),
+		middleware.New("authenticateBearer", app.authenticateBearer(app.apiError)),
+		middleware.New("apiRateLimit", app.apiRateLimit),
+	)
+
+	requireLocalAccounts := middleware.New("requireLocalAccounts", app.requireLocalAccounts)
+
+	return map[middlewareSet]middleware.Stack{
+		chainNone: middleware.NewStack("none"),
+		// Browsers send CSP violation reports without cookies, so the
+		// endpoint doesn't need the session. It is rate-limited per IP
+		// address instead, since anyone can post to it.
+		chainCSPReport: middleware.NewStack("cspReport", middleware.New("rateLimit", app.rateLimit(ratelimit.New(1, 10)))),
+		chainDynamic:   dynamic,
+		// Signup is only available while the signup_open feature flag is
+		// on, and accounts aren't managed by a directory or identity
+		// provider.
+		chainSignup: dynamic.Extend("signup",
+			middleware.New("requireFeature", app.requireFeature(features.SignupOpen)),
+			requireLocalAccounts,
+		),
+		chainLogin: login,
+		// When single sign-on is enforced, the identity provider is the
+		// only way in. Sign-in links and passkeys would get around the
+		// directory or identity provider, so they are turned off whenever
+		// either is in charge of accounts.
+		chainPasswordLogin: login.Extend("passwordLogin", middleware.New("requirePasswordLogin", app.requirePasswordLogin)),
+		chainLocalLogin:    login.Extend("localLogin", requireLocalAccounts),
+		chainProtected:     protected,
+		// Passwords and email addresses are managed by the directory or
+		// identity provider, if there is one in charge of accounts.
+		chainProtectedLocal: protected.Extend("protectedLocal", requireLocalAccounts),
+		// Admin pages additionally require the authenticated user to have
+		// the admin role, and can be restricted to certain networks with
+		// -admin-ip-rules.
+		chainAdmin: dynamic.Extend("admin",
+			middleware.New("requireAllowedIP", app.requireAllowedIP(app.ipRules.admin, "admin")),
+			middleware.New("requireAuthentication", app.requireAuthentication),
+			middleware.New("requireAdmin", app.requireAdmin),
+		),
+		chainAPI:          api,
+		chainAPIProtected: api.Extend("apiProtected", middleware.New("apiRequireAuthentication", app.apiRequireAuthentication)),
+		// The quick paste endpoint is for curl and scripts, which
+		// authenticate with an API token rather than a session. Without a
+		// session cookie there's nothing for another site to forge a
+		// request with, so it needs neither the session nor the CSRF
+		// middleware. It's rate-limited like the rest of the API.
+		chainQuick: middleware.NewStack("quick",
+			middleware.New("requireFeature", app.requireFeature(features.APIEnabled)),
+			middleware.New("requireDatabase", app.requireDatabase(quickError)),
+			middleware.New("authenticateBearer", app.authenticateBearer(quickError)),
+			middleware.New("apiRateLimit", app.apiRateLimit),
+		),
+		// ActivityPub requests come from other servers, which prove who
+		// they are by signing their requests rather than with a session.
+		chainActivityPub: middleware.NewStack("activityPub",
+			middleware.New("requireActivityPub", app.requireActivityPub),
+			middleware.New("requireDatabase", app.requireDatabase(app.apiError)),
+		),
+		// One-click unsubscribe links are posted to by mail clients, with
+		// neither a session cookie nor a CSRF token, as RFC 8058 says.
+		// The signature in the link is what proves the request was meant,
+		// so these routes check it with requireSignedURL and nothing is
+		// taken from the session but the flash message.
+		chainOneClick: middleware.NewStack("oneClick",
+			middleware.New("requireDatabase", app.requireDatabase(app.errorPage)),
+			middleware.New("session", app.sessionManager.LoadAndSave),
+		),
+	}
+}
+
+// routeMiddleware is the middleware which routes can add to the end of their
+// chain by name, with the with field of their entry in routeTable.
+func (app *application) routeMiddleware() map[string]middleware.Middleware {
+	return map[string]middleware.Middleware{
+		"recordView":          middleware.New("recordView", app.recordView),
+		"forbidImpersonation": middleware.New("forbidImpersonation", app.forbidImpersonation),
+		"resolveSnippetID":    middleware.New("resolveSnippetID", app.resolveSnippetID),
+		// Each of these turns source routes off while the instance protects
+		// its source, in the format the route's chain answers in.
+		"requireSourceVisible":    middleware.New("requireSourceVisible", app.requireSourceVisible(app.notFound)),
+		"apiRequireSourceVisible": middleware.New("requireSourceVisible", app.requireSourceVisible(app.apiNotFound)),
+		// Each of these refuses writes while the instance is read-only, in
+		// the format the route's chain answers in.
+		"requireWritable":      middleware.New("requireWritable", app.requireWritable(app.errorPage, readOnlyMessage)),
+		"apiRequireWritable":   middleware.New("requireWritable", app.requireWritable(app.apiError, apiReadOnlyMessage)),
+		"quickRequireWritable": middleware.New("requireWritable", app.requireWritable(quickError, apiReadOnlyMessage)),
+		// Links sent in emails are signed; see internal/signedurl. Those
+		// which are consumed only work once.
+		"requireSignedURL": middleware.New("requireSignedURL", app.urlSigner.Require(nil, app.signedURLFailed)),
+		"consumeSignedURL": middleware.New("consumeSignedURL", app.urlSigner.Require(app.consumedTokens, app.signedURLFailed)),
+		"checkLoginLink":   middleware.New("requireSignedURL", app.urlSigner.Require(nil, app.loginLinkFailed)),
+		"consumeLoginLink": middleware.New("consumeSignedURL", app.urlSigner.Require(app.consumedTokens, app.loginLinkFailed)),
+	}
+}
+
+// routeWith returns the names of the middleware from routeMiddleware which
+// rt adds to its chain: those it asks for, one which looks up the snippet's
+// public ID if it takes one, one which turns it off while the instance
+// protects its source if it's a source route, and one which refuses
+// writes while the instance is read-only, unless it only reads or is safe to
+// post to.
+func routeWith(rt route) []string {
+	with := rt.with
+	if rt.source {
+		sourceVisible := "requireSourceVisible"
+		switch rt.chain {
+		case chainAPI, chainAPIProtected, chainActivityPub:
+			sourceVisible = "apiRequireSourceVisible"
+		}
+		with = append([]string{sourceVisible}, with...)
+	}
+	if rt.publicID {
+		with = append([]string{"resolveSnippetID"}, with...)
+	}
+
+	if rt.method == http.MethodGet || rt.readOnlySafe || rt.chain == chainAdmin {
+		return with
+	}
+
+	writable := "requireWritable"
+	switch rt.chain {
+	case chainAPI, chainAPIProtected, chainActivityPub:
+		writable = "apiRequireWritable"
+	case chainQuick, chainCSPReport:
+		writable = "quickRequireWritable"
+	}
+	return append([]string{writable}, with...)
+}
+
+// routeStack returns the stack which rt runs through, not counting the
+// standard stack. It panics if rt asks for middleware which doesn't exist,
+// since that's a mistake in the route table.
+func routeStack(stacks map[middlewareSet]middleware.Stack, extra map[string]middleware.Middleware, rt route) middleware.Stack {
+	stack := stacks[rt.chain]
+	with := routeWith(rt)
+	if len(with) == 0 {
+		return stack
+	}
+
+	var mw []middleware.Middleware
+	for _, name := range with {
+		m, ok := extra[name]
+		if !ok {
+			panic(fmt.Sprintf("route %q: no route middleware named %q", rt.name, name))
+		}
+		mw = append(mw, m)
+	}
+	return stack.Extend(rt.name, mw...)
+}
+
+// logMiddleware logs the standard stack and then the stack of each
+// middleware set, along with those of the routes which add to theirs, so
+// that what a request goes through can be seen at a glance.
+func (app *application) logMiddleware() {
+	app.infoLog.Printf("middleware %s", app.standardStack())
+
+	stacks := app.middlewareStacks()
+	sets := make([]middlewareSet, 0, len(stacks))
+	for set := range stacks {
+		sets = append(sets, set)
+	}
+	slices.Sort(sets)
+	for _, set := range sets {
+		app.infoLog.Printf("middleware %s", stacks[set])
+	}
+
+	extra := app.routeMiddleware()
+	logged := map[string]bool{}
+	for _, rt := range routeTable {
+		if len(rt.with) == 0 || logged[rt.name] || (rt.debugOnly && !app.debug) {
+			continue
+		}
+		logged[rt.name] = true
+		app.infoLog.Printf("middleware %s", routeStack(stacks, extra, rt))
+	}
+}
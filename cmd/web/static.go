@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/ui"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// staticContentTypes lists the only kinds of file the static file server
+// will serve, along with their content types. Setting these explicitly
+// rather than relying on the system's MIME database means fonts and wasm get
+// the right type everywhere.
+var staticContentTypes = map[string]string{
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".map":   "application/json",
+	".png":   "image/png",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".gif":   "image/gif",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".ico":   "image/x-icon",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".wasm":  "application/wasm",
+	".txt":   "text/plain; charset=utf-8",
+}
+
+// staticEncodings are the pre-compressed variants which are looked for next
+// to each static file, in order of preference. For example a request for
+// main.css from a browser which accepts brotli gets main.css.br, if there
+// is one.
+var staticEncodings = []struct {
+	name      string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticFS holds the static files embedded in ui.Files. Only the static
+// directory is reachable through it, not the templates.
+var staticFS = func() fs.FS {
+	fsys, err := fs.Sub(ui.Files, "static")
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}()
+
+// staticFiles serves the files in staticFS under /static.
+func (app *application) staticFiles(w http.ResponseWriter, r *http.Request) {
+	http.StripPrefix("/static", app.serveStatic(staticFS)).ServeHTTP(w, r)
+}
+
+// serveStatic returns a handler which serves files from fsys. Unlike
+// http.FileServer it never lists directories, only serves the types of file
+// in staticContentTypes, and uses our own 404 response for anything else.
+func (app *application) serveStatic(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+		contentType, ok := staticContentTypes[path.Ext(name)]
+		if !ok {
+			app.notFound(w)
+			return
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil || info.IsDir() {
+			app.notFound(w)
+			return
+		}
+
+		// Serve a compressed variant if the client accepts it. The response
+		// depends on Accept-Encoding whenever a variant exists, even if this
+		// client doesn't get it.
+		encoding := ""
+		for _, enc := range staticEncodings {
+			variant, err := fs.Stat(fsys, name+enc.extension)
+			if err != nil || variant.IsDir() {
+				continue
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			if encoding == "" && acceptsEncoding(r.Header.Get("Accept-Encoding"), enc.name) {
+				encoding = enc.name
+				name += enc.extension
+				info = variant
+			}
+		}
+
+		content, err := openStatic(fsys, name)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", contentType)
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+
+		http.ServeContent(w, r, name, info.ModTime(), content)
+	})
+}
+
+// staticFile is an open static file which http.ServeContent can seek in.
+type staticFile interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// openStatic opens a file for serving. Files from embed.FS can already seek;
+// others are read into memory.
+func openStatic(fsys fs.FS, name string) (staticFile, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if sf, ok := f.(staticFile); ok {
+		return sf, nil
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return nopCloser{bytes.NewReader(b)}, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the given
+// content coding. Codings with a quality of zero are refused, and "*"
+// accepts anything not mentioned explicitly.
+func acceptsEncoding(header, encoding string) bool {
+	wildcard := false
+
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		accepted := true
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				accepted = err == nil && q > 0
+			}
+		}
+
+		switch coding {
+		case encoding:
+			return accepted
+		case "*":
+			wildcard = accepted
+		}
+	}
+
+	return wildcard
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeStatic(t *testing.T) {
+	app := newTestApplication(t)
+
+	fsys := fstest.MapFS{
+		"css/main.css":         {Data: []byte("body {}")},
+		"css/main.css.gz":      {Data: []byte("gzipped")},
+		"css/main.css.br":      {Data: []byte("brotli")},
+		"js/main.js":           {Data: []byte("var x;")},
+		"fonts/mono.woff2":     {Data: []byte("font")},
+		"app.wasm":             {Data: []byte("wasm")},
+		"notes.md":             {Data: []byte("# Notes")},
+		"dir.css/whatever.css": {Data: []byte("")},
+	}
+	handler := app.serveStatic(fsys)
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantCode       int
+		wantType       string
+		wantEncoding   string
+		wantBody       string
+	}{
+		{
+			name:     "Stylesheet",
+			path:     "/css/main.css",
+			wantCode: http.StatusOK,
+			wantType: "text/css; charset=utf-8",
+			wantBody: "body {}",
+		},
+		{
+			name:           "Brotli preferred",
+			path:           "/css/main.css",
+			acceptEncoding: "gzip, deflate, br",
+			wantCode:       http.StatusOK,
+			wantType:       "text/css; charset=utf-8",
+			wantEncoding:   "br",
+			wantBody:       "brotli",
+		},
+		{
+			name:           "Gzip",
+			path:           "/css/main.css",
+			acceptEncoding: "gzip, br;q=0",
+			wantCode:       http.StatusOK,
+			wantType:       "text/css; charset=utf-8",
+			wantEncoding:   "gzip",
+			wantBody:       "gzipped",
+		},
+		{
+			name:           "No variant",
+			path:           "/js/main.js",
+			acceptEncoding: "gzip",
+			wantCode:       http.StatusOK,
+			wantType:       "text/javascript; charset=utf-8",
+			wantBody:       "var x;",
+		},
+		{
+			name:     "Font",
+			path:     "/fonts/mono.woff2",
+			wantCode: http.StatusOK,
+			wantType: "font/woff2",
+		},
+		{
+			name:     "Wasm",
+			path:     "/app.wasm",
+			wantCode: http.StatusOK,
+			wantType: "application/wasm",
+		},
+		{
+			name:     "Directory",
+			path:     "/css/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Directory with an allowed extension",
+			path:     "/dir.css",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Extension not allowed",
+			path:     "/notes.md",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Compressed variant requested directly",
+			path:     "/css/main.css.gz",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Missing file",
+			path:     "/css/missing.css",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Path traversal",
+			path:     "/../css/main.css",
+			wantCode: http.StatusOK,
+			wantBody: "body {}",
+			wantType: "text/css; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tt.path
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, rr.Header().Get("Content-Type"), tt.wantType)
+			assert.Equal(t, rr.Header().Get("Content-Encoding"), tt.wantEncoding)
+			if tt.wantBody != "" {
+				assert.Equal(t, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"deflate, GZIP", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"gzip; q=0.5", "gzip", true},
+		{"*", "br", true},
+		{"*, br;q=0", "br", false},
+		{"*;q=0", "gzip", false},
+		{"deflate", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, acceptsEncoding(tt.header, tt.encoding), tt.want)
+		})
+	}
+}
+
+func TestStaticRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/static/css/main.css")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/css; charset=utf-8")
+
+	// There's no directory listing, and the templates aren't reachable.
+	code, _, _ = ts.get(t, "/static/css/")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, "/static/../html/base.tmpl.html")
+	assert.Equal(t, code, http.StatusNotFound)
+}
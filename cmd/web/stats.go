@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// statsDays is how many days the snippet statistics page covers.
+const statsDays = 30
+
+// recordView is a lightweight analytics middleware for the snippet view page.
+// It records each successful view of the snippet named by the :id parameter,
+// along with where the visitor came from and which browser they used, for the
+// owner's statistics page. Nothing which identifies the visitor is kept, and
+// views by bots aren't counted at all.
+func (app *application) recordView(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status != http.StatusOK {
+			return
+		}
+
+		browser := browserFamily(r.UserAgent())
+		if browser == "Bot" {
+			return
+		}
+
+		id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+		if err != nil {
+			return
+		}
+
+		// The response has already been sent, so all we can do with errors
+		// is log them.
+		err = app.snippetStats.RecordView(&models.SnippetView{
+			SnippetID: id,
+			Referrer:  referrerHost(r),
+			Country:   viewCountry(r),
+			Browser:   browser,
+		})
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+}
+
+// browserFamily sorts a User-Agent header into a handful of broad groups. The
+// order of the checks matters, as most browsers claim to be several others.
+func browserFamily(ua string) string {
+	lower := strings.ToLower(ua)
+
+	switch {
+	case ua == "":
+		return "Unknown"
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawl") || strings.Contains(lower, "spider"):
+		return "Bot"
+	case strings.HasPrefix(lower, "curl/") || strings.HasPrefix(lower, "wget/") || strings.HasPrefix(lower, "go-http-client/"):
+		return "Command line"
+	case strings.Contains(ua, "Edg/"):
+		return "Edge"
+	case strings.Contains(ua, "Firefox/"):
+		return "Firefox"
+	case strings.Contains(ua, "Chrome/") || strings.Contains(ua, "Chromium/"):
+		return "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		return "Safari"
+	default:
+		return "Other"
+	}
+}
+
+// referrerHost returns the host of the page which linked to the request, or
+// an empty string for direct visits.
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// viewCountry returns the visitor's country code, if a CDN in front of the
+// application has worked it out: we don't have a geolocation database of our
+// own. Cloudflare sends the CF-IPCountry header, and other CDNs can be set up
+// to send the same one.
+func viewCountry(r *http.Request) string {
+	// XX means unknown and T1 means Tor.
+	country := strings.ToUpper(r.Header.Get("CF-IPCountry"))
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	for _, c := range country {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return country
+}
+
+// snippetStatsView shows the owner of a snippet how often it has been viewed over
+// the last statsDays days, and by whom.
+func (app *application) snippetStatsView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	userID := reqctx.UserID(r.Context())
+	visible, err := app.snippetVisible(snippet, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !visible {
+		app.notFound(w)
+		return
+	}
+	if snippet.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	stats, err := app.snippetStats.ForSnippet(id, statsDays)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.SnippetStats = stats
+	app.render(w, http.StatusOK, "stats.tmpl.html", data)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"net/http"
+	"testing"
+)
+
+func TestBrowserFamily(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want string
+	}{
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/119.0", "Firefox"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36", "Chrome"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0", "Edge"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15", "Safari"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Bot"},
+		{"curl/8.4.0", "Command line"},
+		{"", "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, browserFamily(tt.ua), tt.want)
+		})
+	}
+}
+
+func TestRecordView(t *testing.T) {
+	stats := &mocks.SnippetStatsModel{}
+
+	app := newTestApplication(t)
+	app.snippetStats = stats
+
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	headers := http.Header{}
+	headers.Set("Referer", "https://News.example.com/item?id=1")
+	headers.Set("CF-IPCountry", "nz")
+	headers.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/119.0")
+	ts.do(t, http.MethodGet, "/snippet/view/00000000000000000000000001", headers, "")
+
+	// Bots and missing snippets aren't counted.
+	headers.Set("User-Agent", "Googlebot/2.1")
+	ts.do(t, http.MethodGet, "/snippet/view/00000000000000000000000001", headers, "")
+	ts.get(t, "/snippet/view/99")
+
+	assert.Equal(t, len(stats.Views), 1)
+	view := stats.Views[0]
+	assert.Equal(t, view.SnippetID, 1)
+	assert.Equal(t, view.Referrer, "news.example.com")
+	assert.Equal(t, view.Country, "NZ")
+	assert.Equal(t, view.Browser, "Firefox")
+}
+
+func TestSnippetStatsView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/snippet/stats/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// Only the owner of the snippet, user 2, can see its statistics.
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/snippet/stats/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	ts.resetClient(t)
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/view/00000000000000000000000001")
+	assert.StringContains(t, body, "href='/snippet/stats/00000000000000000000000001'")
+
+	code, _, body = ts.get(t, "/snippet/stats/00000000000000000000000001")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "1 views in the last 30 days, 1 of them today.")
+	assert.StringContains(t, body, "<td>Direct</td>")
+
+	code, _, _ = ts.get(t, "/snippet/stats/99")
+	assert.Equal(t, code, http.StatusNotFound)
+}
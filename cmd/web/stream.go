@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultStreamAfter is how long renderStream waits for a page's data
+// before it sends the top of the page without it.
+const defaultStreamAfter = 100 * time.Millisecond
+
+// renderStream renders a page in the base layout whose data can take a while
+// to load, like a long list of snippets. load fills in the data in the
+// background. If it's done within app.streamAfter, the page is rendered like
+// any other. Otherwise the top of the page, down to the navigation, is sent
+// straight away, so that the browser can get on with the stylesheet, and
+// the rest once load is done. So load mustn't touch anything that the top of
+// the page uses.
+//
+// Once the top of the page has been sent it's too late for an error page, so
+// an error after that is logged and the response is cut short. Either way,
+// nothing more is sent once the client has gone or the request's deadline
+// has passed.
+func renderStream[T pageData](app *application, w http.ResponseWriter, r *http.Request, status int, page string, data T, load func(T) error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- load(data)
+	}()
+
+	timer := time.NewTimer(app.streamAfter)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		render(app, w, status, page, data)
+		return
+	case <-r.Context().Done():
+		return
+	case <-timer.C:
+	}
+
+	ts, err := app.templateSet(page, "base")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	sw := &streamWriter{buf: buf, w: w}
+	data.base().flush = func() error {
+		w.WriteHeader(status)
+		if err := sw.start(); err != nil {
+			return err
+		}
+		err := http.NewResponseController(w).Flush()
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+
+		select {
+		case err := <-done:
+			return err
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+
+	err = ts.ExecuteTemplate(sw, "base", data)
+	switch {
+	case !sw.started && err == nil:
+		app.serverError(w, fmt.Errorf("rendering %s: the layout never called Flush", page))
+	case !sw.started:
+		app.serverError(w, err)
+	case err != nil && r.Context().Err() == nil:
+		app.errorLog.Printf("streaming %s: %s", page, err)
+	}
+}
+
+// streamWriter holds on to what's written to it until start is called, and
+// from then on writes straight to w.
+type streamWriter struct {
+	buf     *bytes.Buffer
+	w       io.Writer
+	started bool
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	if sw.started {
+		return sw.w.Write(b)
+	}
+	return sw.buf.Write(b)
+}
+
+// start writes what's been held on to so far.
+func (sw *streamWriter) start() error {
+	sw.started = true
+	_, err := sw.buf.WriteTo(sw.w)
+	return err
+}
+
+// Flush sends what's been rendered so far of a page being streamed by
+// renderStream, and waits for the rest of its data. The base layout calls it
+// once the page's header and navigation are done. It does nothing for pages
+// which aren't being streamed.
+func (data *templateBase) Flush() (string, error) {
+	if data.flush == nil {
+		return "", nil
+	}
+	flush := data.flush
+	data.flush = nil
+	return "", flush()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamTestServer serves the home page with renderStream, loading the
+// snippets with load.
+func streamTestServer(t *testing.T, app *application, load func(*homePage) error) *httptest.Server {
+	return httptest.NewServer(app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renderStream(app, w, r, http.StatusOK, "home.tmpl.html", &homePage{templateBase: app.newTemplateBase(r)}, load)
+	})))
+}
+
+func loadSnippet(data *homePage) error {
+	data.Snippets = []*models.Snippet{{ID: 1, Title: "An old silent pond"}}
+	return nil
+}
+
+func TestRenderStream(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Quick", func(t *testing.T) {
+		ts := streamTestServer(t, app, loadSnippet)
+		defer ts.Close()
+
+		rs, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		body, _ := io.ReadAll(rs.Body)
+
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+		assert.StringContains(t, string(body), "An old silent pond")
+		assert.StringContains(t, string(body), "</html>")
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		release := make(chan struct{})
+		ts := streamTestServer(t, app, func(data *homePage) error {
+			<-release
+			return loadSnippet(data)
+		})
+		defer ts.Close()
+
+		rs, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+
+		// The navigation arrives before the snippets have been loaded.
+		br := bufio.NewReader(rs.Body)
+		var top strings.Builder
+		for !strings.Contains(top.String(), "</nav>") {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			top.WriteString(line)
+		}
+
+		close(release)
+		rest, _ := io.ReadAll(br)
+		assert.StringContains(t, string(rest), "An old silent pond")
+		assert.StringContains(t, string(rest), "</html>")
+	})
+
+	t.Run("Quick error", func(t *testing.T) {
+		ts := streamTestServer(t, app, func(data *homePage) error {
+			return errors.New("the database is on fire")
+		})
+		defer ts.Close()
+
+		rs, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, http.StatusInternalServerError)
+	})
+
+	t.Run("Slow error", func(t *testing.T) {
+		ts := streamTestServer(t, app, func(data *homePage) error {
+			time.Sleep(2 * app.streamAfter)
+			return errors.New("the database is on fire")
+		})
+		defer ts.Close()
+
+		rs, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		body, _ := io.ReadAll(rs.Body)
+
+		// It's too late for an error page, so the page is cut short.
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+		assert.StringContains(t, string(body), "</nav>")
+		assert.Equal(t, strings.Contains(string(body), "</html>"), false)
+	})
+}
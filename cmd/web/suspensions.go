@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// suspensionMessage explains to a suspended or banned user why they can't
+// sign in.
+func suspensionMessage(user *models.User) string {
+	msg := "Your account has been banned."
+	if !user.Banned() {
+		msg = fmt.Sprintf("Your account has been suspended until %s UTC.", humanDate(user.SuspendedUntil))
+	}
+	if user.BanReason != "" {
+		msg += " Reason: " + user.BanReason
+	}
+	return msg
+}
+
+// renderSuspended signs a suspended user out and tells them why. It's used
+// by authenticate, so the suspension takes effect on their next request
+// however they signed in.
+func (app *application) renderSuspended(w http.ResponseWriter, r *http.Request, user *models.User) {
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.clearImpersonation(r)
+	app.recordAuthEvent(r, authevents.Event{Kind: authevents.LockedOut, UserID: user.ID, Reason: "suspended"})
+
+	data := app.newTemplateData(r)
+	data.SuspendedUser = user
+	app.render(w, http.StatusForbidden, "suspended.tmpl.html", data)
+}
+
+// unsuspendExpired lifts the suspensions which have ended. It's run by a
+// background task.
+func (app *application) unsuspendExpired() error {
+	ids, err := app.users.UnsuspendExpired()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		app.infoLog.Printf("audit: suspension of user %d ended", id)
+	}
+	return nil
+}
+
+type adminUserLookupForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// adminUsers finds a user by email address, to manage their suspension.
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	form := adminUserLookupForm{Email: strings.TrimSpace(r.URL.Query().Get("email"))}
+
+	data := app.newTemplateData(r)
+
+	if form.Email == "" {
+		data.Form = form
+		app.render(w, http.StatusOK, "admin_users.tmpl.html", data)
+		return
+	}
+
+	user, err := app.users.GetByEmail(form.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddFieldError("email", "Nobody has this email address")
+			data.Form = form
+			app.render(w, http.StatusNotFound, "admin_users.tmpl.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, urlFor("admin.user", user.ID), http.StatusSeeOther)
+}
+
+type adminSuspensionForm struct {
+	Days                int    `form:"days"`
+	Reason              string `form:"reason"`
+	validator.Validator `form:"-"`
+}
+
+// adminUser returns the user named in the URL, or sends a 404 and returns
+// nil if there's no such user.
+func (app *application) adminUser(w http.ResponseWriter, r *http.Request) *models.User {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return user
+}
+
+func (app *application) adminUserView(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+	app.renderAdminUser(w, r, http.StatusOK, user, adminSuspensionForm{Days: 7})
+}
+
+func (app *application) renderAdminUser(w http.ResponseWriter, r *http.Request, status int, user *models.User, form adminSuspensionForm) {
+	data := app.newTemplateData(r)
+	data.AdminUser = user
+	data.Form = form
+	app.render(w, status, "admin_user.tmpl.html", data)
+}
+
+func (app *application) adminUserSuspendPost(w http.ResponseWriter, r *http.Request) {
+	app.suspendUser(w, r, false)
+}
+
+func (app *application) adminUserBanPost(w http.ResponseWriter, r *http.Request) {
+	app.suspendUser(w, r, true)
+}
+
+// suspendUser suspends the user named in the URL for the number of days in
+// the form or, if ban is set, bans them.
+func (app *application) suspendUser(w http.ResponseWriter, r *http.Request, ban bool) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	var form adminSuspensionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form.Reason = strings.TrimSpace(form.Reason)
+
+	adminID := reqctx.UserID(r.Context())
+
+	if !ban {
+		form.CheckField(validator.PermittedValue(form.Days, 1, 7, 30), "days", "This field must equal 1, 7 or 30")
+	}
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 255), "reason", "This field cannot be more than 255 characters long")
+	if user.ID == adminID {
+		form.AddNonFieldError("You can't suspend yourself.")
+	}
+
+	if !form.Valid() {
+		app.renderAdminUser(w, r, http.StatusUnprocessableEntity, user, form)
+		return
+	}
+
+	until := models.BannedUntil
+	if !ban {
+		until = time.Now().AddDate(0, 0, form.Days)
+	}
+
+	err = app.users.Suspend(user.ID, until, form.Reason)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if ban {
+		app.infoLog.Printf("audit: admin %d banned user %d: %s", adminID, user.ID, form.Reason)
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been banned.", user.Name))
+	} else {
+		app.infoLog.Printf("audit: admin %d suspended user %d until %s: %s", adminID, user.ID, until.UTC().Format(time.RFC3339), form.Reason)
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s has been suspended until %s UTC.", user.Name, humanDate(until)))
+	}
+	http.Redirect(w, r, urlFor("admin.user", user.ID), http.StatusSeeOther)
+}
+
+func (app *application) adminUserUnsuspendPost(w http.ResponseWriter, r *http.Request) {
+	user := app.adminUser(w, r)
+	if user == nil {
+		return
+	}
+
+	err := app.users.Unsuspend(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.infoLog.Printf("audit: admin %d lifted the suspension of user %d", reqctx.UserID(r.Context()), user.ID)
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s can sign in again.", user.Name))
+	http.Redirect(w, r, urlFor("admin.user", user.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"log"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAdminUserSuspendPost(t *testing.T) {
+	var audit bytes.Buffer
+	app := newTestApplication(t)
+	app.infoLog = log.New(&audit, "", 0)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+
+	code, headers, _ := ts.get(t, "/admin/users?email=alice@example.com")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/admin/users/1")
+
+	code, _, body := ts.get(t, "/admin/users?email=nobody@example.com")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.StringContains(t, body, "Nobody has this email address")
+
+	csrfToken := ts.csrfToken(t, "/admin/users/1")
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		days      string
+		reason    string
+		wantCode  int
+		wantError string
+	}{
+		{"No reason", "/admin/users/suspend/1", "7", "", http.StatusUnprocessableEntity, "This field cannot be blank"},
+		{"Bad length", "/admin/users/suspend/1", "3", "Spam", http.StatusUnprocessableEntity, "This field must equal 1, 7 or 30"},
+		{"Yourself", "/admin/users/ban/2", "", "Oops", http.StatusUnprocessableEntity, "You can&#39;t suspend yourself."},
+		{"Unknown user", "/admin/users/suspend/99", "7", "Spam", http.StatusNotFound, ""},
+		{"Suspend", "/admin/users/suspend/1", "7", "Spam", http.StatusSeeOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("days", tt.days)
+			form.Add("reason", tt.reason)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+
+	user, err := app.users.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Suspended(time.Now().AddDate(0, 0, 6)), true)
+	assert.Equal(t, user.Suspended(time.Now().AddDate(0, 0, 8)), false)
+	assert.Equal(t, user.BanReason, "Spam")
+	assert.StringContains(t, audit.String(), "audit: admin 2 suspended user 1 until ")
+
+	_, _, body = ts.get(t, "/admin/users/1")
+	assert.StringContains(t, body, "Suspended until")
+	assert.StringContains(t, body, "Lift suspension")
+
+	form := url.Values{}
+	form.Add("reason", "Abuse")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/admin/users/ban/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	user, err = app.users.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Banned(), true)
+	assert.StringContains(t, audit.String(), "audit: admin 2 banned user 1: Abuse")
+
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/admin/users/unsuspend/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	user, err = app.users.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Suspended(time.Now()), false)
+	assert.StringContains(t, audit.String(), "audit: admin 2 lifted the suspension of user 1")
+}
+
+func TestSuspendedUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	token, err := app.apiTokens.Insert(1, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := http.Header{"Authorization": {"Bearer " + token}}
+
+	err = app.users.Suspend(1, time.Now().Add(time.Hour), "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice is signed out and told why on her next request.
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "Your Account Has Been Suspended")
+	assert.StringContains(t, body, "Spam")
+
+	code, headers, _ := ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// Her API tokens stop working too.
+	code, _, body = ts.do(t, http.MethodGet, "/api/v1/snippets", auth, "")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "Your account has been suspended until")
+
+	// Bans are explained differently.
+	err = app.users.Suspend(1, models.BannedUntil, "Abuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.login(t, "alice@example.com", "pa$$word")
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Your Account Has Been Banned")
+}
+
+func TestUnsuspendExpired(t *testing.T) {
+	var audit bytes.Buffer
+	app := newTestApplication(t)
+	app.infoLog = log.New(&audit, "", 0)
+
+	err := app.users.Suspend(1, time.Now().Add(-time.Minute), "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = app.unsuspendExpired(); err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, audit.String(), "audit: suspension of user 1 ended")
+
+	user, err := app.users.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.SuspendedUntil.IsZero(), true)
+}
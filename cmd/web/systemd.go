@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/proxyproto"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"github.com/ngohoang211020/snippetbox/internal/systemd"
+	"net"
+)
+
+// activatedListeners returns the sockets systemd bound for the server, if it
+// was socket activated, with the listener settings to serve each one with.
+// They're served in place of the -addr addresses: the nth socket in the
+// socket unit takes the options of the nth -addr, or of the last one if there
+// are more sockets than addresses, so TLS can still be set for each. It
+// returns nil if the server wasn't socket activated.
+func activatedListeners(listeners []listenerConfig, proxyProtocol bool) ([]net.Listener, []listenerConfig, error) {
+	lns, err := systemd.Listeners()
+	if err != nil || lns == nil {
+		return nil, nil, err
+	}
+
+	configs := make([]listenerConfig, len(lns))
+	for i, ln := range lns {
+		configs[i] = listeners[min(i, len(listeners)-1)]
+		configs[i].addr = ln.Addr().String()
+
+		if proxyProtocol && ln.Addr().Network() == "tcp" {
+			lns[i] = &proxyproto.Listener{Listener: ln}
+		}
+	}
+	return lns, configs, nil
+}
+
+// healthStatus sums up how the application is doing in a line, for
+// systemctl status: it's degraded while the database's circuit breaker isn't
+// closed.
+func healthStatus(state query.BreakerState) string {
+	if state != query.BreakerClosed {
+		return fmt.Sprintf("Serving, degraded: the database circuit breaker is %s", state)
+	}
+	return "Serving"
+}
+
+// notifySystemd tells systemd, if it started the server, that it's ready to
+// accept connections, and starts pinging its watchdog if the unit has one.
+// The pings carry the health status, so a database outage shows up in
+// systemctl status without restarting the server, which wouldn't help.
+func (app *application) notifySystemd() {
+	sent, err := systemd.Notify(systemd.Ready, systemd.Status(healthStatus(app.dbBreaker.Stats().State)))
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	if !sent {
+		return
+	}
+
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		app.errorLog.Print(err)
+		return
+	}
+	if interval > 0 {
+		app.runPeriodically("ping the systemd watchdog", interval/2, func() error {
+			_, err := systemd.Notify(systemd.Watchdog, systemd.Status(healthStatus(app.dbBreaker.Stats().State)))
+			return err
+		})
+	}
+}
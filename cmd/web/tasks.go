@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+)
+
+// publishScheduledSnippets publishes the scheduled snippets which have become
+// due, and lets their owners and the owners' followers know. The snippets are
+// already visible to everyone as soon as their publish time passes; this only
+// takes care of telling people about it.
+func (app *application) publishScheduledSnippets() error {
+	snippets, err := app.snippets.PublishDue()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		app.publishActivity(s)
+
+		if s.UserID == 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("Your scheduled snippet %q has been published.", s.Title)
+		link := urlFor("snippet.view", s.PublicID)
+
+		err = app.notifications.Insert(s.UserID, models.NotificationPublished, message, link)
+		if err != nil {
+			return err
+		}
+
+		// Followers outside the organization couldn't see an org only
+		// snippet, so they aren't told about it.
+		if s.OrgOnly {
+			continue
+		}
+
+		err = app.notifyFollowers(s, link)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(snippets) > 0 {
+		app.infoLog.Printf("published %d scheduled snippets", len(snippets))
+	}
+
+	return nil
+}
+
+func (app *application) notifyFollowers(s *models.Snippet, link string) error {
+	followers, err := app.follows.Followers(s.UserID)
+	if err != nil || len(followers) == 0 {
+		return err
+	}
+
+	owner, err := app.users.Get(s.UserID)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("%s published a new snippet, %q.", owner.Name, s.Title)
+
+	for _, id := range followers {
+		err = app.notifications.Insert(id, models.NotificationPublished, message, link)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
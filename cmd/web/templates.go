@@ -1,34 +1,131 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/abuse"
+	"github.com/ngohoang211020/snippetbox/internal/changelog"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
 	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/query"
 	"github.com/ngohoang211020/snippetbox/ui"
+	"golang.org/x/sync/errgroup"
 	"html/template"
 	"io/fs"
+	"log"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
-// Define a templateData type to act as the holding structure for
-// any dynamic data that we want to pass to our HTML templates.
-// At the moment it only contains one field, but we'll add more
-// to it as the build progresses.
+// templateData is what the pages without a view model of their own are
+// rendered with, so it has a field for everything any of them shows. New
+// pages should get a view model embedding templateBase instead, as the ones
+// in pages.go do, so that a template can only use what its handler fills in.
 
 // If the type that you’re yielding between {{ }} tags has methods defined against it,
 // you can call these methods (so long as they are exported and they return only a single value — or a single value and an error).
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string // Add a Flash field to the templateData struct.
-	IsAuthenticated bool
-	CSRFToken       string // Add a CSRFToken field.
-	YourAccount     *models.User
+	templateBase
+
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	IsOwner             bool
+	Form                any
+	YourAccount         *models.User
+	FeatureFlags        []features.Flag
+	Invitations         []*models.Invitation
+	SignupMode          string
+	Notifications       []*models.Notification
+	CSPReports          []*models.CSPReport
+	Profile             *models.User
+	IsOwnProfile        bool
+	IsFollowing         bool
+	Pagination          pagination
+	Incidents           []*models.Incident
+	SnippetStats        *models.SnippetStats
+	APIRateLimits       []*models.APIRateLimit
+	DefaultAPIRateLimit int
+	DefaultAPIRateBurst int
+	Passkeys            []*models.Passkey
+	APITokens           []*models.APIToken
+	NewAPIToken         string
+	Draft               *models.Draft
+	SnippetTemplates    []*models.SnippetTemplate
+	SnippetTemplate     *models.SnippetTemplate
+	Collections         []*models.Collection
+	ExpiryPolicy        models.ExpiryPolicy
+	Collection          *models.Collection
+	CollectionChoices   []*models.Collection
+	ClientIP            string
+	LanguageCounts      []*models.LanguageCount
+	Language            languages.Language
+	Sort                string
+	SortOptions         []sortOption
+	SessionStats        *models.SessionStats
+	SessionGC           sessionGCStatus
+	QueryStats          *query.Stats
+	Burned              bool
+	Preview             bool
+	Webhooks            []*models.Webhook
+	Webhook             *models.Webhook
+	WebhookDeliveries   []*models.WebhookDelivery
+	WebhookRoutes       string
+	WebhookEvents       []string
+	Changelog           []changelog.Entry
+	TabWidth            int
+	RedirectPath        string
+	EmailNames          []string
+	EmailDeadLetters    []*models.QueuedEmail
+	EmailPreviews       bool
+	AdminUser           *models.User
+	ImpersonationForm   adminImpersonationForm
+	SuspendedUser       *models.User
+	Org                 *models.Org
+	OrgRole             string
+	OrgMembers          []*models.OrgMembership
+	OrgInvitations      []*models.OrgInvitation
+	OrgInvitation       *models.OrgInvitation
+	SoftRateLimit       bool
+	Offenders           []abuse.Offender
+	Diff                *snippetDiff
+}
+
+// templateCache holds the template set for each page, which is the page
+// parsed together with the layouts and partials. The sets are compiled at
+// startup, in parallel, or with -lazy-templates the first time each page is
+// rendered, which gets the server listening sooner at the cost of a slower
+// first request to each page and of a broken template only showing up when
+// its page is rendered. It is safe for concurrent use.
+type templateCache struct {
+	// infoLog, if it isn't nil, is where how long each page took to
+	// compile is logged.
+	infoLog *log.Logger
+
+	mu   sync.RWMutex
+	sets map[string]*template.Template
+	// files are the paths of the pages which haven't been compiled yet.
+	files map[string]string
+}
+
+// newTemplateCache compiles the template set for every page.
+func newTemplateCache() (*templateCache, error) {
+	return loadTemplateCache(nil, false)
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
-	cache := map[string]*template.Template{}
+// loadTemplateCache finds the pages, and compiles their template sets
+// unless lazy is set. If infoLog isn't nil, how long each page took to
+// compile is logged there, along with how long they all took.
+func loadTemplateCache(infoLog *log.Logger, lazy bool) (*templateCache, error) {
+	cache := &templateCache{
+		infoLog: infoLog,
+		sets:    map[string]*template.Template{},
+		files:   map[string]string{},
+	}
 
 	// Use fs.Glob() to get a slice of all filepaths in the ui.Files embedded
 	// filesystem which match the pattern 'html/pages/*.tmpl'. This essentially
@@ -38,28 +135,244 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	if err != nil {
 		return nil, err
 	}
-
 	for _, page := range pages {
-		name := filepath.Base(page)
-
-		// Create a slice containing the filepath patterns for the templates we
-		// want to parse.
-		patterns := []string{
-			"html/base.tmpl.html",
-			"html/partials/*.tmpl.html",
-			page,
+		cache.files[filepath.Base(page)] = page
+	}
+	if lazy {
+		return cache, nil
+	}
+
+	// Each page is parsed on its own, so they're compiled in parallel, as
+	// many at a time as there are CPUs.
+	start := time.Now()
+	sets := make([]*template.Template, len(pages))
+
+	var g errgroup.Group
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for i, page := range pages {
+		i, page := i, page
+		g.Go(func() error {
+			ts, err := cache.compile(page)
+			sets[i] = ts
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, page := range pages {
+		cache.sets[filepath.Base(page)] = sets[i]
+	}
+	clear(cache.files)
+
+	if infoLog != nil {
+		infoLog.Printf("compiled %d templates in %s", len(pages), time.Since(start).Round(time.Millisecond))
+	}
+
+	return cache, nil
+}
+
+// compile parses the template set for the page in the given file.
+func (c *templateCache) compile(file string) (*template.Template, error) {
+	start := time.Now()
+	name := filepath.Base(file)
+
+	// Create a slice containing the filepath patterns for the templates we
+	// want to parse.
+	patterns := []string{
+		"html/base.tmpl.html",
+		"html/print.tmpl.html",
+		"html/partials/*.tmpl.html",
+		file,
+	}
+
+	// Parse the base template file into a template set.
+	ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.infoLog != nil {
+		c.infoLog.Printf("compiled template %s in %s", name, time.Since(start).Round(time.Microsecond))
+	}
+	return ts, nil
+}
+
+// lookup returns the template set for a page, compiling it if it hasn't
+// been yet.
+func (c *templateCache) lookup(page string) (*template.Template, error) {
+	c.mu.RLock()
+	ts, compiled := c.sets[page]
+	c.mu.RUnlock()
+	if compiled {
+		if ts == nil {
+			return nil, fmt.Errorf("the template %s does not exist", page)
 		}
+		return ts, nil
+	}
+
+	// Compiling under the lock means each page is only compiled once, by
+	// whichever request gets there first.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ts, compiled := c.sets[page]; compiled && ts != nil {
+		return ts, nil
+	}
+	file, ok := c.files[page]
+	if !ok {
+		return nil, fmt.Errorf("the template %s does not exist", page)
+	}
+
+	ts, err := c.compile(file)
+	if err != nil {
+		return nil, err
+	}
+	c.sets[page] = ts
+	delete(c.files, page)
+	return ts, nil
+}
+
+// set replaces the template set for a page.
+func (c *templateCache) set(page string, ts *template.Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets[page] = ts
+	delete(c.files, page)
+}
+
+// remove takes a page out of the cache.
+func (c *templateCache) remove(page string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sets, page)
+	delete(c.files, page)
+}
+
+// pages returns the names of the pages in the cache, compiled or not, in
+// alphabetical order.
+func (c *templateCache) pages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-		// Parse the base template file into a template set.
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+	pages := make([]string, 0, len(c.sets)+len(c.files))
+	for page := range c.sets {
+		pages = append(pages, page)
+	}
+	for page := range c.files {
+		pages = append(pages, page)
+	}
+	slices.Sort(pages)
+	return pages
+}
+
+// errorPage is the page sent with 500 Internal Server Error responses. It
+// stands alone rather than using the base layout, and is parsed once at
+// startup rather than kept in the template cache, so that a broken cache
+// can't break it too.
+var errorPage = template.Must(template.ParseFS(ui.Files, "html/error.tmpl.html"))
+
+// errorPageData is what errorPage is executed with. Message replaces the
+// usual apology, Reference identifies the incident for a panic, and Detail is
+// only shown in debug mode.
+type errorPageData struct {
+	StatusText string
+	Message    string
+	Reference  string
+	Detail     string
+}
+
+// templateProblems returns what's wrong with the page's template set in the
+// cache, if anything, so that pages which would fail to render can be found
+// in development before anyone visits them.
+func templateProblems(cache *templateCache, page string) []string {
+	if !slices.Contains(cache.pages(), page) {
+		return []string{"not in the template cache"}
+	}
+	ts, err := cache.lookup(page)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	for _, name := range []string{"base", "title", "main"} {
+		if ts.Lookup(name) == nil {
+			problems = append(problems, fmt.Sprintf("doesn't define %q", name))
+		}
+	}
+	return problems
+}
+
+// staticPages are the pages with a "static" block, whose content is the same
+// for every request; the rest of the page, like the layout with the
+// navigation and flash message, isn't. With -prerender-static their "static"
+// block is rendered once, at startup.
+var staticPages = []string{"about.tmpl.html"}
+
+// prerenderDelim is part of the template delimiters which prerendered
+// content is parsed with.
+const prerenderDelim = "\x00"
+
+// prerenderStaticPages replaces each of the staticPages in the cache with a
+// copy whose "static" block is its content, already rendered.
+func prerenderStaticPages(cache *templateCache) error {
+	for _, page := range staticPages {
+		ts, err := cache.lookup(page)
+		if err != nil {
+			return fmt.Errorf("static page %s: %w", page, err)
+		}
+
+		// A template set can't be cloned once it has been executed, so
+		// clone it first.
+		prerendered, err := ts.Clone()
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		err = ts.ExecuteTemplate(&buf, "static", &templateData{})
 		if err != nil {
-			return nil, err
+			return err
+		}
+		// The rendered content becomes the literal text of the new "static"
+		// block, which costs nothing to execute. It's parsed with delimiters
+		// which can't appear in a page, so that any "{{" in the content stays
+		// as it is.
+		if bytes.Contains(buf.Bytes(), []byte(prerenderDelim)) {
+			return fmt.Errorf("static page %s contains a NUL byte", page)
 		}
-		// Add the template set to the map as normal...
-		cache[name] = ts
+		_, err = prerendered.Delims(prerenderDelim+"{", "}"+prerenderDelim).
+			Parse(prerenderDelim + `{define "static"}` + prerenderDelim + buf.String() + prerenderDelim + "{end}" + prerenderDelim)
+		if err != nil {
+			return err
+		}
+		cache.set(page, prerendered)
 	}
 
-	return cache, nil
+	return nil
+}
+
+// maxPooledBuffer is the largest buffer put back in bufferPool. The odd
+// huge page would otherwise pin its memory for as long as the buffer stays
+// in the pool.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers which pages are rendered into.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -71,9 +384,56 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// numberedLine is a single line of snippet content and its line number.
+type numberedLine struct {
+	Number int
+	Text   string
+}
+
+// lines splits content into numbered lines, for displaying with line
+// numbers. Windows line endings are treated the same as Unix ones, and a
+// single trailing newline doesn't produce an extra empty line.
+func lines(content string) []numberedLine {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+
+	var result []numberedLine
+	for i, text := range strings.Split(content, "\n") {
+		result = append(result, numberedLine{Number: i + 1, Text: text})
+	}
+	return result
+}
+
+// fileField returns the form field name for a field of the additional file
+// at index i in the snippet create form, like "files[0].content". The same
+// name is used as the key for the field's validation errors.
+func fileField(i int, name string) string {
+	return fmt.Sprintf("files[%d].%s", i, name)
+}
+
+// languageChoice is the data for the "languageSelect" partial: the name of
+// the select element and the language which should be selected.
+type languageChoice struct {
+	Name     string
+	Selected string
+}
+
+func newLanguageChoice(name, selected string) languageChoice {
+	return languageChoice{Name: name, Selected: selected}
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":      humanDate,
+	"lines":          lines,
+	"languages":      func() []languages.Language { return languages.All },
+	"languageLabel":  languages.Label,
+	"fileField":      fileField,
+	"languageChoice": newLanguageChoice,
+	"expiryChoice":   newExpiryChoice,
+	"expiryLabel":    expiryLabel,
+	"contains":       slices.Contains[[]string],
+	"urlFor":         urlFor,
 }
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -44,3 +53,150 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestLines(t *testing.T) {
+	got := lines("one\r\ntwo\n\nfour\n")
+
+	assert.Equal(t, len(got), 4)
+	assert.Equal(t, got[0], numberedLine{Number: 1, Text: "one"})
+	assert.Equal(t, got[1], numberedLine{Number: 2, Text: "two"})
+	assert.Equal(t, got[2], numberedLine{Number: 3, Text: ""})
+	assert.Equal(t, got[3], numberedLine{Number: 4, Text: "four"})
+}
+
+func TestLoadTemplateCache(t *testing.T) {
+	var logged bytes.Buffer
+	infoLog := log.New(&logged, "", 0)
+
+	cache, err := loadTemplateCache(infoLog, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := cache.pages()
+	assert.StringContains(t, logged.String(), "compiled template home.tmpl.html in ")
+	assert.StringContains(t, logged.String(), fmt.Sprintf("compiled %d templates in ", len(pages)))
+
+	// Lazily, nothing is compiled until it's looked up, and then only once
+	// however many requests want it at the same time.
+	logged.Reset()
+	lazy, err := loadTemplateCache(infoLog, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Join(lazy.pages(), ","), strings.Join(pages, ","))
+	assert.Equal(t, logged.String(), "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := lazy.lookup("home.tmpl.html"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, strings.Count(logged.String(), "compiled template"), 1)
+	assert.StringContains(t, logged.String(), "compiled template home.tmpl.html in ")
+
+	_, err = lazy.lookup("nope.tmpl.html")
+	assert.Equal(t, err.Error(), "the template nope.tmpl.html does not exist")
+
+	// A lazy cache renders the same pages.
+	page := &aboutPage{templateBase: templateBase{CurrentYear: 2024}}
+	for _, c := range []*templateCache{cache, lazy} {
+		rr := httptest.NewRecorder()
+		app := &application{templateCache: c, errorLog: log.New(io.Discard, "", 0)}
+		render(app, rr, http.StatusOK, "about.tmpl.html", page)
+		assert.Equal(t, rr.Code, http.StatusOK)
+	}
+}
+
+func TestPrerenderStaticPages(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{templateCache: cache, errorLog: log.New(io.Discard, "", 0)}
+
+	page := &aboutPage{
+		templateBase: templateBase{CurrentYear: 2024, Flash: "Hello!"},
+		Stats:        &models.InstanceStats{PublicSnippets: 42},
+	}
+
+	rr := httptest.NewRecorder()
+	render(app, rr, http.StatusOK, "about.tmpl.html", page)
+	want := rr.Body.String()
+
+	// The cached page has been executed now, so prerender a fresh copy.
+	cache, err = newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = prerenderStaticPages(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.templateCache = cache
+
+	// The rest of the page is still rendered for each request, around the
+	// same content.
+	rr = httptest.NewRecorder()
+	render(app, rr, http.StatusOK, "about.tmpl.html", page)
+	assert.Equal(t, rr.Body.String(), want)
+	assert.StringContains(t, rr.Body.String(), "Hello!")
+	assert.StringContains(t, rr.Body.String(), "<td>42</td>")
+}
+
+// benchmarkRender renders a page over and over, as the handlers do.
+func benchmarkRender[T pageData](b *testing.B, page string, data T, prerender bool) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if prerender {
+		if err = prerenderStaticPages(cache); err != nil {
+			b.Fatal(err)
+		}
+	}
+	app := &application{templateCache: cache, errorLog: log.New(io.Discard, "", 0)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		render(app, rr, http.StatusOK, page, data)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("got status %d", rr.Code)
+		}
+	}
+}
+
+func BenchmarkRenderHome(b *testing.B) {
+	snippets := make([]*models.Snippet, 10)
+	for i := range snippets {
+		snippets[i] = &models.Snippet{ID: i + 1, Title: "An old silent pond", Created: time.Now(), PublishAt: time.Now()}
+	}
+	benchmarkRender(b, "home.tmpl.html", &homePage{templateBase: templateBase{IsAuthenticated: true}, Snippets: snippets}, false)
+}
+
+func BenchmarkRenderView(b *testing.B) {
+	snippet := &models.Snippet{
+		ID:       1,
+		Title:    "An old silent pond",
+		Content:  strings.Repeat("An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n", 50),
+		Language: "plaintext",
+		Created:  time.Now(),
+		Expires:  time.Now().Add(time.Hour),
+	}
+	benchmarkRender(b, "view.tmpl.html", &snippetViewPage{Snippet: snippet}, false)
+}
+
+func BenchmarkRenderAbout(b *testing.B) {
+	benchmarkRender(b, "about.tmpl.html", &aboutPage{}, false)
+}
+
+func BenchmarkRenderAboutPrerendered(b *testing.B) {
+	benchmarkRender(b, "about.tmpl.html", &aboutPage{}, true)
+}
@@ -2,19 +2,24 @@ package main
 
 import (
 	"bytes"
-	"github.com/alexedwards/scs/v2"
-	"github.com/go-playground/form/v4"
-	"github.com/ngohoang211020/snippetbox/internal/models/mocks"
+	"context"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/authevents"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
 	"html"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
-	"time"
 )
 
 // newTestcustomServer helper which initalizes and returns a new instance
@@ -43,35 +48,51 @@ func newTestServer1(t *testing.T, h http.Handler) *testServer {
 	return &testServer{ts}
 }
 
+// testOption customizes the application returned by newTestApplication, for
+// example to swap in a different mock for a single test.
+type testOption func(*application)
+
+// withSnippets replaces the default snippet model mock.
+func withSnippets(m models.SnippetModelInterface) testOption {
+	return func(app *application) {
+		app.snippets = m
+	}
+}
+
+// withUsers replaces the default user model mock.
+func withUsers(m models.UserModelInterface) testOption {
+	return func(app *application) {
+		app.users = m
+	}
+}
+
 // Create a newTestApplication helper which returns an instance of our
-// application struct containing mocked dependencies.
-func newTestApplication(t *testing.T) *application {
-	// Create an instance of the template cache.
-	templateCache, err := newTemplateCache()
+// application struct containing mocked dependencies. Any options are applied
+// after the defaults have been set up.
+func newTestApplication(t testing.TB, opts ...testOption) *application {
+	app, err := newMockApplication(&logs{
+		errorLog:   log.New(io.Discard, "", 0),
+		infoLog:    log.New(io.Discard, "", 0),
+		accessLog:  logging.NewAccessLog(io.Discard, logging.Human),
+		authEvents: authevents.New(io.Discard, logging.Human),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// And a form decoder.
-	formDecoder := form.NewDecoder()
+	// Keep the emails sent, and let the tests take the database down.
+	app.mailer = &testMailer{}
+	app.dbHealth.db = &testPinger{}
 
-	// And a session manager instance. Note that we use the same settings as
-	// production, except that we *don't* set a Store for the session manager.
-	// If no store is set, the SCS package will default to using a transient
-	// in-memory store, which is ideal for testing purposes.
-	sessionManager := scs.New()
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
+	// Links in emails are only sent with a base URL.
+	app.baseURL = "https://snippets.example"
+	app.siteURL = app.baseURL
 
-	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{}, // Use the mock.
-		users:          &mocks.UserModel{},    // Use the mock.
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+	for _, opt := range opts {
+		opt(app)
 	}
+
+	return app
 }
 
 // Define a custom testServer type which embeds a httptest.Server instance.
@@ -98,10 +119,6 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 	return rs.StatusCode, rs.Header, string(body)
 }
 
-// Define a regular expression which captures the CSRF token value from the
-// HTML for our user signup page.
-var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
-
 func extractCSRFToken(t *testing.T, body string) string {
 	// Use the FindStringSubmatch method to extract the token from the HTML body.
 	// Note that this returns an array with the entire matched pattern in the
@@ -136,3 +153,252 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// postFormWithHost is postForm with a spoofed Host header, and a Referer to
+// match so that the CSRF check passes, as anyone sending requests by hand
+// can.
+func (ts *testServer) postFormWithHost(t *testing.T, host, urlPath string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "https://"+host+urlPath)
+	req.Host = host
+
+	// The client only sends the cookies for the Host header's host, so the
+	// session and CSRF cookies are added by hand.
+	for _, c := range ts.Client().Jar.Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// resetClient replaces the test server client's cookie jar with an empty one,
+// which is the equivalent of a brand new visitor with no session.
+func (ts *testServer) resetClient(t *testing.T) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Client().Jar = jar
+}
+
+// login signs in as the given user by fetching a CSRF token from the login
+// page and then submitting the login form. It fails the test unless the login
+// succeeds with a redirect.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s: got status %d; want %d", email, code, http.StatusSeeOther)
+	}
+}
+
+// csrfToken fetches the page at urlPath and returns the CSRF token embedded in
+// its form.
+func (ts *testServer) csrfToken(t *testing.T, urlPath string) string {
+	_, _, body := ts.get(t, urlPath)
+	return extractCSRFToken(t, body)
+}
+
+// followRedirect checks that the response was a redirect and then makes a GET
+// request to its Location, returning the second response.
+func (ts *testServer) followRedirect(t *testing.T, code int, headers http.Header) (int, http.Header, string) {
+	location := headers.Get("Location")
+	if code < 300 || code > 399 || location == "" {
+		t.Fatalf("got status %d with Location %q; want a redirect", code, location)
+	}
+
+	return ts.get(t, location)
+}
+
+// postMultipart sends a multipart/form-data POST request to the test server.
+// The files map is keyed by form field name, and each file is sent with the
+// field name as its filename.
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, form url.Values, files map[string]string) (int, http.Header, string) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	for key, values := range form {
+		for _, value := range values {
+			if err := mw.WriteField(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for field, content := range files {
+		fw, err := mw.CreateFormFile(field, field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(fw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// Define a regular expression which captures the flash message rendered by
+// the base template.
+var flashRX = regexp.MustCompile(`<div class='flash'>(.+)</div>`)
+
+// extractFlash returns the flash message in the body, or an empty string if
+// there isn't one.
+func extractFlash(body string) string {
+	matches := flashRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		return ""
+	}
+
+	return html.UnescapeString(strings.TrimSpace(matches[1]))
+}
+
+// assertFlash checks that the body contains the expected flash message.
+func assertFlash(t *testing.T, body, want string) {
+	t.Helper()
+
+	got := extractFlash(body)
+	if got != want {
+		t.Errorf("got flash: %q; want: %q", got, want)
+	}
+}
+
+// postJSON sends a POST request with the given raw JSON body to the test
+// server.
+func (ts *testServer) postJSON(t *testing.T, urlPath, body string) (int, http.Header, string) {
+	rs, err := ts.Client().Post(ts.URL+urlPath, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(respBody)
+}
+
+// do sends a request with the given method, headers and body to the test
+// server. A non-empty body is sent as JSON.
+func (ts *testServer) do(t *testing.T, method, urlPath string, headers http.Header, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(respBody)
+}
+
+// sendEmails runs the task which sends the queued emails, as the
+// application does every few seconds.
+func sendEmails(t *testing.T, app *application) {
+	t.Helper()
+
+	if err := app.sendQueuedEmails(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// emailLink returns the path and query of the link to urlPath in an email's
+// body, for following it on the test server.
+func emailLink(t *testing.T, body, urlPath string) string {
+	t.Helper()
+
+	rx := regexp.MustCompile(`https?://[^/\s]+(` + regexp.QuoteMeta(urlPath) + `\?\S+)`)
+	matches := rx.FindStringSubmatch(body)
+	if matches == nil {
+		t.Fatalf("no link to %s in %q", urlPath, body)
+	}
+	return matches[1]
+}
+
+// testMailer records the messages which would have been sent.
+type testMailer struct {
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// testPinger stands in for the database in readiness checks. Pings fail
+// while down is set, and are counted.
+type testPinger struct {
+	mu    sync.Mutex
+	down  bool
+	pings int
+}
+
+func (p *testPinger) PingContext(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pings++
+	if p.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (p *testPinger) setDown(down bool) {
+	p.mu.Lock()
+	p.down = down
+	p.mu.Unlock()
+}
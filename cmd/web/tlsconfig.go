@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// tlsSettings are the -tls-* flags, for the listeners which serve HTTPS
+// themselves. Left empty, each has a modern default: TLS 1.2 or later, with
+// 1.3 negotiated whenever the client supports it, forward-secret AEAD cipher
+// suites and the curves with constant-time assembly implementations.
+type tlsSettings struct {
+	minVersion   string
+	cipherSuites string
+	curves       string
+	clientCAFile string
+}
+
+// The values of a listener's client-auth option. Client certificates are
+// only asked for on listeners which set it, which are meant for admins and
+// API clients rather than the public.
+const (
+	// clientAuthOptional verifies a client certificate if one is sent, but
+	// lets clients without one connect too.
+	clientAuthOptional = "optional"
+	// clientAuthRequire turns away clients without a certificate signed by
+	// -tls-client-ca.
+	clientAuthRequire = "require"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// defaultCipherSuites are the TLS 1.2 cipher suites used when
+// -tls-cipher-suites isn't given. TLS 1.3's suites aren't configurable, and
+// are all fine.
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// defaultCurves are the curves used when -tls-curves isn't given: the ones
+// with assembly implementations.
+var defaultCurves = []tls.CurveID{tls.X25519, tls.CurveP256}
+
+// newTLSConfig builds the TLS configuration the listeners which serve HTTPS
+// share, reporting every problem with the settings at once.
+func newTLSConfig(s tlsSettings) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     defaultCipherSuites,
+		CurvePreferences: defaultCurves,
+		// ServeTLS offers HTTP/2 itself, but Serve, which listeners with
+		// client-auth are served by, only sets it up when it's listed here.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	var problems []string
+
+	if s.minVersion != "" {
+		v, ok := tlsVersions[s.minVersion]
+		switch {
+		case s.minVersion == "1.0" || s.minVersion == "1.1":
+			problems = append(problems, fmt.Sprintf("-tls-min-version %s is insecure and no longer supported by browsers; use 1.2 or 1.3", s.minVersion))
+		case !ok:
+			problems = append(problems, fmt.Sprintf("-tls-min-version must be 1.2 or 1.3, not %q", s.minVersion))
+		default:
+			config.MinVersion = v
+		}
+	}
+
+	if s.cipherSuites != "" {
+		suites, err := parseCipherSuites(s.cipherSuites)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		config.CipherSuites = suites
+		if config.MinVersion == tls.VersionTLS13 {
+			problems = append(problems, "-tls-cipher-suites only applies to TLS 1.2, but -tls-min-version is 1.3; leave one of them out")
+		}
+	}
+
+	if s.curves != "" {
+		config.CurvePreferences = nil
+		for _, name := range strings.Split(s.curves, ",") {
+			name = strings.TrimSpace(name)
+			id, ok := tlsCurves[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("-tls-curves: unknown curve %q; use X25519, P256, P384 or P521", name))
+				continue
+			}
+			config.CurvePreferences = append(config.CurvePreferences, id)
+		}
+	}
+
+	if s.clientCAFile != "" {
+		pool, err := loadClientCAs(s.clientCAFile)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		config.ClientCAs = pool
+	}
+
+	if err := joinProblems(problems); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseCipherSuites parses a comma-separated list of cipher suite names, like
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Only secure TLS 1.2 suites can be
+// picked.
+func parseCipherSuites(s string) ([]uint16, error) {
+	known := make(map[string]*tls.CipherSuite)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs
+	}
+	insecure := make(map[string]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.Name] = true
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		cs, ok := known[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("-tls-cipher-suites: %s is insecure", name)
+		case !ok:
+			return nil, fmt.Errorf("-tls-cipher-suites: unknown cipher suite %q", name)
+		case !supportsTLS12(cs):
+			return nil, fmt.Errorf("-tls-cipher-suites: %s is a TLS 1.3 suite, which can't be configured", name)
+		}
+		suites = append(suites, cs.ID)
+	}
+	return suites, nil
+}
+
+func supportsTLS12(cs *tls.CipherSuite) bool {
+	for _, v := range cs.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+// loadClientCAs loads the certificates of the authorities which sign client
+// certificates from a PEM file.
+func loadClientCAs(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("-tls-client-ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("-tls-client-ca: %s has no PEM certificates in it", file)
+	}
+	return pool, nil
+}
+
+// checkClientAuth checks the listeners' client-auth options against each
+// other and -tls-client-ca.
+func checkClientAuth(listeners []listenerConfig, s tlsSettings) error {
+	var problems []string
+	asked := false
+
+	for _, lc := range listeners {
+		switch lc.clientAuth {
+		case "":
+			continue
+		case clientAuthOptional, clientAuthRequire:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: client-auth must be %q or %q, not %q", lc.addr, clientAuthOptional, clientAuthRequire, lc.clientAuth))
+			continue
+		}
+		asked = true
+		if lc.tls != tlsApp {
+			problems = append(problems, fmt.Sprintf("%s: client-auth needs tls=app, as client certificates can only be checked where TLS is terminated", lc.addr))
+		}
+		if s.clientCAFile == "" {
+			problems = append(problems, fmt.Sprintf("%s: client-auth needs -tls-client-ca to check the certificates against", lc.addr))
+		}
+	}
+
+	if s.clientCAFile != "" && !asked {
+		problems = append(problems, "-tls-client-ca is only used by listeners with client-auth set; add it to one with -addr or -admin-addr, like \"127.0.0.1:4001,client-auth=require\"")
+	}
+	return joinProblems(problems)
+}
+
+// serveTLSWithClientAuth serves HTTPS on ln like http.Server.ServeTLS, but
+// asks for client certificates as the listener's client-auth option says.
+// ServeTLS can only use srv's own TLS configuration, which is shared by every
+// listener.
+func serveTLSWithClientAuth(srv *http.Server, lc listenerConfig, ln net.Listener) error {
+	if srv.TLSConfig == nil || srv.TLSConfig.ClientCAs == nil {
+		return errors.New("client-auth needs -tls-client-ca")
+	}
+
+	cert, err := tls.LoadX509KeyPair(lc.certFile, lc.keyFile)
+	if err != nil {
+		return err
+	}
+
+	config := srv.TLSConfig.Clone()
+	config.Certificates = []tls.Certificate{cert}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if lc.clientAuth == clientAuthRequire {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return srv.Serve(tls.NewListener(ln, config))
+}
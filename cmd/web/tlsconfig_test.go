@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := newTLSConfig(tlsSettings{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, config.MinVersion, uint16(tls.VersionTLS12))
+		assert.Equal(t, slices.Equal(config.CipherSuites, defaultCipherSuites), true)
+		assert.Equal(t, slices.Equal(config.CurvePreferences, []tls.CurveID{tls.X25519, tls.CurveP256}), true)
+		assert.Equal(t, config.ClientCAs == nil, true)
+
+		// Every default suite is forward-secret and authenticated.
+		insecure := tls.InsecureCipherSuites()
+		for _, id := range config.CipherSuites {
+			assert.Equal(t, slices.ContainsFunc(insecure, func(cs *tls.CipherSuite) bool { return cs.ID == id }), false)
+		}
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		config, err := newTLSConfig(tlsSettings{
+			minVersion:   "1.2",
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			curves:       "P384,X25519",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, slices.Equal(config.CipherSuites, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}), true)
+		assert.Equal(t, slices.Equal(config.CurvePreferences, []tls.CurveID{tls.CurveP384, tls.X25519}), true)
+
+		config, err = newTLSConfig(tlsSettings{minVersion: "1.3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, config.MinVersion, uint16(tls.VersionTLS13))
+	})
+
+	caFile, _ := writeClientCA(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings tlsSettings
+		wantErr  string
+	}{
+		{"Old version", tlsSettings{minVersion: "1.1"}, "-tls-min-version 1.1 is insecure and no longer supported by browsers; use 1.2 or 1.3"},
+		{"Unknown version", tlsSettings{minVersion: "TLSv1.2"}, `-tls-min-version must be 1.2 or 1.3, not "TLSv1.2"`},
+		{"Insecure suite", tlsSettings{cipherSuites: "TLS_RSA_WITH_RC4_128_SHA"}, "-tls-cipher-suites: TLS_RSA_WITH_RC4_128_SHA is insecure"},
+		{"Unknown suite", tlsSettings{cipherSuites: "TLS_NOPE"}, `-tls-cipher-suites: unknown cipher suite "TLS_NOPE"`},
+		{"TLS 1.3 suite", tlsSettings{cipherSuites: "TLS_AES_128_GCM_SHA256"}, "-tls-cipher-suites: TLS_AES_128_GCM_SHA256 is a TLS 1.3 suite, which can't be configured"},
+		{"Suites with TLS 1.3 only", tlsSettings{minVersion: "1.3", cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, "-tls-cipher-suites only applies to TLS 1.2, but -tls-min-version is 1.3"},
+		{"Unknown curve", tlsSettings{curves: "X25519,P224"}, `-tls-curves: unknown curve "P224"`},
+		{"Missing client CA", tlsSettings{clientCAFile: filepath.Join(t.TempDir(), "missing.pem")}, "-tls-client-ca: open "},
+		{"Client CA isn't PEM", tlsSettings{clientCAFile: notPEM}, "has no PEM certificates in it"},
+		{"Several problems", tlsSettings{minVersion: "1.0", curves: "P224"}, `-tls-min-version 1.0 is insecure and no longer supported by browsers; use 1.2 or 1.3; -tls-curves: unknown curve "P224"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSConfig(tt.settings)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("Client CA", func(t *testing.T) {
+		config, err := newTLSConfig(tlsSettings{clientCAFile: caFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, config.ClientCAs != nil, true)
+		assert.Equal(t, config.ClientAuth, tls.NoClientCert)
+	})
+}
+
+func TestCheckClientAuth(t *testing.T) {
+	withCA := tlsSettings{clientCAFile: "ca.pem"}
+
+	tests := []struct {
+		name      string
+		listeners []listenerConfig
+		settings  tlsSettings
+		wantErr   string
+	}{
+		{
+			name:      "None",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsApp}},
+		},
+		{
+			name:      "Admin listener",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsProxy}, {addr: "127.0.0.1:4001", tls: tlsApp, clientAuth: clientAuthRequire}},
+			settings:  withCA,
+		},
+		{
+			name:      "Unknown mode",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsApp, clientAuth: "yes"}},
+			settings:  withCA,
+			wantErr:   `:4000: client-auth must be "optional" or "require", not "yes"`,
+		},
+		{
+			name:      "Behind a proxy",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsProxy, clientAuth: clientAuthOptional}},
+			settings:  withCA,
+			wantErr:   ":4000: client-auth needs tls=app",
+		},
+		{
+			name:      "No CA",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsApp, clientAuth: clientAuthRequire}},
+			wantErr:   ":4000: client-auth needs -tls-client-ca",
+		},
+		{
+			name:      "Unused CA",
+			listeners: []listenerConfig{{addr: ":4000", tls: tlsApp}},
+			settings:  withCA,
+			wantErr:   "-tls-client-ca is only used by listeners with client-auth set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientAuth(tt.listeners, tt.settings)
+			if tt.wantErr == "" {
+				assert.Equal(t, err, nil)
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestServeListenerClientAuth(t *testing.T) {
+	caFile, clientCert := writeClientCA(t)
+
+	tlsConfig, err := newTLSConfig(tlsSettings{clientCAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		// The handshakes which are meant to fail would be logged.
+		ErrorLog:  log.New(io.Discard, "", 0),
+		TLSConfig: tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %d", r.Proto, len(r.TLS.PeerCertificates))
+		}),
+	}
+	defer srv.Close()
+
+	get := func(t *testing.T, lc listenerConfig, certs ...tls.Certificate) (string, error) {
+		t.Helper()
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, Certificates: certs},
+			ForceAttemptHTTP2: true,
+		}}
+		defer client.CloseIdleConnections()
+
+		rs, err := client.Get("https://" + lc.addr + "/")
+		if err != nil {
+			return "", err
+		}
+		defer rs.Body.Close()
+		body, err := io.ReadAll(rs.Body)
+		return string(body), err
+	}
+
+	// serve serves a listener with the given client-auth mode, returning it
+	// with the address it's listening on.
+	serve := func(t *testing.T, mode string) (listenerConfig, net.Listener) {
+		t.Helper()
+
+		lc := listenerConfig{addr: "127.0.0.1:0", tls: tlsApp, certFile: "../../tls/cert.pem", keyFile: "../../tls/key.pem", clientAuth: mode}
+		ln, err := newListener(lc.addr, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serveListener(srv, lc, ln)
+		lc.addr = ln.Addr().String()
+		return lc, ln
+	}
+
+	t.Run("Required", func(t *testing.T) {
+		lc, ln := serve(t, clientAuthRequire)
+		assert.Equal(t, lc.describe(ln), lc.addr+", serving HTTPS with ../../tls/cert.pem to clients with certificates")
+
+		body, err := get(t, lc, clientCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, body, "HTTP/2.0 1")
+
+		_, err = get(t, lc)
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		lc, _ := serve(t, clientAuthOptional)
+
+		body, err := get(t, lc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, body, "HTTP/2.0 0")
+
+		body, err = get(t, lc, clientCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, body, "HTTP/2.0 1")
+
+		// A certificate from anyone else is turned away, even though
+		// having none isn't.
+		_, err = get(t, lc, forgedClientCert(t))
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Modern defaults", func(t *testing.T) {
+		lc, _ := serve(t, clientAuthOptional)
+
+		conn, err := tls.Dial("tcp", lc.addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		assert.Equal(t, conn.ConnectionState().Version, uint16(tls.VersionTLS13))
+
+		// Clients stuck on TLS 1.1 can't connect at all.
+		_, err = tls.Dial("tcp", lc.addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11})
+		assert.Equal(t, err != nil, true)
+	})
+}
+
+// writeClientCA creates a certificate authority, writing its certificate to
+// a file for -tls-client-ca, and returns the file and a client certificate
+// it signed.
+func writeClientCA(t *testing.T) (string, tls.Certificate) {
+	t.Helper()
+
+	ca, caKey := newClientCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, newClientCert(t, ca, caKey)
+}
+
+// forgedClientCert returns a client certificate signed by an authority with
+// the same name as writeClientCA's but a key of its own. Clients only send
+// certificates from the authorities the server names, so one from anyone
+// else wouldn't be sent at all.
+func forgedClientCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	ca, caKey := newClientCA(t)
+	return newClientCert(t, ca, caKey)
+}
+
+func newClientCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Snippetbox test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+// newClientCert returns a client certificate signed by ca.
+func newClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "admin"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// The values of the -tls flag. With tlsApp the server terminates TLS itself,
+// using the certificate in ./tls. With tlsProxy it serves plain HTTP and
+// relies on a reverse proxy like nginx or Caddy in front of it to terminate
+// TLS.
+const (
+	tlsApp   = "app"
+	tlsProxy = "proxy"
+)
+
+// trustedProxies are the reverse proxies whose X-Forwarded-Proto header is
+// believed. Anyone can send the header, so it's ignored on requests from
+// anywhere else.
+type trustedProxies struct {
+	prefixes []netip.Prefix
+
+	// unix is set when the server listens on a Unix socket. Only processes
+	// allowed to open the socket can connect to it, so they are all trusted.
+	unix bool
+}
+
+// parseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// ranges, like "127.0.0.1,10.0.0.0/8".
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// loadTrustedProxies checks how each listener terminates TLS and the
+// -trusted-proxies flag, returning nil if X-Forwarded-Proto shouldn't be
+// trusted at all because no listener is behind a proxy. Behind a proxy the
+// proxies have to be given, unless every listener behind one is a Unix
+// socket.
+func loadTrustedProxies(listeners []listenerConfig, proxies string) (*trustedProxies, error) {
+	p := &trustedProxies{}
+	behindProxy, needProxies := false, false
+
+	for _, lc := range listeners {
+		switch lc.tls {
+		case tlsApp:
+		case tlsProxy:
+			behindProxy = true
+			if strings.HasPrefix(lc.addr, "unix:") {
+				p.unix = true
+			} else {
+				needProxies = true
+			}
+		default:
+			return nil, fmt.Errorf("-tls must be %q or %q, not %q for %s", tlsApp, tlsProxy, lc.tls, lc.addr)
+		}
+	}
+
+	if !behindProxy {
+		if proxies != "" {
+			return nil, errors.New("-trusted-proxies can only be used with -tls=proxy")
+		}
+		return nil, nil
+	}
+
+	var err error
+	p.prefixes, err = parseTrustedProxies(proxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.prefixes) == 0 && needProxies {
+		return nil, errors.New("-tls=proxy needs -trusted-proxies, so that X-Forwarded-Proto isn't believed from anyone")
+	}
+	return p, nil
+}
+
+// trusts reports whether the request came straight from a trusted proxy.
+func (p *trustedProxies) trusts(r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(remoteIP(r))
+	if err != nil {
+		// Connections on a Unix socket don't have an IP address.
+		return p.unix
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// servedOverHTTPS works out whether the client made the request over HTTPS,
+// for requestContext to store. Everything else should use isHTTPS.
+func (app *application) servedOverHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return app.trustedProxies.trusts(r) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		proxies string
+		addr    string
+		wantNil bool
+		wantErr bool
+	}{
+		{"App", tlsApp, "", ":4000", true, false},
+		{"App with proxies", tlsApp, "127.0.0.1", ":4000", false, true},
+		{"Proxy", tlsProxy, "127.0.0.1, 10.0.0.0/8", ":4000", false, false},
+		{"Proxy without proxies", tlsProxy, "", ":4000", false, true},
+		{"Proxy on a Unix socket", tlsProxy, "", "unix:/run/snippetbox.sock", false, false},
+		{"Bad proxy", tlsProxy, "10.0.0.0/33", ":4000", false, true},
+		{"Bad mode", "both", "", ":4000", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := loadTrustedProxies([]listenerConfig{{addr: tt.addr, tls: tt.mode}}, tt.proxies)
+			assert.Equal(t, err != nil, tt.wantErr)
+			if err == nil {
+				assert.Equal(t, p == nil, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestForwardedProto(t *testing.T) {
+	app := newTestApplication(t)
+	proxies, err := loadTrustedProxies([]listenerConfig{{addr: ":4000", tls: tlsProxy}}, "10.0.0.0/8, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.trustedProxies = proxies
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme := "http"
+		if isHTTPS(r) {
+			scheme = "https"
+		}
+		w.Write([]byte(scheme))
+	})
+	handler := app.requestContext(secureHeaders(next))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		wantScheme string
+		wantHSTS   bool
+	}{
+		{"Trusted proxy, HTTPS", "10.1.2.3:5555", "https", "https", true},
+		{"Trusted IPv6 proxy, HTTPS", "[::1]:5555", "https", "https", true},
+		{"Trusted proxy, HTTP", "10.1.2.3:5555", "http", "http", false},
+		{"Untrusted client", "203.0.113.9:5555", "https", "http", false},
+		{"No header", "10.1.2.3:5555", "", "http", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Body.String(), tt.wantScheme)
+			assert.Equal(t, rr.Header().Get("Strict-Transport-Security") != "", tt.wantHSTS)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/signedurl"
+	"net/http"
+	"time"
+)
+
+// unsubscribeLinkTTL is how long the unsubscribe links in emails work for.
+// Mail providers expect them to keep working long after the email was sent.
+const unsubscribeLinkTTL = 90 * 24 * time.Hour
+
+// emailUnsubscribe shows the page which the unsubscribe link in an email
+// points to, with a button which posts back to the same link. It works
+// without signing in, and the link can be used any number of times.
+func (app *application) emailUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	id, ok := signedURLUser(r)
+	if !ok {
+		app.signedURLFailed(w, r, signedurl.ErrInvalid)
+		return
+	}
+
+	p, err := app.preferences.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	render(app, w, http.StatusOK, "unsubscribe.tmpl.html", &emailUnsubscribePage{
+		templateBase: app.newTemplateBase(r),
+		Subscribed:   p.InvitationEmails,
+		Action:       r.URL.RequestURI(),
+	})
+}
+
+// emailUnsubscribePost stops invitations being emailed to the user which
+// the link was sent to. Mail clients post to it for one-click unsubscribe,
+// without a session or a CSRF token; see chainOneClick.
+func (app *application) emailUnsubscribePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := signedURLUser(r)
+	if !ok {
+		app.signedURLFailed(w, r, signedurl.ErrInvalid)
+		return
+	}
+
+	p, err := app.preferences.Get(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if p.InvitationEmails {
+		p.InvitationEmails = false
+		if err := app.preferences.Set(id, p); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been unsubscribed from invitation emails.")
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
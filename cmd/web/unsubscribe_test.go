@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEmailUnsubscribe(t *testing.T) {
+	mail := &testMailer{}
+	app := newTestApplication(t)
+	app.mailer = mail
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	invite := url.Values{"csrf_token": {ts.csrfToken(t, "/org/acme")}, "email": {"alice@example.com"}, "role": {"member"}}
+
+	code, _, _ := ts.postForm(t, "/org/acme/invite", invite)
+	assert.Equal(t, code, http.StatusSeeOther)
+	sendEmails(t, app)
+
+	// Invitations to people with an account can be unsubscribed from.
+	assert.Equal(t, len(mail.sent), 1)
+	link := emailLink(t, mail.sent[0].Body, "/email/unsubscribe")
+	assert.Equal(t, mail.sent[0].Unsubscribe, app.siteURL+link)
+
+	ts.resetClient(t)
+
+	code, _, body := ts.get(t, link)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='"+strings.ReplaceAll(link, "&", "&amp;")+"' method='POST'>")
+
+	code, _, body = ts.get(t, strings.Replace(link, "uid=1", "uid=2", 1))
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "This link is invalid or has expired.")
+
+	// Mail clients post to the link without a session or CSRF token.
+	code, headers, _ := ts.postForm(t, link, url.Values{"List-Unsubscribe": {"One-Click"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	p, err := app.preferences.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, p.InvitationEmails, false)
+
+	// The link keeps working, and says what it's done.
+	_, _, body = ts.followRedirect(t, code, headers)
+	assertFlash(t, body, "You've been unsubscribed from invitation emails.")
+	assert.StringContains(t, body, "You won't be emailed invitations to organizations.")
+
+	code, _, _ = ts.postForm(t, link, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Whoever invites Alice now is given the link to pass on.
+	mail.sent = nil
+	ts.login(t, "admin@example.com", "pa$$word")
+	invite.Set("csrf_token", ts.csrfToken(t, "/org/acme"))
+
+	code, headers, _ = ts.postForm(t, "/org/acme/invite", invite)
+	assert.Equal(t, code, http.StatusSeeOther)
+	sendEmails(t, app)
+	assert.Equal(t, len(mail.sent), 0)
+
+	_, _, body = ts.followRedirect(t, code, headers)
+	assert.StringContains(t, extractFlash(body), "alice@example.com has asked not to be emailed invitations, so send them this link yourself: "+app.siteURL+"/orgs/invitation/")
+}
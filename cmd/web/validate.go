@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/features"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+)
+
+// validateSnippet runs the same checks as creating a snippet, without
+// creating it, so that the page can point out mistakes while the user is
+// still filling the form in. The response looks like:
+//
+//	{"valid": false, "errors": {"title": "This field cannot be blank"}}
+//
+// Only the fields which were submitted are reported on, so that a page can
+// check the fields the user has got to so far without flagging the rest.
+// "valid" is for the form as a whole. Content which doesn't parse in its
+// language is warned about under "warnings", which don't make the form
+// invalid:
+//
+//	{"valid": true, "errors": {}, "warnings": {"content": ["This JSON is invalid at line 7: ..."]}}
+//
+// The submitted contents are counted under "stats", as they'll be stored,
+// along with the limits they're held to:
+//
+//	{"stats": {"content": {"lines": 12, "words": 40, "chars": 310, "bytes": 312}}, "limits": {"max_bytes": 1048576, "max_lines": 20000}}
+func (app *application) validateSnippet(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	policy, err := app.expiryPolicy.Get()
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	primary, _, _ := form.validate(policy, app.contentLimits)
+
+	// Files are only worth checking as they're written, not once they're
+	// encrypted.
+	warnings := map[string][]string{}
+	stats := map[string]validator.ContentStats{}
+	if !form.ContentEncrypted {
+		check := func(field string, f snippetFileForm) {
+			if !r.PostForm.Has(field) {
+				return
+			}
+			stats[field] = validator.Stats(f.Content)
+			if messages := lintMessages(f.effectiveLanguage(), f.Content); messages != nil {
+				warnings[field] = messages
+			}
+		}
+		check("content", primary)
+		for i, f := range form.Files {
+			check(fmt.Sprintf("files[%d].content", i), f)
+		}
+	}
+
+	app.writeFieldErrors(w, r, form.Validator, envelope{"warnings": warnings, "stats": stats, "limits": app.contentLimits})
+}
+
+// validateSignup is the equivalent of validateSnippet for the signup form.
+func (app *application) validateSignup(w http.ResponseWriter, r *http.Request) {
+	var form userSignupForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.apiBadRequest(w, err)
+		return
+	}
+
+	form.validate(app.features.Enabled(features.SignupInviteOnly))
+	app.writeFieldErrors(w, r, form.Validator, nil)
+}
+
+// writeFieldErrors responds with the errors for the submitted fields, and
+// anything else the form has to say about them, like warnings.
+func (app *application) writeFieldErrors(w http.ResponseWriter, r *http.Request, v validator.Validator, extra envelope) {
+	fieldErrors := map[string]string{}
+	for field, message := range v.FieldErrors {
+		if r.PostForm.Has(field) {
+			fieldErrors[field] = message
+		}
+	}
+
+	env := envelope{"valid": v.Valid(), "errors": fieldErrors}
+	for k, value := range extra {
+		env[k] = value
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.apiServerError(w, err)
+	}
+}
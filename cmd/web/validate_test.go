@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// validationResult is the response of the validate endpoints.
+type validationResult struct {
+	Valid    bool                `json:"valid"`
+	Errors   map[string]string   `json:"errors"`
+	Warnings map[string][]string `json:"warnings"`
+
+	Stats  map[string]validator.ContentStats `json:"stats"`
+	Limits validator.ContentLimits           `json:"limits"`
+}
+
+func postValidate(t *testing.T, ts *testServer, urlPath string, form url.Values) validationResult {
+	t.Helper()
+
+	code, headers, body := ts.postForm(t, urlPath, form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+
+	var result validationResult
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestValidateSignup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.csrfToken(t, "/user/signup")
+
+	// Only the submitted fields are reported on.
+	result := postValidate(t, ts, "/validate/signup", url.Values{
+		"csrf_token": {csrfToken},
+		"name":       {""},
+		"password":   {"short"},
+	})
+	assert.Equal(t, result.Valid, false)
+	assert.Equal(t, len(result.Errors), 2)
+	assert.Equal(t, result.Errors["name"], "This field cannot be blank")
+	assert.Equal(t, result.Errors["password"], "This field cannot be less than 8 characters long")
+
+	result = postValidate(t, ts, "/validate/signup", url.Values{
+		"csrf_token": {csrfToken},
+		"name":       {"Bob"},
+		"email":      {"bob@example.com"},
+		"password":   {"validPa$$word"},
+	})
+	assert.Equal(t, result.Valid, true)
+	assert.Equal(t, len(result.Errors), 0)
+
+	// Nothing is created.
+	_, err := app.users.GetByEmail("bob@example.com")
+	assert.Equal(t, err != nil, true)
+
+	// The CSRF token is still required.
+	code, _, _ := ts.postForm(t, "/validate/signup", url.Values{"name": {"Bob"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestValidateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Like creating a snippet, this needs a signed-in user.
+	form := url.Values{"csrf_token": {ts.csrfToken(t, "/user/login")}}
+	code, _, _ := ts.postForm(t, "/validate/snippet", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	result := postValidate(t, ts, "/validate/snippet", url.Values{
+		"csrf_token":        {csrfToken},
+		"title":             {"A title which is far too long for a snippet, going on and on and on for more than a hundred characters"},
+		"language":          {"cobol"},
+		"files[0].filename": {"../etc/passwd"},
+	})
+	assert.Equal(t, result.Valid, false)
+	assert.Equal(t, len(result.Errors), 3)
+	assert.Equal(t, result.Errors["title"], "This field cannot be more than 100 characters long")
+	assert.Equal(t, result.Errors["language"], "This field must be one of the supported languages")
+	assert.Equal(t, result.Errors["files[0].filename"], "This field must be a plain filename")
+
+	// Content which doesn't parse is warned about, without making the form
+	// invalid.
+	result = postValidate(t, ts, "/validate/snippet", url.Values{
+		"csrf_token":        {csrfToken},
+		"title":             {"Config"},
+		"content":           {"{\n  \"debug\": true,\n}"},
+		"language":          {"json"},
+		"files[0].filename": {"config.yaml"},
+		"files[0].content":  {"debug: true"},
+		"expires":           {"7"},
+	})
+	assert.Equal(t, result.Valid, true)
+	assert.Equal(t, len(result.Warnings), 1)
+	assert.StringContains(t, result.Warnings["content"][0], "This JSON is invalid at line 3: ")
+}
+
+func TestValidateSnippetStats(t *testing.T) {
+	app := newTestApplication(t)
+	app.contentLimits = validator.ContentLimits{MaxBytes: 1024, MaxLines: 3}
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/snippet/create")
+
+	// The content is counted as it would be stored, without the Windows line
+	// endings and trailing spaces.
+	result := postValidate(t, ts, "/validate/snippet", url.Values{
+		"csrf_token":       {csrfToken},
+		"content":          {"one  \r\ntwo\r\nthree four\r\n"},
+		"files[0].content": {"a\nb\nc\nd"},
+	})
+	assert.Equal(t, result.Stats["content"], validator.ContentStats{Lines: 3, Words: 4, Chars: 19, Bytes: 19})
+	assert.Equal(t, result.Stats["files[0].content"].Lines, 4)
+	assert.Equal(t, result.Limits, app.contentLimits)
+
+	// Pastes over the limits are pointed out straight away.
+	assert.Equal(t, result.Valid, false)
+	assert.Equal(t, result.Errors["content"], "")
+	assert.Equal(t, result.Errors["files[0].content"], "This is 4 lines long, over the limit of 3 lines. Try sharing only the part that matters, or splitting it into several files.")
+
+	result = postValidate(t, ts, "/validate/snippet", url.Values{
+		"csrf_token": {csrfToken},
+		"content":    {strings.Repeat("x", 2000)},
+	})
+	assert.Equal(t, result.Errors["content"], "This is 2 KB, over the limit of 1 KB. Try sharing only the part that matters, or splitting it into several files.")
+}
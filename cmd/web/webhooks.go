@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"github.com/ngohoang211020/snippetbox/internal/reqctx"
+	"github.com/ngohoang211020/snippetbox/internal/validator"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxWebhooks is how many webhooks each user, and the admins between
+	// them, can have.
+	maxWebhooks = 10
+
+	// webhookBatch is how many deliveries each run of the delivery task
+	// sends at most.
+	webhookBatch = 50
+
+	// webhookLogSize is how many deliveries a webhook's page lists.
+	webhookLogSize = 50
+)
+
+// emitWebhookEvent queues an event for the webhooks which want it: ownerID's
+// and the admins'. Webhooks are a side effect of whatever the request did,
+// so failing to queue them is only logged.
+func (app *application) emitWebhookEvent(event string, ownerID int, data any) {
+	payload, err := webhooks.NewEvent(event, data)
+	if err == nil {
+		_, err = app.webhooks.Enqueue(event, ownerID, payload)
+	}
+	if err != nil {
+		app.errorLog.Printf("queue %s webhooks: %s", event, err)
+	}
+}
+
+// webhookSnippet is how a snippet appears in webhook payloads. The content
+// is left out: webhooks say what happened, and receivers can fetch the
+// snippet through the API if they need more.
+func (app *application) webhookSnippet(s *models.Snippet) map[string]any {
+	data := map[string]any{
+		"id":                 s.PublicID,
+		"title":              s.Title,
+		"user_id":            s.UserID,
+		"url":                app.absoluteURL(urlFor("snippet.view", s.PublicID)),
+		"burn_after_reading": s.BurnAfterReading,
+		"content_encrypted":  s.ContentEncrypted,
+	}
+	if !s.PublishAt.IsZero() {
+		data["publish_at"] = s.PublishAt.UTC()
+	}
+	return data
+}
+
+// emitSnippetLanguageChanged sends snippet.updated for a change to the
+// language of one of a snippet's files, the only edit there is.
+func (app *application) emitSnippetLanguageChanged(s *models.Snippet, position int, language string) {
+	data := app.webhookSnippet(s)
+	data["changes"] = map[string]any{"file": position, "language": language}
+	app.emitWebhookEvent(webhooks.SnippetUpdated, s.UserID, data)
+}
+
+// deliverWebhooks sends the deliveries which are due, scheduling a retry for
+// those which fail until they've failed too often.
+func (app *application) deliverWebhooks() error {
+	due, err := app.webhooks.Due(webhookBatch)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, d := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		status, sendErr := app.webhookSender.Send(ctx, webhooks.Delivery{
+			ID:      d.ID,
+			Event:   d.Event,
+			URL:     d.URL,
+			Secret:  d.Secret,
+			Payload: []byte(d.Payload),
+			Public:  d.UserID != 0,
+		})
+		cancel()
+
+		if sendErr == nil {
+			err = app.webhooks.Delivered(d.ID, status)
+		} else {
+			failed++
+			var retryAt time.Time
+			if delay, ok := webhooks.RetryDelay(d.Attempts); ok {
+				retryAt = time.Now().Add(delay)
+			}
+			err = app.webhooks.Failed(d.ID, status, sendErr.Error(), retryAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		app.infoLog.Printf("%d of %d webhook deliveries failed", failed, len(due))
+	}
+	return nil
+}
+
+// webhookScope is whose webhooks a page manages: a user's own, which only
+// hear about their snippets, or the admins', which hear about everything.
+type webhookScope struct {
+	userID int
+	routes string
+	events []string
+}
+
+func accountWebhookScope(r *http.Request) webhookScope {
+	return webhookScope{userID: reqctx.UserID(r.Context()), routes: "account", events: webhooks.UserEvents}
+}
+
+func adminWebhookScope() webhookScope {
+	return webhookScope{routes: "admin", events: webhooks.AllEvents}
+}
+
+type webhookForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"events"`
+	validator.Validator `form:"-"`
+}
+
+func (f *webhookForm) validate(events []string) {
+	u, err := url.Parse(f.URL)
+	valid := err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && u.User == nil
+
+	f.CheckField(validator.NotBlank(f.URL), "url", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	f.CheckField(valid, "url", "This field must be an http or https URL")
+
+	f.CheckField(len(f.Events) > 0, "events", "Choose at least one event")
+	for _, e := range f.Events {
+		f.CheckField(validator.PermittedValue(e, events...), "events", "This field must only contain the listed events")
+	}
+}
+
+func (app *application) webhookList(w http.ResponseWriter, r *http.Request, scope webhookScope, form webhookForm, status int) {
+	hooks, err := app.webhooks.ForUser(scope.userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = hooks
+	data.WebhookRoutes = scope.routes
+	data.WebhookEvents = scope.events
+	data.Form = form
+	app.render(w, status, "webhooks.tmpl.html", data)
+}
+
+func (app *application) webhookCreate(w http.ResponseWriter, r *http.Request, scope webhookScope) {
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate(scope.events)
+
+	if form.Valid() {
+		hooks, err := app.webhooks.ForUser(scope.userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.CheckField(len(hooks) < maxWebhooks, "url", fmt.Sprintf("You can't have more than %d webhooks", maxWebhooks))
+	}
+
+	if !form.Valid() {
+		app.webhookList(w, r, scope, form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := app.webhooks.Insert(&models.Webhook{UserID: scope.userID, URL: form.URL, Secret: secret, Events: form.Events})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook added. Use its secret to check the signature of each delivery.")
+	http.Redirect(w, r, urlFor(scope.routes+".webhook", id), http.StatusSeeOther)
+}
+
+// webhook returns the webhook named in the URL, if it's in scope. Otherwise
+// it sends a 404 and returns nil.
+func (app *application) webhook(w http.ResponseWriter, r *http.Request, scope webhookScope) *models.Webhook {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	hook, err := app.webhooks.Get(id, scope.userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+	return hook
+}
+
+func (app *application) webhookView(w http.ResponseWriter, r *http.Request, scope webhookScope) {
+	hook := app.webhook(w, r, scope)
+	if hook == nil {
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(hook.ID, webhookLogSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = hook
+	data.WebhookDeliveries = deliveries
+	data.WebhookRoutes = scope.routes
+	app.render(w, http.StatusOK, "webhook.tmpl.html", data)
+}
+
+func (app *application) webhookDelete(w http.ResponseWriter, r *http.Request, scope webhookScope) {
+	hook := app.webhook(w, r, scope)
+	if hook == nil {
+		return
+	}
+
+	err := app.webhooks.Delete(hook.ID, scope.userID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Webhook deleted.")
+	http.Redirect(w, r, urlFor(scope.routes+".webhooks"), http.StatusSeeOther)
+}
+
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.webhookList(w, r, accountWebhookScope(r), webhookForm{}, http.StatusOK)
+}
+
+func (app *application) accountWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	app.webhookCreate(w, r, accountWebhookScope(r))
+}
+
+func (app *application) accountWebhook(w http.ResponseWriter, r *http.Request) {
+	app.webhookView(w, r, accountWebhookScope(r))
+}
+
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	app.webhookDelete(w, r, accountWebhookScope(r))
+}
+
+func (app *application) adminWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.webhookList(w, r, adminWebhookScope(), webhookForm{}, http.StatusOK)
+}
+
+func (app *application) adminWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	app.webhookCreate(w, r, adminWebhookScope())
+}
+
+func (app *application) adminWebhook(w http.ResponseWriter, r *http.Request) {
+	app.webhookView(w, r, adminWebhookScope())
+}
+
+func (app *application) adminWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	app.webhookDelete(w, r, adminWebhookScope())
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "admin@example.com", "pa$$word")
+	csrfToken := ts.csrfToken(t, "/admin/webhooks")
+
+	// Only the listed events can be chosen.
+	form := url.Values{"csrf_token": {csrfToken}, "url": {receiver.URL}, "events": {"snippet.exploded"}}
+	code, _, body := ts.postForm(t, "/admin/webhooks", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must only contain the listed events")
+
+	form = url.Values{"csrf_token": {csrfToken}, "url": {receiver.URL}, "events": {webhooks.SnippetCreated, webhooks.UserRegistered}}
+	code, headers, _ := ts.postForm(t, "/admin/webhooks", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/admin/webhooks/1")
+
+	hook, err := app.webhooks.Get(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form = url.Values{"csrf_token": {csrfToken}, "title": {"Hooked"}, "content": {"Hello"}, "expires": {"7"}}
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	err = app.deliverWebhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	assert.Equal(t, len(received), 1)
+	assert.Equal(t, received[0].Header.Get("X-Snippetbox-Event"), webhooks.SnippetCreated)
+	err = webhooks.Verify(hook.Secret, received[0].Header.Get("X-Snippetbox-Signature"), bodies[0], time.Minute)
+	assert.Equal(t, err, nil)
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(bodies[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	mu.Unlock()
+	assert.Equal(t, event.Type, webhooks.SnippetCreated)
+	assert.Equal(t, event.Data.Title, "Hooked")
+	assert.Equal(t, event.Data.Content, "")
+
+	_, _, body = ts.get(t, "/admin/webhooks/1")
+	assert.StringContains(t, body, hook.Secret)
+	assert.StringContains(t, body, "<td>delivered</td>")
+
+	// Admins' webhooks are theirs alone.
+	ts.resetClient(t)
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, _ = ts.get(t, "/account/webhooks/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Users' webhooks can't be pointed at our own network, and can't
+	// subscribe to signups.
+	csrfToken = ts.csrfToken(t, "/account/webhooks")
+	form = url.Values{"csrf_token": {csrfToken}, "url": {receiver.URL}, "events": {webhooks.UserRegistered}}
+	code, _, _ = ts.postForm(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	form.Set("events", webhooks.SnippetCreated)
+	code, _, _ = ts.postForm(t, "/account/webhooks", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	form = url.Values{"csrf_token": {csrfToken}, "title": {"Mine"}, "content": {"Hello"}, "expires": {"7"}}
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	err = app.deliverWebhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	assert.Equal(t, len(received), 2)
+	mu.Unlock()
+
+	_, _, body = ts.get(t, "/account/webhooks/2")
+	assert.StringContains(t, body, "private or local address")
+	assert.StringContains(t, body, "retrying at")
+}
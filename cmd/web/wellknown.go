@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// securityTxtLifetime is how far ahead the Expires field of security.txt
+// is. It's generated from the current configuration on each request, so it
+// never goes stale, but RFC 9116 asks for less than a year.
+const securityTxtLifetime = 180 * 24 * time.Hour
+
+// wellKnown serves the /.well-known/ URIs. security.txt and change-password
+// are built in; anything else is served from the -well-known-dir directory,
+// which can also override security.txt.
+type wellKnown struct {
+	dir              fs.FS
+	securityContacts []string
+	securityPolicy   string
+}
+
+// newWellKnown checks the configuration for /.well-known/. Contacts must be
+// mailto:, https: or tel: URIs, although bare email addresses are accepted
+// and turned into mailto: ones.
+func newWellKnown(dir string, contacts []string, policy string) (*wellKnown, error) {
+	wk := &wellKnown{securityPolicy: policy}
+
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("-well-known-dir %s is not a directory", dir)
+		}
+		wk.dir = os.DirFS(dir)
+	}
+
+	for _, c := range contacts {
+		if !strings.Contains(c, ":") && strings.Contains(c, "@") {
+			c = "mailto:" + c
+		}
+		if !strings.HasPrefix(c, "mailto:") && !strings.HasPrefix(c, "https://") && !strings.HasPrefix(c, "tel:") {
+			return nil, fmt.Errorf("security contact %q must be an email address or a mailto:, https: or tel: URI", c)
+		}
+		wk.securityContacts = append(wk.securityContacts, c)
+	}
+
+	if policy != "" && !strings.HasPrefix(policy, "https://") {
+		return nil, errors.New("security policy must be an https: URL")
+	}
+
+	return wk, nil
+}
+
+func (app *application) wellKnown(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("name"), "/")
+
+	if app.wellKnownFile(w, r, name) {
+		return
+	}
+
+	switch name {
+	case "security.txt":
+		app.securityTxt(w, r)
+	case "change-password":
+		// https://w3c.github.io/webappsec-change-password-url/
+		http.Redirect(w, r, urlFor("account.password.update"), http.StatusFound)
+	case "webfinger":
+		app.webFinger(w, r)
+	default:
+		app.notFound(w)
+	}
+}
+
+// wellKnownFile serves name from -well-known-dir, if it's there. Dotfiles
+// and directories are never served.
+func (app *application) wellKnownFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	if app.wellKnownConfig.dir == nil || !fs.ValidPath(name) || strings.HasPrefix(path.Base(name), ".") {
+		return false
+	}
+
+	f, err := app.wellKnownConfig.dir.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	// Files like apple-app-site-association have no extension, but are
+	// JSON; everything else goes by its extension, or failing that by
+	// sniffing it.
+	if path.Ext(name) == "" && strings.HasSuffix(name, "-association") {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+// securityTxt serves a security.txt (RFC 9116) built from the -security-*
+// flags. Without any contacts there's nothing to say, so it's a 404.
+func (app *application) securityTxt(w http.ResponseWriter, r *http.Request) {
+	wk := app.wellKnownConfig
+	if len(wk.securityContacts) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	var b strings.Builder
+	for _, c := range wk.securityContacts {
+		fmt.Fprintf(&b, "Contact: %s\n", c)
+	}
+	expires := time.Now().UTC().Add(securityTxtLifetime).Truncate(24 * time.Hour)
+	fmt.Fprintf(&b, "Expires: %s\n", expires.Format(time.RFC3339))
+	if wk.securityPolicy != "" {
+		fmt.Fprintf(&b, "Policy: %s\n", wk.securityPolicy)
+	}
+	fmt.Fprintf(&b, "Preferred-Languages: en\n")
+	fmt.Fprintf(&b, "Canonical: %s\n", app.absoluteURL(urlFor("well-known", "security.txt")))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
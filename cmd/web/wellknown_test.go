@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWellKnown(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer1(t, app.routes())
+	defer ts.Close()
+
+	// Without any contacts there is no security.txt.
+	code, _, _ := ts.get(t, "/.well-known/security.txt")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	wk, err := newWellKnown("", []string{"security@example.com", "https://example.com/report"}, "https://example.com/policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk.dir = fstest.MapFS{
+		"apple-app-site-association": {Data: []byte(`{"webcredentials":{}}`)},
+		".htaccess":                  {Data: []byte("secret")},
+		"sub/file.txt":               {Data: []byte("nested")},
+	}
+	app.wellKnownConfig = wk
+
+	code, headers, body := ts.get(t, "/.well-known/security.txt")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.StringContains(t, body, "Contact: mailto:security@example.com\n")
+	assert.StringContains(t, body, "Contact: https://example.com/report\n")
+	assert.StringContains(t, body, "Policy: https://example.com/policy\n")
+	assert.StringContains(t, body, "Canonical: "+app.siteURL+"/.well-known/security.txt\n")
+	assert.Equal(t, strings.Contains(body, "Expires: "), true)
+
+	// The Host header is up to the client, so it doesn't change the link.
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/security.txt", nil)
+	req.Host = "evil.example"
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	assert.StringContains(t, rr.Body.String(), "Canonical: "+app.siteURL+"/.well-known/security.txt\n")
+
+	code, headers, _ = ts.get(t, "/.well-known/change-password")
+	assert.Equal(t, code, http.StatusFound)
+	assert.Equal(t, headers.Get("Location"), "/account/password/update")
+
+	code, headers, body = ts.get(t, "/.well-known/apple-app-site-association")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	assert.Equal(t, body, `{"webcredentials":{}}`)
+
+	code, _, body = ts.get(t, "/.well-known/sub/file.txt")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "nested")
+
+	for _, name := range []string{".htaccess", "sub", "missing", "../go.mod"} {
+		code, _, _ = ts.get(t, "/.well-known/"+name)
+		assert.Equal(t, code, http.StatusNotFound)
+	}
+
+	_, err = newWellKnown("", []string{"ftp://example.com"}, "")
+	assert.Equal(t, err != nil, true)
+}
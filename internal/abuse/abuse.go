@@ -0,0 +1,279 @@
+// Package abuse keeps a score of how suspicious each client, such as a client
+// IP address, has been recently, so that suspicious clients can be slowed
+// down, challenged and eventually blocked rather than turned away outright.
+//
+// Each suspicious thing a client does is a Signal with a weight, which is
+// added to its score. Scores decay exponentially, halving every HalfLife, so
+// a client which stops misbehaving is soon back to normal.
+package abuse
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often forgotten clients are removed from memory.
+const sweepInterval = time.Minute
+
+// forgetBelow is the score under which a client is no different from one
+// which was never seen.
+const forgetBelow = 0.1
+
+// Level is how a client should be treated, according to its score.
+type Level int
+
+const (
+	// None is for clients which haven't done anything suspicious lately.
+	None Level = iota
+	// Delay clients have their requests slowed down.
+	Delay
+	// Challenge clients have to prove they are a person before their
+	// requests go through.
+	Challenge
+	// Block clients are turned away until their score has decayed.
+	Block
+)
+
+func (l Level) String() string {
+	switch l {
+	case Delay:
+		return "delay"
+	case Challenge:
+		return "challenge"
+	case Block:
+		return "block"
+	default:
+		return "none"
+	}
+}
+
+// Thresholds are the scores at which a client reaches each level.
+type Thresholds struct {
+	Delay     float64
+	Challenge float64
+	Block     float64
+}
+
+// DefaultThresholds are the thresholds used by New.
+var DefaultThresholds = Thresholds{Delay: 5, Challenge: 10, Block: 20}
+
+// Signal is something suspicious a client did, like failing to sign in. Its
+// weight is added to the client's score.
+type Signal struct {
+	Name   string
+	Weight float64
+}
+
+// Status describes where a client stands.
+type Status struct {
+	Level Level
+	Score float64
+	// RetryAfter is how long until a blocked client's score decays enough
+	// for it to stop being blocked.
+	RetryAfter time.Duration
+}
+
+// Offender is a client with a score high enough to be treated differently,
+// as listed by Offenders.
+type Offender struct {
+	Key   string
+	Score float64
+	Level Level
+	// Signals counts the signals recorded for the client since it was last
+	// forgotten, by name.
+	Signals  map[string]int
+	LastSeen time.Time
+}
+
+type entry struct {
+	score float64
+	// updated is when score was last brought up to date, and seen is when
+	// the last signal was recorded.
+	updated time.Time
+	seen    time.Time
+	signals map[string]int
+}
+
+// Scorer keeps the score of each key. It is safe for concurrent use.
+type Scorer struct {
+	HalfLife   time.Duration
+	Thresholds Thresholds
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+
+	// now is replaceable in tests.
+	now func() time.Time
+}
+
+// New returns a scorer whose scores halve every halfLife, using the default
+// thresholds.
+func New(halfLife time.Duration) *Scorer {
+	return &Scorer{
+		HalfLife:   halfLife,
+		Thresholds: DefaultThresholds,
+		entries:    make(map[string]*entry),
+		now:        time.Now,
+	}
+}
+
+// Record adds the signal's weight to the key's score, and returns where the
+// key stands afterwards.
+func (s *Scorer) Record(key string, sig Signal) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	e := s.decay(key, now)
+	if e == nil {
+		e = &entry{updated: now, signals: make(map[string]int)}
+		s.entries[key] = e
+	}
+	e.score += sig.Weight
+	e.seen = now
+	e.signals[sig.Name]++
+
+	return s.status(e.score)
+}
+
+// Check returns where the key stands, without recording anything.
+func (s *Scorer) Check(key string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var score float64
+	if e := s.decay(key, s.now()); e != nil {
+		score = e.score
+	}
+	return s.status(score)
+}
+
+// Relieve lowers the key's score to half the challenge threshold, if it's
+// higher, once the client has shown it's a person by answering a challenge.
+// Blocked clients are relieved too, but they can't be challenged until their
+// score has decayed.
+func (s *Scorer) Relieve(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.decay(key, s.now()); e != nil {
+		e.score = math.Min(e.score, s.Thresholds.Challenge/2)
+	}
+}
+
+// Clear forgets the key, as if it had never been seen. It reports whether the
+// key was known.
+func (s *Scorer) Clear(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok
+}
+
+// Offenders returns the keys which are at the Delay level or above, highest
+// score first.
+func (s *Scorer) Offenders() []Offender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	offenders := []Offender{}
+
+	for key := range s.entries {
+		e := s.decay(key, now)
+		if e == nil || e.score < s.Thresholds.Delay {
+			continue
+		}
+
+		signals := make(map[string]int, len(e.signals))
+		for name, n := range e.signals {
+			signals[name] = n
+		}
+		offenders = append(offenders, Offender{
+			Key:      key,
+			Score:    e.score,
+			Level:    s.level(e.score),
+			Signals:  signals,
+			LastSeen: e.seen,
+		})
+	}
+
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Score != offenders[j].Score {
+			return offenders[i].Score > offenders[j].Score
+		}
+		return offenders[i].Key < offenders[j].Key
+	})
+	return offenders
+}
+
+// decay brings the key's score up to date, forgetting the key if there's
+// nothing left of it, and returns its entry or nil. It must be called with
+// s.mu held.
+func (s *Scorer) decay(key string, now time.Time) *entry {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+
+	e.score = s.decayed(e, now)
+	e.updated = now
+	if e.score < forgetBelow {
+		delete(s.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (s *Scorer) decayed(e *entry, now time.Time) float64 {
+	if s.HalfLife <= 0 {
+		return e.score
+	}
+	return e.score * math.Exp2(-now.Sub(e.updated).Seconds()/s.HalfLife.Seconds())
+}
+
+func (s *Scorer) level(score float64) Level {
+	switch {
+	case score >= s.Thresholds.Block:
+		return Block
+	case score >= s.Thresholds.Challenge:
+		return Challenge
+	case score >= s.Thresholds.Delay:
+		return Delay
+	default:
+		return None
+	}
+}
+
+func (s *Scorer) status(score float64) Status {
+	st := Status{Level: s.level(score), Score: score}
+	if st.Level == Block && s.HalfLife > 0 {
+		// The score falls below the block threshold after log2(score/block)
+		// half-lives. A score exactly at the threshold still needs a moment.
+		halfLives := math.Max(math.Log2(score/s.Thresholds.Block), 0.01)
+		st.RetryAfter = time.Duration(halfLives * float64(s.HalfLife))
+	}
+	return st
+}
+
+// sweep forgets keys whose score has decayed to nothing. It must be called
+// with s.mu held.
+func (s *Scorer) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, e := range s.entries {
+		if s.decayed(e, now) < forgetBelow {
+			delete(s.entries, key)
+		}
+	}
+}
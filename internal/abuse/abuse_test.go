@@ -0,0 +1,71 @@
+package abuse
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestScorer(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := New(time.Minute)
+	s.now = func() time.Time { return now }
+
+	failedLogin := Signal{Name: "failed login", Weight: 3}
+
+	assert.Equal(t, s.Check("a").Level, None)
+	assert.Equal(t, s.Record("a", failedLogin).Level, None)
+	assert.Equal(t, s.Record("a", failedLogin).Level, Delay)
+	assert.Equal(t, s.Record("a", failedLogin).Level, Delay)
+	assert.Equal(t, s.Record("a", failedLogin).Level, Challenge)
+
+	// Other keys are scored separately.
+	assert.Equal(t, s.Check("b").Level, None)
+
+	// A minute later the score of 12 has halved.
+	now = now.Add(time.Minute)
+	st := s.Check("a")
+	assert.Equal(t, st.Level, Delay)
+	assert.Equal(t, st.Score, 6.0)
+
+	for i := 0; i < 5; i++ {
+		st = s.Record("a", failedLogin)
+	}
+	assert.Equal(t, st.Level, Block)
+	assert.Equal(t, st.Score, 21.0)
+	// 21 decays to 20 in log2(21/20) minutes.
+	assert.Equal(t, st.RetryAfter.Round(time.Second), 4*time.Second)
+
+	offenders := s.Offenders()
+	assert.Equal(t, len(offenders), 1)
+	assert.Equal(t, offenders[0].Key, "a")
+	assert.Equal(t, offenders[0].Level, Block)
+	assert.Equal(t, offenders[0].Signals["failed login"], 9)
+	assert.Equal(t, offenders[0].LastSeen, now)
+
+	// Answering a challenge brings the score down to below the challenge
+	// threshold.
+	s.Relieve("a")
+	assert.Equal(t, s.Check("a").Level, Delay)
+
+	assert.Equal(t, s.Clear("a"), true)
+	assert.Equal(t, s.Clear("a"), false)
+	assert.Equal(t, s.Check("a").Level, None)
+	assert.Equal(t, len(s.Offenders()), 0)
+}
+
+func TestScorerForgets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := New(time.Minute)
+	s.now = func() time.Time { return now }
+
+	s.Record("a", Signal{Name: "signup", Weight: 2})
+	s.Record("b", Signal{Name: "signup", Weight: 2})
+
+	// Keys which have decayed to nothing are swept away on the next record.
+	now = now.Add(10 * time.Minute)
+	s.Record("c", Signal{Name: "signup", Weight: 2})
+	assert.Equal(t, len(s.entries), 1)
+}
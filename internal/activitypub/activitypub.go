@@ -0,0 +1,253 @@
+// Package activitypub implements the small part of ActivityPub which lets
+// people on the Fediverse follow a Snippetbox instance: the documents which
+// describe the instance's actor, the activities it sends, WebFinger, and the
+// HTTP signatures servers use to check who sent a request.
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of ActivityPub documents.
+const ContentType = "application/activity+json"
+
+// Public is the collection addressed by activities anyone may see.
+const Public = "https://www.w3.org/ns/activitystreams#Public"
+
+// Context is the JSON-LD context of the documents we publish. The security
+// vocabulary is for the actor's publicKey.
+var Context = []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"}
+
+// Actor describes an account which can be followed.
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+// Endpoints lists an actor's optional endpoints. Servers with many followers
+// on one instance deliver to its shared inbox once, rather than to each of
+// their inboxes.
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// PublicKey is the key an actor's requests are signed with.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// DeliveryInbox returns the inbox to deliver to the actor at: their
+// server's shared inbox if it has one, or their own.
+func (a *Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// Note is a post. Each public snippet is published as one.
+type Note struct {
+	Context      any       `json:"@context,omitempty"`
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	AttributedTo string    `json:"attributedTo"`
+	Name         string    `json:"name,omitempty"`
+	Content      string    `json:"content"`
+	URL          string    `json:"url,omitempty"`
+	Published    time.Time `json:"published"`
+	To           []string  `json:"to,omitempty"`
+	Cc           []string  `json:"cc,omitempty"`
+}
+
+// Activity is something an actor did to an object, such as creating a Note
+// or following someone. Object is a document or the ID of one.
+type Activity struct {
+	Context   any        `json:"@context,omitempty"`
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor"`
+	Object    any        `json:"object"`
+	Published *time.Time `json:"published,omitempty"`
+	To        []string   `json:"to,omitempty"`
+	Cc        []string   `json:"cc,omitempty"`
+}
+
+// Incoming is an activity posted to an inbox. Only the fields we act on are
+// decoded; Object is left raw as it may be a document or an ID.
+type Incoming struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// ObjectID returns the ID of the activity's object, whether it was given as
+// a document or just its ID.
+func (a *Incoming) ObjectID() string {
+	var id string
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	var doc struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(a.Object, &doc)
+	return doc.ID
+}
+
+// ObjectActivity decodes the activity's object as an activity itself, as
+// with the Follow inside an Undo.
+func (a *Incoming) ObjectActivity() (*Incoming, error) {
+	var inner Incoming
+	if err := json.Unmarshal(a.Object, &inner); err != nil {
+		return nil, err
+	}
+	return &inner, nil
+}
+
+// OrderedCollection is a list of items, like an outbox or a follower list.
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+// IsActivityPub reports whether the media type of a Content-Type or Accept
+// header value is one of those used for ActivityPub documents.
+func IsActivityPub(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == ContentType {
+			return true
+		}
+		if mediaType == "application/ld+json" && params["profile"] == "https://www.w3.org/ns/activitystreams" {
+			return true
+		}
+	}
+	return false
+}
+
+// maxDocumentSize is the most we read of a document fetched from another
+// server.
+const maxDocumentSize = 1 << 20
+
+// FetchActor gets the actor document at uri, signing the request with key
+// if it isn't nil, as some servers only answer signed requests.
+func FetchActor(ctx context.Context, client *http.Client, key *Key, uri string) (*Actor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if key != nil {
+		if err = key.Sign(req, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("activitypub: fetching %s: %s", uri, res.Status)
+	}
+
+	actor := &Actor{}
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxDocumentSize)).Decode(actor); err != nil {
+		return nil, fmt.Errorf("activitypub: fetching %s: %w", uri, err)
+	}
+	if actor.ID != uri || actor.Inbox == "" {
+		return nil, fmt.Errorf("activitypub: %s isn't an actor", uri)
+	}
+	return actor, nil
+}
+
+// userAgent identifies our requests to other servers.
+const userAgent = "Snippetbox-ActivityPub/1.0"
+
+// Deliver posts an activity to an inbox, signed with key, and returns an
+// error unless the inbox accepted it.
+func Deliver(ctx context.Context, client *http.Client, key *Key, inbox string, activity []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if err = key.Sign(req, activity); err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("activitypub: %s responded with %s", req.URL.Host, res.Status)
+	}
+	return nil
+}
+
+// WebFinger is a WebFinger response, which points from an account's
+// acct: URI to its actor.
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// ErrNotAcct is returned by ParseAcct for resources which aren't acct: URIs.
+var ErrNotAcct = errors.New("activitypub: not an acct: URI")
+
+// ParseAcct splits a WebFinger resource like "acct:user@example.com" into
+// its user and host.
+func ParseAcct(resource string) (user, host string, err error) {
+	acct, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		return "", "", ErrNotAcct
+	}
+	user, host, ok = strings.Cut(strings.TrimPrefix(acct, "@"), "@")
+	if !ok || user == "" || host == "" {
+		return "", "", ErrNotAcct
+	}
+	return user, strings.ToLower(host), nil
+}
@@ -0,0 +1,179 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) (*Key, *rsa.PublicKey) {
+	t.Helper()
+
+	public, private, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ParsePrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParsePublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: "https://example.com/ap/actor#main-key", Private: priv}, pub
+}
+
+func TestSignAndVerify(t *testing.T) {
+	key, pub := newTestKey(t)
+	lookup := func(keyID string) (*rsa.PublicKey, error) {
+		if keyID != key.ID {
+			return nil, errors.New("unknown key")
+		}
+		return pub, nil
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://snippets.example.com/ap/inbox", strings.NewReader(string(body)))
+		if err := key.Sign(req, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(req *http.Request) []byte
+		valid  bool
+	}{
+		{
+			name:   "Valid",
+			tamper: func(req *http.Request) []byte { return body },
+			valid:  true,
+		},
+		{
+			name:   "Changed body",
+			tamper: func(req *http.Request) []byte { return []byte(`{"type":"Undo"}`) },
+		},
+		{
+			name: "Changed path",
+			tamper: func(req *http.Request) []byte {
+				req.URL.Path = "/ap/other"
+				return body
+			},
+		},
+		{
+			name: "Old date",
+			tamper: func(req *http.Request) []byte {
+				req.Header.Set("Date", time.Now().Add(-2*MaxClockSkew).UTC().Format(http.TimeFormat))
+				return body
+			},
+		},
+		{
+			name: "Date not signed",
+			tamper: func(req *http.Request) []byte {
+				req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), ` date`, ``, 1))
+				return body
+			},
+		},
+		{
+			name: "Unsigned",
+			tamper: func(req *http.Request) []byte {
+				req.Header.Del("Signature")
+				return body
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest()
+			keyID, err := Verify(req, tt.tamper(req), lookup)
+			if tt.valid {
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, keyID, key.ID)
+			} else {
+				assert.Equal(t, errors.Is(err, ErrInvalidSignature), true)
+			}
+		})
+	}
+}
+
+func TestParseAcct(t *testing.T) {
+	user, host, err := ParseAcct("acct:snippetbox@Snippets.Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user, "snippetbox")
+	assert.Equal(t, host, "snippets.example.com")
+
+	for _, resource := range []string{"https://example.com/ap/actor", "acct:snippetbox", "acct:@example.com"} {
+		_, _, err = ParseAcct(resource)
+		assert.Equal(t, errors.Is(err, ErrNotAcct), true)
+	}
+}
+
+func TestIsActivityPub(t *testing.T) {
+	assert.Equal(t, IsActivityPub("application/activity+json"), true)
+	assert.Equal(t, IsActivityPub(`text/html, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`), true)
+	assert.Equal(t, IsActivityPub("application/ld+json"), false)
+	assert.Equal(t, IsActivityPub("text/html,application/xhtml+xml"), false)
+}
+
+func TestFetchActorAndDeliver(t *testing.T) {
+	key, pub := newTestKey(t)
+
+	var delivered []byte
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/bob":
+			w.Header().Set("Content-Type", ContentType)
+			json.NewEncoder(w).Encode(Actor{
+				ID:        ts.URL + "/users/bob",
+				Type:      "Person",
+				Inbox:     ts.URL + "/users/bob/inbox",
+				Endpoints: &Endpoints{SharedInbox: ts.URL + "/inbox"},
+			})
+		case "/inbox":
+			body := make([]byte, r.ContentLength)
+			r.Body.Read(body)
+			_, err := Verify(r, body, func(string) (*rsa.PublicKey, error) { return pub, nil })
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			delivered = body
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	actor, err := FetchActor(context.Background(), ts.Client(), key, ts.URL+"/users/bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, actor.DeliveryInbox(), ts.URL+"/inbox")
+
+	_, err = FetchActor(context.Background(), ts.Client(), nil, ts.URL+"/users/nobody")
+	assert.StringContains(t, err.Error(), "404")
+
+	err = Deliver(context.Background(), ts.Client(), key, actor.DeliveryInbox(), []byte(`{"type":"Create"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(delivered), `{"type":"Create"}`)
+}
@@ -0,0 +1,233 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Requests between ActivityPub servers are signed following the HTTP
+// Signatures draft (draft-cavage-http-signatures), with RSA-SHA256 keys, as
+// that's what Mastodon and the rest of the Fediverse use.
+
+// Key is an actor's key pair, and the ID its public key is published under.
+type Key struct {
+	ID      string
+	Private *rsa.PrivateKey
+}
+
+// GenerateKey makes a new key pair for an actor, returning both halves as
+// PEM.
+func GenerateKey() (public, private string, err error) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	priv, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		return "", "", err
+	}
+
+	public = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	private = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}))
+	return public, private, nil
+}
+
+// ParsePrivateKey reads a private key made by GenerateKey.
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: no PEM data in private key")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("activitypub: private key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey reads the publicKeyPem of an actor, which may be in PKIX or
+// PKCS #1 form.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: no PEM data in public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := k.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("activitypub: public key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Sign adds Date and Signature headers to req, plus a Digest of body for
+// requests which have one, and signs them along with the method, path and
+// host.
+func (k *Key) Sign(req *http.Request, body []byte) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, k.Private, crypto.SHA256, hashOf(signingString(req, headers)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		k.ID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+// ErrInvalidSignature is returned by Verify when a request isn't signed, or
+// the signature doesn't check out.
+var ErrInvalidSignature = errors.New("activitypub: invalid signature")
+
+// MaxClockSkew is how far the Date of a signed request may be from now.
+// Older signatures are rejected so they can't be replayed.
+const MaxClockSkew = time.Hour
+
+// Verify checks the signature of a request to an inbox, whose body has
+// already been read. publicKey looks up the key with the given ID, usually
+// by fetching the actor it belongs to. It returns the ID of the key which
+// made the signature.
+func Verify(req *http.Request, body []byte, publicKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	params := parseSignature(req.Header.Get("Signature"))
+	keyID, sig64 := params["keyId"], params["signature"]
+	if keyID == "" || sig64 == "" {
+		return "", ErrInvalidSignature
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return "", ErrInvalidSignature
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+
+	// The signature must cover what the request does, when it was made and,
+	// for requests with a body, what's in it.
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, h := range required {
+		if !slices.Contains(headers, h) {
+			return "", ErrInvalidSignature
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if skew := time.Since(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", ErrInvalidSignature
+	}
+
+	if len(body) > 0 && !digestMatches(req.Header.Get("Digest"), body) {
+		return "", ErrInvalidSignature
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sig64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	key, err := publicKey(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashOf(signingString(req, headers)), sig)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return keyID, nil
+}
+
+// signingString is what's signed: each of the named headers on a line of
+// its own.
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		var value string
+		switch h {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		default:
+			value = strings.Join(req.Header.Values(h), ", ")
+		}
+		lines[i] = h + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature splits a Signature header into its parameters.
+func parseSignature(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+	return params
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// digestMatches checks a Digest header, which may list several digests, has
+// a SHA-256 one of body.
+func digestMatches(header string, body []byte) bool {
+	want := digest(body)
+	for _, d := range strings.Split(header, ",") {
+		alg, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(alg, "SHA-256") && "SHA-256="+value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func hashOf(s string) []byte {
+	sum := sha256.Sum256([]byte(s))
+	return sum[:]
+}
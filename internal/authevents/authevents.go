@@ -0,0 +1,149 @@
+// Package authevents records what happens when people sign in and out and
+// manage their credentials. Every outcome goes through a Recorder, which
+// writes it to the audit log as a structured event and counts it, so that
+// the log and the metrics always agree.
+package authevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is what happened.
+type Kind string
+
+const (
+	LoginSucceeded Kind = "login_succeeded"
+	LoginFailed    Kind = "login_failed"
+	Logout         Kind = "logout"
+	// LockedOut is a user being refused, or signed out, because their
+	// account is suspended.
+	LockedOut Kind = "locked_out"
+	// LoginLinkRequested is somebody asking for a sign-in link to be
+	// emailed, which is how a user who has forgotten their password gets
+	// back in.
+	LoginLinkRequested   Kind = "login_link_requested"
+	PasswordChanged      Kind = "password_changed"
+	PasswordChangeFailed Kind = "password_change_failed"
+	// ImpersonationStarted and ImpersonationEnded are an admin starting
+	// and stopping acting as another user, to help them.
+	ImpersonationStarted Kind = "impersonation_started"
+	ImpersonationEnded   Kind = "impersonation_ended"
+)
+
+// Method is how a user signed in, or tried to.
+type Method string
+
+const (
+	Password  Method = "password"
+	LoginLink Method = "login_link"
+	Passkey   Method = "passkey"
+	SSO       Method = "sso"
+)
+
+// Event is one authentication outcome. UserID is 0 when the user isn't
+// known, such as after a failed login, when Email says who it was for
+// instead. ImpersonatorID is the admin acting as the user, if one is, and
+// ImpersonationID tells their impersonations apart.
+type Event struct {
+	Time            time.Time `json:"time"`
+	Kind            Kind      `json:"event"`
+	Method          Method    `json:"method,omitempty"`
+	UserID          int       `json:"user_id,omitempty"`
+	Email           string    `json:"email,omitempty"`
+	ImpersonatorID  int       `json:"impersonator_id,omitempty"`
+	ImpersonationID string    `json:"impersonation_id,omitempty"`
+	ClientIP        string    `json:"client_ip"`
+	RequestID       string    `json:"request_id"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// Count is how many events of a kind, by a method, have been recorded.
+type Count struct {
+	Kind   Kind
+	Method Method
+	N      int64
+}
+
+type countKey struct {
+	kind   Kind
+	method Method
+}
+
+// Recorder writes events to the audit log and counts them. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format logging.Format
+	counts map[countKey]int64
+}
+
+// New returns a recorder which writes the audit log to w in the given
+// format.
+func New(w io.Writer, format logging.Format) *Recorder {
+	return &Recorder{w: w, format: format, counts: make(map[countKey]int64)}
+}
+
+// Record counts the event and writes it to the audit log. Write errors are
+// ignored, as there's nothing useful a request can do about them.
+func (rec *Recorder) Record(e Event) {
+	var line []byte
+
+	if rec.format == logging.JSON {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s AUTH\t%s", e.Time.Format("2006/01/02 15:04:05"), e.Kind)
+		if e.Method != "" {
+			fmt.Fprintf(&b, " method=%s", e.Method)
+		}
+		if e.UserID != 0 {
+			fmt.Fprintf(&b, " user=%d", e.UserID)
+		}
+		if e.Email != "" {
+			fmt.Fprintf(&b, " email=%q", e.Email)
+		}
+		if e.ImpersonatorID != 0 {
+			fmt.Fprintf(&b, " impersonator=%d impersonation=%s", e.ImpersonatorID, e.ImpersonationID)
+		}
+		fmt.Fprintf(&b, " ip=%s", e.ClientIP)
+		if e.Reason != "" {
+			fmt.Fprintf(&b, " reason=%q", e.Reason)
+		}
+		fmt.Fprintf(&b, " [%s]\n", e.RequestID)
+		line = []byte(b.String())
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.counts[countKey{e.Kind, e.Method}]++
+	rec.w.Write(line)
+}
+
+// Counts returns the number of events recorded of each kind and method seen
+// so far, ordered by kind and then method.
+func (rec *Recorder) Counts() []Count {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	counts := make([]Count, 0, len(rec.counts))
+	for k, n := range rec.counts {
+		counts = append(counts, Count{Kind: k.kind, Method: k.method, N: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Kind != counts[j].Kind {
+			return counts[i].Kind < counts[j].Kind
+		}
+		return counts[i].Method < counts[j].Method
+	})
+	return counts
+}
@@ -0,0 +1,85 @@
+package authevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/logging"
+	"testing"
+	"time"
+)
+
+func TestRecorderHuman(t *testing.T) {
+	var buf bytes.Buffer
+	rec := New(&buf, logging.Human)
+
+	rec.Record(Event{
+		Time:      time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+		Kind:      LoginFailed,
+		Method:    Password,
+		Email:     "alice@example.com",
+		ClientIP:  "192.0.2.1",
+		RequestID: "abc123",
+		Reason:    "invalid credentials",
+	})
+	assert.Equal(t, buf.String(), "2024/03/17 10:15:00 AUTH\tlogin_failed method=password email=\"alice@example.com\" ip=192.0.2.1 reason=\"invalid credentials\" [abc123]\n")
+
+	buf.Reset()
+	rec.Record(Event{
+		Time:      time.Date(2024, 3, 17, 10, 16, 0, 0, time.UTC),
+		Kind:      Logout,
+		UserID:    1,
+		ClientIP:  "192.0.2.1",
+		RequestID: "def456",
+	})
+	assert.Equal(t, buf.String(), "2024/03/17 10:16:00 AUTH\tlogout user=1 ip=192.0.2.1 [def456]\n")
+
+	buf.Reset()
+	rec.Record(Event{
+		Time:            time.Date(2024, 3, 17, 10, 17, 0, 0, time.UTC),
+		Kind:            ImpersonationStarted,
+		UserID:          1,
+		ImpersonatorID:  2,
+		ImpersonationID: "xyz789",
+		ClientIP:        "192.0.2.2",
+		RequestID:       "ghi789",
+		Reason:          "ticket 42",
+	})
+	assert.Equal(t, buf.String(), "2024/03/17 10:17:00 AUTH\timpersonation_started user=1 impersonator=2 impersonation=xyz789 ip=192.0.2.2 reason=\"ticket 42\" [ghi789]\n")
+}
+
+func TestRecorderJSON(t *testing.T) {
+	var buf bytes.Buffer
+	rec := New(&buf, logging.JSON)
+
+	e := Event{
+		Time:      time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+		Kind:      LoginSucceeded,
+		Method:    Passkey,
+		UserID:    2,
+		ClientIP:  "192.0.2.1",
+		RequestID: "abc123",
+	}
+	rec.Record(e)
+
+	var got Event
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got, e)
+}
+
+func TestRecorderCounts(t *testing.T) {
+	rec := New(&bytes.Buffer{}, logging.Human)
+
+	rec.Record(Event{Kind: LoginFailed, Method: Password})
+	rec.Record(Event{Kind: Logout})
+	rec.Record(Event{Kind: LoginFailed, Method: Password})
+	rec.Record(Event{Kind: LoginFailed, Method: Passkey})
+
+	counts := rec.Counts()
+	assert.Equal(t, len(counts), 3)
+	assert.Equal(t, counts[0], Count{Kind: LoginFailed, Method: Passkey, N: 1})
+	assert.Equal(t, counts[1], Count{Kind: LoginFailed, Method: Password, N: 2})
+	assert.Equal(t, counts[2], Count{Kind: Logout, N: 1})
+}
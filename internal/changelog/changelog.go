@@ -0,0 +1,92 @@
+// Package changelog reads and writes the user-facing list of changes shown
+// on the what's new page. The entries are kept as JSON, newest first.
+package changelog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format of an entry's date.
+const DateLayout = "2006-01-02"
+
+// Entry is one release's worth of changes.
+type Entry struct {
+	Version string   `json:"version"`
+	Date    string   `json:"date"`
+	Items   []string `json:"items"`
+}
+
+// Time returns the entry's date as a time.Time.
+func (e Entry) Time() time.Time {
+	t, _ := time.Parse(DateLayout, e.Date)
+	return t
+}
+
+func (e Entry) validate() error {
+	if strings.TrimSpace(e.Version) == "" {
+		return errors.New("changelog: entry has no version")
+	}
+	if _, err := time.Parse(DateLayout, e.Date); err != nil {
+		return fmt.Errorf("changelog: version %s has an invalid date %q", e.Version, e.Date)
+	}
+	if len(e.Items) == 0 {
+		return fmt.Errorf("changelog: version %s has no items", e.Version)
+	}
+	return nil
+}
+
+// Parse reads a changelog, checking that every entry is complete, that no
+// version appears twice and that the entries are in order, newest first.
+func Parse(data []byte) ([]Entry, error) {
+	var entries []Entry
+
+	err := json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("changelog: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			return nil, err
+		}
+		if seen[e.Version] {
+			return nil, fmt.Errorf("changelog: version %s appears more than once", e.Version)
+		}
+		seen[e.Version] = true
+
+		if i > 0 && e.Date > entries[i-1].Date {
+			return nil, fmt.Errorf("changelog: version %s is newer than the entry before it", e.Version)
+		}
+	}
+
+	return entries, nil
+}
+
+// Prepend adds an entry to the top of a changelog, returning the new
+// changelog.
+func Prepend(data []byte, e Entry) ([]byte, error) {
+	entries, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = append([]Entry{e}, entries...)
+
+	out, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// Parsing the result catches an invalid, duplicate or out of order
+	// entry.
+	if _, err = Parse(out); err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
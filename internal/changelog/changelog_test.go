@@ -0,0 +1,49 @@
+package changelog
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"Valid", `[{"version":"1.1","date":"2024-02-01","items":["b"]},{"version":"1.0","date":"2024-01-01","items":["a"]}]`, false},
+		{"Empty", `[]`, false},
+		{"Not JSON", `nope`, true},
+		{"No version", `[{"date":"2024-01-01","items":["a"]}]`, true},
+		{"Bad date", `[{"version":"1.0","date":"1 Jan","items":["a"]}]`, true},
+		{"No items", `[{"version":"1.0","date":"2024-01-01","items":[]}]`, true},
+		{"Duplicate", `[{"version":"1.0","date":"2024-01-01","items":["a"]},{"version":"1.0","date":"2024-01-01","items":["a"]}]`, true},
+		{"Out of order", `[{"version":"1.0","date":"2024-01-01","items":["a"]},{"version":"1.1","date":"2024-02-01","items":["b"]}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestPrepend(t *testing.T) {
+	data := []byte(`[{"version":"1.0","date":"2024-01-01","items":["a"]}]`)
+
+	out, err := Prepend(data, Entry{Version: "1.1", Date: "2024-02-01", Items: []string{"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(entries), 2)
+	assert.Equal(t, entries[0].Version, "1.1")
+
+	_, err = Prepend(data, Entry{Version: "1.0", Date: "2024-02-01", Items: []string{"b"}})
+	assert.Equal(t, err != nil, true)
+}
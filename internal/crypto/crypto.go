@@ -0,0 +1,163 @@
+// Package crypto encrypts sensitive values, like database columns, with
+// AES-256-GCM.
+//
+// Keys are identified by short IDs, and every ciphertext records the ID of
+// the key which made it. Keys can therefore be rotated by adding a new key
+// in front of the old ones: new values are encrypted with the first key,
+// and the old keys stay available for decrypting existing values until
+// they have been rewritten or have expired.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of an encryption key in bytes.
+const KeySize = 32
+
+// prefix starts every ciphertext, followed by the key ID, another colon and
+// the base64-encoded nonce and sealed data.
+const prefix = "enc:"
+
+var (
+	// ErrUnknownKey is returned when decrypting a value which was encrypted
+	// with a key that isn't in the keyring.
+	ErrUnknownKey = errors.New("crypto: unknown key")
+
+	// ErrDecrypt is returned when a value is malformed, has been tampered
+	// with, or was encrypted with different associated data.
+	ErrDecrypt = errors.New("crypto: message authentication failed")
+)
+
+// Keyring holds the encryption keys. It is safe for concurrent use.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeys parses a comma-separated list of keys, each written as an ID, a
+// colon and 32 base64-encoded bytes, like "2024b:q3Fz...,2024a:9xJ1...". The
+// first key is used for encryption.
+func ParseKeys(s string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New("crypto: keys must be written as id:base64-key")
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("crypto: duplicate key ID %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("crypto: key %q: %w", id, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("crypto: key %q is %d bytes, not %d", id, len(key), KeySize)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.keys[id] = aead
+	}
+
+	if k.primary == "" {
+		return nil, errors.New("crypto: no keys given")
+	}
+
+	return k, nil
+}
+
+// GenerateKey returns a new random key, base64-encoded ready for ParseKeys.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt encrypts plaintext with the primary key. The associated data isn't
+// stored, but the same data must be given to decrypt the value again, which
+// ties the ciphertext to its context: for example, a column value to the
+// row it belongs to, so that it can't be copied into another row.
+func (k *Keyring) Encrypt(plaintext string, associatedData []byte) (string, error) {
+	aead := k.keys[k.primary]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), associatedData)
+
+	return prefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value made by Encrypt, with whichever key made it.
+func (k *Keyring) Decrypt(ciphertext string, associatedData []byte) (string, error) {
+	rest, ok := strings.CutPrefix(ciphertext, prefix)
+	if !ok {
+		return "", ErrDecrypt
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrDecrypt
+	}
+
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], associatedData)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether s looks like a value made by Encrypt, as
+// opposed to a plaintext value stored before encryption was turned on.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// NeedsRotation reports whether a value made by Encrypt was encrypted with
+// a key other than the primary one, and should be encrypted again.
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	rest, ok := strings.CutPrefix(ciphertext, prefix)
+	if !ok {
+		return true
+	}
+	id, _, _ := strings.Cut(rest, ":")
+	return id != k.primary
+}
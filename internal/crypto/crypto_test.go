@@ -0,0 +1,127 @@
+package crypto
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+func mustParseKeys(t *testing.T, s string) *Keyring {
+	t.Helper()
+
+	k, err := ParseKeys(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := mustParseKeys(t, "a:"+key)
+
+	ciphertext, err := k.Encrypt("alice.new@example.com", []byte("user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.HasPrefix(ciphertext, "enc:a:"), true)
+	assert.Equal(t, strings.Contains(ciphertext, "alice"), false)
+	assert.Equal(t, IsEncrypted(ciphertext), true)
+	assert.Equal(t, IsEncrypted("alice.new@example.com"), false)
+
+	plaintext, err := k.Decrypt(ciphertext, []byte("user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, plaintext, "alice.new@example.com")
+
+	// The same plaintext encrypts differently every time.
+	again, err := k.Encrypt("alice.new@example.com", []byte("user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, again == ciphertext, false)
+
+	// Values can't be moved to a different context...
+	_, err = k.Decrypt(ciphertext, []byte("user:2"))
+	assert.Equal(t, err, ErrDecrypt)
+
+	// ...or tampered with.
+	b := []byte(ciphertext)
+	i := len("enc:a:") + 20
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	tampered := string(b)
+	_, err = k.Decrypt(tampered, []byte("user:1"))
+	assert.Equal(t, err, ErrDecrypt)
+
+	_, err = k.Decrypt("alice.new@example.com", []byte("user:1"))
+	assert.Equal(t, err, ErrDecrypt)
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+
+	old := mustParseKeys(t, "2024a:"+oldKey)
+	ciphertext, err := old.Encrypt("secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After rotation, old values can still be read but are flagged for
+	// encrypting again, and new values use the new key.
+	rotated := mustParseKeys(t, "2024b:"+newKey+", 2024a:"+oldKey)
+
+	plaintext, err := rotated.Decrypt(ciphertext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, plaintext, "secret")
+	assert.Equal(t, rotated.NeedsRotation(ciphertext), true)
+
+	fresh, err := rotated.Encrypt("secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.HasPrefix(fresh, "enc:2024b:"), true)
+	assert.Equal(t, rotated.NeedsRotation(fresh), false)
+
+	// Once the old key is retired, its values can't be read.
+	retired := mustParseKeys(t, "2024b:"+newKey)
+	_, err = retired.Decrypt(ciphertext, nil)
+	assert.Equal(t, errors.Is(err, ErrUnknownKey), true)
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	key, _ := GenerateKey()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Empty", " , ", "no keys given"},
+		{"Missing ID", key, "id:base64-key"},
+		{"Bad base64", "a:not base64!", `key "a"`},
+		{"Short key", "a:c2hvcnQ=", "is 5 bytes, not 32"},
+		{"Duplicate ID", "a:" + key + ",a:" + key, `duplicate key ID "a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeys(tt.input)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package diff compares two texts line by line, for showing side by side or
+// as a unified diff. Lines which were changed rather than replaced outright
+// are also compared word by word, so that the parts which changed can be
+// highlighted.
+package diff
+
+import (
+	"github.com/pmezard/go-difflib/difflib"
+	"strings"
+	"unicode"
+)
+
+// minSimilarity is how alike, from 0 to 1, a deleted line and the inserted
+// line opposite it must be for the words which changed to be highlighted.
+// Below it the lines have little in common, and highlighting most of both
+// would only be noise.
+const minSimilarity = 0.4
+
+// Kind says whether a line is in both texts or only one of them.
+type Kind int
+
+const (
+	Equal Kind = iota
+	Deleted
+	Inserted
+)
+
+// Span is a run of text within a line. Changed spans are the parts of a
+// changed line which differ from the line opposite it.
+type Span struct {
+	Text    string
+	Changed bool
+}
+
+// Line is a line of one of the texts. OldNumber and NewNumber are its line
+// numbers, counting from 1, in the texts it's part of; the other is 0.
+type Line struct {
+	Kind      Kind
+	OldNumber int
+	NewNumber int
+	Spans     []Span
+}
+
+// Class names the line's kind, for styling it.
+func (l *Line) Class() string {
+	switch l.Kind {
+	case Deleted:
+		return "deleted"
+	case Inserted:
+		return "inserted"
+	default:
+		return "equal"
+	}
+}
+
+// Row is a row of a side-by-side diff. Old or New is nil where a line was
+// inserted or deleted with nothing opposite it. Rows of unchanged lines have
+// the same line on both sides.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+// Result is the difference between two texts.
+type Result struct {
+	Rows       []Row
+	Insertions int
+	Deletions  int
+}
+
+// Changed reports whether the texts are different.
+func (r *Result) Changed() bool {
+	return r.Insertions > 0 || r.Deletions > 0
+}
+
+// Unified returns the lines of the diff one after another, with the deleted
+// lines of each change before the inserted ones.
+func (r *Result) Unified() []*Line {
+	var lines, inserted []*Line
+
+	for _, row := range r.Rows {
+		if row.Old != nil && row.Old == row.New {
+			lines = append(lines, inserted...)
+			inserted = inserted[:0]
+			lines = append(lines, row.Old)
+			continue
+		}
+		if row.Old != nil {
+			lines = append(lines, row.Old)
+		}
+		if row.New != nil {
+			inserted = append(inserted, row.New)
+		}
+	}
+
+	return append(lines, inserted...)
+}
+
+// Compare compares old with new.
+func Compare(old, new string) *Result {
+	a, b := splitLines(old), splitLines(new)
+	r := &Result{}
+
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, op := range m.GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			for i, j := op.I1, op.J1; i < op.I2; i, j = i+1, j+1 {
+				l := &Line{Kind: Equal, OldNumber: i + 1, NewNumber: j + 1, Spans: []Span{{Text: a[i]}}}
+				r.Rows = append(r.Rows, Row{Old: l, New: l})
+			}
+			continue
+		case 'r':
+			// The lines of a replaced block are paired up in order, and
+			// compared word by word; any left over are on their own.
+			for i, j := op.I1, op.J1; i < op.I2 || j < op.J2; i, j = i+1, j+1 {
+				var row Row
+				switch {
+				case i < op.I2 && j < op.J2:
+					row.Old, row.New = changedLines(a[i], b[j])
+				case i < op.I2:
+					row.Old = &Line{Spans: []Span{{Text: a[i]}}}
+				default:
+					row.New = &Line{Spans: []Span{{Text: b[j]}}}
+				}
+				r.Rows = append(r.Rows, number(row, i+1, j+1))
+			}
+		case 'd':
+			for i := op.I1; i < op.I2; i++ {
+				r.Rows = append(r.Rows, number(Row{Old: &Line{Spans: []Span{{Text: a[i]}}}}, i+1, 0))
+			}
+		case 'i':
+			for j := op.J1; j < op.J2; j++ {
+				r.Rows = append(r.Rows, number(Row{New: &Line{Spans: []Span{{Text: b[j]}}}}, 0, j+1))
+			}
+		}
+	}
+
+	for _, row := range r.Rows {
+		if row.Old != nil && row.Old != row.New {
+			r.Deletions++
+		}
+		if row.New != nil && row.New != row.Old {
+			r.Insertions++
+		}
+	}
+
+	return r
+}
+
+// number fills in the kinds and line numbers of a row of changed lines.
+func number(row Row, oldNumber, newNumber int) Row {
+	if row.Old != nil {
+		row.Old.Kind, row.Old.OldNumber = Deleted, oldNumber
+	}
+	if row.New != nil {
+		row.New.Kind, row.New.NewNumber = Inserted, newNumber
+	}
+	return row
+}
+
+// changedLines compares a deleted line with the inserted line opposite it
+// word by word, and returns them split into spans.
+func changedLines(old, new string) (*Line, *Line) {
+	a, b := tokenize(old), tokenize(new)
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+
+	if m.Ratio() < minSimilarity {
+		return &Line{Spans: []Span{{Text: old}}}, &Line{Spans: []Span{{Text: new}}}
+	}
+
+	oldLine, newLine := &Line{}, &Line{}
+	for _, op := range m.GetOpCodes() {
+		changed := op.Tag != 'e'
+		oldLine.Spans = appendSpan(oldLine.Spans, strings.Join(a[op.I1:op.I2], ""), changed)
+		newLine.Spans = appendSpan(newLine.Spans, strings.Join(b[op.J1:op.J2], ""), changed)
+	}
+	return oldLine, newLine
+}
+
+// appendSpan adds text to spans, merging it into the last span if that was
+// changed or not in the same way.
+func appendSpan(spans []Span, text string, changed bool) []Span {
+	if text == "" {
+		return spans
+	}
+	if n := len(spans); n > 0 && spans[n-1].Changed == changed {
+		spans[n-1].Text += text
+		return spans
+	}
+	return append(spans, Span{Text: text, Changed: changed})
+}
+
+// splitLines splits text into lines, without their line endings. A final
+// line ending doesn't start another line.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// tokenize splits a line into words, runs of spaces and single other
+// characters, which are what changes within a line are made up of.
+func tokenize(line string) []string {
+	var tokens []string
+
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 0
+		}
+	}
+
+	start, prev := 0, -1
+	for i, r := range line {
+		c := class(r)
+		if i > start && (c != prev || c == 0) {
+			tokens = append(tokens, line[start:i])
+			start = i
+		}
+		prev = c
+	}
+	if start < len(line) {
+		tokens = append(tokens, line[start:])
+	}
+
+	return tokens
+}
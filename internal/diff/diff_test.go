@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+// render writes a unified diff in the usual format, with changed spans in
+// brackets.
+func render(lines []*Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.Kind {
+		case Deleted:
+			b.WriteString("-")
+		case Inserted:
+			b.WriteString("+")
+		default:
+			b.WriteString(" ")
+		}
+		for _, s := range l.Spans {
+			if s.Changed {
+				b.WriteString("[" + s.Text + "]")
+			} else {
+				b.WriteString(s.Text)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestCompare(t *testing.T) {
+	old := "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"
+	new := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello, world\")\n}\n"
+
+	r := Compare(old, new)
+	assert.Equal(t, r.Changed(), true)
+	assert.Equal(t, r.Insertions, 3)
+	assert.Equal(t, r.Deletions, 1)
+
+	want := " package main\n" +
+		" \n" +
+		"+import \"fmt\"\n" +
+		"+\n" +
+		" func main() {\n" +
+		"-\t[println](\"hello\")\n" +
+		"+\t[fmt.Println](\"hello[, world]\")\n" +
+		" }\n"
+	assert.Equal(t, render(r.Unified()), want)
+
+	// Side by side, the changed lines are opposite each other and the
+	// inserted ones have nothing opposite.
+	assert.Equal(t, len(r.Rows), 7)
+	assert.Equal(t, r.Rows[2].Old == nil, true)
+	assert.Equal(t, r.Rows[2].New.NewNumber, 3)
+	assert.Equal(t, r.Rows[5].Old.OldNumber, 4)
+	assert.Equal(t, r.Rows[5].New.NewNumber, 6)
+	assert.Equal(t, r.Rows[6].Old, r.Rows[6].New)
+	assert.Equal(t, r.Rows[6].Old.Class(), "equal")
+}
+
+func TestCompareUnrelatedLines(t *testing.T) {
+	// Lines with little in common aren't highlighted word by word.
+	r := Compare("one two three\nsame\n", "alpha beta\nsame\nextra")
+	assert.Equal(t, render(r.Unified()), "-one two three\n+alpha beta\n same\n+extra\n")
+}
+
+func TestCompareSame(t *testing.T) {
+	r := Compare("a\r\nb\r\n", "a\nb")
+	assert.Equal(t, r.Changed(), false)
+	assert.Equal(t, len(r.Rows), 2)
+
+	r = Compare("", "")
+	assert.Equal(t, len(r.Rows), 0)
+}
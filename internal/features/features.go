@@ -0,0 +1,242 @@
+// Package features implements runtime feature flags. Flags start from
+// built-in defaults, can be overridden from the command line, and are finally
+// overridden by any values persisted in the database, which is what the admin
+// UI writes to.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of the flags known to the application.
+const (
+	APIEnabled       = "api_enabled"
+	SignupOpen       = "signup_open"
+	SignupInviteOnly = "signup_invite_only"
+	ActivityPub      = "activitypub"
+	ReadOnly         = "read_only"
+	ProtectSource    = "protect_source"
+)
+
+// Signup modes, selected with the -signup-mode command-line flag or from the
+// admin UI. They are shorthands for combinations of the SignupOpen and
+// SignupInviteOnly flags.
+const (
+	SignupModeOpen   = "open"
+	SignupModeClosed = "closed"
+	SignupModeInvite = "invite"
+)
+
+// Flag describes a known feature flag.
+type Flag struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+// Known lists every flag which can be toggled, in the order they are shown in
+// the admin UI.
+var Known = []Flag{
+	{Name: APIEnabled, Description: "Serve the JSON API under /api/v1", Default: true},
+	{Name: SignupOpen, Description: "Allow new users to sign up", Default: true},
+	{Name: SignupInviteOnly, Description: "Require an invitation code to sign up", Default: false},
+	{Name: ActivityPub, Description: "Let Fediverse users follow the instance and send them new public snippets (needs -base-url)", Default: false},
+	{Name: ReadOnly, Description: "Archive the instance: snippets can still be read, but nothing can be created or changed except by admins", Default: false},
+	{Name: ProtectSource, Description: "For private deployments: snippets can only be read on their pages, with no raw views, downloads, API or feed access to their content, and search engines are asked not to index the site", Default: false},
+}
+
+// IsKnown reports whether name is one of the Known flags.
+func IsKnown(name string) bool {
+	for _, f := range Known {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists flag values. It is implemented by models.FeatureModel.
+type Store interface {
+	All() (map[string]bool, error)
+	Set(name string, enabled bool) error
+}
+
+// Flags holds the current value of every flag and is safe for concurrent use.
+type Flags struct {
+	mu        sync.RWMutex
+	store     Store
+	overrides map[string]bool
+	values    map[string]bool
+}
+
+// New returns a Flags initialized with the defaults from Known, overridden by
+// the given values (typically parsed from the -features command-line flag).
+// Call Load to apply the values persisted in the store.
+func New(store Store, overrides map[string]bool) *Flags {
+	f := &Flags{
+		store:     store,
+		overrides: overrides,
+	}
+	f.values = f.base()
+	return f
+}
+
+func (f *Flags) base() map[string]bool {
+	values := make(map[string]bool, len(Known))
+	for _, flag := range Known {
+		values[flag.Name] = flag.Default
+	}
+	for name, enabled := range f.overrides {
+		values[name] = enabled
+	}
+	return values
+}
+
+// Load reads the persisted flag values from the store and applies them on top
+// of the defaults and overrides.
+func (f *Flags) Load() error {
+	stored, err := f.store.All()
+	if err != nil {
+		return err
+	}
+
+	values := f.base()
+	for name, enabled := range stored {
+		values[name] = enabled
+	}
+
+	f.mu.Lock()
+	f.values = values
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Enabled reports whether the named flag is turned on. Unknown flags are
+// always off.
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.values[name]
+}
+
+// Set persists a new value for the named flag and applies it immediately.
+func (f *Flags) Set(name string, enabled bool) error {
+	if !IsKnown(name) {
+		return fmt.Errorf("features: unknown flag %q", name)
+	}
+
+	err := f.store.Set(name, enabled)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.values[name] = enabled
+	f.mu.Unlock()
+
+	return nil
+}
+
+// All returns a copy of the current flag values, suitable for passing to
+// templates.
+func (f *Flags) All() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	values := make(map[string]bool, len(f.values))
+	for name, enabled := range f.values {
+		values[name] = enabled
+	}
+	return values
+}
+
+// Parse parses a comma-separated list of flag settings such as
+// "api_enabled=false,signup_open". A name without a value means true.
+func Parse(s string) (map[string]bool, error) {
+	values := map[string]bool{}
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(field, "=")
+		enabled := true
+		if found {
+			var err error
+			enabled, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("features: invalid value for %q: %w", name, err)
+			}
+		}
+
+		if !IsKnown(name) {
+			return nil, fmt.Errorf("features: unknown flag %q (known flags: %s)", name, strings.Join(names(), ", "))
+		}
+
+		values[name] = enabled
+	}
+
+	return values, nil
+}
+
+func names() []string {
+	var names []string
+	for _, f := range Known {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SignupModeFlags returns the flag values which make up the given signup
+// mode.
+func SignupModeFlags(mode string) (map[string]bool, error) {
+	switch mode {
+	case SignupModeOpen:
+		return map[string]bool{SignupOpen: true, SignupInviteOnly: false}, nil
+	case SignupModeClosed:
+		return map[string]bool{SignupOpen: false, SignupInviteOnly: false}, nil
+	case SignupModeInvite:
+		return map[string]bool{SignupOpen: true, SignupInviteOnly: true}, nil
+	default:
+		return nil, fmt.Errorf("features: unknown signup mode %q (must be open, closed or invite)", mode)
+	}
+}
+
+// SignupMode returns the current signup mode.
+func (f *Flags) SignupMode() string {
+	switch {
+	case !f.Enabled(SignupOpen):
+		return SignupModeClosed
+	case f.Enabled(SignupInviteOnly):
+		return SignupModeInvite
+	default:
+		return SignupModeOpen
+	}
+}
+
+// SetSignupMode persists the flags for the given signup mode.
+func (f *Flags) SetSignupMode(mode string) error {
+	values, err := SignupModeFlags(mode)
+	if err != nil {
+		return err
+	}
+
+	// Set the flags in a fixed order so that a failure part way through
+	// never leaves signup open without the invitation requirement.
+	for _, name := range []string{SignupInviteOnly, SignupOpen} {
+		if err := f.Set(name, values[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
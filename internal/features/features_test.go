@@ -0,0 +1,70 @@
+package features
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+type memoryStore map[string]bool
+
+func (s memoryStore) All() (map[string]bool, error) {
+	return s, nil
+}
+
+func (s memoryStore) Set(name string, enabled bool) error {
+	s[name] = enabled
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	values, err := Parse("api_enabled=false, signup_open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, values[APIEnabled], false)
+	assert.Equal(t, values[SignupOpen], true)
+
+	_, err = Parse("no_such_flag")
+	assert.Equal(t, err != nil, true)
+
+	_, err = Parse("api_enabled=maybe")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestFlagsPrecedence(t *testing.T) {
+	store := memoryStore{SignupOpen: false}
+
+	f := New(store, map[string]bool{APIEnabled: false})
+
+	// Before loading, only the defaults and overrides apply.
+	assert.Equal(t, f.Enabled(APIEnabled), false)
+	assert.Equal(t, f.Enabled(SignupOpen), true)
+
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, f.Enabled(SignupOpen), false)
+
+	if err := f.Set(APIEnabled, true); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, f.Enabled(APIEnabled), true)
+	assert.Equal(t, store[APIEnabled], true)
+
+	assert.Equal(t, f.Set("no_such_flag", true) != nil, true)
+	assert.Equal(t, f.Enabled("no_such_flag"), false)
+}
+
+func TestSignupMode(t *testing.T) {
+	f := New(memoryStore{}, nil)
+	assert.Equal(t, f.SignupMode(), SignupModeOpen)
+
+	for _, mode := range []string{SignupModeInvite, SignupModeClosed, SignupModeOpen} {
+		if err := f.SetSignupMode(mode); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, f.SignupMode(), mode)
+	}
+
+	assert.Equal(t, f.SetSignupMode("sometimes") != nil, true)
+}
@@ -0,0 +1,135 @@
+// Package format tidies snippets in the languages it has a formatter for,
+// so that what's stored is laid out the way its language is usually
+// written. Unlike the lint package it changes content, so it's only ever
+// run when someone asks for it.
+//
+// Formatters are kept in a registry keyed by language name, as used by the
+// languages package. Go, JSON and SQL are registered to begin with.
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	gofmt "go/format"
+	"go/scanner"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by Format for a language which has no
+// formatter.
+var ErrUnsupported = errors.New("format: no formatter for this language")
+
+// Error is returned by a formatter for content it couldn't make sense of.
+// Line is the line the problem was found on, counting from 1, or 0 if the
+// formatter couldn't tell.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+// Formatter returns content written in its language laid out the usual
+// way. It's only called with content which isn't blank.
+type Formatter func(content string) (string, error)
+
+var (
+	mu         sync.RWMutex
+	formatters = map[string]Formatter{
+		"go":   formatGo,
+		"json": formatJSON,
+		"sql":  formatSQL,
+	}
+)
+
+// Register sets the formatter for a language, replacing any it already had.
+// A nil formatter removes it.
+func Register(language string, f Formatter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if f == nil {
+		delete(formatters, language)
+		return
+	}
+	formatters[language] = f
+}
+
+// Languages returns the names of the languages which have a formatter, in
+// alphabetical order.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format formats content written in the given language. It returns
+// ErrUnsupported if there's no formatter for the language, and an *Error if
+// the content couldn't be formatted. Blank content is returned as it is.
+func Format(language, content string) (string, error) {
+	mu.RLock()
+	f := formatters[language]
+	mu.RUnlock()
+
+	if f == nil {
+		return "", ErrUnsupported
+	}
+	if strings.TrimSpace(content) == "" {
+		return content, nil
+	}
+	return f(content)
+}
+
+// formatGo formats Go source the way gofmt does, so that a snippet can be
+// a whole file, a few declarations or just some statements.
+func formatGo(content string) (string, error) {
+	out, err := gofmt.Source([]byte(content))
+	if err == nil {
+		return string(out), nil
+	}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return "", &Error{Line: list[0].Pos.Line, Message: list[0].Msg}
+	}
+	return "", &Error{Message: err.Error()}
+}
+
+// formatJSON indents a single JSON value by two spaces a level.
+func formatJSON(content string) (string, error) {
+	var buf bytes.Buffer
+	err := json.Indent(&buf, []byte(strings.TrimSpace(content)), "", "  ")
+	if err == nil {
+		buf.WriteByte('\n')
+		return buf.String(), nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return "", &Error{Line: lineAt(content, syntaxErr.Offset), Message: syntaxErr.Error()}
+	}
+	return "", &Error{Message: err.Error()}
+}
+
+// lineAt returns the line which the byte at offset is on, allowing for the
+// space which formatJSON trims from the start.
+func lineAt(content string, offset int64) int {
+	trimmed := len(content) - len(strings.TrimLeft(content, " \t\r\n"))
+	offset = min(offset+int64(trimmed), int64(len(content)))
+	return strings.Count(content[:offset], "\n") + 1
+}
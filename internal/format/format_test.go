@@ -0,0 +1,93 @@
+package format
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+	}{
+		{"Go file", "go", "package main\nfunc main() {\nx:=1\n_ = x}\n", "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n"},
+		{"Go statements", "go", "x:=1\nfmt.Println( x )\n", "x := 1\nfmt.Println(x)\n"},
+		{"JSON", "json", `{"a":[1,2],"b":{}}`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n"},
+		{"SQL", "sql", "select id, title from snippets where expires > utc_timestamp() and id between 1 and 5 order by id desc",
+			"SELECT id,\n  title\nFROM snippets\nWHERE expires > utc_timestamp()\n  AND id BETWEEN 1 AND 5\nORDER BY id DESC\n"},
+		{"SQL joins", "sql", "select * from a left outer join b on a.id = b.a_id natural join c",
+			"SELECT *\nFROM a\nLEFT OUTER JOIN b ON a.id = b.a_id\nNATURAL JOIN c\n"},
+		{"SQL subquery", "sql", "delete from tags where snippet_id in (select id from snippets where title = 'it''s -- not a comment')",
+			"DELETE\nFROM tags\nWHERE snippet_id IN (\n  SELECT id\n  FROM snippets\n  WHERE title = 'it''s -- not a comment'\n)\n"},
+		{"SQL statements", "sql", "insert into tags (snippet_id, tag) values (1, 'go'); -- first\nupdate snippets set title = \"Go\"",
+			"INSERT INTO tags (snippet_id, tag)\nVALUES (1, 'go'); -- first\n\nUPDATE snippets\nSET title = \"Go\"\n"},
+		{"Blank", "json", "  \n", "  \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.language, tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, got, tt.want)
+
+			// Formatting again changes nothing.
+			again, err := Format(tt.language, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, again, got)
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		wantLine int
+	}{
+		{"Invalid Go", "go", "func main() {\n\tx := \n}\n", 3},
+		{"Invalid JSON", "json", "\n{\n  \"a\": 1,\n}\n", 4},
+		{"Unterminated SQL string", "sql", "select *\nfrom t where a = 'b", 2},
+		{"Unbalanced SQL", "sql", "select count(* from t", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Format(tt.language, tt.content)
+
+			var formatErr *Error
+			if !errors.As(err, &formatErr) {
+				t.Fatalf("got error %v; want an *Error", err)
+			}
+			assert.Equal(t, formatErr.Line, tt.wantLine)
+			assert.Equal(t, formatErr.Message != "", true)
+		})
+	}
+
+	_, err := Format("python", "def f(): pass")
+	assert.Equal(t, err, ErrUnsupported)
+}
+
+func TestRegister(t *testing.T) {
+	Register("text", func(content string) (string, error) {
+		return "formatted", nil
+	})
+	defer Register("text", nil)
+
+	got, err := Format("text", "anything")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got, "formatted")
+	assert.Equal(t, strings.Join(Languages(), " "), "go json sql text")
+
+	Register("text", nil)
+	_, err = Format("text", "anything")
+	assert.Equal(t, err, ErrUnsupported)
+}
@@ -0,0 +1,294 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// sqlKeywords are the words which formatSQL writes in upper case. Function
+// and type names are left as they were written.
+var sqlKeywords = setOf(
+	"ADD", "ALL", "ALTER", "AND", "AS", "ASC", "BETWEEN", "BY", "CASE",
+	"CHECK", "COLUMN", "CONSTRAINT", "CREATE", "CROSS", "DEFAULT", "DELETE",
+	"DESC", "DISTINCT", "DROP", "DUPLICATE", "ELSE", "END", "EXCEPT",
+	"EXISTS", "FALSE", "FOREIGN", "FROM", "FULL", "GROUP", "HAVING", "IF",
+	"IN", "INDEX", "INNER", "INSERT", "INTERSECT", "INTERVAL", "INTO", "IS",
+	"JOIN", "KEY", "LEFT", "LIKE", "LIMIT", "NATURAL", "NOT", "NULL",
+	"OFFSET", "ON", "OR", "ORDER", "OUTER", "OVER", "PARTITION", "PRIMARY",
+	"REFERENCES", "REPLACE", "RETURNING", "RIGHT", "SELECT", "SET", "TABLE",
+	"THEN", "TRUE", "UNION", "UNIQUE", "UPDATE", "USING", "VALUES", "VIEW",
+	"WHEN", "WHERE", "WITH",
+)
+
+// sqlClauses are the keywords which start a clause, and so a new line.
+var sqlClauses = setOf(
+	"CROSS", "EXCEPT", "FROM", "FULL", "GROUP", "HAVING", "INNER", "INTERSECT",
+	"JOIN", "LEFT", "LIMIT", "NATURAL", "OFFSET", "ORDER", "RETURNING",
+	"RIGHT", "SELECT", "SET", "UNION", "VALUES", "WHERE",
+)
+
+// sqlJoinModifiers are the keywords which can come before JOIN, on the same
+// line.
+var sqlJoinModifiers = setOf("CROSS", "FULL", "INNER", "LEFT", "NATURAL", "OUTER", "RIGHT")
+
+func setOf(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlQuoted
+	sqlLineComment
+	sqlBlockComment
+	sqlPunct
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// keyword returns the token in upper case if it's a keyword, or "" if not.
+func (t sqlToken) keyword() string {
+	if t.kind != sqlWord {
+		return ""
+	}
+	if upper := strings.ToUpper(t.text); sqlKeywords[upper] {
+		return upper
+	}
+	return ""
+}
+
+// tokenizeSQL splits SQL into words, quoted strings and identifiers,
+// comments and punctuation, dropping the space between them.
+func tokenizeSQL(content string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	line := 1
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		start := i
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+			continue
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+
+		case strings.HasPrefix(content[i:], "--") || c == '#':
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			i += end
+			tokens = append(tokens, sqlToken{sqlLineComment, strings.TrimRight(content[start:i], " \t\r")})
+
+		case strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				return nil, &Error{Line: line, Message: "comment not terminated"}
+			}
+			i += end + 4
+			line += strings.Count(content[start:i], "\n")
+			tokens = append(tokens, sqlToken{sqlBlockComment, content[start:i]})
+
+		case c == '\'' || c == '"' || c == '`':
+			// A quote is escaped by doubling it, or with a backslash in
+			// MySQL's strings.
+			i++
+			for {
+				if i >= len(content) {
+					return nil, &Error{Line: line, Message: "string not terminated"}
+				}
+				if content[i] == '\\' && c != '`' {
+					i += 2
+					continue
+				}
+				if content[i] == c {
+					if i+1 < len(content) && content[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			line += strings.Count(content[start:i], "\n")
+			tokens = append(tokens, sqlToken{sqlQuoted, content[start:i]})
+
+		case isSQLWordByte(c):
+			for i < len(content) && isSQLWordByte(content[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{sqlWord, content[start:i]})
+
+		case strings.IndexByte("<>=!|:&", c) >= 0:
+			for i < len(content) && strings.IndexByte("<>=!|:&", content[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, sqlToken{sqlPunct, content[start:i]})
+
+		default:
+			i++
+			tokens = append(tokens, sqlToken{sqlPunct, content[start:i]})
+		}
+	}
+
+	return tokens, nil
+}
+
+// isSQLWordByte reports whether c can be part of a keyword, an identifier,
+// a number or a placeholder like $1 or @name. Bytes of multi-byte UTF-8
+// characters count, so that identifiers in other scripts stay whole.
+func isSQLWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || c == '$' || c == '@' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// sqlScope is what formatSQL knows about the statement or parenthesised
+// part of one it's in.
+type sqlScope struct {
+	// query is set for a statement or a subquery, where clauses start new
+	// lines, and not for the parentheses around arguments or a list.
+	query bool
+	// clause is the clause the scope is in, such as SELECT or WHERE.
+	clause string
+	// between is set after BETWEEN, until the AND which goes with it.
+	between bool
+}
+
+// formatSQL lays out SQL statements with each clause on a line of its own,
+// the conditions joined by AND and OR and the columns of a SELECT on lines
+// of their own under them, and subqueries indented. Keywords are written in
+// upper case. It doesn't parse the SQL, so it works with any dialect, and
+// only gives up on unterminated strings and unbalanced parentheses.
+func formatSQL(content string) (string, error) {
+	tokens, err := tokenizeSQL(content)
+	if err != nil {
+		return "", err
+	}
+
+	var b []byte
+	scopes := []*sqlScope{{query: true}}
+	lineStart := true
+	blankLine := false
+
+	// newline starts a new line indented for the scope, plus extra levels.
+	// Starting one when already at the start of a line only changes its
+	// indentation.
+	newline := func(extra int) {
+		if len(b) == 0 {
+			return
+		}
+		if lineStart {
+			b = bytes.TrimRight(b, " ")
+		} else {
+			b = append(b, '\n')
+		}
+		b = append(b, strings.Repeat("  ", len(scopes)-1+extra)...)
+		lineStart = true
+	}
+
+	var prev sqlToken
+	for i, t := range tokens {
+		scope := scopes[len(scopes)-1]
+		kw := t.keyword()
+
+		// Statements are separated by a blank line, though a comment after
+		// the semicolon stays on its line.
+		if blankLine && t.kind != sqlLineComment {
+			if lineStart {
+				b = append(bytes.TrimRight(b, " "), '\n')
+			} else {
+				b = append(b, "\n\n"...)
+			}
+			lineStart, blankLine = true, false
+		}
+
+		switch {
+		case sqlClauses[kw] && scope.query && !sqlJoinModifiers[prev.keyword()]:
+			newline(0)
+			scope.clause = kw
+		case (kw == "AND" || kw == "OR") && scope.query && !scope.between:
+			newline(1)
+		case t.text == ")":
+			if len(scopes) == 1 {
+				return "", &Error{Message: "unexpected )"}
+			}
+			scopes = scopes[:len(scopes)-1]
+			if scope.query {
+				newline(0)
+			}
+		}
+
+		if !lineStart && needsSQLSpace(prev, t, tokens[:i]) {
+			b = append(b, ' ')
+		}
+		if kw != "" {
+			b = append(b, kw...)
+		} else {
+			b = append(b, t.text...)
+		}
+		lineStart = false
+
+		switch {
+		case kw == "BETWEEN":
+			scope.between = true
+		case kw == "AND" && scope.between:
+			scope.between = false
+		case t.text == "(":
+			subquery := i+1 < len(tokens) && (tokens[i+1].keyword() == "SELECT" || tokens[i+1].keyword() == "WITH")
+			scopes = append(scopes, &sqlScope{query: subquery})
+		case t.text == "," && scope.query && scope.clause == "SELECT":
+			newline(1)
+		case t.text == ";":
+			scopes = scopes[:1]
+			scopes[0].clause, scopes[0].between = "", false
+			blankLine = true
+		case t.kind == sqlLineComment:
+			newline(0)
+		}
+
+		prev = t
+	}
+
+	if len(scopes) > 1 {
+		return "", &Error{Message: "missing )"}
+	}
+
+	return strings.TrimSpace(string(b)) + "\n", nil
+}
+
+// needsSQLSpace reports whether a space goes between two tokens on the same
+// line. before is every token before t, for telling a function call from a
+// table name followed by its columns.
+func needsSQLSpace(prev, t sqlToken, before []sqlToken) bool {
+	switch {
+	case t.text == "," || t.text == ";" || t.text == ")" || t.text == ".":
+		return false
+	case prev.text == "(" || prev.text == ".":
+		return false
+	case t.text == "(" && prev.kind == sqlWord && prev.keyword() == "":
+		// A function call, unless it's the table in INSERT INTO t (...) or
+		// CREATE TABLE t (...).
+		if n := len(before); n >= 2 {
+			switch before[n-2].keyword() {
+			case "INTO", "TABLE", "EXISTS":
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
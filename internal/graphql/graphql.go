@@ -0,0 +1,626 @@
+// Package graphql is a small GraphQL server, with the schema written in Go.
+// It supports enough of the language for clients to fetch and change what
+// they need in one request: queries and mutations, with arguments,
+// variables and aliases. Fragments, directives, subscriptions and
+// introspection aren't supported.
+//
+// Fields are resolved a level at a time, across every object at that level,
+// and a resolver can return a Thunk instead of a value. The thunks at a level
+// aren't forced until every field at the level has been resolved, so a
+// Loader can fetch whatever they need in one query rather than one per
+// object.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Type is the type of a field or an argument: a *Scalar, an *Object, a
+// List or a NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a type whose values are leaves of the response, like Int.
+type Scalar struct {
+	Name string
+	// Coerce turns an argument's value, as written in the query or given
+	// as a variable, into the value resolvers get.
+	Coerce func(v any) (any, error)
+	// Serialize turns a resolved value into what the response holds. It
+	// may be nil if resolved values go into the response as they are.
+	Serialize func(v any) any
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Object is a type with fields of its own.
+type Object struct {
+	Name   string
+	Fields Fields
+}
+
+func (o *Object) String() string { return o.Name }
+
+// Fields are an object's fields, by name.
+type Fields map[string]*Field
+
+// List is a list of values of another type.
+type List struct {
+	Of Type
+}
+
+func (l List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull is a type whose values can't be null.
+type NonNull struct {
+	Of Type
+}
+
+func (n NonNull) String() string { return n.Of.String() + "!" }
+
+// Field is a field of an object.
+type Field struct {
+	Type    Type
+	Args    Args
+	Resolve Resolver
+	// Cost is what resolving the field adds to a query's complexity. Zero
+	// means one.
+	Cost int
+	// Size is roughly how many items a list field returns when it doesn't
+	// have a "first" argument saying so. Zero means one.
+	Size int
+}
+
+// Args are a field's arguments, by name.
+type Args map[string]*Arg
+
+// Arg is one of a field's arguments. Arguments which aren't given take
+// their Default, if it isn't nil.
+type Arg struct {
+	Type    Type
+	Default any
+}
+
+// Resolver works out a field's value. It may return a Thunk to be forced
+// once the rest of the fields at its level have been resolved.
+type Resolver func(p Params) (any, error)
+
+// Params are what a resolver is given.
+type Params struct {
+	Context context.Context
+	// Source is the value of the object the field belongs to. For fields
+	// of the Query and Mutation types, it's nil.
+	Source any
+	Args   map[string]any
+}
+
+// Thunk is a value which isn't ready yet.
+type Thunk func() (any, error)
+
+// Schema describes what can be queried and how.
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+	// MaxDepth is how deeply selections can be nested, and MaxComplexity
+	// how costly a query can be, as worked out from the costs and sizes of
+	// its fields. Zero means there's no limit.
+	MaxDepth      int
+	MaxComplexity int
+	// InternalError is called with the errors resolvers return, other than
+	// those made with Errorf, and returns the message the client is shown
+	// instead. If it's nil, they're shown "internal error".
+	InternalError func(err error) string
+}
+
+// Request is a request as clients send it. Extensions are accepted, since
+// some clients always send them, but ignored.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
+
+// Response is the result of a request. Data is nil if the request couldn't
+// be run at all.
+type Response struct {
+	Data   *Map     `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is an error in a response. Path is where in the data it happened,
+// if it happened while resolving a field.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(path, "."), e.Message)
+}
+
+// Errorf returns an error whose message is safe to show the client.
+func Errorf(format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf(format, args...)}
+}
+
+// Execute runs a request against the schema.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{asError(err)}}
+	}
+
+	// A document with one operation can be run without naming it.
+	op, ok := doc.operations[req.OperationName]
+	if !ok && req.OperationName == "" && len(doc.operations) == 1 {
+		for _, only := range doc.operations {
+			op, ok = only, true
+		}
+	}
+	if !ok {
+		if req.OperationName == "" {
+			return failed("the document has more than one operation, so operationName is needed")
+		}
+		return failed("there's no operation named %q", req.OperationName)
+	}
+
+	root := s.Query
+	if op.kind == "mutation" {
+		root = s.Mutation
+	}
+	if root == nil {
+		return failed("%ss aren't supported", op.kind)
+	}
+
+	vars, err := coerceVariables(op.variables, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{asError(err)}}
+	}
+
+	v := &validator{vars: vars, defined: map[string]bool{}}
+	for _, def := range op.variables {
+		v.defined[def.name] = true
+	}
+	v.selection(root, op.selection, 1)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+	if s.MaxDepth > 0 && v.depth > s.MaxDepth {
+		return failed("the query is nested %d levels deep, more than the limit of %d", v.depth, s.MaxDepth)
+	}
+	if c := complexity(root, op.selection, vars); s.MaxComplexity > 0 && c > s.MaxComplexity {
+		return failed("the query's complexity is %d, more than the limit of %d", c, s.MaxComplexity)
+	}
+
+	e := &executor{schema: s, ctx: ctx, vars: vars}
+	data := &Map{}
+	e.run([]task{{obj: root, selection: op.selection, out: data}})
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func failed(format string, args ...any) *Response {
+	return &Response{Errors: []*Error{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+// scalars are the types variables can be declared with.
+var scalars = map[string]*Scalar{}
+
+func init() {
+	for _, s := range []*Scalar{Int, Float, String, Boolean, ID} {
+		scalars[s.Name] = s
+	}
+}
+
+// coerceVariables checks the variables given against their definitions,
+// filling in defaults.
+func coerceVariables(defs []*variableDefinition, given map[string]any) (map[string]any, error) {
+	vars := map[string]any{}
+	for _, def := range defs {
+		typ, err := resolveTypeRef(def.typ)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := given[def.name]
+		if !ok && def.hasDef {
+			value, ok = def.defValue, true
+		}
+		if !ok {
+			if _, nonNull := typ.(NonNull); nonNull {
+				return nil, Errorf("variable $%s is required", def.name)
+			}
+			continue
+		}
+
+		if vars[def.name], err = coerce(typ, value, nil); err != nil {
+			return nil, Errorf("variable $%s: %v", def.name, err)
+		}
+	}
+	return vars, nil
+}
+
+func resolveTypeRef(ref typeRef) (Type, error) {
+	var t Type
+	if ref.list != nil {
+		of, err := resolveTypeRef(*ref.list)
+		if err != nil {
+			return nil, err
+		}
+		t = List{Of: of}
+	} else {
+		s, ok := scalars[ref.name]
+		if !ok {
+			return nil, Errorf("unknown variable type %q", ref.name)
+		}
+		t = s
+	}
+	if ref.nonNull {
+		t = NonNull{Of: t}
+	}
+	return t, nil
+}
+
+// coerce turns an argument's value into one of the given type, looking up
+// any variables in it.
+func coerce(t Type, value any, vars map[string]any) (any, error) {
+	if name, ok := value.(variable); ok {
+		value = vars[string(name)]
+	}
+
+	switch t := t.(type) {
+	case NonNull:
+		if value == nil {
+			return nil, fmt.Errorf("expected a value of type %s, found null", t)
+		}
+		return coerce(t.Of, value, vars)
+	case List:
+		if value == nil {
+			return nil, nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			v, err := coerce(t.Of, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	case *Scalar:
+		if value == nil {
+			return nil, nil
+		}
+		if _, ok := value.(enumValue); ok {
+			return nil, fmt.Errorf("expected a value of type %s, found %s", t, value)
+		}
+		return t.Coerce(value)
+	}
+	return nil, fmt.Errorf("%s can't be used as an argument", t)
+}
+
+// validator checks a query against the schema before it's run, and works out
+// how deeply it's nested.
+type validator struct {
+	vars    map[string]any
+	defined map[string]bool
+	depth   int
+	errors  []*Error
+}
+
+func (v *validator) errorf(sel *selection, format string, args ...any) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf("line %d: ", sel.line) + fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) selection(obj *Object, sels []*selection, depth int) {
+	v.depth = max(v.depth, depth)
+
+	for _, sel := range sels {
+		if sel.name == "__typename" {
+			continue
+		}
+
+		f, ok := obj.Fields[sel.name]
+		if !ok {
+			v.errorf(sel, "%s has no field %q", obj.Name, sel.name)
+			continue
+		}
+
+		defined := true
+		for _, a := range sel.arguments {
+			defined = v.variables(sel, a.value) && defined
+		}
+		if _, err := arguments(f, sel, v.vars); err != nil && defined {
+			v.errorf(sel, "%v", err)
+		}
+
+		if child, ok := unwrap(f.Type).(*Object); ok {
+			if sel.selection == nil {
+				v.errorf(sel, "%s.%s is of type %s, so it needs a selection of fields", obj.Name, sel.name, f.Type)
+				continue
+			}
+			v.selection(child, sel.selection, depth+1)
+		} else if sel.selection != nil {
+			v.errorf(sel, "%s.%s is of type %s, so it can't have a selection of fields", obj.Name, sel.name, f.Type)
+		}
+	}
+}
+
+// variables checks that the variables an argument's value refers to are
+// defined by the operation, and reports whether they are.
+func (v *validator) variables(sel *selection, value any) bool {
+	switch value := value.(type) {
+	case variable:
+		if !v.defined[string(value)] {
+			v.errorf(sel, "variable $%s isn't defined", value)
+			return false
+		}
+	case []any:
+		ok := true
+		for _, item := range value {
+			ok = v.variables(sel, item) && ok
+		}
+		return ok
+	}
+	return true
+}
+
+// arguments works out the arguments a field is resolved with.
+func arguments(f *Field, sel *selection, vars map[string]any) (map[string]any, error) {
+	args := map[string]any{}
+	for _, a := range sel.arguments {
+		def, ok := f.Args[a.name]
+		if !ok {
+			return nil, fmt.Errorf("%s has no argument %q", sel.name, a.name)
+		}
+		v, err := coerce(def.Type, a.value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q of %s: %v", a.name, sel.name, err)
+		}
+		if v != nil {
+			args[a.name] = v
+		}
+	}
+
+	for name, def := range f.Args {
+		if _, ok := args[name]; ok {
+			continue
+		}
+		if def.Default != nil {
+			args[name] = def.Default
+		} else if _, nonNull := def.Type.(NonNull); nonNull {
+			return nil, fmt.Errorf("%s needs the argument %q", sel.name, name)
+		}
+	}
+	return args, nil
+}
+
+// unwrap returns the type of the values a field's values are, or hold.
+func unwrap(t Type) Type {
+	for {
+		switch u := t.(type) {
+		case NonNull:
+			t = u.Of
+		case List:
+			t = u.Of
+		default:
+			return t
+		}
+	}
+}
+
+// complexity works out how costly a selection is: the cost of each field,
+// with those of fields inside lists counted once for each item the lists
+// are expected to hold.
+func complexity(obj *Object, sels []*selection, vars map[string]any) int {
+	total := 0
+	for _, sel := range sels {
+		f, ok := obj.Fields[sel.name]
+		if !ok {
+			continue
+		}
+		total += max(f.Cost, 1)
+
+		child, ok := unwrap(f.Type).(*Object)
+		if !ok {
+			continue
+		}
+		n := 1
+		if isList(f.Type) {
+			n = max(f.Size, 1)
+			if args, err := arguments(f, sel, vars); err == nil {
+				if first, ok := args["first"].(int); ok {
+					n = max(first, 0)
+				}
+			}
+		}
+		total += n * complexity(child, sel.selection, vars)
+	}
+	return total
+}
+
+func isList(t Type) bool {
+	if n, ok := t.(NonNull); ok {
+		t = n.Of
+	}
+	_, ok := t.(List)
+	return ok
+}
+
+// executor runs a validated query.
+type executor struct {
+	schema *Schema
+	ctx    context.Context
+	vars   map[string]any
+	errors []*Error
+}
+
+// task is an object whose fields are to be resolved into out.
+type task struct {
+	obj       *Object
+	selection []*selection
+	source    any
+	out       *Map
+	path      []any
+}
+
+// cell is a field being resolved.
+type cell struct {
+	task  *task
+	sel   *selection
+	field *Field
+	value any
+	err   error
+}
+
+func (e *executor) errorf(path []any, err error) {
+	var msg string
+	var safe *Error
+	switch {
+	case errors.As(err, &safe):
+		msg = safe.Message
+	case e.schema.InternalError != nil:
+		msg = e.schema.InternalError(err)
+	default:
+		msg = "internal error"
+	}
+	e.errors = append(e.errors, &Error{Message: msg, Path: path})
+}
+
+// run resolves the fields of the tasks, then of the objects those fields
+// hold, and so on, a level at a time.
+func (e *executor) run(tasks []task) {
+	for len(tasks) > 0 {
+		var cells []*cell
+		for i := range tasks {
+			t := &tasks[i]
+			for _, sel := range t.selection {
+				if sel.name == "__typename" {
+					t.out.Set(sel.key(), t.obj.Name)
+					continue
+				}
+
+				c := &cell{task: t, sel: sel, field: t.obj.Fields[sel.name]}
+				t.out.Set(sel.key(), nil)
+				cells = append(cells, c)
+
+				args, err := arguments(c.field, sel, e.vars)
+				if err != nil {
+					c.err = err
+					continue
+				}
+				if c.field.Resolve == nil {
+					c.value, c.err = defaultResolve(t.source, sel.name)
+					continue
+				}
+				c.value, c.err = c.field.Resolve(Params{Context: e.ctx, Source: t.source, Args: args})
+			}
+		}
+
+		for _, c := range cells {
+			if thunk, ok := c.value.(Thunk); ok && c.err == nil {
+				c.value, c.err = thunk()
+			}
+		}
+
+		var next []task
+		for _, c := range cells {
+			path := append(append([]any{}, c.task.path...), c.sel.key())
+			if c.err != nil {
+				e.errorf(path, c.err)
+				continue
+			}
+			c.task.out.Set(c.sel.key(), e.complete(c.field.Type, c.value, c.sel, path, &next))
+		}
+		tasks = next
+	}
+}
+
+// complete turns a resolved value into what the response holds. Objects are
+// returned empty, with tasks added to next to fill them in.
+func (e *executor) complete(t Type, value any, sel *selection, path []any, next *[]task) any {
+	switch t := t.(type) {
+	case NonNull:
+		v := e.complete(t.Of, value, sel, path, next)
+		if v == nil {
+			e.errorf(path, Errorf("%s can't be null", sel.key()))
+		}
+		return v
+	case List:
+		if isNil(value) {
+			return nil
+		}
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice {
+			e.errorf(path, fmt.Errorf("graphql: %s resolved to %T, not a slice", sel.key(), value))
+			return nil
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			p := append(append([]any{}, path...), i)
+			list[i] = e.complete(t.Of, rv.Index(i).Interface(), sel, p, next)
+		}
+		return list
+	case *Scalar:
+		if isNil(value) {
+			return nil
+		}
+		if t.Serialize != nil {
+			return t.Serialize(value)
+		}
+		return value
+	case *Object:
+		if isNil(value) {
+			return nil
+		}
+		out := &Map{}
+		*next = append(*next, task{obj: t, selection: sel.selection, source: value, out: out, path: path})
+		return out
+	}
+	return nil
+}
+
+// defaultResolve resolves a field without a resolver of its own from a
+// key of the same name, if the object's value is a map.
+func defaultResolve(source any, name string) (any, error) {
+	if m, ok := source.(map[string]any); ok {
+		return m[name], nil
+	}
+	return nil, fmt.Errorf("graphql: no resolver for %s on %T", name, source)
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
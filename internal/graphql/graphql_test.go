@@ -0,0 +1,229 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+type testBook struct {
+	ID       int
+	Title    string
+	AuthorID int
+}
+
+type testAuthor struct {
+	ID   int
+	Name string
+}
+
+// testSchema returns a schema of books and their authors, and a count of
+// how many times the authors have been fetched.
+func testSchema() (*Schema, *int) {
+	fetches := 0
+	books := []*testBook{{1, "Emma", 1}, {2, "Persuasion", 1}, {3, "Middlemarch", 2}}
+	authors := map[int]*testAuthor{1: {1, "Jane Austen"}, 2: {2, "George Eliot"}}
+
+	// A real loader would be made for each request; one will do here.
+	loader := NewLoader(func(ids []int) (map[int]*testAuthor, error) {
+		fetches++
+		found := map[int]*testAuthor{}
+		for _, id := range ids {
+			found[id] = authors[id]
+		}
+		return found, nil
+	})
+
+	author := &Object{Name: "Author", Fields: Fields{
+		"id": {Type: NonNull{ID}, Resolve: func(p Params) (any, error) {
+			return p.Source.(*testAuthor).ID, nil
+		}},
+		"name": {Type: NonNull{String}, Resolve: func(p Params) (any, error) {
+			return p.Source.(*testAuthor).Name, nil
+		}},
+	}}
+
+	book := &Object{Name: "Book", Fields: Fields{
+		"id": {Type: NonNull{ID}, Resolve: func(p Params) (any, error) {
+			return p.Source.(*testBook).ID, nil
+		}},
+		"title": {Type: NonNull{String}, Resolve: func(p Params) (any, error) {
+			return p.Source.(*testBook).Title, nil
+		}},
+		"author": {Type: author, Resolve: func(p Params) (any, error) {
+			return loader.Load(p.Source.(*testBook).AuthorID), nil
+		}},
+	}}
+
+	return &Schema{
+		Query: &Object{Name: "Query", Fields: Fields{
+			"books": {
+				Type: NonNull{List{NonNull{book}}},
+				Args: Args{"first": {Type: Int, Default: 10}},
+				Resolve: func(p Params) (any, error) {
+					return books[:min(p.Args["first"].(int), len(books))], nil
+				},
+			},
+			"book": {
+				Type: book,
+				Args: Args{"id": {Type: NonNull{ID}}},
+				Resolve: func(p Params) (any, error) {
+					for _, b := range books {
+						if b.ID == p.Args["id"] {
+							return b, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"broken": {Type: String, Resolve: func(p Params) (any, error) {
+				return nil, errors.New("the database is on fire")
+			}},
+		}},
+		Mutation: &Object{Name: "Mutation", Fields: Fields{
+			"addBook": {
+				Type: NonNull{book},
+				Args: Args{"title": {Type: NonNull{String}}},
+				Resolve: func(p Params) (any, error) {
+					if p.Args["title"] == "" {
+						return nil, Errorf("the title can't be blank")
+					}
+					b := &testBook{ID: len(books) + 1, Title: p.Args["title"].(string), AuthorID: 2}
+					books = append(books, b)
+					return b, nil
+				},
+			},
+		}},
+		MaxDepth:      3,
+		MaxComplexity: 50,
+	}, &fetches
+}
+
+// run executes a request and returns its response as JSON.
+func run(t *testing.T, s *Schema, req Request) string {
+	t.Helper()
+	js, err := json.Marshal(s.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(js)
+}
+
+func TestExecute(t *testing.T) {
+	s, fetches := testSchema()
+
+	// Fields come back in the order they were asked for, under their
+	// aliases, and the authors of all the books are fetched at once.
+	got := run(t, s, Request{Query: `{
+		books { title, by: author { name } __typename }
+	}`})
+	assert.Equal(t, got, `{"data":{"books":[`+
+		`{"title":"Emma","by":{"name":"Jane Austen"},"__typename":"Book"},`+
+		`{"title":"Persuasion","by":{"name":"Jane Austen"},"__typename":"Book"},`+
+		`{"title":"Middlemarch","by":{"name":"George Eliot"},"__typename":"Book"}]}}`)
+	assert.Equal(t, *fetches, 1)
+
+	// Variables and defaults.
+	got = run(t, s, Request{
+		Query:     `query Get($id: ID!, $n: Int = 1) { book(id: $id) { id title } books(first: $n) { id } }`,
+		Variables: map[string]any{"id": "3"},
+	})
+	assert.Equal(t, got, `{"data":{"book":{"id":"3","title":"Middlemarch"},"books":[{"id":"1"}]}}`)
+
+	// Null objects, and errors from resolvers, with internal ones hidden.
+	got = run(t, s, Request{Query: `{ book(id: 9) { title } broken }`})
+	assert.Equal(t, got, `{"data":{"book":null,"broken":null},"errors":[{"message":"internal error","path":["broken"]}]}`)
+
+	s.InternalError = func(err error) string { return "oops: " + err.Error() }
+	got = run(t, s, Request{Query: `{ broken }`})
+	assert.Equal(t, got, `{"data":{"broken":null},"errors":[{"message":"oops: the database is on fire","path":["broken"]}]}`)
+}
+
+func TestMutation(t *testing.T) {
+	s, _ := testSchema()
+
+	got := run(t, s, Request{
+		Query:     `mutation Add($title: String!) { addBook(title: $title) { id author { name } } }`,
+		Variables: map[string]any{"title": "Silas Marner"},
+	})
+	assert.Equal(t, got, `{"data":{"addBook":{"id":"4","author":{"name":"George Eliot"}}}}`)
+
+	got = run(t, s, Request{Query: `mutation { addBook(title: "") { id } }`})
+	assert.Equal(t, got, `{"data":{"addBook":null},"errors":[{"message":"the title can't be blank","path":["addBook"]}]}`)
+}
+
+func TestInvalid(t *testing.T) {
+	s, _ := testSchema()
+
+	tests := []struct {
+		name  string
+		req   Request
+		error string
+	}{
+		{"Syntax", Request{Query: `{ books { title }`}, `syntax error on line 1: expected a name, found the end of the document`},
+		{"Fragment", Request{Query: `{ books { ...Parts } }`}, `syntax error on line 1: fragments aren't supported`},
+		{"Unknown field", Request{Query: `{ books { isbn } }`}, `line 1: Book has no field "isbn"`},
+		{"Missing selection", Request{Query: `{ books }`}, `line 1: Query.books is of type [Book!]!, so it needs a selection of fields`},
+		{"Scalar selection", Request{Query: `{ broken { x } }`}, `line 1: Query.broken is of type String, so it can't have a selection of fields`},
+		{"Missing argument", Request{Query: `{ book { id } }`}, `line 1: book needs the argument "id"`},
+		{"Wrong type", Request{Query: `{ books(first: "two") { id } }`}, `line 1: argument "first" of books: expected an Int, found two`},
+		{"Undefined variable", Request{Query: `{ book(id: $id) { id } }`}, `line 1: variable $id isn't defined`},
+		{"Missing variable", Request{Query: `query($id: ID!) { book(id: $id) { id } }`}, `variable $id is required`},
+		{"Unknown operation", Request{Query: `query A { broken }`, OperationName: "B"}, `there's no operation named "B"`},
+		{"At the depth limit", Request{Query: `{ book(id: 1) { author { name } } books { author { id } } }`}, ``},
+		{"Complexity", Request{Query: `{ books(first: 20) { title author { name } } }`}, `the query's complexity is 61, more than the limit of 50`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.Execute(context.Background(), tt.req)
+			if tt.error == "" {
+				assert.Equal(t, len(resp.Errors), 0)
+				return
+			}
+			if resp.Data != nil || len(resp.Errors) != 1 {
+				t.Fatalf("got %d errors and data %v; want one error and no data", len(resp.Errors), resp.Data)
+			}
+			assert.Equal(t, resp.Errors[0].Message, tt.error)
+		})
+	}
+
+	s.MaxDepth = 1
+	resp := s.Execute(context.Background(), Request{Query: `{ book(id: 1) { author { name } } }`})
+	assert.Equal(t, resp.Errors[0].Message, "the query is nested 3 levels deep, more than the limit of 1")
+}
+
+func TestLoader(t *testing.T) {
+	var batches [][]string
+	l := NewLoader(func(keys []string) (map[string]int, error) {
+		batches = append(batches, keys)
+		if keys[0] == "bad" {
+			return nil, errors.New("failed")
+		}
+		m := map[string]int{}
+		for _, k := range keys {
+			m[k] = len(k)
+		}
+		return m, nil
+	})
+
+	a, b, a2 := l.Load("a"), l.Load("bb"), l.Load("a")
+	v, _ := b()
+	assert.Equal(t, v, any(2))
+	v, _ = a()
+	assert.Equal(t, v, any(1))
+	v, _ = a2()
+	assert.Equal(t, v, any(1))
+	assert.Equal(t, len(batches), 1)
+	assert.Equal(t, len(batches[0]), 2)
+
+	// Cached values aren't fetched again.
+	v, _ = l.Load("bb")()
+	assert.Equal(t, v, any(2))
+	assert.Equal(t, len(batches), 1)
+
+	_, err := l.Load("bad")()
+	assert.Equal(t, err.Error(), "failed")
+}
@@ -0,0 +1,62 @@
+package graphql
+
+// Loader fetches values by key in batches. Load returns a Thunk, and the
+// first of the thunks to be forced fetches the values for every key loaded
+// since the last batch, with one call to the fetch function. Values are
+// cached for the life of the loader, which should be one request.
+//
+// A Loader isn't safe to use from more than one goroutine, and needn't be:
+// a request's fields are resolved one at a time.
+type Loader[K comparable, V any] struct {
+	fetch   func(keys []K) (map[K]V, error)
+	pending []K
+	cache   map[K]V
+	errs    map[K]error
+}
+
+// NewLoader returns a loader which fetches values with fetch. Keys which
+// fetch leaves out of the map it returns get the zero value.
+func NewLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, cache: map[K]V{}, errs: map[K]error{}}
+}
+
+// Load returns a thunk for the value of key.
+func (l *Loader[K, V]) Load(key K) Thunk {
+	_, cached := l.cache[key]
+	_, failed := l.errs[key]
+	if !cached && !failed {
+		l.pending = append(l.pending, key)
+	}
+
+	return func() (any, error) {
+		if len(l.pending) > 0 {
+			l.flush()
+		}
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.cache[key], nil
+	}
+}
+
+// flush fetches the values of the pending keys.
+func (l *Loader[K, V]) flush() {
+	keys := make([]K, 0, len(l.pending))
+	seen := map[K]bool{}
+	for _, k := range l.pending {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	l.pending = nil
+
+	values, err := l.fetch(keys)
+	for _, k := range keys {
+		if err != nil {
+			l.errs[k] = err
+			continue
+		}
+		l.cache[k] = values[k]
+	}
+}
@@ -0,0 +1,518 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed request: the operations in it, by name. An
+// operation without a name is stored under "".
+type document struct {
+	operations map[string]*operation
+}
+
+// operation is a query or a mutation.
+type operation struct {
+	kind      string
+	name      string
+	variables []*variableDefinition
+	selection []*selection
+}
+
+type variableDefinition struct {
+	name     string
+	typ      typeRef
+	defValue any
+	hasDef   bool
+}
+
+// typeRef is a type as written in a variable definition, like "[Int!]".
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+// selection is a field in a selection set.
+type selection struct {
+	alias     string
+	name      string
+	arguments []*argument
+	selection []*selection
+	line      int
+}
+
+// key is the name the field's value has in the response.
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value any
+}
+
+// variable is a reference to a variable in an argument's value.
+type variable string
+
+// enumValue is a bare name in an argument's value.
+type enumValue string
+
+// The kinds of token.
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	line  int
+}
+
+// lex splits a request into tokens. Commas, like white space and comments,
+// are ignored.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokenPunct, "...", line})
+			i += 3
+		case strings.ContainsRune("!$()[]{}:=@|", rune(c)):
+			tokens = append(tokens, token{tokenPunct, string(c), line})
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, src[start:i], line})
+		case c == '-' || isDigit(c):
+			start, kind := i, tokenInt
+			i++
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				kind = tokenFloat
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				kind = tokenFloat
+				i++
+				if i < len(src) && (src[i] == '+' || src[i] == '-') {
+					i++
+				}
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind, src[start:i], line})
+		case c == '"':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, &Error{Message: fmt.Sprintf("syntax error on line %d: %v", line, err)}
+			}
+			tokens = append(tokens, token{tokenString, s, line})
+			i += n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, &Error{Message: fmt.Sprintf("syntax error on line %d: unexpected %q", line, r)}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", line}), nil
+}
+
+// lexString reads the string at the start of src, returning its value and
+// how many bytes it took up. Block strings aren't supported.
+func lexString(src string) (string, int, error) {
+	if strings.HasPrefix(src, `"""`) {
+		return "", 0, fmt.Errorf("block strings aren't supported")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			i++
+			if i == len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			switch e := src[i]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, fmt.Errorf("bad escape in string")
+				}
+				n, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("bad escape in string")
+				}
+				b.WriteRune(rune(n))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("bad escape in string")
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parser is a recursive descent parser for the parts of the GraphQL
+// language this package supports.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf("syntax error on line %d: ", p.peek().line) + fmt.Sprintf(format, args...)}
+}
+
+// is reports whether the next token is the given punctuator.
+func (p *parser) is(punct string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.value == punct
+}
+
+// skip consumes the given punctuator if it's next, and reports whether it
+// was.
+func (p *parser) skip(punct string) bool {
+	if p.is(punct) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.skip(punct) {
+		return p.errorf("expected %q, found %s", punct, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.peek()
+	if t.kind != tokenName {
+		return "", p.errorf("expected a name, found %s", describe(t))
+	}
+	p.pos++
+	return t.value, nil
+}
+
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "the end of the document"
+	}
+	return strconv.Quote(t.value)
+}
+
+// parse parses a request.
+func parse(src string) (*document, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	doc := &document{operations: map[string]*operation{}}
+
+	for p.peek().kind != tokenEOF {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := doc.operations[op.name]; ok {
+			if op.name == "" {
+				return nil, &Error{Message: "an anonymous operation must be the only operation in the document"}
+			}
+			return nil, &Error{Message: fmt.Sprintf("there's more than one operation named %q", op.name)}
+		}
+		doc.operations[op.name] = op
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "the document has no operations"}
+	}
+	if _, ok := doc.operations[""]; ok && len(doc.operations) > 1 {
+		return nil, &Error{Message: "an anonymous operation must be the only operation in the document"}
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query"}
+
+	if p.is("{") {
+		sel, err := p.selectionSet()
+		op.selection = sel
+		return op, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenName && (t.value == "query" || t.value == "mutation"):
+		op.kind = t.value
+		p.pos++
+	case t.kind == tokenName && t.value == "fragment":
+		return nil, p.errorf("fragments aren't supported")
+	case t.kind == tokenName && t.value == "subscription":
+		return nil, p.errorf("subscriptions aren't supported")
+	default:
+		return nil, p.errorf("expected an operation, found %s", describe(t))
+	}
+
+	if p.peek().kind == tokenName {
+		op.name = p.next().value
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			def, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, def)
+		}
+	}
+
+	if p.is("@") {
+		return nil, p.errorf("directives aren't supported")
+	}
+
+	sel, err := p.selectionSet()
+	op.selection = sel
+	return op, err
+}
+
+func (p *parser) variableDefinition() (*variableDefinition, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return nil, err
+	}
+
+	def := &variableDefinition{name: name, typ: typ}
+	if p.skip("=") {
+		def.defValue, err = p.value(true)
+		def.hasDef = true
+	}
+	return def, err
+}
+
+func (p *parser) typeRef() (typeRef, error) {
+	var t typeRef
+	if p.skip("[") {
+		of, err := p.typeRef()
+		if err != nil {
+			return t, err
+		}
+		if err = p.expect("]"); err != nil {
+			return t, err
+		}
+		t.list = &of
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return t, err
+		}
+		t.name = name
+	}
+	t.nonNull = p.skip("!")
+	return t, nil
+}
+
+func (p *parser) selectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var sels []*selection
+	for !p.skip("}") {
+		if p.is("...") {
+			return nil, p.errorf("fragments aren't supported")
+		}
+		sel, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+
+	if len(sels) == 0 {
+		return nil, p.errorf("a selection set can't be empty")
+	}
+	return sels, nil
+}
+
+func (p *parser) field() (*selection, error) {
+	sel := &selection{line: p.peek().line}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(":") {
+		sel.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	sel.name = name
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			argName, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.value(false)
+			if err != nil {
+				return nil, err
+			}
+			sel.arguments = append(sel.arguments, &argument{name: argName, value: value})
+		}
+	}
+
+	if p.is("@") {
+		return nil, p.errorf("directives aren't supported")
+	}
+
+	if p.is("{") {
+		sel.selection, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+// value parses an argument's value. Constant values, like the defaults of
+// variables, can't refer to variables.
+func (p *parser) value(constant bool) (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("bad integer %s", t.value)
+		}
+		return n, nil
+	case tokenFloat:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %s", t.value)
+		}
+		return f, nil
+	case tokenString:
+		return t.value, nil
+	case tokenName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(t.value), nil
+	case tokenPunct:
+		switch t.value {
+		case "$":
+			if constant {
+				return nil, p.errorf("variables can't be used here")
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			list := []any{}
+			for !p.skip("]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			return nil, p.errorf("input objects aren't supported")
+		}
+	}
+	p.pos--
+	return nil, p.errorf("expected a value, found %s", describe(t))
+}
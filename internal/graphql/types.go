@@ -0,0 +1,136 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The built-in scalar types. Resolvers for Int fields should return ints,
+// and those for ID fields ints or strings; IDs are always strings in the
+// response, and ints in arguments when they look like one. Values which have
+// already been coerced, like those of variables, coerce to themselves.
+var (
+	Int = &Scalar{Name: "Int", Coerce: func(v any) (any, error) {
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case int64:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		case float64:
+			// Variables decoded from JSON are always float64s.
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+		return nil, fmt.Errorf("expected an Int, found %v", v)
+	}}
+
+	Float = &Scalar{Name: "Float", Coerce: func(v any) (any, error) {
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+		return nil, fmt.Errorf("expected a Float, found %v", v)
+	}}
+
+	String = &Scalar{Name: "String", Coerce: func(v any) (any, error) {
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a String, found %v", v)
+	}}
+
+	Boolean = &Scalar{Name: "Boolean", Coerce: func(v any) (any, error) {
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a Boolean, found %v", v)
+	}}
+
+	ID = &Scalar{
+		Name: "ID",
+		Coerce: func(v any) (any, error) {
+			switch id := v.(type) {
+			case string:
+				// Only numbers written the way Serialize writes them are
+				// read as numbers, so that IDs like "007" keep their zeros.
+				if n, err := strconv.Atoi(id); err == nil && strconv.Itoa(n) == id {
+					return n, nil
+				}
+				return id, nil
+			case int:
+				return id, nil
+			case int64:
+				return int(id), nil
+			case float64:
+				if id == math.Trunc(id) {
+					return int(id), nil
+				}
+			}
+			return nil, fmt.Errorf("expected an ID, found %v", v)
+		},
+		Serialize: func(v any) any {
+			return fmt.Sprint(v)
+		},
+	}
+)
+
+// Map is an object in a response. Its keys are kept in the order they were
+// set, which is the order they were selected in.
+type Map struct {
+	keys   []string
+	values map[string]any
+}
+
+// Set sets the value of a key, adding the key if it's new.
+func (m *Map) Set(key string, value any) {
+	if m.values == nil {
+		m.values = map[string]any{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of a key.
+func (m *Map) Get(key string) any {
+	return m.values[key]
+}
+
+// Keys returns the keys in the order they were set.
+func (m *Map) Keys() []string {
+	return m.keys
+}
+
+func (m *Map) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
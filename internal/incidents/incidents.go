@@ -0,0 +1,173 @@
+// Package incidents tells people about panics in the web application. Each
+// Notifier sends an incident somewhere: to a Sentry-compatible error tracker,
+// a generic webhook or by email.
+package incidents
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/mailer"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notifier sends an incident somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, incident *models.Incident) error
+}
+
+// referenceAlphabet leaves out characters which are easily confused with
+// each other, as people read references out over the phone.
+const referenceAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// NewReference returns a random 10 character reference code for an incident.
+func NewReference() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = referenceAlphabet[int(b[i])%len(referenceAlphabet)]
+	}
+	return string(b), nil
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// post sends body as JSON, treating any non-2xx response as an error.
+func post(ctx context.Context, url string, header http.Header, body any) error {
+	js, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("incidents: %s responded with %s", req.URL.Host, res.Status)
+	}
+	return nil
+}
+
+// Webhook posts incidents as JSON to a URL.
+type Webhook struct {
+	URL string
+}
+
+func (n *Webhook) Notify(ctx context.Context, incident *models.Incident) error {
+	return post(ctx, n.URL, nil, incident)
+}
+
+// Sentry sends incidents to Sentry, or anything else which accepts events
+// through Sentry's store API.
+type Sentry struct {
+	storeURL  string
+	publicKey string
+}
+
+// NewSentry configures a Sentry notifier from a DSN like
+// "https://<key>@<host>/<project>".
+func NewSentry(dsn string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	project := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || project == "" || u.Host == "" {
+		return nil, errors.New("incidents: Sentry DSN must look like https://key@host/project")
+	}
+
+	return &Sentry{
+		storeURL:  fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		publicKey: u.User.Username(),
+	}, nil
+}
+
+func (n *Sentry) Notify(ctx context.Context, incident *models.Incident) error {
+	eventID := make([]byte, 16)
+	if _, err := rand.Read(eventID); err != nil {
+		return err
+	}
+
+	event := map[string]any{
+		"event_id":  hex.EncodeToString(eventID),
+		"timestamp": incident.Created.UTC().Format(time.RFC3339),
+		"level":     "error",
+		"platform":  "go",
+		"logger":    "snippetbox",
+		"message":   incident.Message,
+		"request": map[string]any{
+			"method": incident.Method,
+			"url":    incident.Path,
+		},
+		"tags": map[string]string{
+			"reference":  incident.Reference,
+			"request_id": incident.RequestID,
+		},
+		"extra": map[string]string{
+			"stack": incident.Stack,
+		},
+	}
+	if incident.UserID != 0 {
+		event["user"] = map[string]any{"id": fmt.Sprint(incident.UserID)}
+	}
+
+	header := http.Header{}
+	header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=snippetbox/1.0, sentry_key=%s", n.publicKey))
+
+	return post(ctx, n.storeURL, header, event)
+}
+
+// Email sends incidents to a list of email addresses.
+type Email struct {
+	Mailer mailer.Sender
+	To     []string
+}
+
+func (n *Email) Notify(ctx context.Context, incident *models.Incident) error {
+	body := fmt.Sprintf("Reference: %s\nTime: %s\nRequest: %s %s\nRequest ID: %s\nUser ID: %d\n\n%s\n\n%s",
+		incident.Reference, incident.Created.UTC().Format(time.RFC1123), incident.Method, incident.Path,
+		incident.RequestID, incident.UserID, incident.Message, incident.Stack)
+
+	for _, to := range n.To {
+		err := n.Mailer.Send(mailer.Message{
+			To:      to,
+			Subject: fmt.Sprintf("Snippetbox incident %s: %s", incident.Reference, firstLine(incident.Message)),
+			Body:    body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstLine returns s up to its first newline, to keep email subjects on one
+// line.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
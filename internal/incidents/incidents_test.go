@@ -0,0 +1,102 @@
+package incidents
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReference(t *testing.T) {
+	ref, err := NewReference()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(ref), 10)
+	for _, c := range ref {
+		assert.Equal(t, strings.ContainsRune(referenceAlphabet, c), true)
+	}
+}
+
+func TestNewSentry(t *testing.T) {
+	s, err := NewSentry("https://abc123@sentry.example.com/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.storeURL, "https://sentry.example.com/api/42/store/")
+	assert.Equal(t, s.publicKey, "abc123")
+
+	for _, dsn := range []string{"https://sentry.example.com/42", "https://abc123@sentry.example.com/", "::"} {
+		_, err = NewSentry(dsn)
+		if err == nil {
+			t.Errorf("expected an error for %q", dsn)
+		}
+	}
+}
+
+var testIncident = &models.Incident{
+	Reference: "ABCDEFGHJK",
+	Message:   "boom",
+	Method:    http.MethodGet,
+	Path:      "/",
+	UserID:    1,
+	RequestID: "0123456789abcdef",
+	Created:   time.Now(),
+}
+
+func TestSentryNotify(t *testing.T) {
+	var gotPath, gotAuth string
+	var event map[string]any
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("X-Sentry-Auth")
+		json.NewDecoder(r.Body).Decode(&event)
+	}))
+	defer ts.Close()
+
+	s, err := NewSentry(strings.Replace(ts.URL, "://", "://key@", 1) + "/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Notify(context.Background(), testIncident)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, gotPath, "/api/7/store/")
+	assert.StringContains(t, gotAuth, "sentry_key=key")
+	assert.Equal(t, event["message"], "boom")
+}
+
+func TestWebhookNotify(t *testing.T) {
+	status := http.StatusOK
+	var got models.Incident
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	n := &Webhook{URL: ts.URL}
+
+	err := n.Notify(context.Background(), testIncident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got.Reference, "ABCDEFGHJK")
+
+	status = http.StatusBadGateway
+	err = n.Notify(context.Background(), testIncident)
+	if err == nil {
+		t.Error("expected an error for a 502 response")
+	}
+}
@@ -0,0 +1,181 @@
+// Package ipfilter decides whether client IP addresses may use parts of the
+// site, from allow and deny rules kept in a file which can be reloaded while
+// the server is running.
+//
+// Each line of a rules file is a rule: "allow" or "deny" followed by an IP
+// address or a CIDR block. Blank lines and anything after a "#" are ignored:
+//
+//	# The office and the VPN.
+//	allow 203.0.113.0/24
+//	allow 2001:db8::/32
+//	deny  203.0.113.7 # the guest wifi
+//
+// Deny rules take precedence over allow rules. If there are no allow rules,
+// every address which isn't denied is allowed.
+package ipfilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Rules is a parsed set of allow and deny rules.
+type Rules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// Parse reads rules in the format described in the package documentation.
+func Parse(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("ipfilter: line %d: expected \"allow\" or \"deny\" and an address", line)
+		}
+
+		prefix, err := parsePrefix(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("ipfilter: line %d: %w", line, err)
+		}
+
+		switch fields[0] {
+		case "allow":
+			rules.allow = append(rules.allow, prefix)
+		case "deny":
+			rules.deny = append(rules.deny, prefix)
+		default:
+			return nil, fmt.Errorf("ipfilter: line %d: unknown action %q", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// parsePrefix parses a CIDR block, or a single address as a block containing
+// just that address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Allowed reports whether the rules allow the given address. Addresses which
+// can't be parsed are never allowed, unless there are no rules at all.
+func (r *Rules) Allowed(ip string) bool {
+	if len(r.allow) == 0 && len(r.deny) == 0 {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	// IPv4 clients of a dual-stack listener show up as ::ffff:a.b.c.d.
+	addr = addr.Unmap()
+
+	for _, prefix := range r.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, prefix := range r.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of rules.
+func (r *Rules) Len() int {
+	return len(r.allow) + len(r.deny)
+}
+
+// File is a set of rules loaded from a file. A nil *File allows everyone,
+// so an unconfigured filter can be used without checking for it. File is
+// safe for concurrent use.
+type File struct {
+	path string
+
+	mu    sync.RWMutex
+	rules *Rules
+}
+
+// Load reads the rules in the file at path.
+func Load(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the file again. If it can't be read or parsed, the error is
+// returned and the previous rules stay in place.
+func (f *File) Reload() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rules, err := Parse(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.rules = rules
+	f.mu.Unlock()
+	return nil
+}
+
+// Path returns the path of the rules file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Rules returns the rules which are currently in effect.
+func (f *File) Rules() *Rules {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.rules
+}
+
+// Allowed reports whether the current rules allow the given address.
+func (f *File) Allowed(ip string) bool {
+	if f == nil {
+		return true
+	}
+	return f.Rules().Allowed(ip)
+}
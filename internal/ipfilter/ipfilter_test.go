@@ -0,0 +1,113 @@
+package ipfilter
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRulesAllowed(t *testing.T) {
+	rules, err := Parse(strings.NewReader(`
+# The office and the VPN.
+allow 203.0.113.0/24
+allow 2001:db8::/32
+deny  203.0.113.7 # the guest wifi
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rules.Len(), 3)
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.1", true},
+		{"::ffff:203.0.113.1", true},
+		{"2001:db8::1", true},
+		{"203.0.113.7", false},
+		{"198.51.100.1", false},
+		{"not an ip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, rules.Allowed(tt.ip), tt.want)
+		})
+	}
+}
+
+func TestRulesDenyOnly(t *testing.T) {
+	rules, err := Parse(strings.NewReader("deny 198.51.100.0/24\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rules.Allowed("198.51.100.9"), false)
+	assert.Equal(t, rules.Allowed("203.0.113.1"), true)
+
+	// Without any rules, everyone is allowed.
+	rules, err = Parse(strings.NewReader("# nothing yet\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rules.Allowed("203.0.113.1"), true)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Unknown action", "permit 10.0.0.0/8", `line 1: unknown action "permit"`},
+		{"Missing address", "\nallow", "line 2: expected"},
+		{"Bad CIDR", "deny 10.0.0.0/33", "line 1:"},
+		{"Bad address", "allow example.com", "line 1:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("allow 10.0.0.0/8\n")
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, f.Allowed("10.1.2.3"), true)
+	assert.Equal(t, f.Allowed("192.0.2.1"), false)
+
+	write("allow 192.0.2.0/24\n")
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, f.Allowed("10.1.2.3"), false)
+	assert.Equal(t, f.Allowed("192.0.2.1"), true)
+
+	// A broken file leaves the previous rules in place.
+	write("allow everyone\n")
+	assert.Equal(t, f.Reload() != nil, true)
+	assert.Equal(t, f.Allowed("192.0.2.1"), true)
+
+	// An unconfigured filter allows everyone.
+	var none *File
+	assert.Equal(t, none.Allowed("192.0.2.1"), true)
+}
@@ -0,0 +1,141 @@
+package languages
+
+import (
+	"encoding/json"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"regexp"
+	"strings"
+)
+
+// Detect guesses the language of a file. The filename's extension is the
+// most reliable clue, so it's used if it's recognized; otherwise the content
+// is analysed. It returns an empty string if it can't tell.
+func Detect(filename, content string) string {
+	name, _ := Guess(filename, content)
+	return name
+}
+
+// Guess is Detect, but also says how confident the guess is, from 0 to 1.
+// A recognized extension is taken as certain. It returns an empty string and
+// 0 if it can't tell.
+func Guess(filename, content string) (string, float64) {
+	if name := FromFilename(filename); name != "" {
+		return name, 1
+	}
+	return guessContent(content)
+}
+
+// FromContent guesses the language of a piece of code from its content
+// alone. Chroma's lexer analysers are tried first, as they're very accurate
+// when they do recognize something (mostly from shebang lines), but they only
+// exist for a few languages. After that a score is kept for each language
+// from a handful of telltale patterns, and the best scoring language wins if
+// its score is high enough.
+func FromContent(content string) string {
+	name, _ := guessContent(content)
+	return name
+}
+
+// guessContent is FromContent, along with how confident the guess is. The
+// confidence of a lexer analyser is its own score for the content. For the
+// classifiers it's the winning language's share of all the matches, scaled
+// down while there are fewer than fullScore of them.
+func guessContent(content string) (string, float64) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", 0
+	}
+
+	lexer := lexers.Analyse(content)
+	if name := fromLexer(lexer); name != "" {
+		confidence := 1.0
+		if a, ok := lexer.(chroma.Analyser); ok {
+			confidence = min(float64(a.AnalyseText(content)), 1)
+		}
+		return name, confidence
+	}
+
+	if (content[0] == '{' || content[0] == '[') && json.Valid([]byte(content)) {
+		return "json", 1
+	}
+
+	best, bestScore, total := "", 0, 0
+	for _, c := range classifiers {
+		score := 0
+		for _, rx := range c.patterns {
+			score += len(rx.FindAllStringIndex(content, 5))
+		}
+		total += score
+		if score > bestScore {
+			best, bestScore = c.language, score
+		}
+	}
+
+	if bestScore < minScore {
+		return "", 0
+	}
+	share := float64(bestScore) / float64(total)
+	return best, share * min(float64(bestScore)/fullScore, 1)
+}
+
+// fromLexer returns the name of our language for a chroma lexer, or an empty
+// string if it's nil or for a language we don't support.
+func fromLexer(lexer chroma.Lexer) string {
+	if lexer == nil {
+		return ""
+	}
+
+	config := lexer.Config()
+	if config.Name == "MySQL" {
+		return "sql"
+	}
+	for _, alias := range config.Aliases {
+		if _, ok := Lookup(alias); ok {
+			return alias
+		}
+	}
+	return ""
+}
+
+// minScore is how many pattern matches a language needs for FromContent to
+// be confident enough to pick it.
+const minScore = 2
+
+// fullScore is how many pattern matches a language needs before a guess
+// from the classifiers is as confident as the matches for other languages
+// allow.
+const fullScore = 6
+
+type classifier struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+// classifiers are the patterns which suggest each language. Each match adds
+// one to the language's score, up to five per pattern.
+var classifiers = []classifier{
+	{"go", compile(`(?m)^package \w+$`, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`, `:= `, `(?m)^import \($`)},
+	{"python", compile(`(?m)^\s*def \w+\(.*\):\s*$`, `(?m)^(from \w+(\.\w+)* )?import \w+`, `(?m)^\s*(if|for|while|elif|else|try|except).*:\s*$`, `\bself\.`, `print\(`)},
+	{"javascript", compile(`\bfunction\s*\w*\(`, `\b(const|let|var) \w+ =`, `=> \{?`, `console\.log\(`, `\brequire\(`, `document\.`)},
+	{"typescript", compile(`(?m)^\s*(export )?interface \w+`, `:\s*(string|number|boolean)\b`, `(?m)^import .* from '`, `\bas const\b`)},
+	{"ruby", compile(`(?m)^\s*def \w+[^:]*$`, `(?m)^\s*end$`, `\bputs\b`, `(?m)^\s*require '`, `\.each do\b`)},
+	{"rust", compile(`\bfn \w+\(`, `\blet mut\b`, `println!\(`, `(?m)^use \w+(::\w+)+;`, `\bimpl\b`)},
+	{"java", compile(`\bpublic (static )?(class|void)\b`, `System\.out\.print`, `(?m)^import java\.`, `\bprivate \w+ \w+;`)},
+	{"c", compile(`(?m)^#include <\w+\.h>`, `\bprintf\(`, `\bint main\(`, `\bmalloc\(`)},
+	{"cpp", compile(`(?m)^#include <\w+>$`, `\bstd::`, `\bcout <<`, `\bnamespace \w+`)},
+	{"sql", compile(`(?is)\bselect\b.+?\bfrom\b`, `(?i)\binsert into\b`, `(?i)\bcreate table\b`, `(?i)\bwhere\b`, `(?i)\bupdate \w+ set\b`)},
+	{"html", compile(`(?i)<!doctype html>`, `(?i)<(html|head|body|div|span|p|a)[\s>]`, `(?i)</(html|head|body|div|span|p|a)>`)},
+	{"css", compile(`(?m)^[.#]?[\w-]+(\s*[,>+~]?\s*[.#]?[\w-]+)*\s*\{$`, `(?m)^\s*[\w-]+:\s*[^;]+;$`)},
+	{"markdown", compile(`(?m)^#{1,6} \S`, `(?m)^[-*] \S`, `\[[^\]]+\]\([^)]+\)`, "(?m)^```")},
+	{"yaml", compile(`(?m)^[\w-]+:( .+)?$`, `(?m)^\s+- \S`, `(?m)^---$`)},
+	{"bash", compile(`(?m)^\s*(if|for|while) .*; (then|do)$`, `\$\{?\w+\}?`, `(?m)^\s*(echo|export|cd|fi|done)\b`)},
+}
+
+func compile(patterns ...string) []*regexp.Regexp {
+	rxs := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		rxs[i] = regexp.MustCompile(p)
+	}
+	return rxs
+}
@@ -0,0 +1,93 @@
+// Package languages is the registry of programming languages which snippets
+// can be tagged with.
+package languages
+
+import (
+	"path"
+	"strings"
+)
+
+// Language describes a supported language. Name is the identifier stored in
+// the database, and Extensions are the file extensions (without the dot)
+// commonly used for it, with the preferred one first.
+type Language struct {
+	Name       string
+	Label      string
+	Extensions []string
+}
+
+// All lists the supported languages in the order they are offered in forms.
+var All = []Language{
+	{Name: "plaintext", Label: "Plain text", Extensions: []string{"txt"}},
+	{Name: "bash", Label: "Bash", Extensions: []string{"sh", "bash"}},
+	{Name: "c", Label: "C", Extensions: []string{"c", "h"}},
+	{Name: "cpp", Label: "C++", Extensions: []string{"cpp", "cc", "hpp"}},
+	{Name: "css", Label: "CSS", Extensions: []string{"css"}},
+	{Name: "go", Label: "Go", Extensions: []string{"go"}},
+	{Name: "html", Label: "HTML", Extensions: []string{"html", "htm"}},
+	{Name: "java", Label: "Java", Extensions: []string{"java"}},
+	{Name: "javascript", Label: "JavaScript", Extensions: []string{"js", "mjs"}},
+	{Name: "json", Label: "JSON", Extensions: []string{"json"}},
+	{Name: "markdown", Label: "Markdown", Extensions: []string{"md", "markdown"}},
+	{Name: "python", Label: "Python", Extensions: []string{"py"}},
+	{Name: "ruby", Label: "Ruby", Extensions: []string{"rb"}},
+	{Name: "rust", Label: "Rust", Extensions: []string{"rs"}},
+	{Name: "sql", Label: "SQL", Extensions: []string{"sql"}},
+	{Name: "typescript", Label: "TypeScript", Extensions: []string{"ts"}},
+	{Name: "yaml", Label: "YAML", Extensions: []string{"yaml", "yml"}},
+}
+
+// Lookup returns the language with the given name.
+func Lookup(name string) (Language, bool) {
+	for _, l := range All {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Names returns the names of all the supported languages.
+func Names() []string {
+	names := make([]string, len(All))
+	for i, l := range All {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Label returns the human-readable label for a language name, or the name
+// itself if it isn't known.
+func Label(name string) string {
+	if l, ok := Lookup(name); ok {
+		return l.Label
+	}
+	return name
+}
+
+// Extension returns the preferred file extension for a language, falling back
+// to "txt" for unknown or empty language names.
+func Extension(name string) string {
+	if l, ok := Lookup(name); ok {
+		return l.Extensions[0]
+	}
+	return "txt"
+}
+
+// FromFilename guesses the language of a file from its extension. It returns
+// an empty string if the extension isn't recognized.
+func FromFilename(filename string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(filename), "."))
+	if ext == "" {
+		return ""
+	}
+
+	for _, l := range All {
+		for _, e := range l.Extensions {
+			if e == ext {
+				return l.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package languages
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"main.go", "go"},
+		{"README.MD", "markdown"},
+		{"config.yml", "yaml"},
+		{"archive.tar.gz", ""},
+		{"Makefile", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			assert.Equal(t, FromFilename(tt.filename), tt.want)
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, Extension("python"), "py")
+	assert.Equal(t, Extension(""), "txt")
+	assert.Equal(t, Extension("cobol"), "txt")
+}
+
+func TestFromContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", "go"},
+		{"Shebang", "#!/bin/bash\necho hello\n", "bash"},
+		{"Python", "import os\n\ndef main():\n    print(os.getcwd())\n", "python"},
+		{"JSON", `{"name": "snippetbox", "tags": [1, 2]}`, "json"},
+		{"SQL", "SELECT id, title\nFROM snippets\nWHERE id = 1;", "sql"},
+		{"JavaScript", "const greet = (name) => {\n  console.log(`hi ${name}`);\n};\n", "javascript"},
+		{"Markdown", "# Title\n\n- one\n- two\n\nSee [the docs](https://example.com).\n", "markdown"},
+		{"Prose", "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", ""},
+		{"Empty", "  \n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, FromContent(tt.content), tt.want)
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	// The filename wins over the content...
+	assert.Equal(t, Detect("query.sql", "package main"), "sql")
+	// ...unless it doesn't tell us anything.
+	assert.Equal(t, Detect("Makefile", "package main\n\nfunc main() {}\n"), "go")
+}
+
+func TestGuess(t *testing.T) {
+	name, confidence := Guess("main.go", "anything")
+	assert.Equal(t, name, "go")
+	assert.Equal(t, confidence, 1.0)
+
+	// A few telltale lines make for a surer guess than one.
+	name, sure := Guess("", "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tx := 1\n\tfmt.Println(x)\n}\n")
+	assert.Equal(t, name, "go")
+	name, unsure := Guess("", "package main\n\nfunc main() {}\n")
+	assert.Equal(t, name, "go")
+	assert.Equal(t, sure > unsure, true)
+	assert.Equal(t, unsure > 0, true)
+
+	name, confidence = Guess("", "An old silent pond...")
+	assert.Equal(t, name, "")
+	assert.Equal(t, confidence, 0.0)
+}
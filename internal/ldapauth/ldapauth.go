@@ -0,0 +1,186 @@
+// Package ldapauth checks passwords against an LDAP directory, such as
+// Active Directory.
+package ldapauth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned by Authenticate when there's no entry
+// for the login or the password is wrong.
+var ErrInvalidCredentials = errors.New("ldapauth: invalid credentials")
+
+// Config says how to find and authenticate users.
+type Config struct {
+	// URL is the directory server, as ldap://host:port or ldaps://host:port.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS bool
+
+	// BindDN and BindPassword are the service account used to search for
+	// users. Both are left empty if the directory allows anonymous search.
+	BindDN       string
+	BindPassword string
+
+	// BaseDN is where to search for users.
+	BaseDN string
+	// UserFilter finds the entry for a login, which replaces "{login}".
+	UserFilter string
+
+	// EmailAttribute, NameAttribute and GroupAttribute are the attributes
+	// of a user's entry holding their email address, display name and the
+	// groups they are a member of.
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+
+	Timeout time.Duration
+}
+
+// Entry is the directory's entry for an authenticated user.
+type Entry struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// conn is the part of *ldap.Conn which Directory uses.
+type conn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// Directory authenticates users against an LDAP directory. A new connection
+// is made for each attempt.
+type Directory struct {
+	cfg  Config
+	dial func() (conn, error)
+}
+
+// New checks cfg and fills in defaults for anything left empty.
+func New(cfg Config) (*Directory, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("ldapauth: URL %q must be ldap://host or ldaps://host", cfg.URL)
+	}
+	if cfg.StartTLS && u.Scheme == "ldaps" {
+		return nil, errors.New("ldapauth: StartTLS can't be used with ldaps://")
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("ldapauth: a base DN is required")
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(mail={login})"
+	}
+	if !strings.Contains(cfg.UserFilter, "{login}") {
+		return nil, fmt.Errorf("ldapauth: user filter %q doesn't contain {login}", cfg.UserFilter)
+	}
+	if _, err = ldap.CompileFilter(strings.ReplaceAll(cfg.UserFilter, "{login}", "x")); err != nil {
+		return nil, fmt.Errorf("ldapauth: user filter %q: %w", cfg.UserFilter, err)
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = "displayName"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	d := &Directory{cfg: cfg}
+	d.dial = func() (conn, error) {
+		c, err := ldap.DialURL(cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}))
+		if err != nil {
+			return nil, err
+		}
+		c.SetTimeout(cfg.Timeout)
+
+		if cfg.StartTLS {
+			if err = c.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+	return d, nil
+}
+
+// Authenticate finds the entry for login and binds as it with password,
+// returning the entry if that works.
+func (d *Directory) Authenticate(login, password string) (*Entry, error) {
+	// Most servers treat a bind with an empty password as an anonymous
+	// bind, which succeeds whoever the DN belongs to.
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	c, err := d.dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldapauth: connecting to %s: %w", d.cfg.URL, err)
+	}
+	defer c.Close()
+
+	if d.cfg.BindDN != "" {
+		if err = c.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldapauth: binding as %s: %w", d.cfg.BindDN, err)
+		}
+	}
+
+	filter := strings.ReplaceAll(d.cfg.UserFilter, "{login}", ldap.EscapeFilter(login))
+	attributes := []string{d.cfg.EmailAttribute, d.cfg.NameAttribute, d.cfg.GroupAttribute}
+
+	// Asking for two entries is enough to tell whether the filter is
+	// ambiguous.
+	req := ldap.NewSearchRequest(d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(d.cfg.Timeout/time.Second), false, filter, attributes, nil)
+
+	result, err := c.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldapauth: searching for %s: %w", login, err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	e := result.Entries[0]
+
+	err = c.Bind(e.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldapauth: binding as %s: %w", e.DN, err)
+	}
+
+	return &Entry{
+		DN:     e.DN,
+		Email:  e.GetAttributeValue(d.cfg.EmailAttribute),
+		Name:   e.GetAttributeValue(d.cfg.NameAttribute),
+		Groups: e.GetAttributeValues(d.cfg.GroupAttribute),
+	}, nil
+}
+
+// MemberOfAny reports whether the entry is a member of any of groups. DNs
+// are compared ignoring case, as directories do.
+func (e *Entry) MemberOfAny(groups []string) bool {
+	for _, g := range e.Groups {
+		for _, want := range groups {
+			if strings.EqualFold(strings.TrimSpace(g), strings.TrimSpace(want)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package ldapauth
+
+import (
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+// fakeConn is a directory with a service account and some users, all with
+// the password "secret".
+type fakeConn struct {
+	entries []*ldap.Entry
+	bound   string
+	filters []string
+}
+
+func (c *fakeConn) Bind(username, password string) error {
+	if password != "secret" {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	c.bound = username
+	return nil
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.bound != "cn=svc,dc=example,dc=com" {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("not bound"))
+	}
+	c.filters = append(c.filters, req.Filter)
+
+	result := &ldap.SearchResult{}
+	for _, e := range c.entries {
+		switch req.Filter {
+		case "(mail=" + e.GetAttributeValue("mail") + ")", "(mail=*)":
+			result.Entries = append(result.Entries, e)
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func TestAuthenticate(t *testing.T) {
+	d, err := New(Config{
+		URL:          "ldap://ldap.example.com",
+		BindDN:       "cn=svc,dc=example,dc=com",
+		BindPassword: "secret",
+		BaseDN:       "dc=example,dc=com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &fakeConn{entries: []*ldap.Entry{
+		ldap.NewEntry("uid=alice,dc=example,dc=com", map[string][]string{
+			"mail":        {"alice@example.com"},
+			"displayName": {"Alice"},
+			"memberOf":    {"CN=Admins,DC=example,DC=com", "cn=staff,dc=example,dc=com"},
+		}),
+		ldap.NewEntry("uid=bob,dc=example,dc=com", map[string][]string{
+			"mail": {"bob@example.com"},
+		}),
+	}}
+	d.dial = func() (conn, error) { return c, nil }
+
+	e, err := d.Authenticate("alice@example.com", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, e.DN, "uid=alice,dc=example,dc=com")
+	assert.Equal(t, e.Email, "alice@example.com")
+	assert.Equal(t, e.Name, "Alice")
+	assert.Equal(t, e.MemberOfAny([]string{"cn=admins,dc=example,dc=com"}), true)
+	assert.Equal(t, e.MemberOfAny([]string{"cn=ops,dc=example,dc=com"}), false)
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+	}{
+		{"Wrong password", "alice@example.com", "wrong"},
+		{"Empty password", "alice@example.com", ""},
+		{"Unknown user", "carol@example.com", "secret"},
+		{"Wildcard login", "*", "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.Authenticate(tt.login, tt.password)
+			assert.Equal(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	// The login is escaped, so "*" is searched for literally.
+	assert.Equal(t, c.filters[len(c.filters)-1], `(mail=\2a)`)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"Valid", Config{URL: "ldaps://ad.example.com:636", BaseDN: "dc=example,dc=com"}, true},
+		{"Custom filter", Config{URL: "ldap://ad.example.com", BaseDN: "dc=example,dc=com", UserFilter: "(&(objectClass=user)(userPrincipalName={login}))", StartTLS: true}, true},
+		{"Bad scheme", Config{URL: "http://ad.example.com", BaseDN: "dc=example,dc=com"}, false},
+		{"No base DN", Config{URL: "ldap://ad.example.com"}, false},
+		{"No placeholder", Config{URL: "ldap://ad.example.com", BaseDN: "dc=example,dc=com", UserFilter: "(mail=alice)"}, false},
+		{"Bad filter", Config{URL: "ldap://ad.example.com", BaseDN: "dc=example,dc=com", UserFilter: "(mail={login}"}, false},
+		{"StartTLS with ldaps", Config{URL: "ldaps://ad.example.com", BaseDN: "dc=example,dc=com", StartTLS: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			assert.Equal(t, err == nil, tt.valid)
+		})
+	}
+}
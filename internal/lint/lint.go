@@ -0,0 +1,126 @@
+// Package lint runs quick validity checks on snippets in the languages it
+// has a checker for, to warn about content which won't parse. The checks
+// only ever warn: a snippet is often a fragment, or deliberately broken, so
+// nothing is refused because of them.
+//
+// Checkers are kept in a registry keyed by language name, as used by the
+// languages package. Go, JSON and YAML are registered to begin with.
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"go/format"
+	"go/scanner"
+	"gopkg.in/yaml.v3"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Warning is a problem found in a snippet. Line is the line it was found on,
+// counting from 1, or 0 if the checker couldn't tell.
+type Warning struct {
+	Line    int
+	Message string
+}
+
+// Checker checks content written in its language, returning any problems
+// with it. It's only called with content which isn't blank.
+type Checker func(content string) []Warning
+
+var (
+	mu       sync.RWMutex
+	checkers = map[string]Checker{
+		"go":   checkGo,
+		"json": checkJSON,
+		"yaml": checkYAML,
+	}
+)
+
+// Register sets the checker for a language, replacing any it already had.
+// A nil checker removes it.
+func Register(language string, c Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if c == nil {
+		delete(checkers, language)
+		return
+	}
+	checkers[language] = c
+}
+
+// Check checks content written in the given language. It returns nil if
+// there's nothing wrong with it, or no checker for the language.
+func Check(language, content string) []Warning {
+	mu.RLock()
+	c := checkers[language]
+	mu.RUnlock()
+
+	if c == nil || strings.TrimSpace(content) == "" {
+		return nil
+	}
+	return c(content)
+}
+
+// checkGo parses Go source the way gofmt does, so that a snippet can be a
+// whole file, a few declarations or just some statements. Only the first
+// error is reported, as the rest are often caused by it.
+func checkGo(content string) []Warning {
+	_, err := format.Source([]byte(content))
+	if err == nil {
+		return nil
+	}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return []Warning{{Line: list[0].Pos.Line, Message: list[0].Msg}}
+	}
+	return []Warning{{Message: err.Error()}}
+}
+
+// checkJSON checks that content is a single JSON value.
+func checkJSON(content string) []Warning {
+	var v any
+	err := json.Unmarshal([]byte(content), &v)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return []Warning{{Line: lineAt(content, syntaxErr.Offset), Message: syntaxErr.Error()}}
+	}
+	return []Warning{{Message: err.Error()}}
+}
+
+// lineAt returns the line which the byte at offset is on.
+func lineAt(content string, offset int64) int {
+	offset = min(offset, int64(len(content)))
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// yamlLineRx matches the line number which starts most of yaml.v3's error
+// messages.
+var yamlLineRx = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// checkYAML checks every document in content.
+func checkYAML(content string) []Warning {
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			if m := yamlLineRx.FindStringSubmatch(err.Error()); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				return []Warning{{Line: line, Message: m[2]}}
+			}
+			return []Warning{{Message: strings.TrimPrefix(err.Error(), "yaml: ")}}
+		}
+	}
+}
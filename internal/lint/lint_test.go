@@ -0,0 +1,56 @@
+package lint
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		wantLine int
+		wantOK   bool
+	}{
+		{"Go file", "go", "package main\n\nfunc main() {}\n", 0, true},
+		{"Go declarations", "go", "func add(a, b int) int {\n\treturn a + b\n}\n", 0, true},
+		{"Go statements", "go", "x := 1\nfmt.Println(x)\n", 0, true},
+		{"Invalid Go", "go", "func main() {\n\tx := \n}\n", 3, false},
+		{"JSON", "json", `{"a": [1, 2, 3]}`, 0, true},
+		{"Invalid JSON", "json", "{\n  \"a\": 1,\n  \"b\": 2,\n}\n", 4, false},
+		{"Trailing JSON", "json", "{}\n{}", 2, false},
+		{"YAML", "yaml", "a: 1\nb:\n  - c\n---\nd: 2\n", 0, true},
+		{"Invalid YAML", "yaml", "a: 1\nb: c\n  d: e\n", 3, false},
+		{"Invalid second YAML document", "yaml", "a: 1\n---\nb: c: d\n", 3, false},
+		{"No checker", "python", "def (:", 0, true},
+		{"Blank", "json", "  \n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := Check(tt.language, tt.content)
+			if tt.wantOK {
+				assert.Equal(t, len(warnings), 0)
+				return
+			}
+			if len(warnings) != 1 {
+				t.Fatalf("got warnings %v; want one", warnings)
+			}
+			assert.Equal(t, warnings[0].Line, tt.wantLine)
+			assert.Equal(t, warnings[0].Message != "", true)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("text", func(content string) []Warning {
+		return []Warning{{Line: 1, Message: "always"}}
+	})
+	defer Register("text", nil)
+
+	assert.Equal(t, len(Check("text", "anything")), 1)
+
+	Register("text", nil)
+	assert.Equal(t, len(Check("text", "anything")), 0)
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AccessEntry describes a request which the application has handled.
+type AccessEntry struct {
+	Time       time.Time     `json:"time"`
+	RequestID  string        `json:"request_id"`
+	RemoteAddr string        `json:"remote_addr"`
+	Proto      string        `json:"proto"`
+	Method     string        `json:"method"`
+	URI        string        `json:"uri"`
+	Status     int           `json:"status"`
+	Bytes      int           `json:"bytes"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// AccessLog writes an entry for each request to an access log.
+type AccessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+}
+
+// NewAccessLog returns an access log which writes to w in the given format.
+func NewAccessLog(w io.Writer, format Format) *AccessLog {
+	return &AccessLog{w: w, format: format}
+}
+
+// Log writes the entry to the log. Write errors are ignored, as there's
+// nothing useful a request can do about them.
+func (l *AccessLog) Log(e AccessEntry) {
+	var line []byte
+
+	if l.format == JSON {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("%s ACCESS\t%s - %s %s %s %d %d %s [%s]\n",
+			e.Time.Format("2006/01/02 15:04:05"), e.RemoteAddr, e.Proto, e.Method, e.URI,
+			e.Status, e.Bytes, e.Duration.Round(time.Microsecond), e.RequestID))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(line)
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Format is how log entries are written: Human is the standard library's
+// plain text format, and JSON writes one object per line for log processors.
+type Format string
+
+const (
+	Human Format = "human"
+	JSON  Format = "json"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Human, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("logging: unknown format %q (want human or json)", s)
+	}
+}
+
+// New returns a logger which writes to w in the given format. level (such as
+// "INFO") prefixes each human entry and is the level field of JSON entries.
+// If caller is true, entries include the file and line which logged them.
+func New(w io.Writer, format Format, level string, caller bool) *log.Logger {
+	if format == JSON {
+		flags := 0
+		if caller {
+			flags = log.Lshortfile
+		}
+		return log.New(&jsonWriter{w: w, level: strings.ToLower(level), caller: caller}, "", flags)
+	}
+
+	flags := log.Ldate | log.Ltime
+	if caller {
+		flags |= log.Lshortfile
+	}
+	return log.New(w, level+"\t", flags)
+}
+
+// jsonWriter turns each line written by a log.Logger into a JSON object.
+type jsonWriter struct {
+	w      io.Writer
+	level  string
+	caller bool
+}
+
+type jsonEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"msg"`
+}
+
+func (jw *jsonWriter) Write(p []byte) (int, error) {
+	entry := jsonEntry{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Level:   jw.level,
+		Message: strings.TrimSuffix(string(p), "\n"),
+	}
+
+	// With log.Lshortfile and no other flags, the logger starts each line
+	// with "file.go:12: ".
+	if jw.caller {
+		if caller, msg, ok := strings.Cut(entry.Message, ": "); ok {
+			entry.Caller, entry.Message = caller, msg
+		}
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = jw.w.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
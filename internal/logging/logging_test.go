@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("json")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, f, JSON)
+
+	_, err = ParseFormat("xml")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestNewHuman(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, Human, "INFO", false).Print("hello")
+
+	assert.Equal(t, strings.HasPrefix(buf.String(), "INFO\t"), true)
+	assert.Equal(t, strings.HasSuffix(buf.String(), " hello\n"), true)
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, JSON, "ERROR", true).Print("something: broke")
+
+	var entry jsonEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry.Level, "error")
+	assert.Equal(t, strings.HasPrefix(entry.Caller, "logging_test.go:"), true)
+	assert.Equal(t, entry.Message, "something: broke")
+}
+
+func TestAccessLog(t *testing.T) {
+	entry := AccessEntry{
+		Time:       time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+		RequestID:  "abc123",
+		RemoteAddr: "127.0.0.1:1234",
+		Proto:      "HTTP/1.1",
+		Method:     "GET",
+		URI:        "/snippet/view/1",
+		Status:     200,
+		Bytes:      512,
+		Duration:   1500 * time.Microsecond,
+	}
+
+	var buf bytes.Buffer
+	NewAccessLog(&buf, Human).Log(entry)
+	assert.Equal(t, buf.String(), "2024/03/17 10:15:00 ACCESS\t127.0.0.1:1234 - HTTP/1.1 GET /snippet/view/1 200 512 1.5ms [abc123]\n")
+
+	buf.Reset()
+	NewAccessLog(&buf, JSON).Log(entry)
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, decoded["uri"], any("/snippet/view/1"))
+	assert.Equal(t, decoded["status"], any(float64(200)))
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+
+	w, err := Open(Output{Path: path, MaxSize: 1, RotateEvery: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	New(w, Human, "INFO", false).Print("hello")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.StringContains(t, string(b), "hello")
+}
+
+func TestOpenUnwritable(t *testing.T) {
+	// A directory can't be opened as a log file.
+	_, err := Open(Output{Path: t.TempDir()})
+	assert.Equal(t, err != nil, true)
+}
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	for _, name := range []string{
+		"audit.log",
+		"audit-2024-03-01T10-00-00.000.log.gz",
+		"audit-2024-01-01T10-00-00.000.log",
+		"audit-notatime.log",
+		"app-2024-02-01T10-00-00.000.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(backups), 2)
+	assert.Equal(t, backups[0].Path, filepath.Join(dir, "audit-2024-01-01T10-00-00.000.log"))
+	assert.Equal(t, backups[0].Rotated, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, backups[1].Path, filepath.Join(dir, "audit-2024-03-01T10-00-00.000.log.gz"))
+}
@@ -0,0 +1,146 @@
+// Package logging sets up where the application's logs are written and in
+// what format.
+package logging
+
+import (
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output describes where a log is written. Path is "stdout", "stderr" or the
+// path of a file. Files are rotated once they reach MaxSize megabytes and,
+// if RotateEvery is set, at that interval as well. Rotated files are removed
+// after MaxAge days or once there are more than MaxBackups of them (zero
+// means never), and gzipped if Compress is set.
+type Output struct {
+	Path        string
+	MaxSize     int
+	MaxAge      int
+	MaxBackups  int
+	RotateEvery time.Duration
+	Compress    bool
+}
+
+// Open opens the output for writing. Closing the returned writer stops any
+// time-based rotation and closes the file; it does nothing for the standard
+// streams.
+func Open(o Output) (io.WriteCloser, error) {
+	switch o.Path {
+	case "", "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+
+	// lumberjack only opens the file on the first write, so check now that
+	// it can be written to rather than finding out when the first request
+	// comes in.
+	if err := os.MkdirAll(filepath.Dir(o.Path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(o.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	file := &rotatingFile{
+		Logger: &lumberjack.Logger{
+			Filename:   o.Path,
+			MaxSize:    o.MaxSize,
+			MaxAge:     o.MaxAge,
+			MaxBackups: o.MaxBackups,
+			Compress:   o.Compress,
+		},
+		stop: make(chan struct{}),
+	}
+
+	if o.RotateEvery > 0 {
+		go file.rotatePeriodically(o.RotateEvery)
+	}
+
+	return file, nil
+}
+
+type rotatingFile struct {
+	*lumberjack.Logger
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func (f *rotatingFile) rotatePeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Rotate(); err != nil {
+				// There's nowhere better to report this: the log is the
+				// thing which is broken.
+				os.Stderr.WriteString("logging: rotating " + f.Filename + ": " + err.Error() + "\n")
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.closeOnce.Do(func() { close(f.stop) })
+	return f.Logger.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// backupTimeFormat is how lumberjack stamps the files it rotates a log into,
+// between the log's name and its extension: app.log becomes
+// app-2006-01-02T15-04-05.000.log, with .gz on the end if it's compressed.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Backup is a file which a log was rotated into.
+type Backup struct {
+	Path    string
+	Rotated time.Time
+}
+
+// Backups returns the files which the log file at path has been rotated
+// into, oldest first.
+func Backups(path string) ([]Backup, error) {
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		rotated, err := time.Parse(backupTimeFormat, name[len(prefix):len(name)-len(ext)])
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, e.Name()), Rotated: rotated})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Rotated.Before(backups[j].Rotated) })
+	return backups, nil
+}
@@ -0,0 +1,150 @@
+// Package mailer sends the application's emails, through an SMTP server or
+// the SendGrid API.
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is an email to a single recipient. Body is plain text. HTML is an
+// optional alternative to it for clients which show HTML; messages with one
+// are sent as multipart/alternative.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+	// Unsubscribe is a link which unsubscribes the recipient from emails
+	// like this one with a single POST, as RFC 8058 describes. It's sent in
+	// the List-Unsubscribe headers if it's set.
+	Unsubscribe string
+}
+
+// Sender is implemented by anything which can send a Message: a mail
+// provider, or something which hands messages on to one.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// ErrRejected is wrapped by the errors of senders whose provider refused a
+// message outright, such as for an address which doesn't exist. Sending it
+// again won't help.
+var ErrRejected = errors.New("mailer: message rejected")
+
+// retryDelays are how long to wait after each failed attempt to send a
+// message before the next, doubling each time. A message is given up on
+// after the last.
+var retryDelays = []time.Duration{
+	30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute,
+	8 * time.Minute, 16 * time.Minute, 32 * time.Minute, 64 * time.Minute,
+}
+
+// RetryDelay returns how long to wait before trying again to send a message
+// which has failed attempts times, and false once it should be given up on.
+func RetryDelay(attempts int) (time.Duration, bool) {
+	if attempts < 1 || attempts > len(retryDelays) {
+		return 0, false
+	}
+	return retryDelays[attempts-1], true
+}
+
+// SMTP sends messages through an SMTP server. Username and Password may be
+// left empty for servers which don't require authentication.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	err := smtp.SendMail(addr, auth, s.From, []string{msg.To}, format(s.From, msg))
+	if err != nil {
+		// 5xx replies are permanent failures; 4xx ones are worth retrying.
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return fmt.Errorf("mailer: sending to %s: %w: %w", msg.To, ErrRejected, err)
+		}
+		return fmt.Errorf("mailer: sending to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// format builds the raw RFC 5322 message for msg.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.Unsubscribe != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", msg.Unsubscribe)
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(msg.Body))
+		return []byte(b.String())
+	}
+
+	// The alternatives go from plainest to richest, and clients show the
+	// last one they understand. Both are quoted-printable, which keeps
+	// lines short enough for SMTP.
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n", mw.Boundary())
+	b.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Body},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(crlf(part.content)))
+		qp.Close()
+	}
+	mw.Close()
+
+	return []byte(b.String())
+}
+
+// crlf converts line endings to the CRLF which email uses.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// Log writes messages to a logger instead of sending them, for development
+// without an SMTP server.
+type Log struct {
+	Logger *log.Logger
+}
+
+func (l *Log) Send(msg Message) error {
+	l.Logger.Printf("email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	raw := string(format("Snippetbox <no-reply@example.com>", Message{
+		To:      "alice@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two",
+	}))
+
+	assert.StringContains(t, raw, "From: Snippetbox <no-reply@example.com>\r\n")
+	assert.StringContains(t, raw, "To: alice@example.com\r\n")
+	assert.StringContains(t, raw, "Subject: Hello\r\n")
+	assert.Equal(t, strings.HasSuffix(raw, "\r\n\r\nLine one\r\nLine two"), true)
+	assert.Equal(t, strings.Contains(raw, "List-Unsubscribe"), false)
+}
+
+func TestFormatUnsubscribe(t *testing.T) {
+	raw := string(format("Snippetbox <no-reply@example.com>", Message{
+		To:          "alice@example.com",
+		Subject:     "Hello",
+		Body:        "Hi",
+		Unsubscribe: "https://snippets.example.com/email/unsubscribe?sig=x",
+	}))
+
+	assert.StringContains(t, raw, "List-Unsubscribe: <https://snippets.example.com/email/unsubscribe?sig=x>\r\n")
+	assert.StringContains(t, raw, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+}
+
+func TestFormatHTML(t *testing.T) {
+	raw := string(format("Snippetbox <no-reply@example.com>", Message{
+		To:      "alice@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two",
+		HTML:    "<p style=\"color: red\">Line one</p>",
+	}))
+
+	assert.StringContains(t, raw, "Content-Type: multipart/alternative; boundary=")
+	assert.StringContains(t, raw, "Content-Type: text/plain; charset=utf-8\r\n")
+	assert.StringContains(t, raw, "Line one\r\nLine two")
+	assert.StringContains(t, raw, "Content-Type: text/html; charset=utf-8\r\n")
+	assert.StringContains(t, raw, "<p style=3D\"color: red\">Line one</p>")
+	assert.Equal(t, strings.Index(raw, "text/plain") < strings.Index(raw, "text/html"), true)
+}
+
+func TestRetryDelay(t *testing.T) {
+	d, ok := RetryDelay(1)
+	assert.Equal(t, d, 30*time.Second)
+	assert.Equal(t, ok, true)
+
+	// Each delay is twice the one before.
+	next, _ := RetryDelay(2)
+	assert.Equal(t, next, 2*d)
+
+	_, ok = RetryDelay(len(retryDelays) + 1)
+	assert.Equal(t, ok, false)
+}
@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// sendGridEndpoint is SendGrid's v3 Mail Send API.
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends messages through the SendGrid API, for hosts which can't
+// make outgoing SMTP connections. From may include a display name, as in
+// "Snippetbox <no-reply@example.com>".
+type SendGrid struct {
+	APIKey string
+	From   string
+
+	// Endpoint and Client default to the SendGrid API and a client with a
+	// 30 second timeout. They're only changed by tests.
+	Endpoint string
+	Client   *http.Client
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+func (s *SendGrid) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("mailer: sender %q: %w", s.From, err)
+	}
+
+	req := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: from.Address, Name: from.Name},
+		Subject:          msg.Subject,
+		// SendGrid wants the plain text first, like multipart/alternative.
+		Content: []sendGridContent{{Type: "text/plain", Value: msg.Body}},
+	}
+	if msg.HTML != "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+	if msg.Unsubscribe != "" {
+		req.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + msg.Unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	endpoint, client := s.Endpoint, s.Client
+	if endpoint == "" {
+		endpoint = sendGridEndpoint
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	r, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+s.APIKey)
+	r.Header.Set("Content-Type", "application/json")
+
+	rs, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("mailer: sending to %s: %w", msg.To, err)
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode/100 == 2 {
+		return nil
+	}
+
+	// SendGrid explains errors in the body, which is worth keeping.
+	detail, _ := io.ReadAll(io.LimitReader(rs.Body, 512))
+	err = fmt.Errorf("mailer: sending to %s: SendGrid responded %s: %s", msg.To, rs.Status, strings.TrimSpace(string(detail)))
+
+	// Apart from rate limiting, a 4xx means the message itself is wrong and
+	// will be refused however many times it's sent. A bad API key is a 401
+	// too, but that's better noticed in the dead letters than retried for
+	// hours.
+	if rs.StatusCode/100 == 4 && rs.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendGrid(t *testing.T) {
+	var got sendGridRequest
+	var auth string
+	status := http.StatusAccepted
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"errors":[{"message":"nope"}]}`))
+	}))
+	defer ts.Close()
+
+	s := &SendGrid{APIKey: "SG.key", From: "Snippetbox <no-reply@example.com>", Endpoint: ts.URL}
+	msg := Message{To: "alice@example.com", Subject: "Hello", Body: "Hi", HTML: "<p>Hi</p>"}
+
+	if err := s.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, auth, "Bearer SG.key")
+	assert.Equal(t, got.Personalizations[0].To[0].Email, "alice@example.com")
+	assert.Equal(t, got.From.Email, "no-reply@example.com")
+	assert.Equal(t, got.From.Name, "Snippetbox")
+	assert.Equal(t, got.Subject, "Hello")
+	assert.Equal(t, len(got.Content), 2)
+	assert.Equal(t, got.Content[0].Type, "text/plain")
+	assert.Equal(t, got.Content[1].Value, "<p>Hi</p>")
+	assert.Equal(t, len(got.Headers), 0)
+
+	unsubscribe := msg
+	unsubscribe.Unsubscribe = "https://snippets.example.com/email/unsubscribe?sig=x"
+	if err := s.Send(unsubscribe); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got.Headers["List-Unsubscribe"], "<https://snippets.example.com/email/unsubscribe?sig=x>")
+	assert.Equal(t, got.Headers["List-Unsubscribe-Post"], "List-Unsubscribe=One-Click")
+
+	tests := []struct {
+		name     string
+		status   int
+		rejected bool
+	}{
+		{"Bad request", http.StatusBadRequest, true},
+		{"Rate limited", http.StatusTooManyRequests, false},
+		{"Server error", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status = tt.status
+			err := s.Send(msg)
+			assert.Equal(t, err != nil, true)
+			assert.Equal(t, errors.Is(err, ErrRejected), tt.rejected)
+			assert.StringContains(t, err.Error(), "nope")
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Layout is the data the shared layout uses. Every email's data embeds it.
+type Layout struct {
+	// SiteURL is the address of the site, without a trailing slash.
+	SiteURL string
+	// Unsubscribe is the link for unsubscribing from emails like this one,
+	// if they can be. It's put in the footer, and in the message's
+	// List-Unsubscribe header.
+	Unsubscribe string
+}
+
+func (l Layout) layout() Layout {
+	return l
+}
+
+// WelcomeData is the data for the "welcome" email, sent after signing up.
+type WelcomeData struct {
+	Layout
+	Name string
+}
+
+// VerifyEmailData is the data for the "verify_email" email, which asks for
+// a new email address to be confirmed.
+type VerifyEmailData struct {
+	Layout
+	Name string
+	Link string
+}
+
+// EmailChangedData is the data for the "email_changed" email, which warns
+// the old address that the email address of an account is being changed.
+type EmailChangedData struct {
+	Layout
+	Name     string
+	NewEmail string
+}
+
+// LoginLinkData is the data for the "login_link" email, which holds a
+// passwordless sign-in link.
+type LoginLinkData struct {
+	Layout
+	Name string
+	Link string
+}
+
+// OrgInvitationData is the data for the "org_invitation" email, which
+// invites someone to join an organization. Name is the address it's sent to
+// if they don't have an account yet.
+type OrgInvitationData struct {
+	Layout
+	Name      string
+	InvitedBy string
+	Org       string
+	Role      string
+	Link      string
+}
+
+// previewData returns made-up data for previewing each email, with links to
+// the site at siteURL.
+var previewData = map[string]func(siteURL string) any{
+	"welcome": func(siteURL string) any {
+		return WelcomeData{Layout: Layout{SiteURL: siteURL}, Name: "Alice"}
+	},
+	"verify_email": func(siteURL string) any {
+		return VerifyEmailData{Layout: Layout{SiteURL: siteURL}, Name: "Alice", Link: siteURL + "/account/email/confirm?sig=PREVIEW"}
+	},
+	"email_changed": func(siteURL string) any {
+		return EmailChangedData{Layout: Layout{SiteURL: siteURL}, Name: "Alice", NewEmail: "alice@new.example.com"}
+	},
+	"login_link": func(siteURL string) any {
+		return LoginLinkData{Layout: Layout{SiteURL: siteURL}, Name: "Alice", Link: siteURL + "/user/login/magic/link?sig=PREVIEW"}
+	},
+	"org_invitation": func(siteURL string) any {
+		return OrgInvitationData{Layout: Layout{SiteURL: siteURL, Unsubscribe: siteURL + "/email/unsubscribe?sig=PREVIEW"}, Name: "Alice", InvitedBy: "Bob", Org: "Acme", Role: "member", Link: siteURL + "/orgs/invitation/PREVIEW"}
+	},
+}
+
+// email is one kind of email, parsed twice: as text for the subject and
+// plain text body, and as HTML for the HTML body.
+type email struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders the application's emails. Each email is a file in the
+// templates directory which defines "subject", "text" and "html" templates.
+// The text and HTML bodies are put into the shared base.txt and base.html
+// layouts, and the stylesheet is inlined into the HTML, as many email
+// clients ignore <style> elements.
+type Templates struct {
+	emails map[string]*email
+	css    []cssRule
+}
+
+// NewTemplates parses the email templates. It fails if any are broken, so
+// that's found out at startup rather than when the email is sent.
+func NewTemplates() (*Templates, error) {
+	files, err := fs.Glob(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{emails: map[string]*email{}}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		if strings.HasPrefix(name, "base.") {
+			continue
+		}
+
+		text, err := texttemplate.ParseFS(templateFiles, "templates/base.txt.tmpl", file)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: parsing %s: %w", file, err)
+		}
+		html, err := htmltemplate.ParseFS(templateFiles, "templates/base.html.tmpl", file)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: parsing %s: %w", file, err)
+		}
+		for _, block := range []string{"subject", "text", "html"} {
+			if text.Lookup(block) == nil {
+				return nil, fmt.Errorf("mailer: %s doesn't define %q", file, block)
+			}
+		}
+
+		t.emails[name] = &email{text: text, html: html}
+	}
+
+	css, err := templateFiles.ReadFile("templates/styles.css")
+	if err != nil {
+		return nil, err
+	}
+	t.css, err = parseCSS(string(css))
+	if err != nil {
+		return nil, fmt.Errorf("mailer: styles.css: %w", err)
+	}
+
+	return t, nil
+}
+
+// Names returns the names of the emails, in order.
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.emails))
+	for name := range t.emails {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render returns the named email, addressed to to and filled in with data.
+func (t *Templates) Render(to, name string, data any) (Message, error) {
+	e, ok := t.emails[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mailer: there's no %q email", name)
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if err := e.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: rendering %s: %w", name, err)
+	}
+	if err := e.text.ExecuteTemplate(&text, "base.txt", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: rendering %s: %w", name, err)
+	}
+	if err := e.html.ExecuteTemplate(&html, "base.html", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: rendering %s: %w", name, err)
+	}
+
+	msg := Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimLeft(text.String(), "\n"),
+		HTML:    inlineCSS(html.String(), t.css),
+	}
+	if d, ok := data.(interface{ layout() Layout }); ok {
+		msg.Unsubscribe = d.layout().Unsubscribe
+	}
+	return msg, nil
+}
+
+// Preview renders the named email with made-up data, for checking how it
+// looks. Links are made absolute with siteURL.
+func (t *Templates) Preview(name, siteURL string) (Message, error) {
+	data, ok := previewData[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mailer: there's no %q email", name)
+	}
+
+	return t.Render("alice@example.com", name, data(siteURL))
+}
+
+// cssRule is a rule from the stylesheet. Only simple selectors are
+// supported: an element, a class, or an element with a class.
+type cssRule struct {
+	tag          string
+	class        string
+	declarations string
+}
+
+// specificity orders the rules so that more specific ones are applied
+// later, and so win.
+func (r cssRule) specificity() int {
+	n := 0
+	if r.tag != "" {
+		n++
+	}
+	if r.class != "" {
+		n += 10
+	}
+	return n
+}
+
+var (
+	cssComment  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssSelector = regexp.MustCompile(`^([a-z][a-z0-9]*)?(?:\.([a-zA-Z0-9_-]+))?$`)
+)
+
+func parseCSS(css string) ([]cssRule, error) {
+	css = cssComment.ReplaceAllString(css, "")
+
+	var rules []cssRule
+
+	blocks := strings.Split(css, "}")
+	if last := strings.TrimSpace(blocks[len(blocks)-1]); last != "" {
+		return nil, fmt.Errorf("missing } after %q", last)
+	}
+
+	for _, block := range blocks[:len(blocks)-1] {
+
+		selectors, body, ok := strings.Cut(block, "{")
+		if !ok {
+			return nil, fmt.Errorf("missing { in %q", strings.TrimSpace(block))
+		}
+
+		var declarations []string
+		for _, d := range strings.Split(body, ";") {
+			if d = strings.Join(strings.Fields(d), " "); d != "" {
+				declarations = append(declarations, d)
+			}
+		}
+
+		for _, selector := range strings.Split(selectors, ",") {
+			selector = strings.TrimSpace(selector)
+			m := cssSelector.FindStringSubmatch(selector)
+			if m == nil || selector == "" {
+				return nil, fmt.Errorf("unsupported selector %q", selector)
+			}
+			rules = append(rules, cssRule{tag: m[1], class: m[2], declarations: strings.Join(declarations, "; ")})
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].specificity() < rules[j].specificity()
+	})
+	return rules, nil
+}
+
+var (
+	htmlStartTag   = regexp.MustCompile(`<([a-z][a-z0-9]*)(\s[^>]*)?>`)
+	htmlClassAttr  = regexp.MustCompile(`\sclass="([^"]*)"`)
+	htmlStyleAttr  = regexp.MustCompile(`\sstyle="([^"]*)"`)
+	htmlAttrEscape = strings.NewReplacer(`&`, "&amp;", `"`, "&#34;", `<`, "&lt;", `>`, "&gt;")
+)
+
+// inlineCSS copies the declarations of each rule into the style attribute
+// of the elements it matches. Declarations already in a style attribute take
+// precedence. It relies on the HTML coming from html/template, which escapes
+// any > inside attribute values.
+func inlineCSS(html string, rules []cssRule) string {
+	return htmlStartTag.ReplaceAllStringFunc(html, func(tag string) string {
+		m := htmlStartTag.FindStringSubmatch(tag)
+		name, attrs := m[1], m[2]
+
+		var classes []string
+		if c := htmlClassAttr.FindStringSubmatch(attrs); c != nil {
+			classes = strings.Fields(c[1])
+		}
+
+		var styles []string
+		for _, r := range rules {
+			if r.tag != "" && r.tag != name {
+				continue
+			}
+			if r.class != "" && !contains(classes, r.class) {
+				continue
+			}
+			styles = append(styles, r.declarations)
+		}
+		if len(styles) == 0 {
+			return tag
+		}
+
+		if s := htmlStyleAttr.FindStringSubmatch(attrs); s != nil {
+			styles = append(styles, strings.TrimSuffix(strings.TrimSpace(s[1]), ";"))
+			attrs = htmlStyleAttr.ReplaceAllString(attrs, "")
+		}
+
+		return fmt.Sprintf(`<%s%s style="%s">`, name, attrs, htmlAttrEscape.Replace(strings.Join(styles, "; ")))
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
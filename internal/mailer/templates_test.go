@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every email can be previewed.
+	names := templates.Names()
+	assert.Equal(t, len(names), len(previewData))
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			msg, err := templates.Preview(name, "https://snippets.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, msg.To, "alice@example.com")
+			assert.Equal(t, msg.Subject != "" && !strings.Contains(msg.Subject, "\n"), true)
+			assert.StringContains(t, msg.Body, "Hi Alice,")
+			assert.StringContains(t, msg.Body, "\n--\nYou're getting this email")
+			assert.StringContains(t, msg.HTML, "<p style=\"margin: 0 0 16px 0\">Hi Alice,</p>")
+			assert.StringContains(t, msg.HTML, `<a class="brand" href="https://snippets.example.com" style="color: #62cb31; color: #ffffff;`)
+		})
+	}
+
+	msg, err := templates.Render("bob@example.com", "login_link", LoginLinkData{
+		Layout: Layout{SiteURL: "https://snippets.example.com"},
+		Name:   "<Bob>",
+		Link:   "https://snippets.example.com/user/login/magic/TOKEN",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, msg.Subject, "Your Snippetbox sign-in link")
+	assert.StringContains(t, msg.Body, "Hi <Bob>,")
+	assert.StringContains(t, msg.Body, "\nhttps://snippets.example.com/user/login/magic/TOKEN\n")
+	assert.StringContains(t, msg.HTML, "Hi &lt;Bob&gt;,")
+	assert.StringContains(t, msg.HTML, `href="https://snippets.example.com/user/login/magic/TOKEN"`)
+
+	assert.Equal(t, msg.Unsubscribe, "")
+	assert.Equal(t, strings.Contains(msg.Body, "To stop getting emails"), false)
+
+	// Emails which can be unsubscribed from say how in the footer, and
+	// pass the link on for the headers.
+	msg, err = templates.Preview("org_invitation", "https://snippets.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, msg.Unsubscribe, "https://snippets.example.com/email/unsubscribe?sig=PREVIEW")
+	assert.StringContains(t, msg.Body, "To stop getting emails like this one, open this link:\nhttps://snippets.example.com/email/unsubscribe?sig=PREVIEW\n")
+	assert.StringContains(t, msg.HTML, `href="https://snippets.example.com/email/unsubscribe?sig=PREVIEW"`)
+
+	_, err = templates.Render("bob@example.com", "nope", nil)
+	assert.StringContains(t, err.Error(), `there's no "nope" email`)
+}
+
+func TestInlineCSS(t *testing.T) {
+	rules, err := parseCSS(`
+		/* Comments are ignored. */
+		p.note, .note { color: red; }
+		p { margin: 0;
+		    color: black }
+		a { color: blue }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := inlineCSS(`<p class="note">Hi <a href="/x?a=1&amp;b=2" style="color: green;">there</a></p><p><br></p>`, rules)
+
+	// More specific rules come later, and the element's own style last.
+	assert.Equal(t, html, `<p class="note" style="margin: 0; color: black; color: red; color: red">Hi <a href="/x?a=1&amp;b=2" style="color: blue; color: green">there</a></p><p style="margin: 0; color: black"><br></p>`)
+
+	for _, css := range []string{"div > p { color: red }", "#id { color: red }", "p { color: red"} {
+		_, err := parseCSS(css)
+		assert.Equal(t, err != nil, true)
+	}
+}
@@ -0,0 +1,122 @@
+// Package metadata works out facts about a snippet which are worth showing
+// alongside it, like how long it is and how long it takes to read. They're
+// worked out once, when the snippet is saved, by running its files through a
+// Pipeline, and stored with it.
+package metadata
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"math"
+	"strings"
+	"time"
+)
+
+// Version identifies what the Default pipeline works out. It's stored along
+// with the metadata, and should be bumped whenever a step is added or
+// changed, so that metadata worked out by an older version can be found and
+// worked out again.
+const Version = 1
+
+// The speeds read times are estimated from. Code is read much more slowly
+// than prose, so a snippet is assumed to take as long as the slower of
+// reading its words and reading its lines.
+const (
+	wordsPerMinute = 200
+	linesPerMinute = 30
+)
+
+// File is one of a snippet's files. Language is the one the owner chose, if
+// any.
+type File struct {
+	Filename string
+	Language string
+	Content  string
+}
+
+// Metadata is what a pipeline works out about a snippet's files, taken
+// together.
+type Metadata struct {
+	Lines       int `json:"lines"`
+	Bytes       int `json:"bytes"`
+	ReadSeconds int `json:"read_seconds"`
+	// LanguageConfidence is how sure, from 0 to 1, the guess at the first
+	// file's language is. It's 0 if the owner chose the language or it
+	// couldn't be guessed.
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+}
+
+// ReadMinutes returns the estimated read time in whole minutes, rounded up,
+// and never less than one.
+func (m *Metadata) ReadMinutes() int {
+	return max(int(math.Ceil(float64(m.ReadSeconds)/60)), 1)
+}
+
+// Size returns the size of the files for people to read, like "12.5 KB".
+func (m *Metadata) Size() string {
+	switch {
+	case m.Bytes < 1024:
+		return fmt.Sprintf("%d bytes", m.Bytes)
+	case m.Bytes < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(m.Bytes)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(m.Bytes)/(1024*1024))
+	}
+}
+
+// ConfidencePercent returns LanguageConfidence as a whole percentage.
+func (m *Metadata) ConfidencePercent() int {
+	return int(math.Round(m.LanguageConfidence * 100))
+}
+
+// Step works out some of the metadata from the files. Steps run in order,
+// so a step can use what the steps before it worked out.
+type Step func(files []File, m *Metadata)
+
+// Pipeline is a list of steps which together work out a snippet's metadata.
+type Pipeline []Step
+
+// Default is the pipeline snippets are run through when they're saved.
+var Default = Pipeline{countSize, estimateReadTime, languageConfidence}
+
+// Run runs the files through each step of the pipeline in turn, and returns
+// what they worked out.
+func (p Pipeline) Run(files []File) *Metadata {
+	m := &Metadata{}
+	for _, step := range p {
+		step(files, m)
+	}
+	return m
+}
+
+// countSize counts the lines and bytes of the files. A final line ending
+// doesn't start another line.
+func countSize(files []File, m *Metadata) {
+	for _, f := range files {
+		m.Bytes += len(f.Content)
+		if f.Content != "" {
+			m.Lines += strings.Count(strings.TrimSuffix(f.Content, "\n"), "\n") + 1
+		}
+	}
+}
+
+// estimateReadTime estimates how long the files take to read, from the
+// lines counted by countSize and the words in the files.
+func estimateReadTime(files []File, m *Metadata) {
+	words := 0
+	for _, f := range files {
+		words += len(strings.Fields(f.Content))
+	}
+
+	minutes := max(float64(words)/wordsPerMinute, float64(m.Lines)/linesPerMinute)
+	m.ReadSeconds = int(math.Ceil(minutes * time.Minute.Seconds()))
+}
+
+// languageConfidence records how sure the guess at the first file's
+// language is, unless its owner chose the language.
+func languageConfidence(files []File, m *Metadata) {
+	if len(files) == 0 || files[0].Language != "" {
+		return
+	}
+	_, m.LanguageConfidence = languages.Guess(files[0].Filename, files[0].Content)
+}
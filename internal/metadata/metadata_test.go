@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	files := []File{
+		{Filename: "main.go", Content: "package main\n\nfunc main() {}\n"},
+		{Filename: "README", Language: "markdown", Content: "# Hello"},
+	}
+
+	m := Default.Run(files)
+	assert.Equal(t, m.Lines, 4)
+	assert.Equal(t, m.Bytes, 36)
+	// Four lines at 30 a minute take longer than seven words at 200.
+	assert.Equal(t, m.ReadSeconds, 8)
+	assert.Equal(t, m.ReadMinutes(), 1)
+	assert.Equal(t, m.LanguageConfidence, 1.0)
+	assert.Equal(t, m.ConfidencePercent(), 100)
+	assert.Equal(t, m.Size(), "36 bytes")
+
+	// There's no confidence to speak of in a language the owner chose.
+	files[0].Language = "go"
+	assert.Equal(t, Default.Run(files).LanguageConfidence, 0.0)
+}
+
+func TestReadTime(t *testing.T) {
+	// Prose with long lines is read by the word.
+	prose := strings.Repeat("word ", 1000)
+	m := Default.Run([]File{{Content: prose}})
+	assert.Equal(t, m.Lines, 1)
+	assert.Equal(t, m.ReadSeconds, 300)
+	assert.Equal(t, m.ReadMinutes(), 5)
+	assert.Equal(t, m.Size(), "4.9 KB")
+}
+
+func TestPipeline(t *testing.T) {
+	// Steps run in order, and see what the ones before them worked out.
+	double := func(files []File, m *Metadata) { m.Lines *= 2 }
+	m := Pipeline{countSize, double}.Run([]File{{Content: "a\nb"}})
+	assert.Equal(t, m.Lines, 4)
+
+	m = Pipeline{}.Run(nil)
+	assert.Equal(t, *m, Metadata{})
+}
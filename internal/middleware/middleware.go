@@ -0,0 +1,132 @@
+// Package middleware composes HTTP middleware into named stacks.
+//
+// A Stack lists its middleware outermost first, and remembers which stack it
+// was built from, so that the chain a route runs through can be read off at
+// a glance, logged at startup, and checked against rules about what has to
+// come before what.
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Func is the usual shape of a middleware function.
+type Func func(http.Handler) http.Handler
+
+// Middleware is a middleware function with a name, by which stacks are
+// described and rules refer to it.
+type Middleware struct {
+	Name string
+	Func Func
+}
+
+// New names a middleware function.
+func New(name string, fn Func) Middleware {
+	return Middleware{Name: name, Func: fn}
+}
+
+// Stack is a named list of middleware, outermost first. Stacks are values:
+// Extend returns a new stack and leaves the one it was called on alone, so
+// that several stacks can be built on the same base.
+type Stack struct {
+	Name string
+	// Base is the name of the stack this one extends, if any.
+	Base       string
+	Middleware []Middleware
+}
+
+// NewStack returns a stack of the given middleware, outermost first.
+func NewStack(name string, mw ...Middleware) Stack {
+	return Stack{Name: name, Middleware: append([]Middleware(nil), mw...)}
+}
+
+// Extend returns a new stack with the middleware of s followed by mw, which
+// run inside it.
+func (s Stack) Extend(name string, mw ...Middleware) Stack {
+	all := make([]Middleware, 0, len(s.Middleware)+len(mw))
+	all = append(all, s.Middleware...)
+	all = append(all, mw...)
+	return Stack{Name: name, Base: s.Name, Middleware: all}
+}
+
+// Names returns the names of the stack's middleware, outermost first.
+func (s Stack) Names() []string {
+	names := make([]string, len(s.Middleware))
+	for i, m := range s.Middleware {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// index returns the position of the named middleware in the stack, or -1.
+func (s Stack) index(name string) int {
+	for i, m := range s.Middleware {
+		if m.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Then wraps h in the stack's middleware, so that the first middleware in
+// the stack sees the request first.
+func (s Stack) Then(h http.Handler) http.Handler {
+	for i := len(s.Middleware) - 1; i >= 0; i-- {
+		h = s.Middleware[i].Func(h)
+	}
+	return h
+}
+
+// ThenFunc is Then for a handler function.
+func (s Stack) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return s.Then(fn)
+}
+
+// String describes the stack, like "protected (extends dynamic): session >
+// authenticate > requireAuthentication".
+func (s Stack) String() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	if s.Base != "" {
+		fmt.Fprintf(&b, " (extends %s)", s.Base)
+	}
+	b.WriteString(": ")
+	if len(s.Middleware) == 0 {
+		b.WriteString("(none)")
+	}
+	b.WriteString(strings.Join(s.Names(), " > "))
+	return b.String()
+}
+
+// Rule says that the middleware named Outer must wrap the one named Inner,
+// in any stack which has both.
+type Rule struct {
+	Outer string
+	Inner string
+}
+
+// Check checks the stack against the rules, and that no middleware is in it
+// twice, and returns what's wrong, if anything.
+func (s Stack) Check(rules ...Rule) error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, m := range s.Middleware {
+		if seen[m.Name] {
+			errs = append(errs, fmt.Errorf("%s: %s is in the stack twice", s.Name, m.Name))
+		}
+		seen[m.Name] = true
+	}
+
+	for _, r := range rules {
+		outer, inner := s.index(r.Outer), s.index(r.Inner)
+		if outer >= 0 && inner >= 0 && outer > inner {
+			errs = append(errs, fmt.Errorf("%s: %s must wrap %s", s.Name, r.Outer, r.Inner))
+		}
+	}
+
+	return errors.Join(errs...)
+}
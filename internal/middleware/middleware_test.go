@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recorder returns middleware which appends its name to trace when a
+// request passes through it.
+func recorder(name string, trace *[]string) Middleware {
+	return New(name, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name)
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+func TestStack(t *testing.T) {
+	var trace []string
+
+	base := NewStack("base", recorder("a", &trace), recorder("b", &trace))
+	extended := base.Extend("extended", recorder("c", &trace))
+	other := base.Extend("other", recorder("d", &trace))
+
+	// Extending a stack leaves it alone, even when it's extended twice.
+	assert.Equal(t, strings.Join(base.Names(), ","), "a,b")
+	assert.Equal(t, strings.Join(extended.Names(), ","), "a,b,c")
+	assert.Equal(t, strings.Join(other.Names(), ","), "a,b,d")
+
+	h := extended.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, strings.Join(trace, ","), "a,b,c,handler")
+
+	assert.Equal(t, base.String(), "base: a > b")
+	assert.Equal(t, extended.String(), "extended (extends base): a > b > c")
+	assert.Equal(t, NewStack("none").String(), "none: (none)")
+}
+
+func TestStackCheck(t *testing.T) {
+	var trace []string
+
+	s := NewStack("s", recorder("session", &trace), recorder("auth", &trace))
+
+	assert.Equal(t, s.Check(Rule{"session", "auth"}, Rule{"auth", "missing"}), nil)
+
+	err := s.Check(Rule{"auth", "session"})
+	assert.Equal(t, err.Error(), "s: auth must wrap session")
+
+	err = s.Extend("t", recorder("session", &trace)).Check()
+	assert.Equal(t, err.Error(), "t: session is in the stack twice")
+}
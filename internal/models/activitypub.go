@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/crypto"
+	"time"
+)
+
+// ActivityPubDelivery is an activity waiting to be delivered to an inbox.
+type ActivityPubDelivery struct {
+	ID          int
+	Inbox       string
+	Activity    string
+	Attempts    int
+	NextAttempt time.Time
+	Error       string
+	Created     time.Time
+}
+
+type ActivityPubModelInterface interface {
+	Key() (public, private string, err error)
+	InsertKey(public, private string) error
+	AddFollower(actor, inbox string) error
+	RemoveFollower(actor string) error
+	FollowerCount() (int, error)
+	FollowerInboxes() ([]string, error)
+	Enqueue(inboxes []string, activity string) error
+	Due(limit int) ([]*ActivityPubDelivery, error)
+	Delivered(id int) error
+	Failed(id int, message string, retryAt time.Time) error
+}
+
+// ActivityPubModel stores the instance actor's key pair, its followers and
+// the activities waiting to be delivered to them. The private key is
+// encrypted with Keys, if it is set.
+type ActivityPubModel struct {
+	DB   DBTX
+	Keys *crypto.Keyring
+}
+
+// activityPubKeyID is the ID of the only row in activitypub_keys.
+const activityPubKeyID = 1
+
+// Key returns the actor's key pair as PEM, or ErrNoRecord if it hasn't been
+// made yet.
+func (m *ActivityPubModel) Key() (public, private string, err error) {
+	err = m.DB.QueryRow(`SELECT public_key, private_key FROM activitypub_keys WHERE id = ?`, activityPubKeyID).Scan(&public, &private)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrNoRecord
+		}
+		return "", "", err
+	}
+
+	private, err = openField(m.Keys, private, "activitypub_keys.private_key", activityPubKeyID)
+	if err != nil {
+		return "", "", err
+	}
+	return public, private, nil
+}
+
+// InsertKey stores the actor's key pair unless there already is one, as
+// another instance of the application may have beaten us to it. Call Key
+// afterwards to get the pair which won.
+func (m *ActivityPubModel) InsertKey(public, private string) error {
+	private, err := sealField(m.Keys, private, "activitypub_keys.private_key", activityPubKeyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.Exec(`INSERT IGNORE INTO activitypub_keys (id, public_key, private_key, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`,
+		activityPubKeyID, public, private)
+	return err
+}
+
+// AddFollower records that actor follows the instance, updating their inbox
+// if they already did.
+func (m *ActivityPubModel) AddFollower(actor, inbox string) error {
+	stmt := `INSERT INTO activitypub_followers (actor, inbox, created) VALUES(?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE inbox = VALUES(inbox)`
+
+	_, err := m.DB.Exec(stmt, actor, inbox)
+	return err
+}
+
+// RemoveFollower forgets a follower. Removing someone who wasn't following
+// does nothing.
+func (m *ActivityPubModel) RemoveFollower(actor string) error {
+	_, err := m.DB.Exec(`DELETE FROM activitypub_followers WHERE actor = ?`, actor)
+	return err
+}
+
+func (m *ActivityPubModel) FollowerCount() (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM activitypub_followers`).Scan(&n)
+	return n, err
+}
+
+// FollowerInboxes returns the inboxes to deliver to so that every follower
+// gets an activity. Followers on the same server usually share an inbox, so
+// there may be fewer inboxes than followers.
+func (m *ActivityPubModel) FollowerInboxes() ([]string, error) {
+	rows, err := m.DB.Query(`SELECT DISTINCT inbox FROM activitypub_followers ORDER BY inbox`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inbox string
+		if err = rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return inboxes, nil
+}
+
+// Enqueue queues an activity to be delivered to each of the inboxes as soon
+// as possible.
+func (m *ActivityPubModel) Enqueue(inboxes []string, activity string) error {
+	return transact(m.DB, func(tx DBTX) error {
+		for _, inbox := range inboxes {
+			_, err := tx.Exec(`INSERT INTO activitypub_deliveries (inbox, activity, next_attempt, created, updated)
+    VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), UTC_TIMESTAMP())`, inbox, activity)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Due claims up to limit deliveries whose time has come, oldest first,
+// counting the attempt about to be made. As with the email queue, a claimed
+// delivery isn't due again for ten minutes.
+func (m *ActivityPubModel) Due(limit int) ([]*ActivityPubDelivery, error) {
+	stmt := `SELECT id, inbox, activity, attempts, next_attempt, error, created
+    FROM activitypub_deliveries WHERE next_attempt <= UTC_TIMESTAMP() ORDER BY next_attempt, id LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var due []*ActivityPubDelivery
+	for rows.Next() {
+		d := &ActivityPubDelivery{}
+		err = rows.Scan(&d.ID, &d.Inbox, &d.Activity, &d.Attempts, &d.NextAttempt, &d.Error, &d.Created)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var claimed []*ActivityPubDelivery
+
+	for _, d := range due {
+		result, err := m.DB.Exec(`UPDATE activitypub_deliveries SET attempts = attempts + 1, next_attempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL 10 MINUTE), updated = UTC_TIMESTAMP()
+    WHERE id = ? AND attempts = ?`, d.ID, d.Attempts)
+		if err != nil {
+			return claimed, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return claimed, err
+		}
+		if n == 1 {
+			d.Attempts++
+			claimed = append(claimed, d)
+		}
+	}
+
+	return claimed, nil
+}
+
+// Delivered removes a delivery which has been made from the queue.
+func (m *ActivityPubModel) Delivered(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM activitypub_deliveries WHERE id = ?`, id)
+	return err
+}
+
+// Failed records that a delivery failed. It's tried again at retryAt or, if
+// retryAt is zero, given up on and removed from the queue.
+func (m *ActivityPubModel) Failed(id int, message string, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		_, err := m.DB.Exec(`DELETE FROM activitypub_deliveries WHERE id = ?`, id)
+		return err
+	}
+
+	if len(message) > 255 {
+		message = message[:255]
+	}
+
+	_, err := m.DB.Exec(`UPDATE activitypub_deliveries SET error = ?, next_attempt = ?, updated = UTC_TIMESTAMP() WHERE id = ?`,
+		message, retryAt.UTC(), id)
+	return err
+}
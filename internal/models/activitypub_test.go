@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestActivityPubModelKey(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := ActivityPubModel{DB: db}
+
+	_, _, err := m.Key()
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	if err = m.InsertKey("public", "private"); err != nil {
+		t.Fatal(err)
+	}
+	// The first key to be stored wins.
+	if err = m.InsertKey("other public", "other private"); err != nil {
+		t.Fatal(err)
+	}
+
+	public, private, err := m.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, public, "public")
+	assert.Equal(t, private, "private")
+}
+
+func TestActivityPubModelFollowers(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := ActivityPubModel{DB: db}
+
+	for _, f := range []struct{ actor, inbox string }{
+		{"https://a.example/users/alice", "https://a.example/inbox"},
+		{"https://a.example/users/bob", "https://a.example/inbox"},
+		{"https://b.example/users/carol", "https://b.example/users/carol/inbox"},
+		{"https://b.example/users/carol", "https://b.example/inbox"},
+	} {
+		if err := m.AddFollower(f.actor, f.inbox); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := m.FollowerCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 3)
+
+	inboxes, err := m.FollowerInboxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(inboxes), 2)
+	assert.Equal(t, inboxes[0], "https://a.example/inbox")
+	assert.Equal(t, inboxes[1], "https://b.example/inbox")
+
+	if err = m.RemoveFollower("https://b.example/users/carol"); err != nil {
+		t.Fatal(err)
+	}
+	inboxes, err = m.FollowerInboxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(inboxes), 1)
+}
+
+func TestActivityPubModelDeliveries(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := ActivityPubModel{DB: db}
+
+	err := m.Enqueue([]string{"https://a.example/inbox", "https://b.example/inbox"}, `{"type":"Create"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 2)
+	assert.Equal(t, due[0].Activity, `{"type":"Create"}`)
+	assert.Equal(t, due[0].Attempts, 1)
+
+	// Claimed deliveries aren't due again until they're retried.
+	again, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(again), 0)
+
+	if err = m.Delivered(due[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Failed(due[1].ID, "503 Service Unavailable", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err = m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Attempts, 2)
+	assert.Equal(t, due[0].Error, "503 Service Unavailable")
+
+	if err = m.Failed(due[0].ID, "503 Service Unavailable", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Failed(due[0].ID, "", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	due, err = m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 0)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// APIRateLimit is a user's own limit on JSON API requests, replacing the
+// default one. UserName is only filled in by All.
+type APIRateLimit struct {
+	UserID          int
+	UserName        string
+	RequestsPerHour int
+	Burst           int
+	Updated         time.Time
+}
+
+type APIRateLimitModelInterface interface {
+	Get(userID int) (*APIRateLimit, error)
+	Set(userID, requestsPerHour, burst int) error
+	Delete(userID int) error
+	All() ([]*APIRateLimit, error)
+}
+
+type APIRateLimitModel struct {
+	DB DBTX
+}
+
+// Get returns the user's rate limit, or ErrNoRecord if they don't have their
+// own and should get the default.
+func (m *APIRateLimitModel) Get(userID int) (*APIRateLimit, error) {
+	l := &APIRateLimit{}
+
+	stmt := `SELECT user_id, requests_per_hour, burst, updated FROM api_rate_limits WHERE user_id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&l.UserID, &l.RequestsPerHour, &l.Burst, &l.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Set gives the user their own rate limit, replacing any they had before.
+func (m *APIRateLimitModel) Set(userID, requestsPerHour, burst int) error {
+	stmt := `INSERT INTO api_rate_limits (user_id, requests_per_hour, burst, updated)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE requests_per_hour = VALUES(requests_per_hour), burst = VALUES(burst), updated = VALUES(updated)`
+
+	_, err := m.DB.Exec(stmt, userID, requestsPerHour, burst)
+	return err
+}
+
+// Delete puts the user back on the default rate limit.
+func (m *APIRateLimitModel) Delete(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM api_rate_limits WHERE user_id = ?`, userID)
+	return err
+}
+
+// All returns every user's own rate limit, with their names, in order of
+// user ID.
+func (m *APIRateLimitModel) All() ([]*APIRateLimit, error) {
+	stmt := `SELECT l.user_id, u.name, l.requests_per_hour, l.burst, l.updated
+    FROM api_rate_limits l INNER JOIN users u ON u.id = l.user_id ORDER BY l.user_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []*APIRateLimit{}
+
+	for rows.Next() {
+		l := &APIRateLimit{}
+		err = rows.Scan(&l.UserID, &l.UserName, &l.RequestsPerHour, &l.Burst, &l.Updated)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
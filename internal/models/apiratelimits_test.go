@@ -0,0 +1,42 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestAPIRateLimitModel(t *testing.T) {
+	m := APIRateLimitModel{DB: testutils.NewTestDB(t)}
+
+	_, err := m.Get(1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	if err = m.Set(1, 100, 10); err != nil {
+		t.Fatal(err)
+	}
+	// Setting it again replaces the limit.
+	if err = m.Set(1, 500, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, l.RequestsPerHour, 500)
+	assert.Equal(t, l.Burst, 50)
+
+	all, err := m.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(all), 1)
+	assert.Equal(t, all[0].UserName, "Alice")
+
+	if err = m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Get(1)
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	LastUsed *time.Time
+}
+
+type APITokenModelInterface interface {
+	Insert(userID int, name string) (string, error)
+	Authenticate(token string) (int, error)
+	ForUser(userID int) ([]*APIToken, error)
+	Delete(id, userID int) error
+}
+
+// APITokenModel stores the bearer tokens users create for scripts and the
+// command line. Like sign-in tokens, only a hash of each one is kept, so a
+// token can only be seen once: when it's created.
+type APITokenModel struct {
+	DB DBTX
+}
+
+// Insert creates a token for the user and returns it.
+func (m *APITokenModel) Insert(userID int, name string) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate returns the ID of the user a token belongs to and records
+// that it has been used. It returns ErrInvalidToken if there's no such token.
+func (m *APITokenModel) Authenticate(token string) (int, error) {
+	var id, userID int
+
+	hash := hashToken(token)
+
+	err := m.DB.QueryRow(`SELECT id, user_id FROM api_tokens WHERE token_hash = ?`, hash).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// ForUser returns the user's tokens, oldest first.
+func (m *APITokenModel) ForUser(userID int) ([]*APIToken, error) {
+	stmt := `SELECT id, user_id, name, created, last_used FROM api_tokens WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+
+	for rows.Next() {
+		t := &APIToken{}
+		var lastUsed sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			t.LastUsed = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes one of the user's tokens. It returns ErrNoRecord if the
+// token doesn't exist or belongs to somebody else.
+func (m *APITokenModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestAPITokenModel(t *testing.T) {
+	m := APITokenModel{DB: testutils.NewTestDB(t)}
+
+	token, err := m.Insert(1, "Laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Authenticate("not-a-token")
+	assert.Equal(t, err, ErrInvalidToken)
+
+	userID, err := m.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, userID, 1)
+
+	tokens, err := m.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].Name, "Laptop")
+	assert.Equal(t, tokens[0].LastUsed != nil, true)
+
+	// Tokens can only be revoked by their owner.
+	err = m.Delete(tokens[0].ID, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Delete(tokens[0].ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Authenticate(token)
+	assert.Equal(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,350 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Collection is a named group of a user's snippets, in an order they
+// choose.
+type Collection struct {
+	ID          int
+	UserID      int
+	Name        string
+	Slug        string
+	Description string
+	Created     time.Time
+	Updated     time.Time
+
+	// SnippetCount is only filled in by ForUser.
+	SnippetCount int
+}
+
+type CollectionModelInterface interface {
+	Insert(c *Collection) (int, error)
+	Get(id, userID int) (*Collection, error)
+	GetBySlug(slug string) (*Collection, error)
+	ForUser(userID int) ([]*Collection, error)
+	ForSnippet(snippetID int) ([]*Collection, error)
+	Update(c *Collection) error
+	Delete(id, userID int) error
+	AddSnippet(collectionID, snippetID int) error
+	RemoveSnippet(collectionID, snippetID int) error
+	MoveSnippet(collectionID, snippetID int, up bool) error
+	Snippets(collectionID int, all bool) ([]*Snippet, error)
+	SnippetsPage(collectionID int, all bool, limit, offset int) ([]*Snippet, error)
+}
+
+type CollectionModel struct {
+	DB DBTX
+}
+
+// collectionColumns are the columns scanCollection expects, in order.
+const collectionColumns = "c.id, c.user_id, c.name, c.slug, c.description, c.created, c.updated"
+
+func scanCollection(row interface{ Scan(dest ...any) error }, extra ...any) (*Collection, error) {
+	c := &Collection{}
+	dest := append([]any{&c.ID, &c.UserID, &c.Name, &c.Slug, &c.Description, &c.Created, &c.Updated}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Insert stores a new collection, giving it a slug made from its name, and
+// returns its ID. It returns ErrDuplicateCollectionName if the user already
+// has a collection with the same name.
+func (m *CollectionModel) Insert(c *Collection) (int, error) {
+	slug, err := newSlug(c.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO collections (user_id, name, slug, description, created, updated)
+    VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, c.UserID, c.Name, slug, c.Description)
+	if err != nil {
+		return 0, translateMySQLError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get returns one of the user's collections, or ErrNoRecord if they have no
+// collection with that ID.
+func (m *CollectionModel) Get(id, userID int) (*Collection, error) {
+	stmt := `SELECT ` + collectionColumns + ` FROM collections c WHERE c.id = ? AND c.user_id = ?`
+	return m.get(stmt, id, userID)
+}
+
+// GetBySlug returns the collection with the given slug, whoever it belongs
+// to, or ErrNoRecord if there isn't one.
+func (m *CollectionModel) GetBySlug(slug string) (*Collection, error) {
+	stmt := `SELECT ` + collectionColumns + ` FROM collections c WHERE c.slug = ?`
+	return m.get(stmt, slug)
+}
+
+func (m *CollectionModel) get(stmt string, args ...any) (*Collection, error) {
+	c, err := scanCollection(m.DB.QueryRow(stmt, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// ForUser returns the user's collections in order of name, with how many
+// snippets are in each.
+func (m *CollectionModel) ForUser(userID int) ([]*Collection, error) {
+	stmt := `SELECT ` + collectionColumns + `, COUNT(cs.snippet_id)
+    FROM collections c LEFT JOIN collection_snippets cs ON cs.collection_id = c.id
+    WHERE c.user_id = ? GROUP BY c.id ORDER BY c.name, c.id`
+
+	return m.list(stmt, true, userID)
+}
+
+// ForSnippet returns the collections which the snippet is in, in order of
+// name.
+func (m *CollectionModel) ForSnippet(snippetID int) ([]*Collection, error) {
+	stmt := `SELECT ` + collectionColumns + `
+    FROM collections c JOIN collection_snippets cs ON cs.collection_id = c.id
+    WHERE cs.snippet_id = ? ORDER BY c.name, c.id`
+
+	return m.list(stmt, false, snippetID)
+}
+
+func (m *CollectionModel) list(stmt string, counted bool, args ...any) ([]*Collection, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var count int
+		var extra []any
+		if counted {
+			extra = append(extra, &count)
+		}
+		c, err := scanCollection(rows, extra...)
+		if err != nil {
+			return nil, err
+		}
+		c.SnippetCount = count
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Update saves a new name and description for one of the user's
+// collections. The slug stays the same. It returns ErrNoRecord if the user
+// has no collection with c.ID, and ErrDuplicateCollectionName if they have
+// another collection with the new name.
+func (m *CollectionModel) Update(c *Collection) error {
+	stmt := `UPDATE collections SET name = ?, description = ?, updated = UTC_TIMESTAMP() WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, c.Name, c.Description, c.ID, c.UserID)
+	if err != nil {
+		return translateMySQLError(err)
+	}
+
+	// As with snippet templates, an update which changes nothing affects no
+	// rows, so check for the collection separately.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = m.Get(c.ID, c.UserID)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes one of the user's collections. The snippets in it are left
+// alone. It returns ErrNoRecord if the user has no collection with that ID.
+func (m *CollectionModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM collections WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// AddSnippet adds a snippet to the end of a collection. Adding a snippet
+// which is already in the collection does nothing. The caller must check
+// that both belong to the user.
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+    SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, snippetID, collectionID)
+	return err
+}
+
+// RemoveSnippet takes a snippet out of a collection, returning ErrNoRecord
+// if it wasn't in it.
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	result, err := m.DB.Exec(`DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`, collectionID, snippetID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// MoveSnippet swaps a snippet with the one before it in the collection, or
+// the one after it if up is false. Moving the first snippet up or the last
+// one down does nothing. It returns ErrNoRecord if the snippet isn't in the
+// collection.
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	neighbour := `SELECT snippet_id, position FROM collection_snippets
+    WHERE collection_id = ? AND position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if up {
+		neighbour = `SELECT snippet_id, position FROM collection_snippets
+    WHERE collection_id = ? AND position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	return transact(m.DB, func(tx DBTX) error {
+		var position int
+		err := tx.QueryRow(`SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`,
+			collectionID, snippetID).Scan(&position)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+
+		var otherID, otherPosition int
+		err = tx.QueryRow(neighbour, collectionID, position).Scan(&otherID, &otherPosition)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		stmt := `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+		if _, err = tx.Exec(stmt, otherPosition, collectionID, snippetID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(stmt, position, collectionID, otherID)
+		return err
+	})
+}
+
+// Snippets returns the snippets in a collection, in order. Only published
+// snippets are included unless all is set, for the collection's owner, in
+// which case their scheduled, burn after reading and encrypted snippets are
+// too. Expired and burned snippets are always left out.
+func (m *CollectionModel) Snippets(collectionID int, all bool) ([]*Snippet, error) {
+	stmt, args := m.snippets(collectionID, all).Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// SnippetsPage returns a page of the snippets Snippets returns, for going
+// through a large collection a few at a time.
+func (m *CollectionModel) SnippetsPage(collectionID int, all bool, limit, offset int) ([]*Snippet, error) {
+	stmt, args := m.snippets(collectionID, all).Page(limit, offset).Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+func (m *CollectionModel) snippets(collectionID int, all bool) *query.SelectBuilder {
+	d := dialect(m.DB)
+
+	q := publishedSnippets(d)
+	if all {
+		q = query.Select(snippetColumns...).
+			Where("expires > " + d.Now).
+			Where("burned IS NULL")
+	}
+
+	return q.From("snippets JOIN collection_snippets ON collection_snippets.snippet_id = snippets.id").
+		Where("collection_snippets.collection_id = ?", collectionID).
+		OrderBy("collection_snippets.position, snippets.id")
+}
+
+// newSlug makes a slug for a collection from its name, with a random suffix
+// so that collections with the same name don't clash and the slugs can't be
+// guessed.
+func newSlug(name string) (string, error) {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 80 {
+			break
+		}
+	}
+
+	random := make([]byte, 5)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	suffix := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+
+	if b.Len() == 0 {
+		return suffix, nil
+	}
+	return b.String() + "-" + suffix, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"regexp"
+	"testing"
+)
+
+func TestCollectionModel(t *testing.T) {
+	m := CollectionModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Collection{UserID: 1, Name: "Poems", Description: "Short ones"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Insert(&Collection{UserID: 1, Name: "Poems"})
+	assert.Equal(t, errors.Is(err, ErrDuplicateCollectionName), true)
+
+	c, err := m.Get(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, c.Description, "Short ones")
+
+	_, err = m.Get(id, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	bySlug, err := m.GetBySlug(c.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bySlug.ID, id)
+
+	// The pond, the expired snippet and the scheduled one.
+	for _, snippetID := range []int{1, 2, 3} {
+		if err = m.AddSnippet(id, snippetID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Adding a snippet twice does nothing.
+	if err = m.AddSnippet(id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := m.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(collections), 1)
+	assert.Equal(t, collections[0].SnippetCount, 3)
+
+	published, err := m.Snippets(id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(published), 1)
+	assert.Equal(t, published[0].ID, 1)
+
+	// The owner sees the scheduled snippet too, but nobody sees the expired
+	// one.
+	all, err := m.Snippets(id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(all), 2)
+	assert.Equal(t, all[0].ID, 1)
+	assert.Equal(t, all[1].ID, 3)
+
+	// Moving the scheduled snippet up skips over the expired one.
+	if err = m.MoveSnippet(id, 3, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.MoveSnippet(id, 3, true); err != nil {
+		t.Fatal(err)
+	}
+	all, err = m.Snippets(id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, all[0].ID, 3)
+	assert.Equal(t, all[1].ID, 1)
+
+	assert.Equal(t, m.MoveSnippet(id, 99, false), ErrNoRecord)
+
+	in, err := m.ForSnippet(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(in), 1)
+
+	if err = m.RemoveSnippet(id, 3); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, m.RemoveSnippet(id, 3), ErrNoRecord)
+
+	c.Name = "Haiku"
+	if err = m.Update(c); err != nil {
+		t.Fatal(err)
+	}
+	c, err = m.Get(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, c.Name, "Haiku")
+	assert.Equal(t, c.Slug, bySlug.Slug)
+
+	if err = m.Delete(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, m.Delete(id, 1), ErrNoRecord)
+}
+
+func TestNewSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Go Tips & Tricks", `^go-tips-tricks-[a-z2-7]{8}$`},
+		{"  Über  café 2024 ", `^ber-caf-2024-[a-z2-7]{8}$`},
+		{"!!!", `^[a-z2-7]{8}$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slug, err := newSlug(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, regexp.MustCompile(tt.want).MatchString(slug), true)
+		})
+	}
+}
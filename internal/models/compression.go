@@ -0,0 +1,184 @@
+package models
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"io"
+)
+
+// DefaultCompressAbove is the size in bytes above which snippet contents are
+// stored compressed, unless SnippetModel says otherwise.
+const DefaultCompressAbove = 16 << 10
+
+// compressContent returns what to store in the content and content_zlib
+// columns for content. Content longer than threshold bytes is compressed,
+// as long as that makes it smaller; a threshold below zero turns
+// compression off. The blob is nil, for NULL, when content isn't
+// compressed.
+func compressContent(content string, threshold int) (string, any, error) {
+	if threshold < 0 || len(content) <= threshold {
+		return content, nil, nil
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := io.WriteString(zw, content); err != nil {
+		return "", nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, err
+	}
+
+	if buf.Len() >= len(content) {
+		return content, nil, nil
+	}
+	return "", buf.Bytes(), nil
+}
+
+// decompressContent returns the content stored in the content and
+// content_zlib columns.
+func decompressContent(content string, blob []byte) (string, error) {
+	if blob == nil {
+		return content, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	var b bytes.Buffer
+	if _, err = b.ReadFrom(zr); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (m *SnippetModel) compressAbove() int {
+	if m.CompressAbove == 0 {
+		return DefaultCompressAbove
+	}
+	return m.CompressAbove
+}
+
+// storedContent is the content of a snippet or file as it's stored, and
+// where to find it.
+type storedContent struct {
+	table      string
+	where      string
+	args       []any
+	content    string
+	compressed []byte
+}
+
+// recompress rewrites c as the current threshold says it should be stored,
+// and reports whether it had to.
+func (m *SnippetModel) recompress(tx DBTX, c storedContent) (bool, error) {
+	content, err := decompressContent(c.content, c.compressed)
+	if err != nil {
+		return false, err
+	}
+
+	stored, compressed, err := compressContent(content, m.compressAbove())
+	if err != nil {
+		return false, err
+	}
+	if (compressed == nil) == (c.compressed == nil) {
+		return false, nil
+	}
+
+	stmt, args := query.Update(c.table).Set("content", stored).Set("content_zlib", compressed).Where(c.where, c.args...).Build()
+	_, err = tx.Exec(stmt, args...)
+	return err == nil, err
+}
+
+// Recompress brings the storage of up to limit snippets with IDs above
+// afterID, and their files, in line with CompressAbove: compressing large
+// contents which aren't, and decompressing small ones which are. It returns
+// the last ID it looked at, which is zero once there are no more, and how
+// many contents it rewrote. Each batch is locked while it's rewritten, so
+// the application can keep running.
+func (m *SnippetModel) Recompress(afterID, limit int) (int, int, error) {
+	lastID, changed := 0, 0
+
+	err := transact(m.DB, func(tx DBTX) error {
+		stmt, args := query.Select("id", "content", "content_zlib").
+			From("snippets").
+			Where("id > ?", afterID).
+			OrderBy("id").
+			Page(limit, 0).
+			ForUpdate().
+			Build()
+
+		rows, err := tx.Query(stmt, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var contents []storedContent
+		var ids []any
+
+		for rows.Next() {
+			var id int
+			c := storedContent{table: "snippets", where: "id = ?"}
+			if err = rows.Scan(&id, &c.content, &c.compressed); err != nil {
+				return err
+			}
+			c.args = []any{id}
+			contents = append(contents, c)
+			ids = append(ids, id)
+			lastID = id
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		if len(ids) > 0 {
+			stmt, args = query.Select("snippet_id", "position", "content", "content_zlib").
+				From("snippet_files").
+				WhereIn("snippet_id", ids).
+				ForUpdate().
+				Build()
+
+			rows, err = tx.Query(stmt, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var snippetID, position int
+				c := storedContent{table: "snippet_files", where: "snippet_id = ? AND position = ?"}
+				if err = rows.Scan(&snippetID, &position, &c.content, &c.compressed); err != nil {
+					return err
+				}
+				c.args = []any{snippetID, position}
+				contents = append(contents, c)
+			}
+			if err = rows.Err(); err != nil {
+				return err
+			}
+			rows.Close()
+		}
+
+		for _, c := range contents {
+			ok, err := m.recompress(tx, c)
+			if err != nil {
+				return err
+			}
+			if ok {
+				changed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return lastID, changed, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestCompressContent(t *testing.T) {
+	large := strings.Repeat("An old silent pond...\n", 100)
+
+	tests := []struct {
+		name       string
+		content    string
+		threshold  int
+		compressed bool
+	}{
+		{"Small", "An old silent pond...", 100, false},
+		{"Large", large, 100, true},
+		{"Disabled", large, -1, false},
+		{"Incompressible", "a9$Zq!x", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, blob, err := compressContent(tt.content, tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, blob != nil, tt.compressed)
+
+			var b []byte
+			if blob != nil {
+				b = blob.([]byte)
+				assert.Equal(t, content, "")
+				assert.Equal(t, len(b) < len(tt.content), true)
+			}
+
+			got, err := decompressContent(content, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, got, tt.content)
+		})
+	}
+}
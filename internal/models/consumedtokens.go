@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"time"
+)
+
+type ConsumedTokenModelInterface interface {
+	Consume(id string, expires time.Time) (bool, error)
+	DeleteExpired() (int, error)
+}
+
+// ConsumedTokenModel remembers the signed links which have been used, for
+// those which only work once. It implements signedurl.Store.
+type ConsumedTokenModel struct {
+	DB DBTX
+}
+
+// Consume records that the link with the given ID has been used, and
+// reports false if it already had been. The record is kept until expires.
+func (m *ConsumedTokenModel) Consume(id string, expires time.Time) (bool, error) {
+	stmt := `INSERT INTO consumed_tokens (id, expires) VALUES(?, ?)`
+
+	_, err := m.DB.Exec(stmt, id, expires.UTC())
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == mysqlDuplicateEntry {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteExpired forgets the links which wouldn't be accepted anymore, and
+// returns how many were forgotten.
+func (m *ConsumedTokenModel) DeleteExpired() (int, error) {
+	result, err := m.DB.Exec(`DELETE FROM consumed_tokens WHERE expires <= UTC_TIMESTAMP()`)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestConsumedTokenModelConsume(t *testing.T) {
+	m := ConsumedTokenModel{DB: testutils.NewTestDB(t)}
+
+	fresh, err := m.Consume("a", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fresh, true)
+
+	fresh, err = m.Consume("a", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fresh, false)
+
+	fresh, err = m.Consume("b", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fresh, true)
+}
+
+func TestConsumedTokenModelDeleteExpired(t *testing.T) {
+	m := ConsumedTokenModel{DB: testutils.NewTestDB(t)}
+
+	for id, expires := range map[string]time.Time{"a": time.Now().Add(-time.Minute), "b": time.Now().Add(time.Hour)} {
+		if _, err := m.Consume(id, expires); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// CSPReport is a Content Security Policy violation reported by a browser.
+type CSPReport struct {
+	ID                int
+	DocumentURI       string
+	ViolatedDirective string
+	BlockedURI        string
+	SourceFile        string
+	LineNumber        int
+	UserAgent         string
+	Created           time.Time
+}
+
+type CSPReportModelInterface interface {
+	Insert(report *CSPReport) error
+	Latest() ([]*CSPReport, error)
+}
+
+type CSPReportModel struct {
+	DB DBTX
+}
+
+func (m *CSPReportModel) Insert(report *CSPReport) error {
+	stmt := `INSERT INTO csp_reports (document_uri, violated_directive, blocked_uri, source_file, line_number, user_agent, created)
+    VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, report.DocumentURI, report.ViolatedDirective, report.BlockedURI,
+		report.SourceFile, report.LineNumber, report.UserAgent)
+	return err
+}
+
+// Latest returns the 100 most recent reports.
+func (m *CSPReportModel) Latest() ([]*CSPReport, error) {
+	stmt := `SELECT id, document_uri, violated_directive, blocked_uri, source_file, line_number, user_agent, created
+    FROM csp_reports ORDER BY id DESC LIMIT 100`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*CSPReport{}
+
+	for rows.Next() {
+		c := &CSPReport{}
+		err = rows.Scan(&c.ID, &c.DocumentURI, &c.ViolatedDirective, &c.BlockedURI, &c.SourceFile, &c.LineNumber, &c.UserAgent, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
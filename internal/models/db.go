@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+)
+
+// DBTX is the subset of methods shared by *sql.DB and *sql.Tx. The models
+// depend on this interface rather than on *sql.DB directly, which lets the
+// integration tests run every test inside a transaction that is rolled back
+// once the test has finished. In the application it's a *query.DB, which
+// caches prepared statements.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// txDBTX is a transaction started by begin.
+type txDBTX interface {
+	DBTX
+	Commit() error
+	Rollback() error
+}
+
+// begin starts a transaction on db. It returns nil if db can't start one,
+// because it's already a transaction.
+func begin(db DBTX) (txDBTX, error) {
+	switch db := db.(type) {
+	case interface{ Begin() (*query.Tx, error) }:
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case interface{ Begin() (*sql.Tx, error) }:
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	return nil, nil
+}
+
+// transact runs fn inside a transaction, committing it if fn succeeds and
+// rolling it back otherwise. If db is already a transaction (as it is in the
+// integration tests) it can't be nested, so fn simply runs inside it.
+func transact(db DBTX, fn func(tx DBTX) error) (err error) {
+	tx, err := begin(db)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fn(db)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return fn(tx)
+}
+
+// dialect returns the SQL dialect of db. Anything which doesn't say, like the
+// *sql.Tx of the integration tests, is MySQL.
+func dialect(db DBTX) *query.Dialect {
+	if d, ok := db.(interface{ Dialect() *query.Dialect }); ok {
+		return d.Dialect()
+	}
+	return query.MySQL
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Draft is the unsaved content of a user's create snippet form. Data is
+// opaque to the model: it holds the form fields however the caller chose
+// to encode them.
+type Draft struct {
+	UserID  int
+	Data    string
+	Updated time.Time
+}
+
+type DraftModelInterface interface {
+	Get(userID int) (*Draft, error)
+	Save(userID int, data string) error
+	Delete(userID int) error
+}
+
+// DraftModel keeps at most one draft for each user.
+type DraftModel struct {
+	DB DBTX
+}
+
+// Get returns the user's draft, or ErrNoRecord if they don't have one.
+func (m *DraftModel) Get(userID int) (*Draft, error) {
+	d := &Draft{}
+
+	stmt := `SELECT user_id, data, updated FROM drafts WHERE user_id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&d.UserID, &d.Data, &d.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Save replaces the user's draft.
+func (m *DraftModel) Save(userID int, data string) error {
+	stmt := `INSERT INTO drafts (user_id, data, updated) VALUES(?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE data = VALUES(data), updated = VALUES(updated)`
+
+	_, err := m.DB.Exec(stmt, userID, data)
+	return translateMySQLError(err)
+}
+
+// Delete removes the user's draft, if they have one.
+func (m *DraftModel) Delete(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM drafts WHERE user_id = ?`, userID)
+	return err
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestDraftModel(t *testing.T) {
+	m := DraftModel{DB: testutils.NewTestDB(t)}
+
+	_, err := m.Get(1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Save(1, "title=First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Save(1, "title=Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, d.Data, "title=Second")
+
+	err = m.Delete(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Get(1)
+	assert.Equal(t, err, ErrNoRecord)
+}
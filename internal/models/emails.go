@@ -0,0 +1,239 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/ngohoang211020/snippetbox/internal/crypto"
+	"time"
+)
+
+// QueuedEmail is an email waiting to be sent, or a dead letter: one which
+// was given up on. NextAttempt is zero for dead letters.
+type QueuedEmail struct {
+	ID      int
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+	// Unsubscribe is the one-click unsubscribe link for the email, if it
+	// has one.
+	Unsubscribe string
+	Attempts    int
+	NextAttempt time.Time
+	Error       string
+	Created     time.Time
+	Updated     time.Time
+}
+
+type EmailQueueModelInterface interface {
+	Enqueue(e *QueuedEmail) (int, error)
+	Due(limit int) ([]*QueuedEmail, error)
+	Sent(id int) error
+	Failed(id int, message string, retryAt time.Time) error
+	DeadLetters(limit int) ([]*QueuedEmail, error)
+	Retry(id int) error
+	DeleteDeadLetter(id int) error
+}
+
+// EmailQueueModel stores the emails waiting to be sent and the dead letters.
+// The bodies and unsubscribe links are encrypted with Keys, if it is set. A dead letter keeps the
+// ID it had in the queue, so its body can still be decrypted.
+type EmailQueueModel struct {
+	DB   DBTX
+	Keys *crypto.Keyring
+}
+
+// Enqueue queues an email to be sent as soon as possible and returns its ID.
+func (m *EmailQueueModel) Enqueue(e *QueuedEmail) (int, error) {
+	var id int
+
+	err := transact(m.DB, func(tx DBTX) error {
+		result, err := tx.Exec(`INSERT INTO email_queue (to_address, subject, body, html, unsubscribe, next_attempt, created, updated)
+    VALUES(?, ?, '', '', '', UTC_TIMESTAMP(), UTC_TIMESTAMP(), UTC_TIMESTAMP())`, e.To, e.Subject)
+		if err != nil {
+			return err
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = int(lastID)
+
+		body, err := sealField(m.Keys, e.Body, "email_queue.body", id)
+		if err != nil {
+			return err
+		}
+		html, err := sealField(m.Keys, e.HTML, "email_queue.html", id)
+		if err != nil {
+			return err
+		}
+		unsubscribe, err := sealField(m.Keys, e.Unsubscribe, "email_queue.unsubscribe", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE email_queue SET body = ?, html = ?, unsubscribe = ? WHERE id = ?`, body, html, unsubscribe, id)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Due claims up to limit emails whose time has come, oldest first, counting
+// the attempt about to be made. As with webhook deliveries, a claimed email
+// isn't due again for ten minutes, so one whose sender dies is retried, and
+// several instances of the application never send the same email at once.
+func (m *EmailQueueModel) Due(limit int) ([]*QueuedEmail, error) {
+	stmt := `SELECT id, to_address, subject, body, html, unsubscribe, attempts, next_attempt, error, created, updated
+    FROM email_queue WHERE next_attempt <= UTC_TIMESTAMP() ORDER BY next_attempt, id LIMIT ?`
+
+	due, err := m.scanAll(m.DB.Query(stmt, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []*QueuedEmail
+
+	for _, e := range due {
+		result, err := m.DB.Exec(`UPDATE email_queue SET attempts = attempts + 1, next_attempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL 10 MINUTE), updated = UTC_TIMESTAMP()
+    WHERE id = ? AND attempts = ?`, e.ID, e.Attempts)
+		if err != nil {
+			return claimed, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return claimed, err
+		}
+		if n == 1 {
+			e.Attempts++
+			claimed = append(claimed, e)
+		}
+	}
+
+	return claimed, nil
+}
+
+// Sent removes an email which has been sent from the queue.
+func (m *EmailQueueModel) Sent(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM email_queue WHERE id = ?`, id)
+	return err
+}
+
+// Failed records that an attempt to send an email failed. It's tried again
+// at retryAt or, if retryAt is zero, moved to the dead letters.
+func (m *EmailQueueModel) Failed(id int, message string, retryAt time.Time) error {
+	if len(message) > 255 {
+		message = message[:255]
+	}
+
+	if !retryAt.IsZero() {
+		_, err := m.DB.Exec(`UPDATE email_queue SET error = ?, next_attempt = ?, updated = UTC_TIMESTAMP() WHERE id = ?`,
+			message, retryAt.UTC(), id)
+		return err
+	}
+
+	return transact(m.DB, func(tx DBTX) error {
+		_, err := tx.Exec(`INSERT INTO email_dead_letters (id, to_address, subject, body, html, unsubscribe, attempts, error, created, updated)
+    SELECT id, to_address, subject, body, html, unsubscribe, attempts, ?, created, UTC_TIMESTAMP() FROM email_queue WHERE id = ?`, message, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM email_queue WHERE id = ?`, id)
+		return err
+	})
+}
+
+// DeadLetters returns the most recently given up on emails, newest first.
+func (m *EmailQueueModel) DeadLetters(limit int) ([]*QueuedEmail, error) {
+	stmt := `SELECT id, to_address, subject, body, html, unsubscribe, attempts, NULL, error, created, updated
+    FROM email_dead_letters ORDER BY updated DESC, id DESC LIMIT ?`
+
+	dead, err := m.scanAll(m.DB.Query(stmt, limit))
+	if err != nil {
+		return nil, err
+	}
+	if dead == nil {
+		dead = []*QueuedEmail{}
+	}
+	return dead, nil
+}
+
+// Retry puts a dead letter back in the queue, to be sent straight away with
+// a fresh set of attempts. It returns ErrNoRecord if there's no such dead
+// letter.
+func (m *EmailQueueModel) Retry(id int) error {
+	return transact(m.DB, func(tx DBTX) error {
+		result, err := tx.Exec(`INSERT INTO email_queue (id, to_address, subject, body, html, unsubscribe, attempts, next_attempt, error, created, updated)
+    SELECT id, to_address, subject, body, html, unsubscribe, 0, UTC_TIMESTAMP(), error, created, UTC_TIMESTAMP() FROM email_dead_letters WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoRecord
+		}
+		_, err = tx.Exec(`DELETE FROM email_dead_letters WHERE id = ?`, id)
+		return err
+	})
+}
+
+// DeleteDeadLetter deletes a dead letter for good, returning ErrNoRecord if
+// there's no such dead letter.
+func (m *EmailQueueModel) DeleteDeadLetter(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM email_dead_letters WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// scanAll reads the emails from the rows of a query, decrypting their
+// bodies.
+func (m *EmailQueueModel) scanAll(rows *sql.Rows, err error) ([]*QueuedEmail, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*QueuedEmail
+
+	for rows.Next() {
+		e := &QueuedEmail{}
+		var next sql.NullTime
+		err = rows.Scan(&e.ID, &e.To, &e.Subject, &e.Body, &e.HTML, &e.Unsubscribe, &e.Attempts, &next, &e.Error, &e.Created, &e.Updated)
+		if err != nil {
+			return nil, err
+		}
+		e.NextAttempt = next.Time
+
+		e.Body, err = openField(m.Keys, e.Body, "email_queue.body", e.ID)
+		if err != nil {
+			return nil, err
+		}
+		e.HTML, err = openField(m.Keys, e.HTML, "email_queue.html", e.ID)
+		if err != nil {
+			return nil, err
+		}
+		e.Unsubscribe, err = openField(m.Keys, e.Unsubscribe, "email_queue.unsubscribe", e.ID)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestEmailQueueModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := EmailQueueModel{DB: db}
+
+	okID, err := m.Enqueue(&QueuedEmail{To: "alice@example.com", Subject: "Hello", Body: "Hi Alice", HTML: "<p>Hi Alice</p>", Unsubscribe: "https://example.com/email/unsubscribe?sig=x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	badID, err := m.Enqueue(&QueuedEmail{To: "nobody@example.com", Subject: "Hello", Body: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 2)
+	assert.Equal(t, due[0].ID, okID)
+	assert.Equal(t, due[0].Body, "Hi Alice")
+	assert.Equal(t, due[0].HTML, "<p>Hi Alice</p>")
+	assert.Equal(t, due[0].Unsubscribe, "https://example.com/email/unsubscribe?sig=x")
+	assert.Equal(t, due[0].Attempts, 1)
+
+	// Claimed emails aren't due again until they're retried.
+	again, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(again), 0)
+
+	if err = m.Sent(okID); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Failed(badID, "550 no such user", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err = m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Attempts, 2)
+	assert.Equal(t, due[0].Error, "550 no such user")
+
+	// Giving up moves the email to the dead letters.
+	if err = m.Failed(badID, "550 no such user", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	dead, err := m.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(dead), 1)
+	assert.Equal(t, dead[0].ID, badID)
+	assert.Equal(t, dead[0].Attempts, 2)
+	assert.Equal(t, dead[0].Body, "Hi")
+
+	// Retrying puts it back in the queue with a fresh set of attempts.
+	if err = m.Retry(badID); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, m.Retry(badID), ErrNoRecord)
+
+	due, err = m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Attempts, 1)
+
+	if err = m.Failed(badID, "550 no such user", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.DeleteDeadLetter(badID); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, m.DeleteDeadLetter(badID), ErrNoRecord)
+
+	dead, err = m.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(dead), 0)
+}
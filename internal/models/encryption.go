@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/crypto"
+)
+
+// errNoKeys is returned when reading an encrypted column without a keyring.
+var errNoKeys = errors.New("models: value is encrypted but no encryption keys are configured")
+
+// sealField encrypts the value of a sensitive column for storing. The
+// ciphertext is tied to the column and row it is stored in, so it can't be
+// copied elsewhere. Without a keyring the value is stored as it is.
+func sealField(keys *crypto.Keyring, value, column string, rowID any) (string, error) {
+	if keys == nil {
+		return value, nil
+	}
+	return keys.Encrypt(value, fieldContext(column, rowID))
+}
+
+// openField decrypts a value stored by sealField. Values which were stored
+// before encryption was turned on are returned as they are.
+func openField(keys *crypto.Keyring, value, column string, rowID any) (string, error) {
+	if !crypto.IsEncrypted(value) {
+		return value, nil
+	}
+	if keys == nil {
+		return "", errNoKeys
+	}
+	return keys.Decrypt(value, fieldContext(column, rowID))
+}
+
+func fieldContext(column string, rowID any) []byte {
+	return []byte(fmt.Sprintf("%s:%v", column, rowID))
+}
@@ -1,6 +1,11 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"strings"
+)
 
 var (
 	ErrNoRecord = errors.New("models: no matching record found")
@@ -11,4 +16,97 @@ var (
 	// ErrDuplicateEmail Add a new ErrDuplicateEmail error. We'll use this later if a user
 	// tries to signup with an email address that's already in use.
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrInvalidInvitation is returned when an invitation code doesn't exist,
+	// has expired or has already been used up.
+	ErrInvalidInvitation = errors.New("models: invalid invitation")
+
+	// ErrInvalidToken is returned when a token sent to a user by email
+	// doesn't exist or has expired.
+	ErrInvalidToken = errors.New("models: invalid or expired token")
+
+	// ErrEditConflict is returned when a record was changed by someone else
+	// since the version being edited was read.
+	ErrEditConflict = errors.New("models: edit conflict")
+
+	// ErrDuplicatePasskey is returned when a passkey is registered which is
+	// already registered, to this account or another.
+	ErrDuplicatePasskey = errors.New("models: duplicate passkey")
+
+	// ErrDuplicateTemplateName is returned when a user already has a snippet
+	// template with the same name.
+	ErrDuplicateTemplateName = errors.New("models: duplicate template name")
+
+	// ErrDuplicateIdentity is returned when linking an external account
+	// which is already linked to a user.
+	ErrDuplicateIdentity = errors.New("models: duplicate identity")
+
+	// ErrDuplicateCollectionName is returned when a user already has a
+	// collection with the same name.
+	ErrDuplicateCollectionName = errors.New("models: duplicate collection name")
+
+	// ErrLastOwner is returned when the only owner of an organization would
+	// be removed from it or stop being an owner.
+	ErrLastOwner = errors.New("models: last owner of organization")
+
+	// ErrForeignKeyViolation is returned when a record refers to another
+	// which doesn't exist, such as a snippet for an organization which has
+	// been deleted, or when a record which others refer to is deleted.
+	ErrForeignKeyViolation = errors.New("models: foreign key violation")
+
+	// ErrTooLarge is returned when a value doesn't fit in its column, or a
+	// statement is larger than the database will accept.
+	ErrTooLarge = errors.New("models: value too large")
+
+	// ErrExpiryNotPermitted is returned when a snippet is created with an
+	// expiry which the instance's expiry policy doesn't allow.
+	ErrExpiryNotPermitted = errors.New("models: expiry not permitted by policy")
+)
+
+// The MySQL error numbers which translateMySQLError translates.
+const (
+	mysqlDuplicateEntry   = 1062
+	mysqlPacketTooLarge   = 1153
+	mysqlNoReferencedRow  = 1216
+	mysqlRowIsReferenced  = 1217
+	mysqlDataTooLong      = 1406
+	mysqlRowIsReferenced2 = 1451
+	mysqlNoReferencedRow2 = 1452
 )
+
+// uniqueKeys are the errors for duplicate entries in each of the unique
+// keys which callers care about, by the name of the key.
+var uniqueKeys = map[string]error{
+	"users_uc_email":                        ErrDuplicateEmail,
+	"webauthn_credentials_uc_credential_id": ErrDuplicatePasskey,
+	"snippet_templates_uc_user_name":        ErrDuplicateTemplateName,
+	"user_identities_uc_issuer_subject":     ErrDuplicateIdentity,
+	"collections_uc_user_name":              ErrDuplicateCollectionName,
+}
+
+// translateMySQLError translates the MySQL errors which callers might want
+// to act on into this package's errors, so that they can use errors.Is
+// rather than looking at MySQL error numbers. The error returned wraps err
+// as well, so that what MySQL said still makes it into the logs. Duplicate
+// entries are only translated for the keys in uniqueKeys, and any other
+// error is returned as it is.
+func translateMySQLError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if !errors.As(err, &mySQLError) {
+		return err
+	}
+
+	switch mySQLError.Number {
+	case mysqlDuplicateEntry:
+		for key, keyErr := range uniqueKeys {
+			if strings.Contains(mySQLError.Message, key) {
+				return fmt.Errorf("%w: %w", keyErr, err)
+			}
+		}
+	case mysqlNoReferencedRow, mysqlNoReferencedRow2, mysqlRowIsReferenced, mysqlRowIsReferenced2:
+		return fmt.Errorf("%w: %w", ErrForeignKeyViolation, err)
+	case mysqlDataTooLong, mysqlPacketTooLarge:
+		return fmt.Errorf("%w: %w", ErrTooLarge, err)
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestTranslateMySQLError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "Duplicate email",
+			err:  &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice@example.com' for key 'users.users_uc_email'"},
+			want: ErrDuplicateEmail,
+		},
+		{
+			name: "Missing organization",
+			err:  &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails"},
+			want: ErrForeignKeyViolation,
+		},
+		{
+			name: "Referenced user",
+			err:  &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails"},
+			want: ErrForeignKeyViolation,
+		},
+		{
+			name: "Long title",
+			err:  &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'title' at row 1"},
+			want: ErrTooLarge,
+		},
+		{
+			name: "Large packet",
+			err:  &mysql.MySQLError{Number: 1153, Message: "Got a packet bigger than 'max_allowed_packet' bytes"},
+			want: ErrTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateMySQLError(tt.err)
+			assert.Equal(t, errors.Is(err, tt.want), true)
+
+			// The MySQL error is still there, for logging and for the
+			// circuit breaker.
+			var mySQLError *mysql.MySQLError
+			assert.Equal(t, errors.As(err, &mySQLError), true)
+		})
+	}
+
+	// Errors which callers have nothing to do with are left alone.
+	other := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+	assert.Equal(t, translateMySQLError(other), error(other))
+	assert.Equal(t, translateMySQLError(ErrNoRecord), ErrNoRecord)
+	assert.Equal(t, translateMySQLError(nil), nil)
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NeverExpires is the number of days to give for a snippet which never
+// expires, where the expiry policy allows it.
+const NeverExpires = -1
+
+// neverExpiresAt is stored as the expiry of snippets which never expire. It's
+// the last day a DATETIME can hold, so the usual expires > now check keeps
+// working.
+var neverExpiresAt = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// standardExpiries are the expiries offered whenever the policy allows them,
+// alongside its own minimum, maximum and default.
+var standardExpiries = []int{1, 7, 365}
+
+// ExpiryPolicy is the instance's rule for how long snippets may last. The
+// create form only offers what it allows, and everything which creates a
+// snippet, down to SnippetModel.Insert, refuses what it doesn't.
+type ExpiryPolicy struct {
+	// MinDays and MaxDays are the shortest and longest a snippet may last.
+	MinDays int
+	MaxDays int
+	// AllowNever is whether snippets may be kept forever, with
+	// NeverExpires.
+	AllowNever bool
+	// DefaultDays is the expiry given when none is asked for, which may be
+	// NeverExpires.
+	DefaultDays int
+}
+
+// DefaultExpiryPolicy applies until admins set one of their own.
+var DefaultExpiryPolicy = ExpiryPolicy{MinDays: 1, MaxDays: 365, DefaultDays: 365}
+
+// MaxExpiryDays is the longest maximum a policy can set, short of allowing
+// snippets to never expire.
+const MaxExpiryDays = 36500
+
+// Permits reports whether a snippet may last the given number of days:
+// whether it's one of the Choices.
+func (p ExpiryPolicy) Permits(days int) bool {
+	return slices.Contains(p.Choices(), days)
+}
+
+// Choices returns the expiries to offer, longest first: NeverExpires if it's
+// allowed, then the policy's maximum, default and minimum, and whichever of
+// the usual day, week and year fall between them.
+func (p ExpiryPolicy) Choices() []int {
+	var choices []int
+	if p.AllowNever {
+		choices = append(choices, NeverExpires)
+	}
+
+	days := []int{p.MinDays, p.MaxDays}
+	if p.DefaultDays >= p.MinDays && p.DefaultDays <= p.MaxDays {
+		days = append(days, p.DefaultDays)
+	}
+	for _, d := range standardExpiries {
+		if d >= p.MinDays && d <= p.MaxDays {
+			days = append(days, d)
+		}
+	}
+	slices.Sort(days)
+	days = slices.Compact(days)
+	slices.Reverse(days)
+
+	return append(choices, days...)
+}
+
+// Validate checks the policy makes sense: that the range isn't empty, and
+// the default is one of the choices.
+func (p ExpiryPolicy) Validate() error {
+	switch {
+	case p.MinDays < 1:
+		return errors.New("the minimum must be at least 1 day")
+	case p.MaxDays < p.MinDays:
+		return errors.New("the maximum can't be less than the minimum")
+	case p.MaxDays > MaxExpiryDays:
+		return fmt.Errorf("the maximum can't be more than %d days", MaxExpiryDays)
+	case !p.Permits(p.DefaultDays):
+		return errors.New("the default must be one of the permitted expiries")
+	}
+	return nil
+}
+
+// String lists the choices, shortest first, like "1, 7 or 365", for
+// validation messages.
+func (p ExpiryPolicy) String() string {
+	all := p.Choices()
+
+	var choices []string
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i] == NeverExpires {
+			choices = append(choices, "-1 for never")
+		} else {
+			choices = append(choices, strconv.Itoa(all[i]))
+		}
+	}
+	if len(choices) == 1 {
+		return choices[0]
+	}
+	return strings.Join(choices[:len(choices)-1], ", ") + " or " + choices[len(choices)-1]
+}
+
+type ExpiryPolicyModelInterface interface {
+	Get() (ExpiryPolicy, error)
+	Set(p ExpiryPolicy) error
+}
+
+// ExpiryPolicyModel stores the instance's expiry policy.
+type ExpiryPolicyModel struct {
+	DB DBTX
+}
+
+// Get returns the expiry policy, which is DefaultExpiryPolicy until one has
+// been set.
+func (m *ExpiryPolicyModel) Get() (ExpiryPolicy, error) {
+	var p ExpiryPolicy
+
+	stmt := `SELECT min_days, max_days, allow_never, default_days FROM expiry_policy WHERE id = 1`
+
+	err := m.DB.QueryRow(stmt).Scan(&p.MinDays, &p.MaxDays, &p.AllowNever, &p.DefaultDays)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultExpiryPolicy, nil
+	}
+	return p, err
+}
+
+// Set replaces the expiry policy. Snippets which have already been created
+// keep the expiry they were given.
+func (m *ExpiryPolicyModel) Set(p ExpiryPolicy) error {
+	stmt := `INSERT INTO expiry_policy (id, min_days, max_days, allow_never, default_days, updated)
+    VALUES (1, ?, ?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE min_days = VALUES(min_days), max_days = VALUES(max_days), allow_never = VALUES(allow_never),
+    default_days = VALUES(default_days), updated = VALUES(updated)`
+
+	_, err := m.DB.Exec(stmt, p.MinDays, p.MaxDays, p.AllowNever, p.DefaultDays)
+	return err
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestExpiryPolicyChoices(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ExpiryPolicy
+		want   string
+		text   string
+	}{
+		{"Default", DefaultExpiryPolicy, "[365 7 1]", "1, 7 or 365"},
+		{"Short", ExpiryPolicy{MinDays: 1, MaxDays: 30, DefaultDays: 14}, "[30 14 7 1]", "1, 7, 14 or 30"},
+		{"Never", ExpiryPolicy{MinDays: 7, MaxDays: 90, AllowNever: true, DefaultDays: NeverExpires}, "[-1 90 7]", "7, 90 or -1 for never"},
+		{"Fixed", ExpiryPolicy{MinDays: 30, MaxDays: 30, DefaultDays: 30}, "[30]", "30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmt.Sprint(tt.policy.Choices()), tt.want)
+			assert.Equal(t, tt.policy.String(), tt.text)
+			assert.Equal(t, tt.policy.Validate(), nil)
+		})
+	}
+
+	p := ExpiryPolicy{MinDays: 1, MaxDays: 30, DefaultDays: 14}
+	assert.Equal(t, p.Permits(14), true)
+	assert.Equal(t, p.Permits(15), false)
+	assert.Equal(t, p.Permits(NeverExpires), false)
+	assert.Equal(t, p.Permits(0), false)
+}
+
+func TestExpiryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ExpiryPolicy
+		want   string
+	}{
+		{"No minimum", ExpiryPolicy{MinDays: 0, MaxDays: 7, DefaultDays: 7}, "the minimum must be at least 1 day"},
+		{"Empty range", ExpiryPolicy{MinDays: 7, MaxDays: 1, DefaultDays: 7}, "the maximum can't be less than the minimum"},
+		{"Too long", ExpiryPolicy{MinDays: 1, MaxDays: 100000, DefaultDays: 7}, "the maximum can't be more than 36500 days"},
+		{"Default out of range", ExpiryPolicy{MinDays: 1, MaxDays: 30, DefaultDays: 365}, "the default must be one of the permitted expiries"},
+		{"Default never", ExpiryPolicy{MinDays: 1, MaxDays: 30, DefaultDays: NeverExpires}, "the default must be one of the permitted expiries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			assert.Equal(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestExpiryPolicyModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := ExpiryPolicyModel{DB: db}
+
+	p, err := m.Get()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p, DefaultExpiryPolicy)
+
+	want := ExpiryPolicy{MinDays: 1, MaxDays: 30, AllowNever: true, DefaultDays: 7}
+	err = m.Set(want)
+	assert.Equal(t, err, nil)
+
+	p, err = m.Get()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p, want)
+
+	// Snippets are held to the policy whatever creates them.
+	snippets := SnippetModel{DB: db}
+
+	_, err = snippets.Insert(&Snippet{Title: "A year", Content: "Content"}, 365)
+	assert.Equal(t, errors.Is(err, ErrExpiryNotPermitted), true)
+
+	id, err := snippets.Insert(&Snippet{Title: "Forever", Content: "Content"}, NeverExpires)
+	assert.Equal(t, err, nil)
+
+	s, err := snippets.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s.NeverExpires(), true)
+}
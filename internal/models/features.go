@@ -0,0 +1,47 @@
+package models
+
+type FeatureModelInterface interface {
+	All() (map[string]bool, error)
+	Set(name string, enabled bool) error
+}
+
+// FeatureModel persists the feature flag values toggled from the admin UI.
+type FeatureModel struct {
+	DB DBTX
+}
+
+// All returns every persisted flag value, keyed by flag name.
+func (m *FeatureModel) All() (map[string]bool, error) {
+	rows, err := m.DB.Query("SELECT name, enabled FROM feature_flags")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := map[string]bool{}
+
+	for rows.Next() {
+		var name string
+		var enabled bool
+
+		err = rows.Scan(&name, &enabled)
+		if err != nil {
+			return nil, err
+		}
+		flags[name] = enabled
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+// Set inserts or updates the value of a single flag.
+func (m *FeatureModel) Set(name string, enabled bool) error {
+	stmt := `INSERT INTO feature_flags (name, enabled, updated) VALUES (?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE enabled = VALUES(enabled), updated = VALUES(updated)`
+
+	_, err := m.DB.Exec(stmt, name, enabled)
+	return err
+}
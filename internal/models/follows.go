@@ -0,0 +1,73 @@
+package models
+
+type FollowModelInterface interface {
+	Follow(followerID, followeeID int) error
+	Unfollow(followerID, followeeID int) error
+	IsFollowing(followerID, followeeID int) (bool, error)
+	Following(followerID int) ([]int, error)
+	Followers(followeeID int) ([]int, error)
+}
+
+type FollowModel struct {
+	DB DBTX
+}
+
+// Follow makes followerID follow followeeID. Following someone who is already
+// followed does nothing.
+func (m *FollowModel) Follow(followerID, followeeID int) error {
+	stmt := `INSERT IGNORE INTO follows (follower_id, followee_id, created)
+    VALUES(?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, followerID, followeeID)
+	return err
+}
+
+// Unfollow stops followerID following followeeID, if they were.
+func (m *FollowModel) Unfollow(followerID, followeeID int) error {
+	stmt := `DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`
+
+	_, err := m.DB.Exec(stmt, followerID, followeeID)
+	return err
+}
+
+func (m *FollowModel) IsFollowing(followerID, followeeID int) (bool, error) {
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM follows WHERE follower_id = ? AND followee_id = ?)`
+
+	err := m.DB.QueryRow(stmt, followerID, followeeID).Scan(&exists)
+	return exists, err
+}
+
+// Following returns the IDs of the users followed by followerID.
+func (m *FollowModel) Following(followerID int) ([]int, error) {
+	return m.ids(`SELECT followee_id FROM follows WHERE follower_id = ? ORDER BY followee_id`, followerID)
+}
+
+// Followers returns the IDs of the users who follow followeeID.
+func (m *FollowModel) Followers(followeeID int) ([]int, error) {
+	return m.ids(`SELECT follower_id FROM follows WHERE followee_id = ? ORDER BY follower_id`, followeeID)
+}
+
+func (m *FollowModel) ids(stmt string, arg int) ([]int, error) {
+	rows, err := m.DB.Query(stmt, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestFollowModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+
+	users := UserModel{DB: db}
+	err := users.Insert("Bob", "bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.Authenticate("bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := FollowModel{DB: db}
+
+	// Following twice is harmless.
+	for i := 0; i < 2; i++ {
+		if err := m.Follow(1, bob); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	following, err := m.IsFollowing(1, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, following, true)
+
+	followers, err := m.Followers(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(followers), 1)
+	assert.Equal(t, followers[0], 1)
+
+	if err := m.Unfollow(1, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := m.Following(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(ids), 0)
+}
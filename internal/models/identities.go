@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type IdentityModelInterface interface {
+	UserID(issuer, subject string) (int, error)
+	Link(userID int, issuer, subject string) error
+}
+
+// IdentityModel links users to their accounts at external identity
+// providers. An account is identified by the provider's issuer URL and the
+// subject the provider gave it.
+type IdentityModel struct {
+	DB DBTX
+}
+
+// UserID returns the ID of the user linked to an account, or ErrNoRecord if
+// it isn't linked to anyone.
+func (m *IdentityModel) UserID(issuer, subject string) (int, error) {
+	var id int
+
+	stmt := `SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?`
+
+	err := m.DB.QueryRow(stmt, issuer, subject).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Link links an account to a user. It returns ErrDuplicateIdentity if the
+// account is already linked, to this user or another.
+func (m *IdentityModel) Link(userID int, issuer, subject string) error {
+	stmt := `INSERT INTO user_identities (user_id, issuer, subject, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, issuer, subject)
+	return translateMySQLError(err)
+}
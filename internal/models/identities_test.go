@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestIdentityModel(t *testing.T) {
+	m := IdentityModel{DB: testutils.NewTestDB(t)}
+
+	_, err := m.UserID("https://idp.example.com", "alice")
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Link(1, "https://idp.example.com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.UserID("https://idp.example.com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, 1)
+
+	// Subjects are only unique for each issuer.
+	_, err = m.UserID("https://other.example.com", "alice")
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Link(1, "https://idp.example.com", "alice")
+	assert.Equal(t, errors.Is(err, ErrDuplicateIdentity), true)
+}
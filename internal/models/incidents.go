@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Incident is a panic which happened while handling a request. Reference is
+// the short code shown to the user, so that they can quote it when reporting
+// the problem.
+type Incident struct {
+	ID        int       `json:"-"`
+	Reference string    `json:"reference"`
+	Message   string    `json:"message"`
+	Stack     string    `json:"stack"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	UserID    int       `json:"user_id,omitempty"`
+	RequestID string    `json:"request_id"`
+	Created   time.Time `json:"created"`
+}
+
+type IncidentModelInterface interface {
+	Insert(incident *Incident) error
+	Latest() ([]*Incident, error)
+}
+
+type IncidentModel struct {
+	DB DBTX
+}
+
+func (m *IncidentModel) Insert(i *Incident) error {
+	stmt := `INSERT INTO incidents (reference, message, stack, method, path, user_id, request_id, created)
+    VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var userID any
+	if i.UserID != 0 {
+		userID = i.UserID
+	}
+
+	_, err := m.DB.Exec(stmt, i.Reference, i.Message, i.Stack, i.Method, i.Path, userID, i.RequestID, i.Created.UTC())
+	return err
+}
+
+// Latest returns the 50 most recent incidents.
+func (m *IncidentModel) Latest() ([]*Incident, error) {
+	stmt := `SELECT id, reference, message, stack, method, path, user_id, request_id, created FROM incidents
+    ORDER BY id DESC LIMIT 50`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []*Incident{}
+
+	for rows.Next() {
+		i := &Incident{}
+		var userID sql.NullInt64
+		err = rows.Scan(&i.ID, &i.Reference, &i.Message, &i.Stack, &i.Method, &i.Path, &userID, &i.RequestID, &i.Created)
+		if err != nil {
+			return nil, err
+		}
+		i.UserID = int(userID.Int64)
+		incidents = append(incidents, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return incidents, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+)
+
+// OrphanCheck is a kind of row which can be left behind when the row it
+// belongs to is gone. Notifications have no foreign key, so they outlive
+// users deleted by hand. The rest are kept tidy by foreign keys, but can
+// still be orphaned by a restore done with FOREIGN_KEY_CHECKS=0, as
+// mysqldump's output is, which leaves out some of the parents.
+type OrphanCheck struct {
+	Name        string
+	Description string
+
+	table  string
+	column string
+	parent string
+}
+
+// OrphanChecks are the checks IntegrityModel knows how to run, in the
+// order they're run and shown.
+var OrphanChecks = []OrphanCheck{
+	{Name: "notifications", Description: "Notifications for users who no longer exist", table: "notifications", column: "user_id", parent: "users"},
+	{Name: "snippet_files", Description: "Extra files of snippets which no longer exist", table: "snippet_files", column: "snippet_id", parent: "snippets"},
+	{Name: "snippet_views", Description: "Views of snippets which no longer exist", table: "snippet_views", column: "snippet_id", parent: "snippets"},
+	{Name: "snippet_stats", Description: "Daily statistics of snippets which no longer exist", table: "snippet_stats", column: "snippet_id", parent: "snippets"},
+	{Name: "collection_snippets", Description: "Collection entries for snippets which no longer exist", table: "collection_snippets", column: "snippet_id", parent: "snippets"},
+	{Name: "org_members", Description: "Members of organisations which no longer exist", table: "org_members", column: "org_id", parent: "orgs"},
+	{Name: "webhook_deliveries", Description: "Deliveries for webhooks which no longer exist", table: "webhook_deliveries", column: "webhook_id", parent: "webhooks"},
+}
+
+// orphanCheck returns the check with the given name.
+func orphanCheck(name string) (OrphanCheck, error) {
+	for _, c := range OrphanChecks {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return OrphanCheck{}, fmt.Errorf("models: no orphan check called %q", name)
+}
+
+// from is the FROM clause which picks out the check's orphaned rows, as o.
+func (c OrphanCheck) from() string {
+	return fmt.Sprintf("%s o LEFT JOIN %s p ON p.id = o.%s WHERE p.id IS NULL", c.table, c.parent, c.column)
+}
+
+type IntegrityModelInterface interface {
+	CountOrphans(check string) (int, error)
+	DeleteOrphans(check string) (int, error)
+}
+
+// IntegrityModel looks for rows whose parent is gone, and deletes them.
+type IntegrityModel struct {
+	DB DBTX
+}
+
+// CountOrphans counts the rows the named check finds.
+func (m *IntegrityModel) CountOrphans(check string) (int, error) {
+	c, err := orphanCheck(check)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = m.DB.QueryRow("SELECT COUNT(*) FROM " + c.from()).Scan(&n)
+	return n, err
+}
+
+// DeleteOrphans deletes the rows the named check finds, and returns how
+// many were deleted.
+func (m *IntegrityModel) DeleteOrphans(check string) (int, error) {
+	c, err := orphanCheck(check)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := m.DB.Exec("DELETE o FROM " + c.from())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
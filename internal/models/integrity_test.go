@@ -0,0 +1,54 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestIntegrityModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := IntegrityModel{DB: db}
+	notifications := NotificationModel{DB: db}
+
+	// Notifications have no foreign key, so they can belong to users who
+	// don't exist.
+	for _, userID := range []int{1, 99} {
+		if err := notifications.Insert(userID, NotificationAnnouncement, "Hello", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range OrphanChecks {
+		n, err := m.CountOrphans(c.Name)
+		if err != nil {
+			t.Fatalf("%s: %s", c.Name, err)
+		}
+		if c.Name == "notifications" {
+			assert.Equal(t, n, 1)
+		} else {
+			assert.Equal(t, n, 0)
+		}
+	}
+
+	n, err := m.DeleteOrphans("notifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	n, err = m.CountOrphans("notifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+
+	remaining, err := notifications.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(remaining), 1)
+
+	_, err = m.CountOrphans("tags")
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"time"
+)
+
+type Invitation struct {
+	ID        int
+	Code      string
+	MaxUses   int
+	Uses      int
+	CreatedBy int
+	Created   time.Time
+	Expires   time.Time
+}
+
+// Remaining returns how many more times the invitation can be used.
+func (i *Invitation) Remaining() int {
+	if i.Uses >= i.MaxUses {
+		return 0
+	}
+	return i.MaxUses - i.Uses
+}
+
+type InvitationModelInterface interface {
+	Insert(createdBy, maxUses, expires int) (*Invitation, error)
+	Latest() ([]*Invitation, error)
+	Consume(code string) error
+	Release(code string) error
+}
+
+type InvitationModel struct {
+	DB DBTX
+}
+
+// Insert creates a new invitation with a random code which can be used
+// maxUses times and expires after the given number of days.
+func (m *InvitationModel) Insert(createdBy, maxUses, expires int) (*Invitation, error) {
+	code, err := newInvitationCode()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO invitations (code, max_uses, created_by, created, expires)
+    VALUES(?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+
+	result, err := m.DB.Exec(stmt, code, maxUses, createdBy, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Invitation{}
+	stmt = `SELECT id, code, max_uses, uses, created_by, created, expires FROM invitations WHERE id = ?`
+	err = m.DB.QueryRow(stmt, id).Scan(&i.ID, &i.Code, &i.MaxUses, &i.Uses, &i.CreatedBy, &i.Created, &i.Expires)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// Latest returns the 50 most recently created invitations, including used
+// and expired ones.
+func (m *InvitationModel) Latest() ([]*Invitation, error) {
+	stmt := `SELECT id, code, max_uses, uses, created_by, created, expires FROM invitations
+    ORDER BY id DESC LIMIT 50`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+
+	for rows.Next() {
+		i := &Invitation{}
+		err = rows.Scan(&i.ID, &i.Code, &i.MaxUses, &i.Uses, &i.CreatedBy, &i.Created, &i.Expires)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// Consume uses up one use of the invitation with the given code. The check
+// and the increment happen in a single UPDATE statement, so two signups
+// racing for the last use of a code can't both succeed. It returns
+// ErrInvalidInvitation if the code doesn't exist, has expired or has no uses
+// left.
+func (m *InvitationModel) Consume(code string) error {
+	stmt := `UPDATE invitations SET uses = uses + 1
+    WHERE code = ? AND uses < max_uses AND expires > UTC_TIMESTAMP()`
+
+	result, err := m.DB.Exec(stmt, code)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidInvitation
+	}
+
+	return nil
+}
+
+// Release gives back a use consumed by Consume, for when the signup that
+// consumed it failed afterwards.
+func (m *InvitationModel) Release(code string) error {
+	stmt := `UPDATE invitations SET uses = uses - 1 WHERE code = ? AND uses > 0`
+
+	_, err := m.DB.Exec(stmt, code)
+	return err
+}
+
+// newInvitationCode returns a random 16 character code which is easy to read
+// out or type.
+func newInvitationCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.EncodeToString(b), nil
+}
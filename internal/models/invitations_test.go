@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestInvitationModelConsume(t *testing.T) {
+	m := InvitationModel{DB: testutils.NewTestDB(t)}
+
+	invitation, err := m.Insert(1, 2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, invitation.Remaining(), 2)
+
+	// The invitation can be used exactly twice.
+	assert.Equal(t, m.Consume(invitation.Code), nil)
+	assert.Equal(t, m.Consume(invitation.Code), nil)
+	assert.Equal(t, m.Consume(invitation.Code), ErrInvalidInvitation)
+
+	// Releasing a use makes it available again.
+	assert.Equal(t, m.Release(invitation.Code), nil)
+	assert.Equal(t, m.Consume(invitation.Code), nil)
+
+	assert.Equal(t, m.Consume("NOSUCHINVITATION"), ErrInvalidInvitation)
+}
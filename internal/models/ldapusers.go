@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/ldapauth"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+)
+
+// Directory is implemented by *ldapauth.Directory.
+type Directory interface {
+	Authenticate(login, password string) (*ldapauth.Entry, error)
+}
+
+// LDAPUserModel is a UserModel which checks passwords against a directory
+// instead of the users table. Everything apart from Authenticate is
+// unchanged.
+//
+// Users are matched to directory entries by email address, and are created
+// the first time they sign in. Their local password is random, so they can
+// only sign in through the directory. Admins are the exception: the
+// directory is tried first, but an admin's local password also works, so
+// that they can still get in while the directory is down or misconfigured.
+type LDAPUserModel struct {
+	*UserModel
+	Directory Directory
+
+	// AdminGroups are the DNs of the directory groups whose members are
+	// admins. Each user's role is updated from their groups when they sign
+	// in, unless AdminGroups is empty, in which case roles are managed
+	// locally.
+	AdminGroups []string
+}
+
+func (m *LDAPUserModel) Authenticate(email, password string) (int, error) {
+	entry, dirErr := m.Directory.Authenticate(email, password)
+	if dirErr == nil {
+		return m.syncEntry(email, entry)
+	}
+
+	id, err := m.UserModel.Authenticate(email, password)
+	if err == nil {
+		user, err := m.Get(id)
+		if err != nil {
+			return 0, err
+		}
+		if user.IsAdmin {
+			return id, nil
+		}
+	} else if !errors.Is(err, ErrInvalidCredentials) {
+		return 0, err
+	}
+
+	if errors.Is(dirErr, ldapauth.ErrInvalidCredentials) {
+		return 0, ErrInvalidCredentials
+	}
+	return 0, dirErr
+}
+
+// syncEntry returns the ID of the user for a directory entry, creating the
+// user if need be and updating their role from the directory's groups.
+func (m *LDAPUserModel) syncEntry(login string, entry *ldapauth.Entry) (int, error) {
+	email := entry.Email
+	if email == "" {
+		email = login
+	}
+
+	user, err := m.GetByEmail(email)
+	if errors.Is(err, ErrNoRecord) {
+		name := entry.Name
+		if name == "" {
+			name = email
+		}
+
+		password, _, err := newToken()
+		if err != nil {
+			return 0, err
+		}
+		if err = m.Insert(name, email, password); err != nil {
+			return 0, err
+		}
+		user, err = m.GetByEmail(email)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(m.AdminGroups) > 0 {
+		isAdmin := entry.MemberOfAny(m.AdminGroups)
+		if isAdmin != user.IsAdmin {
+			if err = m.setAdmin(user.ID, isAdmin); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return user.ID, nil
+}
+
+// setAdmin grants or revokes the admin role.
+func (m *UserModel) setAdmin(id int, isAdmin bool) error {
+	stmt, args := query.Update("users").Set("is_admin", isAdmin).Where("id = ?", id).Build()
+
+	_, err := m.DB.Exec(stmt, args...)
+	return err
+}
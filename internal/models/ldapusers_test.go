@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/ldapauth"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+// fakeDirectory has every entry's password as "secret". If err is set, the
+// directory is down.
+type fakeDirectory struct {
+	entries map[string]*ldapauth.Entry
+	err     error
+}
+
+func (d *fakeDirectory) Authenticate(login, password string) (*ldapauth.Entry, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	entry, ok := d.entries[login]
+	if !ok || password != "secret" {
+		return nil, ldapauth.ErrInvalidCredentials
+	}
+	return entry, nil
+}
+
+func TestLDAPUserModelAuthenticate(t *testing.T) {
+	dir := &fakeDirectory{entries: map[string]*ldapauth.Entry{
+		"alice@example.com": {DN: "uid=alice", Email: "alice@example.com", Name: "Alice"},
+		"bob@example.com":   {DN: "uid=bob", Email: "bob@example.com", Name: "Bob", Groups: []string{"cn=admins"}},
+	}}
+	m := LDAPUserModel{
+		UserModel:   &UserModel{DB: testutils.NewTestDB(t)},
+		Directory:   dir,
+		AdminGroups: []string{"CN=Admins"},
+	}
+
+	// Existing users are matched by email.
+	id, err := m.Authenticate("alice@example.com", "secret")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, 1)
+
+	// Alice isn't an admin, so her local password doesn't work.
+	_, err = m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	// Bob is created when he first signs in, as an admin.
+	id, err = m.Authenticate("bob@example.com", "secret")
+	assert.Equal(t, err, nil)
+	bob, err := m.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bob.Name, "Bob")
+	assert.Equal(t, bob.IsAdmin, true)
+
+	// Leaving the group takes the role away.
+	dir.entries["bob@example.com"].Groups = nil
+	_, err = m.Authenticate("bob@example.com", "secret")
+	assert.Equal(t, err, nil)
+	bob, err = m.Get(id)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bob.IsAdmin, false)
+
+	// While the directory is down, only admins can sign in with their
+	// local passwords.
+	dir.err = errors.New("connection refused")
+	_, err = m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, dir.err)
+
+	err = m.setAdmin(1, true)
+	assert.Equal(t, err, nil)
+	id, err = m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, 1)
+}
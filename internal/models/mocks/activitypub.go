@@ -0,0 +1,150 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActivityPubModel keeps the actor's key, its followers and the delivery
+// queue in memory. As with the email queue mock, claimed deliveries are due
+// again immediately if they're left in the queue.
+type ActivityPubModel struct {
+	mu         sync.Mutex
+	public     string
+	private    string
+	followers  map[string]string
+	nextID     int
+	deliveries []*models.ActivityPubDelivery
+}
+
+func (m *ActivityPubModel) Key() (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.public == "" {
+		return "", "", models.ErrNoRecord
+	}
+	return m.public, m.private, nil
+}
+
+func (m *ActivityPubModel) InsertKey(public, private string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.public == "" {
+		m.public, m.private = public, private
+	}
+	return nil
+}
+
+func (m *ActivityPubModel) AddFollower(actor, inbox string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.followers == nil {
+		m.followers = map[string]string{}
+	}
+	m.followers[actor] = inbox
+	return nil
+}
+
+func (m *ActivityPubModel) RemoveFollower(actor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.followers, actor)
+	return nil
+}
+
+func (m *ActivityPubModel) FollowerCount() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.followers), nil
+}
+
+func (m *ActivityPubModel) FollowerInboxes() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := map[string]bool{}
+	var inboxes []string
+	for _, inbox := range m.followers {
+		if !seen[inbox] {
+			seen[inbox] = true
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	sort.Strings(inboxes)
+	return inboxes, nil
+}
+
+func (m *ActivityPubModel) Enqueue(inboxes []string, activity string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, inbox := range inboxes {
+		m.nextID++
+		m.deliveries = append(m.deliveries, &models.ActivityPubDelivery{
+			ID:          m.nextID,
+			Inbox:       inbox,
+			Activity:    activity,
+			NextAttempt: time.Now(),
+			Created:     time.Now(),
+		})
+	}
+	return nil
+}
+
+func (m *ActivityPubModel) Due(limit int) ([]*models.ActivityPubDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*models.ActivityPubDelivery
+	for _, d := range m.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if !d.NextAttempt.After(time.Now()) {
+			d.Attempts++
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (m *ActivityPubModel) Delivered(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(id)
+	return nil
+}
+
+func (m *ActivityPubModel) Failed(id int, message string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if retryAt.IsZero() {
+		m.remove(id)
+		return nil
+	}
+	for _, d := range m.deliveries {
+		if d.ID == id {
+			d.Error, d.NextAttempt = message, retryAt
+		}
+	}
+	return nil
+}
+
+func (m *ActivityPubModel) remove(id int) {
+	for i, d := range m.deliveries {
+		if d.ID == id {
+			m.deliveries = append(m.deliveries[:i], m.deliveries[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"time"
+)
+
+// APIRateLimitModel keeps rate limits in memory. Nobody has their own limit
+// to begin with.
+type APIRateLimitModel struct {
+	limits map[int]*models.APIRateLimit
+}
+
+func (m *APIRateLimitModel) Get(userID int) (*models.APIRateLimit, error) {
+	l, ok := m.limits[userID]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return l, nil
+}
+
+func (m *APIRateLimitModel) Set(userID, requestsPerHour, burst int) error {
+	if m.limits == nil {
+		m.limits = map[int]*models.APIRateLimit{}
+	}
+	m.limits[userID] = &models.APIRateLimit{
+		UserID:          userID,
+		RequestsPerHour: requestsPerHour,
+		Burst:           burst,
+		Updated:         time.Now(),
+	}
+	return nil
+}
+
+func (m *APIRateLimitModel) Delete(userID int) error {
+	delete(m.limits, userID)
+	return nil
+}
+
+func (m *APIRateLimitModel) All() ([]*models.APIRateLimit, error) {
+	limits := []*models.APIRateLimit{}
+	for _, l := range m.limits {
+		limits = append(limits, l)
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].UserID < limits[j].UserID })
+	return limits, nil
+}
@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+	"time"
+)
+
+// APITokenModel keeps API tokens in memory. Nobody has any to begin with,
+// and the token handed out for the Nth one created is "APITOKENN".
+type APITokenModel struct {
+	mu     sync.Mutex
+	tokens []*models.APIToken
+	values map[string]int
+	lastID int
+}
+
+func (m *APITokenModel) Insert(userID int, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.values == nil {
+		m.values = make(map[string]int)
+	}
+
+	m.lastID++
+	m.tokens = append(m.tokens, &models.APIToken{ID: m.lastID, UserID: userID, Name: name, Created: time.Now()})
+
+	token := fmt.Sprintf("APITOKEN%d", m.lastID)
+	m.values[token] = m.lastID
+	return token, nil
+}
+
+func (m *APITokenModel) Authenticate(token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.values[token]
+	if !ok {
+		return 0, models.ErrInvalidToken
+	}
+	for _, t := range m.tokens {
+		if t.ID == id {
+			now := time.Now()
+			t.LastUsed = &now
+			return t.UserID, nil
+		}
+	}
+	return 0, models.ErrInvalidToken
+}
+
+func (m *APITokenModel) ForUser(userID int) ([]*models.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []*models.APIToken{}
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *APITokenModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, t := range m.tokens {
+		if t.ID == id && t.UserID == userID {
+			m.tokens = append(m.tokens[:i], m.tokens[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -0,0 +1,209 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CollectionModel keeps collections in memory. There are none to begin
+// with. Their snippets are looked up among the mock snippets.
+type CollectionModel struct {
+	mu          sync.Mutex
+	collections []*models.Collection
+	snippets    map[int][]int
+}
+
+func (m *CollectionModel) Insert(c *models.Collection) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.collections {
+		if other != nil && other.UserID == c.UserID && other.Name == c.Name {
+			return 0, models.ErrDuplicateCollectionName
+		}
+	}
+
+	n := *c
+	n.ID = len(m.collections) + 1
+	n.Slug = strings.ToLower(strings.ReplaceAll(c.Name, " ", "-"))
+	n.Created, n.Updated = time.Now(), time.Now()
+	m.collections = append(m.collections, &n)
+	return n.ID, nil
+}
+
+func (m *CollectionModel) Get(id, userID int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.collections {
+		if c != nil && c.ID == id && c.UserID == userID {
+			n := *c
+			return &n, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *CollectionModel) GetBySlug(slug string) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.collections {
+		if c != nil && c.Slug == slug {
+			n := *c
+			return &n, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *CollectionModel) ForUser(userID int) ([]*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collections := []*models.Collection{}
+	for _, c := range m.collections {
+		if c != nil && c.UserID == userID {
+			n := *c
+			n.SnippetCount = len(m.snippets[c.ID])
+			collections = append(collections, &n)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+func (m *CollectionModel) ForSnippet(snippetID int) ([]*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collections := []*models.Collection{}
+	for _, c := range m.collections {
+		if c != nil && position(m.snippets[c.ID], snippetID) >= 0 {
+			n := *c
+			collections = append(collections, &n)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+func (m *CollectionModel) Update(c *models.Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.collections {
+		if other != nil && other.ID != c.ID && other.UserID == c.UserID && other.Name == c.Name {
+			return models.ErrDuplicateCollectionName
+		}
+	}
+	for _, existing := range m.collections {
+		if existing != nil && existing.ID == c.ID && existing.UserID == c.UserID {
+			existing.Name, existing.Description, existing.Updated = c.Name, c.Description, time.Now()
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CollectionModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.collections {
+		if c != nil && c.ID == id && c.UserID == userID {
+			m.collections[i] = nil
+			delete(m.snippets, id)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.snippets == nil {
+		m.snippets = map[int][]int{}
+	}
+	if position(m.snippets[collectionID], snippetID) < 0 {
+		m.snippets[collectionID] = append(m.snippets[collectionID], snippetID)
+	}
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.snippets[collectionID]
+	i := position(ids, snippetID)
+	if i < 0 {
+		return models.ErrNoRecord
+	}
+	m.snippets[collectionID] = append(ids[:i:i], ids[i+1:]...)
+	return nil
+}
+
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.snippets[collectionID]
+	i := position(ids, snippetID)
+	if i < 0 {
+		return models.ErrNoRecord
+	}
+	j := i + 1
+	if up {
+		j = i - 1
+	}
+	if j >= 0 && j < len(ids) {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return nil
+}
+
+// Snippets returns the mock snippets in the collection. Unlike the real
+// model, it doesn't check whether they've expired.
+func (m *CollectionModel) Snippets(collectionID int, all bool) ([]*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	for _, id := range m.snippets[collectionID] {
+		for _, s := range []*models.Snippet{mockSnippet, mockScheduledSnippet, mockBurnSnippet, mockEncryptedSnippet} {
+			if s.ID != id {
+				continue
+			}
+			if all || (!s.IsScheduled() && !s.BurnAfterReading && !s.ContentEncrypted) {
+				snippets = append(snippets, s)
+			}
+		}
+	}
+	return snippets, nil
+}
+
+// SnippetsPage returns a page of what Snippets returns.
+func (m *CollectionModel) SnippetsPage(collectionID int, all bool, limit, offset int) ([]*models.Snippet, error) {
+	snippets, err := m.Snippets(collectionID, all)
+	if err != nil {
+		return nil, err
+	}
+	snippets = snippets[min(offset, len(snippets)):]
+	return snippets[:min(limit, len(snippets))], nil
+}
+
+// position returns the index of id in ids, or -1 if it isn't there.
+func position(ids []int, id int) int {
+	for i, other := range ids {
+		if other == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,30 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+)
+
+// ConsumedTokenModel remembers the links which have been used in memory.
+type ConsumedTokenModel struct {
+	mu       sync.Mutex
+	consumed map[string]bool
+}
+
+func (m *ConsumedTokenModel) Consume(id string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.consumed[id] {
+		return false, nil
+	}
+	if m.consumed == nil {
+		m.consumed = make(map[string]bool)
+	}
+	m.consumed[id] = true
+	return true, nil
+}
+
+func (m *ConsumedTokenModel) DeleteExpired() (int, error) {
+	return 0, nil
+}
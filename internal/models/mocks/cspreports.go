@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+)
+
+// CSPReportModel keeps inserted reports in memory so tests can inspect them.
+type CSPReportModel struct {
+	Reports []*models.CSPReport
+}
+
+func (m *CSPReportModel) Insert(report *models.CSPReport) error {
+	m.Reports = append(m.Reports, report)
+	return nil
+}
+
+func (m *CSPReportModel) Latest() ([]*models.CSPReport, error) {
+	return m.Reports, nil
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+	"time"
+)
+
+// DraftModel keeps drafts in memory. Nobody has one to begin with.
+type DraftModel struct {
+	mu     sync.Mutex
+	drafts map[int]*models.Draft
+}
+
+func (m *DraftModel) Get(userID int) (*models.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.drafts[userID]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return d, nil
+}
+
+func (m *DraftModel) Save(userID int, data string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.drafts == nil {
+		m.drafts = map[int]*models.Draft{}
+	}
+	m.drafts[userID] = &models.Draft{UserID: userID, Data: data, Updated: time.Now()}
+	return nil
+}
+
+func (m *DraftModel) Delete(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.drafts, userID)
+	return nil
+}
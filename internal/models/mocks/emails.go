@@ -0,0 +1,127 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+	"time"
+)
+
+// EmailQueueModel keeps the email queue and dead letters in memory. Unlike
+// the real model, claimed emails are due again immediately if they're left
+// in the queue.
+type EmailQueueModel struct {
+	mu     sync.Mutex
+	nextID int
+	queue  []*models.QueuedEmail
+	dead   []*models.QueuedEmail
+}
+
+func (m *EmailQueueModel) Enqueue(e *models.QueuedEmail) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	c := *e
+	c.ID, c.Attempts, c.NextAttempt, c.Error = m.nextID, 0, time.Now(), ""
+	c.Created, c.Updated = time.Now(), time.Now()
+	m.queue = append(m.queue, &c)
+	return c.ID, nil
+}
+
+func (m *EmailQueueModel) Due(limit int) ([]*models.QueuedEmail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*models.QueuedEmail
+	for _, e := range m.queue {
+		if len(due) == limit {
+			break
+		}
+		if !e.NextAttempt.After(time.Now()) {
+			e.Attempts++
+			c := *e
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (m *EmailQueueModel) Sent(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queue, _ = removeEmail(m.queue, id)
+	return nil
+}
+
+func (m *EmailQueueModel) Failed(id int, message string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.queue {
+		if e.ID == id {
+			e.Error, e.Updated = message, time.Now()
+			if !retryAt.IsZero() {
+				e.NextAttempt = retryAt
+				return nil
+			}
+		}
+	}
+
+	var e *models.QueuedEmail
+	m.queue, e = removeEmail(m.queue, id)
+	if e != nil {
+		e.NextAttempt = time.Time{}
+		m.dead = append(m.dead, e)
+	}
+	return nil
+}
+
+func (m *EmailQueueModel) DeadLetters(limit int) ([]*models.QueuedEmail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dead := []*models.QueuedEmail{}
+	for i := len(m.dead) - 1; i >= 0 && len(dead) < limit; i-- {
+		c := *m.dead[i]
+		dead = append(dead, &c)
+	}
+	return dead, nil
+}
+
+func (m *EmailQueueModel) Retry(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var e *models.QueuedEmail
+	m.dead, e = removeEmail(m.dead, id)
+	if e == nil {
+		return models.ErrNoRecord
+	}
+	e.Attempts, e.NextAttempt, e.Updated = 0, time.Now(), time.Now()
+	m.queue = append(m.queue, e)
+	return nil
+}
+
+func (m *EmailQueueModel) DeleteDeadLetter(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var e *models.QueuedEmail
+	m.dead, e = removeEmail(m.dead, id)
+	if e == nil {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// removeEmail takes the email with the given ID out of emails, returning it or
+// nil if it wasn't there.
+func removeEmail(emails []*models.QueuedEmail, id int) ([]*models.QueuedEmail, *models.QueuedEmail) {
+	for i, e := range emails {
+		if e.ID == id {
+			return append(emails[:i:i], emails[i+1:]...), e
+		}
+	}
+	return emails, nil
+}
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+)
+
+// ExpiryPolicyModel keeps the expiry policy in memory. The zero value has
+// the default policy.
+type ExpiryPolicyModel struct {
+	mu     sync.Mutex
+	policy *models.ExpiryPolicy
+}
+
+func (m *ExpiryPolicyModel) Get() (models.ExpiryPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.policy == nil {
+		return models.DefaultExpiryPolicy, nil
+	}
+	return *m.policy, nil
+}
+
+func (m *ExpiryPolicyModel) Set(p models.ExpiryPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.policy = &p
+	return nil
+}
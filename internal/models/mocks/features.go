@@ -0,0 +1,23 @@
+package mocks
+
+// FeatureModel is an in-memory feature flag store. The zero value is ready to
+// use and has no persisted flags.
+type FeatureModel struct {
+	flags map[string]bool
+}
+
+func (m *FeatureModel) All() (map[string]bool, error) {
+	flags := map[string]bool{}
+	for name, enabled := range m.flags {
+		flags[name] = enabled
+	}
+	return flags, nil
+}
+
+func (m *FeatureModel) Set(name string, enabled bool) error {
+	if m.flags == nil {
+		m.flags = map[string]bool{}
+	}
+	m.flags[name] = enabled
+	return nil
+}
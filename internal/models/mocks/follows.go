@@ -0,0 +1,52 @@
+package mocks
+
+// FollowModel keeps follows in memory. Alice (user 1) starts off following
+// the admin user (user 2).
+type FollowModel struct {
+	follows map[[2]int]bool
+}
+
+func (m *FollowModel) init() {
+	if m.follows == nil {
+		m.follows = map[[2]int]bool{{1, 2}: true}
+	}
+}
+
+func (m *FollowModel) Follow(followerID, followeeID int) error {
+	m.init()
+	m.follows[[2]int{followerID, followeeID}] = true
+	return nil
+}
+
+func (m *FollowModel) Unfollow(followerID, followeeID int) error {
+	m.init()
+	delete(m.follows, [2]int{followerID, followeeID})
+	return nil
+}
+
+func (m *FollowModel) IsFollowing(followerID, followeeID int) (bool, error) {
+	m.init()
+	return m.follows[[2]int{followerID, followeeID}], nil
+}
+
+func (m *FollowModel) Following(followerID int) ([]int, error) {
+	m.init()
+	ids := []int{}
+	for k := range m.follows {
+		if k[0] == followerID {
+			ids = append(ids, k[1])
+		}
+	}
+	return ids, nil
+}
+
+func (m *FollowModel) Followers(followeeID int) ([]int, error) {
+	m.init()
+	ids := []int{}
+	for k := range m.follows {
+		if k[1] == followeeID {
+			ids = append(ids, k[0])
+		}
+	}
+	return ids, nil
+}
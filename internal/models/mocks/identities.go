@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+)
+
+// IdentityModel keeps identities in memory. None are linked to begin with.
+type IdentityModel struct {
+	mu         sync.Mutex
+	identities map[[2]string]int
+}
+
+func (m *IdentityModel) UserID(issuer, subject string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.identities[[2]string{issuer, subject}]
+	if !ok {
+		return 0, models.ErrNoRecord
+	}
+	return id, nil
+}
+
+func (m *IdentityModel) Link(userID int, issuer, subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{issuer, subject}
+	if _, ok := m.identities[key]; ok {
+		return models.ErrDuplicateIdentity
+	}
+	if m.identities == nil {
+		m.identities = map[[2]string]int{}
+	}
+	m.identities[key] = userID
+	return nil
+}
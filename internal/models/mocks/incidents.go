@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+)
+
+// IncidentModel keeps inserted incidents in memory so tests can inspect them.
+type IncidentModel struct {
+	Incidents []*models.Incident
+}
+
+func (m *IncidentModel) Insert(incident *models.Incident) error {
+	m.Incidents = append(m.Incidents, incident)
+	return nil
+}
+
+func (m *IncidentModel) Latest() ([]*models.Incident, error) {
+	return m.Incidents, nil
+}
@@ -0,0 +1,17 @@
+package mocks
+
+// IntegrityModel finds the rows in Orphans, by check name, which tests can
+// fill in. DeleteOrphans deletes them.
+type IntegrityModel struct {
+	Orphans map[string]int
+}
+
+func (m *IntegrityModel) CountOrphans(check string) (int, error) {
+	return m.Orphans[check], nil
+}
+
+func (m *IntegrityModel) DeleteOrphans(check string) (int, error) {
+	n := m.Orphans[check]
+	delete(m.Orphans, check)
+	return n, nil
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"time"
+)
+
+type InvitationModel struct{}
+
+func (m *InvitationModel) Insert(createdBy, maxUses, expires int) (*models.Invitation, error) {
+	i := &models.Invitation{
+		ID:        1,
+		Code:      "NEWINVITATIONXYZ",
+		MaxUses:   maxUses,
+		CreatedBy: createdBy,
+		Created:   time.Now(),
+		Expires:   time.Now().AddDate(0, 0, expires),
+	}
+
+	return i, nil
+}
+
+func (m *InvitationModel) Latest() ([]*models.Invitation, error) {
+	return []*models.Invitation{}, nil
+}
+
+func (m *InvitationModel) Consume(code string) error {
+	switch code {
+	case "VALIDINVITATION1":
+		return nil
+	default:
+		return models.ErrInvalidInvitation
+	}
+}
+
+func (m *InvitationModel) Release(code string) error {
+	return nil
+}
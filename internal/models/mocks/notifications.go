@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"time"
+)
+
+var mockNotification = &models.Notification{
+	ID:      1,
+	UserID:  1,
+	Kind:    models.NotificationAnnouncement,
+	Message: "Welcome to Snippetbox!",
+	Link:    "/about",
+	Created: time.Now(),
+}
+
+// NotificationModel keeps the messages sent to the admins in AdminMessages.
+type NotificationModel struct {
+	AdminMessages []string
+}
+
+func (m *NotificationModel) Insert(userID int, kind, message, link string) error {
+	return nil
+}
+
+func (m *NotificationModel) Broadcast(kind, message, link string) (int, error) {
+	return 2, nil
+}
+
+func (m *NotificationModel) NotifyAdmins(kind, message, link string) (int, error) {
+	m.AdminMessages = append(m.AdminMessages, message)
+	return 1, nil
+}
+
+func (m *NotificationModel) ForUser(userID int) ([]*models.Notification, error) {
+	if userID == 1 {
+		return []*models.Notification{mockNotification}, nil
+	}
+	return []*models.Notification{}, nil
+}
+
+func (m *NotificationModel) UnreadCount(userID int) (int, error) {
+	if userID == 1 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	return nil
+}
+
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	return nil
+}
+
+func (m *NotificationModel) DeleteOlderThan(days int) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,257 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrgModel keeps organizations in memory. It starts with organization 1,
+// Acme, owned by Admin (2), which mockOrgSnippet was published for. Invitation
+// tokens are "token-" followed by the invitation's ID.
+type OrgModel struct {
+	mu          sync.Mutex
+	orgs        []*models.Org
+	members     map[int]map[int]string
+	invitations []*models.OrgInvitation
+}
+
+func (m *OrgModel) init() {
+	if m.orgs != nil {
+		return
+	}
+	m.orgs = []*models.Org{{ID: 1, Name: "Acme", Slug: "acme", Created: time.Now()}}
+	m.members = map[int]map[int]string{1: {2: models.OrgOwner}}
+}
+
+func (m *OrgModel) Insert(name string, ownerID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	o := &models.Org{
+		ID:      len(m.orgs) + 1,
+		Name:    name,
+		Slug:    strings.ToLower(strings.ReplaceAll(name, " ", "-")),
+		Created: time.Now(),
+	}
+	m.orgs = append(m.orgs, o)
+	m.members[o.ID] = map[int]string{ownerID: models.OrgOwner}
+	return o.ID, nil
+}
+
+func (m *OrgModel) Get(id int) (*models.Org, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for _, o := range m.orgs {
+		if o.ID == id {
+			n := *o
+			return &n, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *OrgModel) GetBySlug(slug string) (*models.Org, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for _, o := range m.orgs {
+		if o.Slug == slug {
+			n := *o
+			return &n, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *OrgModel) ForUser(userID int) ([]*models.Org, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	orgs := []*models.Org{}
+	for _, o := range m.orgs {
+		if role, ok := m.members[o.ID][userID]; ok {
+			n := *o
+			n.Role = role
+			orgs = append(orgs, &n)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Name < orgs[j].Name })
+	return orgs, nil
+}
+
+func (m *OrgModel) Role(orgID, userID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	role, ok := m.members[orgID][userID]
+	if !ok {
+		return "", models.ErrNoRecord
+	}
+	return role, nil
+}
+
+// Members returns the members using the names and addresses of the mock
+// users.
+func (m *OrgModel) Members(orgID int) ([]*models.OrgMembership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	members := []*models.OrgMembership{}
+	for userID, role := range m.members[orgID] {
+		u, err := (&UserModel{}).Get(userID)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, &models.OrgMembership{UserID: userID, Name: u.Name, Email: u.Email, Role: role, Joined: time.Now()})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return members[i].Role == models.OrgOwner
+		}
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+func (m *OrgModel) SetRole(orgID, userID int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	if err := m.checkOwnerLeft(orgID, userID, role == models.OrgOwner); err != nil {
+		return err
+	}
+	m.members[orgID][userID] = role
+	return nil
+}
+
+func (m *OrgModel) RemoveMember(orgID, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	if err := m.checkOwnerLeft(orgID, userID, false); err != nil {
+		return err
+	}
+	delete(m.members[orgID], userID)
+	return nil
+}
+
+func (m *OrgModel) checkOwnerLeft(orgID, userID int, stillOwner bool) error {
+	role, ok := m.members[orgID][userID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	owners := 0
+	for _, r := range m.members[orgID] {
+		if r == models.OrgOwner {
+			owners++
+		}
+	}
+	if role == models.OrgOwner && !stillOwner && owners == 1 {
+		return models.ErrLastOwner
+	}
+	return nil
+}
+
+func (m *OrgModel) Invite(orgID, invitedBy int, email, role string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for i, inv := range m.invitations {
+		if inv != nil && inv.OrgID == orgID && inv.Email == email {
+			m.invitations[i] = nil
+		}
+	}
+
+	inv := &models.OrgInvitation{
+		ID:        len(m.invitations) + 1,
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		InvitedBy: invitedBy,
+		Created:   time.Now(),
+		Expires:   time.Now().Add(ttl),
+	}
+	m.invitations = append(m.invitations, inv)
+	return "token-" + strconv.Itoa(inv.ID), nil
+}
+
+func (m *OrgModel) Invitations(orgID int) ([]*models.OrgInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitations := []*models.OrgInvitation{}
+	for i := len(m.invitations) - 1; i >= 0; i-- {
+		inv := m.invitations[i]
+		if inv != nil && inv.OrgID == orgID && inv.Expires.After(time.Now()) {
+			n := *inv
+			invitations = append(invitations, &n)
+		}
+	}
+	return invitations, nil
+}
+
+func (m *OrgModel) RevokeInvitation(orgID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, inv := range m.invitations {
+		if inv != nil && inv.ID == id && inv.OrgID == orgID {
+			m.invitations[i] = nil
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *OrgModel) GetInvitation(token string) (*models.OrgInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, inv := m.invitation(token)
+	if inv == nil {
+		return nil, models.ErrInvalidToken
+	}
+	n := *inv
+	return &n, nil
+}
+
+func (m *OrgModel) AcceptInvitation(token string, userID int) (*models.OrgInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	i, inv := m.invitation(token)
+	if inv == nil {
+		return nil, models.ErrInvalidToken
+	}
+	if _, ok := m.members[inv.OrgID][userID]; !ok {
+		m.members[inv.OrgID][userID] = inv.Role
+	}
+	m.invitations[i] = nil
+	return inv, nil
+}
+
+// invitation finds the unexpired invitation for a token, and its index.
+func (m *OrgModel) invitation(token string) (int, *models.OrgInvitation) {
+	for i, inv := range m.invitations {
+		if inv != nil && "token-"+strconv.Itoa(inv.ID) == token && inv.Expires.After(time.Now()) {
+			return i, inv
+		}
+	}
+	return -1, nil
+}
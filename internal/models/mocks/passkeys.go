@@ -0,0 +1,87 @@
+package mocks
+
+import (
+	"bytes"
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+	"time"
+)
+
+// PasskeyModel keeps passkeys in memory. Nobody has any to begin with.
+type PasskeyModel struct {
+	mu       sync.Mutex
+	passkeys []*models.Passkey
+	lastID   int
+}
+
+func (m *PasskeyModel) Insert(p *models.Passkey) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.passkeys {
+		if bytes.Equal(existing.CredentialID, p.CredentialID) {
+			return 0, models.ErrDuplicatePasskey
+		}
+	}
+
+	stored := *p
+	m.lastID++
+	stored.ID = m.lastID
+	stored.Created = time.Now()
+	m.passkeys = append(m.passkeys, &stored)
+	return stored.ID, nil
+}
+
+func (m *PasskeyModel) ForUser(userID int) ([]*models.Passkey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	passkeys := []*models.Passkey{}
+	for _, p := range m.passkeys {
+		if p.UserID == userID {
+			passkeys = append(passkeys, p)
+		}
+	}
+	return passkeys, nil
+}
+
+func (m *PasskeyModel) GetByCredentialID(credentialID []byte) (*models.Passkey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.passkeys {
+		if bytes.Equal(p.CredentialID, credentialID) {
+			return p, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *PasskeyModel) Used(id int, signCount uint32, backupState bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.passkeys {
+		if p.ID == id {
+			now := time.Now()
+			p.SignCount = signCount
+			p.BackupState = backupState
+			p.LastUsed = &now
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *PasskeyModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, p := range m.passkeys {
+		if p.ID == id && p.UserID == userID {
+			m.passkeys = append(m.passkeys[:i], m.passkeys[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
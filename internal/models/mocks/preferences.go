@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+)
+
+// PreferenceModel keeps preferences in memory. Everybody has the defaults
+// to begin with.
+type PreferenceModel struct {
+	mu          sync.Mutex
+	preferences map[int]models.Preferences
+}
+
+func (m *PreferenceModel) Get(userID int) (*models.Preferences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.preferences[userID]
+	if !ok {
+		p = models.DefaultPreferences
+	}
+	return &p, nil
+}
+
+func (m *PreferenceModel) Set(userID int, p *models.Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.preferences == nil {
+		m.preferences = map[int]models.Preferences{}
+	}
+	m.preferences[userID] = *p
+	return nil
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"time"
+)
+
+// SessionModel reports three active sessions, one of them expiring soon, and
+// two expired ones which are removed by the first DeleteExpired. Active
+// returns Sessions, which tests can fill in, and Delete removes them.
+type SessionModel struct {
+	Sessions []models.SessionData
+
+	deleted bool
+}
+
+func (m *SessionModel) Stats(soon time.Duration) (*models.SessionStats, error) {
+	stats := &models.SessionStats{Active: 3, ExpiringSoon: 1, Expired: 2}
+	if m.deleted {
+		stats.Expired = 0
+	}
+	return stats, nil
+}
+
+func (m *SessionModel) Active() ([]models.SessionData, error) {
+	return m.Sessions, nil
+}
+
+func (m *SessionModel) Delete(token string) error {
+	for i, s := range m.Sessions {
+		if s.Token == token {
+			m.Sessions = append(m.Sessions[:i], m.Sessions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *SessionModel) DeleteExpired() (int, error) {
+	if m.deleted {
+		return 0, nil
+	}
+	m.deleted = true
+	return 2, nil
+}
@@ -1,33 +1,286 @@
 package mocks
 
 import (
+	"github.com/ngohoang211020/snippetbox/internal/metadata"
 	"github.com/ngohoang211020/snippetbox/internal/models"
+	"slices"
 	"time"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:       1,
+	PublicID: "00000000000000000000000001",
+	Title:    "An old silent pond",
+	Content:  "An old silent pond...",
+	UserID:   2,
+	Files: []*models.SnippetFile{
+		{Position: 1, Filename: "frog.txt", Language: "plaintext", Content: "A frog jumps into the pond,"},
+	},
+	Created:  time.Now(),
+	Expires:  time.Now(),
+	Version:  1,
+	Metadata: &metadata.Metadata{Lines: 2, Bytes: 48, ReadSeconds: 4},
 }
 
-type SnippetModel struct{}
+// mockScheduledSnippet belongs to user 1 and isn't published until next year.
+var mockScheduledSnippet = &models.Snippet{
+	ID:        3,
+	PublicID:  "00000000000000000000000003",
+	Title:     "A scheduled snippet",
+	Content:   "Coming soon.",
+	UserID:    1,
+	Created:   time.Now(),
+	Expires:   time.Now().AddDate(2, 0, 0),
+	PublishAt: time.Now().AddDate(1, 0, 0),
+	Version:   1,
+}
+
+// mockBurnSnippet belongs to user 2 and is burned after reading.
+var mockBurnSnippet = &models.Snippet{
+	ID:               4,
+	PublicID:         "00000000000000000000000004",
+	Title:            "A secret",
+	Content:          "The password is hunter2.",
+	UserID:           2,
+	Created:          time.Now(),
+	Expires:          time.Now().AddDate(0, 0, 7),
+	Version:          1,
+	BurnAfterReading: true,
+}
+
+// mockEncryptedSnippet belongs to user 1 and was encrypted in the browser.
+var mockEncryptedSnippet = &models.Snippet{
+	ID:               5,
+	PublicID:         "00000000000000000000000005",
+	Title:            "An encrypted snippet",
+	Content:          "v1:AAAAAAAAAAAAAAAA:AAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	UserID:           1,
+	Created:          time.Now(),
+	Expires:          time.Now().AddDate(0, 0, 7),
+	Version:          1,
+	ContentEncrypted: true,
+}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+// mockOrgSnippet belongs to user 2, and was published only for the members
+// of organization 1.
+var mockOrgSnippet = &models.Snippet{
+	ID:       6,
+	PublicID: "00000000000000000000000006",
+	Title:    "An internal snippet",
+	Content:  "For the team's eyes only.",
+	UserID:   2,
+	Created:  time.Now(),
+	Expires:  time.Now().AddDate(0, 0, 7),
+	Version:  1,
+	OrgID:    1,
+	OrgOnly:  true,
 }
 
+// SnippetModel remembers whether mockBurnSnippet has been burned.
+type SnippetModel struct {
+	burned bool
+}
+
+// Insert pretends to store the snippet and returns the ID of mockSnippet, so
+// that a subsequent Get for the returned ID succeeds, and gives s its public
+// ID. A snippet titled "Too large" fails with ErrTooLarge, as one too large
+// for the database would.
+func (m *SnippetModel) Insert(s *models.Snippet, expires int) (int, error) {
+	if s.Title == "Too large" {
+		return 0, models.ErrTooLarge
+	}
+	s.PublicID = mockSnippet.PublicID
+	return mockSnippet.ID, nil
+}
+
+// IDForPublicID looks up the mock snippets' public IDs.
+func (m *SnippetModel) IDForPublicID(publicID string) (int, error) {
+	for _, s := range []*models.Snippet{mockSnippet, mockScheduledSnippet, mockBurnSnippet, mockEncryptedSnippet, mockOrgSnippet} {
+		if s.PublicID == publicID {
+			return s.ID, nil
+		}
+	}
+	return 0, models.ErrNoRecord
+}
+
+// Get returns a copy of the mock snippet with the given ID, as the real model
+// does, so that a caller which changes it doesn't change it for everyone.
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	s, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	c := *s
+	return &c, nil
+}
+
+func (m *SnippetModel) lookup(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockScheduledSnippet, nil
+	case 4:
+		if m.burned {
+			s := *mockBurnSnippet
+			s.Content = ""
+			s.BurnedAt = time.Now()
+			return &s, nil
+		}
+		return mockBurnSnippet, nil
+	case 5:
+		return mockEncryptedSnippet, nil
+	case 6:
+		return mockOrgSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
+// SetLanguage pretends to change the language, failing with an edit conflict
+// if a version other than the snippet's current one is given.
+func (m *SnippetModel) SetLanguage(snippetID, position int, language string, version int) error {
+	if s, err := m.Get(snippetID); err == nil && version != 0 && version != s.Version {
+		return models.ErrEditConflict
+	}
+	return nil
+}
+
+// Update pretends to save the snippet, failing with an edit conflict if a
+// version other than the snippet's current one is given.
+func (m *SnippetModel) Update(s *models.Snippet, version int) error {
+	if current, err := m.Get(s.ID); err == nil && version != 0 && version != current.Version {
+		return models.ErrEditConflict
+	}
+	return nil
+}
+
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) PublishDue() ([]*models.Snippet, error) {
+	return []*models.Snippet{}, nil
+}
+
+// LatestFromAuthors returns mockSnippet if its owner, user 2, is one of the
+// authors asked for.
+func (m *SnippetModel) LatestFromAuthors(userIDs []int, limit, offset int) ([]*models.Snippet, int, error) {
+	for _, id := range userIDs {
+		if id == mockSnippet.UserID {
+			return page(offset), 1, nil
+		}
+	}
+	return []*models.Snippet{}, 0, nil
+}
+
+// LatestAfter returns mockSnippet, the only published snippet, from the
+// start of the list if it belongs to one of the users asked for.
+func (m *SnippetModel) LatestAfter(after models.SnippetCursor, limit int, userIDs ...int) ([]*models.Snippet, error) {
+	if after.PublicID != "" || (len(userIDs) > 0 && !slices.Contains(userIDs, mockSnippet.UserID)) {
+		return []*models.Snippet{}, nil
+	}
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+// Page returns mockSnippet as the only published snippet.
+func (m *SnippetModel) Page(limit, offset int) ([]*models.Snippet, int, error) {
+	return page(offset), 1, nil
+}
+
+// CountByLanguage treats mockSnippet as plaintext, the only language in
+// use.
+func (m *SnippetModel) CountByLanguage() ([]*models.LanguageCount, error) {
+	return []*models.LanguageCount{{Language: "plaintext", Count: 1}}, nil
+}
+
+// Stats counts mockSnippet, the only published snippet, and the two mock
+// users.
+func (m *SnippetModel) Stats() (*models.InstanceStats, error) {
+	counts, err := m.CountByLanguage()
+	if err != nil {
+		return nil, err
+	}
+	return &models.InstanceStats{PublicSnippets: 1, Users: 2, Languages: counts}, nil
+}
+
+// ByLanguage returns mockSnippet for plaintext, and nothing for any other
+// language.
+func (m *SnippetModel) ByLanguage(language, order string, limit, offset int) ([]*models.Snippet, int, error) {
+	if language != "plaintext" {
+		return []*models.Snippet{}, 0, nil
+	}
+	return page(offset), 1, nil
+}
+
+// GetAndConsume returns mockBurnSnippet to anyone but its owner, the first
+// time it's asked for.
+func (m *SnippetModel) GetAndConsume(id, viewerID int) (*models.Snippet, error) {
+	if id != mockBurnSnippet.ID || viewerID == mockBurnSnippet.UserID || m.burned {
+		return nil, models.ErrNoRecord
+	}
+	m.burned = true
+	return mockBurnSnippet, nil
+}
+
+// ForOrg returns mockOrgSnippet for organization 1's members, and nothing
+// otherwise.
+func (m *SnippetModel) ForOrg(orgID int, includeOrgOnly bool, limit, offset int) ([]*models.Snippet, int, error) {
+	if orgID != mockOrgSnippet.OrgID || !includeOrgOnly || offset > 0 {
+		return []*models.Snippet{}, 0, nil
+	}
+	return []*models.Snippet{mockOrgSnippet}, 1, nil
+}
+
+// AssignPublicIDs pretends every snippet already has one.
+func (m *SnippetModel) AssignPublicIDs(limit int) (int, error) {
+	return 0, nil
+}
+
+// FillMetadata pretends there's nothing left to fill in.
+func (m *SnippetModel) FillMetadata(limit int) (int, error) {
+	return 0, nil
+}
+
+// FilesFor returns mockSnippet's files, if it's one of the snippets asked
+// for.
+func (m *SnippetModel) FilesFor(snippetIDs []int) (map[int][]*models.SnippetFile, error) {
+	files := map[int][]*models.SnippetFile{}
+	for _, id := range snippetIDs {
+		if id == mockSnippet.ID {
+			files[id] = mockSnippet.Files
+		}
+	}
+	return files, nil
+}
+
+// ForUserAfter returns the mock snippets the user owns, leaving out the
+// encrypted one as the real model does.
+func (m *SnippetModel) ForUserAfter(userID, afterID, limit int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	for _, s := range []*models.Snippet{mockSnippet, mockScheduledSnippet, mockBurnSnippet, mockEncryptedSnippet, mockOrgSnippet} {
+		if s.UserID == userID && s.ID > afterID && !s.ContentEncrypted && len(snippets) < limit {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, nil
+}
+
+// CountAnonymousBefore says there are no snippets without an owner, as
+// every mock snippet has one.
+func (m *SnippetModel) CountAnonymousBefore(t time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) DeleteAnonymousBefore(t time.Time) (int, error) {
+	return 0, nil
+}
+
+// page returns the page of a list containing just mockSnippet which starts
+// at offset.
+func page(offset int) []*models.Snippet {
+	if offset > 0 {
+		return []*models.Snippet{}
+	}
+	return []*models.Snippet{mockSnippet}
+}
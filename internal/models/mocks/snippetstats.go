@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"time"
+)
+
+// SnippetStatsModel keeps recorded views in memory. Nothing is ever
+// aggregated, so every view counts as one from today.
+type SnippetStatsModel struct {
+	Views []*models.SnippetView
+}
+
+func (m *SnippetStatsModel) RecordView(v *models.SnippetView) error {
+	v.Viewed = time.Now()
+	m.Views = append(m.Views, v)
+	return nil
+}
+
+func (m *SnippetStatsModel) Aggregate() (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetStatsModel) ForSnippet(snippetID, days int) (*models.SnippetStats, error) {
+	referrers := map[string]int{}
+	countries := map[string]int{}
+	browsers := map[string]int{}
+
+	stats := &models.SnippetStats{Days: days}
+	for _, v := range m.Views {
+		if v.SnippetID != snippetID {
+			continue
+		}
+		stats.Today++
+		referrers[v.Referrer]++
+		countries[v.Country]++
+		browsers[v.Browser]++
+	}
+	stats.Total = stats.Today
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := days - 1; i >= 0; i-- {
+		day := models.DailyViews{Day: today.AddDate(0, 0, -i)}
+		if i == 0 {
+			day.Views = stats.Today
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+
+	stats.Referrers = viewCounts(referrers)
+	stats.Countries = viewCounts(countries)
+	stats.Browsers = viewCounts(browsers)
+
+	return stats, nil
+}
+
+// CountDetailBefore finds nothing, as every view is from today.
+func (m *SnippetStatsModel) CountDetailBefore(day time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetStatsModel) DeleteDetailBefore(day time.Time) (int, error) {
+	return 0, nil
+}
+
+func viewCounts(m map[string]int) []models.ViewCount {
+	counts := []models.ViewCount{}
+	for value, views := range m {
+		counts = append(counts, models.ViewCount{Value: value, Views: views})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Views != counts[j].Views {
+			return counts[i].Views > counts[j].Views
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	return counts
+}
@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SnippetTemplateModel keeps snippet templates in memory. Alice starts with
+// one template, "Go program", with ID 1.
+type SnippetTemplateModel struct {
+	mu        sync.Mutex
+	templates []*models.SnippetTemplate
+	lastID    int
+}
+
+var mockSnippetTemplate = models.SnippetTemplate{
+	ID:       1,
+	UserID:   1,
+	Name:     "Go program",
+	Title:    "A Go program",
+	Content:  "package main\n\nfunc main() {\n}\n",
+	Filename: "main.go",
+	Language: "go",
+}
+
+func (m *SnippetTemplateModel) init() {
+	if m.templates == nil {
+		t := mockSnippetTemplate
+		m.templates = []*models.SnippetTemplate{&t}
+		m.lastID = 1
+	}
+}
+
+func (m *SnippetTemplateModel) Insert(t *models.SnippetTemplate) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for _, existing := range m.templates {
+		if existing.UserID == t.UserID && existing.Name == t.Name {
+			return 0, models.ErrDuplicateTemplateName
+		}
+	}
+
+	stored := *t
+	m.lastID++
+	stored.ID = m.lastID
+	stored.Created = time.Now()
+	stored.Updated = stored.Created
+	m.templates = append(m.templates, &stored)
+	return stored.ID, nil
+}
+
+func (m *SnippetTemplateModel) Get(id, userID int) (*models.SnippetTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for _, t := range m.templates {
+		if t.ID == id && t.UserID == userID {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetTemplateModel) ForUser(userID int) ([]*models.SnippetTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	templates := []*models.SnippetTemplate{}
+	for _, t := range m.templates {
+		if t.UserID == userID {
+			c := *t
+			templates = append(templates, &c)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (m *SnippetTemplateModel) Update(t *models.SnippetTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for _, existing := range m.templates {
+		if existing.UserID == t.UserID && existing.Name == t.Name && existing.ID != t.ID {
+			return models.ErrDuplicateTemplateName
+		}
+	}
+	for _, existing := range m.templates {
+		if existing.ID == t.ID && existing.UserID == t.UserID {
+			created := existing.Created
+			*existing = *t
+			existing.Created = created
+			existing.Updated = time.Now()
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetTemplateModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for i, t := range m.templates {
+		if t.ID == id && t.UserID == userID {
+			m.templates = append(m.templates[:i], m.templates[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -2,10 +2,22 @@ package mocks
 
 import (
 	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
 	"time"
 )
 
-type UserModel struct{}
+// UserModel has two users, Alice (1) and Admin (2). Only the changelog
+// version they've seen and their suspensions can be changed.
+type UserModel struct {
+	mu            sync.Mutex
+	changelogSeen map[int]string
+	suspensions   map[int]suspension
+}
+
+type suspension struct {
+	until  time.Time
+	reason string
+}
 
 func (m *UserModel) Insert(name, email, password string) error {
 	switch email {
@@ -20,13 +32,16 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
+	if email == "admin@example.com" && password == "pa$$word" {
+		return 2, nil
+	}
 
 	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2:
 		return true, nil
 	default:
 		return false, nil
@@ -34,18 +49,54 @@ func (m *UserModel) Exists(id int) (bool, error) {
 }
 
 func (m *UserModel) Get(id int) (*models.User, error) {
-	if id == 1 {
-		u := &models.User{
+	var u *models.User
+
+	switch id {
+	case 1:
+		u = &models.User{
 			ID:      1,
 			Name:    "Alice",
 			Email:   "alice@example.com",
 			Created: time.Now(),
 		}
+	case 2:
+		u = &models.User{
+			ID:      2,
+			Name:    "Admin",
+			Email:   "admin@example.com",
+			Created: time.Now(),
+			IsAdmin: true,
+		}
+	default:
+		return nil, models.ErrNoRecord
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.suspensions[id]
+	u.SuspendedUntil, u.BanReason = s.until, s.reason
+	return u, nil
+}
 
-		return u, nil
+func (m *UserModel) GetMany(ids []int) (map[int]*models.User, error) {
+	users := map[int]*models.User{}
+	for _, id := range ids {
+		if u, err := m.Get(id); err == nil {
+			users[id] = u
+		}
 	}
+	return users, nil
+}
 
-	return nil, models.ErrNoRecord
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return m.Get(1)
+	case "admin@example.com":
+		return m.Get(2)
+	default:
+		return nil, models.ErrNoRecord
+	}
 }
 
 func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
@@ -59,3 +110,65 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 
 	return models.ErrNoRecord
 }
+
+// SetEmail refuses the addresses of the mock users, which are taken.
+func (m *UserModel) SetEmail(id int, email string) error {
+	if _, err := m.GetByEmail(email); err == nil {
+		return models.ErrDuplicateEmail
+	}
+	return nil
+}
+
+func (m *UserModel) ChangelogSeen(id int) (string, error) {
+	if ok, _ := m.Exists(id); !ok {
+		return "", models.ErrNoRecord
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.changelogSeen[id], nil
+}
+
+func (m *UserModel) SetChangelogSeen(id int, version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.changelogSeen == nil {
+		m.changelogSeen = map[int]string{}
+	}
+	m.changelogSeen[id] = version
+	return nil
+}
+
+func (m *UserModel) Suspend(id int, until time.Time, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.suspensions == nil {
+		m.suspensions = map[int]suspension{}
+	}
+	m.suspensions[id] = suspension{until: until, reason: reason}
+	return nil
+}
+
+func (m *UserModel) Unsuspend(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.suspensions, id)
+	return nil
+}
+
+func (m *UserModel) UnsuspendExpired() ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []int
+	for id, s := range m.suspensions {
+		if !s.until.After(time.Now()) {
+			ids = append(ids, id)
+			delete(m.suspensions, id)
+		}
+	}
+	return ids, nil
+}
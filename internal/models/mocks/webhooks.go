@@ -0,0 +1,147 @@
+package mocks
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/models"
+	"sync"
+	"time"
+)
+
+// WebhookModel keeps webhooks and their deliveries in memory. There are none
+// to begin with. Unlike the real model, claimed deliveries are due again
+// immediately if they're left pending.
+type WebhookModel struct {
+	mu         sync.Mutex
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *WebhookModel) Insert(w *models.Webhook) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := *w
+	c.ID = len(m.webhooks) + 1
+	c.Created = time.Now()
+	m.webhooks = append(m.webhooks, &c)
+	return c.ID, nil
+}
+
+func (m *WebhookModel) Get(id, userID int) (*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.webhooks {
+		if w != nil && w.ID == id && w.UserID == userID {
+			return w, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *WebhookModel) ForUser(userID int) ([]*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhooks := []*models.Webhook{}
+	for _, w := range m.webhooks {
+		if w != nil && w.UserID == userID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *WebhookModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, w := range m.webhooks {
+		if w != nil && w.ID == id && w.UserID == userID {
+			m.webhooks[i] = nil
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Enqueue(event string, ownerID int, payload []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, w := range m.webhooks {
+		if w == nil || (w.UserID != 0 && w.UserID != ownerID) || !w.Subscribes(event) {
+			continue
+		}
+		m.deliveries = append(m.deliveries, &models.WebhookDelivery{
+			ID:          len(m.deliveries) + 1,
+			WebhookID:   w.ID,
+			Event:       event,
+			Payload:     string(payload),
+			Status:      models.DeliveryPending,
+			NextAttempt: time.Now(),
+			Created:     time.Now(),
+			Updated:     time.Now(),
+			URL:         w.URL,
+			Secret:      w.Secret,
+			UserID:      w.UserID,
+		})
+		n++
+	}
+	return n, nil
+}
+
+func (m *WebhookModel) Due(limit int) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*models.WebhookDelivery
+	for _, d := range m.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.Status == models.DeliveryPending && !d.NextAttempt.After(time.Now()) {
+			d.Attempts++
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (m *WebhookModel) Delivered(id, responseStatus int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := m.deliveries[id-1]
+	d.Status, d.ResponseStatus, d.Error, d.Updated = models.DeliveryDelivered, responseStatus, "", time.Now()
+	return nil
+}
+
+func (m *WebhookModel) Failed(id, responseStatus int, message string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := m.deliveries[id-1]
+	d.ResponseStatus, d.Error, d.Updated = responseStatus, message, time.Now()
+	if retryAt.IsZero() {
+		d.Status = models.DeliveryFailed
+	} else {
+		d.NextAttempt = retryAt
+	}
+	return nil
+}
+
+func (m *WebhookModel) Deliveries(webhookID, limit int) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := []*models.WebhookDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if d := m.deliveries[i]; d.WebhookID == webhookID {
+			c := *d
+			deliveries = append(deliveries, &c)
+		}
+	}
+	return deliveries, nil
+}
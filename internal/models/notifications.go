@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of notification.
+const (
+	NotificationAnnouncement = "announcement"
+	NotificationPublished    = "published"
+	NotificationIntegrity    = "integrity"
+)
+
+type Notification struct {
+	ID      int
+	UserID  int
+	Kind    string
+	Message string
+	Link    string
+	Created time.Time
+	Read    bool
+}
+
+type NotificationModelInterface interface {
+	Insert(userID int, kind, message, link string) error
+	Broadcast(kind, message, link string) (int, error)
+	NotifyAdmins(kind, message, link string) (int, error)
+	ForUser(userID int) ([]*Notification, error)
+	UnreadCount(userID int) (int, error)
+	MarkRead(userID, id int) error
+	MarkAllRead(userID int) error
+	DeleteOlderThan(days int) (int, error)
+}
+
+type NotificationModel struct {
+	DB DBTX
+}
+
+// Insert adds a notification for a single user.
+func (m *NotificationModel) Insert(userID int, kind, message, link string) error {
+	stmt := `INSERT INTO notifications (user_id, kind, message, link, created)
+    VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, kind, message, link)
+	return err
+}
+
+// Broadcast adds the same notification for every user, and returns how many
+// users were notified.
+func (m *NotificationModel) Broadcast(kind, message, link string) (int, error) {
+	stmt := `INSERT INTO notifications (user_id, kind, message, link, created)
+    SELECT id, ?, ?, ?, UTC_TIMESTAMP() FROM users`
+
+	result, err := m.DB.Exec(stmt, kind, message, link)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// NotifyAdmins adds the same notification for every admin, and returns how
+// many admins were notified.
+func (m *NotificationModel) NotifyAdmins(kind, message, link string) (int, error) {
+	stmt := `INSERT INTO notifications (user_id, kind, message, link, created)
+    SELECT id, ?, ?, ?, UTC_TIMESTAMP() FROM users WHERE is_admin = TRUE`
+
+	result, err := m.DB.Exec(stmt, kind, message, link)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// ForUser returns the 50 most recent notifications for a user, newest first.
+func (m *NotificationModel) ForUser(userID int) ([]*Notification, error) {
+	stmt := `SELECT id, user_id, kind, message, link, created, read_at FROM notifications
+    WHERE user_id = ? ORDER BY created DESC, id DESC LIMIT 50`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		n := &Notification{}
+		var readAt sql.NullTime
+
+		err = rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Message, &n.Link, &n.Created, &readAt)
+		if err != nil {
+			return nil, err
+		}
+		n.Read = readAt.Valid
+
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// UnreadCount returns the number of unread notifications for a user.
+func (m *NotificationModel) UnreadCount(userID int) (int, error) {
+	var count int
+
+	stmt := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks a single notification as read. The user ID is part of the
+// WHERE clause so users can only mark their own notifications.
+func (m *NotificationModel) MarkRead(userID, id int) error {
+	stmt := `UPDATE notifications SET read_at = UTC_TIMESTAMP()
+    WHERE id = ? AND user_id = ? AND read_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, id, userID)
+	return err
+}
+
+// MarkAllRead marks every notification for a user as read.
+func (m *NotificationModel) MarkAllRead(userID int) error {
+	stmt := `UPDATE notifications SET read_at = UTC_TIMESTAMP() WHERE user_id = ? AND read_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
+
+// DeleteOlderThan prunes notifications created more than the given number of
+// days ago, read or not, and returns how many were deleted.
+func (m *NotificationModel) DeleteOlderThan(days int) (int, error) {
+	stmt := `DELETE FROM notifications WHERE created < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)`
+
+	result, err := m.DB.Exec(stmt, days)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -0,0 +1,400 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// The roles a member of an organization can have. Owners manage the
+// organization: they invite people, change their roles and remove them.
+const (
+	OrgOwner  = "owner"
+	OrgMember = "member"
+)
+
+// Org is an organization, a group of users who publish snippets together.
+type Org struct {
+	ID      int
+	Name    string
+	Slug    string
+	Created time.Time
+
+	// Role is the user's role in the organization. It's only filled in by
+	// ForUser.
+	Role string
+}
+
+// OrgMembership is one of the members of an organization.
+type OrgMembership struct {
+	UserID int
+	Name   string
+	Email  string
+	Role   string
+	Joined time.Time
+}
+
+// OrgInvitation is an invitation, sent by email, to join an organization.
+type OrgInvitation struct {
+	ID        int
+	OrgID     int
+	Email     string
+	Role      string
+	InvitedBy int
+	Created   time.Time
+	Expires   time.Time
+}
+
+type OrgModelInterface interface {
+	Insert(name string, ownerID int) (int, error)
+	Get(id int) (*Org, error)
+	GetBySlug(slug string) (*Org, error)
+	ForUser(userID int) ([]*Org, error)
+	Role(orgID, userID int) (string, error)
+	Members(orgID int) ([]*OrgMembership, error)
+	SetRole(orgID, userID int, role string) error
+	RemoveMember(orgID, userID int) error
+	Invite(orgID, invitedBy int, email, role string, ttl time.Duration) (string, error)
+	Invitations(orgID int) ([]*OrgInvitation, error)
+	RevokeInvitation(orgID, id int) error
+	GetInvitation(token string) (*OrgInvitation, error)
+	AcceptInvitation(token string, userID int) (*OrgInvitation, error)
+}
+
+type OrgModel struct {
+	DB DBTX
+}
+
+// Insert creates an organization, with a slug made from its name, and makes
+// ownerID its first owner. It returns the organization's ID.
+func (m *OrgModel) Insert(name string, ownerID int) (int, error) {
+	slug, err := newSlug(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	err = transact(m.DB, func(tx DBTX) error {
+		result, err := tx.Exec(`INSERT INTO orgs (name, slug, created) VALUES(?, ?, UTC_TIMESTAMP())`, name, slug)
+		if err != nil {
+			return err
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = int(lastID)
+
+		_, err = tx.Exec(`INSERT INTO org_members (org_id, user_id, role, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`,
+			id, ownerID, OrgOwner)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (m *OrgModel) Get(id int) (*Org, error) {
+	return m.get(`SELECT id, name, slug, created FROM orgs WHERE id = ?`, id)
+}
+
+// GetBySlug returns the organization with the given slug, or ErrNoRecord if
+// there isn't one.
+func (m *OrgModel) GetBySlug(slug string) (*Org, error) {
+	return m.get(`SELECT id, name, slug, created FROM orgs WHERE slug = ?`, slug)
+}
+
+func (m *OrgModel) get(stmt string, args ...any) (*Org, error) {
+	o := &Org{}
+	err := m.DB.QueryRow(stmt, args...).Scan(&o.ID, &o.Name, &o.Slug, &o.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// ForUser returns the organizations the user is a member of, in order of
+// name, with their role in each.
+func (m *OrgModel) ForUser(userID int) ([]*Org, error) {
+	stmt := `SELECT o.id, o.name, o.slug, o.created, om.role
+    FROM orgs o JOIN org_members om ON om.org_id = o.id
+    WHERE om.user_id = ? ORDER BY o.name, o.id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*Org{}
+
+	for rows.Next() {
+		o := &Org{}
+		if err = rows.Scan(&o.ID, &o.Name, &o.Slug, &o.Created, &o.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// Role returns the user's role in the organization, or ErrNoRecord if they
+// aren't a member of it.
+func (m *OrgModel) Role(orgID, userID int) (string, error) {
+	var role string
+	err := m.DB.QueryRow(`SELECT role FROM org_members WHERE org_id = ? AND user_id = ?`, orgID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+// Members returns the members of the organization, owners first and then in
+// order of name.
+func (m *OrgModel) Members(orgID int) ([]*OrgMembership, error) {
+	stmt := `SELECT u.id, u.name, u.email, om.role, om.created
+    FROM org_members om JOIN users u ON u.id = om.user_id
+    WHERE om.org_id = ? ORDER BY om.role = 'owner' DESC, u.name, u.id`
+
+	rows, err := m.DB.Query(stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*OrgMembership{}
+
+	for rows.Next() {
+		mm := &OrgMembership{}
+		if err = rows.Scan(&mm.UserID, &mm.Name, &mm.Email, &mm.Role, &mm.Joined); err != nil {
+			return nil, err
+		}
+		members = append(members, mm)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetRole changes a member's role. It returns ErrNoRecord if the user isn't a
+// member, and ErrLastOwner if they are the organization's only owner and
+// would stop being one.
+func (m *OrgModel) SetRole(orgID, userID int, role string) error {
+	return transact(m.DB, func(tx DBTX) error {
+		if err := checkOwnerLeft(tx, orgID, userID, role == OrgOwner); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`UPDATE org_members SET role = ? WHERE org_id = ? AND user_id = ?`, role, orgID, userID)
+		return err
+	})
+}
+
+// RemoveMember takes the user out of the organization. The snippets they
+// published for it stay with it. It returns ErrNoRecord if the user isn't a
+// member, and ErrLastOwner if they are the organization's only owner.
+func (m *OrgModel) RemoveMember(orgID, userID int) error {
+	return transact(m.DB, func(tx DBTX) error {
+		if err := checkOwnerLeft(tx, orgID, userID, false); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`DELETE FROM org_members WHERE org_id = ? AND user_id = ?`, orgID, userID)
+		return err
+	})
+}
+
+// checkOwnerLeft checks that the organization will still have an owner once
+// userID, who must be a member, stops being one unless stillOwner is set.
+// The owners are locked until the transaction ends, so that two owners can't
+// both step down at once.
+func checkOwnerLeft(tx DBTX, orgID, userID int, stillOwner bool) error {
+	rows, err := tx.Query(`SELECT user_id, role FROM org_members WHERE org_id = ? FOR UPDATE`, orgID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	member, owners := false, 0
+	wasOwner := false
+
+	for rows.Next() {
+		var id int
+		var role string
+		if err = rows.Scan(&id, &role); err != nil {
+			return err
+		}
+		if role == OrgOwner {
+			owners++
+		}
+		if id == userID {
+			member = true
+			wasOwner = role == OrgOwner
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if !member {
+		return ErrNoRecord
+	}
+	if wasOwner && !stillOwner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// Invite records an invitation for email to join the organization with the
+// given role, and returns the token which accepts it. An earlier invitation
+// of the same address to the organization is replaced.
+func (m *OrgModel) Invite(orgID, invitedBy int, email, role string, ttl time.Duration) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = transact(m.DB, func(tx DBTX) error {
+		_, err := tx.Exec(`DELETE FROM org_invitations WHERE org_id = ? AND email = ?`, orgID, email)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO org_invitations (org_id, email, role, token_hash, invited_by, created, expires)
+    VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+		_, err = tx.Exec(stmt, orgID, email, role, hash, invitedBy, int(ttl.Seconds()))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// orgInvitationColumns are the columns scanOrgInvitation expects, in order.
+const orgInvitationColumns = "id, org_id, email, role, invited_by, created, expires"
+
+func scanOrgInvitation(row interface{ Scan(dest ...any) error }) (*OrgInvitation, error) {
+	i := &OrgInvitation{}
+	err := row.Scan(&i.ID, &i.OrgID, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expires)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Invitations returns the organization's invitations which haven't been
+// accepted and haven't expired, newest first.
+func (m *OrgModel) Invitations(orgID int) ([]*OrgInvitation, error) {
+	stmt := `SELECT ` + orgInvitationColumns + ` FROM org_invitations
+    WHERE org_id = ? AND expires > UTC_TIMESTAMP() ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*OrgInvitation{}
+
+	for rows.Next() {
+		i, err := scanOrgInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation deletes one of the organization's invitations, so its
+// link stops working. It returns ErrNoRecord if there's no such invitation.
+func (m *OrgModel) RevokeInvitation(orgID, id int) error {
+	result, err := m.DB.Exec(`DELETE FROM org_invitations WHERE id = ? AND org_id = ?`, id, orgID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetInvitation returns the invitation for a token, or ErrInvalidToken if
+// there is no such invitation or it has expired.
+func (m *OrgModel) GetInvitation(token string) (*OrgInvitation, error) {
+	return getOrgInvitation(m.DB, token)
+}
+
+func getOrgInvitation(db DBTX, token string) (*OrgInvitation, error) {
+	stmt := `SELECT ` + orgInvitationColumns + ` FROM org_invitations
+    WHERE token_hash = ? AND expires > UTC_TIMESTAMP()`
+
+	i, err := scanOrgInvitation(db.QueryRow(stmt, hashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return i, nil
+}
+
+// AcceptInvitation makes the user a member of the organization they were
+// invited to, and deletes the invitation. Someone who is already a member
+// keeps the role they have. It returns ErrInvalidToken if the token is
+// unknown or has expired.
+func (m *OrgModel) AcceptInvitation(token string, userID int) (*OrgInvitation, error) {
+	var i *OrgInvitation
+
+	err := transact(m.DB, func(tx DBTX) error {
+		var err error
+		i, err = getOrgInvitation(tx, token)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO org_members (org_id, user_id, role, created) VALUES(?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE role = role`
+
+		_, err = tx.Exec(stmt, i.OrgID, userID, i.Role)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM org_invitations WHERE id = ?`, i.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
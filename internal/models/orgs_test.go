@@ -0,0 +1,158 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestOrgModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+
+	users := UserModel{DB: db}
+	err := users.Insert("Bob", "bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.Authenticate("bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := OrgModel{DB: db}
+
+	id, err := m.Insert("Frog Pond", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySlug, err := m.GetBySlug(o.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bySlug.Name, "Frog Pond")
+
+	role, err := m.Role(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, role, OrgOwner)
+	_, err = m.Role(id, bob)
+	assert.Equal(t, err, ErrNoRecord)
+
+	// The only owner can't leave or step down.
+	assert.Equal(t, m.RemoveMember(id, 1), ErrLastOwner)
+	assert.Equal(t, m.SetRole(id, 1, OrgMember), ErrLastOwner)
+	assert.Equal(t, m.RemoveMember(id, bob), ErrNoRecord)
+
+	// Inviting the same address again replaces the first invitation.
+	first, err := m.Invite(id, 1, "bob@example.com", OrgMember, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := m.Invite(id, 1, "bob@example.com", OrgMember, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.GetInvitation(first)
+	assert.Equal(t, err, ErrInvalidToken)
+
+	invitations, err := m.Invitations(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(invitations), 1)
+	assert.Equal(t, invitations[0].Email, "bob@example.com")
+
+	i, err := m.AcceptInvitation(token, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, i.OrgID, id)
+	_, err = m.AcceptInvitation(token, bob)
+	assert.Equal(t, err, ErrInvalidToken)
+
+	members, err := m.Members(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(members), 2)
+	assert.Equal(t, members[0].UserID, 1)
+	assert.Equal(t, members[1].Role, OrgMember)
+
+	// Once Bob is an owner too, Alice can leave.
+	if err = m.SetRole(id, bob, OrgOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.RemoveMember(id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	orgs, err := m.ForUser(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(orgs), 1)
+	assert.Equal(t, orgs[0].Role, OrgOwner)
+
+	orgs, err = m.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(orgs), 0)
+
+	expired, err := m.Invite(id, bob, "carol@example.com", OrgMember, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.GetInvitation(expired)
+	assert.Equal(t, err, ErrInvalidToken)
+}
+
+func TestSnippetModelForOrg(t *testing.T) {
+	db := testutils.NewTestDB(t)
+
+	orgID, err := (&OrgModel{DB: db}).Insert("Frog Pond", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := SnippetModel{DB: db}
+
+	for _, s := range []*Snippet{
+		{Title: "Shared", Content: "Content", UserID: 1, OrgID: orgID},
+		{Title: "Internal", Content: "Content", UserID: 1, OrgID: orgID, OrgOnly: true},
+	} {
+		if _, err := m.Insert(s, 7); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets, total, err := m.ForOrg(orgID, true, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, total, 2)
+	assert.Equal(t, snippets[0].Title, "Internal")
+	assert.Equal(t, snippets[0].OrgOnly, true)
+	assert.Equal(t, snippets[0].OrgID, orgID)
+
+	snippets, total, err = m.ForOrg(orgID, false, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Title, "Shared")
+
+	// Org only snippets aren't listed publicly.
+	_, total, err = m.Page(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, total, 2)
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Passkey is a WebAuthn credential which a user has registered to sign in
+// with. The model only stores it; checking signatures is up to the caller.
+type Passkey struct {
+	ID              int
+	UserID          int
+	Name            string
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+	Created         time.Time
+	LastUsed        *time.Time
+}
+
+type PasskeyModelInterface interface {
+	Insert(p *Passkey) (int, error)
+	ForUser(userID int) ([]*Passkey, error)
+	GetByCredentialID(credentialID []byte) (*Passkey, error)
+	Used(id int, signCount uint32, backupState bool) error
+	Delete(id, userID int) error
+}
+
+type PasskeyModel struct {
+	DB DBTX
+}
+
+const passkeyColumns = `id, user_id, name, credential_id, public_key, attestation_type, transports,
+    aaguid, sign_count, backup_eligible, backup_state, created, last_used`
+
+// Insert stores a newly registered passkey and returns its ID. It returns
+// ErrDuplicatePasskey if the credential is already registered.
+func (m *PasskeyModel) Insert(p *Passkey) (int, error) {
+	stmt := `INSERT INTO webauthn_credentials (user_id, name, credential_id, public_key, attestation_type,
+    transports, aaguid, sign_count, backup_eligible, backup_state, created)
+    VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, p.UserID, p.Name, p.CredentialID, p.PublicKey, p.AttestationType,
+		strings.Join(p.Transports, ","), p.AAGUID, p.SignCount, p.BackupEligible, p.BackupState)
+	if err != nil {
+		return 0, translateMySQLError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// ForUser returns the user's passkeys, oldest first.
+func (m *PasskeyModel) ForUser(userID int) ([]*Passkey, error) {
+	stmt := `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*Passkey{}
+
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return passkeys, nil
+}
+
+// GetByCredentialID returns the passkey with the given WebAuthn credential
+// ID, or ErrNoRecord if there isn't one.
+func (m *PasskeyModel) GetByCredentialID(credentialID []byte) (*Passkey, error) {
+	stmt := `SELECT ` + passkeyColumns + ` FROM webauthn_credentials WHERE credential_id = ?`
+
+	p, err := scanPasskey(m.DB.QueryRow(stmt, credentialID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Used records a successful sign-in with a passkey, along with the
+// authenticator's new signature counter and backup state.
+func (m *PasskeyModel) Used(id int, signCount uint32, backupState bool) error {
+	stmt := `UPDATE webauthn_credentials SET sign_count = ?, backup_state = ?, last_used = UTC_TIMESTAMP()
+    WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, signCount, backupState, id)
+	return err
+}
+
+// Delete removes one of the user's passkeys. It returns ErrNoRecord if the
+// user has no passkey with that ID.
+func (m *PasskeyModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPasskey(row rowScanner) (*Passkey, error) {
+	p := &Passkey{}
+	var transports string
+	var lastUsed sql.NullTime
+
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.CredentialID, &p.PublicKey, &p.AttestationType, &transports,
+		&p.AAGUID, &p.SignCount, &p.BackupEligible, &p.BackupState, &p.Created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if transports != "" {
+		p.Transports = strings.Split(transports, ",")
+	}
+	if lastUsed.Valid {
+		p.LastUsed = &lastUsed.Time
+	}
+
+	return p, nil
+}
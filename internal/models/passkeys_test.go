@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestPasskeyModel(t *testing.T) {
+	m := PasskeyModel{DB: testutils.NewTestDB(t)}
+
+	p := &Passkey{
+		UserID:          1,
+		Name:            "Laptop",
+		CredentialID:    []byte("credential-1"),
+		PublicKey:       []byte("public-key"),
+		AttestationType: "none",
+		Transports:      []string{"internal", "hybrid"},
+		AAGUID:          make([]byte, 16),
+		SignCount:       1,
+	}
+
+	id, err := m.Insert(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Insert(p)
+	assert.Equal(t, errors.Is(err, ErrDuplicatePasskey), true)
+
+	err = m.Used(id, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.GetByCredentialID([]byte("credential-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, got.ID, id)
+	assert.Equal(t, got.Name, "Laptop")
+	assert.Equal(t, len(got.Transports), 2)
+	assert.Equal(t, got.SignCount, uint32(5))
+	assert.Equal(t, got.BackupState, true)
+	assert.Equal(t, got.LastUsed != nil, true)
+
+	passkeys, err := m.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(passkeys), 1)
+
+	// Passkeys can only be deleted by their owner.
+	err = m.Delete(id, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Delete(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.GetByCredentialID([]byte("credential-1"))
+	assert.Equal(t, err, ErrNoRecord)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Preferences are a user's defaults for creating and viewing snippets.
+type Preferences struct {
+	// Language is the language new snippets start with, or "" to detect
+	// it from the content.
+	Language string
+	// Expires is the number of days new snippets start out expiring in,
+	// or NeverExpires. Zero means the expiry policy's default.
+	Expires int
+	// TabWidth is how many columns wide tabs are shown.
+	TabWidth int
+	// InvitationEmails is whether invitations to organizations are
+	// emailed. It's turned off by the unsubscribe link in them.
+	InvitationEmails bool
+	// LastUsed is what the user chose when they last created a snippet,
+	// which the form starts from rather than Language and Expires, or nil
+	// if they haven't since they last set their preferences.
+	LastUsed *SnippetChoices
+}
+
+// SnippetChoices are the choices on the form for creating a snippet which
+// it remembers from one snippet to the next.
+type SnippetChoices struct {
+	Language         string
+	Expires          int
+	BurnAfterReading bool
+	OrgOnly          bool
+}
+
+// DefaultPreferences are what users get until they set their own, and what
+// anonymous visitors get.
+var DefaultPreferences = Preferences{TabWidth: 8, InvitationEmails: true}
+
+type PreferenceModelInterface interface {
+	Get(userID int) (*Preferences, error)
+	Set(userID int, p *Preferences) error
+}
+
+type PreferenceModel struct {
+	DB DBTX
+}
+
+// Get returns the user's preferences, which are the defaults if they
+// haven't set any.
+func (m *PreferenceModel) Get(userID int) (*Preferences, error) {
+	p := &Preferences{}
+	var lastLanguage sql.NullString
+	var lastExpires sql.NullInt64
+	var last SnippetChoices
+
+	stmt := `SELECT language, expires, tab_width, invitation_emails,
+    last_language, last_expires, last_burn_after_reading, last_org_only
+    FROM user_preferences WHERE user_id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&p.Language, &p.Expires, &p.TabWidth, &p.InvitationEmails,
+		&lastLanguage, &lastExpires, &last.BurnAfterReading, &last.OrgOnly)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			defaults := DefaultPreferences
+			return &defaults, nil
+		}
+		return nil, err
+	}
+
+	if lastExpires.Valid {
+		last.Language = lastLanguage.String
+		last.Expires = int(lastExpires.Int64)
+		p.LastUsed = &last
+	}
+
+	return p, nil
+}
+
+// Set replaces the user's preferences, including what they last used.
+func (m *PreferenceModel) Set(userID int, p *Preferences) error {
+	stmt := `INSERT INTO user_preferences (user_id, language, expires, tab_width, invitation_emails,
+    last_language, last_expires, last_burn_after_reading, last_org_only, updated)
+    VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE language = VALUES(language), expires = VALUES(expires), tab_width = VALUES(tab_width),
+    invitation_emails = VALUES(invitation_emails), last_language = VALUES(last_language),
+    last_expires = VALUES(last_expires), last_burn_after_reading = VALUES(last_burn_after_reading),
+    last_org_only = VALUES(last_org_only), updated = VALUES(updated)`
+
+	var lastLanguage, lastExpires any
+	var last SnippetChoices
+	if p.LastUsed != nil {
+		last = *p.LastUsed
+		lastLanguage, lastExpires = last.Language, last.Expires
+	}
+
+	_, err := m.DB.Exec(stmt, userID, p.Language, p.Expires, p.TabWidth, p.InvitationEmails,
+		lastLanguage, lastExpires, last.BurnAfterReading, last.OrgOnly)
+	return err
+}
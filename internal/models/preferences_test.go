@@ -0,0 +1,51 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestPreferenceModel(t *testing.T) {
+	m := PreferenceModel{DB: testutils.NewTestDB(t)}
+
+	p, err := m.Get(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *p, DefaultPreferences)
+
+	want := Preferences{Language: "go", Expires: 7, TabWidth: 4}
+	err = m.Set(1, &want)
+	assert.Equal(t, err, nil)
+
+	p, err = m.Get(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *p, want)
+
+	want.TabWidth = 2
+	want.InvitationEmails = true
+	err = m.Set(1, &want)
+	assert.Equal(t, err, nil)
+
+	p, err = m.Get(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *p, want)
+
+	// What the user last used is kept until their preferences are set
+	// without it.
+	last := SnippetChoices{Language: "python", Expires: 30, BurnAfterReading: true}
+	want.LastUsed = &last
+	err = m.Set(1, &want)
+	assert.Equal(t, err, nil)
+
+	p, err = m.Get(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *p.LastUsed, last)
+
+	want.LastUsed = nil
+	err = m.Set(1, &want)
+	assert.Equal(t, err, nil)
+
+	p, err = m.Get(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p.LastUsed, nil)
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+)
+
+// SessionStats counts the rows in the sessions table. Expired sessions can
+// no longer be used, but stay in the table until they are deleted.
+type SessionStats struct {
+	Active       int
+	ExpiringSoon int
+	Expired      int
+}
+
+// SessionData is an active session, with its data as the session manager's
+// codec encoded it.
+type SessionData struct {
+	Token string
+	Data  []byte
+}
+
+type SessionModelInterface interface {
+	Stats(soon time.Duration) (*SessionStats, error)
+	Active() ([]SessionData, error)
+	Delete(token string) error
+	DeleteExpired() (int, error)
+}
+
+// SessionModel looks after the sessions table which the session manager
+// stores sessions in.
+type SessionModel struct {
+	DB DBTX
+}
+
+// Stats counts the active sessions, how many of those expire within soon,
+// and the expired sessions still waiting to be deleted.
+func (m *SessionModel) Stats(soon time.Duration) (*SessionStats, error) {
+	stmt := `SELECT
+        COALESCE(SUM(expiry >= UTC_TIMESTAMP(6)), 0),
+        COALESCE(SUM(expiry >= UTC_TIMESTAMP(6) AND expiry < DATE_ADD(UTC_TIMESTAMP(6), INTERVAL ? SECOND)), 0),
+        COALESCE(SUM(expiry < UTC_TIMESTAMP(6)), 0)
+    FROM sessions`
+
+	s := &SessionStats{}
+
+	err := m.DB.QueryRow(stmt, int(soon.Seconds())).Scan(&s.Active, &s.ExpiringSoon, &s.Expired)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Active returns every session which hasn't expired.
+func (m *SessionModel) Active() ([]SessionData, error) {
+	rows, err := m.DB.Query(`SELECT token, data FROM sessions WHERE expiry >= UTC_TIMESTAMP(6)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionData
+	for rows.Next() {
+		var s SessionData
+		if err := rows.Scan(&s.Token, &s.Data); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete removes a session, which signs out whoever it belongs to.
+func (m *SessionModel) Delete(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+// DeleteExpired removes expired sessions, and returns how many were removed.
+func (m *SessionModel) DeleteExpired() (int, error) {
+	result, err := m.DB.Exec(`DELETE FROM sessions WHERE expiry < UTC_TIMESTAMP(6)`)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestSessionModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := SessionModel{DB: db}
+
+	now := time.Now().UTC()
+	for token, expiry := range map[string]time.Time{
+		"active":   now.Add(time.Hour),
+		"expiring": now.Add(time.Minute),
+		"expired":  now.Add(-time.Minute),
+	} {
+		_, err := db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES (?, '', ?)`, token, expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := m.Stats(5 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *stats, SessionStats{Active: 2, ExpiringSoon: 1, Expired: 1})
+
+	active, err := m.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(active), 2)
+
+	err = m.Delete("expiring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err = m.Stats(5 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *stats, SessionStats{Active: 1, ExpiringSoon: 0, Expired: 1})
+
+	n, err := m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	stats, err = m.Stats(5 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stats.Expired, 0)
+}
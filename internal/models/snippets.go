@@ -3,63 +3,605 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/ngohoang211020/snippetbox/internal/languages"
+	"github.com/ngohoang211020/snippetbox/internal/metadata"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"github.com/ngohoang211020/snippetbox/internal/query"
+	"golang.org/x/sync/singleflight"
+	"strconv"
 	"time"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(s *Snippet, expires int) (int, error)
 	Get(id int) (*Snippet, error)
 	Latest() ([]*Snippet, error)
+	SetLanguage(snippetID, position int, language string, version int) error
+	Update(s *Snippet, version int) error
+	PublishDue() ([]*Snippet, error)
+	Page(limit, offset int) ([]*Snippet, int, error)
+	LatestFromAuthors(userIDs []int, limit, offset int) ([]*Snippet, int, error)
+	LatestAfter(after SnippetCursor, limit int, userIDs ...int) ([]*Snippet, error)
+	CountByLanguage() ([]*LanguageCount, error)
+	ByLanguage(language, order string, limit, offset int) ([]*Snippet, int, error)
+	GetAndConsume(id, viewerID int) (*Snippet, error)
+	ForOrg(orgID int, includeOrgOnly bool, limit, offset int) ([]*Snippet, int, error)
+	FillMetadata(limit int) (int, error)
+	IDForPublicID(publicID string) (int, error)
+	AssignPublicIDs(limit int) (int, error)
+	FilesFor(snippetIDs []int) (map[int][]*SnippetFile, error)
+	CountAnonymousBefore(t time.Time) (int, error)
+	DeleteAnonymousBefore(t time.Time) (int, error)
+	ForUserAfter(userID, afterID, limit int) ([]*Snippet, error)
+	Stats() (*InstanceStats, error)
 }
 
+// LanguageCount is the number of published snippets in a language.
+type LanguageCount struct {
+	Language string
+	Count    int
+}
+
+// The orders which lists of snippets can be sorted in. OrderNewest is the
+// default.
+const (
+	OrderNewest = "newest"
+	OrderOldest = "oldest"
+	OrderTitle  = "title"
+)
+
+var snippetOrders = map[string]string{
+	OrderNewest: "publish_at DESC, id DESC",
+	OrderOldest: "publish_at ASC, id ASC",
+	OrderTitle:  "title ASC, id ASC",
+}
+
+// effectiveLanguage is the SQL for a snippet's language: the one its owner
+// chose, or the detected one if they didn't.
+const effectiveLanguage = "COALESCE(NULLIF(language, ''), detected_language)"
+
+// snippetColumns are the columns of a snippet, in the order scanSnippet
+// expects them.
+var snippetColumns = []string{"id", "title", "content", "filename", "language", "detected_language", "user_id", "created", "expires", "publish_at", "version", "burn_after_reading", "burned", "content_encrypted", "content_zlib", "org_id", "org_only", "line_count", "byte_size", "read_seconds", "language_confidence", "metadata_version", "public_id"}
+
+// scanSnippet copies a row of snippetColumns into a new Snippet.
+func scanSnippet(row interface{ Scan(dest ...any) error }) (*Snippet, error) {
+	s := &Snippet{}
+
+	// Use Scan() to copy the values from each field in the row to the
+	// corresponding field in the Snippet struct. Notice that the arguments
+	// to Scan are *pointers* to the place you want to copy the data into,
+	// and the number of arguments must be exactly the same as the number of
+	// columns returned by your statement.
+	var userID, orgID sql.NullInt64
+	var burned sql.NullTime
+	var compressed []byte
+	var meta metadata.Metadata
+	var metaVersion int
+	var publicID sql.NullString
+
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Filename, &s.Language, &s.DetectedLanguage, &userID, &s.Created, &s.Expires, &s.PublishAt, &s.Version, &s.BurnAfterReading, &burned, &s.ContentEncrypted, &compressed, &orgID, &s.OrgOnly,
+		&meta.Lines, &meta.Bytes, &meta.ReadSeconds, &meta.LanguageConfidence, &metaVersion, &publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Snippets saved before there was any metadata, and encrypted ones,
+	// which we can't look inside, don't have any.
+	if metaVersion > 0 && !s.ContentEncrypted {
+		s.Metadata = &meta
+	}
+
+	s.PublicID = publicID.String
+	s.UserID = int(userID.Int64)
+	s.OrgID = int(orgID.Int64)
+	s.BurnedAt = burned.Time
+	s.Content, err = decompressContent(s.Content, compressed)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// scanSnippets collects the rows of snippetColumns into a slice, closing
+// rows when it's done.
+func scanSnippets(rows *sql.Rows) ([]*Snippet, error) {
+	//Closing a resultset with defer rows.Close() is critical.As long as a resultset is open it will keep the underlying database connection open…
+	//so if something goes wrong in this method and the resultset isn’t closed,
+	//it can rapidly lead to all the connections in your pool being used up.
+	defer rows.Close()
+
+	// Initialize an empty slice to hold the Snippet structs.
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	// When the rows.Next() loop has finished we call rows.Err() to retrieve any error that was encountered during the iteration
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// publishedSnippets starts a query for the snippets which have been
+// published and haven't expired yet. Burn after reading snippets are left
+// out, since listing them would show them to everyone, and so are encrypted
+// ones, which are only for people who have been given the key, and org only
+// ones, which are only for the organization's members.
+func publishedSnippets(d *query.Dialect) *query.SelectBuilder {
+	return query.Select(snippetColumns...).
+		From("snippets").
+		Where("expires > " + d.Now).
+		Where("publish_at <= " + d.Now).
+		Where("burn_after_reading = FALSE").
+		Where("content_encrypted = FALSE").
+		Where("org_only = FALSE")
+}
+
+// Snippet is a titled snippet of text. Content, Filename and Language describe
+// its first (and usually only) file; any further files are held in Files.
+// Language is only what the user chose, and DetectedLanguage is our guess
+// from the content when they didn't choose one.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	// ID is the database's own ID, which is never shown to anyone.
+	// PublicID is the one the snippet is known by in URLs and the API. It's
+	// empty for snippets saved before there were public IDs, until
+	// AssignPublicIDs gets to them.
+	ID               int            `json:"-"`
+	PublicID         string         `json:"id"`
+	Title            string         `json:"title"`
+	Content          string         `json:"content"`
+	Filename         string         `json:"filename"`
+	Language         string         `json:"language"`
+	DetectedLanguage string         `json:"detected_language,omitempty"`
+	Files            []*SnippetFile `json:"files,omitempty"`
+	UserID           int            `json:"-"`
+	Created          time.Time      `json:"created"`
+	Expires          time.Time      `json:"expires"`
+	// PublishAt is when the snippet becomes visible to everyone other than
+	// its owner. For snippets which weren't scheduled it's the same as
+	// Created.
+	PublishAt time.Time `json:"publish_at"`
+	// Version starts at 1 and goes up every time the snippet or one of its
+	// files is changed.
+	Version int `json:"-"`
+	// BurnAfterReading snippets are destroyed by the first person other
+	// than their owner to view them. BurnedAt is when that happened; the
+	// snippet is kept as a tombstone, without its content.
+	BurnAfterReading bool      `json:"burn_after_reading"`
+	BurnedAt         time.Time `json:"-"`
+	// ContentEncrypted snippets were encrypted in the browser, and Content
+	// is the ciphertext. Only the title and other metadata are readable.
+	ContentEncrypted bool `json:"content_encrypted"`
+	// OrgID is the organization the snippet was published for, if any.
+	// OrgOnly snippets are only visible to the organization's members.
+	OrgID   int  `json:"-"`
+	OrgOnly bool `json:"org_only,omitempty"`
+	// Metadata is worked out from the files when the snippet is saved. It's
+	// nil for encrypted snippets, and for old ones until it's been filled
+	// in.
+	Metadata *metadata.Metadata `json:"metadata,omitempty"`
+}
+
+// SnippetFile is one of the named files in a multi-file snippet. Position 0 is
+// the snippet's own content, and additional files are numbered from 1.
+type SnippetFile struct {
+	Position         int    `json:"position"`
+	Filename         string `json:"filename"`
+	Language         string `json:"language"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	Content          string `json:"content"`
+}
+
+// MaxSnippetFiles is the maximum number of files in a snippet, including the
+// first.
+const MaxSnippetFiles = 10
+
+// AllFiles returns every file in the snippet, starting with the snippet's own
+// content at position 0.
+func (s *Snippet) AllFiles() []*SnippetFile {
+	files := []*SnippetFile{{
+		Position:         0,
+		Filename:         s.Filename,
+		Language:         s.Language,
+		DetectedLanguage: s.DetectedLanguage,
+		Content:          s.Content,
+	}}
+	return append(files, s.Files...)
+}
+
+// File returns the file at the given position, or false if there isn't one.
+func (s *Snippet) File(position int) (*SnippetFile, bool) {
+	for _, f := range s.AllFiles() {
+		if f.Position == position {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// IsScheduled reports whether the snippet is scheduled to be published in the
+// future.
+func (s *Snippet) IsScheduled() bool {
+	return s.PublishAt.After(time.Now())
+}
+
+// NeverExpires reports whether the snippet was created to never expire.
+func (s *Snippet) NeverExpires() bool {
+	return !s.Expires.Before(neverExpiresAt)
+}
+
+// IsBurned reports whether the snippet was burn after reading and has been
+// read.
+func (s *Snippet) IsBurned() bool {
+	return !s.BurnedAt.IsZero()
+}
+
+// VisibleTo reports whether the user with the given ID (or 0 for anonymous
+// users) can see the snippet. Scheduled snippets are only visible to their
+// owner until they are published. Burn after reading snippets are too:
+// everyone else only gets to see them once, through GetAndConsume. Burned
+// snippets aren't visible to anyone.
+func (s *Snippet) VisibleTo(userID int) bool {
+	if s.IsBurned() {
+		return false
+	}
+	isOwner := userID != 0 && s.UserID == userID
+	return isOwner || (!s.IsScheduled() && !s.BurnAfterReading)
+}
+
+// DisplayName returns the file's name, or a name made up from its position
+// and language if it wasn't given one.
+func (f *SnippetFile) DisplayName() string {
+	if f.Filename != "" {
+		return f.Filename
+	}
+	return fmt.Sprintf("file%d.%s", f.Position+1, languages.Extension(f.EffectiveLanguage()))
 }
+
+// EffectiveLanguage returns the language the file should be highlighted as:
+// the one its owner chose, or failing that the detected one.
+func (f *SnippetFile) EffectiveLanguage() string {
+	if f.Language != "" {
+		return f.Language
+	}
+	return f.DetectedLanguage
+}
+
+// IsDetected reports whether the file's language was detected rather than
+// chosen by its owner.
+func (f *SnippetFile) IsDetected() bool {
+	return f.Language == "" && f.DetectedLanguage != ""
+}
+
+// computeMetadata runs the snippet's files through the metadata pipeline.
+// It returns nil for encrypted snippets, whose content is only ciphertext.
+func (s *Snippet) computeMetadata() *metadata.Metadata {
+	if s.ContentEncrypted {
+		return nil
+	}
+
+	var files []metadata.File
+	for _, f := range s.AllFiles() {
+		files = append(files, metadata.File{Filename: f.Filename, Language: f.Language, Content: f.Content})
+	}
+	return metadata.Default.Run(files)
+}
+
+// clone returns a copy of the snippet and its files, so that callers which
+// share a snippet can't see each other's changes to it.
+func (s *Snippet) clone() *Snippet {
+	c := *s
+	if s.Metadata != nil {
+		meta := *s.Metadata
+		c.Metadata = &meta
+	}
+	if s.Files != nil {
+		c.Files = make([]*SnippetFile, len(s.Files))
+		for i, f := range s.Files {
+			file := *f
+			c.Files[i] = &file
+		}
+	}
+	return &c
+}
+
 type SnippetModel struct {
-	DB *sql.DB
-}
-
-// Insert This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	// Write the SQL statement we want to execute. I've split it over two lines
-	// for readability (which is why it's surrounded with backquotes instead
-	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
-	// method returns a sql.Result type, which contains some basic
-	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	DB DBTX
+
+	// CompressAbove is the size in bytes above which contents are stored
+	// compressed. Zero means DefaultCompressAbove, and a negative size
+	// turns compression off. Contents are read the same either way.
+	CompressAbove int
+
+	// IDs is the kind of public ID given to new snippets. The zero value
+	// gives publicid.Default ones.
+	IDs publicid.Strategy
+
+	// reads coalesces concurrent Gets of the same snippet.
+	reads singleflight.Group
+}
+
+// Insert This will insert a new snippet, along with any additional files in
+// s.Files, into the database. The snippet and its files are written in a
+// single transaction, so a snippet is never left with only some of its files.
+// expires is the number of days the snippet lasts, or NeverExpires, and must
+// be permitted by the expiry policy; ErrExpiryNotPermitted is returned if
+// not. If s.PublishAt is set the snippet is scheduled for publishing then, and
+// otherwise it is published straight away. A zero s.UserID means the snippet
+// has no owner. s.PublicID is set to the snippet's new public ID.
+func (m *SnippetModel) Insert(s *Snippet, expires int) (int, error) {
+	var userID, orgID, publishAt any
+	if s.UserID != 0 {
+		userID = s.UserID
+	}
+	if s.OrgID != 0 {
+		orgID = s.OrgID
+	}
+	if !s.PublishAt.IsZero() {
+		publishAt = s.PublishAt.UTC()
+	}
+
+	content, compressed, err := compressContent(s.Content, m.compressAbove())
+	if err != nil {
+		return 0, err
+	}
+
+	meta := s.computeMetadata()
+	if meta == nil {
+		meta = &metadata.Metadata{}
+	}
+
+	d := dialect(m.DB)
+
+	expiresExpr, expiresArg := d.DaysFromNow("?"), any(expires)
+	if expires == NeverExpires {
+		expiresExpr, expiresArg = "?", neverExpiresAt
+	}
+
+	publicID := m.IDs.New()
+
+	stmt, args := query.Insert("snippets").
+		Set("public_id", publicID).
+		Set("title", s.Title).
+		Set("content", content).
+		Set("content_zlib", compressed).
+		Set("filename", s.Filename).
+		Set("language", s.Language).
+		Set("detected_language", s.DetectedLanguage).
+		Set("user_id", userID).
+		SetExpr("created", d.Now).
+		SetExpr("expires", expiresExpr, expiresArg).
+		SetExpr("publish_at", "COALESCE(?, "+d.Now+")", publishAt).
+		Set("published", publishAt == nil).
+		Set("burn_after_reading", s.BurnAfterReading).
+		Set("content_encrypted", s.ContentEncrypted).
+		Set("org_id", orgID).
+		Set("org_only", s.OrgID != 0 && s.OrgOnly).
+		Set("line_count", meta.Lines).
+		Set("byte_size", meta.Bytes).
+		Set("read_seconds", meta.ReadSeconds).
+		Set("language_confidence", meta.LanguageConfidence).
+		Set("metadata_version", metadata.Version).
+		Build()
+
+	var id int
+
+	err = transact(m.DB, func(tx DBTX) error {
+		// The policy is checked here as well as by whatever took the expiry,
+		// so that nothing can create a snippet it doesn't allow.
+		policy, err := (&ExpiryPolicyModel{DB: tx}).Get()
+		if err != nil {
+			return err
+		}
+		if !policy.Permits(expires) {
+			return ErrExpiryNotPermitted
+		}
+
+		// This method returns a sql.Result type, which contains some basic
+		// information about what happened when the statement was executed.
+		result, err := tx.Exec(stmt, args...)
+		if err != nil {
+			return err
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		// The ID returned has the type int64, so we convert it to an int type
+		id = int(lastID)
+
+		for i, f := range s.Files {
+			content, compressed, err := compressContent(f.Content, m.compressAbove())
+			if err != nil {
+				return err
+			}
+
+			fileStmt, fileArgs := query.Insert("snippet_files").
+				Set("snippet_id", id).
+				Set("position", i+1).
+				Set("filename", f.Filename).
+				Set("language", f.Language).
+				Set("detected_language", f.DetectedLanguage).
+				Set("content", content).
+				Set("content_zlib", compressed).
+				Build()
+
+			_, err = tx.Exec(fileStmt, fileArgs...)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, translateMySQLError(err)
+	}
+
+	s.PublicID = publicID
+	return id, nil
+}
+
+// IDForPublicID returns the ID of the snippet with the given public ID, or
+// ErrNoRecord if there isn't one. Whether the snippet can be shown is left
+// to Get and the rest.
+func (m *SnippetModel) IDForPublicID(publicID string) (int, error) {
+	stmt, args := query.Select("id").
+		From("snippets").
+		Where("public_id = ?", publicID).
+		Build()
+
+	var id int
+	err := m.DB.QueryRow(stmt, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+	return id, err
+}
+
+// AssignPublicIDs gives up to limit of the snippets saved before there were
+// public IDs one, and returns how many it gave.
+func (m *SnippetModel) AssignPublicIDs(limit int) (int, error) {
+	stmt, args := query.Select("id").
+		From("snippets").
+		Where("public_id IS NULL").
+		OrderBy("id").
+		Page(limit, 0).
+		Build()
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return 0, err
 	}
-	id, err := result.LastInsertId()
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		update, updateArgs := query.Update("snippets").
+			Set("public_id", m.IDs.New()).
+			Where("id = ?", id).
+			Where("public_id IS NULL").
+			Build()
+
+		if _, err = m.DB.Exec(update, updateArgs...); err != nil {
+			return i, err
+		}
+		m.reads.Forget(strconv.Itoa(id))
+	}
+
+	return len(ids), nil
+}
+
+// FillMetadata works out the metadata of up to limit snippets which were
+// saved before there was any, or by an older version of the pipeline, and
+// returns how many it filled in. Encrypted snippets are skipped, as are
+// burned ones, which have no content left.
+func (m *SnippetModel) FillMetadata(limit int) (int, error) {
+	stmt, args := query.Select("id").
+		From("snippets").
+		Where("metadata_version < ?", metadata.Version).
+		Where("content_encrypted = FALSE").
+		Where("burned IS NULL").
+		Where("expires > "+dialect(m.DB).Now).
+		OrderBy("id").
+		Page(limit, 0).
+		Build()
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return 0, err
 	}
-	// The ID returned has the type int64, so we convert it to an int type
-	return int(id), nil
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	// The connection is needed for the snippets themselves.
+	rows.Close()
+
+	n := 0
+	for _, id := range ids {
+		s, err := m.get(id)
+		if errors.Is(err, ErrNoRecord) {
+			// It expired in the meantime.
+			continue
+		} else if err != nil {
+			return n, err
+		}
+
+		meta := s.computeMetadata()
+		update, updateArgs := query.Update("snippets").
+			Set("line_count", meta.Lines).
+			Set("byte_size", meta.Bytes).
+			Set("read_seconds", meta.ReadSeconds).
+			Set("language_confidence", meta.LanguageConfidence).
+			Set("metadata_version", metadata.Version).
+			Where("id = ?", id).
+			Build()
+
+		if _, err = m.DB.Exec(update, updateArgs...); err != nil {
+			return n, err
+		}
+		m.reads.Forget(strconv.Itoa(id))
+		n++
+	}
+
+	return n, nil
 }
 
-// Get This will return a specific snippet based on its id.
+// Get This will return a specific snippet based on its id. Scheduled snippets
+// are returned too, so that their owners can see them; use VisibleTo to check
+// whether the snippet should be shown to someone.
+//
+// A popular snippet can be requested many times at once, so concurrent calls
+// for the same ID share a single set of queries. Each caller still gets its
+// own copy of the result.
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	// Initialize a pointer to a new zeroed Snippet struct.
-	s := &Snippet{}
+	v, err, _ := m.reads.Do(strconv.Itoa(id), func() (any, error) {
+		return m.get(id)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Use row.Scan() to copy the values from each field in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that the arguments
-	// to row.Scan are *pointers* to the place you want to copy the data into,
-	// and the number of arguments must be exactly the same as the number of
-	// columns returned by your statement.
-	err := m.DB.QueryRow("SELECT id, title, content, created, expires FROM snippets"+
-		" WHERE expires > UTC_TIMESTAMP() AND id = ?", id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	return v.(*Snippet).clone(), nil
+}
+
+func (m *SnippetModel) get(id int) (*Snippet, error) {
+	stmt, args := query.Select(snippetColumns...).
+		From("snippets").
+		Where("expires > "+dialect(m.DB).Now).
+		Where("id = ?", id).
+		Build()
 
+	s, err := scanSnippet(m.DB.QueryRow(stmt, args...))
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -70,46 +612,550 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	s.Files, err = snippetFiles(m.DB, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
-// Latest This will return the 10 most recently created snippets.
+// GetAndConsume returns a burn after reading snippet for someone other than
+// its owner, and burns it: the content and files are deleted, leaving only a
+// tombstone. The snippet is locked while this happens, so only one viewer
+// ever gets the content. Everyone else gets ErrNoRecord, as does anyone
+// asking for a snippet which isn't burn after reading, or isn't published
+// yet, or for their own snippet.
+func (m *SnippetModel) GetAndConsume(id, viewerID int) (*Snippet, error) {
+	// A Get which is already in flight may have read the content, so later
+	// ones mustn't wait for it.
+	defer m.reads.Forget(strconv.Itoa(id))
+
+	d := dialect(m.DB)
+
+	stmt, args := query.Select(snippetColumns...).
+		From("snippets").
+		Where("expires > "+d.Now).
+		Where("publish_at <= "+d.Now).
+		Where("burn_after_reading = TRUE").
+		Where("burned IS NULL").
+		Where("(user_id IS NULL OR user_id <> ?)", viewerID).
+		Where("id = ?", id).
+		ForUpdate().
+		Build()
+
+	burn, burnArgs := query.Update("snippets").
+		Set("content", "").
+		Set("content_zlib", nil).
+		SetExpr("burned", d.Now).
+		SetExpr("version", "version + 1").
+		Where("id = ?", id).
+		Build()
+
+	var s *Snippet
+
+	err := transact(m.DB, func(tx DBTX) error {
+		var err error
+
+		s, err = scanSnippet(tx.QueryRow(stmt, args...))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+
+		s.Files, err = snippetFiles(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(burn, burnArgs...); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM snippet_files WHERE snippet_id = ?", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// snippetFiles returns the additional files of a snippet, in order.
+func snippetFiles(db DBTX, snippetID int) ([]*SnippetFile, error) {
+	files, err := filesFor(db, []int{snippetID})
+	return files[snippetID], err
+}
+
+// FilesFor returns the additional files of each of the given snippets, in
+// one query, by snippet ID. The lists of snippets returned by Page and the
+// like don't include their files, so this is how to get them for a whole
+// list at once. Snippets without additional files are left out.
+func (m *SnippetModel) FilesFor(snippetIDs []int) (map[int][]*SnippetFile, error) {
+	return filesFor(m.DB, snippetIDs)
+}
+
+func filesFor(db DBTX, snippetIDs []int) (map[int][]*SnippetFile, error) {
+	ids := make([]any, len(snippetIDs))
+	for i, id := range snippetIDs {
+		ids[i] = id
+	}
+
+	stmt, args := query.Select("snippet_id", "position", "filename", "language", "detected_language", "content", "content_zlib").
+		From("snippet_files").
+		WhereIn("snippet_id", ids).
+		OrderBy("snippet_id, position").
+		Build()
+
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := map[int][]*SnippetFile{}
+
+	for rows.Next() {
+		f := &SnippetFile{}
+		var snippetID int
+		var compressed []byte
+		err = rows.Scan(&snippetID, &f.Position, &f.Filename, &f.Language, &f.DetectedLanguage, &f.Content, &compressed)
+		if err != nil {
+			return nil, err
+		}
+		f.Content, err = decompressContent(f.Content, compressed)
+		if err != nil {
+			return nil, err
+		}
+		files[snippetID] = append(files[snippetID], f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// SetLanguage sets the language chosen for the file at the given position of
+// a snippet, replacing whatever the owner chose before. An empty language
+// goes back to using the detected one. If version isn't zero the change is
+// only made if the snippet is still at that version, and ErrEditConflict is
+// returned if it isn't.
+func (m *SnippetModel) SetLanguage(snippetID, position int, language string, version int) error {
+	// A Get which is already in flight may have read the old language, so
+	// later ones mustn't wait for it.
+	defer m.reads.Forget(strconv.Itoa(snippetID))
+
+	bump, bumpArgs := query.Update("snippets").
+		SetExpr("version", "version + 1").
+		Where("id = ?", snippetID).
+		Where("(? = 0 OR version = ?)", version, version).
+		Build()
+
+	var set *query.UpdateBuilder
+	if position == 0 {
+		set = query.Update("snippets").Set("language", language).Where("id = ?", snippetID)
+	} else {
+		set = query.Update("snippet_files").Set("language", language).Where("snippet_id = ?", snippetID).Where("position = ?", position)
+	}
+	setStmt, setArgs := set.Build()
+
+	return transact(m.DB, func(tx DBTX) error {
+		result, err := tx.Exec(bump, bumpArgs...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrEditConflict
+		}
+
+		_, err = tx.Exec(setStmt, setArgs...)
+		return err
+	})
+}
+
+// Update saves changes to a snippet's title and to the content of its first
+// file, along with the language detected for that content, and works out the
+// snippet's metadata again. Its other files are left alone. If version isn't
+// zero the change is only made if the snippet is still at that version, and
+// ErrEditConflict is returned if it isn't.
+func (m *SnippetModel) Update(s *Snippet, version int) error {
+	defer m.reads.Forget(strconv.Itoa(s.ID))
+
+	content, compressed, err := compressContent(s.Content, m.compressAbove())
+	if err != nil {
+		return err
+	}
+
+	meta := s.computeMetadata()
+	if meta == nil {
+		meta = &metadata.Metadata{}
+	}
+
+	stmt, args := query.Update("snippets").
+		Set("title", s.Title).
+		Set("content", content).
+		Set("content_zlib", compressed).
+		Set("detected_language", s.DetectedLanguage).
+		Set("line_count", meta.Lines).
+		Set("byte_size", meta.Bytes).
+		Set("read_seconds", meta.ReadSeconds).
+		Set("language_confidence", meta.LanguageConfidence).
+		Set("metadata_version", metadata.Version).
+		SetExpr("version", "version + 1").
+		Where("id = ?", s.ID).
+		Where("(? = 0 OR version = ?)", version, version).
+		Build()
+
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return translateMySQLError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// Latest This will return the 10 most recently published snippets.
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt, args := publishedSnippets(dialect(m.DB)).
+		OrderBy(snippetOrders[OrderNewest]).
+		Page(10, 0).
+		Build()
 
 	// Use the Query() method on the connection pool to execute our
 	// SQL statement. This returns a sql.Rows resultset containing the result of
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// Page returns a page of the published snippets, newest first, along with
+// the total number of published snippets. limit and offset select the page.
+func (m *SnippetModel) Page(limit, offset int) ([]*Snippet, int, error) {
+	return m.page(publishedSnippets(dialect(m.DB)), OrderNewest, limit, offset)
+}
+
+// LatestFromAuthors returns a page of the published snippets owned by any of
+// the given users, newest first, along with the total number of them.
+func (m *SnippetModel) LatestFromAuthors(userIDs []int, limit, offset int) ([]*Snippet, int, error) {
+	if len(userIDs) == 0 {
+		return []*Snippet{}, 0, nil
+	}
+
+	ids := make([]any, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = id
+	}
+
+	return m.page(publishedSnippets(dialect(m.DB)).WhereIn("user_id", ids), OrderNewest, limit, offset)
+}
+
+// SnippetCursor marks a place in the list of published snippets, newest
+// first, just after the snippet with the given publish time and public ID.
+// Cursors are handed to API clients, so they use the public ID rather than
+// the database's own. The zero SnippetCursor is the start of the list.
+type SnippetCursor struct {
+	PublishAt time.Time
+	PublicID  string
+}
+
+// Cursor returns the cursor just after s.
+func (s *Snippet) Cursor() SnippetCursor {
+	return SnippetCursor{PublishAt: s.PublishAt, PublicID: s.PublicID}
+}
+
+// LatestAfter returns up to limit of the published snippets which come after
+// the cursor, newest first. Unlike with Page, snippets published since the
+// list was started come before the cursor, so they don't push ones already
+// seen onto the next page. If any userIDs are given, only the snippets owned
+// by those users are included.
+func (m *SnippetModel) LatestAfter(after SnippetCursor, limit int, userIDs ...int) ([]*Snippet, error) {
+	q := publishedSnippets(dialect(m.DB))
+
+	if len(userIDs) > 0 {
+		ids := make([]any, len(userIDs))
+		for i, id := range userIDs {
+			ids[i] = id
+		}
+		q = q.WhereIn("user_id", ids)
+	}
+
+	// Snippets published at the same time are ordered by ID, so the
+	// cursor's public ID is looked up to find where it comes among them. If
+	// that snippet has since been deleted, only the ones published before it
+	// are left.
+	if after.PublicID != "" {
+		q = q.Where("(publish_at < ? OR (publish_at = ? AND id < (SELECT id FROM snippets WHERE public_id = ?)))", after.PublishAt, after.PublishAt, after.PublicID)
+	}
+
+	stmt, args := q.OrderBy(snippetOrders[OrderNewest]).Page(limit, 0).Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// ForUserAfter returns up to limit of the user's own snippets with IDs after
+// afterID, in order of ID, for going through all of them a few at a time.
+// Scheduled, org only and unread burn after reading snippets are included,
+// as their owner can see them. Expired and burned ones are left out, and so
+// are encrypted ones, whose content is only ciphertext.
+func (m *SnippetModel) ForUserAfter(userID, afterID, limit int) ([]*Snippet, error) {
+	stmt, args := query.Select(snippetColumns...).
+		From("snippets").
+		Where("user_id = ?", userID).
+		Where("id > ?", afterID).
+		Where("expires > "+dialect(m.DB).Now).
+		Where("burned IS NULL").
+		Where("content_encrypted = FALSE").
+		OrderBy("id").
+		Page(limit, 0).
+		Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// CountByLanguage returns the languages of the published snippets with how
+// many snippets use each one, most used first. A snippet's language is that
+// of its first file, and snippets whose language is unknown are left out.
+func (m *SnippetModel) CountByLanguage() ([]*LanguageCount, error) {
+	d := dialect(m.DB)
+
+	stmt, args := query.Select(effectiveLanguage+" AS lang", "COUNT(*) AS n").
+		From("snippets").
+		Where("expires > " + d.Now).
+		Where("publish_at <= " + d.Now).
+		Where("burn_after_reading = FALSE").
+		Where("content_encrypted = FALSE").
+		Where("org_only = FALSE").
+		GroupBy("lang").
+		Having("lang <> ''").
+		OrderBy("n DESC, lang ASC").
+		Build()
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
-	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns.
-	//Closing a resultset with defer rows.Close() is critical.As long as a resultset is open it will keep the underlying database connection open…
-	//so if something goes wrong in this method and the resultset isn’t closed,
-	//it can rapidly lead to all the connections in your pool being used up.
 	defer rows.Close()
 
-	// Initialize an empty slice to hold the Snippet structs.
-	snippets := []*Snippet{}
+	counts := []*LanguageCount{}
+
+	for rows.Next() {
+		c := &LanguageCount{}
+		if err = rows.Scan(&c.Language, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// InstanceStats are the figures about the whole site shown on the about
+// page: how many snippets have been published, by how many users there are,
+// and in which languages.
+type InstanceStats struct {
+	PublicSnippets int
+	Users          int
+	Languages      []*LanguageCount
+}
+
+// Stats counts the published snippets and the users, and the published
+// snippets in each language as CountByLanguage does. It reads every snippet,
+// so its result is meant to be kept for a while rather than asked for on
+// every request.
+func (m *SnippetModel) Stats() (*InstanceStats, error) {
+	d := dialect(m.DB)
+
+	stmt, args := query.Select("COUNT(*)", "(SELECT COUNT(*) FROM users)").
+		From("snippets").
+		Where("expires > " + d.Now).
+		Where("publish_at <= " + d.Now).
+		Where("burn_after_reading = FALSE").
+		Where("content_encrypted = FALSE").
+		Where("org_only = FALSE").
+		Build()
+
+	stats := &InstanceStats{}
+	if err := m.DB.QueryRow(stmt, args...).Scan(&stats.PublicSnippets, &stats.Users); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if stats.Languages, err = m.CountByLanguage(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ByLanguage returns a page of the published snippets in a language, in the
+// given order, along with the total number of them.
+func (m *SnippetModel) ByLanguage(language, order string, limit, offset int) ([]*Snippet, int, error) {
+	return m.page(publishedSnippets(dialect(m.DB)).Where(effectiveLanguage+" = ?", language), order, limit, offset)
+}
+
+// ForOrg returns a page of the published snippets of an organization,
+// newest first, along with the total number of them. Org only snippets are
+// included if includeOrgOnly is set, which it should only be for members.
+func (m *SnippetModel) ForOrg(orgID int, includeOrgOnly bool, limit, offset int) ([]*Snippet, int, error) {
+	d := dialect(m.DB)
+
+	q := query.Select(snippetColumns...).
+		From("snippets").
+		Where("org_id = ?", orgID).
+		Where("expires > " + d.Now).
+		Where("publish_at <= " + d.Now).
+		Where("burn_after_reading = FALSE").
+		Where("content_encrypted = FALSE")
+	if !includeOrgOnly {
+		q = q.Where("org_only = FALSE")
+	}
+
+	return m.page(q, OrderNewest, limit, offset)
+}
+
+// page returns a page of the snippets which q selects, and the total number
+// which it selects. Unknown orders are treated as OrderNewest.
+func (m *SnippetModel) page(q *query.SelectBuilder, order string, limit, offset int) ([]*Snippet, int, error) {
+	orderBy, ok := snippetOrders[order]
+	if !ok {
+		orderBy = snippetOrders[OrderNewest]
+	}
+
+	var total int
+
+	countStmt, countArgs := q.Count().Build()
+
+	err := m.DB.QueryRow(countStmt, countArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt, args := q.OrderBy(orderBy).Page(limit, offset).Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// PublishDue marks scheduled snippets whose publish time has passed as
+// published, and returns them so their owners can be told. Each snippet is
+// only ever returned once, even if several instances of the application run
+// this at the same time.
+func (m *SnippetModel) PublishDue() ([]*Snippet, error) {
+	d := dialect(m.DB)
+
+	stmt, args := query.Select("id", "title", "user_id", "publish_at", "org_only").
+		From("snippets").
+		Where("published = FALSE").
+		Where("publish_at <= " + d.Now).
+		Where("expires > " + d.Now).
+		Build()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*Snippet
 
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
 		s := &Snippet{}
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		var userID sql.NullInt64
+		err = rows.Scan(&s.ID, &s.Title, &userID, &s.PublishAt, &s.OrgOnly)
 		if err != nil {
 			return nil, err
 		}
-		// Append it to the slice of snippets.
-		snippets = append(snippets, s)
+		s.UserID = int(userID.Int64)
+		due = append(due, s)
 	}
-	// When the rows.Next() loop has finished we call rows.Err() to retrieve any error that was encountered during the iteration
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	return snippets, nil
+
+	var published []*Snippet
+
+	for _, s := range due {
+		// Only the instance whose update actually changes the row gets to
+		// report the snippet as published.
+		stmt, args := query.Update("snippets").
+			Set("published", true).
+			Where("id = ?", s.ID).
+			Where("published = FALSE").
+			Build()
+
+		result, err := m.DB.Exec(stmt, args...)
+		if err != nil {
+			return published, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return published, err
+		}
+		if n == 1 {
+			published = append(published, s)
+		}
+	}
+
+	return published, nil
+}
+
+// CountAnonymousBefore returns the number of snippets without an owner which
+// were created before t.
+func (m *SnippetModel) CountAnonymousBefore(t time.Time) (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE user_id IS NULL AND created < ?`, t.UTC()).Scan(&n)
+	return n, err
+}
+
+// DeleteAnonymousBefore deletes the snippets without an owner which were
+// created before t, along with their files, and returns how many there
+// were.
+func (m *SnippetModel) DeleteAnonymousBefore(t time.Time) (int, error) {
+	result, err := m.DB.Exec(`DELETE FROM snippets WHERE user_id IS NULL AND created < ?`, t.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
 }
@@ -0,0 +1,647 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"github.com/ngohoang211020/snippetbox/internal/publicid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	tests := []struct {
+		name      string
+		snippetID int
+		wantTitle string
+		wantErr   error
+	}{
+		{
+			name:      "Valid ID",
+			snippetID: 1,
+			wantTitle: "An old silent pond",
+		},
+		{
+			name:      "Expired snippet",
+			snippetID: 2,
+			wantErr:   ErrNoRecord,
+		},
+		{
+			name:      "Non-existent ID",
+			snippetID: 99,
+			wantErr:   ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+			s, err := m.Get(tt.snippetID)
+
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, s.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestSnippetClone(t *testing.T) {
+	s := &Snippet{
+		Title: "Original",
+		Files: []*SnippetFile{{Position: 1, Language: "go"}},
+	}
+
+	c := s.clone()
+	c.Title = "Changed"
+	c.Files[0].Language = "python"
+
+	// Changing the copy, including its files, leaves the original alone.
+	assert.Equal(t, s.Title, "Original")
+	assert.Equal(t, s.Files[0].Language, "go")
+}
+
+func TestSnippetModelInsert(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:    "A new snippet",
+		Content:  "Some content",
+		Filename: "main.go",
+		Language: "go",
+		Files: []*SnippetFile{
+			{Filename: "go.mod", Language: "plaintext", Content: "module example"},
+			{Filename: "README.md", Language: "markdown", Content: "# Example"},
+		},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "A new snippet")
+	assert.Equal(t, s.Content, "Some content")
+	assert.Equal(t, s.Language, "go")
+
+	files := s.AllFiles()
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files[0].Filename, "main.go")
+	assert.Equal(t, files[2].Position, 2)
+	assert.Equal(t, files[2].Content, "# Example")
+}
+
+func TestSnippetModelFilesFor(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:   "A new snippet",
+		Content: "Some content",
+		Files: []*SnippetFile{
+			{Filename: "go.mod", Content: "module example"},
+			{Filename: "README.md", Content: "# Example"},
+		},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := m.FilesFor([]int{1, id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(files), 1)
+	assert.Equal(t, len(files[id]), 2)
+	assert.Equal(t, files[id][1].Filename, "README.md")
+
+	files, err = m.FilesFor(nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(files), 0)
+}
+
+func TestSnippetModelPublicIDs(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t), IDs: publicid.ULID}
+
+	s := &Snippet{Title: "A new snippet", Content: "Some content"}
+	id, err := m.Insert(s, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(s.PublicID), 26)
+
+	found, err := m.IDForPublicID(s.PublicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, found, id)
+
+	_, err = m.IDForPublicID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.Equal(t, err, ErrNoRecord)
+
+	// The fixtures were saved before there were public IDs.
+	s, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.PublicID, "")
+
+	n, err := m.AssignPublicIDs(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n > 0, true)
+
+	s, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err = m.IDForPublicID(s.PublicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, found, 1)
+
+	n, err = m.AssignPublicIDs(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+}
+
+func TestSnippetModelMetadata(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:   "A snippet with metadata",
+		Content: "package main\n\nfunc main() {}\n",
+		Files: []*SnippetFile{
+			{Filename: "README.md", Language: "markdown", Content: "# Example"},
+		},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Metadata.Lines, 4)
+	assert.Equal(t, s.Metadata.Bytes, 38)
+	assert.Equal(t, s.Metadata.LanguageConfidence > 0, true)
+
+	// The fixtures were saved without any metadata.
+	s, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Metadata == nil, true)
+
+	n, err := m.FillMetadata(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n > 0, true)
+
+	s, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Metadata.Lines, 5)
+
+	// Once filled in, there's nothing left to do.
+	n, err = m.FillMetadata(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+}
+
+func TestSnippetModelSetLanguage(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:            "A detected snippet",
+		Content:          "package main",
+		DetectedLanguage: "go",
+		Files: []*SnippetFile{
+			{Filename: "notes", DetectedLanguage: "markdown", Content: "# Notes"},
+		},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.SetLanguage(id, 1, "plaintext", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The version has moved on, so a second edit of version 1 conflicts.
+	err = m.SetLanguage(id, 0, "go", 1)
+	assert.Equal(t, err, ErrEditConflict)
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, s.Version, 2)
+
+	files := s.AllFiles()
+	assert.Equal(t, files[0].EffectiveLanguage(), "go")
+	assert.Equal(t, files[0].IsDetected(), true)
+	assert.Equal(t, files[1].Language, "plaintext")
+	assert.Equal(t, files[1].DetectedLanguage, "markdown")
+	assert.Equal(t, files[1].EffectiveLanguage(), "plaintext")
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:            "A snippet to edit",
+		Content:          "package main",
+		DetectedLanguage: "go",
+		Files: []*SnippetFile{
+			{Filename: "notes", DetectedLanguage: "markdown", Content: "# Notes"},
+		},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Title = "An edited snippet"
+	s.Content = "package main\n\nfunc main() {}\n"
+
+	err = m.Update(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The version has moved on, so a second edit of version 1 conflicts.
+	s.Title = "A conflicting edit"
+	err = m.Update(s, 1)
+	assert.Equal(t, err, ErrEditConflict)
+
+	s, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, s.Version, 2)
+	assert.Equal(t, s.Title, "An edited snippet")
+	assert.Equal(t, s.Content, "package main\n\nfunc main() {}\n")
+	assert.Equal(t, s.Metadata.Lines, 4)
+	assert.Equal(t, s.Files[0].Content, "# Notes")
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	snippets, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the unexpired fixture should be returned.
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].ID, 1)
+}
+
+func TestSnippetModelGetScheduled(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	s, err := m.Get(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, s.IsScheduled(), true)
+	assert.Equal(t, s.VisibleTo(0), false)
+	assert.Equal(t, s.VisibleTo(1), true)
+}
+
+func TestSnippetModelGetAndConsume(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:            "A secret",
+		Content:          "hunter2",
+		UserID:           1,
+		BurnAfterReading: true,
+		Files:            []*SnippetFile{{Filename: "more.txt", Content: "and more"}},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.VisibleTo(1), true)
+	assert.Equal(t, s.VisibleTo(0), false)
+
+	// Owners can't burn their own snippets by looking at them.
+	_, err = m.GetAndConsume(id, 1)
+	assert.Equal(t, err, ErrNoRecord)
+
+	s, err = m.GetAndConsume(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Content, "hunter2")
+	assert.Equal(t, len(s.Files), 1)
+
+	_, err = m.GetAndConsume(id, 0)
+	assert.Equal(t, err, ErrNoRecord)
+
+	s, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.IsBurned(), true)
+	assert.Equal(t, s.Content, "")
+	assert.Equal(t, len(s.Files), 0)
+	assert.Equal(t, s.VisibleTo(1), false)
+
+	// Snippets which aren't burn after reading can't be consumed.
+	_, err = m.GetAndConsume(1, 0)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestSnippetModelPublishDue(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&Snippet{
+		Title:     "Due now",
+		Content:   "Some content",
+		UserID:    1,
+		PublishAt: time.Now().Add(-time.Minute),
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fixture scheduled for 2098 isn't due yet, so only the new snippet
+	// should be published...
+	published, err := m.PublishDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(published), 1)
+	assert.Equal(t, published[0].ID, id)
+	assert.Equal(t, published[0].UserID, 1)
+
+	// ...and only once.
+	published, err = m.PublishDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(published), 0)
+}
+
+func TestSnippetModelDeleteAnonymousBefore(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	// Of the fixtures without an owner, only the expired one was created
+	// before 2021.
+	before := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	n, err := m.CountAnonymousBefore(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	n, err = m.DeleteAnonymousBefore(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	n, err = m.CountAnonymousBefore(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+
+	// Newer ones are left alone.
+	_, err = m.Get(1)
+	assert.Equal(t, err, nil)
+}
+
+func TestSnippetModelLatestFromAuthors(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	for _, title := range []string{"First", "Second", "Third"} {
+		_, err := m.Insert(&Snippet{Title: title, Content: "Content", UserID: 1}, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The scheduled fixture owned by user 1 isn't included.
+	snippets, total, err := m.LatestFromAuthors([]int{1}, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, total, 3)
+	assert.Equal(t, snippets[0].Title, "Third")
+
+	snippets, _, err = m.LatestFromAuthors([]int{1}, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "First")
+
+	snippets, total, err = m.LatestFromAuthors(nil, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(snippets), 0)
+	assert.Equal(t, total, 0)
+}
+
+func TestSnippetModelPage(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	// Encrypted and burn after reading snippets are never listed.
+	for _, s := range []*Snippet{
+		{Title: "Encrypted", Content: "v1:AAAAAAAAAAAAAAAA:AAAAAAAAAAAAAAAAAAAAAAAAAAAA", ContentEncrypted: true},
+		{Title: "Burn after reading", Content: "Content", BurnAfterReading: true},
+	} {
+		if _, err := m.Insert(s, 7); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := m.Insert(&Snippet{Title: "Newest", Content: "Content"}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired and scheduled fixtures aren't counted.
+	snippets, total, err := m.Page(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, total, 2)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "Newest")
+
+	snippets, _, err = m.Page(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippets[0].ID, 1)
+}
+
+func TestSnippetModelByLanguage(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	for _, s := range []*Snippet{
+		{Title: "Beta", Content: "package beta", Language: "go"},
+		{Title: "Alpha", Content: "package alpha", Language: "go"},
+		{Title: "Script", Content: "print('hi')", Language: "python"},
+	} {
+		if _, err := m.Insert(s, 7); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The fixture snippet has no language, so it isn't counted.
+	counts, err := m.CountByLanguage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(counts), 2)
+	assert.Equal(t, *counts[0], LanguageCount{Language: "go", Count: 2})
+	assert.Equal(t, *counts[1], LanguageCount{Language: "python", Count: 1})
+
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stats.PublicSnippets, 4)
+	assert.Equal(t, stats.Users, 1)
+	assert.Equal(t, len(stats.Languages), 2)
+
+	snippets, total, err := m.ByLanguage("go", OrderTitle, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, total, 2)
+	assert.Equal(t, snippets[0].Title, "Alpha")
+	assert.Equal(t, snippets[1].Title, "Beta")
+
+	snippets, _, err = m.ByLanguage("go", OrderNewest, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, snippets[0].Title, "Alpha")
+}
+
+func TestSnippetModelCompression(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := &SnippetModel{DB: db, CompressAbove: 100}
+
+	large := strings.Repeat("An old silent pond...\n", 100)
+
+	id, err := m.Insert(&Snippet{
+		Title:   "Large",
+		Content: large,
+		Files:   []*SnippetFile{{Filename: "small.txt", Content: "Small"}, {Filename: "large.txt", Content: large}},
+	}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	var compressed []byte
+	err = db.QueryRow("SELECT content, content_zlib FROM snippets WHERE id = ?", id).Scan(&stored, &compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stored, "")
+	assert.Equal(t, len(compressed) > 0 && len(compressed) < len(large), true)
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Content, large)
+	assert.Equal(t, s.Files[0].Content, "Small")
+	assert.Equal(t, s.Files[1].Content, large)
+
+	// Raising the threshold and recompressing decompresses everything.
+	m.CompressAbove = -1
+	lastID, changed, err := m.Recompress(id-1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, lastID, id)
+	assert.Equal(t, changed, 2)
+
+	err = db.QueryRow("SELECT content, content_zlib FROM snippets WHERE id = ?", id).Scan(&stored, &compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stored, large)
+	assert.Equal(t, compressed == nil, true)
+
+	lastID, _, err = m.Recompress(id, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, lastID, 0)
+}
+
+func TestSnippetModelLatestAfter(t *testing.T) {
+	m := SnippetModel{DB: testutils.NewTestDB(t)}
+
+	for _, title := range []string{"First", "Second", "Third"} {
+		_, err := m.Insert(&Snippet{Title: title, Content: "Content", UserID: 1}, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets, err := m.LatestAfter(SnippetCursor{}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, snippets[0].Title, "Third")
+
+	// A snippet published in the meantime doesn't move the next page.
+	_, err = m.Insert(&Snippet{Title: "Fourth", Content: "Content", UserID: 1}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets, err = m.LatestAfter(snippets[1].Cursor(), 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Title, "First")
+
+	snippets, err = m.LatestAfter(SnippetCursor{}, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range snippets {
+		assert.Equal(t, s.UserID, 2)
+	}
+}
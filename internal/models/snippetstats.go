@@ -0,0 +1,234 @@
+package models
+
+import (
+	"time"
+)
+
+// SnippetView is a single view of a snippet, as recorded by the analytics
+// middleware. Referrer is the host of the referring page, or empty for direct
+// visits; Country is a two-letter country code, or empty if it's unknown.
+type SnippetView struct {
+	SnippetID int
+	Viewed    time.Time
+	Referrer  string
+	Country   string
+	Browser   string
+}
+
+// SnippetStats summarizes the views of a snippet over a number of days.
+// Daily has an entry for each day, oldest first, including days without any
+// views, and the breakdowns are sorted by descending views.
+type SnippetStats struct {
+	Days      int
+	Total     int
+	Today     int
+	Daily     []DailyViews
+	Referrers []ViewCount
+	Countries []ViewCount
+	Browsers  []ViewCount
+}
+
+// MaxDaily returns the highest number of views on any one day, for scaling
+// charts.
+func (s *SnippetStats) MaxDaily() int {
+	max := 0
+	for _, d := range s.Daily {
+		if d.Views > max {
+			max = d.Views
+		}
+	}
+	return max
+}
+
+// DailyViews is the number of views of a snippet on one day.
+type DailyViews struct {
+	Day   time.Time
+	Views int
+}
+
+// ViewCount is the number of views with a particular referrer, country or
+// browser.
+type ViewCount struct {
+	Value string
+	Views int
+}
+
+// The dimensions which views are aggregated by in the snippet_stats table.
+// The total dimension has a single row per day with an empty value.
+var statDimensions = []string{"total", "referrer", "country", "browser"}
+
+type SnippetStatsModelInterface interface {
+	RecordView(v *SnippetView) error
+	Aggregate() (int, error)
+	ForSnippet(snippetID, days int) (*SnippetStats, error)
+	CountDetailBefore(day time.Time) (int, error)
+	DeleteDetailBefore(day time.Time) (int, error)
+}
+
+type SnippetStatsModel struct {
+	DB DBTX
+}
+
+// RecordView stores a view of a snippet. Views are kept individually until
+// Aggregate rolls them up.
+func (m *SnippetStatsModel) RecordView(v *SnippetView) error {
+	stmt := `INSERT INTO snippet_views (snippet_id, viewed, referrer, country, browser)
+    VALUES(?, UTC_TIMESTAMP(), ?, ?, ?)`
+
+	_, err := m.DB.Exec(stmt, v.SnippetID, v.Referrer, v.Country, v.Browser)
+	return err
+}
+
+// Aggregate rolls the individual views from before today (in UTC) up into
+// daily counts in snippet_stats, and deletes them. It returns the number of
+// views which were rolled up. Today's views are left alone, so that each day
+// is only aggregated once it's complete.
+func (m *SnippetStatsModel) Aggregate() (int, error) {
+	var n int64
+
+	err := transact(m.DB, func(tx DBTX) error {
+		for _, dimension := range statDimensions {
+			value := dimension
+			if dimension == "total" {
+				value = "''"
+			}
+
+			stmt := `INSERT INTO snippet_stats (snippet_id, day, dimension, value, views)
+    SELECT snippet_id, DATE(viewed), '` + dimension + `', ` + value + `, COUNT(*) FROM snippet_views
+    WHERE viewed < UTC_DATE() GROUP BY snippet_id, DATE(viewed), ` + value + `
+    ON DUPLICATE KEY UPDATE views = snippet_stats.views + VALUES(views)`
+
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.Exec(`DELETE FROM snippet_views WHERE viewed < UTC_DATE()`)
+		if err != nil {
+			return err
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+
+	return int(n), err
+}
+
+// ForSnippet returns the statistics for a snippet over the given number of
+// days, up to and including today. Today's views haven't been aggregated yet,
+// so they're counted separately.
+func (m *SnippetStatsModel) ForSnippet(snippetID, days int) (*SnippetStats, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	stats := &SnippetStats{Days: days}
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippet_views WHERE snippet_id = ? AND viewed >= UTC_DATE()`,
+		snippetID).Scan(&stats.Today)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := map[string]int{}
+
+	rows, err := m.DB.Query(`SELECT day, views FROM snippet_stats
+    WHERE snippet_id = ? AND dimension = 'total' AND day >= ?`, snippetID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day time.Time
+		var views int
+		if err = rows.Scan(&day, &views); err != nil {
+			return nil, err
+		}
+		daily[day.Format("2006-01-02")] = views
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		views := daily[day.Format("2006-01-02")]
+		if day.Equal(today) {
+			views = stats.Today
+		}
+		stats.Daily = append(stats.Daily, DailyViews{Day: day, Views: views})
+		stats.Total += views
+	}
+
+	breakdowns := map[string]*[]ViewCount{
+		"referrer": &stats.Referrers,
+		"country":  &stats.Countries,
+		"browser":  &stats.Browsers,
+	}
+
+	for dimension, counts := range breakdowns {
+		*counts, err = m.breakdown(snippetID, dimension, since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// breakdown returns the views of a snippet since the given day grouped by one
+// dimension, including today's views which haven't been aggregated yet.
+func (m *SnippetStatsModel) breakdown(snippetID int, dimension string, since time.Time) ([]ViewCount, error) {
+	stmt := `SELECT value, SUM(views) AS views FROM (
+        SELECT value, views FROM snippet_stats WHERE snippet_id = ? AND dimension = ? AND day >= ?
+        UNION ALL
+        SELECT ` + dimension + ` AS value, 1 AS views FROM snippet_views WHERE snippet_id = ? AND viewed >= UTC_DATE()
+    ) v GROUP BY value ORDER BY views DESC, value LIMIT 10`
+
+	rows, err := m.DB.Query(stmt, snippetID, dimension, since, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []ViewCount{}
+
+	for rows.Next() {
+		var c ViewCount
+		if err = rows.Scan(&c.Value, &c.Views); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// CountDetailBefore returns the number of rows breaking down the views
+// before the given day by referrer, country or browser.
+func (m *SnippetStatsModel) CountDetailBefore(day time.Time) (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippet_stats WHERE dimension <> 'total' AND day < ?`,
+		day.UTC().Format("2006-01-02")).Scan(&n)
+	return n, err
+}
+
+// DeleteDetailBefore deletes the breakdowns of the views before the given
+// day by referrer, country and browser, which say the most about who the
+// viewers were. The daily totals are kept. It returns the number of rows
+// deleted.
+func (m *SnippetStatsModel) DeleteDetailBefore(day time.Time) (int, error) {
+	result, err := m.DB.Exec(`DELETE FROM snippet_stats WHERE dimension <> 'total' AND day < ?`,
+		day.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestSnippetStatsModel(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := SnippetStatsModel{DB: db}
+
+	// Two views from yesterday, which Aggregate should roll up...
+	_, err := db.Exec(`INSERT INTO snippet_views (snippet_id, viewed, referrer, country, browser) VALUES
+        (1, UTC_TIMESTAMP() - INTERVAL 1 DAY, 'example.com', 'NZ', 'Firefox'),
+        (1, UTC_TIMESTAMP() - INTERVAL 1 DAY, '', 'NZ', 'Chrome')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ...and one from today, which it should leave alone.
+	err = m.RecordView(&SnippetView{SnippetID: 1, Referrer: "example.com", Browser: "Firefox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := m.Aggregate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 2)
+
+	stats, err := m.ForSnippet(1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(stats.Daily), 7)
+	assert.Equal(t, stats.Daily[5].Views, 2)
+	assert.Equal(t, stats.Daily[6].Views, 1)
+	assert.Equal(t, stats.Today, 1)
+	assert.Equal(t, stats.Total, 3)
+	assert.Equal(t, stats.Referrers[0], ViewCount{Value: "example.com", Views: 2})
+	assert.Equal(t, stats.Browsers[0], ViewCount{Value: "Firefox", Views: 2})
+
+	// Yesterday's breakdowns go, leaving the daily totals and today's views.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	n, err = m.CountDetailBefore(today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 5)
+
+	n, err = m.DeleteDetailBefore(today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 5)
+
+	stats, err = m.ForSnippet(1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, stats.Total, 3)
+	assert.Equal(t, stats.Referrers[0], ViewCount{Value: "example.com", Views: 1})
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SnippetTemplate is a user's reusable starting point for new snippets. Its
+// fields pre-populate the create form.
+type SnippetTemplate struct {
+	ID       int
+	UserID   int
+	Name     string
+	Title    string
+	Content  string
+	Filename string
+	Language string
+	Created  time.Time
+	Updated  time.Time
+}
+
+type SnippetTemplateModelInterface interface {
+	Insert(t *SnippetTemplate) (int, error)
+	Get(id, userID int) (*SnippetTemplate, error)
+	ForUser(userID int) ([]*SnippetTemplate, error)
+	Update(t *SnippetTemplate) error
+	Delete(id, userID int) error
+}
+
+type SnippetTemplateModel struct {
+	DB DBTX
+}
+
+// Insert stores a new template and returns its ID. It returns
+// ErrDuplicateTemplateName if the user already has a template with the same
+// name.
+func (m *SnippetTemplateModel) Insert(t *SnippetTemplate) (int, error) {
+	stmt := `INSERT INTO snippet_templates (user_id, name, title, content, filename, language, created, updated)
+    VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, t.UserID, t.Name, t.Title, t.Content, t.Filename, t.Language)
+	if err != nil {
+		return 0, translateMySQLError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get returns one of the user's templates, or ErrNoRecord if they have no
+// template with that ID.
+func (m *SnippetTemplateModel) Get(id, userID int) (*SnippetTemplate, error) {
+	t := &SnippetTemplate{}
+
+	stmt := `SELECT id, user_id, name, title, content, filename, language, created, updated
+    FROM snippet_templates WHERE id = ? AND user_id = ?`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Title, &t.Content, &t.Filename, &t.Language, &t.Created, &t.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ForUser returns the user's templates in order of name.
+func (m *SnippetTemplateModel) ForUser(userID int) ([]*SnippetTemplate, error) {
+	stmt := `SELECT id, user_id, name, title, content, filename, language, created, updated
+    FROM snippet_templates WHERE user_id = ? ORDER BY name, id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*SnippetTemplate{}
+
+	for rows.Next() {
+		t := &SnippetTemplate{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Title, &t.Content, &t.Filename, &t.Language, &t.Created, &t.Updated)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// Update saves changes to one of the user's templates. It returns
+// ErrNoRecord if the user has no template with t.ID, and
+// ErrDuplicateTemplateName if they have another template with the new name.
+func (m *SnippetTemplateModel) Update(t *SnippetTemplate) error {
+	stmt := `UPDATE snippet_templates SET name = ?, title = ?, content = ?, filename = ?, language = ?, updated = UTC_TIMESTAMP()
+    WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, t.Name, t.Title, t.Content, t.Filename, t.Language, t.ID, t.UserID)
+	if err != nil {
+		return translateMySQLError(err)
+	}
+
+	// MySQL counts matched rather than changed rows here only with the
+	// CLIENT_FOUND_ROWS flag, so check for the template separately when
+	// nothing changed.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = m.Get(t.ID, t.UserID)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes one of the user's templates. It returns ErrNoRecord if the
+// user has no template with that ID.
+func (m *SnippetTemplateModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM snippet_templates WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+)
+
+func TestSnippetTemplateModel(t *testing.T) {
+	m := SnippetTemplateModel{DB: testutils.NewTestDB(t)}
+
+	id, err := m.Insert(&SnippetTemplate{UserID: 1, Name: "Go program", Content: "package main\n", Filename: "main.go", Language: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Insert(&SnippetTemplate{UserID: 1, Name: "Go program"})
+	assert.Equal(t, errors.Is(err, ErrDuplicateTemplateName), true)
+
+	// Names only have to be unique for each user.
+	_, err = m.Insert(&SnippetTemplate{UserID: 2, Name: "Go program"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := m.Get(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tmpl.Filename, "main.go")
+
+	_, err = m.Get(id, 2)
+	assert.Equal(t, err, ErrNoRecord)
+
+	tmpl.Name = "Go main package"
+	err = m.Update(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving without changes still succeeds.
+	err = m.Update(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Update(&SnippetTemplate{ID: id, UserID: 2, Name: "Stolen"})
+	assert.Equal(t, err, ErrNoRecord)
+
+	templates, err := m.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(templates), 1)
+	assert.Equal(t, templates[0].Name, "Go main package")
+
+	err = m.Delete(id, 2)
+	assert.Equal(t, err, ErrNoRecord)
+	err = m.Delete(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+INSERT INTO users (id, name, email, hashed_password, created) VALUES (
+    1,
+    'Alice Jones',
+    'alice@example.com',
+    '$2a$12$dn2LWh.gukXSk6j2WfIQ4.f3Ia5o0i0Hkhnytcfmt1I6myaIgots2',
+    '2022-01-01 10:00:00'
+);
+
+INSERT INTO snippets (id, title, content, created, expires, publish_at) VALUES (
+    1,
+    'An old silent pond',
+    'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō',
+    '2022-01-01 10:00:00',
+    '2099-01-01 10:00:00',
+    '2022-01-01 10:00:00'
+);
+
+INSERT INTO snippets (id, title, content, created, expires, publish_at) VALUES (
+    2,
+    'An expired snippet',
+    'Nothing to see here.',
+    '2020-01-01 10:00:00',
+    '2020-01-02 10:00:00',
+    '2020-01-01 10:00:00'
+);
+
+INSERT INTO snippets (id, title, content, user_id, created, expires, publish_at, published) VALUES (
+    3,
+    'A scheduled snippet',
+    'Coming soon.',
+    1,
+    '2022-01-01 10:00:00',
+    '2099-01-01 10:00:00',
+    '2098-01-01 10:00:00',
+    FALSE
+);
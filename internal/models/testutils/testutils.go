@@ -0,0 +1,152 @@
+// Package testutils provides a throwaway MySQL database for integration tests
+// of the models package.
+//
+// The tests are opt-in: they connect to the database named by the TEST_DSN
+// environment variable and are skipped when it is not set. A disposable
+// database can be started with docker like so:
+//
+//	docker run --rm -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=pass \
+//	    -e MYSQL_DATABASE=test_snippetbox mysql:8
+//	TEST_DSN='root:pass@/test_snippetbox' go test ./internal/models/...
+//
+// WARNING: every table in the TEST_DSN database is dropped before the
+// migrations are applied, so never point it at a database you care about.
+package testutils
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/ngohoang211020/snippetbox/migrations"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+)
+
+//go:embed "testdata/fixtures.sql"
+var fixtures string
+
+var (
+	once    sync.Once
+	db      *sql.DB
+	openErr error
+)
+
+// NewTestDB returns a transaction against the test database which already
+// contains the fixtures from testdata/fixtures.sql. The transaction is rolled
+// back when the test (and all its subtests) complete, so tests can freely
+// insert and update rows without affecting each other.
+func NewTestDB(t *testing.T) *sql.Tx {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DSN not set; skipping models integration test")
+	}
+
+	// Opening the connection pool and applying the migrations is relatively
+	// slow, so we only do it once per test binary.
+	once.Do(func() {
+		db, openErr = openTestDB(dsn)
+	})
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// ErrTxDone just means the test committed or rolled back the
+		// transaction itself, which is fine.
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			t.Error(err)
+		}
+	})
+
+	_, err = tx.Exec(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func openTestDB(dsn string) (*sql.DB, error) {
+	// The migration and fixture files contain several statements each, and
+	// the models rely on DATETIME columns being scanned into time.Time.
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.MultiStatements = true
+	cfg.ParseTime = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = resetSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// resetSchema drops every table in the test database and then applies all
+// the migrations in order, so each run starts from a known schema.
+func resetSchema(db *sql.DB) error {
+	rows, err := db.Query("SHOW TABLES")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		_, err = db.Exec("SET FOREIGN_KEY_CHECKS = 0; DROP TABLE `" + table + "`; SET FOREIGN_KEY_CHECKS = 1")
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := fs.Glob(migrations.Files, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		stmt, err := fs.ReadFile(migrations.Files, file)
+		if err != nil {
+			return err
+		}
+		if _, err = db.Exec(string(stmt)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
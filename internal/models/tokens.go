@@ -0,0 +1,26 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+)
+
+// newToken generates a random token for sending to a user, along with the
+// SHA-256 hash of it which is stored in the database. Only storing the hash
+// means that someone who can read the database still can't use the tokens.
+func newToken() (plaintext string, hash []byte, err error) {
+	b := make([]byte, 20)
+	if _, err = rand.Read(b); err != nil {
+		return "", nil, err
+	}
+
+	plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	return plaintext, hashToken(plaintext), nil
+}
+
+// hashToken returns the hash of a token, for looking it up in the database.
+func hashToken(plaintext string) []byte {
+	h := sha256.Sum256([]byte(plaintext))
+	return h[:]
+}
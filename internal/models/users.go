@@ -3,9 +3,8 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
-	"strings"
+	"github.com/ngohoang211020/snippetbox/internal/passwordhash"
+	"github.com/ngohoang211020/snippetbox/internal/query"
 	"time"
 )
 
@@ -15,6 +14,28 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	IsAdmin        bool
+
+	// SuspendedUntil is when the user's suspension ends, or zero if they
+	// aren't suspended. It's BannedUntil for banned users. BanReason is
+	// what they're told.
+	SuspendedUntil time.Time
+	BanReason      string
+}
+
+// BannedUntil is the end of a ban, which is a suspension which never ends.
+var BannedUntil = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Suspended reports whether the user is suspended or banned at the given
+// time.
+func (u *User) Suspended(now time.Time) bool {
+	return now.Before(u.SuspendedUntil)
+}
+
+// Banned reports whether the user is banned, rather than suspended for a
+// while.
+func (u *User) Banned() bool {
+	return !u.SuspendedUntil.Before(BannedUntil)
 }
 
 type UserModelInterface interface {
@@ -22,39 +43,81 @@ type UserModelInterface interface {
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
 	Get(id int) (*User, error)
+	GetMany(ids []int) (map[int]*User, error)
+	GetByEmail(email string) (*User, error)
 	PasswordUpdate(id int, currentPassword, newPassword string) error
+	SetEmail(id int, email string) error
+	ChangelogSeen(id int) (string, error)
+	SetChangelogSeen(id int, version string) error
+	Suspend(id int, until time.Time, reason string) error
+	Unsuspend(id int) error
+	UnsuspendExpired() ([]int, error)
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB DBTX
+	// Hasher hashes passwords, or passwordhash.Default if it's nil.
+	// Passwords hashed some other way are hashed again when their owner
+	// signs in.
+	Hasher *passwordhash.Hasher
+}
+
+func (m *UserModel) hasher() *passwordhash.Hasher {
+	if m.Hasher == nil {
+		return passwordhash.Default
+	}
+	return m.Hasher
+}
+
+// userColumns are the columns getBy scans into a User. The password hash is
+// left out; only Authenticate and PasswordUpdate need it.
+var userColumns = []string{"id", "name", "email", "created", "is_admin", "suspended_until", "ban_reason"}
+
+// getBy returns the user matching cond, or ErrNoRecord if there isn't one.
+func (m *UserModel) getBy(cond string, arg any) (*User, error) {
+	stmt, args := query.Select(userColumns...).From("users").Where(cond, arg).Build()
+
+	user, err := scanUser(m.DB.QueryRow(stmt, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	return user, err
+}
+
+// scanUser scans a row of userColumns into a User.
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	var suspendedUntil sql.NullTime
+
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.IsAdmin, &suspendedUntil, &user.BanReason)
+	if err != nil {
+		return nil, err
+	}
+	user.SuspendedUntil = suspendedUntil.Time
+	return &user, nil
 }
 
 func (m *UserModel) Insert(name, email, password string) error {
-	// Create a bcrypt hash of the plain-text password.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	// Create a hash of the plain-text password.
+	hashedPassword, err := m.hasher().Hash(password)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt, args := query.Insert("users").
+		Set("name", name).
+		Set("email", email).
+		Set("hashed_password", hashedPassword).
+		SetExpr("created", dialect(m.DB).Now).
+		Build()
 
 	// Use the Exec() method to insert the user details and hashed password
 	// into the users table.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.Exec(stmt, args...)
 	if err != nil {
-		// If this returns an error, we use the errors.As() function to check
-		// whether the error has the type *mysql.MySQLError. If it does, the
-		// error will be assigned to the mySQLError variable. We can then check
-		// whether or not the error relates to our users_uc_email key by
-		// checking if the error code equals 1062 and the contents of the error
-		// message string. If it does, we return an ErrDuplicateEmail error.
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
-		}
-		return err
+		// A duplicate entry in the users_uc_email key becomes
+		// ErrDuplicateEmail.
+		return translateMySQLError(err)
 	}
 
 	return nil
@@ -62,12 +125,13 @@ func (m *UserModel) Insert(name, email, password string) error {
 
 // Authenticate We'll use this method to verify whether a user exists with
 // the provided email address and password. This will return the relevant
-// user ID if they do.
+// user ID if they do. A password hashed with another scheme, or other
+// parameters, than the model's Hasher is hashed again now that it's known.
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
-	var hashedPassword []byte
-	stmt := `SELECT id, hashed_password from users where email= ?`
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	var hashedPassword string
+	stmt, args := query.Select("id", "hashed_password").From("users").Where("email = ?", email).Build()
+	err := m.DB.QueryRow(stmt, args...).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -77,71 +141,214 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	}
 	// Check whether the hashed password and plain-text password provided match.
 	// If they don't, we return the ErrInvalidCredentials error
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	rehash, err := m.hasher().Verify(hashedPassword, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if errors.Is(err, passwordhash.ErrMismatch) {
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
 		}
 	}
 
+	if rehash {
+		if err = m.setPassword(id, password); err != nil {
+			return 0, err
+		}
+	}
+
 	return id, nil
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	var exists bool
 
-	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	stmt, args := query.Select("true").From("users").Where("id = ?", id).Build()
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRow("SELECT EXISTS("+stmt+")", args...).Scan(&exists)
 	return exists, err
 }
 
 func (m *UserModel) Get(id int) (*User, error) {
-	var user User
-	stmt := `SELECT id, name, email, created FROM users WHERE id = ?`
+	return m.getBy("id = ?", id)
+}
+
+// GetMany returns the users with the given IDs, by ID, in one query. IDs
+// which don't belong to a user are left out.
+func (m *UserModel) GetMany(ids []int) (map[int]*User, error) {
+	values := make([]any, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
 
-	err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created)
+	stmt, args := query.Select(userColumns...).From("users").WhereIn("id", values).Build()
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNoRecord
-		} else {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[int]*User, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
+		users[user.ID] = user
 	}
-	return &user, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetByEmail returns the user with the given email address, or ErrNoRecord
+// if there isn't one.
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	return m.getBy("email = ?", email)
+}
+
+// ChangelogSeen returns the newest changelog version the user has seen, or
+// "" if they have never looked.
+func (m *UserModel) ChangelogSeen(id int) (string, error) {
+	var version string
+
+	stmt, args := query.Select("changelog_seen").From("users").Where("id = ?", id).Build()
+
+	err := m.DB.QueryRow(stmt, args...).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+	return version, err
+}
+
+// SetChangelogSeen records that the user has seen the changelog up to
+// version.
+func (m *UserModel) SetChangelogSeen(id int, version string) error {
+	stmt, args := query.Update("users").Set("changelog_seen", version).Where("id = ?", id).Build()
+
+	_, err := m.DB.Exec(stmt, args...)
+	return err
 }
 
 func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
-	var currentHashedPassword []byte
+	var currentHashedPassword string
 
-	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+	stmt, args := query.Select("hashed_password").From("users").Where("id = ?", id).Build()
 
-	err := m.DB.QueryRow(stmt, id).Scan(&currentHashedPassword)
+	err := m.DB.QueryRow(stmt, args...).Scan(&currentHashedPassword)
 	if err != nil {
 		return err
 	}
 
-	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	_, err = m.hasher().Verify(currentHashedPassword, currentPassword)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, passwordhash.ErrMismatch) {
 			return ErrInvalidCredentials
 		} else {
 			return err
 		}
 	}
 
-	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	return m.setPassword(id, newPassword)
+}
+
+// SetEmail changes the user's email address. It returns ErrDuplicateEmail if
+// another account already uses the new address.
+func (m *UserModel) SetEmail(id int, email string) error {
+	stmt, args := query.Update("users").Set("email", email).Where("id = ?", id).Build()
+
+	_, err := m.DB.Exec(stmt, args...)
+	return translateMySQLError(err)
+}
+
+// setPassword hashes the user's password with the model's Hasher and stores
+// the hash.
+func (m *UserModel) setPassword(id int, password string) error {
+	hashedPassword, err := m.hasher().Hash(password)
 	if err != nil {
 		return err
 	}
 
-	stmt = `UPDATE users SET hashed_password= ? where id = ?`
+	stmt, args := query.Update("users").Set("hashed_password", hashedPassword).Where("id = ?", id).Build()
 
-	// Use the Exec() method to insert the user details and hashed password
-	// into the users table.
-	_, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+	// Use the Exec() method to update the hashed password in the users
+	// table.
+	_, err = m.DB.Exec(stmt, args...)
+	return err
+}
+
+// Suspend stops the user signing in until the given time, which is
+// BannedUntil for a ban. The reason is shown to them.
+func (m *UserModel) Suspend(id int, until time.Time, reason string) error {
+	stmt, args := query.Update("users").
+		Set("suspended_until", until.UTC()).
+		Set("ban_reason", reason).
+		Where("id = ?", id).
+		Build()
+
+	_, err := m.DB.Exec(stmt, args...)
 	return err
 }
+
+// Unsuspend lifts the user's suspension or ban straight away.
+func (m *UserModel) Unsuspend(id int) error {
+	stmt, args := query.Update("users").
+		Set("suspended_until", nil).
+		Set("ban_reason", "").
+		Where("id = ?", id).
+		Build()
+
+	_, err := m.DB.Exec(stmt, args...)
+	return err
+}
+
+// UnsuspendExpired lifts the suspensions which have ended and returns the
+// IDs of the users whose suspensions they were.
+func (m *UserModel) UnsuspendExpired() ([]int, error) {
+	var ids []int
+
+	err := transact(m.DB, func(tx DBTX) error {
+		stmt, args := query.Select("id").From("users").
+			Where("suspended_until <= " + dialect(tx).Now).
+			ForUpdate().
+			Build()
+
+		rows, err := tx.Query(stmt, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			if err = rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			stmt, args = query.Update("users").
+				Set("suspended_until", nil).
+				Set("ban_reason", "").
+				Where("id = ?", id).
+				Build()
+			if _, err = tx.Exec(stmt, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,269 @@
+package models
+
+import (
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"github.com/ngohoang211020/snippetbox/internal/passwordhash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserModelExists(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int
+		want   bool
+	}{
+		{
+			name:   "Valid ID",
+			userID: 1,
+			want:   true,
+		},
+		{
+			name:   "Zero ID",
+			userID: 0,
+			want:   false,
+		},
+		{
+			name:   "Non-existent ID",
+			userID: 2,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := UserModel{DB: testutils.NewTestDB(t)}
+
+			exists, err := m.Exists(tt.userID)
+
+			assert.Equal(t, exists, tt.want)
+			assert.Equal(t, err, nil)
+		})
+	}
+}
+
+func TestUserModelInsert(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	err := m.Insert("Bob", "bob@example.com", "validPa$$word")
+	assert.Equal(t, err, nil)
+
+	// Inserting a second user with an email address which is already in use
+	// should be reported as ErrDuplicateEmail rather than a raw MySQL error.
+	err = m.Insert("Alice Again", "alice@example.com", "validPa$$word")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+}
+
+func TestUserModelAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{
+			name:     "Valid credentials",
+			email:    "alice@example.com",
+			password: "pa$$word",
+			wantID:   1,
+		},
+		{
+			name:     "Wrong password",
+			email:    "alice@example.com",
+			password: "wrong",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "Unknown email",
+			email:    "nobody@example.com",
+			password: "pa$$word",
+			wantErr:  ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := UserModel{DB: testutils.NewTestDB(t)}
+
+			id, err := m.Authenticate(tt.email, tt.password)
+
+			assert.Equal(t, id, tt.wantID)
+			assert.Equal(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUserModelAuthenticateRehash(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := UserModel{DB: db, Hasher: &passwordhash.Hasher{Scheme: passwordhash.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}}}
+
+	hashedPassword := func() string {
+		var hash string
+		err := db.QueryRow("SELECT hashed_password FROM users WHERE id = 1").Scan(&hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// Alice's password was hashed with bcrypt, so signing in hashes it again
+	// with Argon2id.
+	id, err := m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, 1)
+	assert.Equal(t, strings.HasPrefix(hashedPassword(), "$argon2id$"), true)
+
+	// Both the new hash and a model still using bcrypt accept it.
+	_, err = m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+
+	old := UserModel{DB: db}
+	_, err = old.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.HasPrefix(hashedPassword(), "$2a$12$"), true)
+}
+
+func TestUserModelGet(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	user, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Email, "alice@example.com")
+
+	_, err = m.Get(2)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelGetMany(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	users, err := m.GetMany([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[1].Email, "alice@example.com")
+}
+
+func TestUserModelChangelogSeen(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	version, err := m.ChangelogSeen(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version, "")
+
+	err = m.SetChangelogSeen(1, "1.6.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err = m.ChangelogSeen(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, version, "1.6.0")
+
+	_, err = m.ChangelogSeen(2)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestUserModelSetEmail(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	err := m.SetEmail(1, "alice.new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Email, "alice.new@example.com")
+
+	err = m.Insert("Bob", "bob@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetEmail(1, "bob@example.com")
+	assert.Equal(t, errors.Is(err, ErrDuplicateEmail), true)
+}
+
+func TestUserModelSuspend(t *testing.T) {
+	m := UserModel{DB: testutils.NewTestDB(t)}
+
+	user, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Suspended(time.Now()), false)
+
+	err = m.Suspend(1, time.Now().Add(time.Hour), "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Suspended(time.Now()), true)
+	assert.Equal(t, user.Banned(), false)
+	assert.Equal(t, user.BanReason, "Spam")
+
+	// Suspensions which haven't ended yet are left alone.
+	ids, err := m.UnsuspendExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(ids), 0)
+
+	err = m.Suspend(1, time.Now().Add(-time.Minute), "Spam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = m.UnsuspendExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(ids), 1)
+	assert.Equal(t, ids[0], 1)
+
+	user, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.SuspendedUntil.IsZero(), true)
+	assert.Equal(t, user.BanReason, "")
+
+	// Bans never end by themselves.
+	err = m.Suspend(1, BannedUntil, "Abuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = m.UnsuspendExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(ids), 0)
+	user, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Banned(), true)
+
+	err = m.Unsuspend(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, user.Suspended(time.Now()), false)
+}
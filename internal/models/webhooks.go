@@ -0,0 +1,335 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/crypto"
+	"strings"
+	"time"
+)
+
+// Webhook is a URL which is sent events as they happen. Webhooks with a
+// UserID are sent the events for that user's snippets; those without one
+// were added by an admin and are sent every event.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+// Subscribes reports whether the webhook wants the given event.
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// The states a webhook delivery can be in.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook. URL,
+// Secret and UserID are copied from the webhook, for sending it.
+type WebhookDelivery struct {
+	ID             int
+	WebhookID      int
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttempt    time.Time
+	ResponseStatus int
+	Error          string
+	Created        time.Time
+	Updated        time.Time
+
+	URL    string
+	Secret string
+	UserID int
+}
+
+type WebhookModelInterface interface {
+	Insert(w *Webhook) (int, error)
+	Get(id, userID int) (*Webhook, error)
+	ForUser(userID int) ([]*Webhook, error)
+	Delete(id, userID int) error
+	Enqueue(event string, ownerID int, payload []byte) (int, error)
+	Due(limit int) ([]*WebhookDelivery, error)
+	Delivered(id, responseStatus int) error
+	Failed(id, responseStatus int, message string, retryAt time.Time) error
+	Deliveries(webhookID, limit int) ([]*WebhookDelivery, error)
+}
+
+// WebhookModel stores webhooks and the queue of deliveries to them. The
+// secrets are encrypted with Keys, if it is set.
+//
+// Methods which take a userID use zero for the admins' webhooks.
+type WebhookModel struct {
+	DB   DBTX
+	Keys *crypto.Keyring
+}
+
+// webhookOwner is the value of the user_id column for a userID: NULL for
+// the admins' webhooks.
+func webhookOwner(userID int) any {
+	if userID == 0 {
+		return nil
+	}
+	return userID
+}
+
+// Insert stores a new webhook and returns its ID.
+func (m *WebhookModel) Insert(w *Webhook) (int, error) {
+	var id int
+
+	// The secret is tied to the row it's stored in, so it can only be
+	// sealed once the row has an ID.
+	err := transact(m.DB, func(tx DBTX) error {
+		result, err := tx.Exec(`INSERT INTO webhooks (user_id, url, secret, events, created) VALUES(?, ?, '', ?, UTC_TIMESTAMP())`,
+			webhookOwner(w.UserID), w.URL, strings.Join(w.Events, ","))
+		if err != nil {
+			return err
+		}
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = int(lastID)
+
+		sealed, err := sealField(m.Keys, w.Secret, "webhooks.secret", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE webhooks SET secret = ? WHERE id = ?`, sealed, id)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Get returns one of the user's webhooks, or ErrNoRecord if they have no
+// webhook with that ID.
+func (m *WebhookModel) Get(id, userID int) (*Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, events, created FROM webhooks WHERE id = ? AND user_id <=> ?`
+
+	w, err := m.scan(m.DB.QueryRow(stmt, id, webhookOwner(userID)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// ForUser returns the user's webhooks, oldest first.
+func (m *WebhookModel) ForUser(userID int) ([]*Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, events, created FROM webhooks WHERE user_id <=> ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, webhookOwner(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		w, err := m.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (m *WebhookModel) scan(row interface{ Scan(dest ...any) error }) (*Webhook, error) {
+	w := &Webhook{}
+	var userID sql.NullInt64
+	var events string
+
+	err := row.Scan(&w.ID, &userID, &w.URL, &w.Secret, &events, &w.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	w.UserID = int(userID.Int64)
+	w.Events = strings.Split(events, ",")
+	w.Secret, err = openField(m.Keys, w.Secret, "webhooks.secret", w.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Delete removes one of the user's webhooks, along with its deliveries. It
+// returns ErrNoRecord if they have no webhook with that ID.
+func (m *WebhookModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM webhooks WHERE id = ? AND user_id <=> ?`, id, webhookOwner(userID))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// Enqueue queues an event for delivery to every webhook which subscribes to
+// it and either belongs to ownerID or to the admins, and returns how many
+// deliveries were queued. An ownerID of zero only reaches the admins'
+// webhooks.
+func (m *WebhookModel) Enqueue(event string, ownerID int, payload []byte) (int, error) {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt, created, updated)
+    SELECT id, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), UTC_TIMESTAMP() FROM webhooks
+    WHERE (user_id IS NULL OR user_id = ?) AND FIND_IN_SET(?, events) > 0`
+
+	result, err := m.DB.Exec(stmt, event, string(payload), DeliveryPending, ownerID, event)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Due claims up to limit pending deliveries whose time has come, oldest
+// first, counting the attempt about to be made. A claimed delivery isn't
+// due again for ten minutes, so if the sender dies before recording the
+// outcome it's retried, and several instances of the application never
+// send the same delivery at once.
+func (m *WebhookModel) Due(limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.response_status, d.error, d.created, d.updated,
+    w.url, w.secret, w.user_id
+    FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+    WHERE d.status = ? AND d.next_attempt <= UTC_TIMESTAMP()
+    ORDER BY d.next_attempt, d.id LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*WebhookDelivery
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		var userID sql.NullInt64
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt, &d.ResponseStatus, &d.Error, &d.Created, &d.Updated,
+			&d.URL, &d.Secret, &userID)
+		if err != nil {
+			return nil, err
+		}
+		d.UserID = int(userID.Int64)
+		d.Secret, err = openField(m.Keys, d.Secret, "webhooks.secret", d.WebhookID)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var claimed []*WebhookDelivery
+
+	for _, d := range due {
+		// The attempt count doubles as a version, so only one instance
+		// gets to claim each attempt.
+		result, err := m.DB.Exec(`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL 10 MINUTE), updated = UTC_TIMESTAMP()
+    WHERE id = ? AND status = ? AND attempts = ?`, d.ID, DeliveryPending, d.Attempts)
+		if err != nil {
+			return claimed, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return claimed, err
+		}
+		if n == 1 {
+			d.Attempts++
+			claimed = append(claimed, d)
+		}
+	}
+
+	return claimed, nil
+}
+
+// Delivered records that a delivery succeeded.
+func (m *WebhookModel) Delivered(id, responseStatus int) error {
+	_, err := m.DB.Exec(`UPDATE webhook_deliveries SET status = ?, response_status = ?, error = '', updated = UTC_TIMESTAMP() WHERE id = ?`,
+		DeliveryDelivered, responseStatus, id)
+	return err
+}
+
+// Failed records that an attempt at a delivery failed. It's tried again at
+// retryAt, or given up on if retryAt is zero. A zero responseStatus means
+// there was no response at all.
+func (m *WebhookModel) Failed(id, responseStatus int, message string, retryAt time.Time) error {
+	status, next := DeliveryPending, any(retryAt.UTC())
+	if retryAt.IsZero() {
+		status, next = DeliveryFailed, nil
+	}
+
+	if len(message) > 255 {
+		message = message[:255]
+	}
+
+	_, err := m.DB.Exec(`UPDATE webhook_deliveries SET status = ?, response_status = ?, error = ?, next_attempt = COALESCE(?, next_attempt), updated = UTC_TIMESTAMP() WHERE id = ?`,
+		status, responseStatus, message, next, id)
+	return err
+}
+
+// Deliveries returns the most recent deliveries to a webhook, newest first.
+// The caller must check that the webhook is one the user may see.
+func (m *WebhookModel) Deliveries(webhookID, limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status, attempts, next_attempt, response_status, error, created, updated
+    FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt, &d.ResponseStatus, &d.Error, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"github.com/ngohoang211020/snippetbox/internal/models/testutils"
+	"testing"
+	"time"
+)
+
+func TestWebhookModelDeliveries(t *testing.T) {
+	db := testutils.NewTestDB(t)
+	m := WebhookModel{DB: db}
+
+	aliceID, err := m.Insert(&Webhook{UserID: 1, URL: "https://alice.example.com/hook", Secret: "a", Events: []string{"snippet.created"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminID, err := m.Insert(&Webhook{URL: "https://admin.example.com/hook", Secret: "b", Events: []string{"snippet.created", "user.registered"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice can't see the admins' webhook, nor they hers.
+	_, err = m.Get(adminID, 1)
+	assert.Equal(t, err, ErrNoRecord)
+	_, err = m.Get(aliceID, 0)
+	assert.Equal(t, err, ErrNoRecord)
+
+	w, err := m.Get(aliceID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, w.Secret, "a")
+
+	// Alice's snippets reach both webhooks; other users' only the admins'.
+	n, err := m.Enqueue("snippet.created", 1, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 2)
+
+	n, err = m.Enqueue("snippet.created", 2, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	n, err = m.Enqueue("user.registered", 0, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	due, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(due), 4)
+	assert.Equal(t, due[0].Attempts, 1)
+
+	// Claimed deliveries aren't due again until they're retried.
+	again, err := m.Due(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(again), 0)
+
+	for _, d := range due {
+		switch d.WebhookID {
+		case aliceID:
+			err = m.Failed(d.ID, 500, "boom", time.Time{})
+		default:
+			err = m.Delivered(d.ID, 200)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, err := m.Deliveries(aliceID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(deliveries), 1)
+	assert.Equal(t, deliveries[0].Status, DeliveryFailed)
+	assert.Equal(t, deliveries[0].Error, "boom")
+
+	// Deleting a webhook takes its deliveries with it.
+	err = m.Delete(adminID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err = m.Deliveries(adminID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(deliveries), 0)
+}
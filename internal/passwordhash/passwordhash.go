@@ -0,0 +1,178 @@
+// Package passwordhash hashes passwords and checks them against hashes made
+// by any of the schemes it knows, so that the scheme or its parameters can
+// change without anyone's password breaking.
+//
+// Every hash starts with a prefix naming its scheme: bcrypt's own "$2a$" or
+// "$2b$", or "$argon2id$" for Argon2id hashes, which are in the PHC string
+// format. A Hasher hashes new passwords with its Scheme, and Verify says
+// when a password which matched was hashed some other way, so that it can
+// be hashed again while it's at hand.
+package passwordhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var (
+	// ErrMismatch is returned by Verify when the password doesn't match the
+	// hash.
+	ErrMismatch = errors.New("passwordhash: password doesn't match")
+	// ErrUnknownFormat is returned by Verify for hashes made by a scheme it
+	// doesn't know.
+	ErrUnknownFormat = errors.New("passwordhash: unknown hash format")
+)
+
+// Scheme is a way of hashing passwords, with its parameters.
+type Scheme interface {
+	// Hash returns the hash of password, starting with the scheme's prefix.
+	Hash(password string) (string, error)
+	// Compare returns ErrMismatch if password doesn't match hash, which must
+	// have been made by the same scheme, if not with the same parameters.
+	Compare(hash, password string) error
+	// Current reports whether hash was made by this scheme with these
+	// parameters.
+	Current(hash string) bool
+}
+
+// Bcrypt hashes passwords with bcrypt at the given cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b Bcrypt) Compare(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+func (b Bcrypt) Current(hash string) bool {
+	if !isBcrypt(hash) {
+		return false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == b.Cost
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+// argon2idPrefix starts every Argon2id hash.
+const argon2idPrefix = "$argon2id$"
+
+// Argon2id hashes passwords with Argon2id. Memory is in KiB.
+type Argon2id struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Argon2id salts and keys are always this long, in bytes.
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Compare(hash, password string) error {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	got := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a Argon2id) Current(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err == nil && params == a
+}
+
+// parseArgon2id splits an Argon2id hash into its parameters, salt and key.
+func parseArgon2id(hash string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, ErrUnknownFormat
+	}
+	return params, salt, key, nil
+}
+
+// Hasher hashes new passwords with Scheme, and checks passwords against
+// hashes made by any scheme.
+type Hasher struct {
+	Scheme Scheme
+}
+
+// Default is what passwords were always hashed with: bcrypt at cost 12.
+var Default = &Hasher{Scheme: Bcrypt{Cost: 12}}
+
+// Hash returns the hash of password.
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.Scheme.Hash(password)
+}
+
+// Verify returns ErrMismatch if password doesn't match hash. If it does,
+// rehash reports whether the hash was made with another scheme or other
+// parameters than the Hasher's, and should be replaced with a new one.
+func (h *Hasher) Verify(hash, password string) (rehash bool, err error) {
+	var scheme Scheme
+	switch {
+	case isBcrypt(hash):
+		scheme = Bcrypt{}
+	case strings.HasPrefix(hash, argon2idPrefix):
+		scheme = Argon2id{}
+	default:
+		return false, ErrUnknownFormat
+	}
+
+	if err := scheme.Compare(hash, password); err != nil {
+		return false, err
+	}
+	return !h.Scheme.Current(hash), nil
+}
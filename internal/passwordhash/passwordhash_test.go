@@ -0,0 +1,76 @@
+package passwordhash
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+)
+
+// Cheap parameters keep the tests fast.
+var (
+	testBcrypt   = Bcrypt{Cost: 4}
+	testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+)
+
+func TestSchemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme Scheme
+		prefix string
+	}{
+		{"Bcrypt", testBcrypt, "$2a$04$"},
+		{"Argon2id", testArgon2id, "$argon2id$v=19$m=64,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.scheme.Hash("pa$$word")
+			assert.Equal(t, err, nil)
+			assert.Equal(t, strings.HasPrefix(hash, tt.prefix), true)
+
+			assert.Equal(t, tt.scheme.Compare(hash, "pa$$word"), nil)
+			assert.Equal(t, tt.scheme.Compare(hash, "password"), ErrMismatch)
+			assert.Equal(t, tt.scheme.Current(hash), true)
+
+			// The same password hashes differently each time.
+			again, _ := tt.scheme.Hash("pa$$word")
+			assert.Equal(t, again == hash, false)
+		})
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	bcryptHash, _ := testBcrypt.Hash("pa$$word")
+	strongerBcryptHash, _ := Bcrypt{Cost: 5}.Hash("pa$$word")
+	argon2idHash, _ := testArgon2id.Hash("pa$$word")
+	strongerArgon2idHash, _ := Argon2id{Memory: 128, Iterations: 1, Parallelism: 1}.Hash("pa$$word")
+
+	tests := []struct {
+		name       string
+		scheme     Scheme
+		hash       string
+		password   string
+		wantRehash bool
+		wantErr    error
+	}{
+		{"Same bcrypt cost", testBcrypt, bcryptHash, "pa$$word", false, nil},
+		{"Other bcrypt cost", testBcrypt, strongerBcryptHash, "pa$$word", true, nil},
+		{"Bcrypt to Argon2id", testArgon2id, bcryptHash, "pa$$word", true, nil},
+		{"Same Argon2id parameters", testArgon2id, argon2idHash, "pa$$word", false, nil},
+		{"Other Argon2id parameters", testArgon2id, strongerArgon2idHash, "pa$$word", true, nil},
+		{"Argon2id to bcrypt", testBcrypt, argon2idHash, "pa$$word", true, nil},
+		{"Wrong password", testBcrypt, argon2idHash, "password", false, ErrMismatch},
+		{"Unknown format", testBcrypt, "5f4dcc3b5aa765d61d8327deb882cf99", "password", false, ErrUnknownFormat},
+		{"Malformed Argon2id", testArgon2id, "$argon2id$v=19$m=64$salt$key", "pa$$word", false, ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hasher{Scheme: tt.scheme}
+
+			rehash, err := h.Verify(tt.hash, tt.password)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, rehash, tt.wantRehash)
+		})
+	}
+}
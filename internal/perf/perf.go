@@ -0,0 +1,191 @@
+// Package perf keeps rolling measurements of how long things take, like
+// requests and database queries, in memory, for the admin performance
+// dashboard. Nothing is sent anywhere or kept across restarts: it's for
+// seeing how the server is doing now, without setting up any tooling.
+//
+// Measurements are kept in series, one for each route or statement, each of
+// which is a ring buffer of the latest samples. Summaries are worked out
+// from the samples when they're asked for.
+package perf
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Other is the series which samples are recorded in once a Store has as many
+// series as it keeps.
+const Other = "(other)"
+
+// Sample is one measurement.
+type Sample struct {
+	At       time.Time
+	Duration time.Duration
+	Failed   bool
+}
+
+// series is a ring buffer of a series' latest samples. It grows up to its
+// size and then overwrites its oldest sample.
+type series struct {
+	samples []Sample
+	next    int
+}
+
+func (s *series) add(sample Sample, size int) {
+	if len(s.samples) < size {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % size
+}
+
+// Store keeps the latest samples of a number of series. It's safe for
+// concurrent use.
+type Store struct {
+	size      int
+	maxSeries int
+
+	mu     sync.Mutex
+	series map[string]*series
+
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+// NewStore returns a Store which keeps up to size samples in each of up to
+// maxSeries series.
+func NewStore(size, maxSeries int) *Store {
+	return &Store{
+		size:      size,
+		maxSeries: maxSeries,
+		series:    make(map[string]*series),
+		now:       time.Now,
+	}
+}
+
+// Record adds a sample to the named series. Once the Store has as many
+// series as it keeps, samples for new ones are added to Other.
+func (s *Store) Record(name string, d time.Duration, failed bool) {
+	sample := Sample{At: s.now(), Duration: d, Failed: failed}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ser, ok := s.series[name]
+	if !ok {
+		if len(s.series) >= s.maxSeries {
+			name = Other
+			ser = s.series[name]
+		}
+		if ser == nil {
+			ser = &series{}
+			s.series[name] = ser
+		}
+	}
+	ser.add(sample, s.size)
+}
+
+// Summary describes a series' samples over a window of time.
+type Summary struct {
+	Name   string
+	Count  int
+	Failed int
+	P50    time.Duration
+	P95    time.Duration
+	Max    time.Duration
+	Total  time.Duration
+}
+
+// ErrorRate returns the fraction of the samples which failed.
+func (s Summary) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Count)
+}
+
+// Summaries describes each series which has samples from within window of
+// now, slowest first by their 95th percentile. As only the latest samples
+// of each series are kept, a busy series' summary may cover less than the
+// window.
+func (s *Store) Summaries(window time.Duration) []Summary {
+	since := s.now().Add(-window)
+
+	s.mu.Lock()
+	recent := make(map[string][]Sample, len(s.series))
+	for name, ser := range s.series {
+		for _, sample := range ser.samples {
+			if !sample.At.Before(since) {
+				recent[name] = append(recent[name], sample)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(recent))
+	for name, samples := range recent {
+		summaries = append(summaries, summarize(name, samples))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].P95 != summaries[j].P95 {
+			return summaries[i].P95 > summaries[j].P95
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// Total summarizes the samples of every series together.
+func (s *Store) Total(window time.Duration) Summary {
+	since := s.now().Add(-window)
+
+	s.mu.Lock()
+	var samples []Sample
+	for _, ser := range s.series {
+		for _, sample := range ser.samples {
+			if !sample.At.Before(since) {
+				samples = append(samples, sample)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	return summarize("", samples)
+}
+
+func summarize(name string, samples []Sample) Summary {
+	sum := Summary{Name: name, Count: len(samples)}
+
+	durations := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		durations[i] = sample.Duration
+		sum.Total += sample.Duration
+		if sample.Failed {
+			sum.Failed++
+		}
+	}
+	slices.Sort(durations)
+
+	sum.P50 = Percentile(durations, 50)
+	sum.P95 = Percentile(durations, 95)
+	if len(durations) > 0 {
+		sum.Max = durations[len(durations)-1]
+	}
+	return sum
+}
+
+// Percentile returns the pth percentile of sorted durations, by the nearest
+// rank method, or 0 if there are none.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// The nearest rank is the smallest one with at least p% of the values
+	// at or below it.
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
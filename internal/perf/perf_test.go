@@ -0,0 +1,78 @@
+package perf
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"math"
+	"runtime/metrics"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := NewStore(4, 2)
+	s.now = func() time.Time { return now }
+
+	for i := 1; i <= 6; i++ {
+		s.Record("GET home", time.Duration(i)*time.Millisecond, i == 6)
+	}
+	s.Record("GET snippet.view", 20*time.Millisecond, false)
+
+	// Only the latest 4 samples of each series are kept.
+	summaries := s.Summaries(time.Minute)
+	assert.Equal(t, len(summaries), 2)
+	assert.Equal(t, summaries[0], Summary{Name: "GET snippet.view", Count: 1, P50: 20 * time.Millisecond, P95: 20 * time.Millisecond, Max: 20 * time.Millisecond, Total: 20 * time.Millisecond})
+	assert.Equal(t, summaries[1], Summary{Name: "GET home", Count: 4, Failed: 1, P50: 4 * time.Millisecond, P95: 6 * time.Millisecond, Max: 6 * time.Millisecond, Total: 18 * time.Millisecond})
+	assert.Equal(t, summaries[1].ErrorRate(), 0.25)
+
+	// Series beyond the limit are lumped together.
+	s.Record("GET about", time.Millisecond, false)
+	s.Record("GET changelog", time.Millisecond, false)
+	summaries = s.Summaries(time.Minute)
+	assert.Equal(t, len(summaries), 3)
+	assert.Equal(t, summaries[2].Name, Other)
+	assert.Equal(t, summaries[2].Count, 2)
+
+	// Samples older than the window are left out.
+	now = now.Add(2 * time.Minute)
+	s.Record("GET home", time.Second, false)
+	summaries = s.Summaries(time.Minute)
+	assert.Equal(t, len(summaries), 1)
+	assert.Equal(t, summaries[0].Count, 1)
+
+	total := s.Total(time.Hour)
+	assert.Equal(t, total.Count, 7)
+	assert.Equal(t, total.Max, time.Second)
+}
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i))
+	}
+
+	assert.Equal(t, Percentile(nil, 50), time.Duration(0))
+	assert.Equal(t, Percentile(durations, 50), time.Duration(50))
+	assert.Equal(t, Percentile(durations, 95), time.Duration(95))
+	assert.Equal(t, Percentile(durations, 100), time.Duration(100))
+	assert.Equal(t, Percentile(durations, 0), time.Duration(1))
+	assert.Equal(t, Percentile(durations[:1], 95), time.Duration(1))
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{5, 4, 1},
+		Buckets: []float64{0, 0.001, 0.01, math.Inf(1)},
+	}
+	assert.Equal(t, histogramPercentile(h, 50), time.Millisecond)
+	assert.Equal(t, histogramPercentile(h, 90), 10*time.Millisecond)
+	assert.Equal(t, histogramPercentile(h, 95), 10*time.Millisecond)
+	assert.Equal(t, histogramPercentile(&metrics.Float64Histogram{Buckets: []float64{0}}, 50), time.Duration(0))
+}
+
+func TestReadRuntime(t *testing.T) {
+	r := ReadRuntime()
+	assert.Equal(t, r.Goroutines > 0, true)
+	assert.Equal(t, r.HeapBytes > 0, true)
+}
@@ -0,0 +1,104 @@
+package perf
+
+import (
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+// Runtime is what the Go runtime says about the process: its goroutines,
+// its heap and how much the garbage collector is costing.
+type Runtime struct {
+	Goroutines uint64
+	HeapBytes  uint64
+	HeapGoal   uint64
+	GCCycles   uint64
+	// GCPauseP50 and GCPauseP95 are over every pause since the process
+	// started.
+	GCPauseP50 time.Duration
+	GCPauseP95 time.Duration
+	// GCCPUSeconds is the CPU time the garbage collector has used, out of
+	// TotalCPUSeconds.
+	GCCPUSeconds    float64
+	TotalCPUSeconds float64
+}
+
+// GCCPUFraction returns the fraction of the CPU time which went on garbage
+// collection.
+func (r Runtime) GCCPUFraction() float64 {
+	if r.TotalCPUSeconds == 0 {
+		return 0
+	}
+	return r.GCCPUSeconds / r.TotalCPUSeconds
+}
+
+// ReadRuntime reads the runtime's metrics. Any the runtime doesn't support
+// are left zero.
+func ReadRuntime() Runtime {
+	samples := []metrics.Sample{
+		{Name: "/sched/goroutines:goroutines"},
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/gc/heap/goal:bytes"},
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/gc/pauses:seconds"},
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+
+	var r Runtime
+	r.Goroutines = uint64Value(samples[0].Value)
+	r.HeapBytes = uint64Value(samples[1].Value)
+	r.HeapGoal = uint64Value(samples[2].Value)
+	r.GCCycles = uint64Value(samples[3].Value)
+	if samples[4].Value.Kind() == metrics.KindFloat64Histogram {
+		pauses := samples[4].Value.Float64Histogram()
+		r.GCPauseP50 = histogramPercentile(pauses, 50)
+		r.GCPauseP95 = histogramPercentile(pauses, 95)
+	}
+	r.GCCPUSeconds = float64Value(samples[5].Value)
+	r.TotalCPUSeconds = float64Value(samples[6].Value)
+	return r
+}
+
+func uint64Value(v metrics.Value) uint64 {
+	if v.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return v.Uint64()
+}
+
+func float64Value(v metrics.Value) float64 {
+	if v.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return v.Float64()
+}
+
+// histogramPercentile returns the upper bound of the bucket the pth
+// percentile of a histogram of seconds falls in, or its lower bound for the
+// last bucket, which has no upper one.
+func histogramPercentile(h *metrics.Float64Histogram, p float64) time.Duration {
+	var total uint64
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	var seen uint64
+	for i, n := range h.Counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		bound := h.Buckets[i+1]
+		if math.IsInf(bound, 1) {
+			bound = h.Buckets[i]
+		}
+		return time.Duration(bound * float64(time.Second))
+	}
+	return 0
+}
@@ -0,0 +1,196 @@
+// Package pow issues and checks proof-of-work challenges: puzzles which take
+// a browser a second or two to solve, but the server no time at all to
+// check. They're a way of making a suspicious client prove it's willing to
+// spend some effort before going on, without sending visitors to a
+// third-party CAPTCHA which would track them.
+//
+// A challenge is a nonce, signed by the server so that it doesn't need to
+// remember the challenges it has issued. Solving it means finding a solution
+// such that the SHA-256 hash of the nonce, a colon and the solution starts
+// with at least Difficulty zero bits. Each challenge expires after a while,
+// and can only be solved once.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired challenges are forgotten.
+const sweepInterval = time.Minute
+
+var (
+	// ErrInvalid is returned by Verify for nonces which weren't issued by the
+	// Issuer.
+	ErrInvalid = errors.New("pow: invalid challenge")
+	// ErrExpired is returned by Verify for challenges which have expired.
+	ErrExpired = errors.New("pow: challenge has expired")
+	// ErrUsed is returned by Verify for challenges which were already
+	// solved.
+	ErrUsed = errors.New("pow: challenge has already been used")
+	// ErrWrong is returned by Verify for solutions which don't solve the
+	// challenge.
+	ErrWrong = errors.New("pow: wrong solution")
+)
+
+// Challenge is a puzzle for the client to solve.
+type Challenge struct {
+	Nonce      string
+	Difficulty int
+}
+
+// Issuer issues challenges and verifies their solutions. It is safe for
+// concurrent use.
+type Issuer struct {
+	// Difficulty is the number of leading zero bits the hash of a solution
+	// needs. Each one more doubles how long solving takes.
+	Difficulty int
+	// TTL is how long a challenge can be solved for.
+	TTL time.Duration
+
+	key []byte
+
+	mu        sync.Mutex
+	used      map[string]time.Time
+	lastSweep time.Time
+
+	// now is replaceable in tests.
+	now func() time.Time
+}
+
+// New returns an issuer of challenges with the given difficulty, which
+// expire after ttl. The challenges are signed with a random key, so they
+// can't be solved once the issuer is gone.
+func New(difficulty int, ttl time.Duration) *Issuer {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	return &Issuer{
+		Difficulty: difficulty,
+		TTL:        ttl,
+		key:        key,
+		used:       make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+// Issue returns a new challenge.
+func (iss *Issuer) Issue() Challenge {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	expires := iss.now().Add(iss.TTL).Unix()
+	payload := strconv.FormatInt(expires, 10) + "." + strconv.Itoa(iss.Difficulty) + "." + base64.RawURLEncoding.EncodeToString(b)
+
+	return Challenge{
+		Nonce:      payload + "." + iss.sign(payload),
+		Difficulty: iss.Difficulty,
+	}
+}
+
+// Verify checks that solution solves the challenge with the given nonce, and
+// uses the challenge up if it does.
+func (iss *Issuer) Verify(nonce, solution string) error {
+	payload, sig, ok := cut(nonce)
+	if !ok || !hmac.Equal([]byte(sig), []byte(iss.sign(payload))) {
+		return ErrInvalid
+	}
+
+	parts := strings.SplitN(payload, ".", 3)
+	if len(parts) != 3 {
+		return ErrInvalid
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ErrInvalid
+	}
+
+	now := iss.now()
+	if now.Unix() >= expires {
+		return ErrExpired
+	}
+
+	if !Solves(nonce, solution, difficulty) {
+		return ErrWrong
+	}
+
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	iss.sweep(now)
+
+	if _, ok := iss.used[nonce]; ok {
+		return ErrUsed
+	}
+	iss.used[nonce] = time.Unix(expires, 0)
+	return nil
+}
+
+// Solves reports whether solution solves the challenge with the given nonce
+// and difficulty.
+func Solves(nonce, solution string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(nonce + ":" + solution))
+
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			zeros += bits.LeadingZeros8(b)
+			break
+		}
+		zeros += 8
+	}
+	return zeros >= difficulty
+}
+
+// Solve finds a solution to the challenge, the way a client would. It's
+// meant for tests and scripts; browsers solve challenges with JavaScript.
+func Solve(c Challenge) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if Solves(c.Nonce, solution, c.Difficulty) {
+			return solution
+		}
+	}
+}
+
+func (iss *Issuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, iss.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cut splits a nonce into its payload and signature.
+func cut(nonce string) (payload, sig string, ok bool) {
+	i := strings.LastIndexByte(nonce, '.')
+	if i < 0 {
+		return "", "", false
+	}
+	return nonce[:i], nonce[i+1:], true
+}
+
+// sweep forgets the used challenges which have expired, since they can't be
+// solved again anyway. It must be called with mu held.
+func (iss *Issuer) sweep(now time.Time) {
+	if now.Sub(iss.lastSweep) < sweepInterval {
+		return
+	}
+	iss.lastSweep = now
+
+	for nonce, expires := range iss.used {
+		if !now.Before(expires) {
+			delete(iss.used, nonce)
+		}
+	}
+}
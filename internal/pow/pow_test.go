@@ -0,0 +1,72 @@
+package pow
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssuer(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	iss := New(8, time.Minute)
+	iss.now = func() time.Time { return now }
+
+	c := iss.Issue()
+	assert.Equal(t, c.Difficulty, 8)
+
+	solution := Solve(c)
+	assert.Equal(t, Solves(c.Nonce, solution, 8), true)
+
+	// A solution which doesn't hash to enough zeros is wrong.
+	wrong := "x"
+	for Solves(c.Nonce, wrong, 8) {
+		wrong += "x"
+	}
+	assert.Equal(t, iss.Verify(c.Nonce, wrong), ErrWrong)
+
+	// The right one is accepted, but only once.
+	assert.Equal(t, iss.Verify(c.Nonce, solution), nil)
+	assert.Equal(t, iss.Verify(c.Nonce, solution), ErrUsed)
+
+	// Challenges expire.
+	c = iss.Issue()
+	solution = Solve(c)
+	now = now.Add(time.Minute)
+	assert.Equal(t, iss.Verify(c.Nonce, solution), ErrExpired)
+
+	// Nonces which weren't issued, or were tampered with, are rejected: a
+	// client can't make a challenge easier.
+	c = iss.Issue()
+	easier := strings.Replace(c.Nonce, ".8.", ".0.", 1)
+	assert.Equal(t, iss.Verify(easier, "0"), ErrInvalid)
+	assert.Equal(t, iss.Verify("nonsense", "0"), ErrInvalid)
+	assert.Equal(t, iss.Verify(New(8, time.Minute).Issue().Nonce, "0"), ErrInvalid)
+
+	// Used challenges are forgotten once they've expired.
+	assert.Equal(t, iss.Verify(c.Nonce, Solve(c)), nil)
+	now = now.Add(2 * time.Minute)
+	c = iss.Issue()
+	assert.Equal(t, iss.Verify(c.Nonce, Solve(c)), nil)
+	assert.Equal(t, len(iss.used), 1)
+}
+
+func TestSolves(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty int
+		want       bool
+	}{
+		{"Zero", 0, true},
+		{"Easy", 4, true},
+		{"Impossible", 257, false},
+	}
+
+	// sha256("a:0") starts with 0x0e, which has four leading zero bits.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Solves("a", "0", tt.difficulty), tt.want)
+		})
+	}
+}
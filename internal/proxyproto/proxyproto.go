@@ -0,0 +1,212 @@
+// Package proxyproto implements the receiving side of the HAProxy PROXY
+// protocol (versions 1 and 2), which L4 load balancers use to pass on the
+// address of the original client when forwarding a TCP connection.
+//
+// See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt for the
+// specification.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v2Signature is the fixed 12 byte prefix of every version 2 header.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrInvalidHeader is returned when a connection does not start with a valid
+// PROXY protocol header.
+var ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+
+// Listener wraps a net.Listener so that every accepted connection must start
+// with a PROXY protocol header. The header is consumed, and the RemoteAddr of
+// the returned connections is the client address it contains.
+type Listener struct {
+	net.Listener
+
+	// HeaderTimeout is how long to wait for the header after a connection
+	// has been accepted. It defaults to 5 seconds.
+	HeaderTimeout time.Duration
+}
+
+// Accept waits for and returns the next connection. The header itself is
+// read lazily on the first call to Read or RemoteAddr, so that a slow client
+// can't hold up the accept loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := l.HeaderTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &Conn{Conn: c, r: bufio.NewReader(c), timeout: timeout}, nil
+}
+
+// Conn is a connection accepted by Listener.
+type Conn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+
+		c.remoteAddr, c.err = ReadHeader(c.r)
+		if c.err != nil {
+			// The connection is useless once the header is broken, so close
+			// it rather than serving a request with the wrong client address.
+			c.Conn.Close()
+		}
+	})
+}
+
+// Read reads data from the connection, after the PROXY header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header. If the header
+// was a LOCAL command (such as a health check from the load balancer itself)
+// or could not be read, it returns the address of the peer instead.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr == nil {
+		return c.Conn.RemoteAddr()
+	}
+	return c.remoteAddr
+}
+
+// ReadHeader reads a version 1 or version 2 PROXY protocol header from r and
+// returns the source address it contains. A nil address with a nil error
+// means the header was valid but didn't carry an address, as is the case for
+// "PROXY UNKNOWN" and LOCAL commands.
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	prefix, err := r.Peek(len(v2Signature))
+	if err != nil && !(errors.Is(err, io.EOF) && len(prefix) > 0) {
+		return nil, err
+	}
+
+	if bytes.Equal(prefix, v2Signature) {
+		return readV2(r)
+	}
+	if bytes.HasPrefix(prefix, []byte("PROXY ")) {
+		return readV1(r)
+	}
+
+	return nil, ErrInvalidHeader
+}
+
+// readV1 parses the human-readable header, such as:
+//
+//	PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	// The longest possible version 1 header is 107 bytes.
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidHeader
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 parses the binary header.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	version, command := header[12]>>4, header[12]&0x0F
+	if version != 2 || command > 1 {
+		return nil, ErrInvalidHeader
+	}
+
+	family := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL connections come from the proxy itself and carry no address.
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if length < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:4]),
+			Port: int(binary.BigEndian.Uint16(payload[8:10])),
+		}, nil
+	case 0x21: // TCP over IPv6
+		if length < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{
+			IP:   net.IP(payload[0:16]),
+			Port: int(binary.BigEndian.Uint16(payload[32:34])),
+		}, nil
+	case 0x00: // UNSPEC
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("proxyproto: unsupported address family 0x%02x", family)
+	}
+}
@@ -0,0 +1,119 @@
+package proxyproto
+
+import (
+	"bufio"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	v2IPv4 := string(v2Signature) + "\x21\x11\x00\x0c" +
+		"\xc0\x00\x02\x01" + "\xc6\x33\x64\x01" + "\xdc\x04" + "\x01\xbb"
+	v2Local := string(v2Signature) + "\x20\x00\x00\x00"
+
+	tests := []struct {
+		name     string
+		header   string
+		wantAddr string
+		wantErr  bool
+	}{
+		{
+			name:     "v1 TCP4",
+			header:   "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+			wantAddr: "192.0.2.1:56324",
+		},
+		{
+			name:     "v1 TCP6",
+			header:   "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+			wantAddr: "[2001:db8::1]:56324",
+		},
+		{
+			name:   "v1 UNKNOWN",
+			header: "PROXY UNKNOWN\r\n",
+		},
+		{
+			name:    "v1 family mismatch",
+			header:  "PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "v1 missing CRLF",
+			header:  "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n",
+			wantErr: true,
+		},
+		{
+			name:     "v2 TCP4",
+			header:   v2IPv4,
+			wantAddr: "192.0.2.1:56324",
+		},
+		{
+			name:   "v2 LOCAL",
+			header: v2Local,
+		},
+		{
+			name:    "No header",
+			header:  "GET / HTTP/1.1\r\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.header + "rest"))
+
+			addr, err := ReadHeader(r)
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantAddr == "" {
+				assert.Equal(t, addr, nil)
+			} else {
+				assert.Equal(t, addr.String(), tt.wantAddr)
+			}
+
+			// The header must be consumed exactly, leaving the data after it.
+			rest, _ := io.ReadAll(r)
+			assert.Equal(t, string(rest), "rest")
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := &Listener{Listener: ln}
+	defer pl.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.WriteString(c, "PROXY TCP4 203.0.113.7 198.51.100.1 1234 443\r\nhello")
+	}()
+
+	c, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	assert.Equal(t, c.RemoteAddr().String(), "203.0.113.7:1234")
+
+	body, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(body), "hello")
+}
@@ -0,0 +1,153 @@
+// Package publicid makes the IDs which snippets are known by outside the
+// database, in URLs and the API. The database's own auto-increment IDs
+// count up one at a time, so anyone with one snippet's link could find
+// every other snippet by counting; public IDs are mostly random, and can't
+// be guessed from each other.
+//
+// Two kinds of ID can be made, both of which start with the time they were
+// made in milliseconds, so that they sort in the order they were made and
+// keep the database's index on them compact:
+//
+//   - UUIDv7 (RFC 9562), like 01890a5d-ac96-774b-bcce-b302099a8057, with
+//     74 random bits.
+//   - ULID, like 01ARZ3NDEKTSV4RRFFQ69G5FAV, with 80 random bits, written
+//     in Crockford's base 32.
+//
+// Which kind is made can be changed at any time, as both kinds are always
+// accepted.
+package publicid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Strategy is the kind of ID which New makes.
+type Strategy string
+
+const (
+	UUIDv7 Strategy = "uuidv7"
+	ULID   Strategy = "ulid"
+)
+
+// Default is the strategy used unless another is chosen.
+const Default = UUIDv7
+
+// Parse returns the strategy with the given name, which is "uuidv7" or
+// "ulid".
+func Parse(name string) (Strategy, error) {
+	switch s := Strategy(strings.ToLower(name)); s {
+	case UUIDv7, ULID:
+		return s, nil
+	default:
+		return "", fmt.Errorf("publicid: unknown strategy %q; use %q or %q", name, UUIDv7, ULID)
+	}
+}
+
+// New makes a new ID of the strategy's kind. The zero Strategy makes
+// Default ones.
+func (s Strategy) New() string {
+	return s.newAt(time.Now())
+}
+
+func (s Strategy) newAt(t time.Time) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// The system's random number generator doesn't fail in practice,
+		// and nothing sensible can be done without it.
+		panic(fmt.Sprintf("publicid: reading random bytes: %v", err))
+	}
+
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+
+	if s == ULID {
+		return encodeULID(b)
+	}
+
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+	return formatUUID(b)
+}
+
+// Normalize returns id written the way New writes it, and whether it's an
+// ID at all. Both kinds are read whatever the strategy: UUIDs in lower
+// case and ULIDs in upper case, with the letters ULIDs leave out read as
+// the digits they look like.
+func Normalize(id string) (string, bool) {
+	switch len(id) {
+	case 36:
+		if id[8] != '-' || id[13] != '-' || id[18] != '-' || id[23] != '-' {
+			return "", false
+		}
+		var b [16]byte
+		if _, err := hex.Decode(b[:], []byte(id[:8]+id[9:13]+id[14:18]+id[19:23]+id[24:])); err != nil {
+			return "", false
+		}
+		return formatUUID(b), true
+
+	case 26:
+		// The first character only has 3 bits to give, as a ULID is 128
+		// bits long.
+		if id[0] > '7' {
+			return "", false
+		}
+		var b strings.Builder
+		for i := 0; i < len(id); i++ {
+			c := crockfordValue(id[i])
+			if c < 0 {
+				return "", false
+			}
+			b.WriteByte(crockford[c])
+		}
+		return b.String(), true
+	}
+
+	return "", false
+}
+
+func formatUUID(b [16]byte) string {
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// crockford is Crockford's base 32 alphabet, which leaves out I, L, O and
+// U so that IDs can be read out and typed in without mix-ups.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// encodeULID writes 128 bits as 26 characters of 5 bits each, the first of
+// which only has 3.
+func encodeULID(b [16]byte) string {
+	var out [26]byte
+	// Work from the last 5 bits to the first, shifting the whole 128 bits
+	// right as we go.
+	hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 | uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+	lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 | uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// crockfordValue returns the value of a character in Crockford's base 32,
+// in either case and reading I and L as 1 and O as 0, or -1 if it isn't
+// one.
+func crockfordValue(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	switch c {
+	case 'I', 'L':
+		c = '1'
+	case 'O':
+		c = '0'
+	}
+	return strings.IndexByte(crockford, c)
+}
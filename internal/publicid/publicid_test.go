@@ -0,0 +1,99 @@
+package publicid
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	// The examples' timestamps from RFC 9562 and the ULID spec.
+	id := UUIDv7.newAt(time.UnixMilli(0x017F22E279B0))
+	assert.Equal(t, len(id), 36)
+	assert.Equal(t, id[:15], "017f22e2-79b0-7")
+	assert.Equal(t, strings.ContainsAny(id[19:20], "89ab"), true)
+
+	id = ULID.newAt(time.UnixMilli(1469918176385))
+	assert.Equal(t, len(id), 26)
+	assert.Equal(t, id[:10], "01ARYZ6S41")
+
+	// The zero strategy is the default.
+	assert.Equal(t, len(Strategy("").New()), 36)
+
+	for _, s := range []Strategy{UUIDv7, ULID} {
+		t.Run(string(s), func(t *testing.T) {
+			seen := map[string]bool{}
+			var ids []string
+			for i := 0; i < 1000; i++ {
+				id := s.newAt(time.UnixMilli(int64(1700000000000 + i)))
+				if seen[id] {
+					t.Fatalf("%s made twice", id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+
+				normalized, ok := Normalize(id)
+				assert.Equal(t, ok, true)
+				assert.Equal(t, normalized, id)
+			}
+
+			// IDs made later sort later.
+			assert.Equal(t, sort.StringsAreSorted(ids), true)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		id     string
+		want   string
+		wantOK bool
+	}{
+		{"017F22E2-79B0-7CC3-98C4-DC0C0C07398F", "017f22e2-79b0-7cc3-98c4-dc0c0c07398f", true},
+		{"01arz3ndektsv4rrffq69g5fav", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAo", "01ARZ3NDEKTSV4RRFFQ69G5FA0", true},
+		{"81ARZ3NDEKTSV4RRFFQ69G5FAV", "", false},
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAU", "", false},
+		{"017f22e2-79b0-7cc3-98c4-dc0c0c07398g", "", false},
+		{"017f22e279b07cc398c4dc0c0c07398f", "", false},
+		{"017f22e2+79b0-7cc3-98c4-dc0c0c07398f", "", false},
+		{"1", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Normalize(tt.id)
+		assert.Equal(t, got, tt.want)
+		assert.Equal(t, ok, tt.wantOK)
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("ULID")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, s, ULID)
+
+	_, err = Parse("snowflake")
+	assert.StringContains(t, err.Error(), `unknown strategy "snowflake"`)
+}
+
+// FuzzNormalize checks that whatever Normalize accepts, it accepts again
+// unchanged.
+func FuzzNormalize(f *testing.F) {
+	f.Add("017f22e2-79b0-7cc3-98c4-dc0c0c07398f")
+	f.Add("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	f.Add("1")
+
+	f.Fuzz(func(t *testing.T, id string) {
+		normalized, ok := Normalize(id)
+		if !ok {
+			return
+		}
+		again, ok := Normalize(normalized)
+		if !ok || again != normalized {
+			t.Fatalf("Normalize(%q) = %q, which normalizes to %q, %v", id, normalized, again, ok)
+		}
+	})
+}
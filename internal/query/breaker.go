@@ -0,0 +1,192 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of running a statement while a
+// Breaker is open.
+var ErrCircuitOpen = errors.New("query: database unavailable (circuit breaker open)")
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every statement through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every statement straight away with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets one statement through to see whether the
+	// database is back.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker for a database. After Threshold errors in a
+// row it opens, failing statements straight away rather than leaving them to
+// pile up against a database that's down. Once Cooldown has passed it lets a
+// single statement through: if that works it closes again, and if not it
+// stays open for another Cooldown. A nil *Breaker is always closed. Breaker
+// is safe for concurrent use.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	// Ignore reports whether an error says nothing about the health of the
+	// database, such as a duplicate key. Ignored errors count as successes.
+	// sql.ErrNoRows and sql.ErrTxDone are always ignored.
+	Ignore func(error) bool
+
+	// OnChange, if set, is called whenever the breaker opens or closes, for
+	// logging. It's called without the breaker locked.
+	OnChange func(BreakerState)
+
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trips    int64
+	trial    bool
+}
+
+// NewBreaker returns a closed breaker which opens after threshold errors in
+// a row, for cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// BreakerStats describe a Breaker, for health checks and metrics.
+type BreakerStats struct {
+	State BreakerState
+	// Failures is the number of errors in a row so far.
+	Failures int
+	// Trips is the number of times the breaker has opened.
+	Trips int64
+	// RetryAt is when an open breaker will next let a statement through.
+	// It's zero unless the breaker is open.
+	RetryAt time.Time
+}
+
+// Stats returns the breaker's state. An open breaker whose cooldown is over
+// is reported as half-open, since the next statement will be let through.
+func (b *Breaker) Stats() BreakerStats {
+	if b == nil {
+		return BreakerStats{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{State: b.state, Failures: b.failures, Trips: b.trips}
+	if b.state == BreakerOpen {
+		stats.RetryAt = b.openedAt.Add(b.Cooldown)
+		if !b.now().Before(stats.RetryAt) {
+			stats.State = BreakerHalfOpen
+			stats.RetryAt = time.Time{}
+		}
+	}
+	return stats
+}
+
+// Allow returns ErrCircuitOpen if a statement mustn't be run now. Every
+// statement that is allowed must have its outcome passed to Record.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.Cooldown)) {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return nil
+	case BreakerHalfOpen:
+		// Only one statement at a time finds out whether the database is
+		// back.
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Record notes the outcome of a statement.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+
+	failed := err != nil && !b.ignored(err)
+
+	b.mu.Lock()
+	before := b.state
+
+	switch {
+	case b.state == BreakerOpen:
+		// A statement started before the breaker opened, such as one in a
+		// transaction, tells us nothing new.
+	case !failed:
+		b.state = BreakerClosed
+		b.failures = 0
+		b.trial = false
+	default:
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+			b.trips++
+			b.trial = false
+		}
+	}
+
+	after := b.state
+	b.mu.Unlock()
+
+	// A failed trial only puts the breaker back the way it was, so it
+	// isn't reported.
+	if b.OnChange != nil && after != before && !(before == BreakerHalfOpen && after == BreakerOpen) {
+		b.OnChange(after)
+	}
+}
+
+func (b *Breaker) ignored(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, sql.ErrTxDone) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	return b.Ignore != nil && b.Ignore(err)
+}
+
+// openCircuit stands in for the database while a breaker is open. A *sql.Row
+// can't be made with an error, so QueryRow runs statements against it, which
+// fails with ErrCircuitOpen without going near the real database.
+var openCircuit = sql.OpenDB(openConnector{})
+
+type openConnector struct{}
+
+func (openConnector) Connect(context.Context) (driver.Conn, error) { return nil, ErrCircuitOpen }
+func (c openConnector) Driver() driver.Driver                      { return c }
+func (openConnector) Open(string) (driver.Conn, error)             { return nil, ErrCircuitOpen }
@@ -0,0 +1,168 @@
+package query
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreaker(t *testing.T) {
+	b, now := newTestBreaker(3, time.Minute)
+
+	// Errors which aren't in a row don't open it.
+	for _, err := range []error{errDown, errDown, nil, errDown, errDown} {
+		assert.Equal(t, b.Allow(), nil)
+		b.Record(err)
+	}
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+	assert.Equal(t, b.Stats().Failures, 2)
+
+	// Nor do errors which say nothing about the database.
+	b.Record(sql.ErrNoRows)
+	b.Record(errDown)
+	b.Record(errDown)
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+
+	b.Record(errDown)
+	stats := b.Stats()
+	assert.Equal(t, stats.State, BreakerOpen)
+	assert.Equal(t, stats.Trips, int64(1))
+	assert.Equal(t, stats.RetryAt, now.Add(time.Minute))
+	assert.Equal(t, b.Allow(), ErrCircuitOpen)
+
+	// After the cooldown one statement is let through at a time, and if it
+	// fails the breaker opens again straight away.
+	*now = now.Add(time.Minute)
+	assert.Equal(t, b.Stats().State, BreakerHalfOpen)
+	assert.Equal(t, b.Allow(), nil)
+	assert.Equal(t, b.Allow(), ErrCircuitOpen)
+	b.Record(errDown)
+	assert.Equal(t, b.Stats().State, BreakerOpen)
+	assert.Equal(t, b.Stats().Trips, int64(2))
+
+	*now = now.Add(time.Minute)
+	assert.Equal(t, b.Allow(), nil)
+	b.Record(nil)
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+	assert.Equal(t, b.Stats().Failures, 0)
+	assert.Equal(t, b.Allow(), nil)
+}
+
+func TestBreakerIgnore(t *testing.T) {
+	errDuplicate := errors.New("duplicate entry")
+
+	b, _ := newTestBreaker(1, time.Minute)
+	b.Ignore = func(err error) bool { return errors.Is(err, errDuplicate) }
+
+	b.Record(errDuplicate)
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+
+	b.Record(errDown)
+	assert.Equal(t, b.Stats().State, BreakerOpen)
+}
+
+func TestBreakerOnChange(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+
+	var changes []string
+	b.OnChange = func(s BreakerState) { changes = append(changes, s.String()) }
+
+	b.Record(errDown)
+	*now = now.Add(time.Minute)
+	b.Allow()
+	b.Record(errDown)
+	*now = now.Add(time.Minute)
+	b.Allow()
+	b.Record(nil)
+
+	assert.Equal(t, strings.Join(changes, " "), "open closed")
+}
+
+func TestBreakerNil(t *testing.T) {
+	var b *Breaker
+	b.Record(errDown)
+	assert.Equal(t, b.Allow(), nil)
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+}
+
+// downDriver is a database driver which can't be connected to while down is
+// set. It counts the attempts.
+type downDriver struct {
+	down     atomic.Bool
+	attempts atomic.Int64
+}
+
+func (d *downDriver) Open(string) (driver.Conn, error) {
+	d.attempts.Add(1)
+	if d.down.Load() {
+		return nil, errDown
+	}
+	return &countingConn{&countingDriver{}}, nil
+}
+
+var testDownDriver = &downDriver{}
+
+func init() {
+	sql.Register("query-test-down", testDownDriver)
+}
+
+func TestDBBreaker(t *testing.T) {
+	pool, err := sql.Open("query-test-down", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxIdleConns(0)
+	t.Cleanup(func() { pool.Close() })
+
+	db := New(pool, nil)
+	b, now := newTestBreaker(2, time.Minute)
+	db.Breaker = b
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	assert.Equal(t, err, nil)
+
+	testDownDriver.down.Store(true)
+	defer testDownDriver.down.Store(false)
+
+	for i := 0; i < 2; i++ {
+		_, err = db.Query("SELECT n FROM t WHERE n = ?", i)
+		assert.Equal(t, errors.Is(err, errDown), true)
+	}
+	assert.Equal(t, b.Stats().State, BreakerOpen)
+
+	// While it's open the database isn't touched.
+	attempts := testDownDriver.attempts.Load()
+
+	_, err = db.Exec("DELETE FROM t")
+	assert.Equal(t, err, ErrCircuitOpen)
+	_, err = db.Begin()
+	assert.Equal(t, err, ErrCircuitOpen)
+	var n int
+	err = db.QueryRow("SELECT n FROM t").Scan(&n)
+	assert.Equal(t, err, ErrCircuitOpen)
+
+	assert.Equal(t, testDownDriver.attempts.Load(), attempts)
+
+	// Once the database is back, the first statement after the cooldown
+	// closes the breaker.
+	testDownDriver.down.Store(false)
+	*now = now.Add(time.Minute)
+
+	_, err = db.Exec("DELETE FROM t")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, b.Stats().State, BreakerClosed)
+}
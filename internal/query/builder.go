@@ -0,0 +1,216 @@
+package query
+
+import (
+	"strings"
+)
+
+// SelectBuilder builds a SELECT statement. Conditions added with Where are
+// joined with AND. The zero value isn't useful; start with Select.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	where   []string
+	args    []any
+	groupBy string
+	having  string
+	orderBy string
+	limit   int
+	offset  int
+	paged   bool
+	lock    bool
+}
+
+// Select starts a SELECT statement for the given columns.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+// From sets the table to select from.
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Where adds a condition, with the arguments for any placeholders in it.
+func (b *SelectBuilder) Where(cond string, args ...any) *SelectBuilder {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// WhereIn adds a condition that column is one of values. An empty list of
+// values matches nothing.
+func (b *SelectBuilder) WhereIn(column string, values []any) *SelectBuilder {
+	if len(values) == 0 {
+		return b.Where("FALSE")
+	}
+	return b.Where(column+" IN ("+placeholders(len(values))+")", values...)
+}
+
+// GroupBy sets the GROUP BY clause.
+func (b *SelectBuilder) GroupBy(expr string) *SelectBuilder {
+	b.groupBy = expr
+	return b
+}
+
+// Having sets the HAVING clause. It mustn't contain placeholders.
+func (b *SelectBuilder) Having(cond string) *SelectBuilder {
+	b.having = cond
+	return b
+}
+
+// OrderBy sets the ORDER BY clause.
+func (b *SelectBuilder) OrderBy(expr string) *SelectBuilder {
+	b.orderBy = expr
+	return b
+}
+
+// Page limits the statement to limit rows, starting after the first offset.
+// Both are passed as arguments, so pages of any size share a statement.
+func (b *SelectBuilder) Page(limit, offset int) *SelectBuilder {
+	b.limit, b.offset, b.paged = limit, offset, true
+	return b
+}
+
+// ForUpdate locks the selected rows until the end of the transaction.
+func (b *SelectBuilder) ForUpdate() *SelectBuilder {
+	b.lock = true
+	return b
+}
+
+// Count returns a statement which counts the rows this one would return,
+// ignoring its order and page.
+func (b *SelectBuilder) Count() *SelectBuilder {
+	return &SelectBuilder{
+		columns: []string{"COUNT(*)"},
+		from:    b.from,
+		where:   b.where,
+		args:    b.args,
+		groupBy: b.groupBy,
+		having:  b.having,
+	}
+}
+
+// Build returns the statement and its arguments, with ? placeholders.
+// Passing the statement to a DB rebinds them for its dialect.
+func (b *SelectBuilder) Build() (string, []any) {
+	var sb strings.Builder
+
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(b.columns, ", "))
+	sb.WriteString(" FROM ")
+	sb.WriteString(b.from)
+
+	if len(b.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(b.where, " AND "))
+	}
+	if b.groupBy != "" {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(b.groupBy)
+	}
+	if b.having != "" {
+		sb.WriteString(" HAVING ")
+		sb.WriteString(b.having)
+	}
+	if b.orderBy != "" {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(b.orderBy)
+	}
+
+	args := append([]any(nil), b.args...)
+
+	if b.paged {
+		sb.WriteString(" LIMIT ? OFFSET ?")
+		args = append(args, b.limit, b.offset)
+	}
+	if b.lock {
+		sb.WriteString(" FOR UPDATE")
+	}
+
+	return sb.String(), args
+}
+
+// InsertBuilder builds an INSERT statement for a single row.
+type InsertBuilder struct {
+	table   string
+	columns []string
+	values  []string
+	args    []any
+}
+
+// Insert starts an INSERT statement into the given table.
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+// Set sets a column to a value.
+func (b *InsertBuilder) Set(column string, value any) *InsertBuilder {
+	return b.SetExpr(column, "?", value)
+}
+
+// SetExpr sets a column to an SQL expression, with the arguments for any
+// placeholders in it.
+func (b *InsertBuilder) SetExpr(column, expr string, args ...any) *InsertBuilder {
+	b.columns = append(b.columns, column)
+	b.values = append(b.values, expr)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Build returns the statement and its arguments.
+func (b *InsertBuilder) Build() (string, []any) {
+	stmt := "INSERT INTO " + b.table + " (" + strings.Join(b.columns, ", ") + ") VALUES (" + strings.Join(b.values, ", ") + ")"
+	return stmt, b.args
+}
+
+// UpdateBuilder builds an UPDATE statement. Conditions added with Where are
+// joined with AND.
+type UpdateBuilder struct {
+	table string
+	sets  []string
+	where []string
+	args  []any
+}
+
+// Update starts an UPDATE statement for the given table.
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+// Set sets a column to a value. It must be called before Where.
+func (b *UpdateBuilder) Set(column string, value any) *UpdateBuilder {
+	return b.SetExpr(column, "?", value)
+}
+
+// SetExpr sets a column to an SQL expression, with the arguments for any
+// placeholders in it. It must be called before Where.
+func (b *UpdateBuilder) SetExpr(column, expr string, args ...any) *UpdateBuilder {
+	if len(b.where) > 0 {
+		panic("query: UpdateBuilder.Set called after Where")
+	}
+	b.sets = append(b.sets, column+" = "+expr)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Where adds a condition, with the arguments for any placeholders in it.
+func (b *UpdateBuilder) Where(cond string, args ...any) *UpdateBuilder {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// Build returns the statement and its arguments.
+func (b *UpdateBuilder) Build() (string, []any) {
+	stmt := "UPDATE " + b.table + " SET " + strings.Join(b.sets, ", ")
+	if len(b.where) > 0 {
+		stmt += " WHERE " + strings.Join(b.where, " AND ")
+	}
+	return stmt, b.args
+}
+
+// placeholders returns n comma-separated placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,61 @@
+package query
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	q := Select("id", "title").
+		From("snippets").
+		Where("expires > "+MySQL.Now).
+		WhereIn("user_id", []any{1, 2}).
+		OrderBy("id DESC").
+		Page(10, 20)
+
+	stmt, args := q.Build()
+	assert.Equal(t, stmt, "SELECT id, title FROM snippets WHERE expires > UTC_TIMESTAMP() AND user_id IN (?,?) ORDER BY id DESC LIMIT ? OFFSET ?")
+	assert.Equal(t, len(args), 4)
+	assert.Equal(t, args[0], any(1))
+	assert.Equal(t, args[3], any(20))
+
+	stmt, args = q.Count().Build()
+	assert.Equal(t, stmt, "SELECT COUNT(*) FROM snippets WHERE expires > UTC_TIMESTAMP() AND user_id IN (?,?)")
+	assert.Equal(t, len(args), 2)
+
+	stmt, _ = Select("id").From("snippets").WhereIn("user_id", nil).Build()
+	assert.Equal(t, stmt, "SELECT id FROM snippets WHERE FALSE")
+
+	stmt, _ = Select("id").From("snippets").Where("id = ?", 1).ForUpdate().Build()
+	assert.Equal(t, stmt, "SELECT id FROM snippets WHERE id = ? FOR UPDATE")
+}
+
+func TestInsertBuilder(t *testing.T) {
+	stmt, args := Insert("users").
+		Set("name", "Alice").
+		SetExpr("expires", Postgres.DaysFromNow("?"), 7).
+		Build()
+
+	assert.Equal(t, stmt, "INSERT INTO users (name, expires) VALUES (?, (NOW() AT TIME ZONE 'UTC' + ? * INTERVAL '1 day'))")
+	assert.Equal(t, len(args), 2)
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	stmt, args := Update("snippets").
+		SetExpr("version", "version + 1").
+		Set("title", "New").
+		Where("id = ?", 3).
+		Build()
+
+	assert.Equal(t, stmt, "UPDATE snippets SET version = version + 1, title = ? WHERE id = ?")
+	assert.Equal(t, len(args), 2)
+	assert.Equal(t, args[0], any("New"))
+}
+
+func TestRebind(t *testing.T) {
+	stmt := "SELECT id FROM t WHERE a = ? AND b <> '?' AND c IN (?, ?)"
+
+	assert.Equal(t, MySQL.Rebind(stmt), stmt)
+	assert.Equal(t, SQLite.Rebind(stmt), stmt)
+	assert.Equal(t, Postgres.Rebind(stmt), "SELECT id FROM t WHERE a = $1 AND b <> '?' AND c IN ($2, $3)")
+}
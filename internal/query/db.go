@@ -0,0 +1,321 @@
+package query
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxStatements is the number of prepared statements a DB keeps by
+// default. Statements built with a variable number of placeholders, like
+// WhereIn, are cached separately for each length, so the cache needs a
+// limit; statements beyond it are run without being prepared first.
+const DefaultMaxStatements = 200
+
+// DB runs statements against a connection pool. Each distinct statement is
+// prepared the first time it's used and the prepared statement is reused
+// after that, which saves a round trip to the database for every query. DB
+// is safe for concurrent use.
+type DB struct {
+	db      *sql.DB
+	dialect *Dialect
+
+	// MaxStatements is the number of prepared statements to keep. It must
+	// not be changed once the DB is in use.
+	MaxStatements int
+
+	// Breaker, if set, stops statements being run while the database is
+	// failing. Statements in transactions count towards opening it, but
+	// aren't stopped by it, so that transactions can finish.
+	Breaker *Breaker
+
+	// Observe, if set, is called once each statement has run, with how
+	// long it took and the error it returned. For statements which return
+	// rows, that's until the first rows are ready. It must not be changed
+	// once the DB is in use.
+	Observe func(stmt string, d time.Duration, err error)
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+
+	queries  atomic.Int64
+	prepares atomic.Int64
+}
+
+// New returns a DB which runs statements against db. A nil dialect means
+// MySQL.
+func New(db *sql.DB, dialect *Dialect) *DB {
+	if dialect == nil {
+		dialect = MySQL
+	}
+	return &DB{
+		db:            db,
+		dialect:       dialect,
+		MaxStatements: DefaultMaxStatements,
+		stmts:         make(map[string]*sql.Stmt),
+	}
+}
+
+// Stats are the counters of a DB, for seeing how busy the database is.
+type Stats struct {
+	// Queries is the number of statements run, including those run in
+	// transactions.
+	Queries int64
+	// Prepares is the number of statements which have been prepared. Once
+	// the cache is warm it should stay still while Queries goes up.
+	Prepares int64
+	// Statements is the number of prepared statements in the cache.
+	Statements int
+}
+
+// Stats returns the DB's counters.
+func (db *DB) Stats() Stats {
+	db.mu.Lock()
+	n := len(db.stmts)
+	db.mu.Unlock()
+
+	return Stats{
+		Queries:    db.queries.Load(),
+		Prepares:   db.prepares.Load(),
+		Statements: n,
+	}
+}
+
+// observe tells Observe, if it's set, about a statement which started at
+// start.
+func (db *DB) observe(stmt string, start time.Time, err error) {
+	if db.Observe != nil {
+		db.Observe(stmt, time.Since(start), err)
+	}
+}
+
+// Dialect returns the dialect statements are rebound for.
+func (db *DB) Dialect() *Dialect {
+	return db.dialect
+}
+
+// Exec runs a statement which doesn't return rows.
+func (db *DB) Exec(stmt string, args ...any) (sql.Result, error) {
+	if err := db.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result, err := db.exec(stmt, args...)
+	db.Breaker.Record(err)
+	db.observe(stmt, start, err)
+	return result, err
+}
+
+func (db *DB) exec(stmt string, args ...any) (sql.Result, error) {
+	db.queries.Add(1)
+
+	s, err := db.prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return db.db.Exec(db.dialect.Rebind(stmt), args...)
+	}
+	return s.Exec(args...)
+}
+
+// Query runs a statement which returns rows.
+func (db *DB) Query(stmt string, args ...any) (*sql.Rows, error) {
+	if err := db.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := db.query(stmt, args...)
+	db.Breaker.Record(err)
+	db.observe(stmt, start, err)
+	return rows, err
+}
+
+func (db *DB) query(stmt string, args ...any) (*sql.Rows, error) {
+	db.queries.Add(1)
+
+	s, err := db.prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return db.db.Query(db.dialect.Rebind(stmt), args...)
+	}
+	return s.Query(args...)
+}
+
+// QueryRow runs a statement which returns at most one row.
+func (db *DB) QueryRow(stmt string, args ...any) *sql.Row {
+	if err := db.Breaker.Allow(); err != nil {
+		return openCircuit.QueryRow(stmt)
+	}
+	start := time.Now()
+	row := db.queryRow(stmt, args...)
+	db.Breaker.Record(row.Err())
+	db.observe(stmt, start, row.Err())
+	return row
+}
+
+func (db *DB) queryRow(stmt string, args ...any) *sql.Row {
+	db.queries.Add(1)
+
+	// A *sql.Row can't be made with an error, so if preparing fails the
+	// statement is run directly, which reports the same error through Scan.
+	s, err := db.prepare(stmt)
+	if err != nil || s == nil {
+		return db.db.QueryRow(db.dialect.Rebind(stmt), args...)
+	}
+	return s.QueryRow(args...)
+}
+
+// Begin starts a transaction. Statements run in it use the same cache of
+// prepared statements.
+func (db *DB) Begin() (*Tx, error) {
+	if err := db.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	tx, err := db.db.Begin()
+	db.Breaker.Record(err)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, db: db}, nil
+}
+
+// Close closes the cached prepared statements. It doesn't close the
+// connection pool.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var err error
+	for key, s := range db.stmts {
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(db.stmts, key)
+	}
+	return err
+}
+
+// prepare returns the prepared statement for stmt, preparing it if it isn't
+// in the cache yet. It returns nil if the cache is full.
+func (db *DB) prepare(stmt string) (*sql.Stmt, error) {
+	db.mu.Lock()
+	s, ok := db.stmts[stmt]
+	full := len(db.stmts) >= db.MaxStatements
+	db.mu.Unlock()
+
+	if ok {
+		return s, nil
+	}
+	if full {
+		return nil, nil
+	}
+
+	// Preparing talks to the database, so it's done without holding the
+	// lock. If two goroutines race to prepare the same statement, the loser
+	// closes its copy.
+	s, err := db.db.Prepare(db.dialect.Rebind(stmt))
+	if err != nil {
+		return nil, err
+	}
+	db.prepares.Add(1)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if existing, ok := db.stmts[stmt]; ok {
+		s.Close()
+		return existing, nil
+	}
+	db.stmts[stmt] = s
+	return s, nil
+}
+
+// Tx is a transaction started by DB.Begin.
+type Tx struct {
+	tx *sql.Tx
+	db *DB
+}
+
+// Dialect returns the dialect statements are rebound for.
+func (tx *Tx) Dialect() *Dialect {
+	return tx.db.dialect
+}
+
+// Exec runs a statement which doesn't return rows.
+func (tx *Tx) Exec(stmt string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.exec(stmt, args...)
+	tx.db.Breaker.Record(err)
+	tx.db.observe(stmt, start, err)
+	return result, err
+}
+
+func (tx *Tx) exec(stmt string, args ...any) (sql.Result, error) {
+	tx.db.queries.Add(1)
+
+	s, err := tx.db.prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return tx.tx.Exec(tx.db.dialect.Rebind(stmt), args...)
+	}
+	return tx.tx.Stmt(s).Exec(args...)
+}
+
+// Query runs a statement which returns rows.
+func (tx *Tx) Query(stmt string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.query(stmt, args...)
+	tx.db.Breaker.Record(err)
+	tx.db.observe(stmt, start, err)
+	return rows, err
+}
+
+func (tx *Tx) query(stmt string, args ...any) (*sql.Rows, error) {
+	tx.db.queries.Add(1)
+
+	s, err := tx.db.prepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return tx.tx.Query(tx.db.dialect.Rebind(stmt), args...)
+	}
+	return tx.tx.Stmt(s).Query(args...)
+}
+
+// QueryRow runs a statement which returns at most one row.
+func (tx *Tx) QueryRow(stmt string, args ...any) *sql.Row {
+	start := time.Now()
+	row := tx.queryRow(stmt, args...)
+	tx.db.Breaker.Record(row.Err())
+	tx.db.observe(stmt, start, row.Err())
+	return row
+}
+
+func (tx *Tx) queryRow(stmt string, args ...any) *sql.Row {
+	tx.db.queries.Add(1)
+
+	s, err := tx.db.prepare(stmt)
+	if err != nil || s == nil {
+		return tx.tx.QueryRow(tx.db.dialect.Rebind(stmt), args...)
+	}
+	return tx.tx.Stmt(s).QueryRow(args...)
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	err := tx.tx.Commit()
+	tx.db.Breaker.Record(err)
+	return err
+}
+
+// Rollback aborts the transaction.
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
@@ -0,0 +1,157 @@
+package query
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingDriver is a database driver whose statements do nothing. It counts
+// how many statements are prepared on it.
+type countingDriver struct {
+	prepares atomic.Int64
+}
+
+func (d *countingDriver) Open(string) (driver.Conn, error) { return &countingConn{d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.prepares.Add(1)
+	return countingStmt{}, nil
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type countingStmt struct{}
+
+func (countingStmt) Close() error                                    { return nil }
+func (countingStmt) NumInput() int                                   { return -1 }
+func (countingStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.ResultNoRows, nil }
+func (countingStmt) Query(args []driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return []string{"n"} }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+var testDriver = &countingDriver{}
+
+func init() {
+	sql.Register("query-test", testDriver)
+}
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	pool, err := sql.Open("query-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+
+	db := New(pool, nil)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDBCachesStatements(t *testing.T) {
+	db := newTestDB(t)
+	before := testDriver.prepares.Load()
+
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("UPDATE t SET n = ?", i); err != nil {
+			t.Fatal(err)
+		}
+		err := db.QueryRow("SELECT n FROM t WHERE id = ?", i).Scan(new(int))
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("got %v; want sql.ErrNoRows", err)
+		}
+	}
+
+	stats := db.Stats()
+	assert.Equal(t, stats.Queries, int64(6))
+	assert.Equal(t, stats.Prepares, int64(2))
+	assert.Equal(t, stats.Statements, 2)
+	assert.Equal(t, testDriver.prepares.Load()-before, int64(2))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("UPDATE t SET n = ?", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats = db.Stats()
+	assert.Equal(t, stats.Queries, int64(7))
+	assert.Equal(t, stats.Prepares, int64(2))
+}
+
+func TestDBMaxStatements(t *testing.T) {
+	db := newTestDB(t)
+	db.MaxStatements = 1
+
+	for _, stmt := range []string{"DELETE FROM a", "DELETE FROM b", "DELETE FROM b"} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := db.Stats()
+	assert.Equal(t, stats.Queries, int64(3))
+	assert.Equal(t, stats.Statements, 1)
+	assert.Equal(t, stats.Prepares, int64(1))
+}
+
+func TestDBObserve(t *testing.T) {
+	db := newTestDB(t)
+
+	var observed []string
+	db.Observe = func(stmt string, d time.Duration, err error) {
+		if d < 0 || err != nil {
+			t.Errorf("%s: observed %v, %v", stmt, d, err)
+		}
+		observed = append(observed, stmt)
+	}
+
+	if _, err := db.Exec("UPDATE t SET n = 1"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pool has one connection, which the transaction holds, so only
+	// statements which are already prepared can be run in it.
+	if _, err := tx.Exec("UPDATE t SET n = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Join(observed, "; "), "UPDATE t SET n = 1; SELECT n FROM t; UPDATE t SET n = 1")
+}
@@ -0,0 +1,92 @@
+// Package query is the small layer between the models and database/sql. It
+// builds SQL statements for a particular database's dialect, and runs them
+// through prepared statements which are cached for the life of the
+// connection pool.
+//
+// Statements are always written with ? placeholders; the dialect rewrites
+// them into whatever the database expects when the statement is built or
+// prepared.
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the parts of SQL which differ between databases.
+type Dialect struct {
+	Name string
+
+	// Placeholder returns the bind parameter for the nth argument, counting
+	// from 1.
+	Placeholder func(n int) string
+
+	// Now is an expression for the current time in UTC.
+	Now string
+
+	// DaysFromNow returns an expression for the time the given number of
+	// days from now, in UTC. days is itself an expression, usually "?".
+	DaysFromNow func(days string) string
+}
+
+// MySQL is the dialect of MySQL and MariaDB, and the default.
+var MySQL = &Dialect{
+	Name:        "mysql",
+	Placeholder: func(int) string { return "?" },
+	Now:         "UTC_TIMESTAMP()",
+	DaysFromNow: func(days string) string {
+		return "DATE_ADD(UTC_TIMESTAMP(), INTERVAL " + days + " DAY)"
+	},
+}
+
+// Postgres is the dialect of PostgreSQL.
+var Postgres = &Dialect{
+	Name:        "postgres",
+	Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	Now:         "(NOW() AT TIME ZONE 'UTC')",
+	DaysFromNow: func(days string) string {
+		return "(NOW() AT TIME ZONE 'UTC' + " + days + " * INTERVAL '1 day')"
+	},
+}
+
+// SQLite is the dialect of SQLite.
+var SQLite = &Dialect{
+	Name:        "sqlite",
+	Placeholder: func(int) string { return "?" },
+	Now:         "datetime('now')",
+	DaysFromNow: func(days string) string {
+		return "datetime('now', " + days + " || ' days')"
+	},
+}
+
+// Rebind rewrites the ? placeholders in stmt into the dialect's own. Question
+// marks inside quoted strings and identifiers are left alone.
+func (d *Dialect) Rebind(stmt string) string {
+	if d == nil || d.Placeholder(1) == "?" {
+		return stmt
+	}
+
+	var b strings.Builder
+	b.Grow(len(stmt))
+
+	var quote rune
+	n := 0
+
+	for _, c := range stmt {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(d.Placeholder(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,134 @@
+// Package ratelimit provides an in-memory token bucket rate limiter with a
+// separate bucket per key, such as a client IP address.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are removed from memory.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  int
+}
+
+// Limiter allows up to Burst events at once per key, refilled at Rate events
+// per second. It is safe for concurrent use.
+type Limiter struct {
+	Rate  float64
+	Burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is replaceable in tests.
+	now func() time.Time
+}
+
+// New returns a limiter allowing rate events per second with bursts of up to
+// burst events.
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether an event for key may happen now, and if so uses up
+// one token from its bucket.
+func (l *Limiter) Allow(key string) bool {
+	return l.Check(key, l.Rate, l.Burst).Allowed
+}
+
+// Status describes a key's bucket after an event was checked against it.
+type Status struct {
+	// Allowed is whether the event may happen.
+	Allowed bool
+	// Remaining is how many more events are allowed straight away.
+	Remaining int
+	// Reset is when the bucket will be full again.
+	Reset time.Time
+	// RetryAfter is how long to wait before the next event will be allowed,
+	// if this one wasn't.
+	RetryAfter time.Duration
+}
+
+// Check is like Allow, but uses the given rate and burst for the key instead
+// of the limiter's own, so that different keys can have different limits,
+// and describes the state of the key's bucket. If the limits for a key change
+// its bucket keeps its tokens, up to the new burst.
+func (l *Limiter) Check(key string, rate float64, burst int) Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b := l.refill(key, rate, burst, now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	reset := now
+	if rate > 0 {
+		reset = now.Add(time.Duration((float64(b.burst) - b.tokens) / rate * float64(time.Second)))
+	}
+
+	var retryAfter time.Duration
+	if !allowed && rate > 0 {
+		retryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	return Status{
+		Allowed:    allowed,
+		Remaining:  int(math.Floor(b.tokens)),
+		Reset:      reset,
+		RetryAfter: retryAfter,
+	}
+}
+
+// refill returns the bucket for key, topped up with the tokens earned since it
+// was last used. It must be called with l.mu held.
+func (l *Limiter) refill(key string, rate float64, burst int, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now, rate: rate, burst: burst}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.rate, b.burst = rate, burst
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+
+	return b
+}
+
+// sweep removes buckets which would be full by now, since they are no
+// different from a new bucket. It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= float64(b.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	// The burst is available straight away...
+	assert.Equal(t, l.Allow("a"), true)
+	assert.Equal(t, l.Allow("a"), true)
+	assert.Equal(t, l.Allow("a"), false)
+
+	// ...and doesn't affect other keys.
+	assert.Equal(t, l.Allow("b"), true)
+
+	// One token is earned per second.
+	now = now.Add(time.Second)
+	assert.Equal(t, l.Allow("a"), true)
+	assert.Equal(t, l.Allow("a"), false)
+
+	// Idle buckets are swept once they would be full again.
+	now = now.Add(time.Hour)
+	l.Allow("c")
+	assert.Equal(t, len(l.buckets), 1)
+}
+
+func TestLimiterCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	// A key can have its own limits: here one event every 10 seconds.
+	s := l.Check("a", 0.1, 3)
+	assert.Equal(t, s.Allowed, true)
+	assert.Equal(t, s.Remaining, 2)
+	assert.Equal(t, s.Reset, now.Add(10*time.Second))
+
+	l.Check("a", 0.1, 3)
+	l.Check("a", 0.1, 3)
+	s = l.Check("a", 0.1, 3)
+	assert.Equal(t, s.Allowed, false)
+	assert.Equal(t, s.Remaining, 0)
+	assert.Equal(t, s.Reset, now.Add(30*time.Second))
+	assert.Equal(t, s.RetryAfter, 10*time.Second)
+
+	// The limiter's own limits still apply to other keys.
+	s = l.Check("b", l.Rate, l.Burst)
+	assert.Equal(t, s.Remaining, 1)
+}
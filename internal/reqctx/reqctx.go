@@ -0,0 +1,128 @@
+// Package reqctx stores the values which describe the current request, like
+// its ID and the authenticated user, in the request context.
+//
+// The values are set up once per request by middleware near the top of the
+// middleware chain, which calls New. They are stored as a pointer, so that
+// middleware further down the chain (like the one which authenticates the
+// user) can fill in details for middleware further up (like the access log
+// and panic reports) to read.
+package reqctx
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// Values are the values stored for a request.
+type Values struct {
+	// RequestID is a random ID for the request, which is sent back to the
+	// client and included in logs and panic reports.
+	RequestID string
+
+	// ClientIP is the IP address of the client, without the port.
+	ClientIP string
+
+	// HTTPS is set when the client made the request over HTTPS, either to
+	// the server itself or to a trusted proxy in front of it.
+	HTTPS bool
+
+	// UserID is the ID of the authenticated user, or zero if the request
+	// isn't from a signed-in user.
+	UserID int
+
+	// ImpersonatorID is the ID of the admin acting as the user, or zero if
+	// the user is signed in as themselves. ImpersonationID tells the
+	// admin's impersonations apart.
+	ImpersonatorID  int
+	ImpersonationID string
+
+	// Locale is the client's preferred language, like "en" or "pt-BR".
+	Locale string
+
+	// CSPNonce is a random value which allows inline scripts and styles
+	// carrying it through the Content-Security-Policy.
+	CSPNonce string
+
+	// Route is the method and name of the route the request matched, like
+	// "GET snippet.view", or empty if it didn't match one.
+	Route string
+}
+
+// New returns a copy of ctx which holds v.
+func New(ctx context.Context, v *Values) context.Context {
+	return context.WithValue(ctx, contextKey{}, v)
+}
+
+// From returns the values stored in ctx. If there are none, it returns empty
+// values which aren't stored anywhere, so callers never have to check for
+// nil.
+func From(ctx context.Context) *Values {
+	v, ok := ctx.Value(contextKey{}).(*Values)
+	if !ok {
+		return &Values{}
+	}
+	return v
+}
+
+// RequestID returns the ID of the request.
+func RequestID(ctx context.Context) string {
+	return From(ctx).RequestID
+}
+
+// ClientIP returns the IP address of the client.
+func ClientIP(ctx context.Context) string {
+	return From(ctx).ClientIP
+}
+
+// IsHTTPS reports whether the client made the request over HTTPS.
+func IsHTTPS(ctx context.Context) bool {
+	return From(ctx).HTTPS
+}
+
+// UserID returns the ID of the authenticated user, or zero if there isn't
+// one.
+func UserID(ctx context.Context) int {
+	return From(ctx).UserID
+}
+
+// IsAuthenticated reports whether the request is from a signed-in user.
+func IsAuthenticated(ctx context.Context) bool {
+	return UserID(ctx) != 0
+}
+
+// SetUserID records the authenticated user. It has no effect if ctx doesn't
+// hold any values.
+func SetUserID(ctx context.Context, id int) {
+	From(ctx).UserID = id
+}
+
+// ImpersonatorID returns the ID of the admin acting as the authenticated
+// user, or zero if there isn't one.
+func ImpersonatorID(ctx context.Context) int {
+	return From(ctx).ImpersonatorID
+}
+
+// ImpersonationID returns the ID of the impersonation the request is part
+// of, or an empty string if it isn't part of one.
+func ImpersonationID(ctx context.Context) string {
+	return From(ctx).ImpersonationID
+}
+
+// SetImpersonation records that the authenticated user is being
+// impersonated by an admin. It has no effect if ctx doesn't hold any values.
+func SetImpersonation(ctx context.Context, impersonatorID int, impersonationID string) {
+	v := From(ctx)
+	v.ImpersonatorID = impersonatorID
+	v.ImpersonationID = impersonationID
+}
+
+// Locale returns the client's preferred language.
+func Locale(ctx context.Context) string {
+	return From(ctx).Locale
+}
+
+// CSPNonce returns the Content-Security-Policy nonce for the response.
+func CSPNonce(ctx context.Context) string {
+	return From(ctx).CSPNonce
+}
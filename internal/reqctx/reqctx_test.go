@@ -0,0 +1,39 @@
+package reqctx
+
+import (
+	"context"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	ctx := New(context.Background(), &Values{RequestID: "abc123", ClientIP: "192.0.2.1", Locale: "en"})
+
+	assert.Equal(t, RequestID(ctx), "abc123")
+	assert.Equal(t, ClientIP(ctx), "192.0.2.1")
+	assert.Equal(t, Locale(ctx), "en")
+	assert.Equal(t, IsAuthenticated(ctx), false)
+
+	// Values set further down the chain are visible to everyone holding the
+	// context, including derived contexts.
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	SetUserID(child, 7)
+	assert.Equal(t, UserID(ctx), 7)
+	assert.Equal(t, IsAuthenticated(ctx), true)
+
+	SetImpersonation(child, 2, "xyz789")
+	assert.Equal(t, ImpersonatorID(ctx), 2)
+	assert.Equal(t, ImpersonationID(ctx), "xyz789")
+}
+
+func TestValuesMissing(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, RequestID(ctx), "")
+	assert.Equal(t, UserID(ctx), 0)
+
+	// Setting values on a context without any is harmless.
+	SetUserID(ctx, 7)
+	assert.Equal(t, UserID(ctx), 0)
+}
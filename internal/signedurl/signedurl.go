@@ -0,0 +1,245 @@
+// Package signedurl makes links which can't be forged or altered, and which
+// stop working after a while, for the links the application emails out:
+// sign-in links, email confirmation links and one-click unsubscribe links.
+// Following such a link proves that whoever followed it received the email,
+// so the request needs neither a session nor a CSRF token.
+//
+// A link is signed by adding four query parameters to it: when it expires,
+// the ID of the key which signed it, a random nonce which tells it apart
+// from every other link, and an HMAC-SHA256 of the path and all the other
+// parameters. Keys are written like the encryption keys in the crypto
+// package, and are rotated the same way: links are signed with the first
+// key, and checked with whichever key signed them.
+//
+// A link can be used any number of times until it expires, unless a Store
+// is given to Require, which then lets each link through only once.
+package signedurl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeySize is the size of a signing key in bytes.
+const KeySize = 32
+
+// The query parameters which Sign adds. The parameters given to Sign
+// mustn't use these names.
+const (
+	paramExpires = "exp"
+	paramKey     = "kid"
+	paramNonce   = "nonce"
+	paramSig     = "sig"
+)
+
+var (
+	// ErrInvalid is returned for a link which isn't signed, was signed with
+	// a key which isn't in the keyring, or has been altered.
+	ErrInvalid = errors.New("signedurl: invalid signature")
+
+	// ErrExpired is returned for a link which was signed properly but has
+	// expired.
+	ErrExpired = errors.New("signedurl: link has expired")
+
+	// ErrUsed is returned by the middleware for a link which may only be
+	// used once, and already has been.
+	ErrUsed = errors.New("signedurl: link has already been used")
+)
+
+// Token is what a signed link says about itself, once it has been checked.
+type Token struct {
+	// ID is the link's nonce, which is different for every link signed.
+	ID string
+	// Expires is when the link stopped, or will stop, working.
+	Expires time.Time
+	// Params are the parameters the link was signed with.
+	Params url.Values
+}
+
+// Signer signs links and checks them. It is safe for concurrent use.
+type Signer struct {
+	primary string
+	keys    map[string][]byte
+
+	// Skew is how long after it expires a link is still accepted, so that
+	// one checked by a server whose clock is a little ahead of the one
+	// which signed it doesn't stop working early.
+	Skew time.Duration
+
+	// now returns the current time, and is replaced in tests.
+	now func() time.Time
+}
+
+// ParseKeys returns a Signer for a comma-separated list of keys, each
+// written as an ID, a colon and 32 base64-encoded bytes, like
+// "2024b:q3Fz...,2024a:9xJ1...". The first key is used for signing.
+func ParseKeys(s string) (*Signer, error) {
+	signer := &Signer{keys: make(map[string][]byte), now: time.Now}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New("signedurl: keys must be written as id:base64-key")
+		}
+		if _, exists := signer.keys[id]; exists {
+			return nil, fmt.Errorf("signedurl: duplicate key ID %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("signedurl: key %q: %w", id, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("signedurl: key %q is %d bytes, not %d", id, len(key), KeySize)
+		}
+
+		if signer.primary == "" {
+			signer.primary = id
+		}
+		signer.keys[id] = key
+	}
+
+	if signer.primary == "" {
+		return nil, errors.New("signedurl: no keys given")
+	}
+
+	return signer, nil
+}
+
+// NewRandom returns a Signer with a random key. The links it signs stop
+// working when the process exits, and aren't accepted by any other
+// instance.
+func NewRandom() (*Signer, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &Signer{primary: "random", keys: map[string][]byte{"random": key}, now: time.Now}, nil
+}
+
+// Sign returns path with params and the signature in its query string. The
+// link works until ttl from now.
+func (s *Signer) Sign(path string, params url.Values, ttl time.Duration) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	for name, values := range params {
+		q[name] = append([]string(nil), values...)
+	}
+	q.Set(paramExpires, strconv.FormatInt(s.now().Add(ttl).Unix(), 10))
+	q.Set(paramKey, s.primary)
+	q.Set(paramNonce, base64.RawURLEncoding.EncodeToString(nonce))
+	q.Set(paramSig, s.signature(s.keys[s.primary], path, q))
+
+	return path + "?" + q.Encode(), nil
+}
+
+// Verify checks that u was signed by s and hasn't expired, and returns what
+// it says. It returns ErrInvalid or ErrExpired if not.
+func (s *Signer) Verify(u *url.URL) (*Token, error) {
+	q := u.Query()
+
+	key, ok := s.keys[q.Get(paramKey)]
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	// The parameters are signed in the order url.Values.Encode puts them
+	// in, so the order they come back in doesn't matter.
+	sig := q.Get(paramSig)
+	q.Del(paramSig)
+	if !hmac.Equal([]byte(sig), []byte(s.signature(key, u.Path, q))) {
+		return nil, ErrInvalid
+	}
+
+	exp, err := strconv.ParseInt(q.Get(paramExpires), 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	expires := time.Unix(exp, 0)
+	if s.now().After(expires.Add(s.Skew)) {
+		return nil, ErrExpired
+	}
+
+	t := &Token{ID: q.Get(paramNonce), Expires: expires}
+	for _, name := range []string{paramExpires, paramKey, paramNonce} {
+		q.Del(name)
+	}
+	t.Params = q
+
+	return t, nil
+}
+
+// signature returns the signature of path with the parameters in q.
+func (s *Signer) signature(key []byte, path string, q url.Values) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "?" + q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Store remembers which links have been used, for links which only work
+// once.
+type Store interface {
+	// Consume records that the link with the given ID has been used, and
+	// reports false if it already had been. The record is only needed
+	// until expires, after which the link wouldn't be accepted anyway.
+	Consume(id string, expires time.Time) (bool, error)
+}
+
+type contextKey struct{}
+
+// FromContext returns the token for the signed link being followed, which
+// Require puts in the request's context, or nil if there isn't one.
+func FromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(contextKey{}).(*Token)
+	return t
+}
+
+// Require returns middleware which only lets through requests whose URL
+// was signed by s, and hands the link's token on in the request's context.
+// If store isn't nil, each link is only let through once. Requests which
+// aren't let through are passed to fail, along with ErrInvalid, ErrExpired,
+// ErrUsed or the store's error.
+func (s *Signer) Require(store Store, fail func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t, err := s.Verify(r.URL)
+			if err != nil {
+				fail(w, r, err)
+				return
+			}
+
+			if store != nil {
+				fresh, err := store.Consume(t.ID, t.Expires.Add(s.Skew))
+				if err != nil {
+					fail(w, r, err)
+					return
+				}
+				if !fresh {
+					fail(w, r, ErrUsed)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, t)))
+		})
+	}
+}
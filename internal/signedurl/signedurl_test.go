@@ -0,0 +1,203 @@
+package signedurl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey returns a key written the way ParseKeys reads it, made of b
+// repeated.
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+func mustParseKeys(t *testing.T, s string) *Signer {
+	t.Helper()
+
+	signer, err := ParseKeys(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func mustSign(t *testing.T, s *Signer, path string, params url.Values, ttl time.Duration) *url.URL {
+	t.Helper()
+
+	link, err := s.Sign(path, params, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := mustParseKeys(t, testKey("a", 1))
+	s.Skew = time.Minute
+	s.now = func() time.Time { return now }
+
+	u := mustSign(t, s, "/email/unsubscribe", url.Values{"uid": {"1"}}, time.Hour)
+	assert.Equal(t, u.Path, "/email/unsubscribe")
+
+	token, err := s.Verify(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, token.Params.Encode(), "uid=1")
+	assert.Equal(t, token.Expires.Equal(now.Add(time.Hour)), true)
+
+	// Every link gets its own nonce.
+	again := mustSign(t, s, "/email/unsubscribe", url.Values{"uid": {"1"}}, time.Hour)
+	assert.Equal(t, again.Query().Get("nonce") == token.ID, false)
+
+	// Changing the path or any of the parameters breaks the signature.
+	tampered := *u
+	tampered.Path = "/user/login/magic/link"
+	_, err = s.Verify(&tampered)
+	assert.Equal(t, err, ErrInvalid)
+
+	for _, name := range []string{"uid", "exp", "kid", "nonce"} {
+		q := u.Query()
+		q.Set(name, q.Get(name)+"0")
+		tampered.Path, tampered.RawQuery = u.Path, q.Encode()
+		_, err = s.Verify(&tampered)
+		assert.Equal(t, err, ErrInvalid)
+	}
+
+	q := u.Query()
+	q.Add("extra", "1")
+	tampered.RawQuery = q.Encode()
+	_, err = s.Verify(&tampered)
+	assert.Equal(t, err, ErrInvalid)
+
+	// The order of the parameters doesn't matter.
+	parts := strings.Split(u.RawQuery, "&")
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	tampered.RawQuery = strings.Join(parts, "&")
+	_, err = s.Verify(&tampered)
+	assert.Equal(t, err, nil)
+
+	// A link is still accepted for Skew after it expires, for servers whose
+	// clocks are ahead.
+	now = now.Add(time.Hour + 30*time.Second)
+	_, err = s.Verify(u)
+	assert.Equal(t, err, nil)
+
+	now = now.Add(time.Minute)
+	_, err = s.Verify(u)
+	assert.Equal(t, err, ErrExpired)
+
+	// Unsigned links are invalid, not expired.
+	_, err = s.Verify(&url.URL{Path: "/email/unsubscribe", RawQuery: "uid=1"})
+	assert.Equal(t, err, ErrInvalid)
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := mustParseKeys(t, testKey("a", 1))
+	u := mustSign(t, old, "/", nil, time.Hour)
+
+	// Links signed with an older key are accepted as long as it's still in
+	// the keyring...
+	rotated := mustParseKeys(t, testKey("b", 2)+","+testKey("a", 1))
+	_, err := rotated.Verify(u)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mustSign(t, rotated, "/", nil, time.Hour).Query().Get("kid"), "b")
+
+	// ...and not once it's been removed, or if it's been replaced.
+	_, err = mustParseKeys(t, testKey("b", 2)).Verify(u)
+	assert.Equal(t, err, ErrInvalid)
+
+	_, err = mustParseKeys(t, testKey("a", 2)).Verify(u)
+	assert.Equal(t, err, ErrInvalid)
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+	}{
+		{"Empty", ""},
+		{"No ID", base64.StdEncoding.EncodeToString(make([]byte, KeySize))},
+		{"Not base64", "a:not base64"},
+		{"Too short", "a:" + base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{"Duplicate ID", testKey("a", 1) + "," + testKey("a", 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeys(tt.keys)
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
+
+// memoryStore is a Store which keeps the IDs of the links used in a map.
+type memoryStore map[string]bool
+
+func (m memoryStore) Consume(id string, expires time.Time) (bool, error) {
+	if m[id] {
+		return false, nil
+	}
+	m[id] = true
+	return true, nil
+}
+
+func TestRequire(t *testing.T) {
+	s, err := NewRandom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failure error
+	fail := func(w http.ResponseWriter, r *http.Request, err error) {
+		failure = err
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).Params.Get("uid")))
+	})
+
+	serve := func(h http.Handler, u *url.URL) *httptest.ResponseRecorder {
+		failure = nil
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, u.String(), nil))
+		return rr
+	}
+
+	u := mustSign(t, s, "/user/login/magic/link", url.Values{"uid": {"2"}}, time.Minute)
+
+	// Without a store a link can be followed again and again...
+	reusable := s.Require(nil, fail)(next)
+	for i := 0; i < 2; i++ {
+		rr := serve(reusable, u)
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Body.String(), "2")
+	}
+
+	// ...and with one only once.
+	once := s.Require(memoryStore{}, fail)(next)
+	rr := serve(once, u)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	rr = serve(once, u)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, failure, ErrUsed)
+
+	// Links which fail to verify aren't used up.
+	serve(once, &url.URL{Path: "/user/login/magic/link", RawQuery: "uid=2"})
+	assert.Equal(t, errors.Is(failure, ErrInvalid), true)
+}
@@ -0,0 +1,133 @@
+// Package systemd implements the parts of systemd's service protocol which
+// a server needs: taking over the sockets systemd bound for it (socket
+// activation), and telling systemd when it's ready and that it's still alive
+// (sd_notify and the watchdog). It talks to systemd through the environment
+// and a datagram socket, as libsystemd does, so it needs no cgo.
+//
+// See sd_listen_fds(3), sd_notify(3) and sd_watchdog_enabled(3).
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The states which can be passed to Notify.
+const (
+	// Ready says the service has finished starting up.
+	Ready = "READY=1"
+	// Stopping says the service is shutting down.
+	Stopping = "STOPPING=1"
+	// Watchdog keeps the watchdog from restarting the service.
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status returns a state for Notify which sets the one-line status shown by
+// systemctl status.
+func Status(status string) string {
+	return "STATUS=" + strings.ReplaceAll(status, "\n", " ")
+}
+
+// listenFDsStart is the first file descriptor systemd passes, after stdin,
+// stdout and stderr.
+var listenFDsStart = 3
+
+// Listeners returns the sockets systemd passed to the process, in the order
+// they're listed in the socket unit, or nil if it wasn't socket activated.
+// The environment variables which pass them are unset, so they aren't
+// passed on to child processes, which means it only returns them once.
+//
+// Only stream sockets are supported; anything else is an error.
+func Listeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	// The sockets were meant for this process, and not its parent.
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// FileListener makes its own copy of the descriptor, which is closed
+		// on exec, so the original is closed whether it worked or not.
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("systemd: socket %s: %w", name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	return listeners, nil
+}
+
+// Notify sends states, such as Ready or Status("..."), to systemd. It
+// reports whether they were sent: they aren't when the process wasn't started
+// by systemd, or its unit doesn't ask to be notified, which isn't an error.
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading @ is an address in the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("systemd: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("systemd: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often systemd expects to hear Watchdog from
+// the process, or 0 if its unit doesn't have a watchdog. systemd restarts
+// the service if it doesn't, so pings are best sent twice as often.
+func WatchdogInterval() (time.Duration, error) {
+	value := os.Getenv("WATCHDOG_USEC")
+	if value == "" {
+		return 0, nil
+	}
+
+	// The watchdog was meant for this process, and not its parent.
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	usec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, errors.New("systemd: WATCHDOG_USEC must be a positive number of microseconds")
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
@@ -0,0 +1,109 @@
+package systemd
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestListeners(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Listeners takes the descriptor over, as it would one from systemd.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(start int) { listenFDsStart = start }(listenFDsStart)
+	listenFDsStart = fd
+
+	// Sockets meant for another process are left alone.
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	lns, err := Listeners()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(lns), 0)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "web")
+	lns, err = Listeners()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(lns), 1)
+	defer lns[0].Close()
+	assert.Equal(t, lns[0].Addr().String(), tcp.Addr().String())
+
+	// The variables are unset, so the sockets aren't passed on.
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	assert.Equal(t, ok, false)
+	lns, err = Listeners()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(lns), 0)
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	sent, err := Notify(Ready)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, sent, false)
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	sent, err = Notify(Ready, Status("Serving\nrequests"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, sent, true)
+
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(buf[:n]), "READY=1\nSTATUS=Serving requests")
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	interval, err := WatchdogInterval()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, interval, time.Duration(0))
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	interval, err = WatchdogInterval()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, interval, 30*time.Second)
+
+	// The watchdog is another process's.
+	t.Setenv("WATCHDOG_PID", "1")
+	interval, err = WatchdogInterval()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, interval, time.Duration(0))
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "soon")
+	_, err = WatchdogInterval()
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,107 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ContentStats are the counts shown under a snippet's content as it's
+// written.
+type ContentStats struct {
+	Lines int `json:"lines"`
+	Words int `json:"words"`
+	Chars int `json:"chars"`
+	Bytes int `json:"bytes"`
+}
+
+// Stats counts the lines, words, characters and bytes of content. A final
+// line without a newline counts as a line, and empty content has none.
+func Stats(content string) ContentStats {
+	return ContentStats{
+		Lines: CountLines(content),
+		Words: len(strings.FieldsFunc(content, unicode.IsSpace)),
+		Chars: utf8.RuneCountInString(content),
+		Bytes: len(content),
+	}
+}
+
+// CountLines returns the number of lines in content, counting a final line
+// without a newline.
+func CountLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	n := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
+
+// MaxBytes() returns true if a value is no more than n bytes long.
+func MaxBytes(value string, n int) bool {
+	return len(value) <= n
+}
+
+// MaxLines() returns true if a value has no more than n lines.
+func MaxLines(value string, n int) bool {
+	return CountLines(value) <= n
+}
+
+// ContentLimits are the most a single paste can hold. Zero means there's no
+// limit.
+type ContentLimits struct {
+	MaxBytes int `json:"max_bytes,omitempty"`
+	MaxLines int `json:"max_lines,omitempty"`
+}
+
+// DefaultContentLimits are the limits used unless they're configured:
+// enough for any source file or config worth sharing, and as much as the
+// quick paste endpoint accepts.
+var DefaultContentLimits = ContentLimits{MaxBytes: 1 << 20, MaxLines: 20000}
+
+// Check adds an error under key if content is over the limits, saying by
+// how much, as a paste which is too big is usually a whole log or a build
+// output which only a part of was meant to be shared.
+func (l ContentLimits) Check(v *Validator, key, content string) {
+	if l.MaxBytes > 0 && !MaxBytes(content, l.MaxBytes) {
+		v.AddFieldError(key, fmt.Sprintf("This is %s, over the limit of %s. Try sharing only the part that matters, or splitting it into several files.", FormatBytes(len(content)), FormatBytes(l.MaxBytes)))
+	}
+	if l.MaxLines > 0 && !MaxLines(content, l.MaxLines) {
+		v.AddFieldError(key, fmt.Sprintf("This is %d lines long, over the limit of %d lines. Try sharing only the part that matters, or splitting it into several files.", CountLines(content), l.MaxLines))
+	}
+}
+
+// FormatBytes writes a size in bytes the way people say it: 512 bytes,
+// 1.5 KB, 2 MB.
+func FormatBytes(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d bytes", n)
+	case n < 1024*1024:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1024), ".0") + " KB"
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1024*1024)), ".0") + " MB"
+	}
+}
+
+// NormalizeContent is the sanitization pass a snippet's content goes
+// through before it's stored: Windows and old Mac line endings become \n,
+// and the spaces and tabs at the ends of lines are dropped. If keepTrailing
+// is set the ends of lines are left alone, for languages like Markdown
+// where they mean something.
+func NormalizeContent(content string, keepTrailing bool) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+	if keepTrailing {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,178 @@
+package validator
+
+import (
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// FuzzLengthChecks checks that MinChars and MaxChars agree with each other
+// and count characters rather than bytes, whatever the input.
+func FuzzLengthChecks(f *testing.F) {
+	f.Add("", 0)
+	f.Add("hello", 5)
+	f.Add("héllo wörld", 11)
+	f.Add("日本語", 2)
+	f.Add("\xff\xfe", 1)
+	f.Add("<script>alert(1)</script>", 100)
+
+	f.Fuzz(func(t *testing.T, value string, n int) {
+		if n < 0 || n > 1<<20 {
+			t.Skip()
+		}
+
+		// Exactly one of "at most n" and "at least n+1" holds.
+		if MaxChars(value, n) == MinChars(value, n+1) {
+			t.Fatalf("MaxChars(%q, %d) and MinChars(%q, %d) agree", value, n, value, n+1)
+		}
+
+		count := utf8.RuneCountInString(value)
+		if !MaxChars(value, count) || !MinChars(value, count) {
+			t.Fatalf("%q isn't %d characters long", value, count)
+		}
+	})
+}
+
+// FuzzNotBlank checks that NotBlank ignores the space around a value, and
+// only that.
+func FuzzNotBlank(f *testing.F) {
+	f.Add("")
+	f.Add(" \t\r\n")
+	f.Add("x")
+	f.Add(" ")
+	f.Add("​")
+
+	f.Fuzz(func(t *testing.T, value string) {
+		got := NotBlank(value)
+		if NotBlank(" \t"+value+"\n") != got {
+			t.Fatalf("NotBlank(%q) changed with space around it", value)
+		}
+		if got != (strings.TrimSpace(value) != "") {
+			t.Fatalf("NotBlank(%q) = %v", value, got)
+		}
+	})
+}
+
+// FuzzEmailRX checks that whatever EmailRX accepts has exactly one @, with
+// something either side of it and no space anywhere.
+func FuzzEmailRX(f *testing.F) {
+	f.Add("alice@example.com")
+	f.Add("alice@@example.com")
+	f.Add("alice @example.com")
+	f.Add("alice@example.com\n")
+	f.Add("<script>@example.com")
+	f.Add("\"'><img src=x onerror=alert(1)>@example.com")
+
+	f.Fuzz(func(t *testing.T, value string) {
+		if !Matches(value, EmailRX) {
+			return
+		}
+
+		local, domain, found := strings.Cut(value, "@")
+		if !found || local == "" || domain == "" || strings.Contains(domain, "@") {
+			t.Fatalf("EmailRX accepted %q", value)
+		}
+		if strings.ContainsAny(value, " \t\r\n<>") {
+			t.Fatalf("EmailRX accepted %q", value)
+		}
+	})
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	assert.Equal(t, v.Valid(), true)
+
+	v.CheckField(true, "title", "Not shown")
+	assert.Equal(t, v.Valid(), true)
+
+	v.CheckField(false, "title", "First")
+	v.CheckField(false, "title", "Second")
+	assert.Equal(t, v.FieldErrors["title"], "First")
+	assert.Equal(t, v.Valid(), false)
+
+	v = Validator{}
+	v.AddNonFieldError("Wrong")
+	assert.Equal(t, v.Valid(), false)
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		content string
+		want    ContentStats
+	}{
+		{"", ContentStats{}},
+		{"one", ContentStats{Lines: 1, Words: 1, Chars: 3, Bytes: 3}},
+		{"one two\n", ContentStats{Lines: 1, Words: 2, Chars: 8, Bytes: 8}},
+		{"one\n\nthree", ContentStats{Lines: 3, Words: 2, Chars: 10, Bytes: 10}},
+		{"héllo wörld\n", ContentStats{Lines: 1, Words: 2, Chars: 12, Bytes: 14}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, Stats(tt.content), tt.want)
+	}
+}
+
+func TestNormalizeContent(t *testing.T) {
+	tests := []struct {
+		content      string
+		keepTrailing bool
+		want         string
+	}{
+		{"a\r\nb\r\n", false, "a\nb\n"},
+		{"a\rb", false, "a\nb"},
+		{"a  \nb\t\n  c", false, "a\nb\n  c"},
+		{"a  \r\nb", true, "a  \nb"},
+		{"  \n\n", false, "\n\n"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, NormalizeContent(tt.content, tt.keepTrailing), tt.want)
+	}
+}
+
+// FuzzNormalizeContent checks that normalized content has no carriage
+// returns or trailing spaces left, and that normalizing it again changes
+// nothing.
+func FuzzNormalizeContent(f *testing.F) {
+	f.Add("a\r\nb  \r\n", false)
+	f.Add("a  \rb\t", true)
+	f.Add("\r\r\n\n \t", false)
+
+	f.Fuzz(func(t *testing.T, content string, keepTrailing bool) {
+		got := NormalizeContent(content, keepTrailing)
+		if strings.Contains(got, "\r") {
+			t.Fatalf("NormalizeContent(%q) = %q, with a \\r", content, got)
+		}
+		if !keepTrailing && (strings.Contains(got, " \n") || strings.Contains(got, "\t\n") || strings.TrimRight(got, " \t") != got) {
+			t.Fatalf("NormalizeContent(%q) = %q, with trailing space", content, got)
+		}
+		if again := NormalizeContent(got, keepTrailing); again != got {
+			t.Fatalf("NormalizeContent(%q) = %q, but %q again", content, got, again)
+		}
+	})
+}
+
+func TestContentLimits(t *testing.T) {
+	var v Validator
+	ContentLimits{}.Check(&v, "content", strings.Repeat("x\n", 100))
+	assert.Equal(t, v.Valid(), true)
+
+	limits := ContentLimits{MaxBytes: 10, MaxLines: 2}
+	limits.Check(&v, "content", "a\nb")
+	assert.Equal(t, v.Valid(), true)
+
+	limits.Check(&v, "content", "a\nb\nc")
+	assert.StringContains(t, v.FieldErrors["content"], "This is 3 lines long, over the limit of 2 lines.")
+
+	v = Validator{}
+	limits.Check(&v, "content", strings.Repeat("x", 11))
+	assert.StringContains(t, v.FieldErrors["content"], "This is 11 bytes, over the limit of 10 bytes.")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, FormatBytes(512), "512 bytes")
+	assert.Equal(t, FormatBytes(1024), "1 KB")
+	assert.Equal(t, FormatBytes(1536), "1.5 KB")
+	assert.Equal(t, FormatBytes(1<<20), "1 MB")
+}
@@ -0,0 +1,227 @@
+// Package webhooks sends events to the URLs people register to hear about
+// them. Every delivery is a JSON POST signed with the webhook's secret, so
+// the receiver can check it came from us and wasn't replayed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The events which can be sent to webhooks.
+const (
+	SnippetCreated = "snippet.created"
+	SnippetUpdated = "snippet.updated"
+	SnippetDeleted = "snippet.deleted"
+	UserRegistered = "user.registered"
+)
+
+// AllEvents are the events admins' webhooks can subscribe to.
+var AllEvents = []string{SnippetCreated, SnippetUpdated, SnippetDeleted, UserRegistered}
+
+// UserEvents are the events users' webhooks can subscribe to: those about
+// their own snippets.
+var UserEvents = []string{SnippetCreated, SnippetUpdated, SnippetDeleted}
+
+// Event is the body of every delivery.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Created time.Time `json:"created"`
+	Data    any       `json:"data"`
+}
+
+// NewEvent returns the payload for an event of the given type, with a
+// random ID which receivers can use to ignore retries they've already seen.
+func NewEvent(eventType string, data any) ([]byte, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Event{
+		ID:      "evt_" + hex.EncodeToString(id),
+		Type:    eventType,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Data:    data,
+	})
+}
+
+// NewSecret returns a random secret for signing a webhook's deliveries.
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the X-Snippetbox-Signature header for a body sent at ts:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">".
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+func mac(secret, t string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// ErrInvalidSignature is returned by Verify when a signature doesn't match
+// the body, or is too old.
+var ErrInvalidSignature = errors.New("webhooks: invalid signature")
+
+// Verify checks a signature header made by Sign, rejecting those more than
+// tolerance away from now. It's what receivers written in Go can use.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t string
+	var sigs [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig, err := hex.DecodeString(v)
+			if err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	want := mac(secret, t, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// retryDelays are how long to wait after each failed attempt before the
+// next. A delivery is given up on after the last.
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+// RetryDelay returns how long to wait before retrying a delivery which has
+// failed attempts times, and false once it should be given up on.
+func RetryDelay(attempts int) (time.Duration, bool) {
+	if attempts < 1 || attempts > len(retryDelays) {
+		return 0, false
+	}
+	return retryDelays[attempts-1], true
+}
+
+// ErrForbiddenAddress is returned when a webhook URL resolves to an address
+// which users' webhooks can't be sent to.
+var ErrForbiddenAddress = errors.New("webhooks: URL resolves to a private or local address")
+
+// publicOnly is a net.Dialer Control function which refuses to connect to
+// loopback, private, link-local and unspecified addresses. Checking the
+// address actually dialled, rather than the URL's host, means a DNS name
+// can't be pointed at an internal service after the URL is checked.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Sender sends deliveries. Users' webhooks are sent with PublicClient, so
+// they can't be used to reach services on our own network; admins' with
+// Client.
+type Sender struct {
+	Client       *http.Client
+	PublicClient *http.Client
+}
+
+// NewSender returns a Sender whose requests time out after timeout.
+func NewSender(timeout time.Duration) *Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	// Redirects aren't followed, as they'd let a webhook send its payload
+	// somewhere other than the URL which was registered.
+	noRedirects := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	return &Sender{
+		Client:       &http.Client{Timeout: timeout, CheckRedirect: noRedirects},
+		PublicClient: &http.Client{Timeout: timeout, CheckRedirect: noRedirects, Transport: transport},
+	}
+}
+
+// Delivery is one event to send to one webhook.
+type Delivery struct {
+	ID      int
+	Event   string
+	URL     string
+	Secret  string
+	Payload []byte
+	Public  bool
+}
+
+// Send posts a delivery, returning the response's status code (zero if
+// there was no response) and an error unless it was a 2xx.
+func (s *Sender) Send(ctx context.Context, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhooks/1.0")
+	req.Header.Set("X-Snippetbox-Event", d.Event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Snippetbox-Signature", Sign(d.Secret, time.Now(), d.Payload))
+
+	client := s.Client
+	if d.Public {
+		client = s.PublicClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhooks: %s responded with %s", req.URL.Host, res.Status)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"snippet.created"}`)
+	now := time.Now()
+
+	sig := Sign("secret", now, body)
+	assert.Equal(t, strings.HasPrefix(sig, "t="), true)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"Valid", "secret", sig, body, nil},
+		{"Wrong secret", "other", sig, body, ErrInvalidSignature},
+		{"Tampered body", "secret", sig, []byte(`{"type":"user.registered"}`), ErrInvalidSignature},
+		{"Too old", "secret", Sign("secret", now.Add(-time.Hour), body), body, ErrInvalidSignature},
+		{"Garbage", "secret", "nonsense", body, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute)
+			assert.Equal(t, err, tt.want)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	d, ok := RetryDelay(1)
+	assert.Equal(t, d, time.Minute)
+	assert.Equal(t, ok, true)
+
+	d, ok = RetryDelay(5)
+	assert.Equal(t, d, 6*time.Hour)
+	assert.Equal(t, ok, true)
+
+	_, ok = RetryDelay(6)
+	assert.Equal(t, ok, false)
+}
+
+func TestNewEvent(t *testing.T) {
+	payload, err := NewEvent(SnippetCreated, map[string]int{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e struct {
+		ID   string         `json:"id"`
+		Type string         `json:"type"`
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.HasPrefix(e.ID, "evt_"), true)
+	assert.Equal(t, e.Type, SnippetCreated)
+	assert.Equal(t, e.Data["id"], 1)
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		if r.Header.Get("X-Snippetbox-Event") == UserRegistered {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSender(5 * time.Second)
+	payload := []byte(`{"type":"snippet.created"}`)
+
+	status, err := s.Send(context.Background(), Delivery{ID: 7, Event: SnippetCreated, URL: srv.URL, Secret: "secret", Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, got.Header.Get("X-Snippetbox-Delivery"), "7")
+	assert.Equal(t, string(gotBody), string(payload))
+	assert.Equal(t, Verify("secret", got.Header.Get("X-Snippetbox-Signature"), gotBody, time.Minute), nil)
+
+	status, err = s.Send(context.Background(), Delivery{Event: UserRegistered, URL: srv.URL, Secret: "secret", Payload: payload})
+	assert.Equal(t, status, http.StatusInternalServerError)
+	assert.Equal(t, err != nil, true)
+
+	// The test server listens on loopback, which users' webhooks can't
+	// reach.
+	status, err = s.Send(context.Background(), Delivery{Event: SnippetCreated, URL: srv.URL, Secret: "secret", Payload: payload, Public: true})
+	assert.Equal(t, status, 0)
+	assert.Equal(t, errors.Is(err, ErrForbiddenAddress), true)
+}
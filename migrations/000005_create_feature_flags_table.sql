@@ -0,0 +1,5 @@
+CREATE TABLE feature_flags (
+    name VARCHAR(100) NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated DATETIME NOT NULL
+);
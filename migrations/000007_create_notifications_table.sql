@@ -0,0 +1,11 @@
+CREATE TABLE notifications (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    message VARCHAR(255) NOT NULL,
+    link VARCHAR(255) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    read_at DATETIME NULL
+);
+
+CREATE INDEX idx_notifications_user_created ON notifications(user_id, created);
@@ -0,0 +1,12 @@
+CREATE TABLE csp_reports (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    document_uri VARCHAR(2048) NOT NULL,
+    violated_directive VARCHAR(255) NOT NULL,
+    blocked_uri VARCHAR(2048) NOT NULL,
+    source_file VARCHAR(2048) NOT NULL,
+    line_number INTEGER NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_csp_reports_created ON csp_reports(created);
@@ -0,0 +1,14 @@
+ALTER TABLE snippets ADD COLUMN filename VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    language VARCHAR(50) NOT NULL,
+    content TEXT NOT NULL,
+    CONSTRAINT fk_snippet_files_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position);
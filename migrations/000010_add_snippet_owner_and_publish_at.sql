@@ -0,0 +1,13 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Snippets are only visible once publish_at has passed. The published flag
+-- records whether the scheduled publishing task has dealt with the snippet
+-- yet (by notifying its owner), and is set straight away for snippets which
+-- aren't scheduled.
+ALTER TABLE snippets ADD COLUMN publish_at DATETIME NULL;
+UPDATE snippets SET publish_at = created;
+ALTER TABLE snippets MODIFY publish_at DATETIME NOT NULL;
+ALTER TABLE snippets ADD COLUMN published BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX idx_snippets_publish_at ON snippets(publish_at);
@@ -0,0 +1,13 @@
+CREATE TABLE incidents (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    reference CHAR(10) NOT NULL,
+    message TEXT NOT NULL,
+    stack TEXT NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(2048) NOT NULL,
+    user_id INTEGER NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE incidents ADD CONSTRAINT incidents_uc_reference UNIQUE (reference);
@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN detected_language VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE snippet_files ADD COLUMN detected_language VARCHAR(50) NOT NULL DEFAULT '';
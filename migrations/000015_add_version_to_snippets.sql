@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
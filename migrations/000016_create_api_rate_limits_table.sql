@@ -0,0 +1,7 @@
+CREATE TABLE api_rate_limits (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    requests_per_hour INTEGER NOT NULL,
+    burst INTEGER NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT fk_api_rate_limits_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,21 @@
+CREATE TABLE snippet_views (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    viewed DATETIME NOT NULL,
+    referrer VARCHAR(255) NOT NULL,
+    country CHAR(2) NOT NULL,
+    browser VARCHAR(50) NOT NULL,
+    CONSTRAINT fk_snippet_views_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_views_viewed ON snippet_views(viewed);
+
+CREATE TABLE snippet_stats (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day, dimension, value),
+    CONSTRAINT fk_snippet_stats_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+CREATE TABLE webauthn_credentials (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    credential_id VARBINARY(1023) NOT NULL,
+    public_key BLOB NOT NULL,
+    attestation_type VARCHAR(32) NOT NULL,
+    transports VARCHAR(255) NOT NULL DEFAULT '',
+    aaguid BINARY(16) NOT NULL,
+    sign_count INTEGER UNSIGNED NOT NULL DEFAULT 0,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL,
+    last_used DATETIME NULL,
+    CONSTRAINT webauthn_credentials_uc_credential_id UNIQUE (credential_id),
+    CONSTRAINT fk_webauthn_credentials_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
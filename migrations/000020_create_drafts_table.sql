@@ -0,0 +1,6 @@
+CREATE TABLE drafts (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    data MEDIUMTEXT NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT fk_drafts_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE snippet_templates (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    title VARCHAR(100) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    CONSTRAINT snippet_templates_uc_user_name UNIQUE (user_id, name),
+    CONSTRAINT fk_snippet_templates_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
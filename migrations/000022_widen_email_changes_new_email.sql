@@ -0,0 +1,2 @@
+-- Pending email addresses are stored encrypted, which makes them longer.
+ALTER TABLE email_changes MODIFY new_email VARCHAR(512) NOT NULL;
@@ -0,0 +1,6 @@
+-- Burn after reading snippets are destroyed by the first person other than
+-- their owner to view them. The row is kept, without its content, so that
+-- later visitors can be told what happened to it.
+ALTER TABLE snippets
+    ADD burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD burned DATETIME NULL;
@@ -0,0 +1,3 @@
+-- Encrypted snippets are encrypted in the browser, with a key the server
+-- never sees. The content column holds the ciphertext.
+ALTER TABLE snippets ADD content_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- The newest changelog version each user has seen, so the "new" badge on
+-- the changelog link follows them between devices.
+ALTER TABLE users ADD changelog_seen VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Large snippet and file contents are stored zlib-compressed in content_zlib,
+-- leaving content empty. Small ones stay in content, uncompressed, so that
+-- most rows never pay for decompression.
+ALTER TABLE snippets ADD content_zlib MEDIUMBLOB NULL;
+ALTER TABLE snippet_files ADD content_zlib MEDIUMBLOB NULL;
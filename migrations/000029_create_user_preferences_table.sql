@@ -0,0 +1,10 @@
+-- A user without a row here gets the defaults: a detected language, a
+-- year's expiry and tabs eight columns wide.
+CREATE TABLE user_preferences (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    language VARCHAR(50) NOT NULL DEFAULT '',
+    expires INTEGER NOT NULL DEFAULT 365,
+    tab_width INTEGER NOT NULL DEFAULT 8,
+    updated DATETIME NOT NULL,
+    CONSTRAINT fk_user_preferences_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
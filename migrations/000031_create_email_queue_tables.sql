@@ -0,0 +1,33 @@
+-- Every email is queued here and sent by a background job, so that a slow
+-- or broken mail provider never holds up a request. Rows are deleted once
+-- they've been sent. The body and HTML are encrypted when encryption keys
+-- are configured, as they can hold login links.
+CREATE TABLE email_queue (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    to_address VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    html MEDIUMTEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt DATETIME NOT NULL,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE INDEX idx_email_queue_due ON email_queue(next_attempt);
+
+-- Emails which were given up on, either because they kept failing or
+-- because the provider rejected them outright, are moved here with the same
+-- ID until an admin retries or deletes them.
+CREATE TABLE email_dead_letters (
+    id INTEGER NOT NULL PRIMARY KEY,
+    to_address VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    html MEDIUMTEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    error VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL
+);
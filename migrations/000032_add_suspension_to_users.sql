@@ -0,0 +1,5 @@
+-- Suspended users can't sign in, and are signed out, until suspended_until
+-- has passed. A ban is a suspension which never ends. ban_reason is shown to
+-- the user.
+ALTER TABLE users ADD suspended_until DATETIME NULL;
+ALTER TABLE users ADD ban_reason VARCHAR(255) NOT NULL DEFAULT '';
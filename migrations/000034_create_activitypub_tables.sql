@@ -0,0 +1,34 @@
+-- The instance's ActivityPub actor signs what it sends with this key pair,
+-- which is made the first time it's needed. There's only ever one row. The
+-- private key is encrypted when encryption keys are configured.
+CREATE TABLE activitypub_keys (
+    id INTEGER NOT NULL PRIMARY KEY,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+-- Fediverse accounts which follow the instance, and the inbox to deliver
+-- activities for them to: their server's shared inbox if it has one.
+CREATE TABLE activitypub_followers (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    actor VARCHAR(512) NOT NULL,
+    inbox VARCHAR(2048) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT activitypub_followers_uc_actor UNIQUE (actor)
+);
+
+-- Activities waiting to be delivered to an inbox. As with the email queue,
+-- rows are deleted once they've been delivered or given up on.
+CREATE TABLE activitypub_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    inbox VARCHAR(2048) NOT NULL,
+    activity MEDIUMTEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt DATETIME NOT NULL,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL
+);
+
+CREATE INDEX idx_activitypub_deliveries_due ON activitypub_deliveries(next_attempt);
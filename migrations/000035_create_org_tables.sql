@@ -0,0 +1,46 @@
+-- Organizations are groups of users who publish snippets together. Owners
+-- manage the organization and its members; members publish snippets for it.
+-- Like collections, each organization has a page under a slug made from its
+-- name when it's created.
+CREATE TABLE orgs (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT orgs_uc_slug UNIQUE (slug)
+);
+
+-- role is "owner" or "member". Every organization keeps at least one owner.
+CREATE TABLE org_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (org_id, user_id),
+    CONSTRAINT fk_org_members_org FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE,
+    CONSTRAINT fk_org_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_org_members_user ON org_members(user_id);
+
+-- Invitations to join an organization are emailed as a link with a token, of
+-- which only the SHA-256 hash is stored.
+CREATE TABLE org_invitations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    org_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    token_hash BINARY(32) NOT NULL,
+    invited_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT org_invitations_uc_token_hash UNIQUE (token_hash),
+    CONSTRAINT fk_org_invitations_org FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE,
+    CONSTRAINT fk_org_invitations_user FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Snippets can be published for an organization. org_only ones are only
+-- shown to its members, and never appear in public lists.
+ALTER TABLE snippets ADD org_id INTEGER NULL;
+ALTER TABLE snippets ADD org_only BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_org FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE SET NULL;
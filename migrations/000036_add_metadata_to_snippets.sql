@@ -0,0 +1,9 @@
+-- Metadata about a snippet's content, worked out when it's saved rather
+-- than every time it's shown. metadata_version is the version of the
+-- pipeline which worked it out, or 0 for snippets saved before there was
+-- one; such snippets are filled in by a background job.
+ALTER TABLE snippets ADD line_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD byte_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD read_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD language_confidence DOUBLE NOT NULL DEFAULT 0;
+ALTER TABLE snippets ADD metadata_version SMALLINT NOT NULL DEFAULT 0;
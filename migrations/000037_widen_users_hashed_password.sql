@@ -0,0 +1,3 @@
+-- Argon2id hashes are longer than bcrypt's 60 characters, and both kinds are
+-- stored side by side while passwords are re-hashed as people sign in.
+ALTER TABLE users MODIFY hashed_password VARCHAR(255) NOT NULL;
@@ -0,0 +1,19 @@
+-- Sign-in and email confirmation links are signed URLs rather than tokens
+-- stored in the database, and only the IDs of those which have been used are
+-- kept here, until they would have expired anyway.
+CREATE TABLE consumed_tokens (
+    id VARCHAR(32) NOT NULL PRIMARY KEY,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_consumed_tokens_expires ON consumed_tokens(expires);
+
+DROP TABLE login_tokens;
+DROP TABLE email_changes;
+
+-- Invitations to organizations are the only emails which can be unsubscribed
+-- from. The link to do so is also sent in the List-Unsubscribe header, which
+-- has to be kept with the queued email until it's sent.
+ALTER TABLE user_preferences ADD COLUMN invitation_emails BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE email_queue ADD COLUMN unsubscribe VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE email_dead_letters ADD COLUMN unsubscribe VARCHAR(1024) NOT NULL DEFAULT '';
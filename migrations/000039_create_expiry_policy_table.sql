@@ -0,0 +1,11 @@
+-- The instance's expiry policy, set by admins. There's only ever the one row,
+-- and until it's saved the defaults apply: snippets last between a day and a
+-- year, a year unless said otherwise, and never forever.
+CREATE TABLE expiry_policy (
+    id INTEGER NOT NULL PRIMARY KEY,
+    min_days INTEGER NOT NULL,
+    max_days INTEGER NOT NULL,
+    allow_never BOOLEAN NOT NULL,
+    default_days INTEGER NOT NULL,
+    updated DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+-- The ID a snippet is known by in URLs and the API, so that snippets can't
+-- be found by counting through the auto-increment IDs. Snippets saved
+-- before there were public IDs are given one by a background job, until
+-- which they can't be linked to.
+ALTER TABLE snippets ADD public_id VARCHAR(36) NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_public_id UNIQUE (public_id);
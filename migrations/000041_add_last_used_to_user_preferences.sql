@@ -0,0 +1,7 @@
+-- The choices users made on the form when they last created a snippet, which
+-- the form starts from next time. They're NULL until then, and again once
+-- the user saves their preferences, so that the form starts from those.
+ALTER TABLE user_preferences ADD COLUMN last_language VARCHAR(50) NULL;
+ALTER TABLE user_preferences ADD COLUMN last_expires INTEGER NULL;
+ALTER TABLE user_preferences ADD COLUMN last_burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE user_preferences ADD COLUMN last_org_only BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,12 @@
+package migrations
+
+import (
+	"embed"
+)
+
+// Files holds the SQL migrations for the snippetbox database. Each file is
+// applied in lexical order, so new migrations should use the next sequence
+// number as their filename prefix.
+//
+//go:embed "*.sql"
+var Files embed.FS
@@ -0,0 +1,399 @@
+// Package client is a Go client for the Snippetbox JSON API, for command
+// line tools and anybody else who wants to script a Snippetbox server.
+//
+// Requests are authenticated with an API token, which users create on their
+// account page:
+//
+//	c, err := client.New("https://snippets.example.com", token)
+//	if err != nil {
+//		return err
+//	}
+//	snippet, err := c.CreateSnippet(ctx, client.NewSnippet{Title: "Hello", Content: "world", Expires: 7})
+//
+// Requests which fail because of the rate limit or a temporary problem at
+// the server are retried with exponential backoff, honouring the server's
+// Retry-After header.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client makes requests to a Snippetbox server. Its fields may be changed
+// before it's first used, but not after.
+type Client struct {
+	baseURL *url.URL
+	token   string
+
+	// HTTPClient sends the requests. It defaults to a client with a 30
+	// second timeout.
+	HTTPClient *http.Client
+	// MaxRetries is how many times a failed request is retried. Zero turns
+	// retries off.
+	MaxRetries int
+	// Backoff is how long to wait before the first retry. Each retry waits
+	// twice as long as the one before, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// UserAgent is sent with every request.
+	UserAgent string
+}
+
+// New returns a client for the server at baseURL, such as
+// "https://snippets.example.com". Requests are made anonymously if token is
+// empty, which only allows reading published snippets.
+func New(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q must be http://host or https://host", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	return &Client{
+		baseURL:    u,
+		token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+		UserAgent:  "snippetbox-client",
+	}, nil
+}
+
+// Snippet is a snippet as the API returns it.
+type Snippet struct {
+	ID               string        `json:"id"`
+	Title            string        `json:"title"`
+	Content          string        `json:"content"`
+	Filename         string        `json:"filename"`
+	Language         string        `json:"language"`
+	DetectedLanguage string        `json:"detected_language,omitempty"`
+	Files            []SnippetFile `json:"files,omitempty"`
+	Created          time.Time     `json:"created"`
+	Expires          time.Time     `json:"expires"`
+	PublishAt        time.Time     `json:"publish_at"`
+	BurnAfterReading bool          `json:"burn_after_reading"`
+	ContentEncrypted bool          `json:"content_encrypted"`
+	// Metadata is nil for encrypted snippets, and for old ones until the
+	// server has worked it out.
+	Metadata *SnippetMetadata `json:"metadata,omitempty"`
+}
+
+// SnippetMetadata is what the server worked out about a snippet's files
+// when it was saved.
+type SnippetMetadata struct {
+	Lines       int `json:"lines"`
+	Bytes       int `json:"bytes"`
+	ReadSeconds int `json:"read_seconds"`
+	// LanguageConfidence is how sure, from 0 to 1, the server's guess at
+	// the first file's language is, if it had to guess.
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+}
+
+// SnippetFile is one of the files in a multi-file snippet. Position 0 is the
+// snippet's own content.
+type SnippetFile struct {
+	Position         int    `json:"position"`
+	Filename         string `json:"filename"`
+	Language         string `json:"language"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	Content          string `json:"content"`
+}
+
+// NeverExpires is the Expires of a NewSnippet which never expires, where the
+// server's expiry policy allows it.
+const NeverExpires = -1
+
+// NewSnippet is a snippet to create. Expires is how many days it lasts, which
+// must be one the server's expiry policy permits: 1, 7 or 365 unless its
+// admins have changed it. Zero means the policy's default. The language of
+// each file is detected if it's left empty.
+type NewSnippet struct {
+	Title            string        `json:"title"`
+	Content          string        `json:"content"`
+	Filename         string        `json:"filename,omitempty"`
+	Language         string        `json:"language,omitempty"`
+	Files            []SnippetFile `json:"files,omitempty"`
+	Expires          int           `json:"expires,omitempty"`
+	PublishAt        *time.Time    `json:"publish_at,omitempty"`
+	BurnAfterReading bool          `json:"burn_after_reading,omitempty"`
+}
+
+// ListOptions chooses a page of snippets. Pages are numbered from 1, and
+// the server picks the page size if PerPage is zero. Mine lists only the
+// snippets of the user the token belongs to. Fields, if there are any, are
+// the only fields of each snippet the server sends, such as "title" and
+// "created"; the rest are left empty.
+//
+// Cursor, if it's set, chooses the page after the one with that NextCursor
+// instead of Page. Unlike numbered pages, those chosen by cursor don't
+// shift while snippets are being published. Set it to Start to begin.
+type ListOptions struct {
+	Page    int
+	PerPage int
+	Mine    bool
+	Fields  []string
+	Cursor  string
+}
+
+// Start is the Cursor of the first page.
+const Start = "start"
+
+// SnippetPage is a page of snippets, along with how many there are in all.
+// NextCursor is the cursor of the next page, if there is one. Total isn't
+// known for pages chosen by cursor.
+type SnippetPage struct {
+	Snippets   []Snippet
+	Total      int
+	NextCursor string
+}
+
+// Error is returned when the server responds with an error.
+type Error struct {
+	StatusCode int
+	// Message is the server's description of the problem.
+	Message string
+	// FieldErrors says what was wrong with each invalid field of a snippet
+	// which couldn't be created.
+	FieldErrors map[string]string
+}
+
+func (e *Error) Error() string {
+	if len(e.FieldErrors) > 0 {
+		fields := make([]string, 0, len(e.FieldErrors))
+		for field, message := range e.FieldErrors {
+			fields = append(fields, field+": "+message)
+		}
+		return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), strings.Join(fields, "; "))
+	}
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 Not Found from the server.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// GetSnippet returns the snippet with the given ID.
+func (c *Client) GetSnippet(ctx context.Context, id string) (*Snippet, error) {
+	var body struct {
+		Snippet *Snippet `json:"snippet"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/snippets/"+url.PathEscape(id), nil, &body)
+	if err != nil {
+		return nil, err
+	}
+	return body.Snippet, nil
+}
+
+// SnippetURL returns the address of the snippet's page on the site.
+func (c *Client) SnippetURL(id string) string {
+	return fmt.Sprintf("%s/snippet/view/%s", c.baseURL, url.PathEscape(id))
+}
+
+// ListSnippets returns a page of the published snippets, newest first.
+func (c *Client) ListSnippets(ctx context.Context, opts ListOptions) (*SnippetPage, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(opts.PerPage))
+	}
+	if opts.Mine {
+		query.Set("mine", "true")
+	}
+	if len(opts.Fields) > 0 {
+		query.Set("fields", strings.Join(opts.Fields, ","))
+	}
+	switch opts.Cursor {
+	case "":
+	case Start:
+		query.Set("cursor", "")
+	default:
+		query.Set("cursor", opts.Cursor)
+	}
+
+	path := "/api/v1/snippets"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var body struct {
+		Snippets   []Snippet `json:"snippets"`
+		NextCursor *string   `json:"next_cursor"`
+	}
+
+	headers, err := c.do(ctx, http.MethodGet, path, nil, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	total, _ := strconv.Atoi(headers.Get("X-Total-Count"))
+	page := &SnippetPage{Snippets: body.Snippets, Total: total}
+	if body.NextCursor != nil {
+		page.NextCursor = *body.NextCursor
+	}
+	return page, nil
+}
+
+// CreateSnippet creates a snippet, which needs a token, and returns it as
+// it was saved.
+func (c *Client) CreateSnippet(ctx context.Context, s NewSnippet) (*Snippet, error) {
+	var body struct {
+		Snippet *Snippet `json:"snippet"`
+	}
+
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/snippets", s, &body)
+	if err != nil {
+		return nil, err
+	}
+	return body.Snippet, nil
+}
+
+// do sends a request, retrying it if that's safe, and decodes the response
+// into dst. It returns the response headers.
+func (c *Client) do(ctx context.Context, method, path string, input, dst any) (http.Header, error) {
+	var payload []byte
+	if input != nil {
+		var err error
+		payload, err = json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		rs, err := c.send(ctx, method, path, payload)
+
+		if attempt < c.MaxRetries && retryable(method, rs, err) {
+			delay := c.backoff(attempt, rs)
+			if rs != nil {
+				io.Copy(io.Discard, rs.Body)
+				rs.Body.Close()
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		defer rs.Body.Close()
+
+		if rs.StatusCode >= 400 {
+			return nil, decodeError(rs)
+		}
+
+		if err = json.NewDecoder(rs.Body).Decode(dst); err != nil {
+			return nil, fmt.Errorf("client: decoding response: %w", err)
+		}
+		return rs.Header, nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+// retryable reports whether a request which got rs or err is worth trying
+// again. Requests which create something are only retried when the server
+// is known not to have acted on them, so that snippets aren't created twice.
+func retryable(method string, rs *http.Response, err error) bool {
+	if err != nil {
+		// The context being cancelled or running out is final.
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		return method == http.MethodGet
+	}
+
+	switch rs.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry: what the server
+// asked for in Retry-After if it said, or else an exponentially growing
+// delay with some jitter, so that clients which failed together don't all
+// come back together.
+func (c *Client) backoff(attempt int, rs *http.Response) time.Duration {
+	if rs != nil {
+		if seconds, err := strconv.Atoi(rs.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, c.MaxBackoff)
+		}
+	}
+
+	delay := c.Backoff << attempt
+	if delay <= 0 || delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// decodeError turns an error response into an *Error. The API sends either
+// a message or, for invalid snippets, an object of field errors.
+func decodeError(rs *http.Response) error {
+	e := &Error{StatusCode: rs.StatusCode, Message: http.StatusText(rs.StatusCode)}
+
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	b, err := io.ReadAll(io.LimitReader(rs.Body, 1<<20))
+	if err != nil || json.Unmarshal(b, &body) != nil || body.Error == nil {
+		if text := strings.TrimSpace(string(b)); text != "" && err == nil {
+			e.Message = text
+		}
+		return e
+	}
+
+	if json.Unmarshal(body.Error, &e.Message) != nil {
+		json.Unmarshal(body.Error, &e.FieldErrors)
+		e.Message = "invalid snippet"
+	}
+	return e
+}
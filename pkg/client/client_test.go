@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/ngohoang211020/snippetbox/internal/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a server which answers with handler,
+// and which retries without waiting.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL, "TOKEN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Backoff = time.Millisecond
+	c.MaxBackoff = time.Millisecond
+	return c
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int
+		wantRequests int32
+		wantErr      bool
+	}{
+		{"GET after 503", http.MethodGet, []int{503, 200}, 2, false},
+		{"GET after 429", http.MethodGet, []int{429, 429, 200}, 3, false},
+		{"GET gives up", http.MethodGet, []int{503, 503, 503, 503, 503}, 4, true},
+		{"GET 404 isn't retried", http.MethodGet, []int{404, 200}, 1, true},
+		{"POST after 429", http.MethodPost, []int{429, 201}, 2, false},
+		{"POST 503 isn't retried", http.MethodPost, []int{503, 201}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, r.Header.Get("Authorization"), "Bearer TOKEN")
+
+				n := requests.Add(1)
+				status := tt.statuses[n-1]
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				if status >= 400 {
+					w.Write([]byte(`{"error": "nope"}`))
+				} else {
+					w.Write([]byte(`{"snippet": {"id": "01HZX3V4Q8K2M5N7P9R1S3T5V7", "title": "Hello"}}`))
+				}
+			})
+
+			var err error
+			if tt.method == http.MethodGet {
+				_, err = c.GetSnippet(context.Background(), "01HZX3V4Q8K2M5N7P9R1S3T5V7")
+			} else {
+				_, err = c.CreateSnippet(context.Background(), NewSnippet{Title: "Hello", Content: "world", Expires: 7})
+			}
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, requests.Load(), tt.wantRequests)
+		})
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.Backoff = time.Hour
+	c.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetSnippet(ctx, "01HZX3V4Q8K2M5N7P9R1S3T5V7")
+	assert.Equal(t, errors.Is(err, context.DeadlineExceeded), true)
+}
+
+func TestErrors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "the requested resource could not be found"}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error": {"title": "This field cannot be blank"}}`))
+		}
+	})
+
+	_, err := c.GetSnippet(context.Background(), "01HZX3V4Q8K2M5N7P9R1S3T5V7")
+	assert.Equal(t, IsNotFound(err), true)
+	assert.StringContains(t, err.Error(), "the requested resource could not be found")
+
+	_, err = c.CreateSnippet(context.Background(), NewSnippet{})
+	var e *Error
+	assert.Equal(t, errors.As(err, &e), true)
+	assert.Equal(t, e.StatusCode, http.StatusUnprocessableEntity)
+	assert.Equal(t, e.FieldErrors["title"], "This field cannot be blank")
+	assert.Equal(t, IsNotFound(err), false)
+}
+
+func TestNew(t *testing.T) {
+	for _, u := range []string{"", "snippets.example.com", "ftp://snippets.example.com"} {
+		_, err := New(u, "")
+		assert.Equal(t, err != nil, true)
+	}
+
+	c, err := New("https://snippets.example.com/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, c.baseURL.String(), "https://snippets.example.com")
+}
@@ -5,4 +5,602 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// Show the files of a multi-file snippet one at a time, switching between
+// them with the tabs above. Without JavaScript all the files are listed.
+var fileTabs = document.querySelectorAll(".file-tabs a");
+if (fileTabs.length > 0) {
+	var showFile = function(id) {
+		for (var i = 0; i < fileTabs.length; i++) {
+			var tab = fileTabs[i];
+			var selected = tab.getAttribute("href") == "#" + id;
+			tab.classList.toggle("live", selected);
+			document.getElementById(tab.getAttribute("href").slice(1)).hidden = !selected;
+		}
+	};
+	for (var i = 0; i < fileTabs.length; i++) {
+		fileTabs[i].addEventListener("click", function(e) {
+			e.preventDefault();
+			showFile(this.getAttribute("href").slice(1));
+		});
+	}
+	var initial = window.location.hash.slice(1);
+	showFile(document.getElementById(initial) ? initial : fileTabs[0].getAttribute("href").slice(1));
+}
+
+// Let the create form add more file sections, by copying the last (empty)
+// one and renumbering its fields.
+var addFile = document.getElementById("add-file");
+if (addFile) {
+	addFile.hidden = false;
+	addFile.addEventListener("click", function() {
+		var sections = document.querySelectorAll("#snippet-files .file-section");
+		var last = sections[sections.length - 1];
+		var section = last.cloneNode(true);
+		var fields = section.querySelectorAll("input, select, textarea");
+		for (var i = 0; i < fields.length; i++) {
+			fields[i].name = fields[i].name.replace(/^files\[\d+\]/, "files[" + sections.length + "]");
+			fields[i].value = "";
+		}
+		var errors = section.querySelectorAll(".error");
+		for (var i = 0; i < errors.length; i++) {
+			errors[i].remove();
+		}
+		last.parentNode.appendChild(section);
+	});
+}
+
+// Register and sign in with passkeys. The server's WebAuthn options and the
+// browser's credentials both carry binary fields, which travel as base64url
+// strings in JSON. Without WebAuthn support the forms stay hidden and
+// signing in with a password works as before.
+var passkeyForms = document.querySelectorAll("#passkey-register, #passkey-login");
+if (passkeyForms.length > 0 && window.PublicKeyCredential) {
+	var fromBase64URL = function(s) {
+		s = s.replace(/-/g, "+").replace(/_/g, "/");
+		while (s.length % 4) {
+			s += "=";
+		}
+		return Uint8Array.from(atob(s), function(c) { return c.charCodeAt(0); }).buffer;
+	};
+	var toBase64URL = function(buf) {
+		var s = String.fromCharCode.apply(null, new Uint8Array(buf));
+		return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	};
+	var postJSON = function(form, url, body) {
+		return fetch(url, {
+			method: "POST",
+			credentials: "same-origin",
+			headers: {
+				"Content-Type": "application/json",
+				"X-CSRF-Token": form.elements["csrf_token"].value
+			},
+			body: body === undefined ? undefined : JSON.stringify(body)
+		}).then(function(rs) {
+			return rs.json().then(function(data) {
+				if (!rs.ok) {
+					throw new Error(data.error || "Something went wrong.");
+				}
+				return data;
+			});
+		});
+	};
+	var showError = function(form, err) {
+		var el = form.querySelector(".error");
+		el.textContent = err.message;
+		el.hidden = false;
+	};
+
+	var register = document.getElementById("passkey-register");
+	if (register) {
+		register.hidden = false;
+		document.getElementById("passkey-unsupported").hidden = true;
+		register.addEventListener("submit", function(e) {
+			e.preventDefault();
+			postJSON(register, "/account/security/passkeys/register/begin").then(function(data) {
+				var options = data.options.publicKey;
+				options.challenge = fromBase64URL(options.challenge);
+				options.user.id = fromBase64URL(options.user.id);
+				(options.excludeCredentials || []).forEach(function(c) { c.id = fromBase64URL(c.id); });
+				return navigator.credentials.create({publicKey: options});
+			}).then(function(cred) {
+				var name = encodeURIComponent(register.elements["name"].value);
+				return postJSON(register, "/account/security/passkeys/register/finish?name=" + name, {
+					id: cred.id,
+					rawId: toBase64URL(cred.rawId),
+					type: cred.type,
+					response: {
+						clientDataJSON: toBase64URL(cred.response.clientDataJSON),
+						attestationObject: toBase64URL(cred.response.attestationObject),
+						transports: cred.response.getTransports ? cred.response.getTransports() : []
+					}
+				});
+			}).then(function(data) {
+				window.location = data.redirect;
+			}).catch(function(err) {
+				showError(register, err);
+			});
+		});
+	}
+
+	var login = document.getElementById("passkey-login");
+	if (login) {
+		login.hidden = false;
+		login.addEventListener("submit", function(e) {
+			e.preventDefault();
+			postJSON(login, "/user/login/passkey/begin").then(function(data) {
+				var options = data.options.publicKey;
+				options.challenge = fromBase64URL(options.challenge);
+				return navigator.credentials.get({publicKey: options});
+			}).then(function(cred) {
+				return postJSON(login, "/user/login/passkey/finish", {
+					id: cred.id,
+					rawId: toBase64URL(cred.rawId),
+					type: cred.type,
+					response: {
+						clientDataJSON: toBase64URL(cred.response.clientDataJSON),
+						authenticatorData: toBase64URL(cred.response.authenticatorData),
+						signature: toBase64URL(cred.response.signature),
+						userHandle: cred.response.userHandle ? toBase64URL(cred.response.userHandle) : null
+					}
+				});
+			}).then(function(data) {
+				window.location = data.redirect;
+			}).catch(function(err) {
+				showError(login, err.name === "NotAllowedError" ? new Error("Passkey sign-in was cancelled. You can use your password instead.") : err);
+			});
+		});
+	}
+}
+
+// Autosave the create form as a draft, a second after the user stops
+// typing, so their work survives navigating away or a crash.
+var createForm = document.querySelector("form[action='/snippet/create']");
+
+// isEncrypting reports whether the snippet being created is to be encrypted
+// in the browser, in which case its content must never be sent in the clear.
+var isEncrypting = function() {
+	var box = createForm && createForm.querySelector("input[name='content_encrypted']");
+	return !!(box && box.checked);
+};
+
+if (createForm) {
+	var draftTimer = null;
+	var saveDraft = function() {
+		if (isEncrypting()) {
+			return;
+		}
+		fetch("/snippet/draft", {
+			method: "POST",
+			credentials: "same-origin",
+			body: new URLSearchParams(new FormData(createForm))
+		});
+	};
+	var scheduleDraft = function() {
+		clearTimeout(draftTimer);
+		draftTimer = setTimeout(saveDraft, 1000);
+	};
+	createForm.addEventListener("input", scheduleDraft);
+	createForm.addEventListener("change", scheduleDraft);
+	createForm.addEventListener("submit", function() {
+		clearTimeout(draftTimer);
+	});
+	createForm.addEventListener("change", function(e) {
+		// Throw away any draft saved before encryption was chosen.
+		if (e.target.name == "content_encrypted" && e.target.checked) {
+			clearTimeout(draftTimer);
+			fetch("/snippet/draft/delete", {
+				method: "POST",
+				credentials: "same-origin",
+				body: new URLSearchParams({csrf_token: createForm.querySelector("input[name='csrf_token']").value})
+			});
+		}
+	});
+}
+
+// Preview the snippet being created. The server renders the form with the
+// same template as the snippet's page, so the preview is exactly what will
+// be published. Encrypted snippets can't be previewed, as their content
+// must never be sent in the clear.
+var previewTabs = createForm && createForm.querySelector(".preview-tabs");
+if (previewTabs) {
+	var preview = document.getElementById("snippet-preview");
+	var tabs = previewTabs.querySelectorAll("button");
+	var showTab = function(tab) {
+		for (var i = 0; i < tabs.length; i++) {
+			tabs[i].classList.toggle("live", tabs[i] === tab);
+		}
+		var previewing = tab.dataset.tab === "preview";
+		createForm.classList.toggle("previewing", previewing);
+		preview.hidden = !previewing;
+	};
+
+	previewTabs.hidden = false;
+	for (var i = 0; i < tabs.length; i++) {
+		tabs[i].addEventListener("click", function(e) {
+			var tab = e.target;
+			if (tab.dataset.tab !== "preview") {
+				showTab(tab);
+				return;
+			}
+			if (isEncrypting()) {
+				window.alert("Encrypted snippets can't be previewed, as their content never leaves your browser unencrypted.");
+				return;
+			}
+			preview.textContent = "Loading preview\u2026";
+			showTab(tab);
+			fetch(tab.dataset.url, {
+				method: "POST",
+				credentials: "same-origin",
+				body: new URLSearchParams(new FormData(createForm))
+			}).then(function(res) {
+				if (!res.ok) {
+					throw new Error("The preview couldn't be made (" + res.status + ").");
+				}
+				return res.text();
+			}).then(function(html) {
+				preview.innerHTML = html;
+			}).catch(function(err) {
+				preview.textContent = err.message;
+			});
+		});
+	}
+}
+
+// Client-side encryption. Snippet content is encrypted with AES-GCM under a
+// fresh key, and only the ciphertext is sent to the server, as
+// "v1:<nonce>:<ciphertext>" in base64url. The key goes in the fragment of
+// the snippet's URL, which browsers never send to the server.
+var base64url = function(bytes) {
+	var s = "";
+	for (var i = 0; i < bytes.length; i++) {
+		s += String.fromCharCode(bytes[i]);
+	}
+	return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+};
+var unbase64url = function(s) {
+	var bin = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+	var bytes = new Uint8Array(bin.length);
+	for (var i = 0; i < bin.length; i++) {
+		bytes[i] = bin.charCodeAt(i);
+	}
+	return bytes;
+};
+
+if (createForm) {
+	createForm.addEventListener("submit", function(e) {
+		if (!isEncrypting()) {
+			return;
+		}
+		e.preventDefault();
+
+		// Never fall back to sending the plaintext.
+		if (!window.crypto || !crypto.subtle) {
+			window.alert("This browser can't encrypt snippets. Untick \"Encrypt in my browser\" to publish it unencrypted.");
+			return;
+		}
+
+		var textarea = createForm.querySelector("textarea[name='content']");
+		var plaintext = textarea.value;
+		var iv = crypto.getRandomValues(new Uint8Array(12));
+		var key;
+
+		crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt", "decrypt"]).then(function(k) {
+			key = k;
+			return crypto.subtle.encrypt({name: "AES-GCM", iv: iv}, key, new TextEncoder().encode(plaintext));
+		}).then(function(ciphertext) {
+			var body = new URLSearchParams(new FormData(createForm));
+			body.set("content", "v1:" + base64url(iv) + ":" + base64url(new Uint8Array(ciphertext)));
+			return fetch(createForm.action, {method: "POST", credentials: "same-origin", body: body});
+		}).then(function(res) {
+			if (res.ok && res.redirected) {
+				return crypto.subtle.exportKey("raw", key).then(function(raw) {
+					window.location = res.url + "#" + base64url(new Uint8Array(raw));
+				});
+			}
+			// Show the errors from the re-rendered form, but put the
+			// plaintext back rather than the ciphertext the server saw.
+			return res.text().then(function(html) {
+				var fresh = new DOMParser().parseFromString(html, "text/html").querySelector("form[action='/snippet/create']");
+				if (fresh) {
+					createForm.innerHTML = fresh.innerHTML;
+					createForm.querySelector("textarea[name='content']").value = plaintext;
+				}
+			});
+		});
+	});
+}
+
+var encrypted = document.querySelectorAll("code.encrypted");
+if (encrypted.length > 0 && window.crypto && crypto.subtle && location.hash.length > 1) {
+	crypto.subtle.importKey("raw", unbase64url(location.hash.slice(1)), "AES-GCM", false, ["decrypt"]).then(function(key) {
+		encrypted.forEach(function(code) {
+			var parts = code.dataset.ciphertext.split(":");
+			crypto.subtle.decrypt({name: "AES-GCM", iv: unbase64url(parts[1])}, key, unbase64url(parts[2])).then(function(plaintext) {
+				code.textContent = new TextDecoder().decode(plaintext);
+			}, function() {
+				code.textContent = "This snippet couldn't be decrypted. Check that you have the whole link.";
+			});
+		});
+	}, function() {
+		encrypted.forEach(function(code) {
+			code.textContent = "The key in this link isn't valid. Check that you have the whole link.";
+		});
+	});
+}
+
+// Check the signup and create forms as the user fills them in, using the
+// same rules as the server. Errors are shown for the fields the user has
+// left; submitting the form checks everything again as usual. Warnings,
+// about content which doesn't parse in its language, are shown after the
+// field and don't stop the form being submitted, and so are the counts of
+// the content's lines, words and bytes, which are kept up to date as it's
+// typed or pasted.
+var validatedForms = [
+	{form: document.querySelector("form[action='/user/signup']"), url: "/validate/signup"},
+	{form: createForm, url: "/validate/snippet", skip: function(name) { return name == "content" && isEncrypting(); }}
+];
+validatedForms.forEach(function(v) {
+	if (!v.form) {
+		return;
+	}
+	var touched = {};
+	var formatBytes = function(n) {
+		if (n < 1024) {
+			return n + " bytes";
+		}
+		if (n < 1024 * 1024) {
+			return (n / 1024).toFixed(1).replace(/\.0$/, "") + " KB";
+		}
+		return (n / 1024 / 1024).toFixed(1).replace(/\.0$/, "") + " MB";
+	};
+	var showStats = function(field, stats, limits) {
+		var small = field.parentNode.querySelector("small.content-stats[data-for='" + field.name + "']");
+		if (!stats) {
+			if (small) {
+				small.remove();
+			}
+			return;
+		}
+		if (!small) {
+			small = document.createElement("small");
+			small.className = "content-stats";
+			small.dataset.for = field.name;
+			var after = field.nextElementSibling && field.nextElementSibling.classList.contains("warning") ? field.nextElementSibling : field;
+			field.parentNode.insertBefore(small, after.nextSibling);
+		}
+		var lines = stats.lines + (stats.lines == 1 ? " line" : " lines");
+		if (limits.max_lines) {
+			lines += " of " + limits.max_lines.toLocaleString();
+		}
+		var size = formatBytes(stats.bytes);
+		if (limits.max_bytes) {
+			size += " of " + formatBytes(limits.max_bytes);
+		}
+		small.textContent = [lines, stats.words + (stats.words == 1 ? " word" : " words"), size].join(" · ");
+	};
+	var showErrors = function(errors, warnings, stats, limits) {
+		warnings = warnings || {};
+		stats = stats || {};
+		limits = limits || {};
+		var fields = v.form.querySelectorAll("input[name], select[name], textarea[name]");
+		for (var i = 0; i < fields.length; i++) {
+			var field = fields[i];
+			if (!touched[field.name]) {
+				continue;
+			}
+			var label = field.previousElementSibling;
+			if (!label || !label.classList.contains("error")) {
+				label = null;
+			}
+			var message = errors[field.name];
+			if (message && !label) {
+				label = document.createElement("label");
+				label.className = "error";
+				field.parentNode.insertBefore(label, field);
+			}
+			if (message) {
+				label.textContent = message;
+			} else if (label) {
+				label.remove();
+			}
+			var warning = field.nextElementSibling;
+			if (!warning || !warning.classList.contains("warning")) {
+				warning = null;
+			}
+			if (warnings[field.name] && !warning) {
+				warning = document.createElement("p");
+				warning.className = "warning";
+				field.parentNode.insertBefore(warning, field.nextSibling);
+			}
+			if (warnings[field.name]) {
+				warning.textContent = warnings[field.name].join(" ");
+			} else if (warning) {
+				warning.remove();
+			}
+			if (field.tagName == "TEXTAREA") {
+				showStats(field, stats[field.name], limits);
+			}
+		}
+	};
+	var check = function(e) {
+		if (!e.target.name || e.target.name == "csrf_token") {
+			return;
+		}
+		touched[e.target.name] = true;
+		var body = new URLSearchParams();
+		new FormData(v.form).forEach(function(value, name) {
+			if (v.skip && v.skip(name)) {
+				return;
+			}
+			if (touched[name] || name == "csrf_token") {
+				body.append(name, value);
+			}
+		});
+		fetch(v.url, {method: "POST", credentials: "same-origin", body: body})
+			.then(function(res) { return res.ok ? res.json() : null; })
+			.then(function(data) { if (data) showErrors(data.errors, data.warnings, data.stats, data.limits); });
+	};
+	v.form.addEventListener("focusout", check);
+	// Content is checked while it's written too, a moment after the typing
+	// stops, so that its counts keep up and a paste which is too big is
+	// pointed out straight away.
+	var timer;
+	v.form.addEventListener("input", function(e) {
+		if (e.target.tagName != "TEXTAREA") {
+			return;
+		}
+		clearTimeout(timer);
+		timer = setTimeout(function() { check(e); }, 500);
+	});
+});
+
+// Solve the challenge page's proof-of-work challenge, by finding a number
+// which hashes together with the nonce to enough leading zero bits, and send
+// it instead of having the visitor answer the question. If the browser can't
+// hash, or the solution is rejected, the question is left to answer.
+var challengeForm = document.getElementById("challenge");
+if (challengeForm && window.crypto && crypto.subtle && challengeForm.querySelectorAll(".error").length == 0) {
+	var difficulty = parseInt(challengeForm.dataset.difficulty, 10);
+	var nonce = challengeForm.elements.nonce.value;
+	var leadingZeros = function(hash) {
+		var bytes = new Uint8Array(hash), zeros = 0;
+		for (var i = 0; i < bytes.length; i++) {
+			if (bytes[i] != 0) {
+				return zeros + Math.clz32(bytes[i]) - 24;
+			}
+			zeros += 8;
+		}
+		return zeros;
+	};
+	var attempt = function(n) {
+		crypto.subtle.digest("SHA-256", new TextEncoder().encode(nonce + ":" + n)).then(function(hash) {
+			if (leadingZeros(hash) >= difficulty) {
+				challengeForm.elements.solution.value = n;
+				challengeForm.submit();
+			} else {
+				attempt(n + 1);
+			}
+		});
+	};
+	if (nonce && difficulty >= 0) {
+		var questions = challengeForm.querySelectorAll(".question");
+		for (var i = 0; i < questions.length; i++) {
+			questions[i].hidden = true;
+		}
+		challengeForm.querySelector(".pow-status").hidden = false;
+		attempt(0);
+	}
+}
+
+// Save the edit form through the API every few seconds while it's being
+// changed. Each save sends the ETag of the version being edited in
+// If-Match, so if someone else has saved the snippet in the meantime the
+// save fails with 412 and the conflict is shown instead of their changes
+// being overwritten. Submitting the form saves as usual. The warnings under
+// the content are replaced with the ones for what was saved.
+var editForm = document.getElementById("snippet-edit");
+if (editForm) {
+	var editStatus = editForm.querySelector(".autosave-status");
+	var editConflict = editForm.querySelector(".autosave-conflict");
+	var editWarnings = editForm.querySelector(".lint-warnings");
+	var editDirty = false, editSaving = false;
+	editForm.addEventListener("input", function() {
+		editDirty = true;
+		editStatus.textContent = "";
+	});
+	setInterval(function() {
+		if (!editDirty || editSaving || !editConflict.hidden) {
+			return;
+		}
+		editDirty = false;
+		editSaving = true;
+		fetch(editForm.dataset.url, {
+			method: "PATCH",
+			credentials: "same-origin",
+			headers: {"Content-Type": "application/json", "If-Match": editForm.dataset.etag},
+			body: JSON.stringify({title: editForm.elements.title.value, content: editForm.elements.content.value})
+		}).then(function(res) {
+			editSaving = false;
+			if (res.status == 412) {
+				editConflict.hidden = false;
+				editStatus.textContent = "";
+				return;
+			}
+			if (!res.ok) {
+				editDirty = true;
+				editStatus.textContent = "Couldn't save your changes; trying again shortly.";
+				return;
+			}
+			// The ETag is "id.version"; keep the form's version in step, so
+			// that submitting it doesn't conflict with the saves made here.
+			var etag = res.headers.get("ETag");
+			editForm.dataset.etag = etag;
+			editForm.elements.version.value = etag.replace(/"/g, "").split(".")[1];
+			editStatus.textContent = "Saved at " + new Date().toLocaleTimeString() + ".";
+			return res.json().then(function(data) {
+				editWarnings.textContent = "";
+				(data.warnings["0"] || []).forEach(function(message) {
+					var p = document.createElement("p");
+					p.className = "warning";
+					p.textContent = message;
+					editWarnings.appendChild(p);
+				});
+			});
+		}, function() {
+			editSaving = false;
+			editDirty = true;
+		});
+	}, parseInt(editForm.dataset.autosave, 10) * 1000);
+}
+
+// Format buttons, beside each content box when the API is turned on. The
+// content is formatted by the server for the language chosen beside it, or
+// the snippet's own language on the edit page, and replaces what was there.
+// An input event is sent afterwards so that it's autosaved like typing.
+// Content which is to be encrypted is never sent.
+var formatButtons = document.querySelectorAll(".format-button");
+for (var i = 0; i < formatButtons.length; i++) {
+	formatButtons[i].hidden = false;
+}
+document.addEventListener("click", function(e) {
+	var button = e.target;
+	if (!button.classList || !button.classList.contains("format-button")) {
+		return;
+	}
+	if (isEncrypting()) {
+		window.alert("Encrypted snippets can't be formatted, as their content never leaves your browser unencrypted.");
+		return;
+	}
+
+	var textarea = button.previousElementSibling;
+	var language = button.dataset.language;
+	if (language === undefined) {
+		var select = button.form.elements[textarea.name.replace(/content$/, "language")];
+		language = select ? select.value : "";
+	}
+
+	button.disabled = true;
+	fetch(button.dataset.url, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify({content: textarea.value, language: language})
+	}).then(function(res) {
+		return res.json().then(function(data) {
+			if (!res.ok) {
+				var message = data.error;
+				if (typeof message === "object") {
+					message = message.content || message.language;
+				}
+				throw new Error(message || "The content couldn't be formatted (" + res.status + ").");
+			}
+			textarea.value = data.content;
+			textarea.dispatchEvent(new Event("input", {bubbles: true}));
+		});
+	}).catch(function(err) {
+		window.alert(err.message);
+	}).then(function() {
+		button.disabled = false;
+	});
+});